@@ -89,7 +89,7 @@ func (cli *RtspUdpPlaySession) HandleDescribe(client *rtsp.RtspClient, res rtsp.
 				log.Println("Got H264 Frame size:", len(sample.Sample), " timestamp:", sample.Timestamp)
 				cli.videoFile.Write(sample.Sample)
 			})
-		} else if t.Codec.Cid == rtsp.RTSP_CODEC_AAC {
+		} else if t.Codec.Cid == rtsp.RTSP_CODEC_AAC || t.Codec.Cid == rtsp.RTSP_CODEC_AAC_LATM {
 			if cli.audioFile == nil {
 				cli.audioFile, _ = os.OpenFile("audio.aac", os.O_CREATE|os.O_RDWR, 0666)
 			}
@@ -231,8 +231,14 @@ func ConvertADTSToASC(frame []byte) (*AudioSpecificConfiguration, error) {
     if len(frame) < 7 {
         return nil, errors.New("len of frame < 7")
     }
+    if frame[0] != 0xFF || frame[1]&0xF0 != 0xF0 {
+        return nil, errors.New("adts syncword not found")
+    }
     adts := NewAdtsFrameHeader()
     adts.Decode(frame)
+    if int(adts.Fix_Header.Sampling_frequency_index) >= len(AAC_Sampling_Idx) {
+        return nil, errors.New("invalid adts sampling_frequency_index")
+    }
     asc := NewAudioSpecificConfiguration()
     asc.Audio_object_type = adts.Fix_Header.Profile + 1
     asc.Channel_configuration = adts.Fix_Header.Channel_configuration
@@ -0,0 +1,147 @@
+package codec
+
+import "errors"
+
+// ATSC A/52 AC-3 and E-AC-3
+//
+// syncinfo() {
+//     syncword       16   0x0B77
+//     crc1           16
+//     fscod           2
+//     frmsizecod      6
+// }
+// bsi() {
+//     bsid            5
+//     bsmod           3
+//     acmod           3
+//     if((acmod & 0x1) && (acmod != 0x1)) cmixlev  2
+//     if(acmod & 0x4) surmixlev                    2
+//     if(acmod == 0x2) dsurmod                     2
+//     lfeon           1
+//     ......
+// }
+//
+// E-AC-3 syncframe (Annex E)
+// syncinfo() {
+//     syncword       16   0x0B77
+// }
+// bsi() {
+//     strmtyp         2
+//     substreamid     3
+//     frmsiz         11
+//     fscod           2
+//     if(fscod == 0x3) fscod2        2
+//     else numblkscod                2
+//     acmod           3
+//     lfeon           1
+//     bsid            5
+//     ......
+// }
+
+var AC3SampleRateTable [3]int = [3]int{48000, 44100, 32000}
+var EAC3ReducedSampleRateTable [3]int = [3]int{24000, 22050, 16000}
+var AC3BitrateTable [19]int = [19]int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+var AC3ChannelsTable [8]int = [8]int{2, 1, 2, 3, 3, 4, 4, 5}
+var EAC3BlocksTable [4]int = [4]int{1, 2, 3, 6}
+
+type AC3FrameHead struct {
+    IsEAC3      bool
+    Bsid        uint8
+    Fscod       uint8
+    Frmsizecod  uint8 //only AC-3
+    Strmtyp     uint8 //only E-AC-3
+    Substreamid uint8 //only E-AC-3
+    Acmod       uint8
+    Lfeon       uint8
+    SampleRate  int
+    Channels    int
+    SampleSize  int //samples per frame
+    FrameSize   int //bytes
+    Bitrate     int //bps
+}
+
+func DecodeAC3FrameHead(data []byte) (*AC3FrameHead, error) {
+    if len(data) < 7 {
+        return nil, errors.New("ac3 frame head must has 7 bytes")
+    }
+    if data[0] != 0x0B || data[1] != 0x77 {
+        return nil, errors.New("ac3 frame must start with 0x0B77")
+    }
+    head := &AC3FrameHead{}
+    head.Bsid = data[5] >> 3
+    bs := NewBitStream(data[2:])
+    if head.Bsid <= 10 {
+        bs.SkipBits(16) //crc1
+        head.Fscod = bs.Uint8(2)
+        head.Frmsizecod = bs.Uint8(6)
+        if head.Fscod == 3 || head.Frmsizecod >= 38 {
+            return nil, errors.New("illegal ac3 fscod or frmsizecod")
+        }
+        bs.SkipBits(8) //bsid bsmod
+        head.Acmod = bs.Uint8(3)
+        if head.Acmod&0x01 == 0x01 && head.Acmod != 0x01 {
+            bs.SkipBits(2)
+        }
+        if head.Acmod&0x04 == 0x04 {
+            bs.SkipBits(2)
+        }
+        if head.Acmod == 0x02 {
+            bs.SkipBits(2)
+        }
+        head.Lfeon = bs.GetBit()
+        head.SampleRate = AC3SampleRateTable[head.Fscod]
+        head.SampleSize = 1536
+        kbps := AC3BitrateTable[head.Frmsizecod>>1]
+        head.Bitrate = kbps * 1000
+        switch head.Fscod {
+        case 0:
+            head.FrameSize = kbps * 2 * 2
+        case 1:
+            head.FrameSize = (kbps*1000*1536/44100/16 + int(head.Frmsizecod&0x01)) * 2
+        case 2:
+            head.FrameSize = kbps * 3 * 2
+        }
+    } else if head.Bsid <= 16 {
+        head.IsEAC3 = true
+        head.Strmtyp = bs.Uint8(2)
+        head.Substreamid = bs.Uint8(3)
+        head.FrameSize = (int(bs.Uint16(11)) + 1) * 2
+        head.Fscod = bs.Uint8(2)
+        numblks := 6
+        if head.Fscod == 3 {
+            fscod2 := bs.Uint8(2)
+            if fscod2 == 3 {
+                return nil, errors.New("illegal eac3 fscod2")
+            }
+            head.SampleRate = EAC3ReducedSampleRateTable[fscod2]
+        } else {
+            numblks = EAC3BlocksTable[bs.Uint8(2)]
+            head.SampleRate = AC3SampleRateTable[head.Fscod]
+        }
+        head.Acmod = bs.Uint8(3)
+        head.Lfeon = bs.GetBit()
+        head.SampleSize = numblks * 256
+        head.Bitrate = head.FrameSize * 8 * head.SampleRate / head.SampleSize
+    } else {
+        return nil, errors.New("unsupport ac3 bsid")
+    }
+    head.Channels = AC3ChannelsTable[head.Acmod] + int(head.Lfeon)
+    return head, nil
+}
+
+func SplitAC3Frames(data []byte, onFrame func(head *AC3FrameHead, frame []byte)) error {
+    for len(data) > 0 {
+        head, err := DecodeAC3FrameHead(data)
+        if err != nil {
+            return err
+        }
+        if head.FrameSize > len(data) {
+            return errors.New("incomplete ac3 frame")
+        }
+        if onFrame != nil {
+            onFrame(head, data[:head.FrameSize])
+        }
+        data = data[head.FrameSize:]
+    }
+    return nil
+}
@@ -2,6 +2,8 @@ package codec
 
 import (
     "encoding/binary"
+    "errors"
+    "strings"
 )
 
 var BitMask [8]byte = [8]byte{0x01, 0x03, 0x07, 0x0F, 0x1F, 0x3F, 0x7F, 0xFF}
@@ -12,6 +14,8 @@ type BitStream struct {
     bitsOffset  int
     bitsmark    int
     bytemark    int
+    checked     bool
+    err         error
 }
 
 func NewBitStream(buf []byte) *BitStream {
@@ -24,6 +28,27 @@ func NewBitStream(buf []byte) *BitStream {
     }
 }
 
+// reading beyond the end of a checked bitstream returns zero instead of panic,
+// the first error is kept and returned by Err,it is used to parse the untrusted data
+func NewCheckedBitStream(buf []byte) *BitStream {
+    bs := NewBitStream(buf)
+    bs.checked = true
+    return bs
+}
+
+func (bs *BitStream) Err() error {
+    return bs.err
+}
+
+func (bs *BitStream) fail(reason string) {
+    if !bs.checked {
+        panic(reason)
+    }
+    if bs.err == nil {
+        bs.err = errors.New(strings.ToLower(reason))
+    }
+}
+
 func (bs *BitStream) Uint8(n int) uint8 {
     return uint8(bs.GetBits(n))
 }
@@ -38,10 +63,12 @@ func (bs *BitStream) Uint32(n int) uint32 {
 
 func (bs *BitStream) GetBytes(n int) []byte {
     if bs.bytesOffset+n > len(bs.bits) {
-        panic("OUT OF RANGE")
+        bs.fail("OUT OF RANGE")
+        return nil
     }
     if bs.bitsOffset != 0 {
-        panic("invaild operation")
+        bs.fail("invaild operation")
+        return nil
     }
     data := make([]byte, n)
     copy(data, bs.bits[bs.bytesOffset:bs.bytesOffset+n])
@@ -52,7 +79,8 @@ func (bs *BitStream) GetBytes(n int) []byte {
 //n <= 64
 func (bs *BitStream) GetBits(n int) uint64 {
     if bs.bytesOffset >= len(bs.bits) {
-        panic("OUT OF RANGE")
+        bs.fail("OUT OF RANGE")
+        return 0
     }
     var ret uint64 = 0
     if 8-bs.bitsOffset >= n {
@@ -69,7 +97,8 @@ func (bs *BitStream) GetBits(n int) uint64 {
         bs.bitsOffset = 0
         for n > 0 {
             if bs.bytesOffset >= len(bs.bits) {
-                panic("OUT OF RANGE")
+                bs.fail("OUT OF RANGE")
+                return 0
             }
             if n >= 8 {
                 ret = ret<<8 | uint64(bs.bits[bs.bytesOffset])
@@ -87,7 +116,8 @@ func (bs *BitStream) GetBits(n int) uint64 {
 
 func (bs *BitStream) GetBit() uint8 {
     if bs.bytesOffset >= len(bs.bits) {
-        panic("OUT OF RANGE")
+        bs.fail("OUT OF RANGE")
+        return 0
     }
     ret := bs.bits[bs.bytesOffset] >> (7 - bs.bitsOffset) & 0x01
     bs.bitsOffset++
@@ -150,7 +180,15 @@ func (bs *BitStream) RemainData() []byte {
 func (bs *BitStream) ReadUE() uint64 {
     leadingZeroBits := 0
     for bs.GetBit() == 0 {
+        if bs.err != nil {
+            return 0
+        }
         leadingZeroBits++
+        //the longest code of h264/h265 is 32 bits
+        if leadingZeroBits > 32 && bs.checked {
+            bs.fail("exp-golomb code is too long")
+            return 0
+        }
     }
     if leadingZeroBits == 0 {
         return 0
@@ -139,3 +139,28 @@ func TestBitStream_ReadUE(t *testing.T) {
         })
     }
 }
+
+func TestBitStream_Checked(t *testing.T) {
+    bs := NewCheckedBitStream([]byte{0x00})
+    if got := bs.ReadUE(); got != 0 || bs.Err() == nil {
+        t.Errorf("ReadUE() = %v,err = %v,want out of range error", got, bs.Err())
+    }
+    bs = NewCheckedBitStream([]byte{0xFF})
+    if got := bs.GetBits(12); got != 0 || bs.Err() == nil {
+        t.Errorf("GetBits(12) = %v,err = %v,want out of range error", got, bs.Err())
+    }
+    bs = NewCheckedBitStream([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x80})
+    if got := bs.ReadUE(); got != 0 || bs.Err() == nil {
+        t.Errorf("ReadUE() = %v,err = %v,want too long error", got, bs.Err())
+    }
+    bs = NewCheckedBitStream([]byte{0x40})
+    if got := bs.ReadUE(); got != 1 || bs.Err() != nil {
+        t.Errorf("ReadUE() = %v,err = %v,want 1", got, bs.Err())
+    }
+    defer func() {
+        if recover() == nil {
+            t.Error("unchecked bitstream should panic")
+        }
+    }()
+    NewBitStream([]byte{0x00}).ReadUE()
+}
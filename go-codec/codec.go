@@ -6,12 +6,16 @@ const (
     CODECID_VIDEO_H264 CodecID = iota
     CODECID_VIDEO_H265
     CODECID_VIDEO_VP8
+    CODECID_VIDEO_MPEG2
 
-    CODECID_AUDIO_AAC CodecID = iota + 98
+    CODECID_AUDIO_AAC CodecID = iota + 97
     CODECID_AUDIO_G711A
     CODECID_AUDIO_G711U
     CODECID_AUDIO_OPUS
     CODECID_AUDIO_MP3
+    CODECID_AUDIO_AC3
+    CODECID_AUDIO_EAC3
+    CODECID_AUDIO_AAC_LATM //LOAS/LATM(ISO/IEC 14496-3 1.7),not ADTS
 
     CODECID_UNRECOGNIZED = 999
 )
@@ -72,6 +76,8 @@ func CodecString(codecid CodecID) string {
         return "H265"
     case CODECID_VIDEO_VP8:
         return "VP8"
+    case CODECID_VIDEO_MPEG2:
+        return "MPEG2"
     case CODECID_AUDIO_AAC:
         return "AAC"
     case CODECID_AUDIO_G711A:
@@ -82,6 +88,12 @@ func CodecString(codecid CodecID) string {
         return "OPUS"
     case CODECID_AUDIO_MP3:
         return "MP3"
+    case CODECID_AUDIO_AC3:
+        return "AC3"
+    case CODECID_AUDIO_EAC3:
+        return "EAC3"
+    case CODECID_AUDIO_AAC_LATM:
+        return "AAC_LATM"
     default:
         return "UNRECOGNIZED"
    }
@@ -117,8 +117,8 @@ func (sps *SPS) Decode(bs *BitStream) {
 		sps.Offset_for_non_ref_pic = bs.ReadSE()         // offset_for_non_ref_pic
 		sps.Offset_for_top_to_bottom_field = bs.ReadSE() // offset_for_top_to_bottom_field
 		num_ref_frames_in_pic_order_cnt_cycle := bs.ReadUE()
-		for i := 0; i < int(num_ref_frames_in_pic_order_cnt_cycle); i++ {
-			sps.Offset_for_ref_frame[i] = bs.ReadSE() // offset_for_ref_frame
+		for i := 0; i < int(num_ref_frames_in_pic_order_cnt_cycle) && bs.Err() == nil; i++ {
+			sps.Offset_for_ref_frame = append(sps.Offset_for_ref_frame, bs.ReadSE()) // offset_for_ref_frame
 		}
 	}
 	sps.Max_num_ref_frames = bs.ReadUE()
@@ -250,7 +250,30 @@ func GetH264Resolution(sps []byte) (width uint32, height uint32) {
 	bs := NewBitStream(sodb)
 	var s SPS
 	s.Decode(bs)
+	width, height = s.resolution()
+	return
+}
 
+// same as GetH264Resolution,but the truncated or malformed sps returns error instead of panic
+func ParseH264Resolution(sps []byte) (width uint32, height uint32, err error) {
+	start, sc := FindStartCode(sps, 0)
+	if start < 0 {
+		start, sc = 0, 0
+	}
+	if start+int(sc)+1 >= len(sps) {
+		return 0, 0, errors.New("h264 sps is too short")
+	}
+	bs := NewCheckedBitStream(CovertRbspToSodb(sps[start+int(sc)+1:]))
+	var s SPS
+	s.Decode(bs)
+	if bs.Err() != nil {
+		return 0, 0, bs.Err()
+	}
+	width, height = s.resolution()
+	return
+}
+
+func (s *SPS) resolution() (width uint32, height uint32) {
 	widthInSample := (uint32(s.Pic_width_in_mbs_minus1) + 1) * 16
 	widthCrop := uint32(s.Frame_crop_left_offset)*2 + uint32(s.Frame_crop_right_offset)*2
 	width = widthInSample - widthCrop
@@ -258,7 +281,6 @@ func GetH264Resolution(sps []byte) (width uint32, height uint32) {
 	heightInSample := ((2 - uint32(s.Frame_mbs_only_flag)) * (uint32(s.Pic_height_in_map_units_minus1) + 1) * 16)
 	heightCrop := uint32(s.Frame_crop_bottom_offset)*2 - uint32(s.Frame_crop_top_offset)*2
 	height = heightInSample - heightCrop
-
 	return
 }
 
@@ -562,6 +584,10 @@ func (h264Hrd *H264HrdParameters) Decode(bs *BitStream) {
 	h264Hrd.CpbCntMinus1 = bs.ReadUE()
 	h264Hrd.BitRateScale = bs.Uint8(4)
 	h264Hrd.CpbSizeScale = bs.Uint8(4)
+	if h264Hrd.CpbCntMinus1 > 31 {
+		bs.fail("cpb_cnt_minus1 > 31")
+		return
+	}
 
 	h264Hrd.H264BitRateCpbSizeCbrFlag = make([]H264BitRateCpbSizeCbrFlag, h264Hrd.CpbCntMinus1+1)
 
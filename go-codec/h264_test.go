@@ -68,6 +68,25 @@ func TestGetH264Resolution(t *testing.T) {
     }
 }
 
+func TestParseH264Resolution(t *testing.T) {
+    width, height, err := ParseH264Resolution(sps2)
+    if err != nil || width != 1920 || height != 1080 {
+        t.Errorf("ParseH264Resolution() = %d,%d,%v, want 1920,1080", width, height, err)
+    }
+    //poc type 1 with offset_for_ref_frame
+    poc1 := []byte{0x67, 0x42, 0x00, 0x1E, 0xF4, 0x4D, 0x1F, 0xE0, 0x38}
+    //the tail of sps2 is not parsed
+    for i := 0; i < 40; i++ {
+        if _, _, err := ParseH264Resolution(sps2[:i]); err == nil {
+            t.Errorf("ParseH264Resolution() truncated at %d should fail", i)
+        }
+    }
+    //must not panic
+    for i := 0; i <= len(poc1); i++ {
+        ParseH264Resolution(poc1[:i])
+    }
+}
+
 var spss1 [][]byte = [][]byte{{0x00, 0x00, 0x00, 0x01, 0x67, 0x64, 0x00, 0x0A, 0xAC, 0x72, 0x84, 0x44,
     0x26, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04, 0x00, 0x00, 0x03, 0x00, 0xCA, 0x3C, 0x48, 0x96, 0x11, 0x80}}
 var ppss1 [][]byte = [][]byte{{0x00, 0x00, 0x00, 0x01, 0x68, 0xE8, 0x43, 0x8F, 0x13, 0x21, 0x30}}
@@ -196,8 +196,10 @@ type H265RawSPS struct {
 
 //nalu without startcode
 func (sps *H265RawSPS) Decode(nalu []byte) {
-    sodb := CovertRbspToSodb(nalu)
-    bs := NewBitStream(sodb)
+    sps.decode(NewBitStream(CovertRbspToSodb(nalu)))
+}
+
+func (sps *H265RawSPS) decode(bs *BitStream) {
     hdr := H265NaluHdr{}
     hdr.Decode(bs)
     sps.Sps_video_parameter_set_id = bs.Uint8(4)
@@ -257,15 +259,16 @@ func (sps *H265RawSPS) Decode(nalu []byte) {
     }
     num_short_term_ref_pic_sets := bs.ReadUE()
     if num_short_term_ref_pic_sets > 64 {
-        panic("beyond HEVC_MAX_SHORT_TERM_REF_PIC_SETS")
+        bs.fail("beyond HEVC_MAX_SHORT_TERM_REF_PIC_SETS")
+        return
     }
     var num_delta_pocs [64]uint32
-    for i := 0; i < int(num_short_term_ref_pic_sets); i++ {
+    for i := 0; i < int(num_short_term_ref_pic_sets) && bs.Err() == nil; i++ {
         parse_rps(i, num_short_term_ref_pic_sets, num_delta_pocs, bs)
     }
     if bs.GetBit() == 1 {
         num_long_term_ref_pics_sps := bs.ReadUE()
-        for i := 0; i < int(num_long_term_ref_pics_sps); i++ {
+        for i := 0; i < int(num_long_term_ref_pics_sps) && bs.Err() == nil; i++ {
             length := Min(int(sps.Log2_max_pic_order_cnt_lsb_minus4+4), 16)
             bs.SkipBits(length)
             bs.SkipBits(1)
@@ -413,7 +416,8 @@ func skip_hrd_parameters(cprms_present_flag uint8, max_sub_layers_minus1 uint32,
         if low_delay_hrd_flag == 0 {
             cpb_cnt_minus1 = uint32(bs.ReadUE())
             if cpb_cnt_minus1 > 31 {
-                panic("cpb_cnt_minus1 > 31")
+                bs.fail("cpb_cnt_minus1 > 31")
+                return
             }
         }
         skip_sub_layer_hrd_parameters := func() {
@@ -461,7 +465,8 @@ func scaling_list_data(bs *BitStream) {
 func parse_rps(rps_idx int, nums_rps uint64, num_delta_pocs [64]uint32, bs *BitStream) {
     if rps_idx > 0 && bs.GetBit() > 0 {
         if rps_idx > int(nums_rps) {
-            panic("rps_idx > int(nums_rps)")
+            bs.fail("rps_idx > int(nums_rps)")
+            return
         }
         bs.SkipBits(1)
         bs.ReadUE()
@@ -480,7 +485,8 @@ func parse_rps(rps_idx int, nums_rps uint64, num_delta_pocs [64]uint32, bs *BitS
         num_negative_pics := bs.ReadUE()
         num_positive_pics := bs.ReadUE()
         if (num_negative_pics+num_positive_pics)*2 > uint64(bs.RemainBits()) {
-            panic("(num_negative_pics + num_positive_pics) * 2> uint64(bs.RemainBits())")
+            bs.fail("(num_negative_pics + num_positive_pics) * 2> uint64(bs.RemainBits())")
+            return
         }
         for i := 0; i < int(num_negative_pics); i++ {
             bs.ReadUE()
@@ -559,6 +565,26 @@ func GetH265Resolution(sps []byte) (width uint32, height uint32) {
     return
 }
 
+// same as GetH265Resolution,but the truncated or malformed sps returns error instead of panic
+func ParseH265Resolution(sps []byte) (width uint32, height uint32, err error) {
+    start, sc := FindStartCode(sps, 0)
+    if start < 0 {
+        start, sc = 0, 0
+    }
+    if start+int(sc) >= len(sps) {
+        return 0, 0, errors.New("h265 sps is too short")
+    }
+    bs := NewCheckedBitStream(CovertRbspToSodb(sps[start+int(sc):]))
+    h265sps := H265RawSPS{}
+    h265sps.decode(bs)
+    if bs.Err() != nil {
+        return 0, 0, bs.Err()
+    }
+    width = uint32(h265sps.Pic_width_in_luma_samples)
+    height = uint32(h265sps.Pic_height_in_luma_samples)
+    return
+}
+
 func GetVPSIdWithStartCode(vps []byte) uint8 {
     start, sc := FindStartCode(vps, 0)
     return GetVPSId(vps[start+int(sc):])
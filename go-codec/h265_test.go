@@ -96,6 +96,22 @@ func TestH265RawSPS_Decode(t *testing.T) {
 	}
 }
 
+func TestParseH265Resolution(t *testing.T) {
+	wantWidth, wantHeight := GetH265Resolution(sps)
+	width, height, err := ParseH265Resolution(sps)
+	if err != nil || width != wantWidth || height != wantHeight {
+		t.Errorf("ParseH265Resolution() = %d,%d,%v, want %d,%d", width, height, err, wantWidth, wantHeight)
+	}
+	for i := 0; i < len(sps)-8; i++ {
+		if _, _, err := ParseH265Resolution(sps[:i]); err == nil {
+			t.Errorf("ParseH265Resolution() truncated at %d should fail", i)
+		}
+	}
+	if _, _, err := ParseH265Resolution([]byte{0x42, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}); err == nil {
+		t.Error("ParseH265Resolution() of garbage should fail")
+	}
+}
+
 func TestH265RawPPS_Decode(t *testing.T) {
 
 	type args struct {
@@ -0,0 +1,384 @@
+package codec
+
+import "errors"
+
+// ISO/IEC 14496-3 1.7.2 LOAS(Low Overhead Audio Stream)
+// AudioSyncStream() {
+//     while (nextbits() == 0x2B7) {
+//         syncword                  11 bslbf
+//         audioMuxLengthBytes       13 uimsbf
+//         AudioMuxElement(1)
+//     }
+// }
+
+func SplitLOASFrames(data []byte, onFrame func(loas []byte)) error {
+    for len(data) > 0 {
+        if len(data) < 3 {
+            return errors.New("loas frame must has 3 bytes")
+        }
+        if data[0] != 0x56 || data[1]&0xE0 != 0xE0 {
+            return errors.New("loas frame must start with 0x2B7")
+        }
+        size := 3 + (int(data[1]&0x1F)<<8 | int(data[2]))
+        if size > len(data) {
+            return errors.New("incomplete loas frame")
+        }
+        if onFrame != nil {
+            onFrame(data[:size])
+        }
+        data = data[size:]
+    }
+    return nil
+}
+
+// ISO/IEC 14496-3 1.7.3 StreamMuxConfig
+// only one program with one layer is supported,which is used by all the rtp(rfc3016/rfc6416) streams
+// StreamMuxConfig() {
+//     audioMuxVersion                        1
+//     if (audioMuxVersion == 1)
+//         audioMuxVersionA                   1
+//     if (audioMuxVersionA == 0) {
+//         if (audioMuxVersion == 1)
+//             taraBufferFullness = LatmGetValue()
+//         allStreamsSameTimeFraming          1
+//         numSubFrames                       6
+//         numProgram                         4
+//         numLayer                           3
+//         AudioSpecificConfig()
+//         frameLengthType                    3
+//         ......
+//         otherDataPresent                   1
+//         crcCheckPresent                    1
+//     }
+// }
+
+type StreamMuxConfig struct {
+    AudioMuxVersion           uint8
+    AllStreamsSameTimeFraming uint8
+    NumSubFrames              uint8 //the number of PayloadMux in AudioMuxElement is NumSubFrames+1
+    Asc                       []byte
+    FrameLengthType           uint8
+    LatmBufferFullness        uint8
+    FrameLength               uint16 //frameLengthType 1,the payload is (FrameLength+20)*8 bits
+    OtherDataPresent          uint8
+    OtherDataLenBits          uint32
+    CrcCheckPresent           uint8
+    CrcCheckSum               uint8
+}
+
+func NewStreamMuxConfig(asc []byte) *StreamMuxConfig {
+    return &StreamMuxConfig{
+        AllStreamsSameTimeFraming: 1,
+        Asc:                       asc,
+        LatmBufferFullness:        0xFF,
+    }
+}
+
+func (smc *StreamMuxConfig) Decode(buf []byte) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = errors.New("incomplete StreamMuxConfig")
+        }
+    }()
+    return smc.decode(NewBitStream(buf))
+}
+
+func (smc *StreamMuxConfig) decode(bs *BitStream) error {
+    smc.AudioMuxVersion = bs.GetBit()
+    if smc.AudioMuxVersion == 1 {
+        if bs.GetBit() == 1 {
+            return errors.New("unsupport audioMuxVersionA 1")
+        }
+        latmGetValue(bs) //taraBufferFullness
+    }
+    smc.AllStreamsSameTimeFraming = bs.GetBit()
+    smc.NumSubFrames = bs.Uint8(6)
+    if bs.Uint8(4) != 0 || bs.Uint8(3) != 0 {
+        return errors.New("unsupport multiple programs or layers in latm")
+    }
+    if smc.AudioMuxVersion == 0 {
+        bs.Markdot()
+        if err := skipAudioSpecificConfig(bs); err != nil {
+            return err
+        }
+        n := bs.DistanceFromMarkDot()
+        bs.UnRead(n)
+        smc.Asc = copyBits(bs, n)
+    } else {
+        n := int(latmGetValue(bs))
+        smc.Asc = copyBits(bs, n)
+    }
+    smc.FrameLengthType = bs.Uint8(3)
+    switch smc.FrameLengthType {
+    case 0:
+        smc.LatmBufferFullness = bs.Uint8(8)
+    case 1:
+        smc.FrameLength = bs.Uint16(9)
+    default:
+        return errors.New("unsupport latm frameLengthType of CELP or HVXC")
+    }
+    smc.OtherDataPresent = bs.GetBit()
+    if smc.OtherDataPresent == 1 {
+        if smc.AudioMuxVersion == 1 {
+            smc.OtherDataLenBits = latmGetValue(bs)
+        } else {
+            smc.OtherDataLenBits = 0
+            for {
+                esc := bs.GetBit()
+                smc.OtherDataLenBits = smc.OtherDataLenBits<<8 | bs.Uint32(8)
+                if esc == 0 {
+                    break
+                }
+            }
+        }
+    }
+    smc.CrcCheckPresent = bs.GetBit()
+    if smc.CrcCheckPresent == 1 {
+        smc.CrcCheckSum = bs.Uint8(8)
+    }
+    return nil
+}
+
+func (smc *StreamMuxConfig) Encode() []byte {
+    bsw := NewBitStreamWriter(16 + len(smc.Asc))
+    smc.encode(bsw)
+    return bsw.Bits()
+}
+
+func (smc *StreamMuxConfig) encode(bsw *BitStreamWriter) {
+    ascBits := len(smc.Asc) * 8
+    if smc.AudioMuxVersion == 0 {
+        //the AudioSpecificConfig is not byte aligned in StreamMuxConfig
+        bs := NewBitStream(smc.Asc)
+        func() {
+            defer func() { recover() }()
+            if skipAudioSpecificConfig(bs) == nil {
+                ascBits = len(smc.Asc)*8 - bs.RemainBits()
+            }
+        }()
+    }
+    bsw.PutUint8(smc.AudioMuxVersion, 1)
+    if smc.AudioMuxVersion == 1 {
+        bsw.PutUint8(0, 1) //audioMuxVersionA
+        latmPutValue(bsw, 0xFF)
+    }
+    bsw.PutUint8(smc.AllStreamsSameTimeFraming, 1)
+    bsw.PutUint8(smc.NumSubFrames, 6)
+    bsw.PutUint8(0, 4) //numProgram
+    bsw.PutUint8(0, 3) //numLayer
+    if smc.AudioMuxVersion == 1 {
+        latmPutValue(bsw, uint32(ascBits))
+    }
+    putBits(bsw, NewBitStream(smc.Asc), ascBits)
+    bsw.PutUint8(smc.FrameLengthType, 3)
+    if smc.FrameLengthType == 1 {
+        bsw.PutUint16(smc.FrameLength, 9)
+    } else {
+        bsw.PutUint8(smc.LatmBufferFullness, 8)
+    }
+    bsw.PutUint8(smc.OtherDataPresent, 1)
+    if smc.OtherDataPresent == 1 {
+        if smc.AudioMuxVersion == 1 {
+            latmPutValue(bsw, smc.OtherDataLenBits)
+        } else {
+            var tmp []uint8
+            for v := smc.OtherDataLenBits; ; v >>= 8 {
+                tmp = append([]uint8{uint8(v)}, tmp...)
+                if v < 256 {
+                    break
+                }
+            }
+            for i, v := range tmp {
+                if i == len(tmp)-1 {
+                    bsw.PutUint8(0, 1)
+                } else {
+                    bsw.PutUint8(1, 1)
+                }
+                bsw.PutUint8(v, 8)
+            }
+        }
+    }
+    bsw.PutUint8(smc.CrcCheckPresent, 1)
+    if smc.CrcCheckPresent == 1 {
+        bsw.PutUint8(smc.CrcCheckSum, 8)
+    }
+}
+
+// the samples of one raw aac frame,1024 or 960
+func (smc *StreamMuxConfig) FrameSamples() int {
+    asc := NewAudioSpecificConfiguration()
+    if asc.Decode(smc.Asc) == nil && asc.GA_framelength_flag == 1 {
+        return 960
+    }
+    return 1024
+}
+
+// AudioMuxElement(muxConfigPresent)
+// with muxConfigPresent,the config is updated by the in-band StreamMuxConfig,
+// onFrame is called with every raw aac frame of PayloadMux,
+// the AudioMuxElement is byte aligned,the consumed bytes are returned
+func SplitAudioMuxElement(data []byte, muxConfigPresent bool, config *StreamMuxConfig, onFrame func(aac []byte)) (n int, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = errors.New("incomplete AudioMuxElement")
+        }
+    }()
+    bs := NewBitStream(data)
+    if muxConfigPresent {
+        //useSameStreamMux
+        if bs.GetBit() == 0 {
+            if err = config.decode(bs); err != nil {
+                return 0, err
+            }
+        }
+    }
+    if len(config.Asc) == 0 {
+        return 0, errors.New("latm has no StreamMuxConfig")
+    }
+    if config.AllStreamsSameTimeFraming == 0 {
+        return 0, errors.New("unsupport latm chunks of allStreamsSameTimeFraming 0")
+    }
+    for i := 0; i <= int(config.NumSubFrames); i++ {
+        //PayloadLengthInfo
+        size := 0
+        if config.FrameLengthType == 1 {
+            size = int(config.FrameLength) + 20
+        } else {
+            for {
+                tmp := int(bs.Uint8(8))
+                size += tmp
+                if tmp != 255 {
+                    break
+                }
+            }
+        }
+        //PayloadMux
+        if size*8 > bs.RemainBits() {
+            return 0, errors.New("incomplete latm payload")
+        }
+        frame := copyBits(bs, size*8)
+        if onFrame != nil {
+            onFrame(frame)
+        }
+    }
+    if config.OtherDataPresent == 1 {
+        bs.SkipBits(int(config.OtherDataLenBits))
+    }
+    n = bs.ByteOffset()
+    if bs.bitsOffset > 0 {
+        n++
+    }
+    if n > len(data) {
+        return 0, errors.New("incomplete AudioMuxElement")
+    }
+    return n, nil
+}
+
+// AudioMuxElement(0) of one raw aac frame,StreamMuxConfig is out-of-band with frameLengthType 0
+func CreateAudioMuxElement(aac []byte) []byte {
+    element := make([]byte, 0, len(aac)+len(aac)/255+1)
+    size := len(aac)
+    for size >= 255 {
+        element = append(element, 255)
+        size -= 255
+    }
+    element = append(element, uint8(size))
+    return append(element, aac...)
+}
+
+func latmGetValue(bs *BitStream) uint32 {
+    bytesForValue := int(bs.Uint8(2))
+    value := uint32(0)
+    for i := 0; i <= bytesForValue; i++ {
+        value = value<<8 | bs.Uint32(8)
+    }
+    return value
+}
+
+func latmPutValue(bsw *BitStreamWriter, value uint32) {
+    bytesForValue := 0
+    for v := value >> 8; v > 0; v >>= 8 {
+        bytesForValue++
+    }
+    bsw.PutUint8(uint8(bytesForValue), 2)
+    for i := bytesForValue; i >= 0; i-- {
+        bsw.PutUint8(uint8(value>>(8*i)), 8)
+    }
+}
+
+// read n bits into the byte aligned buffer
+func copyBits(bs *BitStream, n int) []byte {
+    bsw := NewBitStreamWriter(n/8 + 1)
+    putBits(bsw, bs, n)
+    return bsw.Bits()
+}
+
+func putBits(bsw *BitStreamWriter, bs *BitStream, n int) {
+    for ; n > 0; n -= 8 {
+        if n >= 8 {
+            bsw.PutUint8(bs.Uint8(8), 8)
+        } else {
+            bsw.PutUint8(bs.Uint8(n), n)
+        }
+    }
+}
+
+func getAudioObjectType(bs *BitStream) uint8 {
+    aot := bs.Uint8(5)
+    if aot == 31 {
+        aot = 32 + bs.Uint8(6)
+    }
+    return aot
+}
+
+// ISO/IEC 14496-3 1.6.2.1 AudioSpecificConfig,
+// the audio object types of GASpecificConfig are supported
+func skipAudioSpecificConfig(bs *BitStream) error {
+    aot := getAudioObjectType(bs)
+    if bs.Uint8(4) == 0x0F {
+        bs.SkipBits(24)
+    }
+    channelConfiguration := bs.Uint8(4)
+    if aot == 5 || aot == 29 {
+        //explicit sbr/ps signaling
+        if bs.Uint8(4) == 0x0F {
+            bs.SkipBits(24)
+        }
+        aot = getAudioObjectType(bs)
+        if aot == 22 {
+            bs.SkipBits(4)
+        }
+    }
+    switch aot {
+    case 1, 2, 3, 4, 6, 7, 17, 19, 20, 21, 22, 23:
+        if channelConfiguration == 0 {
+            return errors.New("unsupport program_config_element in AudioSpecificConfig")
+        }
+        //GASpecificConfig
+        bs.SkipBits(1) //frameLengthFlag
+        if bs.GetBit() == 1 {
+            bs.SkipBits(14) //coreCoderDelay
+        }
+        extensionFlag := bs.GetBit()
+        if aot == 6 || aot == 20 {
+            bs.SkipBits(3) //layerNr
+        }
+        if extensionFlag == 1 {
+            if aot == 22 {
+                bs.SkipBits(16) //numOfSubFrame,layer_length
+            }
+            if aot == 17 || aot == 19 || aot == 20 || aot == 23 {
+                bs.SkipBits(3)
+            }
+            bs.SkipBits(1) //extensionFlag3
+        }
+    default:
+        return errors.New("unsupport audio object type in AudioSpecificConfig")
+    }
+    if aot >= 17 && aot <= 27 {
+        if bs.Uint8(2) >= 2 {
+            return errors.New("unsupport epConfig in AudioSpecificConfig")
+        }
+    }
+    return nil
+}
@@ -0,0 +1,86 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStreamMuxConfig(t *testing.T) {
+	//rfc3016 example,aac lc 44100 stereo
+	config := []byte{0x40, 0x00, 0x24, 0x20, 0x3F, 0xC0}
+	smc := &StreamMuxConfig{}
+	if err := smc.Decode(config); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(smc.Asc, []byte{0x12, 0x10}) || smc.FrameLengthType != 0 || smc.LatmBufferFullness != 0xFF || smc.NumSubFrames != 0 {
+		t.Fatalf("decode %+v", smc)
+	}
+	if got := NewStreamMuxConfig([]byte{0x12, 0x10}).Encode(); !bytes.Equal(got, config) {
+		t.Fatalf("encode %x", got)
+	}
+
+	v1 := NewStreamMuxConfig([]byte{0x11, 0x90})
+	v1.AudioMuxVersion = 1
+	v1.OtherDataPresent = 1
+	v1.OtherDataLenBits = 300
+	v1.CrcCheckPresent = 1
+	v1.CrcCheckSum = 0x5A
+	got := &StreamMuxConfig{}
+	if err := got.Decode(v1.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Asc, v1.Asc) || got.OtherDataLenBits != 300 || got.CrcCheckSum != 0x5A {
+		t.Fatalf("decode version 1 %+v", got)
+	}
+
+	if err := got.Decode(config[:3]); err == nil {
+		t.Fatal("decode incomplete StreamMuxConfig")
+	}
+}
+
+func TestSplitAudioMuxElement(t *testing.T) {
+	frame := make([]byte, 600)
+	for i := range frame {
+		frame[i] = byte(i)
+	}
+	element := CreateAudioMuxElement(frame)
+	if !bytes.Equal(element[:3], []byte{255, 255, 90}) {
+		t.Fatalf("PayloadLengthInfo %x", element[:3])
+	}
+
+	//out-of-band config
+	config := NewStreamMuxConfig([]byte{0x12, 0x10})
+	var frames [][]byte
+	n, err := SplitAudioMuxElement(append(element, element...), false, config, func(aac []byte) {
+		frames = append(frames, aac)
+	})
+	if err != nil || n != len(element) || len(frames) != 1 || !bytes.Equal(frames[0], frame) {
+		t.Fatalf("split out-of-band n=%d err=%v", n, err)
+	}
+
+	//in-band config,the payload is not byte aligned
+	bsw := NewBitStreamWriter(len(element) + 8)
+	bsw.PutUint8(0, 1) //useSameStreamMux
+	NewStreamMuxConfig([]byte{0x12, 0x10}).encode(bsw)
+	for _, b := range element {
+		bsw.PutUint8(b, 8)
+	}
+	inband := &StreamMuxConfig{}
+	frames = frames[:0]
+	n, err = SplitAudioMuxElement(bsw.Bits(), true, inband, func(aac []byte) {
+		frames = append(frames, aac)
+	})
+	if err != nil || n != len(bsw.Bits()) || len(frames) != 1 || !bytes.Equal(frames[0], frame) {
+		t.Fatalf("split in-band n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(inband.Asc, []byte{0x12, 0x10}) {
+		t.Fatalf("in-band asc %x", inband.Asc)
+	}
+
+	if _, err = SplitAudioMuxElement(element[:100], false, config, nil); err == nil {
+		t.Fatal("split incomplete AudioMuxElement")
+	}
+	if _, err = SplitAudioMuxElement(element, false, &StreamMuxConfig{}, nil); err == nil {
+		t.Fatal("split without StreamMuxConfig")
+	}
+}
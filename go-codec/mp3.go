@@ -202,6 +202,9 @@ func DecodeMp3Head(data []byte) (*MP3FrameHead, error) {
     if len(data) == 0 {
         return nil, errors.New("empty mp3 frame")
     }
+    if len(data) < 4 {
+        return nil, errors.New("mp3 frame head must has 4 bytes")
+    }
     bs := NewBitStream(data)
     syncWord := bs.GetBits(11)
     if syncWord != 0x7FF {
@@ -244,6 +247,9 @@ func DecodeMp3Head(data []byte) (*MP3FrameHead, error) {
     head.Copyright = bs.GetBit()
     head.Original = bs.GetBit()
     head.Emphasis = uint8(bs.GetBits(2))
+    if head.Version == VERSION_RESERVED || head.Layer == LAYER_RESERVED || head.BitrateIndex == 0x0F || head.SampleRateIndex == 0x03 {
+        return nil, errors.New("mp3 frame head has reserved value")
+    }
 
     if head.Layer == LAYER_1 {
         head.SampleSize = 384
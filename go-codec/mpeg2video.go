@@ -0,0 +1,123 @@
+package codec
+
+import "errors"
+
+// ISO/IEC 13818-2 start code value, 0x000001XX
+type MPEG2_START_CODE int
+
+const (
+    MPEG2_PICTURE_START_CODE   MPEG2_START_CODE = 0x00
+    MPEG2_SLICE_START_CODE_MIN MPEG2_START_CODE = 0x01
+    MPEG2_SLICE_START_CODE_MAX MPEG2_START_CODE = 0xAF
+    MPEG2_USER_DATA_START_CODE MPEG2_START_CODE = 0xB2
+    MPEG2_SEQUENCE_HEADER_CODE MPEG2_START_CODE = 0xB3
+    MPEG2_EXTENSION_START_CODE MPEG2_START_CODE = 0xB5
+    MPEG2_SEQUENCE_END_CODE    MPEG2_START_CODE = 0xB7
+    MPEG2_GROUP_START_CODE     MPEG2_START_CODE = 0xB8
+)
+
+// picture_coding_type
+const (
+    MPEG2_I_PICTURE = 1
+    MPEG2_P_PICTURE = 2
+    MPEG2_B_PICTURE = 3
+)
+
+// frame_rate_code 1~8
+var Mpeg2FrameRateTable [9]float64 = [9]float64{0, 24000.0 / 1001, 24, 25, 30000.0 / 1001, 30, 50, 60000.0 / 1001, 60}
+
+// sequence_header() {
+//     sequence_header_code                 32 bslbf
+//     horizontal_size_value                12 uimsbf
+//     vertical_size_value                  12 uimsbf
+//     aspect_ratio_information              4 uimsbf
+//     frame_rate_code                       4 uimsbf
+//     bit_rate_value                       18 uimsbf
+//     marker_bit                            1 bslbf
+//     vbv_buffer_size_value                10 uimsbf
+//     constrained_parameters_flag           1 bslbf
+//     ......
+// }
+
+type Mpeg2SequenceHeader struct {
+    Horizontal_size_value       uint16
+    Vertical_size_value         uint16
+    Aspect_ratio_information    uint8
+    Frame_rate_code             uint8
+    Bit_rate_value              uint32
+    Vbv_buffer_size_value       uint16
+    Constrained_parameters_flag uint8
+}
+
+// data must start with sequence header code 0x000001B3
+func (seq *Mpeg2SequenceHeader) Decode(data []byte) error {
+    if len(data) < 12 {
+        return errors.New("sequence header must has 12 bytes")
+    }
+    if data[0] != 0x00 || data[1] != 0x00 || data[2] != 0x01 || data[3] != byte(MPEG2_SEQUENCE_HEADER_CODE) {
+        return errors.New("sequence header must start with 0x000001B3")
+    }
+    bs := NewBitStream(data[4:])
+    seq.Horizontal_size_value = bs.Uint16(12)
+    seq.Vertical_size_value = bs.Uint16(12)
+    seq.Aspect_ratio_information = bs.Uint8(4)
+    seq.Frame_rate_code = bs.Uint8(4)
+    seq.Bit_rate_value = bs.Uint32(18)
+    bs.SkipBits(1)
+    seq.Vbv_buffer_size_value = bs.Uint16(10)
+    seq.Constrained_parameters_flag = bs.GetBit()
+    return nil
+}
+
+func (seq *Mpeg2SequenceHeader) FrameRate() float64 {
+    if seq.Frame_rate_code > 8 {
+        return 0
+    }
+    return Mpeg2FrameRateTable[seq.Frame_rate_code]
+}
+
+// find the start code of 0x000001XX in frame
+func findMpeg2StartCode(frame []byte, code MPEG2_START_CODE) int {
+    for offset := 0; offset < len(frame); {
+        pos, sc := FindStartCode(frame, offset)
+        if pos < 0 || pos+int(sc) >= len(frame) {
+            return -1
+        }
+        if frame[pos+int(sc)] == byte(code) {
+            return pos + int(sc) - 3
+        }
+        offset = pos + int(sc)
+    }
+    return -1
+}
+
+func GetMpeg2VideoResolution(frame []byte) (width uint32, height uint32) {
+    pos := findMpeg2StartCode(frame, MPEG2_SEQUENCE_HEADER_CODE)
+    if pos < 0 {
+        return 0, 0
+    }
+    var seq Mpeg2SequenceHeader
+    if seq.Decode(frame[pos:]) != nil {
+        return 0, 0
+    }
+    return uint32(seq.Horizontal_size_value), uint32(seq.Vertical_size_value)
+}
+
+// picture_header() {
+//     picture_start_code                   32 bslbf
+//     temporal_reference                   10 uimsbf
+//     picture_coding_type                   3 uimsbf
+//     ......
+// }
+// return picture_coding_type of the first picture, 0 if picture header is not found
+func Mpeg2VideoPictureType(frame []byte) int {
+    pos := findMpeg2StartCode(frame, MPEG2_PICTURE_START_CODE)
+    if pos < 0 || pos+6 > len(frame) {
+        return 0
+    }
+    return int(frame[pos+5]>>3) & 0x07
+}
+
+func IsMpeg2VideoKeyFrame(frame []byte) bool {
+    return Mpeg2VideoPictureType(frame) == MPEG2_I_PICTURE
+}
@@ -0,0 +1,311 @@
+package gb28181
+
+import (
+    "fmt"
+    "net"
+    "strconv"
+    "sync/atomic"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+)
+
+// catalog items of one MESSAGE,keep the message smaller than MTU
+const CATALOG_ITEMS_PER_MESSAGE = 4
+
+type DeviceConfig struct {
+    ID           string //sip id of device,20 digits
+    Domain       string //sip domain,the first 10 digits of ServerID by default
+    Password     string
+    ServerID     string
+    ServerAddr   string //udp,e.g. 192.168.1.2:5060
+    ListenAddr   string //udp,e.g. 0.0.0.0:5060
+    IP           string //address in Via/Contact/SDP,the local address to ServerAddr by default
+    Expires      int    //second,DEFAULT_EXPIRES if zero
+    Name         string
+    Manufacturer string
+    Model        string
+    Firmware     string
+    Channels     []CatalogItem
+}
+
+// Device is the device side of GB/T 28181,
+// it registers to the platform,answers the queries and sends the stream of INVITE
+type Device struct {
+    cfg      DeviceConfig
+    ua       *sipUA
+    server   *net.UDPAddr
+    callID   string
+    fromTag  string
+    sn       int32
+    sessions sessionTable
+    // called after ACK,the stream is sent by Session.Sender until BYE
+    OnPlay func(sess *Session)
+    OnBye  func(sess *Session)
+}
+
+func NewDevice(cfg DeviceConfig) *Device {
+    if cfg.Domain == "" && len(cfg.ServerID) >= 10 {
+        cfg.Domain = cfg.ServerID[:10]
+    }
+    if cfg.Expires == 0 {
+        cfg.Expires = DEFAULT_EXPIRES
+    }
+    dev := &Device{
+        cfg:     cfg,
+        callID:  newCallID(),
+        fromTag: newTag(),
+    }
+    dev.ua = newSipUA(dev.handleRequest)
+    return dev
+}
+
+func (dev *Device) Start() error {
+    var err error
+    if dev.server, err = net.ResolveUDPAddr("udp", dev.cfg.ServerAddr); err != nil {
+        return err
+    }
+    ip := dev.cfg.IP
+    if ip == "" {
+        if laddr, err := net.ResolveUDPAddr("udp", dev.cfg.ListenAddr); err == nil && (laddr.IP == nil || laddr.IP.IsUnspecified()) {
+            //the local address of the route to server
+            conn, err := net.DialUDP("udp", nil, dev.server)
+            if err != nil {
+                return err
+            }
+            ip = conn.LocalAddr().(*net.UDPAddr).IP.String()
+            conn.Close()
+        }
+    }
+    if err = dev.ua.listen(dev.cfg.ListenAddr, ip); err != nil {
+        return err
+    }
+    go dev.ua.serve()
+    return nil
+}
+
+func (dev *Device) Close() {
+    dev.ua.close()
+    dev.sessions.closeAll()
+}
+
+func (dev *Device) uri(id string) string {
+    return "sip:" + id + "@" + dev.cfg.Domain
+}
+
+func (dev *Device) Register() error {
+    return dev.register(dev.cfg.Expires)
+}
+
+func (dev *Device) Unregister() error {
+    return dev.register(0)
+}
+
+// REGISTER,answer the digest challenge if it is required
+func (dev *Device) register(expires int) error {
+    uri := dev.uri(dev.cfg.ServerID)
+    newRegister := func() *SipMessage {
+        req := NewSipRequest(SIP_REGISTER, uri)
+        req.AddHeader(SipVia, dev.ua.via())
+        req.AddHeader(SipFrom, "<"+dev.uri(dev.cfg.ID)+">;tag="+dev.fromTag)
+        req.AddHeader(SipTo, "<"+dev.uri(dev.cfg.ID)+">")
+        req.AddHeader(SipCallID, dev.callID)
+        req.AddHeader(SipCSeq, strconv.FormatUint(uint64(dev.ua.nextCSeq()), 10)+" "+SIP_REGISTER)
+        req.AddHeader(SipContact, "<sip:"+dev.cfg.ID+"@"+dev.ua.hostport()+">")
+        req.AddHeader(SipExpires, strconv.Itoa(expires))
+        return req
+    }
+    res, err := dev.ua.request(newRegister(), dev.server)
+    if err != nil {
+        return err
+    }
+    if res.StatusCode == SIP_STATUS_UNAUTHORIZED && res.Header(SipWWWAuthenticate) != "" {
+        auth := rtsp.NewDigestAuth(dev.cfg.ID, dev.cfg.Password, dev.cfg.Domain)
+        req := newRegister()
+        req.AddHeader(SipAuthorization, auth.Authorization(res.Header(SipWWWAuthenticate), SIP_REGISTER, uri))
+        if res, err = dev.ua.request(req, dev.server); err != nil {
+            return err
+        }
+    }
+    if res.StatusCode != SIP_STATUS_OK {
+        return fmt.Errorf("register failed: %d %s", res.StatusCode, res.Reason)
+    }
+    return nil
+}
+
+func (dev *Device) Keepalive() error {
+    msg := NewManscdp(MANSCDP_NOTIFY, CMD_KEEPALIVE, int(atomic.AddInt32(&dev.sn, 1)), dev.cfg.ID)
+    msg.Status = "OK"
+    return dev.sendMessage(msg)
+}
+
+func (dev *Device) sendMessage(msg *Manscdp) error {
+    body, err := msg.Encode()
+    if err != nil {
+        return err
+    }
+    req := dev.ua.newRequest(SIP_MESSAGE, dev.uri(dev.cfg.ServerID), dev.uri(dev.cfg.ID), dev.uri(dev.cfg.ServerID))
+    req.AddHeader(SipContentType, MANSCDP_CONTENT_TYPE)
+    req.Body = body
+    res, err := dev.ua.request(req, dev.server)
+    if err != nil {
+        return err
+    }
+    if res.StatusCode != SIP_STATUS_OK {
+        return fmt.Errorf("message %s failed: %d %s", msg.CmdType, res.StatusCode, res.Reason)
+    }
+    return nil
+}
+
+func (dev *Device) handleRequest(req *SipMessage, from *net.UDPAddr) {
+    switch req.Method {
+    case SIP_MESSAGE:
+        dev.onMessage(req, from)
+    case SIP_INVITE:
+        dev.onInvite(req, from)
+    case SIP_ACK:
+        if sess := dev.sessions.get(req.CallID()); sess != nil {
+            dev.onAck(sess)
+        }
+    case SIP_BYE:
+        if sess := dev.sessions.onBye(dev.ua, req, from); sess != nil && dev.OnBye != nil {
+            dev.OnBye(sess)
+        }
+    default:
+        dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_NOT_IMPLEMENTED), from)
+    }
+}
+
+func (dev *Device) onMessage(req *SipMessage, from *net.UDPAddr) {
+    query, err := DecodeManscdp(req.Body)
+    if err != nil {
+        dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_BAD_REQUEST), from)
+        return
+    }
+    dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_OK), from)
+    if query.XMLName.Local != MANSCDP_QUERY {
+        return
+    }
+    switch query.CmdType {
+    case CMD_CATALOG:
+        channels := dev.cfg.Channels
+        for start := 0; start == 0 || start < len(channels); start += CATALOG_ITEMS_PER_MESSAGE {
+            end := start + CATALOG_ITEMS_PER_MESSAGE
+            if end > len(channels) {
+                end = len(channels)
+            }
+            res := NewManscdp(MANSCDP_RESPONSE, CMD_CATALOG, query.SN, dev.cfg.ID)
+            res.SumNum = len(channels)
+            res.DeviceList = &DeviceList{Num: end - start, Items: channels[start:end]}
+            if dev.sendMessage(res) != nil {
+                return
+            }
+        }
+    case CMD_DEVICE_INFO:
+        res := NewManscdp(MANSCDP_RESPONSE, CMD_DEVICE_INFO, query.SN, dev.cfg.ID)
+        res.Result = "OK"
+        res.DeviceName = dev.cfg.Name
+        res.Manufacturer = dev.cfg.Manufacturer
+        res.Model = dev.cfg.Model
+        res.Firmware = dev.cfg.Firmware
+        res.Channel = len(dev.cfg.Channels)
+        dev.sendMessage(res)
+    }
+}
+
+func (dev *Device) hasChannel(channelID string) bool {
+    if channelID == dev.cfg.ID {
+        return true
+    }
+    for _, channel := range dev.cfg.Channels {
+        if channel.DeviceID == channelID {
+            return true
+        }
+    }
+    return false
+}
+
+// the media transport of device is opposite to the offer of platform
+func (dev *Device) onInvite(req *SipMessage, from *net.UDPAddr) {
+    channelID := uriUser(req.Uri)
+    if !dev.hasChannel(channelID) {
+        dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_NOT_FOUND), from)
+        return
+    }
+    offer := &GBSdp{}
+    if err := offer.Decode(string(req.Body)); err != nil {
+        dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_BAD_REQUEST), from)
+        return
+    }
+    answer := &GBSdp{
+        Username:    channelID,
+        SessionName: offer.SessionName,
+        IP:          dev.ua.ip,
+        Port:        9,
+        Direction:   SDP_SENDONLY,
+        SSRC:        offer.SSRC,
+    }
+    var err error
+    switch offer.Transport {
+    case MEDIA_TRANSPORT_UDP:
+        answer.Transport = MEDIA_TRANSPORT_UDP
+    case MEDIA_TRANSPORT_TCP_PASSIVE:
+        answer.Transport = MEDIA_TRANSPORT_TCP_ACTIVE
+    case MEDIA_TRANSPORT_TCP_ACTIVE:
+        answer.Transport = MEDIA_TRANSPORT_TCP_PASSIVE
+    }
+    sender := NewMediaSender(answer.Transport, offer.SSRCValue())
+    switch answer.Transport {
+    case MEDIA_TRANSPORT_UDP:
+        err = sender.Dial(net.JoinHostPort(offer.IP, strconv.Itoa(offer.Port)))
+        answer.Port = sender.LocalPort()
+    case MEDIA_TRANSPORT_TCP_PASSIVE:
+        err = sender.Listen(":0")
+        answer.Port = sender.LocalPort()
+    }
+    if err != nil {
+        sender.Close()
+        dev.ua.respond(req, NewSipResponse(req, SIP_STATUS_INTERNAL_ERROR), from)
+        return
+    }
+
+    res := NewSipResponse(req, SIP_STATUS_OK)
+    res.AddHeader(SipContact, "<sip:"+dev.cfg.ID+"@"+dev.ua.hostport()+">")
+    res.AddHeader(SipContentType, "Application/SDP")
+    res.Body = []byte(answer.Encode())
+    sess := &Session{
+        CallID:    req.CallID(),
+        DeviceID:  dev.cfg.ID,
+        ChannelID: channelID,
+        SSRC:      offer.SSRCValue(),
+        LocalSdp:  answer,
+        RemoteSdp: offer,
+        Sender:    sender,
+        ua:        dev.ua,
+        remote:    from,
+        from:      res.Header(SipTo),
+        to:        req.Header(SipFrom),
+        target:    dev.uri(dev.cfg.ServerID),
+    }
+    if contact := addressUri(req.Header(SipContact)); contact != "" {
+        sess.target = contact
+    }
+    dev.sessions.add(sess)
+    dev.ua.respond(req, res, from)
+}
+
+func (dev *Device) onAck(sess *Session) {
+    var err error
+    switch sess.LocalSdp.Transport {
+    case MEDIA_TRANSPORT_TCP_ACTIVE:
+        err = sess.Sender.Dial(net.JoinHostPort(sess.RemoteSdp.IP, strconv.Itoa(sess.RemoteSdp.Port)))
+    case MEDIA_TRANSPORT_TCP_PASSIVE:
+        err = sess.Sender.Accept()
+    }
+    if err != nil {
+        sess.Bye()
+        return
+    }
+    if dev.OnPlay != nil {
+        dev.OnPlay(sess)
+    }
+}
@@ -0,0 +1,102 @@
+package gb28181
+
+import (
+    "bytes"
+    "encoding/xml"
+    "io"
+)
+
+// MANSCDP(GB/T 28181 Annex A) xml carried by SIP MESSAGE
+//   <Notify>   Keepalive
+//   <Query>    Catalog,DeviceInfo
+//   <Response> Catalog,DeviceInfo
+
+const MANSCDP_CONTENT_TYPE = "Application/MANSCDP+xml"
+
+const (
+    MANSCDP_NOTIFY   = "Notify"
+    MANSCDP_QUERY    = "Query"
+    MANSCDP_RESPONSE = "Response"
+)
+
+const (
+    CMD_KEEPALIVE   = "Keepalive"
+    CMD_CATALOG     = "Catalog"
+    CMD_DEVICE_INFO = "DeviceInfo"
+)
+
+type CatalogItem struct {
+    DeviceID     string `xml:"DeviceID"`
+    Name         string `xml:"Name"`
+    Manufacturer string `xml:"Manufacturer,omitempty"`
+    Model        string `xml:"Model,omitempty"`
+    Owner        string `xml:"Owner,omitempty"`
+    CivilCode    string `xml:"CivilCode,omitempty"`
+    Address      string `xml:"Address,omitempty"`
+    Parental     int    `xml:"Parental"`
+    ParentID     string `xml:"ParentID,omitempty"`
+    RegisterWay  int    `xml:"RegisterWay,omitempty"`
+    Secrecy      int    `xml:"Secrecy"`
+    Status       string `xml:"Status,omitempty"`
+}
+
+type DeviceList struct {
+    Num   int           `xml:"Num,attr"`
+    Items []CatalogItem `xml:"Item"`
+}
+
+// Manscdp covers the fields of all the supported commands,
+// XMLName.Local is one of Notify/Query/Response
+type Manscdp struct {
+    XMLName  xml.Name
+    CmdType  string `xml:"CmdType"`
+    SN       int    `xml:"SN"`
+    DeviceID string `xml:"DeviceID"`
+    //Keepalive
+    Status string `xml:"Status,omitempty"`
+    //Catalog response
+    SumNum     int         `xml:"SumNum,omitempty"`
+    DeviceList *DeviceList `xml:"DeviceList,omitempty"`
+    //DeviceInfo response
+    Result       string `xml:"Result,omitempty"`
+    DeviceName   string `xml:"DeviceName,omitempty"`
+    Manufacturer string `xml:"Manufacturer,omitempty"`
+    Model        string `xml:"Model,omitempty"`
+    Firmware     string `xml:"Firmware,omitempty"`
+    Channel      int    `xml:"Channel,omitempty"`
+}
+
+func NewManscdp(root string, cmdType string, sn int, deviceID string) *Manscdp {
+    return &Manscdp{
+        XMLName:  xml.Name{Local: root},
+        CmdType:  cmdType,
+        SN:       sn,
+        DeviceID: deviceID,
+    }
+}
+
+func (m *Manscdp) Encode() ([]byte, error) {
+    body, err := xml.MarshalIndent(m, "", "  ")
+    if err != nil {
+        return nil, err
+    }
+    return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// the standard requires GB2312,there is no conversion here,
+// the non UTF-8 text(e.g. chinese name) is replaced by U+FFFD
+func DecodeManscdp(data []byte) (*Manscdp, error) {
+    m := &Manscdp{}
+    decoder := xml.NewDecoder(bytes.NewReader(data))
+    decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+        text, err := io.ReadAll(input)
+        if err != nil {
+            return nil, err
+        }
+        return bytes.NewReader(bytes.ToValidUTF8(text, []byte("\uFFFD"))), nil
+    }
+    if err := decoder.Decode(m); err != nil {
+        return nil, err
+    }
+    return m, nil
+}
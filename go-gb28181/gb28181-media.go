@@ -0,0 +1,366 @@
+package gb28181
+
+import (
+    "errors"
+    "net"
+    "sync"
+    "time"
+
+    "github.com/yapingcat/gomedia/go-mpeg2"
+    "github.com/yapingcat/gomedia/go-rtsp/rtp"
+)
+
+// GB/T 28181 media transport,PS over RTP
+//   UDP:          RTP/AVP
+//   TCP passive:  TCP/RTP/AVP a=setup:passive,the side waits for connection
+//   TCP active:   TCP/RTP/AVP a=setup:active,the side connects to the peer
+// over TCP every rtp packet is preceded by 2 bytes length(RFC4571)
+type MEDIA_TRANSPORT int
+
+const (
+    MEDIA_TRANSPORT_UDP MEDIA_TRANSPORT = iota
+    MEDIA_TRANSPORT_TCP_PASSIVE
+    MEDIA_TRANSPORT_TCP_ACTIVE
+)
+
+func (t MEDIA_TRANSPORT) String() string {
+    switch t {
+    case MEDIA_TRANSPORT_UDP:
+        return "UDP"
+    case MEDIA_TRANSPORT_TCP_PASSIVE:
+        return "TCP-PASSIVE"
+    case MEDIA_TRANSPORT_TCP_ACTIVE:
+        return "TCP-ACTIVE"
+    default:
+        return "UNKNOWN"
+    }
+}
+
+const (
+    PS_PAYLOAD_TYPE     = 96
+    PS_CLOCK_RATE       = 90000
+    DEFAULT_REORDER_LEN = 64 //packets held by the jitter buffer
+    DEFAULT_MTU         = 1400
+)
+
+// the interval to release the packets held by the jitter buffer when no udp packet arrives
+const JITTER_POLL_INTERVAL = 20 * time.Millisecond
+
+var errNotConnected = errors.New("media transport is not connected")
+
+// mediaConn hides the difference between udp and tcp(active/passive)
+type mediaConn struct {
+    transport MEDIA_TRANSPORT
+    udp       *net.UDPConn
+    listener  net.Listener
+    tcp       net.Conn
+    peer      *net.UDPAddr
+    mtx       sync.Mutex
+    closed    bool
+}
+
+// UDP or TCP passive
+func (mc *mediaConn) listen(addr string) error {
+    if mc.transport == MEDIA_TRANSPORT_UDP {
+        laddr, err := net.ResolveUDPAddr("udp", addr)
+        if err != nil {
+            return err
+        }
+        mc.udp, err = net.ListenUDP("udp", laddr)
+        return err
+    } else if mc.transport == MEDIA_TRANSPORT_TCP_PASSIVE {
+        var err error
+        mc.listener, err = net.Listen("tcp", addr)
+        return err
+    }
+    return errors.New("listen is not supported by " + mc.transport.String())
+}
+
+// UDP(connected socket) or TCP active
+func (mc *mediaConn) dial(addr string) error {
+    if mc.transport == MEDIA_TRANSPORT_UDP {
+        raddr, err := net.ResolveUDPAddr("udp", addr)
+        if err != nil {
+            return err
+        }
+        if mc.udp == nil {
+            mc.udp, err = net.ListenUDP("udp", nil)
+            if err != nil {
+                return err
+            }
+        }
+        mc.peer = raddr
+        return nil
+    } else if mc.transport == MEDIA_TRANSPORT_TCP_ACTIVE {
+        conn, err := net.Dial("tcp", addr)
+        if err != nil {
+            return err
+        }
+        mc.setTcp(conn)
+        return nil
+    }
+    return errors.New("dial is not supported by " + mc.transport.String())
+}
+
+func (mc *mediaConn) setTcp(conn net.Conn) {
+    mc.mtx.Lock()
+    defer mc.mtx.Unlock()
+    if mc.closed {
+        conn.Close()
+        return
+    }
+    mc.tcp = conn
+}
+
+// tcp is set by accept/dial while the other goroutine is reading or writing
+func (mc *mediaConn) getTcp() net.Conn {
+    mc.mtx.Lock()
+    defer mc.mtx.Unlock()
+    return mc.tcp
+}
+
+// wait for the peer of TCP passive
+func (mc *mediaConn) accept() error {
+    if mc.transport != MEDIA_TRANSPORT_TCP_PASSIVE || mc.getTcp() != nil {
+        return nil
+    }
+    if mc.listener == nil {
+        return errNotConnected
+    }
+    conn, err := mc.listener.Accept()
+    if err != nil {
+        return err
+    }
+    mc.setTcp(conn)
+    return nil
+}
+
+func (mc *mediaConn) localAddr() net.Addr {
+    if mc.udp != nil {
+        return mc.udp.LocalAddr()
+    } else if mc.listener != nil {
+        return mc.listener.Addr()
+    } else if tcp := mc.getTcp(); tcp != nil {
+        return tcp.LocalAddr()
+    }
+    return nil
+}
+
+func (mc *mediaConn) write(pkt []byte) error {
+    if mc.udp != nil {
+        if mc.peer == nil {
+            return errNotConnected
+        }
+        _, err := mc.udp.WriteToUDP(pkt, mc.peer)
+        return err
+    }
+    tcp := mc.getTcp()
+    if tcp == nil {
+        return errNotConnected
+    }
+    frame, err := EncodeRFC4571(pkt)
+    if err != nil {
+        return err
+    }
+    _, err = tcp.Write(frame)
+    return err
+}
+
+// read rtp packets until the connection is closed,
+// onIdle is called if no udp packet arrives in JITTER_POLL_INTERVAL
+func (mc *mediaConn) readLoop(onPacket func(pkt []byte, from net.Addr), onIdle func(now time.Time)) error {
+    if mc.udp != nil {
+        buf := make([]byte, 65536)
+        for {
+            mc.udp.SetReadDeadline(time.Now().Add(JITTER_POLL_INTERVAL))
+            n, from, err := mc.udp.ReadFromUDP(buf)
+            if err != nil {
+                if ne, ok := err.(net.Error); ok && ne.Timeout() {
+                    onIdle(time.Now())
+                    continue
+                }
+                return err
+            }
+            onPacket(buf[:n], from)
+        }
+    }
+    if err := mc.accept(); err != nil {
+        return err
+    }
+    tcp := mc.getTcp()
+    if tcp == nil {
+        return errNotConnected
+    }
+    reader := newRFC4571Reader(tcp)
+    for {
+        pkt, err := reader.next()
+        if err != nil {
+            return err
+        }
+        onPacket(pkt, tcp.RemoteAddr())
+    }
+}
+
+func (mc *mediaConn) close() {
+    mc.mtx.Lock()
+    defer mc.mtx.Unlock()
+    mc.closed = true
+    if mc.udp != nil {
+        mc.udp.Close()
+    }
+    if mc.listener != nil {
+        mc.listener.Close()
+    }
+    if mc.tcp != nil {
+        mc.tcp.Close()
+    }
+}
+
+// MediaReceiver receives PS over RTP from GB28181 device,
+// udp packets are reordered by rtp.JitterBuffer before being depacketized,
+// frames are demuxed by mpeg2.PSDemuxer
+type MediaReceiver struct {
+    conn     mediaConn
+    unpacker *rtp.PsEsUnPacker
+    jitter   *rtp.JitterBuffer
+    ssrc     uint32
+    OnFrame  func(frame []byte, cid mpeg2.PS_STREAM_TYPE, pts uint64, dts uint64)
+    //optional,every rtp packet in sequence number order
+    OnRtp func(pkg *rtp.RtpPacket)
+}
+
+func NewMediaReceiver(transport MEDIA_TRANSPORT) *MediaReceiver {
+    recv := &MediaReceiver{
+        conn:     mediaConn{transport: transport},
+        unpacker: rtp.NewPsEsUnPacker(),
+    }
+    recv.jitter = rtp.NewJitterBuffer(recv.unpacker)
+    recv.jitter.SetMaxPackets(DEFAULT_REORDER_LEN)
+    recv.unpacker.OnEsFrame(func(frame []byte, cid mpeg2.PS_STREAM_TYPE, pts uint64, dts uint64) {
+        if recv.OnFrame != nil {
+            recv.OnFrame(frame, cid, pts, dts)
+        }
+    })
+    recv.unpacker.HookRtp(func(pkg *rtp.RtpPacket) {
+        if recv.OnRtp != nil {
+            recv.OnRtp(pkg)
+        }
+    })
+    return recv
+}
+
+// only accept the rtp packets with ssrc(y= in sdp),zero means any ssrc
+func (recv *MediaReceiver) SetSSRC(ssrc uint32) {
+    recv.ssrc = ssrc
+}
+
+// UDP or TCP passive,addr may be ":0" to let the system choose a port
+func (recv *MediaReceiver) Listen(addr string) error {
+    return recv.conn.listen(addr)
+}
+
+// TCP active,connect to the device
+func (recv *MediaReceiver) Dial(addr string) error {
+    return recv.conn.dial(addr)
+}
+
+func (recv *MediaReceiver) LocalPort() int {
+    switch addr := recv.conn.localAddr().(type) {
+    case *net.UDPAddr:
+        return addr.Port
+    case *net.TCPAddr:
+        return addr.Port
+    default:
+        return 0
+    }
+}
+
+// Serve reads rtp packets until Close is called or the connection is broken,
+// for TCP passive it waits for the connection of device at first,
+// for UDP the jitter buffer is polled while no packet arrives
+func (recv *MediaReceiver) Serve() error {
+    err := recv.conn.readLoop(func(pkt []byte, from net.Addr) {
+        recv.Input(pkt)
+    }, func(now time.Time) {
+        //the tail of the stream is released after the jitter delay
+        recv.jitter.Poll(now)
+    })
+    recv.jitter.Flush()
+    recv.unpacker.Flush()
+    return err
+}
+
+// Input rtp packet from other transport
+func (recv *MediaReceiver) Input(pkt []byte) error {
+    var hdr rtp.RtpHdr
+    if _, err := hdr.Decode(pkt); err != nil {
+        return err
+    }
+    if recv.ssrc != 0 && hdr.SSRC != recv.ssrc {
+        return nil
+    }
+    if recv.conn.transport == MEDIA_TRANSPORT_UDP {
+        return recv.jitter.UnPack(pkt)
+    }
+    return recv.unpacker.UnPack(pkt)
+}
+
+func (recv *MediaReceiver) Close() {
+    recv.conn.close()
+}
+
+// MediaSender sends PS over RTP to the peer for talkback/broadcast,
+// frames are muxed by mpeg2.PSMuxer
+type MediaSender struct {
+    conn   mediaConn
+    packer *rtp.PsEsPacker
+}
+
+func NewMediaSender(transport MEDIA_TRANSPORT, ssrc uint32) *MediaSender {
+    sender := &MediaSender{
+        conn:   mediaConn{transport: transport},
+        packer: rtp.NewPsEsPacker(PS_PAYLOAD_TYPE, ssrc, 0, DEFAULT_MTU),
+    }
+    sender.packer.OnPacket(func(pkt []byte) error {
+        return sender.conn.write(pkt)
+    })
+    return sender
+}
+
+// TCP passive,the peer connects to addr,call Accept to wait for it
+func (sender *MediaSender) Listen(addr string) error {
+    return sender.conn.listen(addr)
+}
+
+func (sender *MediaSender) Accept() error {
+    return sender.conn.accept()
+}
+
+// UDP or TCP active,send to the peer
+func (sender *MediaSender) Dial(addr string) error {
+    return sender.conn.dial(addr)
+}
+
+func (sender *MediaSender) LocalPort() int {
+    switch addr := sender.conn.localAddr().(type) {
+    case *net.UDPAddr:
+        return addr.Port
+    case *net.TCPAddr:
+        return addr.Port
+    default:
+        return 0
+    }
+}
+
+func (sender *MediaSender) AddStream(cid mpeg2.PS_STREAM_TYPE) uint8 {
+    return sender.packer.AddStream(cid)
+}
+
+// pts/dts: millisecond
+func (sender *MediaSender) WriteFrame(sid uint8, frame []byte, pts uint64, dts uint64) error {
+    return sender.packer.WriteFrame(sid, frame, pts, dts)
+}
+
+func (sender *MediaSender) Close() {
+    sender.conn.close()
+}
@@ -0,0 +1,188 @@
+package gb28181
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yapingcat/gomedia/go-mpeg2"
+	"github.com/yapingcat/gomedia/go-rtsp/rtp"
+)
+
+var testIdr = append([]byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 6000)...)
+var testP = append([]byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x9A}, bytes.Repeat([]byte{0x22}, 1500)...)
+
+func writeTestFrames(sender *MediaSender, frames int) error {
+	vid := sender.AddStream(mpeg2.PS_STREAM_H264)
+	for i := 0; i < frames; i++ {
+		frame := testP
+		if i%10 == 0 {
+			frame = testIdr
+		}
+		if err := sender.WriteFrame(vid, frame, uint64(i*40), uint64(i*40)); err != nil {
+			return err
+		}
+		//give udp receiver a chance to read
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
+
+type frameCounter struct {
+	mtx    sync.Mutex
+	slices int
+	lastTs uint64
+}
+
+func (fc *frameCounter) onFrame(frame []byte, cid mpeg2.PS_STREAM_TYPE, pts uint64, dts uint64) {
+	fc.mtx.Lock()
+	defer fc.mtx.Unlock()
+	//PSDemuxer outputs one nalu per callback,count the slices
+	if cid == mpeg2.PS_STREAM_H264 && (bytes.HasSuffix(frame, testIdr[len(testIdr)-100:]) || bytes.HasSuffix(frame, testP[4:])) {
+		fc.slices++
+		fc.lastTs = pts
+	}
+}
+
+func TestMediaReceiver_Loopback(t *testing.T) {
+	tests := []struct {
+		recvTransport MEDIA_TRANSPORT
+		sendTransport MEDIA_TRANSPORT
+	}{
+		{recvTransport: MEDIA_TRANSPORT_UDP, sendTransport: MEDIA_TRANSPORT_UDP},
+		{recvTransport: MEDIA_TRANSPORT_TCP_PASSIVE, sendTransport: MEDIA_TRANSPORT_TCP_ACTIVE},
+		{recvTransport: MEDIA_TRANSPORT_TCP_ACTIVE, sendTransport: MEDIA_TRANSPORT_TCP_PASSIVE},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s-%s", tt.recvTransport, tt.sendTransport), func(t *testing.T) {
+			const ssrc = 0x12345678
+			receiver := NewMediaReceiver(tt.recvTransport)
+			receiver.SetSSRC(ssrc)
+			sender := NewMediaSender(tt.sendTransport, ssrc)
+			fc := &frameCounter{}
+			receiver.OnFrame = fc.onFrame
+
+			var err error
+			if tt.recvTransport == MEDIA_TRANSPORT_TCP_ACTIVE {
+				if err = sender.Listen("127.0.0.1:0"); err == nil {
+					err = receiver.Dial(fmt.Sprintf("127.0.0.1:%d", sender.LocalPort()))
+				}
+				if err == nil {
+					err = sender.Accept()
+				}
+			} else {
+				if err = receiver.Listen("127.0.0.1:0"); err == nil {
+					err = sender.Dial(fmt.Sprintf("127.0.0.1:%d", receiver.LocalPort()))
+				}
+			}
+			if err != nil {
+				t.Fatalf("setup media transport error = %v", err)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				receiver.Serve()
+				close(done)
+			}()
+			if err := writeTestFrames(sender, 30); err != nil {
+				t.Fatalf("MediaSender.WriteFrame() error = %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			sender.Close()
+			receiver.Close()
+			<-done
+
+			fc.mtx.Lock()
+			defer fc.mtx.Unlock()
+			if fc.slices != 30 || fc.lastTs != 29*40 {
+				t.Errorf("MediaReceiver got %d frames,last pts %d, want 30 frames,last pts %d", fc.slices, fc.lastTs, 29*40)
+			}
+		})
+	}
+}
+
+func TestMediaReceiver_Reorder(t *testing.T) {
+	packer := rtp.NewPsEsPacker(PS_PAYLOAD_TYPE, 1, 65500, DEFAULT_MTU)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	vid := packer.AddStream(mpeg2.PS_STREAM_H264)
+	for i := 0; i < 20; i++ {
+		frame := testP
+		if i%10 == 0 {
+			frame = testIdr
+		}
+		packer.WriteFrame(vid, frame, uint64(i*40), uint64(i*40))
+	}
+	//swap neighbours and duplicate packets,sequence number wraps around
+	for i := 1; i+1 < len(pkts); i += 3 {
+		pkts[i], pkts[i+1] = pkts[i+1], pkts[i]
+	}
+	receiver := NewMediaReceiver(MEDIA_TRANSPORT_UDP)
+	fc := &frameCounter{}
+	receiver.OnFrame = fc.onFrame
+	for i, pkt := range pkts {
+		receiver.Input(pkt)
+		if i%5 == 0 {
+			receiver.Input(pkt)
+		}
+	}
+	receiver.jitter.Flush()
+	receiver.unpacker.Flush()
+	if fc.slices != 20 {
+		t.Errorf("MediaReceiver got %d frames, want 20", fc.slices)
+	}
+}
+
+func TestMediaReceiver_PollJitter(t *testing.T) {
+	receiver := NewMediaReceiver(MEDIA_TRANSPORT_UDP)
+	fc := &frameCounter{}
+	receiver.OnFrame = fc.onFrame
+	if err := receiver.Listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		receiver.Serve()
+		close(done)
+	}()
+	defer func() {
+		receiver.Close()
+		<-done
+	}()
+	conn, err := net.Dial("udp", fmt.Sprintf("127.0.0.1:%d", receiver.LocalPort()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	packer := rtp.NewPsEsPacker(PS_PAYLOAD_TYPE, 1, 0, DEFAULT_MTU)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	vid := packer.AddStream(mpeg2.PS_STREAM_H264)
+	for i := 0; i < 20; i++ {
+		packer.WriteFrame(vid, testP, uint64(i*40), uint64(i*40))
+	}
+	//the lost packet holds the following packets in the jitter buffer,
+	//they are released by polling although no more packet arrives
+	for i, pkt := range pkts {
+		if i == 5 {
+			continue
+		}
+		conn.Write(pkt)
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(rtp.DEFAULT_JITTER_DELAY + 5*JITTER_POLL_INTERVAL)
+	fc.mtx.Lock()
+	defer fc.mtx.Unlock()
+	if fc.slices < 17 {
+		t.Errorf("MediaReceiver got %d frames before close, want at least 17", fc.slices)
+	}
+}
@@ -0,0 +1,50 @@
+package gb28181
+
+import (
+    "bufio"
+    "encoding/binary"
+    "errors"
+    "io"
+)
+
+// RFC4571 Framing RTP and RTCP Packets over Connection-Oriented Transport
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// ---------------------------------------------------------------
+// |             LENGTH            |  RTP or RTCP packet ...       |
+// ---------------------------------------------------------------
+
+func EncodeRFC4571(pkt []byte) ([]byte, error) {
+    if len(pkt) > 0xFFFF {
+        return nil, errors.New("rtp packet is too large for rfc4571 framing")
+    }
+    frame := make([]byte, 2+len(pkt))
+    binary.BigEndian.PutUint16(frame, uint16(len(pkt)))
+    copy(frame[2:], pkt)
+    return frame, nil
+}
+
+type rfc4571Reader struct {
+    r   *bufio.Reader
+    buf []byte
+}
+
+func newRFC4571Reader(r io.Reader) *rfc4571Reader {
+    return &rfc4571Reader{
+        r:   bufio.NewReaderSize(r, 65536),
+        buf: make([]byte, 65535),
+    }
+}
+
+// the returned packet is valid until next call
+func (reader *rfc4571Reader) next() ([]byte, error) {
+    var hdr [2]byte
+    if _, err := io.ReadFull(reader.r, hdr[:]); err != nil {
+        return nil, err
+    }
+    length := int(binary.BigEndian.Uint16(hdr[:]))
+    if _, err := io.ReadFull(reader.r, reader.buf[:length]); err != nil {
+        return nil, err
+    }
+    return reader.buf[:length], nil
+}
@@ -0,0 +1,170 @@
+package gb28181
+
+import (
+    "errors"
+    "fmt"
+    "strconv"
+    "strings"
+)
+
+// SDP of GB/T 28181 INVITE
+//   v=0
+//   o=34020000001320000001 0 0 IN IP4 192.168.1.10
+//   s=Play
+//   c=IN IP4 192.168.1.10
+//   t=0 0
+//   m=video 6000 RTP/AVP 96          TCP: m=video 6000 TCP/RTP/AVP 96
+//   a=recvonly
+//   a=rtpmap:96 PS/90000
+//   a=setup:passive                  TCP only
+//   a=connection:new                 TCP only
+//   y=0100000001                     SSRC,decimal
+//   f=v/2/4///a///                   media format,optional
+//
+// y= first digit 0:realtime 1:history,digits 2-6 are from the sip domain,the rest is sequence number
+
+const (
+    SDP_SESSION_PLAY     = "Play"
+    SDP_SESSION_PLAYBACK = "Playback"
+    SDP_SESSION_DOWNLOAD = "Download"
+    SDP_SESSION_TALK     = "Talk"
+)
+
+const (
+    SDP_RECVONLY = "recvonly"
+    SDP_SENDONLY = "sendonly"
+    SDP_SENDRECV = "sendrecv"
+)
+
+type GBSdp struct {
+    Username    string //o= ,id of the channel
+    SessionName string //s= ,Play/Playback/Download/Talk
+    IP          string
+    Port        int
+    Media       string //video or audio
+    Transport   MEDIA_TRANSPORT
+    Direction   string
+    StartTime   uint64 //t= ,Playback/Download
+    EndTime     uint64
+    Uri         string //u= ,Playback/Download,e.g. 34020000001320000001:0
+    SSRC        string //y=
+    Format      string //f=
+}
+
+func (s *GBSdp) SSRCValue() uint32 {
+    ssrc, _ := strconv.ParseUint(s.SSRC, 10, 32)
+    return uint32(ssrc)
+}
+
+func (s *GBSdp) Encode() string {
+    media := s.Media
+    if media == "" {
+        media = "video"
+    }
+    proto := "RTP/AVP"
+    if s.Transport != MEDIA_TRANSPORT_UDP {
+        proto = "TCP/RTP/AVP"
+    }
+    var sb strings.Builder
+    sb.WriteString("v=0\r\n")
+    sb.WriteString(fmt.Sprintf("o=%s 0 0 IN IP4 %s\r\n", s.Username, s.IP))
+    sb.WriteString("s=" + s.SessionName + "\r\n")
+    if s.Uri != "" {
+        sb.WriteString("u=" + s.Uri + "\r\n")
+    }
+    sb.WriteString("c=IN IP4 " + s.IP + "\r\n")
+    sb.WriteString(fmt.Sprintf("t=%d %d\r\n", s.StartTime, s.EndTime))
+    sb.WriteString(fmt.Sprintf("m=%s %d %s %d\r\n", media, s.Port, proto, PS_PAYLOAD_TYPE))
+    if s.Direction != "" {
+        sb.WriteString("a=" + s.Direction + "\r\n")
+    }
+    sb.WriteString(fmt.Sprintf("a=rtpmap:%d PS/%d\r\n", PS_PAYLOAD_TYPE, PS_CLOCK_RATE))
+    if s.Transport == MEDIA_TRANSPORT_TCP_PASSIVE {
+        sb.WriteString("a=setup:passive\r\na=connection:new\r\n")
+    } else if s.Transport == MEDIA_TRANSPORT_TCP_ACTIVE {
+        sb.WriteString("a=setup:active\r\na=connection:new\r\n")
+    }
+    if s.SSRC != "" {
+        sb.WriteString("y=" + s.SSRC + "\r\n")
+    }
+    if s.Format != "" {
+        sb.WriteString("f=" + s.Format + "\r\n")
+    }
+    return sb.String()
+}
+
+// only the first m= is used
+func (s *GBSdp) Decode(content string) error {
+    hasMedia := false
+    tcp := false
+    setup := ""
+    for _, line := range strings.Split(content, "\n") {
+        line = strings.TrimRight(line, "\r")
+        if len(line) < 2 || line[1] != '=' {
+            continue
+        }
+        value := line[2:]
+        switch line[0] {
+        case 'o':
+            if items := strings.Fields(value); len(items) >= 6 {
+                s.Username = items[0]
+                if s.IP == "" {
+                    s.IP = items[5]
+                }
+            }
+        case 's':
+            s.SessionName = value
+        case 'u':
+            s.Uri = value
+        case 'c':
+            //c= of media level overrides the session level
+            if items := strings.Fields(value); len(items) >= 3 {
+                s.IP = items[2]
+            }
+        case 't':
+            if items := strings.Fields(value); len(items) == 2 {
+                s.StartTime, _ = strconv.ParseUint(items[0], 10, 64)
+                s.EndTime, _ = strconv.ParseUint(items[1], 10, 64)
+            }
+        case 'm':
+            if hasMedia {
+                continue
+            }
+            items := strings.Fields(value)
+            if len(items) < 3 {
+                return errors.New("invalid sdp media line " + line)
+            }
+            hasMedia = true
+            s.Media = items[0]
+            port, err := strconv.Atoi(strings.SplitN(items[1], "/", 2)[0])
+            if err != nil {
+                return errors.New("invalid sdp media port " + items[1])
+            }
+            s.Port = port
+            tcp = strings.HasPrefix(strings.ToUpper(items[2]), "TCP")
+        case 'a':
+            switch {
+            case value == SDP_RECVONLY || value == SDP_SENDONLY || value == SDP_SENDRECV:
+                s.Direction = value
+            case strings.HasPrefix(value, "setup:"):
+                setup = strings.TrimPrefix(value, "setup:")
+            }
+        case 'y':
+            s.SSRC = value
+        case 'f':
+            s.Format = value
+        }
+    }
+    if !hasMedia {
+        return errors.New("no media in sdp")
+    }
+    s.Transport = MEDIA_TRANSPORT_UDP
+    if tcp {
+        //the default of RFC4145 is active
+        s.Transport = MEDIA_TRANSPORT_TCP_ACTIVE
+        if setup == "passive" {
+            s.Transport = MEDIA_TRANSPORT_TCP_PASSIVE
+        }
+    }
+    return nil
+}
@@ -0,0 +1,442 @@
+package gb28181
+
+import (
+    "errors"
+    "fmt"
+    "net"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+)
+
+const (
+    DEFAULT_EXPIRES           = 3600 //second
+    DEFAULT_QUERY_TIMEOUT     = 10 * time.Second
+    DEFAULT_KEEPALIVE_TIMEOUT = 3 * 60 * time.Second //3 keepalive intervals of 60s
+    SIP_DATE_FORMAT           = "2006-01-02T15:04:05.000"
+)
+
+// how often the registrations are checked after Start
+const expiryCheckInterval = time.Second
+
+var errDeviceNotRegistered = errors.New("device is not registered")
+
+type ServerConfig struct {
+    ID         string //sip id of platform,20 digits
+    Domain     string //sip domain and digest realm,the first 10 digits of ID by default
+    Password   string //empty means no authentication
+    ListenAddr string //udp,e.g. 0.0.0.0:5060
+    IP         string //address in Via/Contact/SDP,required if ListenAddr is unspecified address
+    //the device is removed if no keepalive is received in time,
+    //DEFAULT_KEEPALIVE_TIMEOUT if zero,negative disables the check
+    KeepaliveTimeout time.Duration
+}
+
+type RemoteDevice struct {
+    ID            string
+    Addr          *net.UDPAddr
+    Expires       int
+    RegisterTime  time.Time
+    LastKeepalive time.Time
+}
+
+type pendingQuery struct {
+    cmdType string
+    items   []CatalogItem
+    result  *Manscdp
+    done    chan struct{}
+    closed  bool
+}
+
+// Server is the platform side of GB/T 28181,
+// devices REGISTER to it,it queries the devices and INVITEs the channels
+type Server struct {
+    cfg          ServerConfig
+    ua           *sipUA
+    mtx          sync.Mutex
+    devices      map[string]*RemoteDevice
+    auths        map[string]*rtsp.DigestAuth
+    queries      map[string]*pendingQuery
+    sessions     sessionTable
+    sn           int32
+    ssrcSeq      uint32
+    OnRegister   func(dev RemoteDevice)
+    OnUnregister func(deviceID string) //called on unregister,expiry or keepalive timeout
+    OnKeepalive  func(dev RemoteDevice)
+    OnBye        func(sess *Session)
+}
+
+func NewServer(cfg ServerConfig) *Server {
+    if cfg.Domain == "" && len(cfg.ID) >= 10 {
+        cfg.Domain = cfg.ID[:10]
+    }
+    srv := &Server{
+        cfg:     cfg,
+        devices: make(map[string]*RemoteDevice),
+        auths:   make(map[string]*rtsp.DigestAuth),
+        queries: make(map[string]*pendingQuery),
+    }
+    srv.ua = newSipUA(srv.handleRequest)
+    return srv
+}
+
+func (srv *Server) Start() error {
+    if err := srv.ua.listen(srv.cfg.ListenAddr, srv.cfg.IP); err != nil {
+        return err
+    }
+    go srv.ua.serve()
+    go srv.checkExpiryLoop()
+    return nil
+}
+
+func (srv *Server) checkExpiryLoop() {
+    ticker := time.NewTicker(expiryCheckInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case now := <-ticker.C:
+            srv.CheckExpiry(now)
+        case <-srv.ua.closed:
+            return
+        }
+    }
+}
+
+// remove the devices whose registration expires or keepalive times out,
+// OnUnregister is called for each of them,
+// it is called periodically after Start
+func (srv *Server) CheckExpiry(now time.Time) {
+    var expired []string
+    srv.mtx.Lock()
+    for id, dev := range srv.devices {
+        if srv.expired(dev, now) {
+            delete(srv.devices, id)
+            expired = append(expired, id)
+        }
+    }
+    srv.mtx.Unlock()
+    if srv.OnUnregister == nil {
+        return
+    }
+    for _, id := range expired {
+        srv.OnUnregister(id)
+    }
+}
+
+func (srv *Server) expired(dev *RemoteDevice, now time.Time) bool {
+    if now.After(dev.RegisterTime.Add(time.Duration(dev.Expires) * time.Second)) {
+        return true
+    }
+    timeout := srv.cfg.KeepaliveTimeout
+    if timeout == 0 {
+        timeout = DEFAULT_KEEPALIVE_TIMEOUT
+    }
+    return timeout > 0 && now.After(dev.LastKeepalive.Add(timeout))
+}
+
+func (srv *Server) LocalPort() int {
+    return srv.ua.port
+}
+
+func (srv *Server) Close() {
+    srv.ua.close()
+    srv.sessions.closeAll()
+}
+
+func (srv *Server) Device(deviceID string) (RemoteDevice, bool) {
+    srv.mtx.Lock()
+    defer srv.mtx.Unlock()
+    if dev, found := srv.devices[deviceID]; found {
+        return *dev, true
+    }
+    return RemoteDevice{}, false
+}
+
+func (srv *Server) uri(id string) string {
+    return "sip:" + id + "@" + srv.cfg.Domain
+}
+
+func (srv *Server) handleRequest(req *SipMessage, from *net.UDPAddr) {
+    switch req.Method {
+    case SIP_REGISTER:
+        srv.onRegister(req, from)
+    case SIP_MESSAGE:
+        srv.onMessage(req, from)
+    case SIP_BYE:
+        if sess := srv.sessions.onBye(srv.ua, req, from); sess != nil && srv.OnBye != nil {
+            srv.OnBye(sess)
+        }
+    case SIP_ACK:
+    default:
+        srv.ua.respond(req, NewSipResponse(req, SIP_STATUS_NOT_IMPLEMENTED), from)
+    }
+}
+
+func (srv *Server) onRegister(req *SipMessage, from *net.UDPAddr) {
+    deviceID := uriUser(addressUri(req.Header(SipFrom)))
+    if deviceID == "" {
+        srv.ua.respond(req, NewSipResponse(req, SIP_STATUS_BAD_REQUEST), from)
+        return
+    }
+    if srv.cfg.Password != "" {
+        srv.mtx.Lock()
+        auth, found := srv.auths[deviceID]
+        if !found {
+            auth = rtsp.NewDigestAuth(deviceID, srv.cfg.Password, srv.cfg.Domain)
+            srv.auths[deviceID] = auth
+        }
+        authorization := req.Header(SipAuthorization)
+        if authorization == "" || !auth.Check(authorization, SIP_REGISTER) {
+            res := NewSipResponse(req, SIP_STATUS_UNAUTHORIZED)
+            res.AddHeader(SipWWWAuthenticate, auth.Challenge())
+            srv.mtx.Unlock()
+            srv.ua.respond(req, res, from)
+            return
+        }
+        srv.mtx.Unlock()
+    }
+
+    expires, found := req.Expires()
+    if !found {
+        expires = DEFAULT_EXPIRES
+    }
+    res := NewSipResponse(req, SIP_STATUS_OK)
+    if contact := req.Header(SipContact); contact != "" {
+        res.AddHeader(SipContact, contact)
+    }
+    res.AddHeader(SipExpires, strconv.Itoa(expires))
+    //devices synchronize the time by Date of REGISTER response
+    res.AddHeader(SipDate, time.Now().Format(SIP_DATE_FORMAT))
+
+    if expires == 0 {
+        srv.mtx.Lock()
+        delete(srv.devices, deviceID)
+        srv.mtx.Unlock()
+        srv.ua.respond(req, res, from)
+        if srv.OnUnregister != nil {
+            srv.OnUnregister(deviceID)
+        }
+        return
+    }
+    now := time.Now()
+    dev := &RemoteDevice{
+        ID:            deviceID,
+        Addr:          from,
+        Expires:       expires,
+        RegisterTime:  now,
+        LastKeepalive: now,
+    }
+    srv.mtx.Lock()
+    srv.devices[deviceID] = dev
+    srv.mtx.Unlock()
+    srv.ua.respond(req, res, from)
+    if srv.OnRegister != nil {
+        srv.OnRegister(*dev)
+    }
+}
+
+func (srv *Server) onMessage(req *SipMessage, from *net.UDPAddr) {
+    deviceID := uriUser(addressUri(req.Header(SipFrom)))
+    srv.mtx.Lock()
+    dev, found := srv.devices[deviceID]
+    srv.mtx.Unlock()
+    if !found {
+        //let the device register again
+        srv.ua.respond(req, NewSipResponse(req, SIP_STATUS_FORBIDDEN), from)
+        return
+    }
+    msg, err := DecodeManscdp(req.Body)
+    if err != nil {
+        srv.ua.respond(req, NewSipResponse(req, SIP_STATUS_BAD_REQUEST), from)
+        return
+    }
+    srv.ua.respond(req, NewSipResponse(req, SIP_STATUS_OK), from)
+
+    switch {
+    case msg.XMLName.Local == MANSCDP_NOTIFY && msg.CmdType == CMD_KEEPALIVE:
+        srv.mtx.Lock()
+        dev.LastKeepalive = time.Now()
+        dev.Addr = from
+        keepalive := *dev
+        srv.mtx.Unlock()
+        if srv.OnKeepalive != nil {
+            srv.OnKeepalive(keepalive)
+        }
+    case msg.XMLName.Local == MANSCDP_RESPONSE:
+        srv.onQueryResponse(deviceID, msg)
+    }
+}
+
+func queryKey(deviceID string, sn int) string {
+    return deviceID + ":" + strconv.Itoa(sn)
+}
+
+// catalog may be split into several messages,it is done when SumNum items are received
+func (srv *Server) onQueryResponse(deviceID string, msg *Manscdp) {
+    srv.mtx.Lock()
+    defer srv.mtx.Unlock()
+    query, found := srv.queries[queryKey(deviceID, msg.SN)]
+    if !found || query.closed || query.cmdType != msg.CmdType {
+        return
+    }
+    if msg.CmdType == CMD_CATALOG {
+        if msg.DeviceList != nil {
+            query.items = append(query.items, msg.DeviceList.Items...)
+        }
+        if len(query.items) < msg.SumNum {
+            return
+        }
+    }
+    query.result = msg
+    query.closed = true
+    close(query.done)
+}
+
+func (srv *Server) query(deviceID string, cmdType string) (*pendingQuery, error) {
+    dev, found := srv.Device(deviceID)
+    if !found {
+        return nil, errDeviceNotRegistered
+    }
+    sn := int(atomic.AddInt32(&srv.sn, 1))
+    key := queryKey(deviceID, sn)
+    query := &pendingQuery{cmdType: cmdType, done: make(chan struct{})}
+    srv.mtx.Lock()
+    srv.queries[key] = query
+    srv.mtx.Unlock()
+    defer func() {
+        srv.mtx.Lock()
+        delete(srv.queries, key)
+        srv.mtx.Unlock()
+    }()
+
+    body, err := NewManscdp(MANSCDP_QUERY, cmdType, sn, deviceID).Encode()
+    if err != nil {
+        return nil, err
+    }
+    req := srv.ua.newRequest(SIP_MESSAGE, "sip:"+deviceID+"@"+dev.Addr.String(), srv.uri(srv.cfg.ID), srv.uri(deviceID))
+    req.AddHeader(SipContentType, MANSCDP_CONTENT_TYPE)
+    req.Body = body
+    res, err := srv.ua.request(req, dev.Addr)
+    if err != nil {
+        return nil, err
+    }
+    if res.StatusCode != SIP_STATUS_OK {
+        return nil, fmt.Errorf("query %s failed: %d %s", cmdType, res.StatusCode, res.Reason)
+    }
+    select {
+    case <-query.done:
+    case <-time.After(DEFAULT_QUERY_TIMEOUT):
+        return nil, fmt.Errorf("query %s timeout", cmdType)
+    }
+    return query, nil
+}
+
+func (srv *Server) QueryCatalog(deviceID string) ([]CatalogItem, error) {
+    query, err := srv.query(deviceID, CMD_CATALOG)
+    if err != nil {
+        return nil, err
+    }
+    return query.items, nil
+}
+
+func (srv *Server) QueryDeviceInfo(deviceID string) (*Manscdp, error) {
+    query, err := srv.query(deviceID, CMD_DEVICE_INFO)
+    if err != nil {
+        return nil, err
+    }
+    return query.result, nil
+}
+
+// y= of realtime stream, 0 + domain[3:8] + sequence
+func (srv *Server) newSSRC() string {
+    prefix := "00000"
+    if len(srv.cfg.Domain) >= 8 {
+        prefix = srv.cfg.Domain[3:8]
+    }
+    return fmt.Sprintf("0%s%04d", prefix, atomic.AddUint32(&srv.ssrcSeq, 1)%10000)
+}
+
+// Invite the realtime stream of channel,
+// set Receiver.OnFrame of the returned session then call Serve to receive the stream
+func (srv *Server) Invite(deviceID string, channelID string, transport MEDIA_TRANSPORT) (*Session, error) {
+    dev, found := srv.Device(deviceID)
+    if !found {
+        return nil, errDeviceNotRegistered
+    }
+    offer := &GBSdp{
+        Username:    srv.cfg.ID,
+        SessionName: SDP_SESSION_PLAY,
+        IP:          srv.ua.ip,
+        Port:        9, //RFC4145 discard port for active
+        Transport:   transport,
+        Direction:   SDP_RECVONLY,
+        SSRC:        srv.newSSRC(),
+    }
+    receiver := NewMediaReceiver(transport)
+    receiver.SetSSRC(offer.SSRCValue())
+    if transport != MEDIA_TRANSPORT_TCP_ACTIVE {
+        if err := receiver.Listen(":0"); err != nil {
+            return nil, err
+        }
+        offer.Port = receiver.LocalPort()
+    }
+
+    req := srv.ua.newRequest(SIP_INVITE, "sip:"+channelID+"@"+dev.Addr.String(), srv.uri(srv.cfg.ID), srv.uri(channelID))
+    req.AddHeader(SipContact, "<sip:"+srv.cfg.ID+"@"+srv.ua.hostport()+">")
+    req.AddHeader(SipSubject, channelID+":"+offer.SSRC+","+srv.cfg.ID+":0")
+    req.AddHeader(SipContentType, "Application/SDP")
+    req.Body = []byte(offer.Encode())
+    res, err := srv.ua.request(req, dev.Addr)
+    if err != nil {
+        receiver.Close()
+        return nil, err
+    }
+    if res.StatusCode != SIP_STATUS_OK {
+        receiver.Close()
+        return nil, fmt.Errorf("invite failed: %d %s", res.StatusCode, res.Reason)
+    }
+
+    cseq, _ := req.CSeq()
+    sess := &Session{
+        CallID:    req.CallID(),
+        DeviceID:  deviceID,
+        ChannelID: channelID,
+        SSRC:      offer.SSRCValue(),
+        LocalSdp:  offer,
+        RemoteSdp: &GBSdp{},
+        Receiver:  receiver,
+        ua:        srv.ua,
+        remote:    dev.Addr,
+        from:      req.Header(SipFrom),
+        to:        res.Header(SipTo),
+        target:    req.Uri,
+        cseq:      cseq,
+    }
+    if contact := addressUri(res.Header(SipContact)); contact != "" {
+        sess.target = contact
+    }
+    ack := NewSipRequest(SIP_ACK, sess.target)
+    ack.AddHeader(SipVia, srv.ua.via())
+    ack.AddHeader(SipFrom, sess.from)
+    ack.AddHeader(SipTo, sess.to)
+    ack.AddHeader(SipCallID, sess.CallID)
+    ack.AddHeader(SipCSeq, strconv.FormatUint(uint64(cseq), 10)+" "+SIP_ACK)
+    if err := srv.ua.send(ack, dev.Addr); err != nil {
+        receiver.Close()
+        return nil, err
+    }
+    srv.sessions.add(sess)
+
+    err = sess.RemoteSdp.Decode(string(res.Body))
+    if err == nil && transport == MEDIA_TRANSPORT_TCP_ACTIVE {
+        err = receiver.Dial(net.JoinHostPort(sess.RemoteSdp.IP, strconv.Itoa(sess.RemoteSdp.Port)))
+    }
+    if err != nil {
+        sess.Bye()
+        return nil, err
+    }
+    return sess, nil
+}
@@ -0,0 +1,237 @@
+package gb28181
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const (
+	testServerID = "34020000002000000001"
+	testDeviceID = "34020000001110000001"
+)
+
+func newTestPair(t *testing.T, serverPasswd, devicePasswd string, channels int) (*Server, *Device) {
+	srv := NewServer(ServerConfig{ID: testServerID, Password: serverPasswd, ListenAddr: "127.0.0.1:0"})
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Server.Start() error = %v", err)
+	}
+	cfg := DeviceConfig{
+		ID:           testDeviceID,
+		Password:     devicePasswd,
+		ServerID:     testServerID,
+		ServerAddr:   fmt.Sprintf("127.0.0.1:%d", srv.LocalPort()),
+		ListenAddr:   "127.0.0.1:0",
+		Name:         "IPC",
+		Manufacturer: "gomedia",
+	}
+	for i := 0; i < channels; i++ {
+		cfg.Channels = append(cfg.Channels, CatalogItem{DeviceID: fmt.Sprintf("3402000000131000%04d", i+1), Name: fmt.Sprintf("camera%d", i+1), Status: "ON"})
+	}
+	dev := NewDevice(cfg)
+	if err := dev.Start(); err != nil {
+		srv.Close()
+		t.Fatalf("Device.Start() error = %v", err)
+	}
+	return srv, dev
+}
+
+func TestServer_Register(t *testing.T) {
+	tests := []struct {
+		name         string
+		serverPasswd string
+		devicePasswd string
+		wantErr      bool
+	}{
+		{name: "no auth", serverPasswd: "", devicePasswd: ""},
+		{name: "digest", serverPasswd: "12345678", devicePasswd: "12345678"},
+		{name: "wrong password", serverPasswd: "12345678", devicePasswd: "87654321", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, dev := newTestPair(t, tt.serverPasswd, tt.devicePasswd, 0)
+			defer srv.Close()
+			defer dev.Close()
+			err := dev.Register()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Device.Register() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, found := srv.Device(testDeviceID); found == tt.wantErr {
+				t.Fatalf("Server.Device() found = %v", found)
+			}
+			if tt.wantErr {
+				return
+			}
+			if err := dev.Keepalive(); err != nil {
+				t.Errorf("Device.Keepalive() error = %v", err)
+			}
+			if err := dev.Unregister(); err != nil {
+				t.Errorf("Device.Unregister() error = %v", err)
+			}
+			if _, found := srv.Device(testDeviceID); found {
+				t.Errorf("device is still registered after unregister")
+			}
+			//messages from unregistered device are forbidden
+			if err := dev.Keepalive(); err == nil {
+				t.Errorf("Device.Keepalive() of unregistered device succeeded")
+			}
+		})
+	}
+}
+
+func TestServer_Query(t *testing.T) {
+	srv, dev := newTestPair(t, "12345678", "12345678", 10)
+	defer srv.Close()
+	defer dev.Close()
+	if err := dev.Register(); err != nil {
+		t.Fatalf("Device.Register() error = %v", err)
+	}
+	items, err := srv.QueryCatalog(testDeviceID)
+	if err != nil {
+		t.Fatalf("Server.QueryCatalog() error = %v", err)
+	}
+	if len(items) != 10 || items[9].DeviceID != "34020000001310000010" || items[0].Name != "camera1" {
+		t.Errorf("Server.QueryCatalog() = %+v", items)
+	}
+	info, err := srv.QueryDeviceInfo(testDeviceID)
+	if err != nil {
+		t.Fatalf("Server.QueryDeviceInfo() error = %v", err)
+	}
+	if info.DeviceName != "IPC" || info.Manufacturer != "gomedia" || info.Channel != 10 {
+		t.Errorf("Server.QueryDeviceInfo() = %+v", info)
+	}
+	if _, err := srv.QueryCatalog("34020000001110000002"); err == nil {
+		t.Errorf("Server.QueryCatalog() of unknown device succeeded")
+	}
+}
+
+func TestServer_Invite(t *testing.T) {
+	transports := []MEDIA_TRANSPORT{MEDIA_TRANSPORT_UDP, MEDIA_TRANSPORT_TCP_PASSIVE, MEDIA_TRANSPORT_TCP_ACTIVE}
+	for _, transport := range transports {
+		t.Run(transport.String(), func(t *testing.T) {
+			srv, dev := newTestPair(t, "", "", 1)
+			defer srv.Close()
+			defer dev.Close()
+			played := make(chan error, 1)
+			dev.OnPlay = func(sess *Session) {
+				played <- writeTestFrames(sess.Sender, 30)
+			}
+			devBye := make(chan *Session, 1)
+			dev.OnBye = func(sess *Session) {
+				devBye <- sess
+			}
+			if err := dev.Register(); err != nil {
+				t.Fatalf("Device.Register() error = %v", err)
+			}
+			if _, err := srv.Invite(testDeviceID, "34020000001310009999", transport); err == nil {
+				t.Errorf("Server.Invite() of unknown channel succeeded")
+			}
+
+			sess, err := srv.Invite(testDeviceID, "34020000001310000001", transport)
+			if err != nil {
+				t.Fatalf("Server.Invite() error = %v", err)
+			}
+			if sess.RemoteSdp.SSRC != sess.LocalSdp.SSRC || sess.SSRC == 0 {
+				t.Errorf("ssrc of answer %s, want %s", sess.RemoteSdp.SSRC, sess.LocalSdp.SSRC)
+			}
+			fc := &frameCounter{}
+			sess.Receiver.OnFrame = fc.onFrame
+			done := make(chan struct{})
+			go func() {
+				sess.Serve()
+				close(done)
+			}()
+			select {
+			case err := <-played:
+				if err != nil {
+					t.Fatalf("MediaSender.WriteFrame() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("device did not receive ACK")
+			}
+			time.Sleep(100 * time.Millisecond)
+			if err := sess.Bye(); err != nil {
+				t.Errorf("Session.Bye() error = %v", err)
+			}
+			<-done
+			select {
+			case bye := <-devBye:
+				if bye.CallID != sess.CallID {
+					t.Errorf("device got BYE of %s, want %s", bye.CallID, sess.CallID)
+				}
+			case <-time.After(time.Second):
+				t.Errorf("device did not receive BYE")
+			}
+			fc.mtx.Lock()
+			defer fc.mtx.Unlock()
+			if fc.slices != 30 {
+				t.Errorf("Session.Receiver got %d frames, want 30", fc.slices)
+			}
+		})
+	}
+}
+
+func TestServer_Expiry(t *testing.T) {
+	srv, dev := newTestPair(t, "", "", 0)
+	defer srv.Close()
+	defer dev.Close()
+	unregistered := make(chan string, 4)
+	srv.OnUnregister = func(deviceID string) {
+		unregistered <- deviceID
+	}
+	if err := dev.Register(); err != nil {
+		t.Fatalf("Device.Register() error = %v", err)
+	}
+	registered, _ := srv.Device(testDeviceID)
+
+	//keepalive timeout
+	srv.CheckExpiry(registered.LastKeepalive.Add(DEFAULT_KEEPALIVE_TIMEOUT - time.Second))
+	if _, found := srv.Device(testDeviceID); !found {
+		t.Fatalf("device is removed before keepalive timeout")
+	}
+	srv.CheckExpiry(registered.LastKeepalive.Add(DEFAULT_KEEPALIVE_TIMEOUT + time.Second))
+	if _, found := srv.Device(testDeviceID); found {
+		t.Fatalf("device is still registered after keepalive timeout")
+	}
+	if id := <-unregistered; id != testDeviceID {
+		t.Errorf("OnUnregister(%s), want %s", id, testDeviceID)
+	}
+
+	//registration expires although keepalive is received
+	srv.mtx.Lock()
+	srv.cfg.KeepaliveTimeout = -1
+	srv.mtx.Unlock()
+	if err := dev.Register(); err != nil {
+		t.Fatalf("Device.Register() error = %v", err)
+	}
+	if err := dev.Keepalive(); err != nil {
+		t.Fatalf("Device.Keepalive() error = %v", err)
+	}
+	registered, _ = srv.Device(testDeviceID)
+	expires := registered.RegisterTime.Add(time.Duration(registered.Expires) * time.Second)
+	srv.CheckExpiry(expires.Add(-time.Second))
+	if _, found := srv.Device(testDeviceID); !found {
+		t.Fatalf("device is removed before the registration expires")
+	}
+	srv.CheckExpiry(expires.Add(time.Second))
+	if _, found := srv.Device(testDeviceID); found {
+		t.Fatalf("device is still registered after the registration expires")
+	}
+	<-unregistered
+
+	//the server checks the registrations by itself
+	srv.mtx.Lock()
+	srv.cfg.KeepaliveTimeout = 100 * time.Millisecond
+	srv.mtx.Unlock()
+	if err := dev.Register(); err != nil {
+		t.Fatalf("Device.Register() error = %v", err)
+	}
+	select {
+	case <-unregistered:
+	case <-time.After(3 * expiryCheckInterval):
+		t.Fatalf("device without keepalive is not removed")
+	}
+	if err := dev.Keepalive(); err == nil {
+		t.Errorf("Device.Keepalive() of removed device succeeded")
+	}
+}
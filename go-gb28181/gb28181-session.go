@@ -0,0 +1,136 @@
+package gb28181
+
+import (
+    "errors"
+    "fmt"
+    "net"
+    "strconv"
+    "sync"
+    "sync/atomic"
+)
+
+// Session is the INVITE dialog and its PS over RTP media,
+// the platform receives the stream by Receiver,the device sends the stream by Sender
+type Session struct {
+    CallID    string
+    DeviceID  string
+    ChannelID string
+    SSRC      uint32
+    LocalSdp  *GBSdp
+    RemoteSdp *GBSdp
+    Receiver  *MediaReceiver
+    Sender    *MediaSender
+    ua        *sipUA
+    remote    *net.UDPAddr
+    from      string //From of in-dialog request,with local tag
+    to        string //To of in-dialog request,with remote tag
+    target    string //Request-URI of in-dialog request
+    cseq      uint32
+    mtx       sync.Mutex
+    closed    bool
+    onClose   func(sess *Session)
+}
+
+// platform side,read the stream until the session is closed
+func (sess *Session) Serve() error {
+    if sess.Receiver == nil {
+        return errors.New("session has no media receiver")
+    }
+    return sess.Receiver.Serve()
+}
+
+// send BYE to the peer and close the media
+func (sess *Session) Bye() error {
+    if !sess.close() {
+        return nil
+    }
+    req := NewSipRequest(SIP_BYE, sess.target)
+    req.AddHeader(SipVia, sess.ua.via())
+    req.AddHeader(SipFrom, sess.from)
+    req.AddHeader(SipTo, sess.to)
+    req.AddHeader(SipCallID, sess.CallID)
+    req.AddHeader(SipCSeq, strconv.FormatUint(uint64(atomic.AddUint32(&sess.cseq, 1)), 10)+" "+SIP_BYE)
+    res, err := sess.ua.request(req, sess.remote)
+    if err != nil {
+        return err
+    }
+    if res.StatusCode != SIP_STATUS_OK {
+        return fmt.Errorf("bye failed: %d %s", res.StatusCode, res.Reason)
+    }
+    return nil
+}
+
+// return false if the session is already closed
+func (sess *Session) close() bool {
+    sess.mtx.Lock()
+    if sess.closed {
+        sess.mtx.Unlock()
+        return false
+    }
+    sess.closed = true
+    sess.mtx.Unlock()
+    if sess.Receiver != nil {
+        sess.Receiver.Close()
+    }
+    if sess.Sender != nil {
+        sess.Sender.Close()
+    }
+    if sess.onClose != nil {
+        sess.onClose(sess)
+    }
+    return true
+}
+
+// sessions by Call-ID
+type sessionTable struct {
+    mtx      sync.Mutex
+    sessions map[string]*Session
+}
+
+func (table *sessionTable) add(sess *Session) {
+    table.mtx.Lock()
+    defer table.mtx.Unlock()
+    if table.sessions == nil {
+        table.sessions = make(map[string]*Session)
+    }
+    table.sessions[sess.CallID] = sess
+    sess.onClose = table.remove
+}
+
+func (table *sessionTable) remove(sess *Session) {
+    table.mtx.Lock()
+    defer table.mtx.Unlock()
+    delete(table.sessions, sess.CallID)
+}
+
+func (table *sessionTable) get(callID string) *Session {
+    table.mtx.Lock()
+    defer table.mtx.Unlock()
+    return table.sessions[callID]
+}
+
+func (table *sessionTable) closeAll() {
+    table.mtx.Lock()
+    sessions := make([]*Session, 0, len(table.sessions))
+    for _, sess := range table.sessions {
+        sessions = append(sessions, sess)
+    }
+    table.mtx.Unlock()
+    for _, sess := range sessions {
+        sess.close()
+    }
+}
+
+// BYE from peer,return the closed session
+func (table *sessionTable) onBye(ua *sipUA, req *SipMessage, from *net.UDPAddr) *Session {
+    sess := table.get(req.CallID())
+    if sess == nil {
+        ua.respond(req, NewSipResponse(req, SIP_STATUS_CALL_DOES_NOT_EXIST), from)
+        return nil
+    }
+    ua.respond(req, NewSipResponse(req, SIP_STATUS_OK), from)
+    if !sess.close() {
+        return nil
+    }
+    return sess
+}
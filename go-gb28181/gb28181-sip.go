@@ -0,0 +1,371 @@
+package gb28181
+
+import (
+    "bytes"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// minimal SIP(RFC3261) message for GB/T 28181 signaling over UDP
+
+const SIP_VERSION = "SIP/2.0"
+
+const (
+    SIP_REGISTER = "REGISTER"
+    SIP_MESSAGE  = "MESSAGE"
+    SIP_INVITE   = "INVITE"
+    SIP_ACK      = "ACK"
+    SIP_BYE      = "BYE"
+    SIP_CANCEL   = "CANCEL"
+)
+
+const (
+    SIP_STATUS_TRYING              = 100
+    SIP_STATUS_OK                  = 200
+    SIP_STATUS_BAD_REQUEST         = 400
+    SIP_STATUS_UNAUTHORIZED        = 401
+    SIP_STATUS_FORBIDDEN           = 403
+    SIP_STATUS_NOT_FOUND           = 404
+    SIP_STATUS_REQUEST_TIMEOUT     = 408
+    SIP_STATUS_CALL_DOES_NOT_EXIST = 481
+    SIP_STATUS_INTERNAL_ERROR      = 500
+    SIP_STATUS_NOT_IMPLEMENTED     = 501
+    SIP_STATUS_UNSUPPORTED_MEDIA   = 415
+    SIP_STATUS_SERVICE_UNAVAILABLE = 503
+)
+
+var sipReason = map[int]string{
+    SIP_STATUS_TRYING:              "Trying",
+    SIP_STATUS_OK:                  "OK",
+    SIP_STATUS_BAD_REQUEST:         "Bad Request",
+    SIP_STATUS_UNAUTHORIZED:        "Unauthorized",
+    SIP_STATUS_FORBIDDEN:           "Forbidden",
+    SIP_STATUS_NOT_FOUND:           "Not Found",
+    SIP_STATUS_REQUEST_TIMEOUT:     "Request Timeout",
+    SIP_STATUS_UNSUPPORTED_MEDIA:   "Unsupported Media Type",
+    SIP_STATUS_CALL_DOES_NOT_EXIST: "Call/Transaction Does Not Exist",
+    SIP_STATUS_INTERNAL_ERROR:      "Server Internal Error",
+    SIP_STATUS_NOT_IMPLEMENTED:     "Not Implemented",
+    SIP_STATUS_SERVICE_UNAVAILABLE: "Service Unavailable",
+}
+
+const (
+    SipVia             = "Via"
+    SipFrom            = "From"
+    SipTo              = "To"
+    SipCallID          = "Call-ID"
+    SipCSeq            = "CSeq"
+    SipContact         = "Contact"
+    SipMaxForwards     = "Max-Forwards"
+    SipExpires         = "Expires"
+    SipContentType     = "Content-Type"
+    SipContentLength   = "Content-Length"
+    SipUserAgent       = "User-Agent"
+    SipAuthorization   = "Authorization"
+    SipWWWAuthenticate = "WWW-Authenticate"
+    SipSubject         = "Subject"
+    SipDate            = "Date"
+)
+
+// header names are case-insensitive,some of them have compact form
+var sipHeaderNames = map[string]string{
+    "via":              SipVia,
+    "v":                SipVia,
+    "from":             SipFrom,
+    "f":                SipFrom,
+    "to":               SipTo,
+    "t":                SipTo,
+    "call-id":          SipCallID,
+    "i":                SipCallID,
+    "cseq":             SipCSeq,
+    "contact":          SipContact,
+    "m":                SipContact,
+    "max-forwards":     SipMaxForwards,
+    "expires":          SipExpires,
+    "content-type":     SipContentType,
+    "c":                SipContentType,
+    "content-length":   SipContentLength,
+    "l":                SipContentLength,
+    "user-agent":       SipUserAgent,
+    "authorization":    SipAuthorization,
+    "www-authenticate": SipWWWAuthenticate,
+    "subject":          SipSubject,
+    "s":                SipSubject,
+    "date":             SipDate,
+}
+
+func canonicalSipHeader(name string) string {
+    if canonical, found := sipHeaderNames[strings.ToLower(name)]; found {
+        return canonical
+    }
+    return name
+}
+
+type sipHeader struct {
+    name  string
+    value string
+}
+
+type SipMessage struct {
+    IsRequest  bool
+    Method     string
+    Uri        string
+    StatusCode int
+    Reason     string
+    headers    []sipHeader
+    Body       []byte
+}
+
+var errIncompleteSip = errors.New("incomplete sip message")
+
+func ParseSipMessage(data []byte) (*SipMessage, error) {
+    end := bytes.Index(data, []byte("\r\n\r\n"))
+    if end < 0 {
+        return nil, errIncompleteSip
+    }
+    lines := strings.Split(string(data[:end]), "\r\n")
+    msg := &SipMessage{}
+    if strings.HasPrefix(lines[0], SIP_VERSION+" ") {
+        items := strings.SplitN(lines[0], " ", 3)
+        code, err := strconv.Atoi(items[1])
+        if err != nil {
+            return nil, errors.New("invalid sip status code")
+        }
+        msg.StatusCode = code
+        if len(items) > 2 {
+            msg.Reason = items[2]
+        }
+    } else {
+        items := strings.SplitN(lines[0], " ", 3)
+        if len(items) != 3 || items[2] != SIP_VERSION {
+            return nil, errors.New("invalid sip request line")
+        }
+        msg.IsRequest = true
+        msg.Method = items[0]
+        msg.Uri = items[1]
+    }
+
+    for _, line := range lines[1:] {
+        if len(line) == 0 {
+            continue
+        }
+        //header folding
+        if (line[0] == ' ' || line[0] == '\t') && len(msg.headers) > 0 {
+            msg.headers[len(msg.headers)-1].value += " " + strings.TrimSpace(line)
+            continue
+        }
+        kv := strings.SplitN(line, ":", 2)
+        if len(kv) != 2 {
+            return nil, errors.New("invalid sip header " + line)
+        }
+        msg.headers = append(msg.headers, sipHeader{
+            name:  canonicalSipHeader(strings.TrimSpace(kv[0])),
+            value: strings.TrimSpace(kv[1]),
+        })
+    }
+
+    body := data[end+4:]
+    if cl := msg.Header(SipContentLength); cl != "" {
+        length, err := strconv.Atoi(cl)
+        if err != nil || length < 0 {
+            return nil, errors.New("invalid sip content-length")
+        }
+        if length > len(body) {
+            return nil, errIncompleteSip
+        }
+        body = body[:length]
+    }
+    if len(body) > 0 {
+        msg.Body = append([]byte{}, body...)
+    }
+    return msg, nil
+}
+
+// Content-Length is always generated from Body
+func (msg *SipMessage) Encode() []byte {
+    var buf bytes.Buffer
+    if msg.IsRequest {
+        buf.WriteString(msg.Method + " " + msg.Uri + " " + SIP_VERSION + "\r\n")
+    } else {
+        reason := msg.Reason
+        if reason == "" {
+            reason = sipReason[msg.StatusCode]
+        }
+        buf.WriteString(SIP_VERSION + " " + strconv.Itoa(msg.StatusCode) + " " + reason + "\r\n")
+    }
+    for _, hdr := range msg.headers {
+        if hdr.name == SipContentLength {
+            continue
+        }
+        buf.WriteString(hdr.name + ": " + hdr.value + "\r\n")
+    }
+    buf.WriteString(SipContentLength + ": " + strconv.Itoa(len(msg.Body)) + "\r\n\r\n")
+    buf.Write(msg.Body)
+    return buf.Bytes()
+}
+
+// the first value of the header
+func (msg *SipMessage) Header(name string) string {
+    name = canonicalSipHeader(name)
+    for _, hdr := range msg.headers {
+        if strings.EqualFold(hdr.name, name) {
+            return hdr.value
+        }
+    }
+    return ""
+}
+
+func (msg *SipMessage) Headers(name string) []string {
+    name = canonicalSipHeader(name)
+    var values []string
+    for _, hdr := range msg.headers {
+        if strings.EqualFold(hdr.name, name) {
+            values = append(values, hdr.value)
+        }
+    }
+    return values
+}
+
+func (msg *SipMessage) AddHeader(name string, value string) {
+    msg.headers = append(msg.headers, sipHeader{name: canonicalSipHeader(name), value: value})
+}
+
+// replace all the values of the header
+func (msg *SipMessage) SetHeader(name string, value string) {
+    msg.DelHeader(name)
+    msg.AddHeader(name, value)
+}
+
+func (msg *SipMessage) DelHeader(name string) {
+    name = canonicalSipHeader(name)
+    headers := msg.headers[:0]
+    for _, hdr := range msg.headers {
+        if !strings.EqualFold(hdr.name, name) {
+            headers = append(headers, hdr)
+        }
+    }
+    msg.headers = headers
+}
+
+func (msg *SipMessage) CallID() string {
+    return msg.Header(SipCallID)
+}
+
+func (msg *SipMessage) CSeq() (uint32, string) {
+    items := strings.Fields(msg.Header(SipCSeq))
+    if len(items) != 2 {
+        return 0, ""
+    }
+    seq, _ := strconv.ParseUint(items[0], 10, 32)
+    return uint32(seq), items[1]
+}
+
+// branch of the top Via,identifies the transaction
+func (msg *SipMessage) Branch() string {
+    return headerParam(msg.Header(SipVia), "branch")
+}
+
+func (msg *SipMessage) FromTag() string {
+    return headerParam(msg.Header(SipFrom), "tag")
+}
+
+func (msg *SipMessage) ToTag() string {
+    return headerParam(msg.Header(SipTo), "tag")
+}
+
+func (msg *SipMessage) Expires() (int, bool) {
+    if expires := msg.Header(SipExpires); expires != "" {
+        if n, err := strconv.Atoi(expires); err == nil {
+            return n, true
+        }
+    }
+    if expires := headerParam(msg.Header(SipContact), "expires"); expires != "" {
+        if n, err := strconv.Atoi(expires); err == nil {
+            return n, true
+        }
+    }
+    return 0, false
+}
+
+func NewSipRequest(method string, uri string) *SipMessage {
+    return &SipMessage{IsRequest: true, Method: method, Uri: uri}
+}
+
+// Via,From,To,Call-ID and CSeq are copied from request,
+// a new tag is added to To if it has none
+func NewSipResponse(req *SipMessage, code int) *SipMessage {
+    res := &SipMessage{StatusCode: code, Reason: sipReason[code]}
+    for _, via := range req.Headers(SipVia) {
+        res.AddHeader(SipVia, via)
+    }
+    res.AddHeader(SipFrom, req.Header(SipFrom))
+    to := req.Header(SipTo)
+    if code > SIP_STATUS_TRYING && req.ToTag() == "" {
+        to += ";tag=" + newTag()
+    }
+    res.AddHeader(SipTo, to)
+    res.AddHeader(SipCallID, req.Header(SipCallID))
+    res.AddHeader(SipCSeq, req.Header(SipCSeq))
+    return res
+}
+
+// <sip:user@host:port>;tag=xxx  => sip:user@host:port
+func addressUri(value string) string {
+    if begin := strings.IndexByte(value, '<'); begin >= 0 {
+        if end := strings.IndexByte(value[begin:], '>'); end > 0 {
+            return value[begin+1 : begin+end]
+        }
+    }
+    if pos := strings.IndexByte(value, ';'); pos >= 0 {
+        value = value[:pos]
+    }
+    return strings.TrimSpace(value)
+}
+
+// parameter of header value, e.g. tag of From, branch of Via
+func headerParam(value string, key string) string {
+    if end := strings.IndexByte(value, '>'); end >= 0 {
+        value = value[end+1:]
+    }
+    for _, param := range strings.Split(value, ";") {
+        kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+        if strings.EqualFold(kv[0], key) {
+            if len(kv) == 2 {
+                return strings.Trim(kv[1], "\"")
+            }
+            return ""
+        }
+    }
+    return ""
+}
+
+// sip:user@host:port => user
+func uriUser(uri string) string {
+    uri = strings.TrimPrefix(strings.TrimPrefix(uri, "sip:"), "sips:")
+    if pos := strings.IndexByte(uri, '@'); pos >= 0 {
+        return uri[:pos]
+    }
+    return ""
+}
+
+func randomHex(n int) string {
+    buf := make([]byte, n)
+    rand.Read(buf)
+    return hex.EncodeToString(buf)
+}
+
+func newTag() string {
+    return randomHex(4)
+}
+
+// RFC3261 magic cookie
+func newBranch() string {
+    return "z9hG4bK" + randomHex(8)
+}
+
+func newCallID() string {
+    return randomHex(16)
+}
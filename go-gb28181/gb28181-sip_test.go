@@ -0,0 +1,125 @@
+package gb28181
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSipMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		method  string
+		code    int
+		callID  string
+		branch  string
+		cseq    uint32
+		body    string
+	}{
+		{
+			name: "register",
+			data: "REGISTER sip:34020000002000000001@3402000000 SIP/2.0\r\n" +
+				"Via: SIP/2.0/UDP 192.168.1.64:5060;rport;branch=z9hG4bK1371463273\r\n" +
+				"From: <sip:34020000001320000001@3402000000>;tag=2043466181\r\n" +
+				"To: <sip:34020000001320000001@3402000000>\r\n" +
+				"Call-ID: 1011047669\r\n" +
+				"CSeq: 1 REGISTER\r\n" +
+				"Expires: 3600\r\n" +
+				"Content-Length: 0\r\n\r\n",
+			method: SIP_REGISTER,
+			callID: "1011047669",
+			branch: "z9hG4bK1371463273",
+			cseq:   1,
+		},
+		{
+			name: "compact form and folding",
+			data: "MESSAGE sip:34020000002000000001@3402000000 SIP/2.0\r\n" +
+				"v: SIP/2.0/UDP 192.168.1.64:5060;branch=z9hG4bK42\r\n" +
+				"i: abc\r\n" +
+				"CSeq: 20\r\n" +
+				"  MESSAGE\r\n" +
+				"l: 4\r\n\r\n" +
+				"body-and-more",
+			method: SIP_MESSAGE,
+			callID: "abc",
+			branch: "z9hG4bK42",
+			cseq:   20,
+			body:   "body",
+		},
+		{
+			name: "response",
+			data: "SIP/2.0 401 Unauthorized\r\n" +
+				"Via: SIP/2.0/UDP 192.168.1.64:5060;branch=z9hG4bK1\r\n" +
+				"Call-ID: 1\r\n" +
+				"CSeq: 2 REGISTER\r\n" +
+				"Content-Length: 0\r\n\r\n",
+			code:   401,
+			callID: "1",
+			branch: "z9hG4bK1",
+			cseq:   2,
+		},
+		{
+			name:    "incomplete header",
+			data:    "REGISTER sip:3402000000 SIP/2.0\r\nCSeq: 1 REGISTER\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "incomplete body",
+			data:    "MESSAGE sip:3402000000 SIP/2.0\r\nContent-Length: 10\r\n\r\nabc",
+			wantErr: true,
+		},
+		{
+			name:    "not sip",
+			data:    "GET / HTTP/1.1\r\n\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseSipMessage([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSipMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			cseq, _ := msg.CSeq()
+			if msg.Method != tt.method || msg.StatusCode != tt.code || msg.CallID() != tt.callID ||
+				msg.Branch() != tt.branch || cseq != tt.cseq || string(msg.Body) != tt.body {
+				t.Errorf("ParseSipMessage() = %+v", msg)
+			}
+			//encode and parse again
+			again, err := ParseSipMessage(msg.Encode())
+			if err != nil || !reflect.DeepEqual(again.Body, msg.Body) || again.CallID() != msg.CallID() {
+				t.Errorf("ParseSipMessage(Encode()) = %+v, %v", again, err)
+			}
+		})
+	}
+}
+
+func TestGBSdp(t *testing.T) {
+	tests := []struct {
+		name string
+		sdp  GBSdp
+	}{
+		{name: "udp", sdp: GBSdp{Username: "34020000002000000001", SessionName: SDP_SESSION_PLAY, IP: "192.168.1.2", Port: 30000,
+			Media: "video", Transport: MEDIA_TRANSPORT_UDP, Direction: SDP_RECVONLY, SSRC: "0200000001"}},
+		{name: "tcp passive", sdp: GBSdp{Username: "34020000001320000001", SessionName: SDP_SESSION_PLAY, IP: "192.168.1.64", Port: 15060,
+			Media: "video", Transport: MEDIA_TRANSPORT_TCP_PASSIVE, Direction: SDP_SENDONLY, SSRC: "0200000001", Format: "v/2/4///a///"}},
+		{name: "playback tcp active", sdp: GBSdp{Username: "34020000002000000001", SessionName: SDP_SESSION_PLAYBACK, IP: "10.0.0.1", Port: 9,
+			Media: "video", Transport: MEDIA_TRANSPORT_TCP_ACTIVE, Direction: SDP_RECVONLY, StartTime: 1700000000, EndTime: 1700003600,
+			Uri: "34020000001320000001:0", SSRC: "1200000002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got GBSdp
+			if err := got.Decode(tt.sdp.Encode()); err != nil {
+				t.Fatalf("GBSdp.Decode() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.sdp) {
+				t.Errorf("GBSdp.Decode() = %+v, want %+v", got, tt.sdp)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package gb28181
+
+import (
+    "errors"
+    "net"
+    "strconv"
+    "sync"
+    "sync/atomic"
+    "time"
+)
+
+// RFC3261 17.1.1.1 Timers
+const (
+    SIP_T1                  = 500 * time.Millisecond
+    SIP_T2                  = 4 * time.Second
+    SIP_TRANSACTION_TIMEOUT = 64 * SIP_T1
+)
+
+const DEFAULT_USER_AGENT = "gomedia-gb28181"
+
+var errSipTimeout = errors.New("sip transaction timeout")
+var errSipClosed = errors.New("sip user agent is closed")
+
+type serverTransaction struct {
+    response []byte
+    created  time.Time
+}
+
+// sipUA is the transport and transaction layer over UDP,
+// requests are retransmitted until response received,
+// retransmitted requests from peer are answered with the cached response
+type sipUA struct {
+    conn      *net.UDPConn
+    ip        string
+    port      int
+    userAgent string
+    timeout   time.Duration
+    onRequest func(req *SipMessage, from *net.UDPAddr)
+    mtx       sync.Mutex
+    pending   map[string]chan *SipMessage
+    served    map[string]*serverTransaction
+    cseq      uint32
+    closed    chan struct{}
+    closeOnce sync.Once
+}
+
+func newSipUA(onRequest func(req *SipMessage, from *net.UDPAddr)) *sipUA {
+    return &sipUA{
+        userAgent: DEFAULT_USER_AGENT,
+        timeout:   SIP_TRANSACTION_TIMEOUT,
+        onRequest: onRequest,
+        pending:   make(map[string]chan *SipMessage),
+        served:    make(map[string]*serverTransaction),
+        closed:    make(chan struct{}),
+    }
+}
+
+// ip is the address in Via/Contact/SDP,empty means the host of addr
+func (ua *sipUA) listen(addr string, ip string) error {
+    laddr, err := net.ResolveUDPAddr("udp", addr)
+    if err != nil {
+        return err
+    }
+    if ua.conn, err = net.ListenUDP("udp", laddr); err != nil {
+        return err
+    }
+    local := ua.conn.LocalAddr().(*net.UDPAddr)
+    ua.port = local.Port
+    ua.ip = ip
+    if ua.ip == "" {
+        if local.IP.IsUnspecified() {
+            ua.conn.Close()
+            return errors.New("ip must be specified when listening on unspecified address")
+        }
+        ua.ip = local.IP.String()
+    }
+    return nil
+}
+
+func (ua *sipUA) serve() error {
+    buf := make([]byte, 65536)
+    for {
+        n, from, err := ua.conn.ReadFromUDP(buf)
+        if err != nil {
+            select {
+            case <-ua.closed:
+                return nil
+            default:
+                return err
+            }
+        }
+        ua.input(buf[:n], from)
+    }
+}
+
+func (ua *sipUA) input(data []byte, from *net.UDPAddr) {
+    msg, err := ParseSipMessage(data)
+    if err != nil {
+        return
+    }
+    if !msg.IsRequest {
+        ua.mtx.Lock()
+        ch, found := ua.pending[msg.Branch()]
+        ua.mtx.Unlock()
+        if found {
+            select {
+            case ch <- msg:
+            default:
+            }
+        }
+        return
+    }
+    if msg.Method != SIP_ACK {
+        key := msg.Branch() + " " + msg.Method
+        ua.mtx.Lock()
+        if st, found := ua.served[key]; found {
+            response := st.response
+            ua.mtx.Unlock()
+            if response != nil {
+                ua.conn.WriteToUDP(response, from)
+            }
+            return
+        }
+        now := time.Now()
+        for k, st := range ua.served {
+            if now.Sub(st.created) > SIP_TRANSACTION_TIMEOUT {
+                delete(ua.served, k)
+            }
+        }
+        ua.served[key] = &serverTransaction{created: now}
+        ua.mtx.Unlock()
+    }
+    //handler may send requests and wait for the responses
+    go ua.onRequest(msg, from)
+}
+
+func (ua *sipUA) send(msg *SipMessage, to *net.UDPAddr) error {
+    if msg.IsRequest {
+        if msg.Header(SipMaxForwards) == "" {
+            msg.AddHeader(SipMaxForwards, "70")
+        }
+        if msg.Header(SipUserAgent) == "" {
+            msg.AddHeader(SipUserAgent, ua.userAgent)
+        }
+    }
+    _, err := ua.conn.WriteToUDP(msg.Encode(), to)
+    return err
+}
+
+// send the response of req,it is resent if req is retransmitted
+func (ua *sipUA) respond(req *SipMessage, res *SipMessage, to *net.UDPAddr) error {
+    data := res.Encode()
+    if res.StatusCode >= 200 {
+        ua.mtx.Lock()
+        if st, found := ua.served[req.Branch()+" "+req.Method]; found {
+            st.response = data
+        }
+        ua.mtx.Unlock()
+    }
+    _, err := ua.conn.WriteToUDP(data, to)
+    return err
+}
+
+// send request and wait for the final response,
+// Via is added if req has no Via
+func (ua *sipUA) request(req *SipMessage, to *net.UDPAddr) (*SipMessage, error) {
+    if req.Header(SipVia) == "" {
+        req.AddHeader(SipVia, ua.via())
+    }
+    branch := req.Branch()
+    ch := make(chan *SipMessage, 4)
+    ua.mtx.Lock()
+    ua.pending[branch] = ch
+    ua.mtx.Unlock()
+    defer func() {
+        ua.mtx.Lock()
+        delete(ua.pending, branch)
+        ua.mtx.Unlock()
+    }()
+
+    if err := ua.send(req, to); err != nil {
+        return nil, err
+    }
+    data := req.Encode()
+    interval := SIP_T1
+    retransmit := time.NewTimer(interval)
+    defer retransmit.Stop()
+    timeout := time.NewTimer(ua.timeout)
+    defer timeout.Stop()
+    for {
+        select {
+        case res := <-ch:
+            if res.StatusCode >= 200 {
+                return res, nil
+            }
+            //provisional response,stop retransmission
+            retransmit.Stop()
+        case <-retransmit.C:
+            if _, err := ua.conn.WriteToUDP(data, to); err != nil {
+                return nil, err
+            }
+            if interval *= 2; interval > SIP_T2 {
+                interval = SIP_T2
+            }
+            retransmit.Reset(interval)
+        case <-timeout.C:
+            return nil, errSipTimeout
+        case <-ua.closed:
+            return nil, errSipClosed
+        }
+    }
+}
+
+// out of dialog request with new Call-ID and From tag
+func (ua *sipUA) newRequest(method string, uri string, from string, to string) *SipMessage {
+    req := NewSipRequest(method, uri)
+    req.AddHeader(SipVia, ua.via())
+    req.AddHeader(SipFrom, "<"+from+">;tag="+newTag())
+    req.AddHeader(SipTo, "<"+to+">")
+    req.AddHeader(SipCallID, newCallID())
+    req.AddHeader(SipCSeq, strconv.FormatUint(uint64(ua.nextCSeq()), 10)+" "+method)
+    return req
+}
+
+func (ua *sipUA) via() string {
+    return "SIP/2.0/UDP " + ua.hostport() + ";rport;branch=" + newBranch()
+}
+
+func (ua *sipUA) hostport() string {
+    return net.JoinHostPort(ua.ip, strconv.Itoa(ua.port))
+}
+
+func (ua *sipUA) nextCSeq() uint32 {
+    return atomic.AddUint32(&ua.cseq, 1)
+}
+
+func (ua *sipUA) close() {
+    ua.closeOnce.Do(func() {
+        close(ua.closed)
+        if ua.conn != nil {
+            ua.conn.Close()
+        }
+    })
+}
@@ -25,9 +25,9 @@ const (
 func findPESIDByStreamType(cid TS_STREAM_TYPE) PES_STREMA_ID {
 
     switch cid {
-    case TS_STREAM_AAC, TS_STREAM_AUDIO_MPEG1, TS_STREAM_AUDIO_MPEG2:
+    case TS_STREAM_AAC, TS_STREAM_AAC_LATM, TS_STREAM_AUDIO_MPEG1, TS_STREAM_AUDIO_MPEG2:
         return PES_STREAM_AUDIO
-    case TS_STREAM_H264, TS_STREAM_H265:
+    case TS_STREAM_H264, TS_STREAM_H265, TS_STREAM_MPEG2_VIDEO:
         return PES_STREAM_VIDEO
     default:
         return PES_STREAM_PRIVATE
@@ -251,7 +251,9 @@ func (pkg *PesPacket) DecodeMpeg1(bs *codec.BitStream) error {
     if bs.NextBits(2) == 0x01 {
         bs.SkipBits(16)
     }
+    pkg.PTS_DTS_flags = 0
     if bs.NextBits(4) == 0x02 {
+        pkg.PTS_DTS_flags = 0x02
         bs.SkipBits(4)
         pkg.Pts = bs.GetBits(3)
         bs.SkipBits(1)
@@ -259,7 +261,9 @@ func (pkg *PesPacket) DecodeMpeg1(bs *codec.BitStream) error {
         bs.SkipBits(1)
         pkg.Pts = pkg.Pts<<15 | bs.GetBits(15)
         bs.SkipBits(1)
+        pkg.Dts = pkg.Pts
     } else if bs.NextBits(4) == 0x03 {
+        pkg.PTS_DTS_flags = 0x03
         bs.SkipBits(4)
         pkg.Pts = bs.GetBits(3)
         bs.SkipBits(1)
@@ -267,11 +271,12 @@ func (pkg *PesPacket) DecodeMpeg1(bs *codec.BitStream) error {
         bs.SkipBits(1)
         pkg.Pts = pkg.Pts<<15 | bs.GetBits(15)
         bs.SkipBits(1)
+        bs.SkipBits(4)
         pkg.Dts = bs.GetBits(3)
         bs.SkipBits(1)
-        pkg.Dts = pkg.Pts<<15 | bs.GetBits(15)
+        pkg.Dts = pkg.Dts<<15 | bs.GetBits(15)
         bs.SkipBits(1)
-        pkg.Dts = pkg.Pts<<15 | bs.GetBits(15)
+        pkg.Dts = pkg.Dts<<15 | bs.GetBits(15)
         bs.SkipBits(1)
     } else if bs.NextBits(8) == 0x0F {
         bs.SkipBits(8)
@@ -311,6 +316,7 @@ func (pkg *PesPacket) Encode(bsw *codec.BitStreamWriter) {
     bsw.PutUint8(pkg.PES_CRC_flag, 1)
     bsw.PutUint8(pkg.PES_extension_flag, 1)
     bsw.PutByte(pkg.PES_header_data_length)
+    bsw.Markdot()
     if pkg.PTS_DTS_flags == 0x02 {
         bsw.PutUint8(0x02, 4)
         bsw.PutUint64(pkg.Pts>>30, 3)
@@ -347,5 +353,38 @@ func (pkg *PesPacket) Encode(bsw *codec.BitStreamWriter) {
         bsw.PutUint64(pkg.ESCR_base, 15)
         bsw.PutUint8(0x01, 1)
     }
+    //stuffing_byte, e.g. teletext requires PES_header_data_length == 0x24
+    if stuffing := int(pkg.PES_header_data_length) - bsw.DistanceFromMarkDot()/8; stuffing > 0 {
+        bsw.PutRepetValue(0xFF, stuffing)
+    }
+    bsw.PutBytes(pkg.Pes_payload)
+}
+
+// ISO/IEC 11172-1 packet,STD buffer fields are not written
+//   PTS_DTS_flags 0x02: '0010' PTS
+//   PTS_DTS_flags 0x03: '0011' PTS '0001' DTS
+//   otherwise:          0x0F
+func (pkg *PesPacket) EncodeMpeg1(bsw *codec.BitStreamWriter) {
+    bsw.PutBytes([]byte{0x00, 0x00, 0x01})
+    bsw.PutByte(pkg.Stream_id)
+    bsw.PutUint16(pkg.PES_packet_length, 16)
+    putTimestamp := func(prefix uint8, ts uint64) {
+        bsw.PutUint8(prefix, 4)
+        bsw.PutUint64(ts>>30, 3)
+        bsw.PutUint8(0x01, 1)
+        bsw.PutUint64(ts>>15, 15)
+        bsw.PutUint8(0x01, 1)
+        bsw.PutUint64(ts, 15)
+        bsw.PutUint8(0x01, 1)
+    }
+    switch pkg.PTS_DTS_flags {
+    case 0x02:
+        putTimestamp(0x02, pkg.Pts)
+    case 0x03:
+        putTimestamp(0x03, pkg.Pts)
+        putTimestamp(0x01, pkg.Dts)
+    default:
+        bsw.PutByte(0x0F)
+    }
     bsw.PutBytes(pkg.Pes_payload)
 }
@@ -1,6 +1,9 @@
 package mpeg2
 
 import (
+	"errors"
+	"io"
+
 	"github.com/yapingcat/gomedia/go-codec"
 )
 
@@ -10,14 +13,23 @@ type psstream struct {
     pts       uint64
     dts       uint64
     streamBuf []byte
+    info      PSStreamInfo
+    probed    bool
+    hasTs     bool   //pts/dts has been received,for wraparound
+    au        []byte //nalus of the current access unit,frame mode
+    auPts     uint64
+    auDts     uint64
+    damaged   bool
 }
 
 func newpsstream(sid uint8, cid PS_STREAM_TYPE) *psstream {
-    return &psstream{
+    stream := &psstream{
         sid:       sid,
-        cid:       cid,
         streamBuf: make([]byte, 0, 4096),
     }
+    stream.info.StreamID = sid
+    stream.setCid(cid)
+    return stream
 }
 
 type PSDemuxer struct {
@@ -26,10 +38,22 @@ type PSDemuxer struct {
     mpeg1     bool
     cache     []byte
     OnFrame   func(frame []byte, cid PS_STREAM_TYPE, pts uint64, dts uint64)
+    //optional,it is called instead of OnFrame if set,
+    //one access unit per callback instead of one nalu
+    OnFrameWithInfo func(frame *PSFrame)
+    //optional,it is called when the streams are found or changed by PSM,
+    //frames are held until the resolution/sample rate of every stream is probed
+    OnStreamsChanged func(streams []PSStreamInfo)
     //解ps包过程中，解码回调psm，system header，pes包等
     //decodeResult 解码ps包时的产生的错误
     //这个回调主要用于debug，查看是否ps包存在问题
     OnPacket func(pkg Display, decodeResult error)
+    ready    bool //OnStreamsChanged has been called for current streams
+    pending  []*PSFrame
+    reader   io.Reader //pull mode
+    queue    []*PSFrame
+    eof      bool
+    err      error
 }
 
 func NewPSDemuxer() *PSDemuxer {
@@ -42,6 +66,51 @@ func NewPSDemuxer() *PSDemuxer {
     }
 }
 
+// pull mode,frames are read by ReadFrame instead of OnFrame callback
+func NewPSDemuxerWithReader(r io.Reader) *PSDemuxer {
+    demuxer := NewPSDemuxer()
+    demuxer.reader = r
+    return demuxer
+}
+
+// ReadFrame returns the next access unit of any stream,io.EOF at the end of stream,
+// the returned frame is owned by the caller
+func (psdemuxer *PSDemuxer) ReadFrame() (*PSFrame, error) {
+    if psdemuxer.reader == nil {
+        return nil, errors.New("ps demuxer is not created by NewPSDemuxerWithReader")
+    }
+    buf := make([]byte, 64*1024)
+    for len(psdemuxer.queue) == 0 {
+        if psdemuxer.eof {
+            if psdemuxer.err != nil {
+                return nil, psdemuxer.err
+            }
+            return nil, io.EOF
+        }
+        n, err := psdemuxer.reader.Read(buf)
+        if n > 0 {
+            //the broken packet is reported by OnPacket,the demuxer resyncs at the next start code
+            psdemuxer.Input(buf[:n])
+        }
+        if err != nil {
+            if !errors.Is(err, io.EOF) {
+                psdemuxer.err = err
+            }
+            psdemuxer.eof = true
+            psdemuxer.Flush()
+        }
+    }
+    frame := psdemuxer.queue[0]
+    psdemuxer.queue[0] = nil
+    psdemuxer.queue = psdemuxer.queue[1:]
+    return frame, nil
+}
+
+// frame mode delivers one access unit per frame,otherwise one nalu
+func (psdemuxer *PSDemuxer) frameMode() bool {
+    return psdemuxer.reader != nil || psdemuxer.OnFrameWithInfo != nil
+}
+
 func (psdemuxer *PSDemuxer) Input(data []byte) error {
     var bs *codec.BitStream
     if len(psdemuxer.cache) > 0 {
@@ -97,12 +166,7 @@ func (psdemuxer *PSDemuxer) Input(data []byte) error {
                 psdemuxer.pkg.Psm = new(Program_stream_map)
             }
             if ret = psdemuxer.pkg.Psm.Decode(bs); ret == nil {
-                for _, streaminfo := range psdemuxer.pkg.Psm.Stream_map {
-                    if _, found := psdemuxer.streamMap[streaminfo.Elementary_stream_id]; !found {
-                        stream := newpsstream(streaminfo.Elementary_stream_id, PS_STREAM_TYPE(streaminfo.Stream_type))
-                        psdemuxer.streamMap[stream.sid] = stream
-                    }
-                }
+                psdemuxer.updateStreams(psdemuxer.pkg.Psm)
             }
             if psdemuxer.OnPacket != nil {
                 psdemuxer.OnPacket(psdemuxer.pkg.Psm, ret)
@@ -135,20 +199,7 @@ func (psdemuxer *PSDemuxer) Input(data []byte) error {
                     psdemuxer.OnPacket(psdemuxer.pkg.Pes, ret)
                 }
                 if ret == nil {
-                    if stream, found := psdemuxer.streamMap[psdemuxer.pkg.Pes.Stream_id]; found {
-                        if psdemuxer.mpeg1 && stream.cid == PS_STREAM_UNKNOW {
-                            psdemuxer.guessCodecid(stream)
-                        }
-                        psdemuxer.demuxPespacket(stream, psdemuxer.pkg.Pes)
-                    } else {
-                        if psdemuxer.mpeg1 {
-                            stream := newpsstream(psdemuxer.pkg.Pes.Stream_id, PS_STREAM_UNKNOW)
-                            psdemuxer.streamMap[stream.sid] = stream
-                            stream.streamBuf = append(stream.streamBuf, psdemuxer.pkg.Pes.Pes_payload...)
-                            stream.pts = psdemuxer.pkg.Pes.Pts
-                            stream.dts = psdemuxer.pkg.Pes.Dts
-                        }
-                    }
+                    psdemuxer.inputPes(psdemuxer.pkg.Pes)
                 }
             } else {
                 bs.SkipBits(8)
@@ -156,27 +207,181 @@ func (psdemuxer *PSDemuxer) Input(data []byte) error {
         }
     }
 
-    if ret == nil && len(psdemuxer.cache) > 0 {
+    if mpegerr, ok := ret.(Error); !ok || !mpegerr.NeedMore() {
+        //the cached bytes have been consumed or dropped
         psdemuxer.cache = nil
     }
 
     return ret
 }
 
+// streams in PSM,the stream type of PSM is preferred to guessing
+func (psdemuxer *PSDemuxer) updateStreams(psm *Program_stream_map) {
+    for _, streaminfo := range psm.Stream_map {
+        cid := PS_STREAM_TYPE(streaminfo.Stream_type)
+        stream, found := psdemuxer.streamMap[streaminfo.Elementary_stream_id]
+        if !found {
+            stream = newpsstream(streaminfo.Elementary_stream_id, cid)
+            psdemuxer.streamMap[stream.sid] = stream
+            psdemuxer.ready = false
+        } else if stream.cid != cid {
+            stream.streamBuf = stream.streamBuf[:0]
+            stream.au = nil
+            stream.setCid(cid)
+            psdemuxer.ready = false
+        }
+        if !stream.probed {
+            stream.setDescriptors(streaminfo.Descriptors)
+        }
+    }
+}
+
+func (psdemuxer *PSDemuxer) inputPes(pes *PesPacket) {
+    stream, found := psdemuxer.streamMap[pes.Stream_id]
+    if !found {
+        //no PSM(e.g. MPEG-1),the codec is guessed from the payload of the next PES
+        stream = newpsstream(pes.Stream_id, PS_STREAM_UNKNOW)
+        psdemuxer.streamMap[stream.sid] = stream
+        psdemuxer.ready = false
+    }
+    if pes.PTS_DTS_flags&0x02 == 0 {
+        //the rest of a large frame,PES without timestamp
+        pes.Pts = stream.pts
+        pes.Dts = stream.dts
+    } else {
+        if pes.PTS_DTS_flags&0x01 == 0 {
+            pes.Dts = pes.Pts
+        }
+        if stream.hasTs {
+            pes.Pts = unwrapTimestamp(stream.dts, pes.Pts)
+            pes.Dts = unwrapTimestamp(stream.dts, pes.Dts)
+        }
+        stream.hasTs = true
+    }
+    if !found {
+        stream.streamBuf = append(stream.streamBuf, pes.Pes_payload...)
+        stream.pts = pes.Pts
+        stream.dts = pes.Dts
+        return
+    }
+    if stream.cid == PS_STREAM_UNKNOW {
+        psdemuxer.guessCodecid(stream)
+    }
+    psdemuxer.demuxPespacket(stream, pes)
+}
+
+// deliver the buffered frames,called at the end of stream or at the end of a complete frame(e.g. RTP marker)
 func (psdemuxer *PSDemuxer) Flush() {
     for _, stream := range psdemuxer.streamMap {
-        if len(stream.streamBuf) == 0 {
+        if stream.cid == PS_STREAM_H264 || stream.cid == PS_STREAM_H265 {
+            psdemuxer.splitNalus(stream, true)
+            psdemuxer.flushAU(stream)
+        } else if len(stream.streamBuf) > 0 {
+            psdemuxer.emit(stream, stream.streamBuf, stream.pts, stream.dts)
+            stream.streamBuf = stream.streamBuf[:0]
+        }
+        stream.damaged = false
+    }
+    if !psdemuxer.ready && len(psdemuxer.pending) > 0 {
+        psdemuxer.notifyStreamsChanged()
+    }
+}
+
+// called when the input is discontinuous(e.g. RTP packets are lost),
+// the incomplete packet is dropped and the buffered frames are delivered,
+// only the frame continued by the incomplete packet is damaged,
+// the demuxer resyncs at the next start code of the input
+func (psdemuxer *PSDemuxer) Discontinuity() {
+    sid, continued := psdemuxer.incompletePes()
+    for _, stream := range psdemuxer.streamMap {
+        stream.damaged = continued && stream.sid == sid && (len(stream.streamBuf) > 0 || len(stream.au) > 0)
+    }
+    psdemuxer.Flush()
+    psdemuxer.cache = nil
+}
+
+// the stream id of the incomplete PES in cache,
+// continued is true if the PES has no PTS,that is the rest of the buffered frame,
+// the PES whose header is truncated is treated as continued
+func (psdemuxer *PSDemuxer) incompletePes() (sid uint8, continued bool) {
+    data := psdemuxer.cache
+    for i := 0; i+3 < len(data); i++ {
+        if data[i] != 0x00 || data[i+1] != 0x00 || data[i+2] != 0x01 || data[i+3]&0xE0 != 0xC0 && data[i+3]&0xF0 != 0xE0 {
             continue
         }
-        if psdemuxer.OnFrame != nil {
-            psdemuxer.OnFrame(stream.streamBuf, stream.cid, stream.pts/90, stream.dts/90)
+        sid = data[i+3]
+        if !psdemuxer.mpeg1 {
+            if i+7 >= len(data) {
+                return sid, true
+            }
+            return sid, data[i+7]&0x80 == 0
+        }
+        //mpeg1:stuffing bytes,STD buffer,then PTS
+        j := i + 6
+        for j < len(data) && data[j] == 0xFF {
+            j++
+        }
+        if j < len(data) && data[j]&0xC0 == 0x40 {
+            j += 2
+        }
+        if j >= len(data) {
+            return sid, true
         }
+        return sid, data[j]&0xE0 != 0x20
+    }
+    return 0, false
+}
+
+func (psdemuxer *PSDemuxer) emit(stream *psstream, data []byte, pts uint64, dts uint64) {
+    frame := &PSFrame{
+        StreamID: stream.sid,
+        Cid:      stream.cid,
+        Codec:    stream.info.Codec,
+        Data:     data,
+        Pts:      pts / 90,
+        Dts:      dts / 90,
+        KeyFrame: isPSKeyFrame(stream.cid, data),
+        Damaged:  stream.damaged,
+    }
+    held := psdemuxer.OnStreamsChanged != nil && !psdemuxer.ready
+    if held || psdemuxer.reader != nil {
+        //the frame is delivered after the buffer is reused
+        frame.Data = append([]byte{}, data...)
+    }
+    if held {
+        stream.probe(data)
+        psdemuxer.pending = append(psdemuxer.pending, frame)
+        if psdemuxer.allProbed() || len(psdemuxer.pending) >= maxProbePackets || frame.Dts > psdemuxer.pending[0].Dts+maxProbeDuration {
+            psdemuxer.notifyStreamsChanged()
+        }
+        return
+    }
+    psdemuxer.dispatch(frame)
+}
+
+func (psdemuxer *PSDemuxer) notifyStreamsChanged() {
+    psdemuxer.ready = true
+    psdemuxer.OnStreamsChanged(psdemuxer.StreamInfo())
+    pending := psdemuxer.pending
+    psdemuxer.pending = nil
+    for _, frame := range pending {
+        psdemuxer.dispatch(frame)
+    }
+}
+
+func (psdemuxer *PSDemuxer) dispatch(frame *PSFrame) {
+    if psdemuxer.reader != nil {
+        psdemuxer.queue = append(psdemuxer.queue, frame)
+    } else if psdemuxer.OnFrameWithInfo != nil {
+        psdemuxer.OnFrameWithInfo(frame)
+    } else if psdemuxer.OnFrame != nil {
+        psdemuxer.OnFrame(frame.Data, frame.Cid, frame.Pts, frame.Dts)
     }
 }
 
 func (psdemuxer *PSDemuxer) guessCodecid(stream *psstream) {
     if stream.sid&0xE0 == uint8(PES_STREAM_AUDIO) {
-        stream.cid = PS_STREAM_AAC
+        stream.setCid(PS_STREAM_AAC)
     } else if stream.sid&0xE0 == uint8(PES_STREAM_VIDEO) {
         h264score := 0
         h265score := 0
@@ -204,9 +409,9 @@ func (psdemuxer *PSDemuxer) guessCodecid(stream *psstream) {
                 h265score -= 1
             }
             if h264score > h265score && h264score >= 4 {
-                stream.cid = PS_STREAM_H264
+                stream.setCid(PS_STREAM_H264)
             } else if h264score < h265score && h265score >= 4 {
-                stream.cid = PS_STREAM_H265
+                stream.setCid(PS_STREAM_H265)
             }
             return true
         })
@@ -215,7 +420,8 @@ func (psdemuxer *PSDemuxer) guessCodecid(stream *psstream) {
 
 func (psdemuxer *PSDemuxer) demuxPespacket(stream *psstream, pes *PesPacket) error {
     switch stream.cid {
-    case PS_STREAM_AAC, PS_STREAM_G711A, PS_STREAM_G711U:
+    case PS_STREAM_AAC, PS_STREAM_G711A, PS_STREAM_G711U, PS_STREAM_SVAC_AUDIO, PS_STREAM_SVAC_VIDEO:
+        //one frame per timestamp
         return psdemuxer.demuxAudio(stream, pes)
     case PS_STREAM_H264, PS_STREAM_H265:
         return psdemuxer.demuxH26x(stream, pes)
@@ -232,9 +438,7 @@ func (psdemuxer *PSDemuxer) demuxPespacket(stream *psstream, pes *PesPacket) err
 
 func (psdemuxer *PSDemuxer) demuxAudio(stream *psstream, pes *PesPacket) error {
     if stream.pts != pes.Pts && len(stream.streamBuf) > 0 {
-        if psdemuxer.OnFrame != nil {
-            psdemuxer.OnFrame(stream.streamBuf, stream.cid, stream.pts/90, stream.dts/90)
-        }
+        psdemuxer.emit(stream, stream.streamBuf, stream.pts, stream.dts)
         stream.streamBuf = stream.streamBuf[:0]
     }
     stream.streamBuf = append(stream.streamBuf, pes.Pes_payload...)
@@ -247,34 +451,72 @@ func (psdemuxer *PSDemuxer) demuxH26x(stream *psstream, pes *PesPacket) error {
     if len(stream.streamBuf) == 0 {
         stream.pts = pes.Pts
         stream.dts = pes.Dts
+    } else if stream.pts != pes.Pts || stream.dts != pes.Dts {
+        //PES of the next frame begins with a start code,the buffered nalus are complete
+        psdemuxer.splitNalus(stream, true)
+        psdemuxer.flushAU(stream)
+        stream.pts = pes.Pts
+        stream.dts = pes.Dts
     }
     stream.streamBuf = append(stream.streamBuf, pes.Pes_payload...)
+    psdemuxer.splitNalus(stream, false)
+    return nil
+}
+
+// deliver the complete nalus of streamBuf,
+// the last nalu is complete only if final is true
+func (psdemuxer *PSDemuxer) splitNalus(stream *psstream, final bool) {
     start, sc := codec.FindStartCode(stream.streamBuf, 0)
+    if start < 0 {
+        if final {
+            stream.streamBuf = stream.streamBuf[:0]
+        }
+        return
+    }
     for start >= 0 {
         end, sc2 := codec.FindStartCode(stream.streamBuf, start+int(sc))
         if end < 0 {
-            break
-        }
-        if stream.cid == PS_STREAM_H264 {
-            naluType := codec.H264NaluType(stream.streamBuf[start:])
-            if naluType != codec.H264_NAL_AUD {
-                if psdemuxer.OnFrame != nil {
-                    psdemuxer.OnFrame(stream.streamBuf[start:end], stream.cid, stream.pts/90, stream.dts/90)
-                }
-            }
-        } else if stream.cid == PS_STREAM_H265 {
-            naluType := codec.H265NaluType(stream.streamBuf[start:])
-            if naluType != codec.H265_NAL_AUD {
-                if psdemuxer.OnFrame != nil {
-                    psdemuxer.OnFrame(stream.streamBuf[start:end], stream.cid, stream.pts/90, stream.dts/90)
-                }
+            if !final {
+                break
             }
+            end = len(stream.streamBuf)
+        }
+        psdemuxer.onNalu(stream, stream.streamBuf[start:end])
+        if end == len(stream.streamBuf) {
+            start = end
+            break
         }
         start = end
         sc = sc2
     }
     stream.streamBuf = stream.streamBuf[start:]
-    stream.pts = pes.Pts
-    stream.dts = pes.Dts
-    return nil
+}
+
+func (psdemuxer *PSDemuxer) onNalu(stream *psstream, nalu []byte) {
+    if stream.cid == PS_STREAM_H264 && codec.H264NaluType(nalu) == codec.H264_NAL_AUD {
+        return
+    } else if stream.cid == PS_STREAM_H265 && codec.H265NaluType(nalu) == codec.H265_NAL_AUD {
+        return
+    }
+    if !psdemuxer.frameMode() {
+        psdemuxer.emit(stream, nalu, stream.pts, stream.dts)
+        return
+    }
+    if len(stream.au) > 0 && (stream.auPts != stream.pts || stream.auDts != stream.dts) {
+        psdemuxer.flushAU(stream)
+    }
+    if len(stream.au) == 0 {
+        stream.auPts = stream.pts
+        stream.auDts = stream.dts
+    }
+    stream.au = append(stream.au, nalu...)
+}
+
+func (psdemuxer *PSDemuxer) flushAU(stream *psstream) {
+    if len(stream.au) == 0 {
+        return
+    }
+    au := stream.au
+    stream.au = nil
+    psdemuxer.emit(stream, au, stream.auPts, stream.auDts)
 }
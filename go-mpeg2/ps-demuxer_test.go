@@ -1,6 +1,8 @@
 package mpeg2
 
 import (
+	"bytes"
+	"io"
 	"testing"
 )
 
@@ -78,3 +80,174 @@ func TestPSDemuxer_Input(t *testing.T) {
 		})
 	}
 }
+
+var testSps = []byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0xC0, 0x1F, 0xDA, 0x01, 0x40, 0x16, 0xE8, 0x06, 0xD0, 0xA1, 0x35}
+
+// AAC LC 44100Hz stereo
+func makeTestAdtsFrame(payload []byte) []byte {
+	l := 7 + len(payload)
+	frame := []byte{0xFF, 0xF1, 0x50, 0x80 | uint8(l>>11)&0x03, uint8(l >> 3), uint8(l&0x07)<<5 | 0x1F, 0xFC}
+	return append(frame, payload...)
+}
+
+func TestPSDemuxer_ReadFrame(t *testing.T) {
+	//timestamps wrap around 2^33 in the middle of the stream
+	base := uint64(1)<<33/90 - 1000
+	var input []*PSFrame
+	for i := 0; i < 50; i++ {
+		var video []byte
+		if i%25 == 0 {
+			video = append(append([]byte{}, testSps...), makeTestH264Frame(true, 80000)...)
+		} else {
+			video = makeTestH264Frame(false, 2000)
+		}
+		input = append(input, &PSFrame{StreamID: 0xE0, Cid: PS_STREAM_H264, Data: video, Pts: base + uint64(i*40), KeyFrame: i%25 == 0})
+		input = append(input, &PSFrame{StreamID: 0xC0, Cid: PS_STREAM_AAC, Data: makeTestAdtsFrame(bytes.Repeat([]byte{uint8(i)}, 200)), Pts: base + uint64(i*40+20), KeyFrame: true})
+	}
+	muxer := NewPsMuxer()
+	muxer.AddStreamWithDescriptor(PS_STREAM_H264, GBVideoDescriptor(0, 0, 25))
+	muxer.AddStream(PS_STREAM_AAC)
+	var ps bytes.Buffer
+	muxer.OnPacket = func(pkg []byte) {
+		ps.Write(pkg)
+	}
+	for _, f := range input {
+		if err := muxer.Write(f.StreamID, f.Data, f.Pts, f.Pts); err != nil {
+			t.Fatalf("PSMuxer.Write() error = %v", err)
+		}
+	}
+
+	demuxer := NewPSDemuxerWithReader(&ps)
+	var streams []PSStreamInfo
+	demuxer.OnStreamsChanged = func(infos []PSStreamInfo) {
+		streams = infos
+	}
+	var output []*PSFrame
+	for {
+		frame, err := demuxer.ReadFrame()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("PSDemuxer.ReadFrame() error = %v", err)
+		}
+		if streams == nil {
+			t.Fatalf("frame is read before OnStreamsChanged")
+		}
+		output = append(output, frame)
+	}
+	if len(streams) != 2 {
+		t.Fatalf("OnStreamsChanged() got %+v", streams)
+	}
+	if audio := streams[0]; audio.StreamID != 0xC0 || audio.SampleRate != 44100 || audio.Channels != 2 {
+		t.Errorf("audio stream = %+v", audio)
+	}
+	if video := streams[1]; video.StreamID != 0xE0 || video.Width != 1280 || video.Height != 720 || video.FrameRate != 25 {
+		t.Errorf("video stream = %+v", video)
+	}
+	if len(output) != len(input) {
+		t.Fatalf("PSDemuxer.ReadFrame() got %d frames, want %d", len(output), len(input))
+	}
+	for _, want := range input {
+		found := false
+		for _, got := range output {
+			if got.StreamID == want.StreamID && got.Pts == want.Pts {
+				found = true
+				if got.Cid != want.Cid || got.Dts != want.Pts || got.KeyFrame != want.KeyFrame || got.Damaged || !bytes.Equal(got.Data, want.Data) {
+					t.Fatalf("frame of stream %X pts %d = %+v", want.StreamID, want.Pts, got)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("frame of stream %X pts %d is not found", want.StreamID, want.Pts)
+		}
+	}
+}
+
+func TestPSDemuxer_Discontinuity(t *testing.T) {
+	muxer := NewPsMuxer()
+	sid := muxer.AddStream(PS_STREAM_H264)
+	var packets [][]byte
+	muxer.OnPacket = func(pkg []byte) {
+		packets = append(packets, append([]byte{}, pkg...))
+	}
+	for i := 0; i < 3; i++ {
+		muxer.Write(sid, makeTestH264Frame(i == 0, 1000), uint64(i*40), uint64(i*40))
+	}
+
+	var frames []*PSFrame
+	demuxer := NewPSDemuxer()
+	demuxer.OnFrameWithInfo = func(frame *PSFrame) {
+		frames = append(frames, frame)
+	}
+	demuxer.Input(packets[0])
+	//the frame is delivered without waiting for the next frame
+	demuxer.Flush()
+	if len(frames) != 1 || frames[0].Damaged || !frames[0].KeyFrame || frames[0].Pts != 0 {
+		t.Fatalf("PSDemuxer.Flush() got %+v", frames)
+	}
+	demuxer.Input(packets[1][:len(packets[1])/2])
+	demuxer.Discontinuity()
+	if len(frames) != 1 {
+		t.Fatalf("incomplete PES is delivered")
+	}
+	demuxer.Input(packets[2])
+	demuxer.Flush()
+	if len(frames) != 2 || frames[1].Pts != 80 || !bytes.Equal(frames[1].Data, makeTestH264Frame(false, 1000)) {
+		t.Fatalf("PSDemuxer got %+v after discontinuity", frames[1:])
+	}
+}
+
+func TestPSDemuxer_DiscontinuityDamaged(t *testing.T) {
+	muxer := NewPsMuxer()
+	sid := muxer.AddStream(PS_STREAM_H264)
+	var packets [][]byte
+	muxer.OnPacket = func(pkg []byte) {
+		packets = append(packets, append([]byte{}, pkg...))
+	}
+	//the second frame is split into two PES,only the first one carries PTS
+	muxer.Write(sid, makeTestH264Frame(true, 1000), 0, 0)
+	muxer.Write(sid, makeTestH264Frame(false, 100000), 40, 40)
+	muxer.Write(sid, makeTestH264Frame(false, 1000), 80, 80)
+	if len(packets) != 4 {
+		t.Fatalf("PSMuxer.Write() got %d packets, want 4", len(packets))
+	}
+
+	var frames []*PSFrame
+	demuxer := NewPSDemuxer()
+	demuxer.OnFrameWithInfo = func(frame *PSFrame) {
+		frames = append(frames, frame)
+	}
+	//the next frame is lost,the buffered frame is complete
+	demuxer.Input(packets[0])
+	demuxer.Input(packets[1][:100])
+	demuxer.Discontinuity()
+	if len(frames) != 1 || frames[0].Pts != 0 || frames[0].Damaged {
+		t.Fatalf("complete frame is delivered as %+v", frames)
+	}
+
+	//the second PES of the frame is lost
+	demuxer.Input(packets[1])
+	demuxer.Input(packets[2][:100])
+	demuxer.Discontinuity()
+	if len(frames) != 2 || frames[1].Pts != 40 || !frames[1].Damaged {
+		t.Fatalf("incomplete frame is delivered as %+v", frames[1:])
+	}
+	demuxer.Input(packets[3])
+	demuxer.Flush()
+	if len(frames) != 3 || frames[2].Pts != 80 || frames[2].Damaged {
+		t.Fatalf("PSDemuxer got %+v after discontinuity", frames[2:])
+	}
+}
+
+func TestPSStream_ProbeMalformed(t *testing.T) {
+	stream := newpsstream(0xE0, PS_STREAM_H264)
+	stream.probe([]byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x64, 0x00, 0x28, 0xAC})
+	if stream.probed {
+		t.Error("stream probed by a truncated sps")
+	}
+	stream = newpsstream(0xC0, PS_STREAM_AAC)
+	stream.probe([]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if stream.info.SampleRate != 0 {
+		t.Errorf("sample rate %d probed from a frame without adts syncword", stream.info.SampleRate)
+	}
+}
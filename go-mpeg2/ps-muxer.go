@@ -2,47 +2,129 @@ package mpeg2
 
 import "github.com/yapingcat/gomedia/go-codec"
 
+const (
+    DEFAULT_PS_RATE_BOUND = 26234 //50 bytes/s,about 10Mbps
+    DEFAULT_PS_SCR_DELAY  = 3600  //90kHz,SCR is earlier than DTS by 40ms
+    psRateWindow          = 90000 //90kHz,the mux rate is measured in the last second
+    psMinPackSize         = 64
+)
+
+type psPackSize struct {
+    dts  uint64
+    size int
+}
+
+// PSMuxer writes one frame into one pack by default,
+// the frame is split into PES packets of up to 65535 bytes and only the first one carries PTS/DTS.
+//
+// the pack header carries SCR = DTS - scrDelay and program_mux_rate measured from the frames of the last second,
+// the system header and PSM are written before the first frame,every key frame and the frame after AddStream,
+// SetPackSize limits the size of each pack for the decoders with small buffer,
+// SetMpeg1 writes ISO/IEC 11172-1 pack and packet(without PSM)
 type PSMuxer struct {
-    system     *System_header
-    psm        *Program_stream_map
-    OnPacket   func(pkg []byte)
-    firstframe bool
+    system       *System_header
+    psm          *Program_stream_map
+    OnPacket     func(pkg []byte)
+    firstframe   bool
+    mpeg1        bool
+    packSize     int
+    scrDelay     uint64
+    lastScr      uint64
+    hasScr       bool
+    history      []psPackSize
+    historyBytes int
 }
 
 func NewPsMuxer() *PSMuxer {
     muxer := new(PSMuxer)
     muxer.firstframe = true
     muxer.system = new(System_header)
-    muxer.system.Rate_bound = 26234
+    muxer.system.Rate_bound = DEFAULT_PS_RATE_BOUND
     muxer.psm = new(Program_stream_map)
     muxer.psm.Current_next_indicator = 1
     muxer.psm.Program_stream_map_version = 1
+    muxer.scrDelay = DEFAULT_PS_SCR_DELAY
     muxer.OnPacket = nil
     return muxer
 }
 
-func (muxer *PSMuxer) AddStream(cid PS_STREAM_TYPE) uint8 {
-    if cid == PS_STREAM_H265 || cid == PS_STREAM_H264 {
-        es := NewElementary_Stream(uint8(PES_STREAM_VIDEO) + muxer.system.Video_bound)
+// MPEG-1 system stream,must be set before the first frame
+func (muxer *PSMuxer) SetMpeg1(mpeg1 bool) {
+    muxer.mpeg1 = mpeg1
+}
+
+// the maximum bytes of one pack(pack header + system header + PSM + PES),
+// zero means one pack per frame
+func (muxer *PSMuxer) SetPackSize(size int) {
+    if size > 0 && size < psMinPackSize {
+        size = psMinPackSize
+    }
+    muxer.packSize = size
+}
+
+// rate_bound of system header,50 bytes/second,
+// program_mux_rate never exceeds it
+func (muxer *PSMuxer) SetRateBound(rate uint32) {
+    muxer.system.Rate_bound = rate & 0x3FFFFF
+}
+
+// delay between SCR and DTS,90kHz
+func (muxer *PSMuxer) SetScrDelay(delay uint64) {
+    muxer.scrDelay = delay
+}
+
+// P-STD_buffer_size_bound of the stream in system header
+func (muxer *PSMuxer) SetStdBufferSize(sid uint8, size int) error {
+    for _, es := range muxer.system.Streams {
+        if es.Stream_id == sid {
+            setStdBufferSize(es, size)
+            return nil
+        }
+    }
+    return errNotFound
+}
+
+// the unit is 128 bytes if scale is 0,otherwise 1024 bytes
+func setStdBufferSize(es *Elementary_Stream, size int) {
+    if size <= 0x1FFF*128 {
+        es.P_STD_buffer_bound_scale = 0
+        es.P_STD_buffer_size_bound = uint16((size + 127) / 128)
+    } else {
+        if size > 0x1FFF*1024 {
+            size = 0x1FFF * 1024
+        }
         es.P_STD_buffer_bound_scale = 1
-        es.P_STD_buffer_size_bound = 400
-        muxer.system.Streams = append(muxer.system.Streams, es)
+        es.P_STD_buffer_size_bound = uint16((size + 1023) / 1024)
+    }
+}
+
+func (muxer *PSMuxer) AddStream(cid PS_STREAM_TYPE) uint8 {
+    return muxer.AddStreamWithDescriptor(cid)
+}
+
+// descriptors are written into the elementary stream info of PSM,e.g. GBVideoDescriptor
+func (muxer *PSMuxer) AddStreamWithDescriptor(cid PS_STREAM_TYPE, descs ...Descriptor) uint8 {
+    var es *Elementary_Stream
+    if isPSVideoStream(cid) {
+        es = NewElementary_Stream(uint8(PES_STREAM_VIDEO) + muxer.system.Video_bound)
+        setStdBufferSize(es, 400*1024)
         muxer.system.Video_bound++
-        muxer.psm.Stream_map = append(muxer.psm.Stream_map, NewElementary_stream_elem(uint8(cid), es.Stream_id))
-        muxer.psm.Program_stream_map_version++
-        return es.Stream_id
     } else {
-        es := NewElementary_Stream(uint8(PES_STREAM_AUDIO) + muxer.system.Audio_bound)
-        es.P_STD_buffer_bound_scale = 0
-        es.P_STD_buffer_size_bound = 32
-        muxer.system.Streams = append(muxer.system.Streams, es)
+        es = NewElementary_Stream(uint8(PES_STREAM_AUDIO) + muxer.system.Audio_bound)
+        setStdBufferSize(es, 4*1024)
         muxer.system.Audio_bound++
-        muxer.psm.Stream_map = append(muxer.psm.Stream_map, NewElementary_stream_elem(uint8(cid), es.Stream_id))
-        muxer.psm.Program_stream_map_version++
-        return es.Stream_id
     }
+    muxer.system.Streams = append(muxer.system.Streams, es)
+    elem := NewElementary_stream_elem(uint8(cid), es.Stream_id)
+    elem.Descriptors = descs
+    muxer.psm.Stream_map = append(muxer.psm.Stream_map, elem)
+    muxer.psm.Program_stream_map_version++
+    //the new stream map is announced with the next frame
+    muxer.firstframe = true
+    return es.Stream_id
 }
 
+// pts/dts: millisecond
 func (muxer *PSMuxer) Write(sid uint8, frame []byte, pts uint64, dts uint64) error {
     var stream *Elementary_stream_elem = nil
     for _, es := range muxer.psm.Stream_map {
@@ -59,7 +141,6 @@ func (muxer *PSMuxer) Write(sid uint8, frame []byte, pts uint64, dts uint64) err
     }
     var withaud bool = false
     var idr_flag bool = false
-    var first bool = true
     var vcl bool = false
     if stream.Stream_type == uint8(PS_STREAM_H264) || stream.Stream_type == uint8(PS_STREAM_H265) {
         codec.SplitFrame(frame, func(nalu []byte) bool {
@@ -95,53 +176,148 @@ func (muxer *PSMuxer) Write(sid uint8, frame []byte, pts uint64, dts uint64) err
 
     dts = dts * 90
     pts = pts * 90
-    bsw := codec.NewBitStreamWriter(1024)
-    var pack PSPackHeader
-    pack.System_clock_reference_base = dts - 3600
-    pack.System_clock_reference_extension = 0
-    pack.Program_mux_rate = 6106
-    pack.Encode(bsw)
-    if muxer.firstframe || idr_flag {
-        muxer.system.Encode(bsw)
-        muxer.psm.Encode(bsw)
-        muxer.firstframe = false
-    }
-    pespkg := NewPesPacket()
-    for len(frame) > 0 {
-        peshdrlen := 13
-        pespkg.Stream_id = sid
-        pespkg.PTS_DTS_flags = 0x03
-        pespkg.PES_header_data_length = 10
-        pespkg.Pts = pts
-        pespkg.Dts = dts
-        if idr_flag {
-            pespkg.Data_alignment_indicator = 1
+    var aud []byte
+    if !withaud && vcl {
+        if stream.Stream_type == uint8(PS_STREAM_H264) {
+            aud = H264_AUD_NALU
+        } else {
+            aud = H265_AUD_NALU
         }
-        if first && !withaud && vcl {
-            if stream.Stream_type == uint8(PS_STREAM_H264) {
-                pespkg.Pes_payload = append(pespkg.Pes_payload, H264_AUD_NALU...)
-                peshdrlen += 6
-            } else if stream.Stream_type == uint8(PS_STREAM_H265) {
-                pespkg.Pes_payload = append(pespkg.Pes_payload, H265_AUD_NALU...)
-                peshdrlen += 7
+    }
+    muxRate := muxer.updateMuxRate(dts, len(aud)+len(frame))
+    scr := muxer.nextScr(dts)
+    writeHeaders := muxer.firstframe || idr_flag
+    muxer.firstframe = false
+
+    bsw := codec.NewBitStreamWriter(1024)
+    first := true
+    for first || len(frame) > 0 {
+        packLen := 0
+        if first || muxer.packSize > 0 {
+            var pack PSPackHeader
+            pack.IsMpeg1 = muxer.mpeg1
+            pack.System_clock_reference_base = scr
+            pack.System_clock_reference_extension = 0
+            pack.Program_mux_rate = muxRate
+            pack.Encode(bsw)
+            if first && writeHeaders {
+                muxer.system.Encode(bsw)
+                if !muxer.mpeg1 {
+                    muxer.psm.Encode(bsw)
+                }
             }
+            packLen = len(bsw.Bits())
         }
-        if peshdrlen+len(frame) >= 0xFFFF {
-            pespkg.PES_packet_length = 0xFFFF
-            pespkg.Pes_payload = append(pespkg.Pes_payload, frame[0:0xFFFF-peshdrlen]...)
-            frame = frame[0xFFFF-peshdrlen:]
-        } else {
-            pespkg.PES_packet_length = uint16(peshdrlen + len(frame))
-            pespkg.Pes_payload = append(pespkg.Pes_payload, frame[0:]...)
-            frame = frame[:0]
-        }
-        pespkg.Encode(bsw)
-        pespkg.Pes_payload = pespkg.Pes_payload[:0]
+        n := muxer.writePes(bsw, sid, aud, frame, pts, dts, first, idr_flag, packLen)
+        frame = frame[n:]
+        aud = nil
+        first = false
         if muxer.OnPacket != nil {
             muxer.OnPacket(bsw.Bits())
         }
+        if muxer.packSize > 0 && muxRate > 0 {
+            //the pack is delivered at mux rate
+            scr += uint64(len(bsw.Bits())) * 90000 / (uint64(muxRate) * 50)
+            if scr > dts {
+                scr = dts
+            }
+        }
         bsw.Reset()
-        first = false
     }
+    muxer.lastScr = scr
+    muxer.hasScr = true
     return nil
 }
+
+// write one PES packet with prefix and the front of data,return the bytes of data written
+func (muxer *PSMuxer) writePes(bsw *codec.BitStreamWriter, sid uint8, prefix []byte, data []byte, pts uint64, dts uint64, first bool, key bool, packLen int) int {
+    pespkg := NewPesPacket()
+    pespkg.Stream_id = sid
+    hdrlen := 0 //bytes after PES_packet_length
+    if muxer.mpeg1 {
+        hdrlen = 1
+        if first {
+            hdrlen = 5
+            pespkg.PTS_DTS_flags = 0x02
+            if pts != dts {
+                hdrlen = 10
+                pespkg.PTS_DTS_flags = 0x03
+            }
+        }
+    } else {
+        hdrlen = 3
+        if first {
+            pespkg.Data_alignment_indicator = 1
+            pespkg.PES_header_data_length = 5
+            pespkg.PTS_DTS_flags = 0x02
+            if pts != dts {
+                pespkg.PES_header_data_length = 10
+                pespkg.PTS_DTS_flags = 0x03
+            }
+            hdrlen += int(pespkg.PES_header_data_length)
+        }
+        if key {
+            pespkg.PES_priority = 1
+        }
+    }
+    pespkg.Pts = pts
+    pespkg.Dts = dts
+
+    room := 0xFFFF - hdrlen
+    if muxer.packSize > 0 {
+        if limit := muxer.packSize - packLen - 6 - hdrlen; limit < room {
+            room = limit
+        }
+        if room < len(prefix)+1 {
+            room = len(prefix) + 1
+        }
+    }
+    n := room - len(prefix)
+    if n > len(data) {
+        n = len(data)
+    }
+    pespkg.PES_packet_length = uint16(hdrlen + len(prefix) + n)
+    pespkg.Pes_payload = append(append(make([]byte, 0, len(prefix)+n), prefix...), data[:n]...)
+    if muxer.mpeg1 {
+        pespkg.EncodeMpeg1(bsw)
+    } else {
+        pespkg.Encode(bsw)
+    }
+    return n
+}
+
+// SCR is monotonic and earlier than DTS,
+// a big step back of DTS means the source is restarted
+func (muxer *PSMuxer) nextScr(dts uint64) uint64 {
+    var scr uint64
+    if dts > muxer.scrDelay {
+        scr = dts - muxer.scrDelay
+    }
+    if muxer.hasScr && scr < muxer.lastScr && muxer.lastScr-scr < psRateWindow {
+        scr = muxer.lastScr
+    }
+    return scr
+}
+
+// program_mux_rate in 50 bytes/s,the bytes of frames in the last second
+func (muxer *PSMuxer) updateMuxRate(dts uint64, size int) uint32 {
+    if len(muxer.history) > 0 && dts < muxer.history[len(muxer.history)-1].dts {
+        muxer.history = muxer.history[:0]
+        muxer.historyBytes = 0
+    }
+    muxer.history = append(muxer.history, psPackSize{dts: dts, size: size})
+    muxer.historyBytes += size
+    for len(muxer.history) > 1 && dts-muxer.history[0].dts > psRateWindow {
+        muxer.historyBytes -= muxer.history[0].size
+        muxer.history = muxer.history[1:]
+    }
+    span := dts - muxer.history[0].dts
+    if span == 0 {
+        return muxer.system.Rate_bound
+    }
+    rate := uint64(muxer.historyBytes)*90000/span/50 + 1
+    if rate > uint64(muxer.system.Rate_bound) {
+        rate = uint64(muxer.system.Rate_bound)
+    }
+    return uint32(rate)
+}
@@ -0,0 +1,132 @@
+package mpeg2
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yapingcat/gomedia/go-codec"
+)
+
+func makeTestH264Frame(idr bool, size int) []byte {
+	var frame []byte
+	if idr {
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x1E)
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x3C, 0x80)
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x65, 0x88)
+	} else {
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x41, 0x9A)
+	}
+	return append(frame, bytes.Repeat([]byte{0x11}, size)...)
+}
+
+func TestPSMuxer_Write(t *testing.T) {
+	tests := []struct {
+		name     string
+		mpeg1    bool
+		packSize int
+		audio    PS_STREAM_TYPE
+	}{
+		{name: "default", audio: PS_STREAM_AAC},
+		{name: "pack size", packSize: 1400, audio: PS_STREAM_G711A},
+		{name: "mpeg1", mpeg1: true, audio: PS_STREAM_AAC},
+		{name: "mpeg1 pack size", mpeg1: true, packSize: 4096, audio: PS_STREAM_AAC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			type frame struct {
+				cid  PS_STREAM_TYPE
+				data []byte
+				pts  uint64
+			}
+			var input []frame
+			for i := 0; i < 50; i++ {
+				size := 3000
+				if i%25 == 0 {
+					size = 150000 //larger than one PES
+				}
+				input = append(input, frame{cid: PS_STREAM_H264, data: makeTestH264Frame(i%25 == 0, size), pts: uint64(i * 40)})
+				input = append(input, frame{cid: tt.audio, data: bytes.Repeat([]byte{uint8(i + 1)}, 320), pts: uint64(i*40 + 20)})
+			}
+
+			muxer := NewPsMuxer()
+			muxer.SetMpeg1(tt.mpeg1)
+			muxer.SetPackSize(tt.packSize)
+			vid := muxer.AddStreamWithDescriptor(PS_STREAM_H264, GBVideoDescriptor(1920, 1080, 25))
+			aid := muxer.AddStream(tt.audio)
+
+			var ps []byte
+			var lastScr uint64
+			muxer.OnPacket = func(pkg []byte) {
+				ps = append(ps, pkg...)
+				if !bytes.HasPrefix(pkg, []byte{0x00, 0x00, 0x01, 0xBA}) {
+					if tt.packSize > 0 {
+						t.Fatalf("PSMuxer.OnPacket() pack without pack header")
+					}
+					return
+				}
+				if tt.packSize > 0 && len(pkg) > tt.packSize {
+					t.Fatalf("PSMuxer.OnPacket() pack size %d > %d", len(pkg), tt.packSize)
+				}
+				var pack PSPackHeader
+				if err := pack.Decode(codec.NewBitStream(pkg)); err != nil {
+					t.Fatalf("PSPackHeader.Decode() error = %v", err)
+				}
+				if pack.IsMpeg1 != tt.mpeg1 || pack.Program_mux_rate == 0 || pack.Program_mux_rate > DEFAULT_PS_RATE_BOUND {
+					t.Fatalf("PSPackHeader = %+v", pack)
+				}
+				if pack.System_clock_reference_base < lastScr {
+					t.Fatalf("SCR %d goes back from %d", pack.System_clock_reference_base, lastScr)
+				}
+				lastScr = pack.System_clock_reference_base
+			}
+			for _, f := range input {
+				sid := vid
+				if f.cid != PS_STREAM_H264 {
+					sid = aid
+				}
+				if err := muxer.Write(sid, f.data, f.pts, f.pts); err != nil {
+					t.Fatalf("PSMuxer.Write() error = %v", err)
+				}
+			}
+
+			type frameKey struct {
+				cid PS_STREAM_TYPE
+				pts uint64
+			}
+			output := make(map[frameKey][]byte)
+			demuxer := NewPSDemuxer()
+			demuxer.OnFrame = func(data []byte, cid PS_STREAM_TYPE, pts uint64, dts uint64) {
+				//video frame is delivered nalu by nalu
+				key := frameKey{cid: cid, pts: pts}
+				output[key] = append(output[key], data...)
+			}
+			var psm *Program_stream_map
+			demuxer.OnPacket = func(pkg Display, decodeResult error) {
+				if m, ok := pkg.(*Program_stream_map); ok && decodeResult == nil && psm == nil {
+					psm = m
+				}
+			}
+			if err := demuxer.Input(ps); err != nil {
+				t.Fatalf("PSDemuxer.Input() error = %v", err)
+			}
+			demuxer.Flush()
+
+			if len(output) != len(input) {
+				t.Fatalf("PSDemuxer got %d frames, want %d", len(output), len(input))
+			}
+			for _, want := range input {
+				if got := output[frameKey{cid: want.cid, pts: want.pts}]; !bytes.Equal(got, want.data) {
+					t.Fatalf("frame of %d pts %d is %d bytes, want %d bytes", want.cid, want.pts, len(got), len(want.data))
+				}
+			}
+			if !tt.mpeg1 {
+				if psm == nil || len(psm.Stream_map) != 2 || len(psm.Stream_map[0].Descriptors) != 1 {
+					t.Fatalf("PSM = %+v", psm)
+				}
+				if w, h, fps, ok := DecodeGBVideoDescriptor(&psm.Stream_map[0].Descriptors[0]); !ok || w != 1920 || h != 1080 || fps != 25 {
+					t.Errorf("DecodeGBVideoDescriptor() = %d %d %d %v", w, h, fps, ok)
+				}
+			}
+		})
+	}
+}
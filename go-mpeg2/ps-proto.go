@@ -43,15 +43,22 @@ func (e *sidNotFoundError) StreamIdNotFound() bool { return true }
 
 type PS_STREAM_TYPE int
 
+// SVAC and G711 are defined by GB/T 28181
 const (
-    PS_STREAM_UNKNOW PS_STREAM_TYPE = 0xFF
-    PS_STREAM_AAC    PS_STREAM_TYPE = 0x0F
-    PS_STREAM_H264   PS_STREAM_TYPE = 0x1B
-    PS_STREAM_H265   PS_STREAM_TYPE = 0x24
-    PS_STREAM_G711A  PS_STREAM_TYPE = 0x90
-    PS_STREAM_G711U  PS_STREAM_TYPE = 0x91
+    PS_STREAM_UNKNOW     PS_STREAM_TYPE = 0xFF
+    PS_STREAM_AAC        PS_STREAM_TYPE = 0x0F
+    PS_STREAM_H264       PS_STREAM_TYPE = 0x1B
+    PS_STREAM_H265       PS_STREAM_TYPE = 0x24
+    PS_STREAM_SVAC_VIDEO PS_STREAM_TYPE = 0x80
+    PS_STREAM_G711A      PS_STREAM_TYPE = 0x90
+    PS_STREAM_G711U      PS_STREAM_TYPE = 0x91
+    PS_STREAM_SVAC_AUDIO PS_STREAM_TYPE = 0x9B
 )
 
+func isPSVideoStream(cid PS_STREAM_TYPE) bool {
+    return cid == PS_STREAM_H264 || cid == PS_STREAM_H265 || cid == PS_STREAM_SVAC_VIDEO
+}
+
 // Table 2-33 – Program Stream pack header
 // pack_header() {
 //     pack_start_code                                     32      bslbf
@@ -149,15 +156,18 @@ func (ps_pkg_hdr *PSPackHeader) decodeMpeg1(bs *codec.BitStream) error {
     ps_pkg_hdr.System_clock_reference_base = ps_pkg_hdr.System_clock_reference_base<<15 | bs.GetBits(15)
     bs.SkipBits(1)
     ps_pkg_hdr.System_clock_reference_extension = 1
-    ps_pkg_hdr.Program_mux_rate = bs.Uint32(7)
     bs.SkipBits(1)
-    ps_pkg_hdr.Program_mux_rate = ps_pkg_hdr.Program_mux_rate<<15 | bs.Uint32(15)
+    ps_pkg_hdr.Program_mux_rate = bs.Uint32(22)
     bs.SkipBits(1)
     return nil
 }
 
 func (ps_pkg_hdr *PSPackHeader) Encode(bsw *codec.BitStreamWriter) {
     bsw.PutBytes([]byte{0x00, 0x00, 0x01, 0xBA})
+    if ps_pkg_hdr.IsMpeg1 {
+        ps_pkg_hdr.encodeMpeg1(bsw)
+        return
+    }
     bsw.PutUint8(1, 2)
     bsw.PutUint64(ps_pkg_hdr.System_clock_reference_base>>30, 3)
     bsw.PutUint8(1, 1)
@@ -175,6 +185,21 @@ func (ps_pkg_hdr *PSPackHeader) Encode(bsw *codec.BitStreamWriter) {
     bsw.PutRepetValue(0xFF, int(ps_pkg_hdr.Pack_stuffing_length))
 }
 
+// ISO/IEC 11172-1 pack
+// '0010' SCR[32..30] marker SCR[29..15] marker SCR[14..0] marker marker mux_rate(22) marker
+func (ps_pkg_hdr *PSPackHeader) encodeMpeg1(bsw *codec.BitStreamWriter) {
+    bsw.PutUint8(2, 4)
+    bsw.PutUint64(ps_pkg_hdr.System_clock_reference_base>>30, 3)
+    bsw.PutUint8(1, 1)
+    bsw.PutUint64(ps_pkg_hdr.System_clock_reference_base>>15, 15)
+    bsw.PutUint8(1, 1)
+    bsw.PutUint64(ps_pkg_hdr.System_clock_reference_base, 15)
+    bsw.PutUint8(1, 1)
+    bsw.PutUint8(1, 1)
+    bsw.PutUint32(ps_pkg_hdr.Program_mux_rate, 22)
+    bsw.PutUint8(1, 1)
+}
+
 type Elementary_Stream struct {
     Stream_id                uint8
     P_STD_buffer_bound_scale uint8
@@ -316,6 +341,27 @@ type Elementary_stream_elem struct {
     Stream_type                   uint8
     Elementary_stream_id          uint8
     Elementary_stream_info_length uint16
+    Descriptors                   []Descriptor
+}
+
+// video descriptor in the PSM of GB/T 28181 stream
+//   descriptor_tag(0x42) descriptor_length width(16) height(16) frame_rate(8)
+const PS_DESCRIPTOR_GB_VIDEO = 0x42
+
+func GBVideoDescriptor(width uint16, height uint16, frameRate uint8) Descriptor {
+    return Descriptor{
+        Tag:  PS_DESCRIPTOR_GB_VIDEO,
+        Data: []byte{uint8(width >> 8), uint8(width), uint8(height >> 8), uint8(height), frameRate},
+    }
+}
+
+func DecodeGBVideoDescriptor(desc *Descriptor) (width uint16, height uint16, frameRate uint8, ok bool) {
+    if desc.Tag != PS_DESCRIPTOR_GB_VIDEO || len(desc.Data) < 5 {
+        return 0, 0, 0, false
+    }
+    width = uint16(desc.Data[0])<<8 | uint16(desc.Data[1])
+    height = uint16(desc.Data[2])<<8 | uint16(desc.Data[3])
+    return width, height, desc.Data[4], true
 }
 
 func NewElementary_stream_elem(stype uint8, esid uint8) *Elementary_stream_elem {
@@ -379,9 +425,16 @@ func (psm *Program_stream_map) PrettyPrint(file *os.File) {
             file.WriteString("    stream_type:H264\n")
         } else if es.Stream_type == uint8(PS_STREAM_H265) {
             file.WriteString("    stream_type:H265\n")
+        } else if es.Stream_type == uint8(PS_STREAM_SVAC_VIDEO) {
+            file.WriteString("    stream_type:SVAC Video\n")
+        } else if es.Stream_type == uint8(PS_STREAM_SVAC_AUDIO) {
+            file.WriteString("    stream_type:SVAC Audio\n")
         }
         file.WriteString(fmt.Sprintf("    elementary_stream_id:%d\n", es.Elementary_stream_id))
         file.WriteString(fmt.Sprintf("    elementary_stream_info_length:%d\n", es.Elementary_stream_info_length))
+        for _, desc := range es.Descriptors {
+            desc.PrettyPrint(file)
+        }
     }
 }
 
@@ -396,12 +449,21 @@ func (psm *Program_stream_map) Encode(bsw *codec.BitStreamWriter) {
     bsw.PutUint8(0x7F, 7)
     bsw.PutUint8(1, 1)
     bsw.PutUint16(0, 16)
-    psm.Elementary_stream_map_length = uint16(len(psm.Stream_map) * 4)
+    psm.Elementary_stream_map_length = 0
+    esinfos := make([][]byte, len(psm.Stream_map))
+    for i, streaminfo := range psm.Stream_map {
+        for _, desc := range streaminfo.Descriptors {
+            esinfos[i] = append(esinfos[i], desc.Encode()...)
+        }
+        streaminfo.Elementary_stream_info_length = uint16(len(esinfos[i]))
+        psm.Elementary_stream_map_length += 4 + streaminfo.Elementary_stream_info_length
+    }
     bsw.PutUint16(psm.Elementary_stream_map_length, 16)
-    for _, streaminfo := range psm.Stream_map {
+    for i, streaminfo := range psm.Stream_map {
         bsw.PutUint8(streaminfo.Stream_type, 8)
         bsw.PutUint8(streaminfo.Elementary_stream_id, 8)
-        bsw.PutUint16(0, 16)
+        bsw.PutUint16(streaminfo.Elementary_stream_info_length, 16)
+        bsw.PutBytes(esinfos[i])
     }
     length := bsw.DistanceFromMarkDot()/8 + 4
     bsw.SetUint16(uint16(length), loc)
@@ -453,10 +515,10 @@ func (psm *Program_stream_map) Decode(bs *codec.BitStream) error {
         elem.Stream_type = bs.Uint8(8)
         elem.Elementary_stream_id = bs.Uint8(8)
         elem.Elementary_stream_info_length = bs.Uint16(16)
-        //TODO Parser descriptor
         if bs.RemainBytes() < int(elem.Elementary_stream_info_length) {
             return errParser
         }
+        elem.Descriptors, _ = DecodeDescriptors(bs.RemainData()[:elem.Elementary_stream_info_length])
         bs.SkipBits(int(elem.Elementary_stream_info_length) * 8)
         i += int(4 + elem.Elementary_stream_info_length)
         psm.Stream_map = append(psm.Stream_map, elem)
@@ -0,0 +1,157 @@
+package mpeg2
+
+import (
+    "sort"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// demuxed frame of program stream
+type PSFrame struct {
+    StreamID uint8
+    Cid      PS_STREAM_TYPE
+    Codec    codec.CodecID
+    Data     []byte
+    Pts      uint64 //ms,continuous after 33 bits wraparound
+    Dts      uint64 //ms,continuous after 33 bits wraparound
+    KeyFrame bool   //always true for audio
+    Damaged  bool   //flushed by Discontinuity,part of the frame may be lost
+}
+
+// elementary stream information from PSM and the first frames of the stream
+type PSStreamInfo struct {
+    StreamID    uint8
+    Cid         PS_STREAM_TYPE
+    Codec       codec.CodecID
+    Descriptors []Descriptor
+    Width       uint32 //video
+    Height      uint32 //video
+    FrameRate   int    //video,from GB/T 28181 video descriptor,zero if unknown
+    SampleRate  int    //audio,zero if unknown
+    Channels    int    //audio,zero if unknown
+}
+
+func PSStreamTypeToCodecId(cid PS_STREAM_TYPE) codec.CodecID {
+    switch cid {
+    case PS_STREAM_H264:
+        return codec.CODECID_VIDEO_H264
+    case PS_STREAM_H265:
+        return codec.CODECID_VIDEO_H265
+    case PS_STREAM_AAC:
+        return codec.CODECID_AUDIO_AAC
+    case PS_STREAM_G711A:
+        return codec.CODECID_AUDIO_G711A
+    case PS_STREAM_G711U:
+        return codec.CODECID_AUDIO_G711U
+    default:
+        return codec.CODECID_UNRECOGNIZED
+    }
+}
+
+func isPSKeyFrame(cid PS_STREAM_TYPE, frame []byte) bool {
+    switch cid {
+    case PS_STREAM_H264:
+        return codec.IsH264IDRFrame(frame)
+    case PS_STREAM_H265:
+        return codec.IsH265IDRFrame(frame)
+    default:
+        return !isPSVideoStream(cid)
+    }
+}
+
+func (stream *psstream) setCid(cid PS_STREAM_TYPE) {
+    stream.cid = cid
+    stream.info.Cid = cid
+    stream.info.Codec = PSStreamTypeToCodecId(cid)
+    stream.probed = false
+}
+
+// stream information from the elementary stream info of PSM
+func (stream *psstream) setDescriptors(descs []Descriptor) {
+    stream.info.Descriptors = descs
+    for i := range descs {
+        if width, height, frameRate, ok := DecodeGBVideoDescriptor(&descs[i]); ok && isPSVideoStream(stream.cid) {
+            stream.info.Width = uint32(width)
+            stream.info.Height = uint32(height)
+            stream.info.FrameRate = int(frameRate)
+            stream.probed = width > 0 && height > 0
+        }
+    }
+}
+
+// probe resolution from SPS and sample rate from ADTS header,
+// the stream is probed when the information is found
+func (stream *psstream) probe(frame []byte) {
+    if stream.probed || stream.cid == PS_STREAM_UNKNOW {
+        return
+    }
+    info := &stream.info
+    switch stream.cid {
+    case PS_STREAM_H264:
+        codec.SplitFrameWithStartCode(frame, func(nalu []byte) bool {
+            if codec.H264NaluType(nalu) == codec.H264_NAL_SPS {
+                if width, height, err := codec.ParseH264Resolution(nalu); err == nil {
+                    info.Width, info.Height = width, height
+                }
+                return false
+            }
+            return true
+        })
+    case PS_STREAM_H265:
+        codec.SplitFrameWithStartCode(frame, func(nalu []byte) bool {
+            if codec.H265NaluType(nalu) == codec.H265_NAL_SPS {
+                if width, height, err := codec.ParseH265Resolution(nalu); err == nil {
+                    info.Width, info.Height = width, height
+                }
+                return false
+            }
+            return true
+        })
+    case PS_STREAM_AAC:
+        if asc, err := codec.ConvertADTSToASC(frame); err == nil {
+            info.SampleRate = codec.AACSampleIdxToSample(int(asc.Sample_freq_index))
+            info.Channels = int(asc.Channel_configuration)
+        }
+    case PS_STREAM_G711A, PS_STREAM_G711U:
+        info.SampleRate = 8000
+        info.Channels = 1
+    }
+    if stream.cid == PS_STREAM_H264 || stream.cid == PS_STREAM_H265 {
+        stream.probed = info.Width > 0 && info.Height > 0
+    } else {
+        stream.probed = true
+    }
+}
+
+func (psdemuxer *PSDemuxer) allProbed() bool {
+    for _, stream := range psdemuxer.streamMap {
+        if !stream.probed {
+            return false
+        }
+    }
+    return true
+}
+
+// information of the streams found by PSM or PES,sorted by stream id
+func (psdemuxer *PSDemuxer) StreamInfo() []PSStreamInfo {
+    infos := make([]PSStreamInfo, 0, len(psdemuxer.streamMap))
+    for _, stream := range psdemuxer.streamMap {
+        infos = append(infos, stream.info)
+    }
+    sort.Slice(infos, func(i, j int) bool {
+        return infos[i].StreamID < infos[j].StreamID
+    })
+    return infos
+}
+
+// PTS/DTS are 33 bits,extend them to 64 bits relative to the last timestamp
+func unwrapTimestamp(last uint64, ts uint64) uint64 {
+    const wrap = uint64(1) << 33
+    ts = last&^(wrap-1) | ts&(wrap-1)
+    if ts+wrap/2 < last {
+        ts += wrap
+    } else if ts > last+wrap/2 && ts >= wrap {
+        ts -= wrap
+    }
+    return ts
+}
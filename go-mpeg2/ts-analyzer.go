@@ -0,0 +1,589 @@
+package mpeg2
+
+import (
+    "encoding/json"
+    "errors"
+    "io"
+    "sort"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// ETSI TR 101 290 measurement guidelines for DVB systems
+// All time measurements are based on the PCR of the first PCR PID found in the stream,
+// the arrival time of a ts packet is interpolated from its byte position and the transport rate.
+
+const (
+    tsClock            uint64 = 27000000
+    pcrWrap            uint64 = (1 << 33) * 300
+    ptsWrap            uint64 = 1 << 33
+    patInterval        uint64 = tsClock / 2      //500ms
+    pmtInterval        uint64 = tsClock / 2      //500ms
+    pcrInterval        uint64 = tsClock / 10     //100ms
+    pcrDiscontinuity   uint64 = tsClock / 10     //100ms
+    ptsInterval        uint64 = tsClock * 7 / 10 //700ms
+    pcrAccuracyInTicks int64  = 14               //±500ns
+)
+
+type TR101290Priority1 struct {
+    TSSyncLoss           uint64 `json:"ts_sync_loss"`
+    SyncByteError        uint64 `json:"sync_byte_error"`
+    PATError             uint64 `json:"pat_error"`
+    ContinuityCountError uint64 `json:"continuity_count_error"`
+    PMTError             uint64 `json:"pmt_error"`
+    PIDError             uint64 `json:"pid_error"`
+}
+
+type TR101290Priority2 struct {
+    TransportError                 uint64 `json:"transport_error"`
+    CRCError                       uint64 `json:"crc_error"`
+    PCRRepetitionError             uint64 `json:"pcr_repetition_error"`
+    PCRDiscontinuityIndicatorError uint64 `json:"pcr_discontinuity_indicator_error"`
+    PCRAccuracyError               uint64 `json:"pcr_accuracy_error"`
+    PTSError                       uint64 `json:"pts_error"`
+    CATError                       uint64 `json:"cat_error"`
+}
+
+type TSPidReport struct {
+    PID               uint16  `json:"pid"`
+    Type              string  `json:"type"`
+    StreamType        uint8   `json:"stream_type,omitempty"`
+    Packets           uint64  `json:"packets"`
+    Bitrate           uint64  `json:"bitrate"`
+    ContinuityErrors  uint64  `json:"continuity_errors"`
+    TransportErrors   uint64  `json:"transport_errors"`
+    ScrambledPackets  uint64  `json:"scrambled_packets"`
+    PCRCount          uint64  `json:"pcr_count,omitempty"`
+    MaxPCRIntervalMs  float64 `json:"max_pcr_interval_ms,omitempty"`
+    MaxPCRJitterNs    float64 `json:"max_pcr_jitter_ns,omitempty"`
+    PTSCount          uint64  `json:"pts_count,omitempty"`
+    PTSDTSOrderErrors uint64  `json:"pts_dts_order_errors"`
+}
+
+type TSAnalyzeReport struct {
+    TotalPackets uint64            `json:"total_packets"`
+    DurationSec  float64           `json:"duration_sec"`
+    Bitrate      uint64            `json:"bitrate"`
+    Priority1    TR101290Priority1 `json:"priority1"`
+    Priority2    TR101290Priority2 `json:"priority2"`
+    Pids         []TSPidReport     `json:"pids"`
+}
+
+func (report *TSAnalyzeReport) JSON() ([]byte, error) {
+    return json.MarshalIndent(report, "", "  ")
+}
+
+type pidState struct {
+    report        TSPidReport
+    lastCC        uint8
+    hasCC         bool
+    dupCount      int
+    lastSeen      uint64
+    referenced    bool
+    pidErrFlagged bool
+    isPmt         bool
+    pmtSeen       uint64
+    hasPmt        bool
+    pmtFlagged    bool
+    section       []byte
+
+    //pcr
+    firstPcr    uint64
+    firstPcrIdx uint64
+    lastPcr     uint64
+    lastPcrIdx  uint64
+    hasPcr      bool
+
+    //pes
+    lastPtsTime uint64
+    hasPtsTime  bool
+    ptsFlagged  bool
+    lastDts     uint64
+    hasDts      bool
+}
+
+type TSAnalyzer struct {
+    Priority1 TR101290Priority1
+    Priority2 TR101290Priority2
+    //PID_error,referred PID does not occur for this period,in 27MHz ticks,default 5s
+    PIDTimeout uint64
+
+    pids       map[uint16]*pidState
+    pktIdx     uint64
+    syncCount  int
+    lostCount  int
+    inSync     bool
+    cache      []byte
+    catSeen    bool
+    clockPid   uint16
+    hasClock   bool
+    clockBase  uint64
+    now        uint64
+    patSeen    uint64
+    hasPat     bool
+    patFlagged bool
+}
+
+func NewTSAnalyzer() *TSAnalyzer {
+    return &TSAnalyzer{
+        PIDTimeout: 5 * tsClock,
+        pids:       make(map[uint16]*pidState),
+        inSync:     true,
+    }
+}
+
+// analyze the whole ts stream from r
+func (analyzer *TSAnalyzer) Input(r io.Reader) error {
+    buf := make([]byte, 64*TS_PAKCET_SIZE)
+    for {
+        n, err := r.Read(buf)
+        if n > 0 {
+            analyzer.Feed(buf[:n])
+        }
+        if err != nil {
+            if errors.Is(err, io.EOF) {
+                return nil
+            }
+            return err
+        }
+    }
+}
+
+// Feed accepts arbitrary sized chunks of a ts stream,e.g. from udp/rtp
+func (analyzer *TSAnalyzer) Feed(data []byte) {
+    analyzer.cache = append(analyzer.cache, data...)
+    buf := analyzer.cache
+    for len(buf) >= TS_PAKCET_SIZE {
+        if buf[0] != 0x47 {
+            analyzer.syncByteError()
+            i := 1
+            for ; i < len(buf) && buf[i] != 0x47; i++ {
+            }
+            buf = buf[i:]
+            continue
+        }
+        analyzer.InputPacket(buf[:TS_PAKCET_SIZE])
+        buf = buf[TS_PAKCET_SIZE:]
+    }
+    analyzer.cache = append(analyzer.cache[:0], buf...)
+}
+
+func (analyzer *TSAnalyzer) syncByteError() {
+    analyzer.Priority1.SyncByteError++
+    analyzer.syncCount = 0
+    analyzer.lostCount++
+    //sync is lost after two or more consecutive corrupted sync bytes
+    if analyzer.inSync && analyzer.lostCount >= 2 {
+        analyzer.inSync = false
+        analyzer.Priority1.TSSyncLoss++
+    }
+}
+
+// InputPacket analyzes one 188 bytes ts packet
+func (analyzer *TSAnalyzer) InputPacket(pkt []byte) {
+    if len(pkt) < TS_PAKCET_SIZE {
+        return
+    }
+    if pkt[0] != 0x47 {
+        analyzer.syncByteError()
+        return
+    }
+    analyzer.lostCount = 0
+    analyzer.syncCount++
+    //sync is acquired with five consecutive correct sync bytes
+    if !analyzer.inSync && analyzer.syncCount >= 5 {
+        analyzer.inSync = true
+    }
+
+    analyzer.pktIdx++
+    bs := codec.NewBitStream(pkt[:TS_PAKCET_SIZE])
+    var pkg TSPacket
+    if err := pkg.DecodeHeader(bs); err != nil {
+        return
+    }
+    state := analyzer.pidState(pkg.PID)
+    state.report.Packets++
+    if pcrFlag(&pkg) {
+        analyzer.doPcr(state, &pkg)
+    }
+    analyzer.updateClock()
+    state.lastSeen = analyzer.now
+    state.pidErrFlagged = false
+
+    if pkg.Transport_error_indicator == 1 {
+        analyzer.Priority2.TransportError++
+        state.report.TransportErrors++
+        return
+    }
+    if pkg.PID == TS_PID_Nil {
+        return
+    }
+    if pkg.Transport_scrambling_control != 0 {
+        state.report.ScrambledPackets++
+        if !analyzer.catSeen {
+            analyzer.Priority2.CATError++
+        }
+    }
+    analyzer.doContinuity(state, &pkg)
+
+    if pkg.Adaptation_field_control&0x01 == 0 || bs.RemainBytes() <= 0 {
+        analyzer.checkTimeout()
+        return
+    }
+    payload := bs.RemainData()
+    switch {
+    case pkg.PID == uint16(TS_PID_PAT):
+        if pkg.Transport_scrambling_control != 0 {
+            analyzer.Priority1.PATError++
+        }
+        analyzer.doSection(state, pkg.Payload_unit_start_indicator, payload)
+    case pkg.PID == 0x0001:
+        analyzer.doSection(state, pkg.Payload_unit_start_indicator, payload)
+    case state.isPmt:
+        if pkg.Transport_scrambling_control != 0 {
+            analyzer.Priority1.PMTError++
+        }
+        analyzer.doSection(state, pkg.Payload_unit_start_indicator, payload)
+    default:
+        if pkg.Payload_unit_start_indicator == 1 && pkg.Transport_scrambling_control == 0 {
+            analyzer.doPes(state, payload)
+        }
+    }
+    analyzer.checkTimeout()
+}
+
+func (analyzer *TSAnalyzer) pidState(pid uint16) *pidState {
+    state, found := analyzer.pids[pid]
+    if !found {
+        state = &pidState{lastSeen: analyzer.now}
+        state.report.PID = pid
+        state.report.Type = "unknown"
+        switch {
+        case pid == uint16(TS_PID_PAT):
+            state.report.Type = "PAT"
+        case pid == 0x0001:
+            state.report.Type = "CAT"
+        case pid == TS_PID_Nil:
+            state.report.Type = "NULL"
+        }
+        analyzer.pids[pid] = state
+    }
+    return state
+}
+
+func pcrFlag(pkg *TSPacket) bool {
+    return pkg.Field != nil && pkg.Adaptation_field_control&0x02 != 0 && pkg.Field.Adaptation_field_length > 0 && pkg.Field.PCR_flag == 1
+}
+
+func (analyzer *TSAnalyzer) doContinuity(state *pidState, pkg *TSPacket) {
+    discontinuity := pkg.Field != nil && pkg.Adaptation_field_control&0x02 != 0 && pkg.Field.Adaptation_field_length > 0 && pkg.Field.Discontinuity_indicator == 1
+    hasPayload := pkg.Adaptation_field_control&0x01 != 0
+    defer func() {
+        state.lastCC = pkg.Continuity_counter
+        state.hasCC = true
+    }()
+    if !state.hasCC || discontinuity {
+        state.dupCount = 0
+        return
+    }
+    if !hasPayload {
+        //continuity_counter shall not be incremented when adaptation_field_control == '00' or '10'
+        if pkg.Continuity_counter != state.lastCC {
+            analyzer.ccError(state)
+        }
+        return
+    }
+    if pkg.Continuity_counter == state.lastCC {
+        //a packet may be sent twice
+        state.dupCount++
+        if state.dupCount > 1 {
+            analyzer.ccError(state)
+        }
+        return
+    }
+    state.dupCount = 0
+    if pkg.Continuity_counter != (state.lastCC+1)&0x0F {
+        analyzer.ccError(state)
+    }
+}
+
+func (analyzer *TSAnalyzer) ccError(state *pidState) {
+    analyzer.Priority1.ContinuityCountError++
+    state.report.ContinuityErrors++
+}
+
+func (analyzer *TSAnalyzer) doPcr(state *pidState, pkg *TSPacket) {
+    pcr := pkg.Field.Program_clock_reference_base*300 + uint64(pkg.Field.Program_clock_reference_extension)
+    state.report.PCRCount++
+    if !analyzer.hasClock {
+        analyzer.hasClock = true
+        analyzer.clockPid = pkg.PID
+    }
+    discontinuity := !state.hasPcr || pkg.Field.Discontinuity_indicator == 1
+    if !discontinuity {
+        diff := (pcr + pcrWrap - state.lastPcr) % pcrWrap
+        if diff > pcrWrap/2 || diff > pcrDiscontinuity {
+            //negative or too large gap without discontinuity_indicator
+            analyzer.Priority2.PCRDiscontinuityIndicatorError++
+            discontinuity = true
+        } else {
+            if diff > pcrInterval {
+                analyzer.Priority2.PCRRepetitionError++
+            }
+            if ms := float64(diff) * 1000 / float64(tsClock); ms > state.report.MaxPCRIntervalMs {
+                state.report.MaxPCRIntervalMs = ms
+            }
+            if state.lastPcrIdx > state.firstPcrIdx {
+                //transport rate is estimated from the first PCR to the previous one,
+                //the expected PCR is interpolated by byte position
+                elapsed := (state.lastPcr + pcrWrap - state.firstPcr) % pcrWrap
+                expected := state.lastPcr + elapsed*(analyzer.pktIdx-state.lastPcrIdx)/(state.lastPcrIdx-state.firstPcrIdx)
+                jitter := int64(pcr) - int64(expected%pcrWrap)
+                if jitter < 0 {
+                    jitter = -jitter
+                }
+                if jitter > pcrAccuracyInTicks && jitter < int64(pcrDiscontinuity) {
+                    analyzer.Priority2.PCRAccuracyError++
+                }
+                if ns := float64(jitter) * 1e9 / float64(tsClock); ns > state.report.MaxPCRJitterNs {
+                    state.report.MaxPCRJitterNs = ns
+                }
+            }
+        }
+    }
+    if discontinuity {
+        state.firstPcr = pcr
+        state.firstPcrIdx = analyzer.pktIdx
+        if pkg.PID == analyzer.clockPid {
+            analyzer.clockBase = analyzer.now
+        }
+    }
+    state.hasPcr = true
+    state.lastPcr = pcr
+    state.lastPcrIdx = analyzer.pktIdx
+}
+
+// packet arrival time in 27MHz ticks,based on the PCR of clock pid
+func (analyzer *TSAnalyzer) updateClock() {
+    if !analyzer.hasClock {
+        return
+    }
+    //the clock keeps going across PCR discontinuities
+    state := analyzer.pids[analyzer.clockPid]
+    elapsed := (state.lastPcr + pcrWrap - state.firstPcr) % pcrWrap
+    now := analyzer.clockBase + elapsed
+    if state.lastPcrIdx > state.firstPcrIdx {
+        now += elapsed * (analyzer.pktIdx - state.lastPcrIdx) / (state.lastPcrIdx - state.firstPcrIdx)
+    }
+    if now > analyzer.now {
+        analyzer.now = now
+    }
+}
+
+func (analyzer *TSAnalyzer) doSection(state *pidState, start uint8, payload []byte) {
+    if start == 1 {
+        pointer := int(payload[0])
+        if pointer+1 >= len(payload) {
+            return
+        }
+        state.section = append(state.section[:0], payload[1+pointer:]...)
+    } else if len(state.section) > 0 {
+        state.section = append(state.section, payload...)
+    } else {
+        return
+    }
+    if len(state.section) < 3 || state.section[0] == 0xFF {
+        return
+    }
+    sectionLen := int(state.section[1]&0x0F)<<8 | int(state.section[2])
+    if len(state.section) < 3+sectionLen {
+        return
+    }
+    section := state.section[:3+sectionLen]
+    state.section = state.section[:0]
+    tid := section[0]
+    crcOk := codec.CalcCrc32(0xffffffff, section) == 0
+    if !crcOk {
+        analyzer.Priority2.CRCError++
+    }
+    switch {
+    case state.report.PID == uint16(TS_PID_PAT):
+        if tid != uint8(TS_TID_PAS) {
+            analyzer.Priority1.PATError++
+            return
+        }
+        if analyzer.hasClock && analyzer.hasPat && analyzer.now-analyzer.patSeen > patInterval && !analyzer.patFlagged {
+            analyzer.Priority1.PATError++
+        }
+        analyzer.patSeen = analyzer.now
+        analyzer.hasPat = true
+        analyzer.patFlagged = false
+        if !crcOk {
+            return
+        }
+        if pat, err := ReadSection(TS_TID_PAS, codec.NewBitStream(section)); err == nil {
+            for _, pm := range pat.(*Pat).Pmts {
+                if pm.Program_number == 0x0000 {
+                    continue
+                }
+                pmtstate := analyzer.pidState(pm.PID)
+                if !pmtstate.isPmt {
+                    pmtstate.isPmt = true
+                    pmtstate.referenced = true
+                    pmtstate.report.Type = "PMT"
+                }
+            }
+        }
+    case state.report.PID == 0x0001:
+        if tid == uint8(TS_TID_CAS) {
+            analyzer.catSeen = true
+        } else {
+            analyzer.Priority2.CATError++
+        }
+    case state.isPmt:
+        if tid != uint8(TS_TID_PMS) {
+            analyzer.Priority1.PMTError++
+            return
+        }
+        if analyzer.hasClock && state.hasPmt && analyzer.now-state.pmtSeen > pmtInterval && !state.pmtFlagged {
+            analyzer.Priority1.PMTError++
+        }
+        state.pmtSeen = analyzer.now
+        state.hasPmt = true
+        state.pmtFlagged = false
+        if !crcOk {
+            return
+        }
+        if pmt, err := ReadSection(TS_TID_PMS, codec.NewBitStream(section)); err == nil {
+            for _, stream := range pmt.(*Pmt).Streams {
+                es := analyzer.pidState(stream.Elementary_PID)
+                es.referenced = true
+                es.report.StreamType = stream.StreamType
+                es.report.Type = streamTypeName(TS_STREAM_TYPE(stream.StreamType))
+                if stream.StreamType == uint8(TS_STREAM_PRIVATE) {
+                    if cid := privateStreamType(stream.Descriptors); cid != TS_STREAM_PRIVATE {
+                        es.report.Type = streamTypeName(cid)
+                    }
+                }
+            }
+        }
+    }
+}
+
+func (analyzer *TSAnalyzer) doPes(state *pidState, payload []byte) {
+    if len(payload) < 9 || payload[0] != 0x00 || payload[1] != 0x00 || payload[2] != 0x01 {
+        return
+    }
+    pes := NewPesPacket()
+    if err := pes.Decode(codec.NewBitStream(payload)); err != nil && !errors.Is(err, errNeedMore) {
+        return
+    }
+    if pes.PTS_DTS_flags&0x02 == 0 {
+        return
+    }
+    state.report.PTSCount++
+    if analyzer.hasClock {
+        if state.hasPtsTime && analyzer.now-state.lastPtsTime > ptsInterval && !state.ptsFlagged {
+            analyzer.Priority2.PTSError++
+        }
+        state.lastPtsTime = analyzer.now
+        state.hasPtsTime = true
+        state.ptsFlagged = false
+    }
+    //DTS must increase,PTS must not be earlier than DTS
+    if (pes.Pts+ptsWrap-pes.Dts)%ptsWrap > ptsWrap/2 {
+        state.report.PTSDTSOrderErrors++
+    }
+    if state.hasDts {
+        diff := (pes.Dts + ptsWrap - state.lastDts) % ptsWrap
+        if diff == 0 || diff > ptsWrap/2 {
+            state.report.PTSDTSOrderErrors++
+        }
+    }
+    state.lastDts = pes.Dts
+    state.hasDts = true
+}
+
+func (analyzer *TSAnalyzer) checkTimeout() {
+    if !analyzer.hasClock {
+        return
+    }
+    now := analyzer.now
+    if analyzer.hasPat && now-analyzer.patSeen > patInterval && !analyzer.patFlagged {
+        analyzer.Priority1.PATError++
+        analyzer.patFlagged = true
+    }
+    for _, state := range analyzer.pids {
+        if state.isPmt && state.hasPmt && now-state.pmtSeen > pmtInterval && !state.pmtFlagged {
+            analyzer.Priority1.PMTError++
+            state.pmtFlagged = true
+        }
+        if state.referenced && now-state.lastSeen > analyzer.PIDTimeout && !state.pidErrFlagged {
+            analyzer.Priority1.PIDError++
+            state.pidErrFlagged = true
+        }
+        if state.hasPtsTime && now-state.lastPtsTime > ptsInterval && !state.ptsFlagged {
+            analyzer.Priority2.PTSError++
+            state.ptsFlagged = true
+        }
+    }
+}
+
+func streamTypeName(cid TS_STREAM_TYPE) string {
+    switch cid {
+    case TS_STREAM_AUDIO_MPEG1:
+        return "MPEG1 Audio"
+    case TS_STREAM_AUDIO_MPEG2:
+        return "MPEG2 Audio"
+    case TS_STREAM_PRIVATE:
+        return "PES private data"
+    case TS_STREAM_AAC:
+        return "AAC"
+    case TS_STREAM_H264:
+        return "H264"
+    case TS_STREAM_H265:
+        return "H265"
+    case TS_STREAM_MPEG2_VIDEO:
+        return "MPEG2 Video"
+    case TS_STREAM_AAC_LATM:
+        return "AAC LATM"
+    case TS_STREAM_AC3:
+        return "AC3"
+    case TS_STREAM_EAC3:
+        return "EAC3"
+    case TS_STREAM_OPUS:
+        return "Opus"
+    default:
+        return "unknown"
+    }
+}
+
+// Report summarizes error counters and per-PID bitrate,
+// bitrate is zero if there is no PCR in the stream
+func (analyzer *TSAnalyzer) Report() *TSAnalyzeReport {
+    report := &TSAnalyzeReport{
+        TotalPackets: analyzer.pktIdx,
+        Priority1:    analyzer.Priority1,
+        Priority2:    analyzer.Priority2,
+        Pids:         make([]TSPidReport, 0, len(analyzer.pids)),
+    }
+    report.DurationSec = float64(analyzer.now) / float64(tsClock)
+    for _, state := range analyzer.pids {
+        pid := state.report
+        pid.Bitrate = analyzer.bitrate(pid.Packets)
+        report.Pids = append(report.Pids, pid)
+    }
+    report.Bitrate = analyzer.bitrate(analyzer.pktIdx)
+    sort.Slice(report.Pids, func(i, j int) bool {
+        return report.Pids[i].PID < report.Pids[j].PID
+    })
+    return report
+}
+
+// bits per second,float64 avoids the overflow of long captures
+func (analyzer *TSAnalyzer) bitrate(packets uint64) uint64 {
+    if analyzer.now == 0 {
+        return 0
+    }
+    return uint64(float64(packets) * TS_PAKCET_SIZE * 8 * float64(tsClock) / float64(analyzer.now))
+}
@@ -0,0 +1,134 @@
+package mpeg2
+
+import (
+	"bytes"
+	"testing"
+)
+
+// a 188 bytes ts packet,the adaptation field carries PCR if pcr >= 0
+func makeAnalyzerPacket(pid uint16, cc uint8, pcr int64) []byte {
+	pkt := bytes.Repeat([]byte{0xFF}, TS_PAKCET_SIZE)
+	pkt[0] = 0x47
+	pkt[1] = byte(pid>>8) & 0x1F
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10 | cc&0x0F
+	if pcr >= 0 {
+		base, ext := uint64(pcr)/300, uint64(pcr)%300
+		pkt[3] |= 0x20
+		pkt[4] = 7
+		pkt[5] = 0x10
+		pkt[6] = byte(base >> 25)
+		pkt[7] = byte(base >> 17)
+		pkt[8] = byte(base >> 9)
+		pkt[9] = byte(base >> 1)
+		pkt[10] = byte(base<<7) | 0x7E | byte(ext>>8)
+		pkt[11] = byte(ext)
+	}
+	return pkt
+}
+
+// 1ms per packet(1504000 bps),PCR on pid 0x100 every 10 packets,payload on pid 0x101,
+// the PCR of packet i is shifted by jitter(i) ticks
+func makeAnalyzerStream(packets int, jitter func(i int) int64) []byte {
+	var ts bytes.Buffer
+	cc := map[uint16]uint8{}
+	for i := 0; i < packets; i++ {
+		pid := uint16(0x101)
+		pcr := int64(-1)
+		if i%10 == 0 {
+			pid = 0x100
+			pcr = int64(i)*27000 + jitter(i)
+		}
+		ts.Write(makeAnalyzerPacket(pid, cc[pid], pcr))
+		cc[pid]++
+	}
+	return ts.Bytes()
+}
+
+func noJitter(i int) int64 { return 0 }
+
+func TestTSAnalyzer_ContinuityCount(t *testing.T) {
+	tests := []struct {
+		name string
+		ccs  []uint8
+		want uint64
+	}{
+		{name: "continuous", ccs: []uint8{14, 15, 0, 1, 2}, want: 0},
+		{name: "lost", ccs: []uint8{0, 1, 3, 4}, want: 1},
+		{name: "duplicated once", ccs: []uint8{0, 1, 1, 2}, want: 0},
+		{name: "duplicated twice", ccs: []uint8{0, 1, 1, 1, 2}, want: 1},
+		{name: "out of order", ccs: []uint8{0, 2, 1, 3}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewTSAnalyzer()
+			for _, cc := range tt.ccs {
+				analyzer.InputPacket(makeAnalyzerPacket(0x101, cc, -1))
+			}
+			report := analyzer.Report()
+			if report.Priority1.ContinuityCountError != tt.want || report.Pids[0].ContinuityErrors != tt.want {
+				t.Errorf("ContinuityCountError = %d, want %d", report.Priority1.ContinuityCountError, tt.want)
+			}
+		})
+	}
+}
+
+func TestTSAnalyzer_PCR(t *testing.T) {
+	analyzer := NewTSAnalyzer()
+	if err := analyzer.Input(bytes.NewReader(makeAnalyzerStream(1000, noJitter))); err != nil {
+		t.Fatal(err)
+	}
+	report := analyzer.Report()
+	pcr := report.Pids[0]
+	if report.Priority2.PCRAccuracyError != 0 || pcr.MaxPCRJitterNs != 0 || pcr.PCRCount != 100 || pcr.MaxPCRIntervalMs != 10 {
+		t.Errorf("constant rate stream report = %+v", pcr)
+	}
+
+	//74us jitter of the 50th PCR
+	analyzer = NewTSAnalyzer()
+	analyzer.Input(bytes.NewReader(makeAnalyzerStream(1000, func(i int) int64 {
+		if i == 500 {
+			return 2000
+		}
+		return 0
+	})))
+	report = analyzer.Report()
+	pcr = report.Pids[0]
+	if report.Priority2.PCRAccuracyError == 0 || pcr.MaxPCRJitterNs < 70000 || pcr.MaxPCRJitterNs > 80000 {
+		t.Errorf("jitter report = %+v,%+v", report.Priority2, pcr)
+	}
+	if report.Priority2.PCRRepetitionError != 0 || report.Priority2.PCRDiscontinuityIndicatorError != 0 {
+		t.Errorf("jitter report = %+v", report.Priority2)
+	}
+
+	//200ms gap without discontinuity_indicator
+	analyzer = NewTSAnalyzer()
+	analyzer.Input(bytes.NewReader(makeAnalyzerStream(1000, func(i int) int64 {
+		if i >= 500 {
+			return 200 * 27000
+		}
+		return 0
+	})))
+	if analyzer.Priority2.PCRDiscontinuityIndicatorError != 1 {
+		t.Errorf("PCRDiscontinuityIndicatorError = %d, want 1", analyzer.Priority2.PCRDiscontinuityIndicatorError)
+	}
+}
+
+func TestTSAnalyzer_Bitrate(t *testing.T) {
+	analyzer := NewTSAnalyzer()
+	analyzer.Input(bytes.NewReader(makeAnalyzerStream(1001, noJitter)))
+	report := analyzer.Report()
+	if report.TotalPackets != 1001 || report.DurationSec != 1 || report.Bitrate != 1001*188*8 {
+		t.Errorf("report = %d packets %fs %dbps", report.TotalPackets, report.DurationSec, report.Bitrate)
+	}
+	if len(report.Pids) != 2 || report.Pids[0].Bitrate != 101*188*8 || report.Pids[1].Bitrate != 900*188*8 {
+		t.Errorf("pid bitrate = %+v", report.Pids)
+	}
+
+	//one thousand hours at 1504000 bps overflows the integer computation
+	analyzer.pktIdx = 3600 * 1000 * 1000
+	analyzer.now = 3600 * 1000 * tsClock
+	if bitrate := analyzer.Report().Bitrate; bitrate != 1504000 {
+		t.Errorf("long capture bitrate = %d", bitrate)
+	}
+}
@@ -22,166 +22,314 @@ func newPacket_t(size uint32) *pakcet_t {
 }
 
 type tsstream struct {
+    pid     uint16
+    program *tsprogram
+    info    TSStreamInfo
+    probed  bool
     cid     TS_STREAM_TYPE
     pes_sid PES_STREMA_ID
     pes_pkg *PesPacket
     pkg     *pakcet_t
+    private *PrivateStream
+    pes_len int
+    pes_off int //where the current PES begins in pkg.payload
+    cc      uint8
+    hascc   bool
+    skip    bool //drop the rest of PES after continuity_counter error
+    damaged bool
 }
 
 type tsprogram struct {
     pn      uint16
+    pmtPid  uint16
+    pcrPid  uint16
+    version int //-1 before the first PMT
     streams map[uint16]*tsstream
+    ready   bool //OnStreamsChanged has been called for current streams
+    pending []*Packet
 }
 
 type TSDemuxer struct {
     programs   map[uint16]*tsprogram
     OnFrame    func(cid TS_STREAM_TYPE, frame []byte, pts uint64, dts uint64)
-    OnTSPacket func(pkg *TSPacket)
+    //optional,it is called instead of OnFrame if set,
+    //damaged is true if part of the frame was lost because of continuity_counter error
+    OnFrameWithFlag func(cid TS_STREAM_TYPE, frame []byte, pts uint64, dts uint64, damaged bool)
+    OnTSPacket      func(pkg *TSPacket)
+    //PES private data stream(DVB subtitle,teletext...),one complete PES payload per callback
+    OnPrivateFrame func(stream *PrivateStream, frame []byte, pts uint64, dts uint64)
+    //optional,it is called when the streams of a program are found or changed by PMT,
+    //frames are held until the resolution/sample rate of every audio/video stream is probed,
+    //so the stream information is always known before the first frame
+    OnStreamsChanged func(program *TSProgramInfo)
+    //optional,the PES payload which can't be split into frames is reported and dropped
+    OnError  func(pid uint16, err error)
+    analyzer         *TSAnalyzer
+    ccErrors         int
+    resyncs          int
+    reader           *tsPacketReader //pull mode
+    queue            []*Packet
+    eof              bool
+    err              error
 }
 
 func NewTSDemuxer() *TSDemuxer {
     return &TSDemuxer{
-        programs:   make(map[uint16]*tsprogram),
-        OnFrame:    nil,
-        OnTSPacket: nil,
+        programs:       make(map[uint16]*tsprogram),
+        OnFrame:        nil,
+        OnTSPacket:     nil,
+        OnPrivateFrame: nil,
     }
 }
 
-func (demuxer *TSDemuxer) Input(r io.Reader) error {
-    var err error = nil
-    var buf []byte
-    for {
-        if len(buf) > TS_PAKCET_SIZE {
-            buf = buf[TS_PAKCET_SIZE:]
-        } else {
-            if err != nil {
-                if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-                    break
-                }
-                return err
+// pull mode,frames are read by ReadPacket instead of OnFrame/OnPrivateFrame callbacks
+func NewTSDemuxerWithReader(r io.Reader) *TSDemuxer {
+    demuxer := NewTSDemuxer()
+    demuxer.reader = newTsPacketReader(r)
+    return demuxer
+}
+
+// ReadPacket returns the next frame of any program,io.EOF at the end of stream,
+// the returned packet is owned by the caller
+func (demuxer *TSDemuxer) ReadPacket() (*Packet, error) {
+    if demuxer.reader == nil {
+        return nil, errors.New("ts demuxer is not created by NewTSDemuxerWithReader")
+    }
+    for len(demuxer.queue) == 0 {
+        if demuxer.eof {
+            if demuxer.err != nil {
+                return nil, demuxer.err
             }
-            buf, err = demuxer.probe(r)
-            if err != nil && buf == nil {
-                if errors.Is(err, io.EOF) {
-                    break
-                }
-                return err
+            return nil, io.EOF
+        }
+        buf, err := demuxer.reader.next()
+        if err != nil {
+            if !errors.Is(err, io.EOF) {
+                demuxer.err = err
             }
+            demuxer.eof = true
+            demuxer.flush()
+            continue
         }
+        demuxer.inputPacket(buf)
+    }
+    pkt := demuxer.queue[0]
+    demuxer.queue[0] = nil
+    demuxer.queue = demuxer.queue[1:]
+    return pkt, nil
+}
 
-        bs := codec.NewBitStream(buf[:TS_PAKCET_SIZE])
-        var pkg TSPacket
-        if err := pkg.DecodeHeader(bs); err != nil {
+// analysis mode,every ts packet is checked by TSAnalyzer before demuxing,
+// call TSAnalyzer.Report() to get the TR 101 290 error counters
+func (demuxer *TSDemuxer) EnableAnalyzer() *TSAnalyzer {
+    if demuxer.analyzer == nil {
+        demuxer.analyzer = NewTSAnalyzer()
+    }
+    return demuxer.analyzer
+}
+
+func (demuxer *TSDemuxer) Input(r io.Reader) error {
+    reader := newTsPacketReader(r)
+    defer func() {
+        demuxer.resyncs += reader.resyncs
+    }()
+    for {
+        buf, err := reader.next()
+        if err != nil {
+            if errors.Is(err, io.EOF) {
+                break
+            }
             return err
         }
-        if pkg.PID == uint16(TS_PID_PAT) {
-            if pkg.Payload_unit_start_indicator == 1 {
-                bs.SkipBits(8)
-            }
-            pkg.Payload, err = ReadSection(TS_TID_PAS, bs)
-            if err != nil {
-                return err
-            }
-            pat := pkg.Payload.(*Pat)
-            for _, pmt := range pat.Pmts {
-                if pmt.Program_number != 0x0000 {
-                    if _, found := demuxer.programs[pmt.PID]; !found {
-                        demuxer.programs[pmt.PID] = &tsprogram{pn: 0, streams: make(map[uint16]*tsstream)}
-                    }
+        demuxer.inputPacket(buf)
+    }
+    demuxer.flush()
+    return nil
+}
+
+// corrupted ts packet is dropped instead of aborting the whole stream
+func (demuxer *TSDemuxer) inputPacket(buf []byte) {
+    var err error
+    if demuxer.analyzer != nil {
+        demuxer.analyzer.InputPacket(buf)
+    }
+    bs := codec.NewBitStream(buf[:TS_PAKCET_SIZE])
+    var pkg TSPacket
+    if err := pkg.DecodeHeader(bs); err != nil {
+        return
+    }
+    if pkg.Transport_error_indicator == 1 {
+        //PID may be wrong,the loss will be detected by continuity_counter of the stream
+        return
+    }
+    if pkg.PID == uint16(TS_PID_PAT) {
+        if pkg.Payload_unit_start_indicator == 1 {
+            bs.SkipBits(8)
+        }
+        pkg.Payload, err = ReadSection(TS_TID_PAS, bs)
+        if err != nil || pkg.Payload == nil {
+            return
+        }
+        pat := pkg.Payload.(*Pat)
+        for _, pmt := range pat.Pmts {
+            if pmt.Program_number != 0x0000 {
+                if _, found := demuxer.programs[pmt.PID]; !found {
+                    demuxer.programs[pmt.PID] = &tsprogram{pn: 0, pmtPid: pmt.PID, version: -1, streams: make(map[uint16]*tsstream)}
                 }
             }
-        } else if pkg.PID == TS_PID_Nil {
-            continue
-        } else {
-            for p, s := range demuxer.programs {
-                if p == pkg.PID { // pmt table
-                    if pkg.Payload_unit_start_indicator == 1 {
-                        bs.SkipBits(8) //pointer filed
+        }
+    } else if pkg.PID == TS_PID_Nil {
+        return
+    } else {
+        for p, s := range demuxer.programs {
+            if p == pkg.PID { // pmt table
+                if pkg.Payload_unit_start_indicator == 1 {
+                    bs.SkipBits(8) //pointer filed
+                }
+                pkg.Payload, err = ReadSection(TS_TID_PMS, bs)
+                if err != nil || pkg.Payload == nil {
+                    return
+                }
+                demuxer.updateProgram(s, pkg.Payload.(*Pmt))
+            } else {
+                for sid, stream := range s.streams {
+                    if sid != pkg.PID {
+                        continue
                     }
-                    pkg.Payload, err = ReadSection(TS_TID_PMS, bs)
-                    if err != nil {
-                        return err
+                    if !demuxer.checkContinuity(stream, &pkg) {
+                        break
                     }
-                    pmt := pkg.Payload.(*Pmt)
-                    s.pn = pmt.Program_number
-                    for _, ps := range pmt.Streams {
-                        if _, found := s.streams[ps.Elementary_PID]; !found {
-                            s.streams[ps.Elementary_PID] = &tsstream{
-                                cid:     TS_STREAM_TYPE(ps.StreamType),
-                                pes_sid: findPESIDByStreamType(TS_STREAM_TYPE(ps.StreamType)),
-                                pes_pkg: NewPesPacket(),
-                            }
-                        }
+                    if pkg.Adaptation_field_control&0x01 == 0 {
+                        break
                     }
-                } else {
-                    for sid, stream := range s.streams {
-                        if sid != pkg.PID {
-                            continue
-                        }
-                        if pkg.Payload_unit_start_indicator == 1 {
-                            err := stream.pes_pkg.Decode(bs)
-                            // ignore error if it was a short payload read, next ts packet should append missing data
-                            if err != nil && !(errors.Is(err, errNeedMore) && stream.pes_pkg.Pes_payload != nil) {
-                                return err
-                            }
-                            pkg.Payload = stream.pes_pkg
-                        } else {
-                            stream.pes_pkg.Pes_payload = bs.RemainData()
-                            pkg.Payload = bs.RemainData()
+                    if pkg.Payload_unit_start_indicator == 1 {
+                        err := stream.pes_pkg.Decode(bs)
+                        // ignore error if it was a short payload read, next ts packet should append missing data
+                        if err != nil && !(errors.Is(err, errNeedMore) && stream.pes_pkg.Pes_payload != nil) {
+                            stream.skip = true
+                            break
                         }
-                        stype := findPESIDByStreamType(stream.cid)
-                        if stype == PES_STREAM_AUDIO {
-                            demuxer.doAudioPesPacket(stream, pkg.Payload_unit_start_indicator)
-                        } else if stype == PES_STREAM_VIDEO {
-                            demuxer.doVideoPesPacket(stream, pkg.Payload_unit_start_indicator)
+                        stream.skip = false
+                        pkg.Payload = stream.pes_pkg
+                    } else {
+                        if stream.skip {
+                            break
                         }
+                        stream.pes_pkg.Pes_payload = bs.RemainData()
+                        pkg.Payload = bs.RemainData()
+                    }
+                    if isAudioStream(stream.cid) {
+                        demuxer.doAudioPesPacket(stream, pkg.Payload_unit_start_indicator)
+                    } else if isVideoStream(stream.cid) {
+                        demuxer.doVideoPesPacket(stream, pkg.Payload_unit_start_indicator)
+                    } else if stream.cid == TS_STREAM_PRIVATE {
+                        demuxer.doPrivatePesPacket(stream, pkg.Payload_unit_start_indicator)
                     }
                 }
             }
         }
-        if demuxer.OnTSPacket != nil {
-            demuxer.OnTSPacket(&pkg)
+    }
+    if demuxer.OnTSPacket != nil {
+        demuxer.OnTSPacket(&pkg)
+    }
+}
+
+// streams are added or replaced by stream_type,
+// the streams missing in PMT are removed only if version_number changes
+func (demuxer *TSDemuxer) updateProgram(pg *tsprogram, pmt *Pmt) {
+    pg.pn = pmt.Program_number
+    pg.pcrPid = pmt.PCR_PID
+    changed := false
+    if pg.version != int(pmt.Version_number) {
+        if pg.version >= 0 {
+            pids := make(map[uint16]bool)
+            for _, ps := range pmt.Streams {
+                pids[ps.Elementary_PID] = true
+            }
+            for pid := range pg.streams {
+                if !pids[pid] {
+                    delete(pg.streams, pid)
+                    changed = true
+                }
+            }
         }
+        pg.version = int(pmt.Version_number)
+    }
+    for _, ps := range pmt.Streams {
+        cid := TS_STREAM_TYPE(ps.StreamType)
+        if cid == TS_STREAM_PRIVATE {
+            cid = privateStreamType(ps.Descriptors)
+        } else if cid == TS_STREAM_AC3 && !isAtscAC3(ps.Descriptors) {
+            //0x81 is user private outside ATSC systems
+            continue
+        }
+        if old, found := pg.streams[ps.Elementary_PID]; found && old.cid == cid {
+            continue
+        }
+        stream := &tsstream{
+            pid:     ps.Elementary_PID,
+            program: pg,
+            cid:     cid,
+            pes_sid: findPESIDByStreamType(cid),
+            pes_pkg: NewPesPacket(),
+        }
+        if stream.cid == TS_STREAM_PRIVATE {
+            stream.private = NewPrivateStream(ps.Elementary_PID, ps.Descriptors)
+        }
+        stream.info = TSStreamInfo{
+            PID:         ps.Elementary_PID,
+            Cid:         cid,
+            Codec:       TSStreamTypeToCodecId(cid),
+            Descriptors: ps.Descriptors,
+            Private:     stream.private,
+        }
+        pg.streams[ps.Elementary_PID] = stream
+        changed = true
+    }
+    if changed {
+        pg.ready = false
     }
-    demuxer.flush()
-    return nil
 }
 
-func (demuxer *TSDemuxer) probe(r io.Reader) ([]byte, error) {
-    buf := make([]byte, TS_PAKCET_SIZE, 2*TS_PAKCET_SIZE)
-    if _, err := io.ReadFull(r, buf); err != nil {
-        return nil, err
+// returns false if the packet should be ignored(duplicate packet),
+// on continuity_counter error the partial PES is dropped and the frame is flagged as damaged
+func (demuxer *TSDemuxer) checkContinuity(stream *tsstream, pkg *TSPacket) bool {
+    discontinuity := pkg.Field != nil && pkg.Adaptation_field_control&0x02 != 0 &&
+        pkg.Field.Adaptation_field_length > 0 && pkg.Field.Discontinuity_indicator == 1
+    hasPayload := pkg.Adaptation_field_control&0x01 != 0
+    lastcc := stream.cc
+    hascc := stream.hascc
+    stream.cc = pkg.Continuity_counter
+    stream.hascc = true
+    if !hascc || discontinuity || !hasPayload {
+        return true
     }
-    if buf[0] == 0x47 {
-        return buf, nil
+    if pkg.Continuity_counter == lastcc {
+        return false
     }
-    buf = buf[:2*TS_PAKCET_SIZE]
-    if _, err := io.ReadFull(r, buf[TS_PAKCET_SIZE:]); err != nil {
-        return nil, err
+    if pkg.Continuity_counter == (lastcc+1)&0x0F {
+        return true
     }
-LOOP:
-    i := 0
-    for ; i < TS_PAKCET_SIZE; i++ {
-        if buf[i] == 0x47 && buf[i+TS_PAKCET_SIZE] == 0x47 {
-            break
+    demuxer.ccErrors++
+    stream.damaged = true
+    if stream.pkg != nil {
+        if stream.cid == TS_STREAM_PRIVATE {
+            stream.pkg = nil
+        } else if stream.pes_off <= len(stream.pkg.payload) {
+            stream.pkg.payload = stream.pkg.payload[:stream.pes_off]
         }
     }
-    if i == 0 {
-        return buf, nil
-    } else if i < TS_PAKCET_SIZE {
-        copy(buf, buf[i:])
-        if _, err := io.ReadFull(r, buf[2*TS_PAKCET_SIZE-i:]); err != nil {
-            return buf[:TS_PAKCET_SIZE], err
-        } else {
-            return buf, nil
-        }
-    } else {
-        copy(buf, buf[TS_PAKCET_SIZE:])
-        if _, err := io.ReadFull(r, buf[TS_PAKCET_SIZE:]); err != nil {
-            return buf[:TS_PAKCET_SIZE], err
-        }
-        goto LOOP
+    //the rest of the PES is useless
+    stream.skip = pkg.Payload_unit_start_indicator == 0
+    return true
+}
+
+// number of continuity_counter errors and sync losses since demuxing
+func (demuxer *TSDemuxer) Errors() (ccErrors int, resyncs int) {
+    if demuxer.reader != nil {
+        return demuxer.ccErrors, demuxer.resyncs + demuxer.reader.resyncs
     }
+    return demuxer.ccErrors, demuxer.resyncs
 }
 
 func (demuxer *TSDemuxer) flush() {
@@ -191,7 +339,9 @@ func (demuxer *TSDemuxer) flush() {
                 continue
             }
 
-            if demuxer.OnFrame == nil {
+            if stream.cid == TS_STREAM_PRIVATE {
+                demuxer.emitFrame(stream, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
+                stream.pkg = nil
                 continue
             }
             if stream.cid == TS_STREAM_H264 || stream.cid == TS_STREAM_H265 {
@@ -208,30 +358,128 @@ func (demuxer *TSDemuxer) flush() {
                     }
                     return false
                 })
-                demuxer.OnFrame(stream.cid, stream.pkg.payload[audLen:], stream.pkg.pts/90, stream.pkg.dts/90)
+                demuxer.emitFrame(stream, stream.pkg.payload[audLen:], stream.pkg.pts/90, stream.pkg.dts/90)
+            } else if isAudioStream(stream.cid) {
+                demuxer.emitAudioFrame(stream, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
             } else {
-                demuxer.OnFrame(stream.cid, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
+                demuxer.emitFrame(stream, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
             }
             stream.pkg = nil
         }
     }
+    for _, pm := range demuxer.programs {
+        if !pm.ready && len(pm.pending) > 0 {
+            demuxer.notifyStreamsChanged(pm)
+        }
+    }
 }
 
-func (demuxer *TSDemuxer) doVideoPesPacket(stream *tsstream, start uint8) {
-    if stream.cid != TS_STREAM_H264 && stream.cid != TS_STREAM_H265 {
+func (demuxer *TSDemuxer) emitFrame(stream *tsstream, frame []byte, pts uint64, dts uint64) {
+    pkt := &Packet{
+        ProgramNumber: stream.program.pn,
+        PID:           stream.pid,
+        Cid:           stream.cid,
+        Codec:         stream.info.Codec,
+        Data:          frame,
+        Pts:           pts,
+        Dts:           dts,
+        Damaged:       stream.damaged,
+        Private:       stream.private,
+    }
+    if demuxer.reader != nil {
+        pkt.KeyFrame = isKeyFrame(stream.cid, frame)
+    }
+    stream.damaged = false
+    pg := stream.program
+    if demuxer.OnStreamsChanged != nil && !pg.ready {
+        stream.probe(frame)
+        pkt.Data = append([]byte{}, frame...)
+        pg.pending = append(pg.pending, pkt)
+        if pg.allProbed() || len(pg.pending) >= maxProbePackets || pkt.Dts > pg.pending[0].Dts+maxProbeDuration {
+            demuxer.notifyStreamsChanged(pg)
+        }
         return
     }
+    demuxer.dispatch(pkt)
+}
+
+func (demuxer *TSDemuxer) notifyStreamsChanged(pg *tsprogram) {
+    pg.ready = true
+    demuxer.OnStreamsChanged(pg.programInfo())
+    pending := pg.pending
+    pg.pending = nil
+    for _, pkt := range pending {
+        demuxer.dispatch(pkt)
+    }
+}
+
+func (demuxer *TSDemuxer) dispatch(pkt *Packet) {
+    if demuxer.reader != nil {
+        if demuxer.OnStreamsChanged == nil {
+            pkt.Data = append([]byte{}, pkt.Data...)
+        }
+        demuxer.queue = append(demuxer.queue, pkt)
+        return
+    }
+    if pkt.Cid == TS_STREAM_PRIVATE {
+        if demuxer.OnPrivateFrame != nil {
+            demuxer.OnPrivateFrame(pkt.Private, pkt.Data, pkt.Pts, pkt.Dts)
+        }
+    } else if demuxer.OnFrameWithFlag != nil {
+        demuxer.OnFrameWithFlag(pkt.Cid, pkt.Data, pkt.Pts, pkt.Dts, pkt.Damaged)
+    } else if demuxer.OnFrame != nil {
+        demuxer.OnFrame(pkt.Cid, pkt.Data, pkt.Pts, pkt.Dts)
+    }
+}
+
+// AC-3/E-AC-3 and Opus PES payload may carry several frames,
+// each frame is delivered with its own timestamp
+func (demuxer *TSDemuxer) emitAudioFrame(stream *tsstream, payload []byte, pts uint64, dts uint64) {
+    var samples uint64 = 0
+    var err error
+    switch stream.cid {
+    case TS_STREAM_AC3, TS_STREAM_EAC3:
+        err = codec.SplitAC3Frames(payload, func(head *codec.AC3FrameHead, frame []byte) {
+            offset := samples * 1000 / uint64(head.SampleRate)
+            demuxer.emitFrame(stream, frame, pts+offset, dts+offset)
+            samples += uint64(head.SampleSize)
+        })
+    case TS_STREAM_OPUS:
+        err = splitOpusPackets(payload, func(packet []byte) {
+            if len(packet) == 0 {
+                return
+            }
+            offset := samples / 48
+            demuxer.emitFrame(stream, packet, pts+offset, dts+offset)
+            if packet[0]&0x03 != 0x03 || len(packet) > 1 {
+                samples += codec.OpusPacketDuration(packet)
+            }
+        })
+    default:
+        demuxer.emitFrame(stream, payload, pts, dts)
+    }
+    if err != nil && demuxer.OnError != nil {
+        demuxer.OnError(stream.pid, err)
+    }
+}
+
+func (demuxer *TSDemuxer) doVideoPesPacket(stream *tsstream, start uint8) {
     if stream.pkg == nil {
         stream.pkg = newPacket_t(1024)
         stream.pkg.pts = stream.pes_pkg.Pts
         stream.pkg.dts = stream.pes_pkg.Dts
     }
+    if start == 1 {
+        stream.pes_off = len(stream.pkg.payload)
+    }
     stream.pkg.payload = append(stream.pkg.payload, stream.pes_pkg.Pes_payload...)
     update := false
     if stream.cid == TS_STREAM_H264 {
         update = demuxer.splitH264Frame(stream)
-    } else {
+    } else if stream.cid == TS_STREAM_H265 {
         update = demuxer.splitH265Frame(stream)
+    } else {
+        update = demuxer.splitMpeg2VideoFrame(stream)
     }
     if update {
         stream.pkg.pts = stream.pes_pkg.Pts
@@ -240,10 +488,6 @@ func (demuxer *TSDemuxer) doVideoPesPacket(stream *tsstream, start uint8) {
 }
 
 func (demuxer *TSDemuxer) doAudioPesPacket(stream *tsstream, start uint8) {
-    if stream.cid != TS_STREAM_AAC && stream.cid != TS_STREAM_AUDIO_MPEG1 && stream.cid != TS_STREAM_AUDIO_MPEG2 {
-        return
-    }
-
     if stream.pkg == nil {
         stream.pkg = newPacket_t(1024)
         stream.pkg.pts = stream.pes_pkg.Pts
@@ -251,16 +495,42 @@ func (demuxer *TSDemuxer) doAudioPesPacket(stream *tsstream, start uint8) {
     }
 
     if len(stream.pkg.payload) > 0 && (start == 1 || stream.pes_pkg.Pts != stream.pkg.pts) {
-        if demuxer.OnFrame != nil {
-            demuxer.OnFrame(stream.cid, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
-        }
+        demuxer.emitAudioFrame(stream, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
         stream.pkg.payload = stream.pkg.payload[:0]
     }
+    if start == 1 {
+        stream.pes_off = len(stream.pkg.payload)
+    }
     stream.pkg.payload = append(stream.pkg.payload, stream.pes_pkg.Pes_payload...)
     stream.pkg.pts = stream.pes_pkg.Pts
     stream.pkg.dts = stream.pes_pkg.Dts
 }
 
+// DVB subtitle and teletext PES packets are always bounded,
+// the whole PES payload is delivered as one frame
+func (demuxer *TSDemuxer) doPrivatePesPacket(stream *tsstream, start uint8) {
+    if start == 1 {
+        if stream.pkg != nil && len(stream.pkg.payload) > 0 {
+            demuxer.emitFrame(stream, stream.pkg.payload, stream.pkg.pts/90, stream.pkg.dts/90)
+        }
+        stream.pkg = newPacket_t(1024)
+        stream.pkg.pts = stream.pes_pkg.Pts
+        stream.pkg.dts = stream.pes_pkg.Dts
+        stream.pes_len = 0
+        if stream.pes_pkg.PES_packet_length > 0 {
+            stream.pes_len = int(stream.pes_pkg.PES_packet_length) - 3 - int(stream.pes_pkg.PES_header_data_length)
+        }
+    } else if stream.pkg == nil {
+        //wait for the first PES packet
+        return
+    }
+    stream.pkg.payload = append(stream.pkg.payload, stream.pes_pkg.Pes_payload...)
+    if stream.pes_len > 0 && len(stream.pkg.payload) >= stream.pes_len {
+        demuxer.emitFrame(stream, stream.pkg.payload[:stream.pes_len], stream.pkg.pts/90, stream.pkg.dts/90)
+        stream.pkg = nil
+    }
+}
+
 func (demuxer *TSDemuxer) splitH264Frame(stream *tsstream) bool {
     data := stream.pkg.payload
     start, sct := codec.FindStartCode(data, 0)
@@ -299,16 +569,14 @@ func (demuxer *TSDemuxer) splitH264Frame(stream *tsstream) bool {
         }
 
         if vcl > 0 && newAcessUnit {
-            if demuxer.OnFrame != nil {
-                audLen := 0
-                codec.SplitFrameWithStartCode(data[frameBeg:start], func(nalu []byte) bool {
-                    if codec.H264NaluType(nalu) == codec.H264_NAL_AUD {
-                        audLen += len(nalu)
-                    }
-                    return false
-                })
-                demuxer.OnFrame(stream.cid, data[frameBeg+audLen:start], stream.pkg.pts/90, stream.pkg.dts/90)
-            }
+            audLen := 0
+            codec.SplitFrameWithStartCode(data[frameBeg:start], func(nalu []byte) bool {
+                if codec.H264NaluType(nalu) == codec.H264_NAL_AUD {
+                    audLen += len(nalu)
+                }
+                return false
+            })
+            demuxer.emitFrame(stream, data[frameBeg+audLen:start], stream.pkg.pts/90, stream.pkg.dts/90)
             frameBeg = start
             needUpdate = true
             vcl = 0
@@ -325,6 +593,10 @@ func (demuxer *TSDemuxer) splitH264Frame(stream *tsstream) bool {
     if frameBeg == 0 {
         return needUpdate
     }
+    stream.pes_off -= frameBeg
+    if stream.pes_off < 0 {
+        stream.pes_off = 0
+    }
     copy(stream.pkg.payload, data[frameBeg:datalen])
     stream.pkg.payload = stream.pkg.payload[0 : datalen-frameBeg]
     return needUpdate
@@ -376,16 +648,14 @@ func (demuxer *TSDemuxer) splitH265Frame(stream *tsstream) bool {
         }
 
         if vcl > 0 && newAcessUnit {
-            if demuxer.OnFrame != nil {
-                audLen := 0
-                codec.SplitFrameWithStartCode(data[frameBeg:start], func(nalu []byte) bool {
-                    if codec.H265NaluType(nalu) == codec.H265_NAL_AUD {
-                        audLen = len(nalu)
-                    }
-                    return false
-                })
-                demuxer.OnFrame(stream.cid, data[frameBeg+audLen:start], stream.pkg.pts/90, stream.pkg.dts/90)
-            }
+            audLen := 0
+            codec.SplitFrameWithStartCode(data[frameBeg:start], func(nalu []byte) bool {
+                if codec.H265NaluType(nalu) == codec.H265_NAL_AUD {
+                    audLen = len(nalu)
+                }
+                return false
+            })
+            demuxer.emitFrame(stream, data[frameBeg+audLen:start], stream.pkg.pts/90, stream.pkg.dts/90)
             frameBeg = start
             needUpdate = true
             vcl = 0
@@ -403,6 +673,56 @@ func (demuxer *TSDemuxer) splitH265Frame(stream *tsstream) bool {
     if frameBeg == 0 {
         return needUpdate
     }
+    stream.pes_off -= frameBeg
+    if stream.pes_off < 0 {
+        stream.pes_off = 0
+    }
+    copy(stream.pkg.payload, data[frameBeg:datalen])
+    stream.pkg.payload = stream.pkg.payload[0 : datalen-frameBeg]
+    return needUpdate
+}
+
+// ISO/IEC 13818-2 access unit begins with sequence header,group of pictures header or picture header,
+// a new access unit is found when the next picture header,or the headers before it, follows a picture
+func (demuxer *TSDemuxer) splitMpeg2VideoFrame(stream *tsstream) bool {
+    data := stream.pkg.payload
+    start, sct := codec.FindStartCode(data, 0)
+    datalen := len(data)
+    picture := false
+    needUpdate := false
+    frameBeg := start
+    if frameBeg < 0 {
+        frameBeg = 0
+    }
+    for start >= 0 && start+int(sct) < datalen {
+        code := codec.MPEG2_START_CODE(data[start+int(sct)])
+        switch code {
+        case codec.MPEG2_SEQUENCE_HEADER_CODE, codec.MPEG2_GROUP_START_CODE, codec.MPEG2_PICTURE_START_CODE:
+            if picture {
+                demuxer.emitFrame(stream, data[frameBeg:start], stream.pkg.pts/90, stream.pkg.dts/90)
+                frameBeg = start
+                needUpdate = true
+                picture = false
+            }
+            if code == codec.MPEG2_PICTURE_START_CODE {
+                picture = true
+            }
+        }
+        end, sct2 := codec.FindStartCode(data, start+3)
+        if end < 0 {
+            break
+        }
+        start = end
+        sct = sct2
+    }
+
+    if frameBeg == 0 {
+        return needUpdate
+    }
+    stream.pes_off -= frameBeg
+    if stream.pes_off < 0 {
+        stream.pes_off = 0
+    }
     copy(stream.pkg.payload, data[frameBeg:datalen])
     stream.pkg.payload = stream.pkg.payload[0 : datalen-frameBeg]
     return needUpdate
@@ -0,0 +1,428 @@
+package mpeg2
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/yapingcat/gomedia/go-codec"
+)
+
+func makeTsPackets(frames int) [][]byte {
+	muxer := NewTSMuxer()
+	var pkts [][]byte
+	muxer.OnPacket = func(pkg []byte) {
+		pkts = append(pkts, append([]byte{}, pkg...))
+	}
+	vid := muxer.AddStream(TS_STREAM_H264)
+	aid := muxer.AddStream(TS_STREAM_AAC)
+	idr := append([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 3000)...)
+	p := append([]byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x88}, bytes.Repeat([]byte{0x11}, 800)...)
+	adts := []byte{0xff, 0xf1, 0x50, 0x80, 0x02, 0x1f, 0xfc, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	for i := 0; i < frames; i++ {
+		frame := p
+		if i%25 == 0 {
+			frame = idr
+		}
+		muxer.Write(vid, frame, uint64(i*40+100), uint64(i*40))
+		muxer.Write(aid, adts, uint64(i*40), uint64(i*40))
+	}
+	return pkts
+}
+
+func TestTSDemuxer_Input(t *testing.T) {
+	pkts := makeTsPackets(50)
+	var ts188, m2ts, ts204, lost, garbage bytes.Buffer
+	garbage.Write([]byte{0x01, 0x02, 0x47, 0x04, 0x05})
+	for i, pkg := range pkts {
+		ts188.Write(pkg)
+		m2ts.Write([]byte{0x00, 0x00, 0x01, byte(i)})
+		m2ts.Write(pkg)
+		ts204.Write(pkg)
+		ts204.Write(bytes.Repeat([]byte{0x47}, 16))
+		if i != 30 {
+			lost.Write(pkg)
+		}
+		if i == 40 {
+			garbage.Write(pkg[:100])
+		} else {
+			garbage.Write(pkg)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		wantVideo   int
+		wantAudio   int
+		wantDamaged int
+		wantResync  int
+	}{
+		{name: "188", data: ts188.Bytes(), wantVideo: 50, wantAudio: 50},
+		{name: "192-m2ts", data: m2ts.Bytes(), wantVideo: 50, wantAudio: 50},
+		{name: "204", data: ts204.Bytes(), wantVideo: 50, wantAudio: 50},
+		{name: "cc-error", data: lost.Bytes(), wantVideo: 49, wantAudio: 50, wantDamaged: 1},
+		{name: "resync", data: garbage.Bytes(), wantVideo: 49, wantAudio: 50, wantDamaged: 1, wantResync: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video, audio, damaged := 0, 0, 0
+			demuxer := NewTSDemuxer()
+			demuxer.OnFrameWithFlag = func(cid TS_STREAM_TYPE, frame []byte, pts uint64, dts uint64, isDamaged bool) {
+				if cid == TS_STREAM_H264 {
+					video++
+				} else if cid == TS_STREAM_AAC {
+					audio++
+				}
+				if isDamaged {
+					damaged++
+				}
+			}
+			if err := demuxer.Input(bytes.NewReader(tt.data)); err != nil {
+				t.Errorf("TSDemuxer.Input() error = %v", err)
+			}
+			_, resyncs := demuxer.Errors()
+			if video != tt.wantVideo || audio != tt.wantAudio || damaged != tt.wantDamaged || resyncs != tt.wantResync {
+				t.Errorf("TSDemuxer.Input() video=%d audio=%d damaged=%d resync=%d, want %d %d %d %d",
+					video, audio, damaged, resyncs, tt.wantVideo, tt.wantAudio, tt.wantDamaged, tt.wantResync)
+			}
+		})
+	}
+}
+
+func TestTSDemuxer_AudioVideoCodecs(t *testing.T) {
+	muxer := NewTSMuxer()
+	var ts bytes.Buffer
+	muxer.OnPacket = func(pkg []byte) {
+		ts.Write(pkg)
+	}
+	vid := muxer.AddStream(TS_STREAM_MPEG2_VIDEO)
+	ac3 := muxer.AddStream(TS_STREAM_AC3)
+	eac3 := muxer.AddStream(TS_STREAM_EAC3)
+	opus := muxer.AddStream(TS_STREAM_OPUS)
+	latm := muxer.AddStream(TS_STREAM_AAC_LATM)
+	// 48kHz 32kbps stereo,128 bytes
+	ac3Frame := append([]byte{0x0B, 0x77, 0x00, 0x00, 0x00, 0x40, 0x40}, bytes.Repeat([]byte{0x22}, 121)...)
+	// 48kHz 6 blocks stereo,128 bytes
+	eac3Frame := append([]byte{0x0B, 0x77, 0x00, 0x3F, 0x34, 0x80, 0x00}, bytes.Repeat([]byte{0x33}, 121)...)
+	// CELT 20ms,the packet is longer than 255 bytes
+	opusPacket := append([]byte{0xFC}, bytes.Repeat([]byte{0x44}, 300)...)
+	loas := []byte{0x56, 0xE0, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05}
+	seqHeader := []byte{0x00, 0x00, 0x01, 0xB3, 0x2D, 0x01, 0xE0, 0x24, 0xFF, 0xFF, 0xE0, 0x00}
+	for i := 0; i < 30; i++ {
+		var picture []byte
+		pictureType := byte(codec.MPEG2_P_PICTURE)
+		if i%10 == 0 {
+			pictureType = codec.MPEG2_I_PICTURE
+			picture = append(picture, seqHeader...)
+			picture = append(picture, 0x00, 0x00, 0x01, 0xB8, 0x00, 0x00, 0x00, 0x00)
+		}
+		picture = append(picture, 0x00, 0x00, 0x01, 0x00, 0x00, pictureType<<3, 0x00, 0x00)
+		picture = append(picture, 0x00, 0x00, 0x01, 0x01)
+		picture = append(picture, bytes.Repeat([]byte{0x55}, 500)...)
+		muxer.Write(vid, picture, uint64(i*40+80), uint64(i*40))
+		muxer.Write(ac3, append(append([]byte{}, ac3Frame...), ac3Frame...), uint64(i*64), uint64(i*64))
+		muxer.Write(eac3, eac3Frame, uint64(i*32), uint64(i*32))
+		muxer.Write(opus, opusPacket, uint64(i*20), uint64(i*20))
+		muxer.Write(latm, loas, uint64(i*20), uint64(i*20))
+	}
+
+	type frameInfo struct {
+		count   int
+		lastPts uint64
+	}
+	frames := make(map[TS_STREAM_TYPE]*frameInfo)
+	keyframes := 0
+	demuxer := NewTSDemuxer()
+	demuxer.OnFrame = func(cid TS_STREAM_TYPE, frame []byte, pts uint64, dts uint64) {
+		if frames[cid] == nil {
+			frames[cid] = &frameInfo{}
+		}
+		frames[cid].count++
+		frames[cid].lastPts = pts
+		switch cid {
+		case TS_STREAM_MPEG2_VIDEO:
+			if codec.IsMpeg2VideoKeyFrame(frame) {
+				keyframes++
+				if w, h := codec.GetMpeg2VideoResolution(frame); w != 720 || h != 480 {
+					t.Errorf("GetMpeg2VideoResolution() = %dx%d, want 720x480", w, h)
+				}
+			}
+		case TS_STREAM_AC3:
+			if !bytes.Equal(frame, ac3Frame) {
+				t.Errorf("ac3 frame mismatch")
+			}
+		case TS_STREAM_EAC3:
+			if !bytes.Equal(frame, eac3Frame) {
+				t.Errorf("eac3 frame mismatch")
+			}
+		case TS_STREAM_OPUS:
+			if !bytes.Equal(frame, opusPacket) {
+				t.Errorf("opus packet mismatch")
+			}
+		case TS_STREAM_AAC_LATM:
+			if !bytes.Equal(frame, loas) {
+				t.Errorf("loas frame mismatch")
+			}
+		}
+	}
+	if err := demuxer.Input(bytes.NewReader(ts.Bytes())); err != nil {
+		t.Fatalf("TSDemuxer.Input() error = %v", err)
+	}
+	tests := []struct {
+		cid       TS_STREAM_TYPE
+		wantCount int
+		wantPts   uint64
+	}{
+		{cid: TS_STREAM_MPEG2_VIDEO, wantCount: 30, wantPts: 29*40 + 80},
+		{cid: TS_STREAM_AC3, wantCount: 60, wantPts: 29*64 + 32},
+		{cid: TS_STREAM_EAC3, wantCount: 30, wantPts: 29 * 32},
+		{cid: TS_STREAM_OPUS, wantCount: 30, wantPts: 29 * 20},
+		{cid: TS_STREAM_AAC_LATM, wantCount: 30, wantPts: 29 * 20},
+	}
+	for _, tt := range tests {
+		got := frames[tt.cid]
+		if got == nil || got.count != tt.wantCount || got.lastPts != tt.wantPts {
+			t.Errorf("stream 0x%x got %+v, want count=%d pts=%d", int(tt.cid), got, tt.wantCount, tt.wantPts)
+		}
+	}
+	if keyframes != 3 {
+		t.Errorf("mpeg2 video keyframes = %d, want 3", keyframes)
+	}
+}
+
+func TestTSDemuxer_ReadPacket(t *testing.T) {
+	muxer := NewTSMuxer()
+	var ts bytes.Buffer
+	muxer.OnPacket = func(pkg []byte) {
+		ts.Write(pkg)
+	}
+	vid := muxer.AddStream(TS_STREAM_H264)
+	aid := muxer.AddStream(TS_STREAM_AAC)
+	sps := []byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x64, 0x00, 0x28, 0xAC, 0x2C, 0xA4, 0x01, 0xE0, 0x08, 0x9F, 0x97, 0xFF, 0x00, 0x01, 0x00, 0x01, 0x52, 0x02, 0x02, 0x02, 0x80, 0x00,
+		0x01, 0xF4, 0x80, 0x00, 0x75, 0x30, 0x70, 0x10, 0x00, 0x16, 0xE3, 0x60, 0x00, 0x08, 0x95, 0x45, 0xF8, 0xC7, 0x07, 0x68, 0x58, 0xB4, 0x48}
+	idr := append(append([]byte{}, sps...), append([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 3000)...)...)
+	p := append([]byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x88}, bytes.Repeat([]byte{0x11}, 800)...)
+	adts := []byte{0xff, 0xf1, 0x50, 0x80, 0x02, 0x1f, 0xfc, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	for i := 0; i < 30; i++ {
+		frame := p
+		if i%10 == 2 {
+			frame = idr
+		}
+		muxer.Write(vid, frame, uint64(i*40+100), uint64(i*40))
+		muxer.Write(aid, adts, uint64(i*40), uint64(i*40))
+	}
+
+	demuxer := NewTSDemuxerWithReader(bytes.NewReader(ts.Bytes()))
+	var programs []*TSProgramInfo
+	demuxer.OnStreamsChanged = func(program *TSProgramInfo) {
+		programs = append(programs, program)
+	}
+	video, audio, keyframes := 0, 0, 0
+	for {
+		pkt, err := demuxer.ReadPacket()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("TSDemuxer.ReadPacket() error = %v", err)
+		}
+		if len(programs) != 1 {
+			t.Fatalf("OnStreamsChanged is not called before the first packet")
+		}
+		if pkt.ProgramNumber != 1 {
+			t.Errorf("Packet.ProgramNumber = %d, want 1", pkt.ProgramNumber)
+		}
+		switch pkt.PID {
+		case vid:
+			video++
+			if pkt.Codec != codec.CODECID_VIDEO_H264 {
+				t.Errorf("video Packet.Codec = %s", codec.CodecString(pkt.Codec))
+			}
+			if pkt.KeyFrame {
+				keyframes++
+			}
+		case aid:
+			audio++
+			if pkt.Codec != codec.CODECID_AUDIO_AAC || !pkt.KeyFrame {
+				t.Errorf("audio Packet.Codec = %s,KeyFrame = %v", codec.CodecString(pkt.Codec), pkt.KeyFrame)
+			}
+		}
+	}
+	if video != 30 || audio != 30 || keyframes != 3 {
+		t.Errorf("TSDemuxer.ReadPacket() video=%d audio=%d keyframes=%d, want 30 30 3", video, audio, keyframes)
+	}
+	if len(programs) != 1 || len(programs[0].Streams) != 2 {
+		t.Fatalf("OnStreamsChanged() got %d programs", len(programs))
+	}
+	v, a := programs[0].Streams[0], programs[0].Streams[1]
+	if v.PID != vid || v.Width != 1920 || v.Height != 1080 {
+		t.Errorf("video stream info = %+v, want 1920x1080", v)
+	}
+	if a.PID != aid || a.SampleRate != 44100 || a.Channels != 2 {
+		t.Errorf("audio stream info = %+v, want 44100Hz 2 channels", a)
+	}
+}
+
+func TestTSDemuxer_PrivateStream(t *testing.T) {
+	subtitling := (&SubtitlingDescriptor{Subtitles: []SubtitlingInfo{
+		{Language: "eng", SubtitlingType: 0x10, CompositionPageId: 1, AncillaryPageId: 1},
+		{Language: "ger", SubtitlingType: 0x20, CompositionPageId: 2, AncillaryPageId: 2},
+	}}).Encode()
+	teletext := (&TeletextDescriptor{Pages: []TeletextPage{
+		{Language: "fra", TeletextType: 2, MagazineNumber: 1, PageNumber: 0x88},
+	}}).Encode()
+	sources := []*PrivateStream{
+		NewPrivateStream(0x150, []Descriptor{subtitling}),
+		NewPrivateStream(0x151, []Descriptor{teletext}),
+	}
+	subtitle := append([]byte{0x20, 0x00, 0x0F, 0x10, 0x00, 0x01, 0x00, 0x02, 0x01, 0x02}, 0xFF)
+	ttx := append([]byte{0x10}, bytes.Repeat([]byte{0x02, 0x2C, 0xE4, 0x40}, 40)...)
+	payloads := [][]byte{subtitle, ttx}
+
+	mux := func(streams []*PrivateStream) []byte {
+		muxer := NewTSMuxer()
+		var ts bytes.Buffer
+		muxer.OnPacket = func(pkg []byte) {
+			ts.Write(pkg)
+		}
+		vid := muxer.AddStream(TS_STREAM_H264)
+		pids := make([]uint16, len(streams))
+		for i, s := range streams {
+			pids[i] = muxer.AddPrivateStream(s)
+		}
+		idr := append([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 500)...)
+		for i := 0; i < 5; i++ {
+			muxer.Write(vid, idr, uint64(i*40), uint64(i*40))
+			for j, pid := range pids {
+				muxer.Write(pid, payloads[j], uint64(i*40), uint64(i*40))
+			}
+		}
+		return ts.Bytes()
+	}
+	demux := func(ts []byte) (map[uint16]*PrivateStream, map[uint16]int) {
+		streams := make(map[uint16]*PrivateStream)
+		counts := make(map[uint16]int)
+		demuxer := NewTSDemuxer()
+		demuxer.OnPrivateFrame = func(stream *PrivateStream, frame []byte, pts uint64, dts uint64) {
+			streams[stream.PID] = stream
+			counts[stream.PID]++
+			want := subtitle
+			if stream.Codec == TS_PRIVATE_DVB_TELETEXT {
+				want = ttx
+			}
+			if !bytes.Equal(frame, want) {
+				t.Errorf("pid 0x%x frame = %x", stream.PID, frame)
+			}
+		}
+		if err := demuxer.Input(bytes.NewReader(ts)); err != nil {
+			t.Fatalf("TSDemuxer.Input() error = %v", err)
+		}
+		return streams, counts
+	}
+
+	streams, counts := demux(mux(sources))
+	//remux the demuxed streams,pid and descriptors are kept
+	remuxed, remuxedCounts := demux(mux([]*PrivateStream{streams[0x150], streams[0x151]}))
+	tests := []struct {
+		pid       uint16
+		codec     TS_PRIVATE_CODEC
+		languages []string
+		desc      Descriptor
+	}{
+		{pid: 0x150, codec: TS_PRIVATE_DVB_SUBTITLE, languages: []string{"eng", "ger"}, desc: subtitling},
+		{pid: 0x151, codec: TS_PRIVATE_DVB_TELETEXT, languages: []string{"fra"}, desc: teletext},
+	}
+	for _, result := range []map[uint16]*PrivateStream{streams, remuxed} {
+		for _, tt := range tests {
+			s := result[tt.pid]
+			if s == nil {
+				t.Fatalf("pid 0x%x is not demuxed", tt.pid)
+			}
+			if s.Codec != tt.codec || strings.Join(s.Languages, ",") != strings.Join(tt.languages, ",") {
+				t.Errorf("pid 0x%x = %v %v", tt.pid, s.Codec, s.Languages)
+			}
+			if len(s.Descriptors) != 1 || s.Descriptors[0].Tag != tt.desc.Tag || !bytes.Equal(s.Descriptors[0].Data, tt.desc.Data) {
+				t.Errorf("pid 0x%x descriptors = %+v", tt.pid, s.Descriptors)
+			}
+		}
+	}
+	for _, pid := range []uint16{0x150, 0x151} {
+		if counts[pid] != 5 || remuxedCounts[pid] != 5 {
+			t.Errorf("pid 0x%x frames = %d,%d", pid, counts[pid], remuxedCounts[pid])
+		}
+	}
+}
+
+func TestTSDemuxer_StreamTypes(t *testing.T) {
+	muxer := NewTSMuxer()
+	var ts bytes.Buffer
+	muxer.OnPacket = func(pkg []byte) {
+		ts.Write(pkg)
+	}
+	latm := muxer.AddStream(TS_STREAM_AAC_LATM)
+	ac3 := muxer.AddStream(TS_STREAM_AC3)
+	private := muxer.AddStream(TS_STREAM_AC3)
+	//stream_type 0x81 without registration descriptor is user private
+	muxer.pat.pmts[0].streams[2].descriptors = nil
+	loas := []byte{0x56, 0xE0, 0x05, 0x01, 0x02, 0x03, 0x04, 0x05}
+	ac3Frame := append([]byte{0x0B, 0x77, 0x00, 0x00, 0x00, 0x40, 0x40}, bytes.Repeat([]byte{0x22}, 121)...)
+	for i := 0; i < 5; i++ {
+		muxer.Write(latm, loas, uint64(i*20), uint64(i*20))
+		frame := ac3Frame
+		if i == 2 {
+			//the second syncframe is truncated
+			frame = append(append([]byte{}, ac3Frame...), ac3Frame[:60]...)
+		}
+		muxer.Write(ac3, frame, uint64(i*32), uint64(i*32))
+		muxer.Write(private, ac3Frame, uint64(i*32), uint64(i*32))
+	}
+
+	var program *TSProgramInfo
+	var errs []uint16
+	frames := make(map[TS_STREAM_TYPE]int)
+	demuxer := NewTSDemuxer()
+	demuxer.OnStreamsChanged = func(info *TSProgramInfo) {
+		program = info
+	}
+	demuxer.OnFrame = func(cid TS_STREAM_TYPE, frame []byte, pts uint64, dts uint64) {
+		frames[cid]++
+	}
+	demuxer.OnError = func(pid uint16, err error) {
+		errs = append(errs, pid)
+	}
+	if err := demuxer.Input(bytes.NewReader(ts.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if program == nil || len(program.Streams) != 2 {
+		t.Fatalf("program = %+v", program)
+	}
+	if program.Streams[0].Codec != codec.CODECID_AUDIO_AAC_LATM || program.Streams[1].Codec != codec.CODECID_AUDIO_AC3 {
+		t.Errorf("codecs = %s %s", codec.CodecString(program.Streams[0].Codec), codec.CodecString(program.Streams[1].Codec))
+	}
+	if frames[TS_STREAM_AAC_LATM] != 5 || frames[TS_STREAM_AC3] != 5 {
+		t.Errorf("frames = %v", frames)
+	}
+	if len(errs) != 1 || errs[0] != ac3 {
+		t.Errorf("errors of pid %v, want [%d]", errs, ac3)
+	}
+}
+
+func TestTSStream_ProbeMalformed(t *testing.T) {
+	frames := map[TS_STREAM_TYPE][]byte{
+		TS_STREAM_H264: {0x00, 0x00, 0x00, 0x01, 0x67, 0x64, 0x00, 0x28, 0xAC},
+		TS_STREAM_H265: {0x00, 0x00, 0x00, 0x01, 0x42, 0x01, 0x01, 0x01, 0x60},
+	}
+	for cid, frame := range frames {
+		stream := &tsstream{cid: cid}
+		stream.probe(frame)
+		if stream.probed {
+			t.Errorf("stream %d probed by a truncated sps", cid)
+		}
+	}
+	stream := &tsstream{cid: TS_STREAM_AAC}
+	stream.probe([]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06})
+	if stream.info.SampleRate != 0 {
+		t.Errorf("sample rate %d probed from a frame without adts syncword", stream.info.SampleRate)
+	}
+}
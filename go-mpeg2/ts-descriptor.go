@@ -0,0 +1,356 @@
+package mpeg2
+
+import (
+    "errors"
+    "fmt"
+    "os"
+)
+
+// descriptor tags used by ISO/IEC 13818-1 and ETSI EN 300 468
+const (
+    TS_DESCRIPTOR_REGISTRATION     uint8 = 0x05
+    TS_DESCRIPTOR_ISO_639_LANGUAGE uint8 = 0x0A
+    TS_DESCRIPTOR_VBI_TELETEXT     uint8 = 0x46
+    TS_DESCRIPTOR_TELETEXT         uint8 = 0x56
+    TS_DESCRIPTOR_SUBTITLING       uint8 = 0x59
+    TS_DESCRIPTOR_AC3              uint8 = 0x6A
+    TS_DESCRIPTOR_ENHANCED_AC3     uint8 = 0x7A
+    TS_DESCRIPTOR_EXTENSION        uint8 = 0x7F
+)
+
+// descriptor_tag_extension of DVB extension_descriptor,defined by "Encapsulation of Opus in MPEG-2 Transport Streams"
+const (
+    TS_EXTENSION_DESCRIPTOR_OPUS uint8 = 0x80
+)
+
+// codec carried by a PES private data stream(stream_type 0x06),
+// the stream type alone is not enough, it is detected by descriptors in PMT
+type TS_PRIVATE_CODEC int
+
+const (
+    TS_PRIVATE_UNKNOWN TS_PRIVATE_CODEC = iota
+    TS_PRIVATE_DVB_SUBTITLE
+    TS_PRIVATE_DVB_TELETEXT
+)
+
+func (c TS_PRIVATE_CODEC) String() string {
+    switch c {
+    case TS_PRIVATE_DVB_SUBTITLE:
+        return "DVB_SUBTITLE"
+    case TS_PRIVATE_DVB_TELETEXT:
+        return "DVB_TELETEXT"
+    default:
+        return "UNKNOWN"
+    }
+}
+
+// descriptor() {
+//     descriptor_tag      8 uimsbf
+//     descriptor_length   8 uimsbf
+//     for (i = 0; i < N; i++) {
+//         data_byte       8 bslbf
+//     }
+// }
+
+type Descriptor struct {
+    Tag  uint8
+    Data []byte
+}
+
+func (desc *Descriptor) PrettyPrint(file *os.File) {
+    file.WriteString(fmt.Sprintf("    descriptor_tag:0x%02x\n", desc.Tag))
+    file.WriteString(fmt.Sprintf("    descriptor_length:%d\n", len(desc.Data)))
+}
+
+func (desc *Descriptor) Encode() []byte {
+    buf := make([]byte, 2+len(desc.Data))
+    buf[0] = desc.Tag
+    buf[1] = uint8(len(desc.Data))
+    copy(buf[2:], desc.Data)
+    return buf
+}
+
+func DecodeDescriptors(data []byte) ([]Descriptor, error) {
+    var descs []Descriptor
+    for len(data) > 0 {
+        if len(data) < 2 || len(data) < 2+int(data[1]) {
+            return descs, errors.New("descriptor length is out of range")
+        }
+        desc := Descriptor{Tag: data[0], Data: make([]byte, data[1])}
+        copy(desc.Data, data[2:2+int(data[1])])
+        descs = append(descs, desc)
+        data = data[2+int(data[1]):]
+    }
+    return descs, nil
+}
+
+func EncodeDescriptors(descs []Descriptor) []byte {
+    var buf []byte
+    for i := range descs {
+        buf = append(buf, descs[i].Encode()...)
+    }
+    return buf
+}
+
+// registration_descriptor() {
+//     descriptor_tag                8 uimsbf
+//     descriptor_length             8 uimsbf
+//     format_identifier            32 uimsbf
+//     ......
+// }
+func RegistrationDescriptor(format string) Descriptor {
+    return Descriptor{Tag: TS_DESCRIPTOR_REGISTRATION, Data: []byte(format)}
+}
+
+// extension_descriptor() {
+//     descriptor_tag                8 uimsbf   0x7F
+//     descriptor_length             8 uimsbf
+//     descriptor_tag_extension      8 uimsbf   0x80
+//     channel_config_code           8 uimsbf
+// }
+// channel_config_code 0x01~0x08 is the channel count with Vorbis channel order
+func OpusAudioDescriptor(channels uint8) Descriptor {
+    return Descriptor{Tag: TS_DESCRIPTOR_EXTENSION, Data: []byte{TS_EXTENSION_DESCRIPTOR_OPUS, channels}}
+}
+
+func hasRegistration(descs []Descriptor, format string) bool {
+    for _, desc := range descs {
+        if desc.Tag == TS_DESCRIPTOR_REGISTRATION && len(desc.Data) >= 4 && string(desc.Data[:4]) == format {
+            return true
+        }
+    }
+    return false
+}
+
+// audio codecs carried by PES private data stream(stream_type 0x06) in DVB system,
+// returns TS_STREAM_PRIVATE if the stream is not AC-3/E-AC-3/Opus
+func privateStreamType(descs []Descriptor) TS_STREAM_TYPE {
+    switch {
+    case FindDescriptor(descs, TS_DESCRIPTOR_ENHANCED_AC3) != nil || hasRegistration(descs, "EAC3"):
+        return TS_STREAM_EAC3
+    case FindDescriptor(descs, TS_DESCRIPTOR_AC3) != nil || hasRegistration(descs, "AC-3"):
+        return TS_STREAM_AC3
+    case hasRegistration(descs, "Opus"):
+        return TS_STREAM_OPUS
+    default:
+        return TS_STREAM_PRIVATE
+    }
+}
+
+// stream_type 0x81 is AC-3 only with the registration descriptor "AC-3"(ATSC A/52 Annex A),
+// the AC-3 audio descriptor of ATSC(0x81) or DVB(0x6A) is also accepted
+func isAtscAC3(descs []Descriptor) bool {
+    return hasRegistration(descs, "AC-3") || FindDescriptor(descs, 0x81) != nil || FindDescriptor(descs, TS_DESCRIPTOR_AC3) != nil
+}
+
+func FindDescriptor(descs []Descriptor, tag uint8) *Descriptor {
+    for i := range descs {
+        if descs[i].Tag == tag {
+            return &descs[i]
+        }
+    }
+    return nil
+}
+
+// ISO_639_language_descriptor() {
+//     descriptor_tag                8 uimsbf
+//     descriptor_length             8 uimsbf
+//     for (i = 0; i < N; i++) {
+//         ISO_639_language_code    24 bslbf
+//         audio_type                8 bslbf
+//     }
+// }
+
+type ISO639Language struct {
+    Language  string
+    AudioType uint8
+}
+
+type ISO639LanguageDescriptor struct {
+    Languages []ISO639Language
+}
+
+func (desc *ISO639LanguageDescriptor) Decode(data []byte) error {
+    if len(data)%4 != 0 {
+        return errors.New("illegal ISO_639_language_descriptor")
+    }
+    desc.Languages = desc.Languages[:0]
+    for i := 0; i+4 <= len(data); i += 4 {
+        desc.Languages = append(desc.Languages, ISO639Language{
+            Language:  string(data[i : i+3]),
+            AudioType: data[i+3],
+        })
+    }
+    return nil
+}
+
+func (desc *ISO639LanguageDescriptor) Encode() Descriptor {
+    data := make([]byte, 0, 4*len(desc.Languages))
+    for _, lang := range desc.Languages {
+        data = append(data, languageCode(lang.Language)...)
+        data = append(data, lang.AudioType)
+    }
+    return Descriptor{Tag: TS_DESCRIPTOR_ISO_639_LANGUAGE, Data: data}
+}
+
+// ETSI EN 300 468 6.2.41 Subtitling descriptor
+// subtitling_descriptor(){
+//     descriptor_tag                8 uimsbf
+//     descriptor_length             8 uimsbf
+//     for (i= 0;i<N;I++){
+//         ISO_639_language_code    24 bslbf
+//         subtitling_type           8 bslbf
+//         composition_page_id      16 bslbf
+//         ancillary_page_id        16 bslbf
+//     }
+// }
+
+type SubtitlingInfo struct {
+    Language          string
+    SubtitlingType    uint8
+    CompositionPageId uint16
+    AncillaryPageId   uint16
+}
+
+type SubtitlingDescriptor struct {
+    Subtitles []SubtitlingInfo
+}
+
+func (desc *SubtitlingDescriptor) Decode(data []byte) error {
+    if len(data)%8 != 0 {
+        return errors.New("illegal subtitling_descriptor")
+    }
+    desc.Subtitles = desc.Subtitles[:0]
+    for i := 0; i+8 <= len(data); i += 8 {
+        desc.Subtitles = append(desc.Subtitles, SubtitlingInfo{
+            Language:          string(data[i : i+3]),
+            SubtitlingType:    data[i+3],
+            CompositionPageId: uint16(data[i+4])<<8 | uint16(data[i+5]),
+            AncillaryPageId:   uint16(data[i+6])<<8 | uint16(data[i+7]),
+        })
+    }
+    return nil
+}
+
+func (desc *SubtitlingDescriptor) Encode() Descriptor {
+    data := make([]byte, 0, 8*len(desc.Subtitles))
+    for _, sub := range desc.Subtitles {
+        data = append(data, languageCode(sub.Language)...)
+        data = append(data, sub.SubtitlingType)
+        data = append(data, uint8(sub.CompositionPageId>>8), uint8(sub.CompositionPageId))
+        data = append(data, uint8(sub.AncillaryPageId>>8), uint8(sub.AncillaryPageId))
+    }
+    return Descriptor{Tag: TS_DESCRIPTOR_SUBTITLING, Data: data}
+}
+
+// ETSI EN 300 468 6.2.43 Teletext descriptor
+// teletext_descriptor(){
+//     descriptor_tag                8 uimsbf
+//     descriptor_length             8 uimsbf
+//     for (i=0;i<N;i++){
+//         ISO_639_language_code    24 bslbf
+//         teletext_type             5 uimsbf
+//         teletext_magazine_number  3 uimsbf
+//         teletext_page_number      8 uimsbf
+//     }
+// }
+
+type TeletextPage struct {
+    Language       string
+    TeletextType   uint8
+    MagazineNumber uint8
+    PageNumber     uint8
+}
+
+type TeletextDescriptor struct {
+    Tag   uint8 //TS_DESCRIPTOR_TELETEXT or TS_DESCRIPTOR_VBI_TELETEXT
+    Pages []TeletextPage
+}
+
+func (desc *TeletextDescriptor) Decode(data []byte) error {
+    if len(data)%5 != 0 {
+        return errors.New("illegal teletext_descriptor")
+    }
+    desc.Pages = desc.Pages[:0]
+    for i := 0; i+5 <= len(data); i += 5 {
+        desc.Pages = append(desc.Pages, TeletextPage{
+            Language:       string(data[i : i+3]),
+            TeletextType:   data[i+3] >> 3,
+            MagazineNumber: data[i+3] & 0x07,
+            PageNumber:     data[i+4],
+        })
+    }
+    return nil
+}
+
+func (desc *TeletextDescriptor) Encode() Descriptor {
+    data := make([]byte, 0, 5*len(desc.Pages))
+    for _, page := range desc.Pages {
+        data = append(data, languageCode(page.Language)...)
+        data = append(data, page.TeletextType<<3|page.MagazineNumber&0x07, page.PageNumber)
+    }
+    tag := desc.Tag
+    if tag == 0 {
+        tag = TS_DESCRIPTOR_TELETEXT
+    }
+    return Descriptor{Tag: tag, Data: data}
+}
+
+func languageCode(lang string) []byte {
+    code := []byte{' ', ' ', ' '}
+    copy(code, lang)
+    return code
+}
+
+// PES private data stream(stream_type 0x06) found in PMT
+type PrivateStream struct {
+    PID         uint16
+    Codec       TS_PRIVATE_CODEC
+    Languages   []string
+    Descriptors []Descriptor
+}
+
+func NewPrivateStream(pid uint16, descs []Descriptor) *PrivateStream {
+    stream := &PrivateStream{
+        PID:         pid,
+        Codec:       TS_PRIVATE_UNKNOWN,
+        Descriptors: descs,
+    }
+    for _, desc := range descs {
+        switch desc.Tag {
+        case TS_DESCRIPTOR_SUBTITLING:
+            stream.Codec = TS_PRIVATE_DVB_SUBTITLE
+            sub := &SubtitlingDescriptor{}
+            if sub.Decode(desc.Data) == nil {
+                for _, s := range sub.Subtitles {
+                    stream.addLanguage(s.Language)
+                }
+            }
+        case TS_DESCRIPTOR_TELETEXT, TS_DESCRIPTOR_VBI_TELETEXT:
+            stream.Codec = TS_PRIVATE_DVB_TELETEXT
+            ttx := &TeletextDescriptor{}
+            if ttx.Decode(desc.Data) == nil {
+                for _, p := range ttx.Pages {
+                    stream.addLanguage(p.Language)
+                }
+            }
+        case TS_DESCRIPTOR_ISO_639_LANGUAGE:
+            lang := &ISO639LanguageDescriptor{}
+            if lang.Decode(desc.Data) == nil {
+                for _, l := range lang.Languages {
+                    stream.addLanguage(l.Language)
+                }
+            }
+        }
+    }
+    return stream
+}
+
+func (stream *PrivateStream) addLanguage(lang string) {
+    for _, l := range stream.Languages {
+        if l == lang {
+            return
+        }
+    }
+    stream.Languages = append(stream.Languages, lang)
+}
@@ -7,9 +7,10 @@ import (
 )
 
 type pes_stream struct {
-    pid        uint16
-    cc         uint8
-    streamtype TS_STREAM_TYPE
+    pid         uint16
+    cc          uint8
+    streamtype  TS_STREAM_TYPE
+    descriptors []Descriptor
 }
 
 func NewPESStream(pid uint16, cid TS_STREAM_TYPE) *pes_stream {
@@ -40,6 +41,25 @@ func NewTablePmt() *table_pmt {
     }
 }
 
+func (pmt *table_pmt) pcrStreamType() TS_STREAM_TYPE {
+    for _, stream := range pmt.streams {
+        if stream.pid == pmt.pcr_pid {
+            return stream.streamtype
+        }
+    }
+    return TS_STREAM_PRIVATE
+}
+
+// video stream is preferred to carry PCR,subtitle stream is the last choice
+func pcrPriority(cid TS_STREAM_TYPE) int {
+    if isVideoStream(cid) {
+        return 2
+    } else if isAudioStream(cid) {
+        return 1
+    }
+    return 0
+}
+
 type table_pat struct {
     cc             uint8
     version_number uint8
@@ -73,6 +93,29 @@ func NewTSMuxer() *TSMuxer {
 }
 
 func (mux *TSMuxer) AddStream(cid TS_STREAM_TYPE) uint16 {
+    return mux.AddStreamWithDescriptor(cid)
+}
+
+// descriptors are written into the ES_info loop of PMT,
+// for TS_STREAM_OPUS registration descriptor and stereo OpusAudioDescriptor are added if they are missing,
+// for TS_STREAM_AC3 registration descriptor "AC-3" is added if it is missing
+func (mux *TSMuxer) AddStreamWithDescriptor(cid TS_STREAM_TYPE, descs ...Descriptor) uint16 {
+    if cid == TS_STREAM_AC3 && !isAtscAC3(descs) {
+        descs = append([]Descriptor{RegistrationDescriptor("AC-3")}, descs...)
+    }
+    if cid == TS_STREAM_OPUS {
+        if !hasRegistration(descs, "Opus") {
+            descs = append([]Descriptor{RegistrationDescriptor("Opus")}, descs...)
+        }
+        if FindDescriptor(descs, TS_DESCRIPTOR_EXTENSION) == nil {
+            descs = append(descs, OpusAudioDescriptor(2))
+        }
+    }
+    return mux.addStream(0, cid, descs)
+}
+
+// the pid is allocated by muxer if pid is 0
+func (mux *TSMuxer) addStream(pid uint16, cid TS_STREAM_TYPE, descs []Descriptor) uint16 {
     if mux.pat == nil {
         mux.pat = NewTablePat()
     }
@@ -83,15 +126,49 @@ func (mux *TSMuxer) AddStream(cid TS_STREAM_TYPE) uint16 {
         mux.pmt_pid++
         mux.pat.pmts = append(mux.pat.pmts, tmppmt)
     }
-    sid := mux.stream_pid
-    tmpstream := NewPESStream(sid, cid)
-    mux.stream_pid++
+    if pid == 0 {
+        for mux.pidInUse(mux.stream_pid) {
+            mux.stream_pid++
+        }
+        pid = mux.stream_pid
+        mux.stream_pid++
+    }
+    tmpstream := NewPESStream(pid, cid)
+    tmpstream.descriptors = descs
     mux.pat.pmts[0].streams = append(mux.pat.pmts[0].streams, tmpstream)
-    return sid
+    return pid
+}
+
+func (mux *TSMuxer) pidInUse(pid uint16) bool {
+    if mux.pat == nil {
+        return false
+    }
+    for _, pmt := range mux.pat.pmts {
+        if pmt.pid == pid {
+            return true
+        }
+        for _, stream := range pmt.streams {
+            if stream.pid == pid {
+                return true
+            }
+        }
+    }
+    return false
+}
+
+// passthrough DVB subtitle/teletext stream from TSDemuxer.OnPrivateFrame,
+// the pid of the source stream is kept if it is not used by the other streams
+func (mux *TSMuxer) AddPrivateStream(stream *PrivateStream) uint16 {
+    pid := uint16(0)
+    if stream.PID >= 0x20 && stream.PID < 0x1FFF && stream.PID != mux.pmt_pid && !mux.pidInUse(stream.PID) {
+        pid = stream.PID
+    }
+    return mux.addStream(pid, TS_STREAM_PRIVATE, stream.Descriptors)
 }
 
 /// Muxer audio/video stream data
 /// pid: stream id by AddStream
+/// data: one access unit,one opus packet for TS_STREAM_OPUS,one or more syncframes for TS_STREAM_AC3/TS_STREAM_EAC3
 /// pts: audio/video stream timestamp in ms
 /// dts: audio/video stream timestamp in ms
 func (mux *TSMuxer) Write(pid uint16, data []byte, pts uint64, dts uint64) error {
@@ -109,7 +186,7 @@ func (mux *TSMuxer) Write(pid uint16, data []byte, pts uint64, dts uint64) error
     if whichpmt == nil || whichstream == nil {
         return errors.New("not Found pid stream")
     }
-    if whichpmt.pcr_pid == 0 || (findPESIDByStreamType(whichstream.streamtype) == PES_STREAM_VIDEO && whichpmt.pcr_pid != pid) {
+    if whichpmt.pcr_pid == 0 || pcrPriority(whichstream.streamtype) > pcrPriority(whichpmt.pcrStreamType()) {
         whichpmt.pcr_pid = pid
     }
 
@@ -163,8 +240,11 @@ func (mux *TSMuxer) Write(pid uint16, data []byte, pts uint64, dts uint64) error
             for _, stream := range pmt.streams {
                 var sp StreamPair
                 sp.StreamType = uint8(stream.streamtype)
+                if stream.streamtype == TS_STREAM_OPUS {
+                    sp.StreamType = uint8(TS_STREAM_PRIVATE)
+                }
                 sp.Elementary_PID = stream.pid
-                sp.ES_Info_Length = 0
+                sp.Descriptors = stream.descriptors
                 tmppmt.Streams = append(tmppmt.Streams, sp)
             }
             mux.writePmt(tmppmt, pmt)
@@ -177,6 +257,10 @@ func (mux *TSMuxer) Write(pid uint16, data []byte, pts uint64, dts uint64) error
         flag = codec.IsH264IDRFrame(data)
     case TS_STREAM_H265:
         flag = codec.IsH265IDRFrame(data)
+    case TS_STREAM_MPEG2_VIDEO:
+        flag = codec.IsMpeg2VideoKeyFrame(data)
+    case TS_STREAM_OPUS:
+        data = append(packOpusControlHeader(len(data)), data...)
     }
 
     mux.writePES(whichstream, whichpmt, data, pts*90, dts*90, flag, withaud)
@@ -264,14 +348,6 @@ func (mux *TSMuxer) writePES(pes *pes_stream, pmt *table_pmt, data []byte, pts u
         var pespkg *PesPacket = nil
         if firstPesPacket {
             oldheadlen := headlen
-            headlen += 19
-            if !withaud && pes.streamtype == TS_STREAM_H264 {
-                headlen += 6
-                payload = append(payload, H264_AUD_NALU...)
-            } else if !withaud && pes.streamtype == TS_STREAM_H265 {
-                payload = append(payload, H265_AUD_NALU...)
-                headlen += 7
-            }
             pespkg = NewPesPacket()
             pespkg.PTS_DTS_flags = 0x03
             pespkg.PES_header_data_length = 10
@@ -281,6 +357,23 @@ func (mux *TSMuxer) writePES(pes *pes_stream, pmt *table_pmt, data []byte, pts u
             if idr_flag {
                 pespkg.Data_alignment_indicator = 1
             }
+            if pes.streamtype == TS_STREAM_PRIVATE {
+                // ETSI EN 300 743/EN 300 472, PTS only and aligned PES
+                pespkg.PTS_DTS_flags = 0x02
+                pespkg.PES_header_data_length = 5
+                pespkg.Data_alignment_indicator = 1
+                if NewPrivateStream(pes.pid, pes.descriptors).Codec == TS_PRIVATE_DVB_TELETEXT {
+                    pespkg.PES_header_data_length = 0x24
+                }
+            }
+            headlen += 9 + int(pespkg.PES_header_data_length)
+            if !withaud && pes.streamtype == TS_STREAM_H264 {
+                headlen += 6
+                payload = append(payload, H264_AUD_NALU...)
+            } else if !withaud && pes.streamtype == TS_STREAM_H265 {
+                payload = append(payload, H265_AUD_NALU...)
+                headlen += 7
+            }
             if headlen-oldheadlen-6+len(data) > 0xFFFF {
                 pespkg.PES_packet_length = 0
             } else {
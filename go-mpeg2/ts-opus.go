@@ -0,0 +1,80 @@
+package mpeg2
+
+import "errors"
+
+// Encapsulation of Opus in MPEG-2 Transport Streams
+// every opus packet in PES payload is preceded by a control header
+// opus_control_header() {
+//     control_header_prefix        11 bslbf  0x3ff
+//     start_trim_flag               1 bslbf
+//     end_trim_flag                 1 bslbf
+//     control_extension_flag        1 bslbf
+//     reserved                      2 bslbf
+//     au_size = 0
+//     while (nextbits(8) == 0xFF) {
+//         ff_byte                   8 bslbf
+//         au_size += 255
+//     }
+//     au_size_last_byte             8 uimsbf
+//     au_size += au_size_last_byte
+//     if (start_trim_flag) {
+//         reserved                  3 bslbf
+//         start_trim               13 uimsbf
+//     }
+//     if (end_trim_flag) {
+//         reserved                  3 bslbf
+//         end_trim                 13 uimsbf
+//     }
+//     if (control_extension_flag) {
+//         control_extension_length  8 uimsbf
+//         reserved                  N bslbf
+//     }
+// }
+
+func packOpusControlHeader(size int) []byte {
+    hdr := make([]byte, 0, 2+size/255+1)
+    hdr = append(hdr, 0x7F, 0xE0)
+    for ; size >= 255; size -= 255 {
+        hdr = append(hdr, 0xFF)
+    }
+    return append(hdr, byte(size))
+}
+
+// trim information is ignored,onPacket gets opus packet without control header
+func splitOpusPackets(data []byte, onPacket func(packet []byte)) error {
+    for len(data) > 0 {
+        if len(data) < 3 || data[0] != 0x7F || data[1]&0xE0 != 0xE0 {
+            return errors.New("opus control header must start with 0x3ff")
+        }
+        flags := data[1]
+        off := 2
+        size := 0
+        for off < len(data) && data[off] == 0xFF {
+            size += 255
+            off++
+        }
+        if off >= len(data) {
+            return errors.New("incomplete opus control header")
+        }
+        size += int(data[off])
+        off++
+        if flags&0x10 > 0 {
+            off += 2
+        }
+        if flags&0x08 > 0 {
+            off += 2
+        }
+        if flags&0x04 > 0 {
+            if off >= len(data) {
+                return errors.New("incomplete opus control header")
+            }
+            off += 1 + int(data[off])
+        }
+        if off+size > len(data) {
+            return errors.New("incomplete opus packet")
+        }
+        onPacket(data[off : off+size])
+        data = data[off+size:]
+    }
+    return nil
+}
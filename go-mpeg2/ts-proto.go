@@ -34,13 +34,35 @@ const (
 type TS_STREAM_TYPE int
 
 const (
+    TS_STREAM_MPEG2_VIDEO TS_STREAM_TYPE = 0x02
     TS_STREAM_AUDIO_MPEG1 TS_STREAM_TYPE = 0x03
     TS_STREAM_AUDIO_MPEG2 TS_STREAM_TYPE = 0x04
+    TS_STREAM_PRIVATE     TS_STREAM_TYPE = 0x06 //PES packets containing private data,DVB subtitle/teletext
     TS_STREAM_AAC         TS_STREAM_TYPE = 0x0F
+    TS_STREAM_AAC_LATM    TS_STREAM_TYPE = 0x11 //ISO/IEC 14496-3 LATM/LOAS
     TS_STREAM_H264        TS_STREAM_TYPE = 0x1B
     TS_STREAM_H265        TS_STREAM_TYPE = 0x24
+    TS_STREAM_AC3         TS_STREAM_TYPE = 0x81 //ATSC A/52
+    TS_STREAM_EAC3        TS_STREAM_TYPE = 0x87 //ATSC A/52 Annex G
+    //Opus has no stream_type of its own,it is carried by 0x06 with registration descriptor 'Opus'
+    //this value is only used by TSMuxer/TSDemuxer and never written into PMT
+    TS_STREAM_OPUS TS_STREAM_TYPE = 0x106
 )
 
+func isVideoStream(cid TS_STREAM_TYPE) bool {
+    return cid == TS_STREAM_H264 || cid == TS_STREAM_H265 || cid == TS_STREAM_MPEG2_VIDEO
+}
+
+func isAudioStream(cid TS_STREAM_TYPE) bool {
+    switch cid {
+    case TS_STREAM_AAC, TS_STREAM_AAC_LATM, TS_STREAM_AUDIO_MPEG1, TS_STREAM_AUDIO_MPEG2,
+        TS_STREAM_AC3, TS_STREAM_EAC3, TS_STREAM_OPUS:
+        return true
+    default:
+        return false
+    }
+}
+
 const (
     TS_PAKCET_SIZE = 188
 )
@@ -515,6 +537,7 @@ type StreamPair struct {
     StreamType     uint8  //8 uimsbf
     Elementary_PID uint16 //13 uimsbf
     ES_Info_Length uint16 //12 uimsbf
+    Descriptors    []Descriptor
 }
 
 type Pmt struct {
@@ -563,11 +586,28 @@ func (pmt *Pmt) PrettyPrint(file *os.File) {
             file.WriteString("    stream_type:H264\n")
         } else if stream.StreamType == uint8(TS_STREAM_H265) {
             file.WriteString("    stream_type:H265\n")
+        } else if stream.StreamType == uint8(TS_STREAM_MPEG2_VIDEO) {
+            file.WriteString("    stream_type:MPEG2 Video\n")
+        } else if stream.StreamType == uint8(TS_STREAM_AAC_LATM) {
+            file.WriteString("    stream_type:AAC LATM\n")
+        } else if stream.StreamType == uint8(TS_STREAM_AC3) {
+            file.WriteString("    stream_type:AC3\n")
+        } else if stream.StreamType == uint8(TS_STREAM_EAC3) {
+            file.WriteString("    stream_type:EAC3\n")
+        } else if stream.StreamType == uint8(TS_STREAM_PRIVATE) {
+            if cid := privateStreamType(stream.Descriptors); cid != TS_STREAM_PRIVATE {
+                file.WriteString(fmt.Sprintf("    stream_type:PES private data,%s\n", streamTypeName(cid)))
+            } else {
+                file.WriteString(fmt.Sprintf("    stream_type:PES private data,%s\n", NewPrivateStream(stream.Elementary_PID, stream.Descriptors).Codec))
+            }
         } else {
             file.WriteString(fmt.Sprintf("    stream_type:UnSupport streamtype:%d\n", stream.StreamType))
         }
         file.WriteString(fmt.Sprintf("    elementary_PID:%d\n", stream.Elementary_PID))
         file.WriteString(fmt.Sprintf("    ES_info_length:%d\n", stream.ES_Info_Length))
+        for i := range stream.Descriptors {
+            stream.Descriptors[i].PrettyPrint(file)
+        }
     }
 }
 
@@ -595,8 +635,9 @@ func (pmt *Pmt) Encode(bsw *codec.BitStreamWriter) {
         bsw.PutUint8(0x00, 3)
         bsw.PutUint16(stream.Elementary_PID, 13)
         bsw.PutUint8(0x00, 4)
-        //TODO ES_info
-        bsw.PutUint8(0 /*ES_info_length*/, 12)
+        esinfo := EncodeDescriptors(stream.Descriptors)
+        bsw.PutUint16(uint16(len(esinfo)), 12)
+        bsw.PutBytes(esinfo)
     }
     length := bsw.DistanceFromMarkDot()
     pmt.Section_length = uint16(length)/8 + 4
@@ -639,8 +680,12 @@ func (pmt *Pmt) Decode(bs *codec.BitStream) error {
         tmp.Elementary_PID = bs.Uint16(13)
         bs.SkipBits(4)
         tmp.ES_Info_Length = bs.Uint16(12)
-        //TODO N loop descriptors
-        bs.SkipBits(int(tmp.ES_Info_Length) * 8)
+        if bs.RemainBytes() < int(tmp.ES_Info_Length) {
+            // pmt section spans more than one ts packet
+            pmt.Streams = append(pmt.Streams, tmp)
+            break
+        }
+        tmp.Descriptors, _ = DecodeDescriptors(bs.GetBytes(int(tmp.ES_Info_Length)))
         pmt.Streams = append(pmt.Streams, tmp)
         i += 5 + int(tmp.ES_Info_Length)
     }
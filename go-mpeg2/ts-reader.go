@@ -0,0 +1,148 @@
+package mpeg2
+
+import (
+    "errors"
+    "io"
+)
+
+const (
+    TS_M2TS_PACKET_SIZE = 192 //4 bytes TP_extra_header(copy_permission_indicator + arrival_time_stamp) + 188
+    TS_RS_PACKET_SIZE   = 204 //188 + 16 bytes Reed-Solomon parity
+)
+
+var tsPacketSizes = []int{TS_PAKCET_SIZE, TS_M2TS_PACKET_SIZE, TS_RS_PACKET_SIZE}
+
+// tsPacketReader reads 188 bytes ts packets from 188/192/204 bytes packet stream,
+// the packet size is detected by the distance of sync bytes,
+// on sync loss it drops bytes until the sync is acquired again.
+//
+// all packet size can be handled in the same way if the reader position is on the sync byte,
+// the distance between two sync bytes is always the packet size:
+//   188: |0x47 ... |0x47
+//   192: |0x47 ...  timestamp(4 bytes)|0x47
+//   204: |0x47 ...  parity(16 bytes)|0x47
+type tsPacketReader struct {
+    r       io.Reader
+    buf     []byte
+    off     int
+    eof     bool
+    err     error
+    pktSize int
+    resyncs int
+}
+
+func newTsPacketReader(r io.Reader) *tsPacketReader {
+    return &tsPacketReader{
+        r:   r,
+        buf: make([]byte, 0, 64*TS_RS_PACKET_SIZE),
+    }
+}
+
+func (tr *tsPacketReader) remain() int {
+    return len(tr.buf) - tr.off
+}
+
+// make sure there are at least n bytes in buffer unless reaching end of stream
+func (tr *tsPacketReader) fill(n int) {
+    if tr.remain() >= n || tr.eof {
+        return
+    }
+    if tr.off > 0 {
+        copy(tr.buf, tr.buf[tr.off:])
+        tr.buf = tr.buf[:len(tr.buf)-tr.off]
+        tr.off = 0
+    }
+    if cap(tr.buf) < n {
+        tmp := make([]byte, len(tr.buf), n*2)
+        copy(tmp, tr.buf)
+        tr.buf = tmp
+    }
+    for len(tr.buf) < n {
+        rn, err := tr.r.Read(tr.buf[len(tr.buf):cap(tr.buf)])
+        tr.buf = tr.buf[:len(tr.buf)+rn]
+        if err != nil {
+            tr.eof = true
+            if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+                tr.err = err
+            }
+            return
+        }
+    }
+}
+
+func (tr *tsPacketReader) isSync(pos int, size int, count int) bool {
+    for i := 0; i < count; i++ {
+        if tr.off+pos+i*size >= len(tr.buf) || tr.buf[tr.off+pos+i*size] != 0x47 {
+            return false
+        }
+    }
+    return true
+}
+
+// find the first position where a few consecutive sync bytes are found
+func (tr *tsPacketReader) probe() bool {
+    tr.fill(4*TS_RS_PACKET_SIZE + 1)
+    for pos := 0; pos < tr.remain(); pos++ {
+        if tr.buf[tr.off+pos] != 0x47 {
+            continue
+        }
+        for _, size := range tsPacketSizes {
+            //less packets are checked at the end of stream
+            count := (tr.remain() - pos - 1) / size
+            if count > 3 {
+                count = 3
+            }
+            if tr.isSync(pos, size, count+1) && (count > 0 || pos == 0) {
+                tr.off += pos
+                tr.pktSize = size
+                return true
+            }
+        }
+    }
+    //not found, keep the last few bytes which may be the beginning of a sync sequence
+    if tr.remain() > 3*TS_RS_PACKET_SIZE {
+        tr.off = len(tr.buf) - 3*TS_RS_PACKET_SIZE
+    } else if tr.eof {
+        tr.off = len(tr.buf)
+    }
+    return false
+}
+
+// next returns a 188 bytes ts packet,the returned slice is valid until next call
+func (tr *tsPacketReader) next() ([]byte, error) {
+    for {
+        if tr.pktSize == 0 {
+            if !tr.probe() {
+                if tr.eof {
+                    if tr.err != nil {
+                        return nil, tr.err
+                    }
+                    return nil, io.EOF
+                }
+                continue
+            }
+        }
+        tr.fill(tr.pktSize)
+        if tr.remain() < TS_PAKCET_SIZE {
+            tr.off = len(tr.buf)
+            if tr.err != nil {
+                return nil, tr.err
+            }
+            return nil, io.EOF
+        }
+        if tr.buf[tr.off] != 0x47 {
+            //lost sync,probe packet size again from the next byte
+            tr.resyncs++
+            tr.pktSize = 0
+            tr.off++
+            continue
+        }
+        pkt := tr.buf[tr.off : tr.off+TS_PAKCET_SIZE]
+        if tr.remain() < tr.pktSize {
+            tr.off = len(tr.buf)
+        } else {
+            tr.off += tr.pktSize
+        }
+        return pkt, nil
+    }
+}
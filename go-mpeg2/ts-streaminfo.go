@@ -0,0 +1,174 @@
+package mpeg2
+
+import (
+    "sort"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// frames are held until every audio/video stream of the program is probed,
+// or the limits are reached(e.g. a stream in PMT never appears)
+const (
+    maxProbePackets  = 1024
+    maxProbeDuration = 5000 //ms
+)
+
+// demuxed frame with the program and stream it belongs to
+type Packet struct {
+    ProgramNumber uint16
+    PID           uint16
+    Cid           TS_STREAM_TYPE
+    Codec         codec.CodecID
+    Data          []byte
+    Pts           uint64 //ms
+    Dts           uint64 //ms
+    KeyFrame      bool   //always true for audio
+    Damaged       bool   //part of the frame was lost because of continuity_counter error
+    Private       *PrivateStream
+}
+
+// elementary stream information from PMT and the first frames of the stream
+type TSStreamInfo struct {
+    PID         uint16
+    Cid         TS_STREAM_TYPE
+    Codec       codec.CodecID
+    Descriptors []Descriptor
+    Private     *PrivateStream //only for PES private data stream(DVB subtitle,teletext...)
+    Width       uint32         //video
+    Height      uint32         //video
+    SampleRate  int            //audio,zero if unknown
+    Channels    int            //audio,zero if unknown
+}
+
+type TSProgramInfo struct {
+    ProgramNumber uint16
+    PmtPID        uint16
+    PcrPID        uint16
+    Streams       []TSStreamInfo
+}
+
+func TSStreamTypeToCodecId(cid TS_STREAM_TYPE) codec.CodecID {
+    switch cid {
+    case TS_STREAM_H264:
+        return codec.CODECID_VIDEO_H264
+    case TS_STREAM_H265:
+        return codec.CODECID_VIDEO_H265
+    case TS_STREAM_MPEG2_VIDEO:
+        return codec.CODECID_VIDEO_MPEG2
+    case TS_STREAM_AAC:
+        return codec.CODECID_AUDIO_AAC
+    case TS_STREAM_AAC_LATM:
+        return codec.CODECID_AUDIO_AAC_LATM
+    case TS_STREAM_AUDIO_MPEG1, TS_STREAM_AUDIO_MPEG2:
+        return codec.CODECID_AUDIO_MP3
+    case TS_STREAM_AC3:
+        return codec.CODECID_AUDIO_AC3
+    case TS_STREAM_EAC3:
+        return codec.CODECID_AUDIO_EAC3
+    case TS_STREAM_OPUS:
+        return codec.CODECID_AUDIO_OPUS
+    default:
+        return codec.CODECID_UNRECOGNIZED
+    }
+}
+
+func isKeyFrame(cid TS_STREAM_TYPE, frame []byte) bool {
+    switch cid {
+    case TS_STREAM_H264:
+        return codec.IsH264IDRFrame(frame)
+    case TS_STREAM_H265:
+        return codec.IsH265IDRFrame(frame)
+    case TS_STREAM_MPEG2_VIDEO:
+        return codec.IsMpeg2VideoKeyFrame(frame)
+    default:
+        return true
+    }
+}
+
+// probe resolution from SPS/sequence header and sample rate from frame header,
+// the stream is probed when the information is found,
+// streams other than audio/video are never waited for
+func (stream *tsstream) probe(frame []byte) {
+    if stream.probed {
+        return
+    }
+    info := &stream.info
+    switch stream.cid {
+    case TS_STREAM_H264:
+        codec.SplitFrameWithStartCode(frame, func(nalu []byte) bool {
+            if codec.H264NaluType(nalu) == codec.H264_NAL_SPS {
+                if width, height, err := codec.ParseH264Resolution(nalu); err == nil {
+                    info.Width, info.Height = width, height
+                }
+                return false
+            }
+            return true
+        })
+    case TS_STREAM_H265:
+        codec.SplitFrameWithStartCode(frame, func(nalu []byte) bool {
+            if codec.H265NaluType(nalu) == codec.H265_NAL_SPS {
+                if width, height, err := codec.ParseH265Resolution(nalu); err == nil {
+                    info.Width, info.Height = width, height
+                }
+                return false
+            }
+            return true
+        })
+    case TS_STREAM_MPEG2_VIDEO:
+        info.Width, info.Height = codec.GetMpeg2VideoResolution(frame)
+    case TS_STREAM_AAC:
+        if asc, err := codec.ConvertADTSToASC(frame); err == nil {
+            info.SampleRate = codec.AACSampleIdxToSample(int(asc.Sample_freq_index))
+            info.Channels = int(asc.Channel_configuration)
+        }
+    case TS_STREAM_AUDIO_MPEG1, TS_STREAM_AUDIO_MPEG2:
+        if head, err := codec.DecodeMp3Head(frame); err == nil {
+            info.SampleRate = head.GetSampleRate()
+            info.Channels = head.GetChannelCount()
+        }
+    case TS_STREAM_AC3, TS_STREAM_EAC3:
+        if head, err := codec.DecodeAC3FrameHead(frame); err == nil {
+            info.SampleRate = head.SampleRate
+            info.Channels = head.Channels
+        }
+    case TS_STREAM_OPUS:
+        info.SampleRate = 48000
+        info.Channels = 2
+        for _, desc := range info.Descriptors {
+            if desc.Tag == TS_DESCRIPTOR_EXTENSION && len(desc.Data) >= 2 &&
+                desc.Data[0] == TS_EXTENSION_DESCRIPTOR_OPUS && desc.Data[1] >= 1 && desc.Data[1] <= 8 {
+                info.Channels = int(desc.Data[1])
+            }
+        }
+    }
+    if isVideoStream(stream.cid) {
+        stream.probed = info.Width > 0 && info.Height > 0
+    } else {
+        stream.probed = true
+    }
+}
+
+func (pg *tsprogram) allProbed() bool {
+    for _, stream := range pg.streams {
+        if !stream.probed && (isVideoStream(stream.cid) || isAudioStream(stream.cid)) {
+            return false
+        }
+    }
+    return true
+}
+
+func (pg *tsprogram) programInfo() *TSProgramInfo {
+    info := &TSProgramInfo{
+        ProgramNumber: pg.pn,
+        PmtPID:        pg.pmtPid,
+        PcrPID:        pg.pcrPid,
+        Streams:       make([]TSStreamInfo, 0, len(pg.streams)),
+    }
+    for _, stream := range pg.streams {
+        info.Streams = append(info.Streams, stream.info)
+    }
+    sort.Slice(info.Streams, func(i, j int) bool {
+        return info.Streams[i].PID < info.Streams[j].PID
+    })
+    return info
+}
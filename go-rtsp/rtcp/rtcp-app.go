@@ -37,19 +37,19 @@ func (pkt *App) Decode(data []byte) error {
     }
 
     pkt.SubType = data[0] & 0x1F
-    if pkt.Length > uint16(len(data)-4) {
+    if int(pkt.PayloadLen)+4 > len(data) || pkt.PayloadLen < 8 {
         return errors.New("app rtcp packet need more data")
     }
     pkt.SSRC = binary.BigEndian.Uint32(data[4:])
     pkt.Name = data[8:12]
-    pkt.AppData = data[12 : 12+pkt.PayloadLen]
+    pkt.AppData = data[12 : 4+pkt.PayloadLen]
     return nil
 }
 
 func (pkt *App) Encode() []byte {
     pkt.Comm.Length = pkt.calcLength()
     data := pkt.Comm.Encode()
-    data[1] |= (0x1F & pkt.SubType)
+    data[0] |= (0x1F & pkt.SubType)
     offset := 4
     binary.BigEndian.PutUint32(data[offset:], pkt.SSRC)
     offset += 4
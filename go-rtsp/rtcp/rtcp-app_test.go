@@ -0,0 +1,55 @@
+package rtcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestApp_Decode(t *testing.T) {
+	data := []byte{
+		0x85, 0xCC, 0x00, 0x03,
+		0x11, 0x11, 0x11, 0x11,
+		'T', 'E', 'S', 'T',
+		0x01, 0x02, 0x03, 0x04,
+	}
+	app := NewApp()
+	if err := app.Decode(data); err != nil {
+		t.Fatalf("App.Decode() error = %v", err)
+	}
+	if app.SubType != 5 || app.SSRC != 0x11111111 || string(app.Name) != "TEST" || !bytes.Equal(app.AppData, []byte{1, 2, 3, 4}) {
+		t.Errorf("App.Decode() = %d %x %q %x", app.SubType, app.SSRC, app.Name, app.AppData)
+	}
+	encoded := &App{Comm: Comm{PT: RTCP_APP}, SubType: 5, SSRC: 0x11111111, Name: []byte("TEST"), AppData: []byte{1, 2, 3, 4}}
+	if got := encoded.Encode(); !bytes.Equal(got, data) {
+		t.Errorf("App.Encode() = %x, want %x", got, data)
+	}
+
+	//the padding is not application data
+	padded := []byte{
+		0xA5, 0xCC, 0x00, 0x04,
+		0x11, 0x11, 0x11, 0x11,
+		'T', 'E', 'S', 'T',
+		0x01, 0x02, 0x03, 0x04,
+		0x00, 0x00, 0x00, 0x04,
+	}
+	if err := app.Decode(padded); err != nil {
+		t.Fatalf("App.Decode(padded) error = %v", err)
+	}
+	if !bytes.Equal(app.AppData, []byte{1, 2, 3, 4}) {
+		t.Errorf("App.Decode(padded) data = %x", app.AppData)
+	}
+}
+
+func TestApp_DecodeBounds(t *testing.T) {
+	bad := [][]byte{
+		//no name
+		{0x80, 0xCC, 0x00, 0x01, 0x11, 0x11, 0x11, 0x11},
+		//the length is longer than the data
+		{0x80, 0xCC, 0x00, 0x03, 0x11, 0x11, 0x11, 0x11, 'T', 'E', 'S', 'T'},
+	}
+	for i, data := range bad {
+		if err := NewApp().Decode(data); err == nil {
+			t.Errorf("App.Decode(bad %d) error = nil, want error", i)
+		}
+	}
+}
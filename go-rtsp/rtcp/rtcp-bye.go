@@ -1,6 +1,9 @@
 package rtcp
 
-import "encoding/binary"
+import (
+    "encoding/binary"
+    "errors"
+)
 
 //  	  0                   1                   2                   3
 //  	  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
@@ -34,22 +37,31 @@ func (pkt *Bye) Decode(data []byte) error {
         return err
     }
     pkt.SC = data[0] & 0x1F
+    end := 4 + int(pkt.PayloadLen)
+    if end > len(data) || int(pkt.SC)*4 > int(pkt.PayloadLen) {
+        return errors.New("bye rtcp packet need more data")
+    }
     offset := 4
     for i := 0; i < int(pkt.SC); i++ {
-        pkt.SSRCS = append(pkt.SSRCS, binary.BigEndian.Uint32(data[offset+i*4:]))
-        offset += i * 4
+        pkt.SSRCS = append(pkt.SSRCS, binary.BigEndian.Uint32(data[offset:]))
+        offset += 4
+    }
+    //the reason is optional
+    if offset < end {
+        pkt.ReasonLen = data[offset]
+        offset++
+        if offset+int(pkt.ReasonLen) > end {
+            return errors.New("bye rtcp packet need more data")
+        }
+        pkt.Reason = string(data[offset : offset+int(pkt.ReasonLen)])
     }
-
-    pkt.ReasonLen = data[offset]
-    offset++
-    pkt.Reason = string(data[offset : offset+int(pkt.ReasonLen)])
     return nil
 }
 
 func (pkt *Bye) Encode() []byte {
     pkt.Comm.Length = pkt.calcLength()
     data := pkt.Comm.Encode()
-    data[1] |= (0x1F & pkt.SC)
+    data[0] |= (0x1F & pkt.SC)
     offset := 4
     for _, ssrc := range pkt.SSRCS {
         binary.BigEndian.PutUint32(data[offset:], ssrc)
@@ -64,9 +76,8 @@ func (pkt *Bye) Encode() []byte {
 
 func (pkt *Bye) calcLength() uint16 {
     length := len(pkt.SSRCS) * 4
-    if (len(pkt.Reason)+1)%4 == 0 {
-        length += len(pkt.Reason) + 1
-    } else {
+    //the reason is optional,its length octet and text are padded to 32 bits
+    if len(pkt.Reason) > 0 {
         length += (len(pkt.Reason) + 4) / 4 * 4
     }
     return uint16(length) / 4
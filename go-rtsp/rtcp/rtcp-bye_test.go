@@ -0,0 +1,65 @@
+package rtcp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestBye_Decode(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		ssrcs  []uint32
+		reason string
+	}{
+		{
+			name:  "one ssrc",
+			data:  []byte{0x81, 0xCB, 0x00, 0x01, 0x11, 0x11, 0x11, 0x11},
+			ssrcs: []uint32{0x11111111},
+		},
+		{
+			//the reason follows SC ssrcs
+			name: "two ssrcs with reason",
+			data: []byte{
+				0x82, 0xCB, 0x00, 0x03,
+				0x11, 0x11, 0x11, 0x11,
+				0x22, 0x22, 0x22, 0x22,
+				0x03, 'b', 'y', 'e',
+			},
+			ssrcs:  []uint32{0x11111111, 0x22222222},
+			reason: "bye",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bye := NewBye()
+			if err := bye.Decode(tt.data); err != nil {
+				t.Fatalf("Bye.Decode() error = %v", err)
+			}
+			if int(bye.SC) != len(tt.ssrcs) || !reflect.DeepEqual(bye.SSRCS, tt.ssrcs) || bye.Reason != tt.reason {
+				t.Errorf("Bye.Decode() = %d %x %q, want %x %q", bye.SC, bye.SSRCS, bye.Reason, tt.ssrcs, tt.reason)
+			}
+			encoded := &Bye{Comm: Comm{PT: RTCP_BYE}, SC: uint8(len(tt.ssrcs)), SSRCS: tt.ssrcs, Reason: tt.reason}
+			if got := encoded.Encode(); !bytes.Equal(got, tt.data) {
+				t.Errorf("Bye.Encode() = %x, want %x", got, tt.data)
+			}
+		})
+	}
+}
+
+func TestBye_DecodeBounds(t *testing.T) {
+	bad := [][]byte{
+		//SC is 3 but the packet has 2 ssrcs
+		{0x83, 0xCB, 0x00, 0x02, 0x11, 0x11, 0x11, 0x11, 0x22, 0x22, 0x22, 0x22},
+		//the reason is longer than the packet
+		{0x81, 0xCB, 0x00, 0x02, 0x11, 0x11, 0x11, 0x11, 0x08, 'b', 'y', 'e'},
+		//the length is longer than the data
+		{0x81, 0xCB, 0x00, 0x02, 0x11, 0x11, 0x11, 0x11},
+	}
+	for i, data := range bad {
+		if err := NewBye().Decode(data); err == nil {
+			t.Errorf("Bye.Decode(bad %d) error = nil, want error", i)
+		}
+	}
+}
@@ -31,9 +31,16 @@ func (pkt *Comm) Decode(data []byte) error {
     pkt.Length = binary.BigEndian.Uint16(data[2:])
     pkt.PayloadLen = pkt.Length * 4
     if pkt.Padding {
-        paddingLen := data[pkt.Length*4-1]
-        pkt.PayloadLen = pkt.Length*4 - uint16(paddingLen)
-        pkt.PaddingData = data[pkt.Length*4-uint16(paddingLen):]
+        //the last octet of the packet is the count of padding octets
+        if int(pkt.Length)*4+4 > len(data) {
+            return errors.New("rtcp padding need more data")
+        }
+        paddingLen := uint16(data[pkt.Length*4+3])
+        if paddingLen == 0 || paddingLen > pkt.Length*4 {
+            return errors.New("invalid rtcp padding")
+        }
+        pkt.PayloadLen = pkt.Length*4 - paddingLen
+        pkt.PaddingData = data[4+pkt.PayloadLen : 4+pkt.Length*4-1]
     }
     return nil
 }
@@ -0,0 +1,85 @@
+package rtcp
+
+import (
+    "encoding/binary"
+    "errors"
+)
+
+// SR,RR,SDES,BYE,APP and feedback messages
+type Packet interface {
+    Decode(data []byte) error
+    Encode() []byte
+}
+
+// rfc3550 6.1 RTCP Packet Format,
+// a compound packet begins with SR or RR and contains SDES CNAME
+func EncodeCompound(pkts ...Packet) []byte {
+    var data []byte
+    for _, pkt := range pkts {
+        data = append(data, pkt.Encode()...)
+    }
+    return data
+}
+
+// split the compound packet and decode each packet,
+// the packet of unknown type is skipped
+func DecodeCompound(data []byte) ([]Packet, error) {
+    var pkts []Packet
+    for len(data) > 0 {
+        if len(data) < 4 {
+            return pkts, errors.New("rtcp packet need more data")
+        }
+        if data[0]>>6 != 2 {
+            return pkts, errors.New("unsupport rtcp version")
+        }
+        length := 4 + int(binary.BigEndian.Uint16(data[2:]))*4
+        if length > len(data) {
+            return pkts, errors.New("rtcp packet need more data")
+        }
+        var pkt Packet
+        switch data[1] {
+        case RTCP_SR:
+            pkt = NewSenderReport()
+        case RTCP_RR:
+            pkt = NewReceiverReport()
+        case RTCP_SDES:
+            pkt = NewSourceDescription()
+        case RTCP_BYE:
+            pkt = NewBye()
+        case RTCP_APP:
+            pkt = NewApp()
+        case RTCP_RTPFB:
+            switch data[0] & 0x1F {
+            case RTPFB_NACK:
+                pkt = &Nack{}
+            case RTPFB_TWCC:
+                pkt = &TransportCC{}
+            default:
+                pkt = &Feedback{}
+            }
+        case RTCP_PSFB:
+            switch data[0] & 0x1F {
+            case PSFB_PLI:
+                pkt = &Pli{}
+            case PSFB_FIR:
+                pkt = &Fir{}
+            case PSFB_AFB:
+                if length >= 16 && string(data[12:16]) == "REMB" {
+                    pkt = &Remb{}
+                } else {
+                    pkt = &Feedback{}
+                }
+            default:
+                pkt = &Feedback{}
+            }
+        }
+        if pkt != nil {
+            if err := pkt.Decode(data[:length]); err != nil {
+                return pkts, err
+            }
+            pkts = append(pkts, pkt)
+        }
+        data = data[length:]
+    }
+    return pkts, nil
+}
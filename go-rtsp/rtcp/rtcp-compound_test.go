@@ -0,0 +1,56 @@
+package rtcp
+
+import (
+	"testing"
+)
+
+func TestDecodeCompound(t *testing.T) {
+	sr := NewSenderReport()
+	sr.SSRC = 0x11111111
+	sdes := NewSourceDescription()
+	sdes.Chunks = []SDESChunk{{SSRC: 0x11111111, Item: MakeCNameItem([]byte("host"))}}
+	bye := &Bye{Comm: Comm{PT: RTCP_BYE}, SC: 1, SSRCS: []uint32{0x11111111}}
+	remb := &Remb{SenderSSRC: 0x11111111, Bitrate: 1000000, SSRCs: []uint32{0x22222222}}
+	afb := &Feedback{Comm: Comm{PT: RTCP_PSFB}, FMT: PSFB_AFB, SenderSSRC: 0x11111111, FCI: []byte("ABCD")}
+	//the extended report(XR) is unknown
+	xr := []byte{0x80, 0xCF, 0x00, 0x01, 0x11, 0x11, 0x11, 0x11}
+
+	data := EncodeCompound(sr, sdes)
+	data = append(data, xr...)
+	data = append(data, EncodeCompound(remb, afb, &Nack{SenderSSRC: 1, MediaSSRC: 2, Pairs: []NackPair{{PID: 1}}}, bye)...)
+	pkts, err := DecodeCompound(data)
+	if err != nil {
+		t.Fatalf("DecodeCompound() error = %v", err)
+	}
+	if len(pkts) != 6 {
+		t.Fatalf("DecodeCompound() = %d packets, want 6", len(pkts))
+	}
+	if p, ok := pkts[0].(*SenderReport); !ok || p.SSRC != 0x11111111 {
+		t.Errorf("packet 0 = %T %+v, want SR", pkts[0], pkts[0])
+	}
+	if p, ok := pkts[1].(*SourceDescription); !ok || string(p.Chunks[0].Item.Txt) != "host" {
+		t.Errorf("packet 1 = %T %+v, want SDES", pkts[1], pkts[1])
+	}
+	if p, ok := pkts[2].(*Remb); !ok || p.Bitrate != 1000000 {
+		t.Errorf("packet 2 = %T %+v, want REMB", pkts[2], pkts[2])
+	}
+	if p, ok := pkts[3].(*Feedback); !ok || string(p.FCI) != "ABCD" {
+		t.Errorf("packet 3 = %T %+v, want application layer feedback", pkts[3], pkts[3])
+	}
+	if _, ok := pkts[4].(*Nack); !ok {
+		t.Errorf("packet 4 = %T, want NACK", pkts[4])
+	}
+	if p, ok := pkts[5].(*Bye); !ok || p.SSRCS[0] != 0x11111111 {
+		t.Errorf("packet 5 = %T %+v, want BYE", pkts[5], pkts[5])
+	}
+
+	//the packets before the truncated one are returned
+	pkts, err = DecodeCompound(data[:len(data)-2])
+	if err == nil || len(pkts) != 5 {
+		t.Errorf("DecodeCompound(truncated) = %d packets, %v", len(pkts), err)
+	}
+	data[0] = 0x40
+	if _, err := DecodeCompound(data); err == nil {
+		t.Errorf("DecodeCompound(version 1) error = nil, want error")
+	}
+}
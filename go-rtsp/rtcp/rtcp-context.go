@@ -29,6 +29,12 @@ type RtcpContext struct {
     sendBytes        uint64
     sendPackets      uint64
     bindwidth        int
+    mediaSSRC        uint32 //ssrc of the received rtp
+    hasSeq           bool
+    highestSeq       uint16
+    missing          map[uint16]int //lost sequence number => nack times
+    onLoss           func(lost []uint16)
+    firSeq           uint8
 }
 
 const (
@@ -38,18 +44,94 @@ const (
     MAX_MISORDER   = 100
 )
 
+const (
+    NACK_MAX_RETRIES = 3
+    NACK_MAX_AGE     = 512 //the lost packet older than it is not requested
+)
+
 func NewRtcpContext(ssrc uint32, seq uint16, sampleRate uint32) *RtcpContext {
     return &RtcpContext{
         ssrc:       ssrc,
         maxSeq:     seq - 1,
         probation:  MIN_SEQUENTIAL,
         sampleRate: sampleRate,
+        missing:    make(map[uint16]int),
+    }
+}
+
+// the hook is called with the newly lost sequence numbers when a gap is detected,
+// e.g. send GenerateNack() immediately
+func (ctx *RtcpContext) OnLoss(onLoss func(lost []uint16)) {
+    ctx.onLoss = onLoss
+}
+
+// generic nack of the lost packets,each packet is requested NACK_MAX_RETRIES times at most,
+// nil if there is no lost packet
+func (ctx *RtcpContext) GenerateNack() *Nack {
+    if len(ctx.missing) == 0 {
+        return nil
     }
+    lost := make([]uint16, 0, len(ctx.missing))
+    for seq, times := range ctx.missing {
+        lost = append(lost, seq)
+        if times+1 >= NACK_MAX_RETRIES {
+            delete(ctx.missing, seq)
+        } else {
+            ctx.missing[seq] = times + 1
+        }
+    }
+    return NewNack(ctx.ssrc, ctx.mediaSSRC, lost)
+}
+
+func (ctx *RtcpContext) GeneratePli() *Pli {
+    return &Pli{SenderSSRC: ctx.ssrc, MediaSSRC: ctx.mediaSSRC}
+}
+
+func (ctx *RtcpContext) GenerateFir() *Fir {
+    ctx.firSeq++
+    return &Fir{SenderSSRC: ctx.ssrc, Entries: []FirEntry{{SSRC: ctx.mediaSSRC, SeqNum: ctx.firSeq}}}
+}
+
+// bitrate: bits per second
+func (ctx *RtcpContext) GenerateRemb(bitrate uint64) *Remb {
+    return &Remb{SenderSSRC: ctx.ssrc, Bitrate: bitrate, SSRCs: []uint32{ctx.mediaSSRC}}
 }
 
-//rfc 3550 Computing the RTCP Transmission Interval
-// func (ctx *RtcpContext) ComputeTransmitInterval() float32 {
-// }
+// the lost packets between the highest sequence number and seq are recorded,
+// the reordered packet is removed from them
+func (ctx *RtcpContext) detectLoss(seq uint16) {
+    if !ctx.hasSeq {
+        ctx.hasSeq = true
+        ctx.highestSeq = seq
+        return
+    }
+    diff := seq - ctx.highestSeq
+    if diff == 0 {
+        return
+    } else if diff >= 0x8000 {
+        delete(ctx.missing, seq)
+        return
+    }
+    ctx.highestSeq = seq
+    if diff >= MAX_DROPOUT {
+        //the source is restarted
+        ctx.missing = make(map[uint16]int)
+        return
+    }
+    var lost []uint16
+    for lostSeq := seq - diff + 1; lostSeq != seq; lostSeq++ {
+        ctx.missing[lostSeq] = 0
+        lost = append(lost, lostSeq)
+    }
+    for lostSeq := range ctx.missing {
+        if seq-lostSeq > NACK_MAX_AGE {
+            delete(ctx.missing, lostSeq)
+        }
+    }
+    if len(lost) > 0 && ctx.onLoss != nil {
+        ctx.onLoss(lost)
+    }
+}
 
 func (ctx *RtcpContext) GenerateApp(name string, data []byte) *App {
     app := NewApp()
@@ -73,9 +155,11 @@ func (ctx *RtcpContext) GenerateSDES(sdesType uint8, txt string) *SourceDescript
     sdes.SC = 1
     sdes.Chunks = make([]SDESChunk, 1)
     sdes.Chunks[0].SSRC = ctx.ssrc
-    sdes.Chunks[0].Item.Length = uint8(len(txt))
-    sdes.Chunks[0].Item.Txt = []byte(txt)
-    sdes.Chunks[0].Item.Type = sdesType
+    sdes.Chunks[0].Item = &ChunkItem{
+        Type:   sdesType,
+        Length: uint8(len(txt)),
+        Txt:    []byte(txt),
+    }
     return sdes
 }
 
@@ -99,6 +183,10 @@ func (ctx *RtcpContext) ReceivedSR(sr *SenderReport) {
     ctx.senderSSRC = sr.SSRC
 }
 
+func (ctx *RtcpContext) SentPackets() uint64 {
+    return ctx.sendPackets
+}
+
 func (ctx *RtcpContext) SendRtp(pkt *rtp.RtpPacket) {
     ctx.sendBytes += uint64(len(pkt.Payload))
     ctx.sendPackets++
@@ -113,6 +201,8 @@ func (ctx *RtcpContext) SendRtp(pkt *rtp.RtpPacket) {
 // s->jitter += (1./16.) * ((double)d - s->jitter);
 
 func (ctx *RtcpContext) ReceivedRtp(pkt *rtp.RtpPacket) {
+    ctx.mediaSSRC = pkt.Header.SSRC
+    ctx.detectLoss(pkt.Header.SequenceNumber)
     if ctx.updateSeq(pkt.Header.SequenceNumber) == 0 {
         return
     }
@@ -0,0 +1,82 @@
+package rtcp
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/yapingcat/gomedia/go-rtsp/rtp"
+)
+
+func receiveTestRtp(ctx *RtcpContext, seqs ...uint16) {
+	for _, seq := range seqs {
+		pkt := &rtp.RtpPacket{}
+		pkt.Header.SSRC = 0x22222222
+		pkt.Header.SequenceNumber = seq
+		pkt.Header.Timestamp = uint32(seq) * 160
+		ctx.ReceivedRtp(pkt)
+	}
+}
+
+func TestRtcpContext_DetectLoss(t *testing.T) {
+	ctx := NewRtcpContext(0x11111111, 0, 8000)
+	var reported [][]uint16
+	ctx.OnLoss(func(lost []uint16) {
+		reported = append(reported, append([]uint16{}, lost...))
+	})
+	receiveTestRtp(ctx, 65533, 65535, 2)
+	want := [][]uint16{{65534}, {0, 1}}
+	if !reflect.DeepEqual(reported, want) {
+		t.Fatalf("OnLoss() = %v, want %v", reported, want)
+	}
+	//the reordered packet is not lost any more,the duplicated one is ignored
+	receiveTestRtp(ctx, 0, 2)
+	var missing []int
+	for seq := range ctx.missing {
+		missing = append(missing, int(seq))
+	}
+	sort.Ints(missing)
+	if !reflect.DeepEqual(missing, []int{1, 65534}) {
+		t.Fatalf("missing = %v, want [1 65534]", missing)
+	}
+	//the source is restarted
+	receiveTestRtp(ctx, 2+MAX_DROPOUT)
+	if len(ctx.missing) != 0 || len(reported) != 2 {
+		t.Errorf("missing = %v after restart, %d reports", ctx.missing, len(reported))
+	}
+}
+
+func TestRtcpContext_GenerateNack(t *testing.T) {
+	ctx := NewRtcpContext(0x11111111, 0, 8000)
+	if ctx.GenerateNack() != nil {
+		t.Fatalf("GenerateNack() without loss != nil")
+	}
+	receiveTestRtp(ctx, 65533, 65535, 2)
+	for i := 0; i < NACK_MAX_RETRIES; i++ {
+		nack := ctx.GenerateNack()
+		if nack == nil {
+			t.Fatalf("GenerateNack() retry %d = nil", i)
+		}
+		if nack.SenderSSRC != 0x11111111 || nack.MediaSSRC != 0x22222222 {
+			t.Errorf("GenerateNack() ssrc = %x %x", nack.SenderSSRC, nack.MediaSSRC)
+		}
+		want := []NackPair{{PID: 65534, BLP: 0x0006}}
+		if !reflect.DeepEqual(nack.Pairs, want) {
+			t.Errorf("GenerateNack() pairs = %v, want %v", nack.Pairs, want)
+		}
+	}
+	if ctx.GenerateNack() != nil {
+		t.Errorf("GenerateNack() after %d retries != nil", NACK_MAX_RETRIES)
+	}
+}
+
+func TestRtcpContext_NackMaxAge(t *testing.T) {
+	ctx := NewRtcpContext(0x11111111, 0, 8000)
+	receiveTestRtp(ctx, 10, 12, 12+NACK_MAX_AGE)
+	if _, found := ctx.missing[11]; found {
+		t.Errorf("the packet older than NACK_MAX_AGE is still requested")
+	}
+	if len(ctx.missing) != NACK_MAX_AGE-1 {
+		t.Errorf("%d missing packets, want %d", len(ctx.missing), NACK_MAX_AGE-1)
+	}
+}
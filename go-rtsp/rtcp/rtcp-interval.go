@@ -0,0 +1,156 @@
+package rtcp
+
+import (
+    "math/rand"
+    "time"
+)
+
+// rfc3550 6.2 RTCP Transmission Interval
+const (
+    RTCP_MIN_INTERVAL              = 5 * time.Second
+    RTCP_BANDWIDTH_FRACTION        = 0.05 //of session bandwidth
+    RTCP_SENDER_BANDWIDTH_FRACTION = 0.25 //of rtcp bandwidth
+    RTCP_IP_UDP_OVERHEAD           = 28
+    DEFAULT_SESSION_BANDWIDTH      = 1000000 //bits per second
+    rtcpCompensation               = 2.71828 - 1.5
+)
+
+// rfc3550 A.7 Computing the RTCP Transmission Interval,
+// rtcpBw: octets per second,avgRtcpSize: octets
+func ComputeTransmitInterval(members int, senders int, rtcpBw float64, weSent bool, avgRtcpSize float64, initial bool) time.Duration {
+    minTime := RTCP_MIN_INTERVAL.Seconds()
+    if initial {
+        minTime /= 2
+    }
+    n := members
+    if senders <= int(float64(members)*RTCP_SENDER_BANDWIDTH_FRACTION) {
+        if weSent {
+            rtcpBw *= RTCP_SENDER_BANDWIDTH_FRACTION
+            n = senders
+        } else {
+            rtcpBw *= 1 - RTCP_SENDER_BANDWIDTH_FRACTION
+            n -= senders
+        }
+    }
+    t := minTime
+    if rtcpBw > 0 {
+        if d := avgRtcpSize * float64(n) / rtcpBw; d > t {
+            t = d
+        }
+    }
+    //randomized to [0.5,1.5] times to avoid synchronization
+    t = t * (rand.Float64() + 0.5) / rtcpCompensation
+    return time.Duration(t * float64(time.Second))
+}
+
+// RtcpScheduler decides when to send the next compound packet with timer reconsideration,
+// it has no timer,the caller polls it with the current time
+type RtcpScheduler struct {
+    ssrc        uint32
+    tp          time.Time //last time a rtcp packet was sent
+    tn          time.Time //next scheduled transmission time
+    pmembers    int
+    members     map[uint32]bool //ssrc => sender
+    weSent      bool
+    rtcpBw      float64
+    avgRtcpSize float64
+    initial     bool
+}
+
+// sessionBandwidth: bits per second
+func NewRtcpScheduler(ssrc uint32, sessionBandwidth int, now time.Time) *RtcpScheduler {
+    if sessionBandwidth <= 0 {
+        sessionBandwidth = DEFAULT_SESSION_BANDWIDTH
+    }
+    s := &RtcpScheduler{
+        ssrc:        ssrc,
+        tp:          now,
+        pmembers:    1,
+        members:     map[uint32]bool{ssrc: false},
+        rtcpBw:      float64(sessionBandwidth) / 8 * RTCP_BANDWIDTH_FRACTION,
+        avgRtcpSize: 128,
+        initial:     true,
+    }
+    s.tn = now.Add(s.interval())
+    return s
+}
+
+func (s *RtcpScheduler) senders() int {
+    n := 0
+    for _, sender := range s.members {
+        if sender {
+            n++
+        }
+    }
+    return n
+}
+
+func (s *RtcpScheduler) interval() time.Duration {
+    return ComputeTransmitInterval(len(s.members), s.senders(), s.rtcpBw, s.weSent, s.avgRtcpSize, s.initial)
+}
+
+func (s *RtcpScheduler) NextTime() time.Time {
+    return s.tn
+}
+
+// whether we have sent rtp since the last report
+func (s *RtcpScheduler) SetSender(weSent bool) {
+    s.weSent = weSent
+    s.members[s.ssrc] = weSent
+}
+
+// the interval is reconsidered when the scheduled time is reached,
+// true means a compound packet should be sent now,call Sent after it is sent
+func (s *RtcpScheduler) Poll(now time.Time) bool {
+    if now.Before(s.tn) {
+        return false
+    }
+    t := s.tp.Add(s.interval())
+    if !now.Before(t) {
+        return true
+    }
+    s.tn = t
+    s.pmembers = len(s.members)
+    return false
+}
+
+// size: octets of the compound packet
+func (s *RtcpScheduler) Sent(size int, now time.Time) {
+    s.updateAvgSize(size)
+    s.initial = false
+    s.tp = now
+    s.tn = now.Add(s.interval())
+    s.pmembers = len(s.members)
+}
+
+// rtp from ssrc,the member becomes a sender
+func (s *RtcpScheduler) ReceivedRtp(ssrc uint32) {
+    s.members[ssrc] = true
+}
+
+// size: octets of the compound packet
+func (s *RtcpScheduler) ReceivedRtcp(ssrc uint32, size int) {
+    if _, found := s.members[ssrc]; !found {
+        s.members[ssrc] = false
+    }
+    s.updateAvgSize(size)
+}
+
+// rfc3550 6.3.4 reverse reconsideration when the member leaves
+func (s *RtcpScheduler) ReceivedBye(ssrc uint32, now time.Time) {
+    if ssrc == s.ssrc {
+        return
+    }
+    delete(s.members, ssrc)
+    members := len(s.members)
+    if members < s.pmembers && s.pmembers > 0 {
+        ratio := float64(members) / float64(s.pmembers)
+        s.tn = now.Add(time.Duration(float64(s.tn.Sub(now)) * ratio))
+        s.tp = now.Add(-time.Duration(float64(now.Sub(s.tp)) * ratio))
+        s.pmembers = members
+    }
+}
+
+func (s *RtcpScheduler) updateAvgSize(size int) {
+    s.avgRtcpSize = float64(size+RTCP_IP_UDP_OVERHEAD)/16 + s.avgRtcpSize*15/16
+}
@@ -0,0 +1,84 @@
+package rtcp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeTransmitInterval(t *testing.T) {
+	//1Mbps session,rtcp bandwidth is 6250 octets per second
+	rtcpBw := float64(DEFAULT_SESSION_BANDWIDTH) / 8 * RTCP_BANDWIDTH_FRACTION
+	tests := []struct {
+		name    string
+		members int
+		senders int
+		weSent  bool
+		initial bool
+		seconds float64 //the deterministic interval before randomization
+	}{
+		{"two members", 2, 1, false, false, 5},
+		{"initial", 2, 1, false, true, 2.5},
+		//the receivers share 3/4 of rtcp bandwidth
+		{"many receivers", 1000, 0, false, false, 128 * 1000 / (rtcpBw * 0.75)},
+		//the senders share 1/4 of rtcp bandwidth
+		{"few senders", 1000, 10, true, false, 5},
+		{"many senders", 1000, 500, true, false, 128 * 1000 / rtcpBw},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			min := time.Duration(tt.seconds * 0.5 / rtcpCompensation * float64(time.Second))
+			max := time.Duration(tt.seconds * 1.5 / rtcpCompensation * float64(time.Second))
+			for i := 0; i < 100; i++ {
+				got := ComputeTransmitInterval(tt.members, tt.senders, rtcpBw, tt.weSent, 128, tt.initial)
+				if got < min || got > max {
+					t.Fatalf("ComputeTransmitInterval() = %v, want [%v,%v]", got, min, max)
+				}
+			}
+		})
+	}
+}
+
+func TestRtcpScheduler_ReceivedBye(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	s := NewRtcpScheduler(1, 0, t0)
+	for ssrc := uint32(2); ssrc <= 4; ssrc++ {
+		s.ReceivedRtcp(ssrc, 100)
+	}
+	s.Sent(100, t0)
+	tn := s.NextTime()
+
+	//rfc3550 6.3.4,tn and tp are scaled by members/pmembers
+	now := t0.Add(time.Second)
+	s.ReceivedBye(2, now)
+	wantTn := now.Add(time.Duration(float64(tn.Sub(now)) * 3 / 4))
+	if !s.NextTime().Equal(wantTn) {
+		t.Fatalf("NextTime() = %v, want %v", s.NextTime(), wantTn)
+	}
+	if wantTp := now.Add(-time.Second * 3 / 4); !s.tp.Equal(wantTp) {
+		t.Errorf("tp = %v, want %v", s.tp, wantTp)
+	}
+
+	//bye of ourself or an unknown member is ignored
+	s.ReceivedBye(1, now)
+	s.ReceivedBye(100, now)
+	if !s.NextTime().Equal(wantTn) || len(s.members) != 3 {
+		t.Errorf("NextTime() = %v with %d members after ignored bye", s.NextTime(), len(s.members))
+	}
+}
+
+func TestRtcpScheduler_Poll(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	s := NewRtcpScheduler(1, 0, t0)
+	if s.Poll(t0) {
+		t.Fatalf("Poll() before the first interval = true")
+	}
+	//the initial interval is at most 1.5*2.5s/(e-1.5)
+	now := t0.Add(4 * time.Second)
+	if !s.Poll(now) {
+		t.Fatalf("Poll() after the initial interval = false")
+	}
+	s.Sent(100, now)
+	if !s.NextTime().After(now) || s.initial {
+		t.Errorf("NextTime() = %v after Sent at %v", s.NextTime(), now)
+	}
+}
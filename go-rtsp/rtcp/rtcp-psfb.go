@@ -0,0 +1,139 @@
+package rtcp
+
+import (
+    "encoding/binary"
+    "errors"
+)
+
+// rfc4585 6.3.1 Picture Loss Indication,no FCI
+type Pli struct {
+    SenderSSRC uint32
+    MediaSSRC  uint32
+}
+
+func (pkt *Pli) Decode(data []byte) error {
+    fb := Feedback{}
+    if err := fb.Decode(data); err != nil {
+        return err
+    }
+    if fb.PT != RTCP_PSFB || fb.FMT != PSFB_PLI {
+        return errors.New("rtcp packet is not pli")
+    }
+    pkt.SenderSSRC = fb.SenderSSRC
+    pkt.MediaSSRC = fb.MediaSSRC
+    return nil
+}
+
+func (pkt *Pli) Encode() []byte {
+    fb := Feedback{Comm: Comm{PT: RTCP_PSFB}, FMT: PSFB_PLI, SenderSSRC: pkt.SenderSSRC, MediaSSRC: pkt.MediaSSRC}
+    return fb.Encode()
+}
+
+// rfc5104 4.3.1 Full Intra Request
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                              SSRC                             |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// | Seq nr.       |    Reserved                                   |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+type FirEntry struct {
+    SSRC   uint32
+    SeqNum uint8 //increased by one for each new request
+}
+
+// the SSRC of media source is not used(zero)
+type Fir struct {
+    SenderSSRC uint32
+    Entries    []FirEntry
+}
+
+func (pkt *Fir) Decode(data []byte) error {
+    fb := Feedback{}
+    if err := fb.Decode(data); err != nil {
+        return err
+    }
+    if fb.PT != RTCP_PSFB || fb.FMT != PSFB_FIR {
+        return errors.New("rtcp packet is not fir")
+    }
+    pkt.SenderSSRC = fb.SenderSSRC
+    pkt.Entries = pkt.Entries[:0]
+    for i := 0; i+8 <= len(fb.FCI); i += 8 {
+        pkt.Entries = append(pkt.Entries, FirEntry{SSRC: binary.BigEndian.Uint32(fb.FCI[i:]), SeqNum: fb.FCI[i+4]})
+    }
+    return nil
+}
+
+func (pkt *Fir) Encode() []byte {
+    fb := Feedback{Comm: Comm{PT: RTCP_PSFB}, FMT: PSFB_FIR, SenderSSRC: pkt.SenderSSRC}
+    fb.FCI = make([]byte, 8*len(pkt.Entries))
+    for i, entry := range pkt.Entries {
+        binary.BigEndian.PutUint32(fb.FCI[i*8:], entry.SSRC)
+        fb.FCI[i*8+4] = entry.SeqNum
+    }
+    return fb.Encode()
+}
+
+// draft-alvestrand-rmcat-remb-03 2.2 Receiver Estimated Max Bitrate
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Unique identifier 'R' 'E' 'M' 'B'                            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  Num SSRC     | BR Exp    |  BR Mantissa                      |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |   SSRC feedback                                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  ...                                                          |
+
+type Remb struct {
+    SenderSSRC uint32
+    Bitrate    uint64 //bits per second
+    SSRCs      []uint32
+}
+
+func (pkt *Remb) Decode(data []byte) error {
+    fb := Feedback{}
+    if err := fb.Decode(data); err != nil {
+        return err
+    }
+    if fb.PT != RTCP_PSFB || fb.FMT != PSFB_AFB || len(fb.FCI) < 8 || string(fb.FCI[:4]) != "REMB" {
+        return errors.New("rtcp packet is not remb")
+    }
+    num := int(fb.FCI[4])
+    if len(fb.FCI) < 8+num*4 {
+        return errors.New("remb rtcp packet need more data")
+    }
+    pkt.SenderSSRC = fb.SenderSSRC
+    exp := fb.FCI[5] >> 2
+    mantissa := uint64(fb.FCI[5]&0x03)<<16 | uint64(fb.FCI[6])<<8 | uint64(fb.FCI[7])
+    pkt.Bitrate = mantissa << exp
+    pkt.SSRCs = make([]uint32, num)
+    for i := 0; i < num; i++ {
+        pkt.SSRCs[i] = binary.BigEndian.Uint32(fb.FCI[8+i*4:])
+    }
+    return nil
+}
+
+func (pkt *Remb) Encode() []byte {
+    exp := uint8(0)
+    mantissa := pkt.Bitrate
+    for mantissa > 0x3FFFF {
+        mantissa >>= 1
+        exp++
+    }
+    fb := Feedback{Comm: Comm{PT: RTCP_PSFB}, FMT: PSFB_AFB, SenderSSRC: pkt.SenderSSRC}
+    fb.FCI = make([]byte, 8+4*len(pkt.SSRCs))
+    copy(fb.FCI, "REMB")
+    fb.FCI[4] = uint8(len(pkt.SSRCs))
+    fb.FCI[5] = exp<<2 | uint8(mantissa>>16)&0x03
+    fb.FCI[6] = uint8(mantissa >> 8)
+    fb.FCI[7] = uint8(mantissa)
+    for i, ssrc := range pkt.SSRCs {
+        binary.BigEndian.PutUint32(fb.FCI[8+i*4:], ssrc)
+    }
+    return fb.Encode()
+}
@@ -0,0 +1,77 @@
+package rtcp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestPli(t *testing.T) {
+	want := []byte{
+		0x81, 0xCE, 0x00, 0x02,
+		0x11, 0x11, 0x11, 0x11,
+		0x22, 0x22, 0x22, 0x22,
+	}
+	pli := &Pli{SenderSSRC: 0x11111111, MediaSSRC: 0x22222222}
+	if got := pli.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("Pli.Encode() = %x, want %x", got, want)
+	}
+	decoded := &Pli{}
+	if err := decoded.Decode(want); err != nil || *decoded != *pli {
+		t.Errorf("Pli.Decode() = %+v, %v", decoded, err)
+	}
+}
+
+func TestFir(t *testing.T) {
+	want := []byte{
+		0x84, 0xCE, 0x00, 0x06,
+		0x11, 0x11, 0x11, 0x11,
+		0x00, 0x00, 0x00, 0x00,
+		0x22, 0x22, 0x22, 0x22,
+		0x07, 0x00, 0x00, 0x00,
+		0x33, 0x33, 0x33, 0x33,
+		0x08, 0x00, 0x00, 0x00,
+	}
+	fir := &Fir{SenderSSRC: 0x11111111, Entries: []FirEntry{{SSRC: 0x22222222, SeqNum: 7}, {SSRC: 0x33333333, SeqNum: 8}}}
+	if got := fir.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("Fir.Encode() = %x, want %x", got, want)
+	}
+	decoded := &Fir{}
+	if err := decoded.Decode(want); err != nil || !reflect.DeepEqual(decoded, fir) {
+		t.Errorf("Fir.Decode() = %+v, %v", decoded, err)
+	}
+	if err := decoded.Decode((&Pli{}).Encode()); err == nil {
+		t.Errorf("Fir.Decode(pli) error = nil, want error")
+	}
+}
+
+func TestRemb(t *testing.T) {
+	//1000000 = 250000(0x3D090) << 2
+	want := []byte{
+		0x8F, 0xCE, 0x00, 0x05,
+		0x11, 0x11, 0x11, 0x11,
+		0x00, 0x00, 0x00, 0x00,
+		'R', 'E', 'M', 'B',
+		0x01, 0x0B, 0xD0, 0x90,
+		0x22, 0x22, 0x22, 0x22,
+	}
+	remb := &Remb{SenderSSRC: 0x11111111, Bitrate: 1000000, SSRCs: []uint32{0x22222222}}
+	if got := remb.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("Remb.Encode() = %x, want %x", got, want)
+	}
+	decoded := &Remb{}
+	if err := decoded.Decode(want); err != nil || !reflect.DeepEqual(decoded, remb) {
+		t.Errorf("Remb.Decode() = %+v, %v", decoded, err)
+	}
+	//the mantissa is truncated to 18 bits
+	remb.Bitrate = 1000001
+	if err := decoded.Decode(remb.Encode()); err != nil || decoded.Bitrate != 1000000 {
+		t.Errorf("Remb.Decode() bitrate = %d, %v", decoded.Bitrate, err)
+	}
+	//the ssrc list is longer than the packet
+	bad := append([]byte{}, want...)
+	bad[16] = 2
+	if err := decoded.Decode(bad); err == nil {
+		t.Errorf("Remb.Decode(bad num ssrc) error = nil, want error")
+	}
+}
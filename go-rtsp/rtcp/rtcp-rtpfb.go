@@ -0,0 +1,311 @@
+package rtcp
+
+import (
+    "encoding/binary"
+    "errors"
+    "sort"
+)
+
+// rfc4585 6.1 Common Packet Format for Feedback Messages
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |V=2|P|   FMT   |       PT      |          length               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                  SSRC of packet sender                        |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                  SSRC of media source                         |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// :            Feedback Control Information (FCI)                 :
+// :                                                               :
+
+type Feedback struct {
+    Comm
+    FMT        uint8
+    SenderSSRC uint32
+    MediaSSRC  uint32
+    FCI        []byte
+}
+
+func (pkt *Feedback) Decode(data []byte) error {
+    if err := pkt.Comm.Decode(data); err != nil {
+        return err
+    }
+    if pkt.PayloadLen < 8 || int(pkt.PayloadLen)+4 > len(data) {
+        return errors.New("feedback rtcp packet need more data")
+    }
+    pkt.FMT = data[0] & 0x1F
+    pkt.SenderSSRC = binary.BigEndian.Uint32(data[4:])
+    pkt.MediaSSRC = binary.BigEndian.Uint32(data[8:])
+    pkt.FCI = data[12 : 4+pkt.PayloadLen]
+    return nil
+}
+
+func (pkt *Feedback) Encode() []byte {
+    pkt.Comm.Length = uint16((8 + len(pkt.FCI) + 3) / 4)
+    data := pkt.Comm.Encode()
+    data[0] |= pkt.FMT & 0x1F
+    binary.BigEndian.PutUint32(data[4:], pkt.SenderSSRC)
+    binary.BigEndian.PutUint32(data[8:], pkt.MediaSSRC)
+    copy(data[12:], pkt.FCI)
+    return data
+}
+
+// rfc4585 6.2.1 Generic NACK
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |            PID                |             BLP               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+type NackPair struct {
+    PID uint16 //lost packet
+    BLP uint16 //bitmask of following lost packets
+}
+
+type Nack struct {
+    SenderSSRC uint32
+    MediaSSRC  uint32
+    Pairs      []NackPair
+}
+
+// lost sequence numbers are packed into PID/BLP pairs
+func NewNack(senderSSRC uint32, mediaSSRC uint32, lost []uint16) *Nack {
+    nack := &Nack{SenderSSRC: senderSSRC, MediaSSRC: mediaSSRC}
+    seqs := append([]uint16{}, lost...)
+    //sort in the order of sequence number wraparound,the first one is the oldest
+    if len(seqs) > 0 {
+        base := seqs[0]
+        for _, seq := range seqs {
+            if int16(seq-base) < 0 {
+                base = seq
+            }
+        }
+        sort.Slice(seqs, func(i, j int) bool {
+            return seqs[i]-base < seqs[j]-base
+        })
+    }
+    for _, seq := range seqs {
+        if n := len(nack.Pairs); n > 0 {
+            pair := &nack.Pairs[n-1]
+            if seq == pair.PID {
+                continue
+            }
+            if diff := seq - pair.PID; diff <= 16 {
+                pair.BLP |= 1 << (diff - 1)
+                continue
+            }
+        }
+        nack.Pairs = append(nack.Pairs, NackPair{PID: seq})
+    }
+    return nack
+}
+
+func (pkt *Nack) Sequences() []uint16 {
+    var seqs []uint16
+    for _, pair := range pkt.Pairs {
+        seqs = append(seqs, pair.PID)
+        for i := 0; i < 16; i++ {
+            if pair.BLP&(1<<i) != 0 {
+                seqs = append(seqs, pair.PID+uint16(i)+1)
+            }
+        }
+    }
+    return seqs
+}
+
+func (pkt *Nack) Decode(data []byte) error {
+    fb := Feedback{}
+    if err := fb.Decode(data); err != nil {
+        return err
+    }
+    if fb.PT != RTCP_RTPFB || fb.FMT != RTPFB_NACK {
+        return errors.New("rtcp packet is not generic nack")
+    }
+    pkt.SenderSSRC = fb.SenderSSRC
+    pkt.MediaSSRC = fb.MediaSSRC
+    pkt.Pairs = pkt.Pairs[:0]
+    for i := 0; i+4 <= len(fb.FCI); i += 4 {
+        pkt.Pairs = append(pkt.Pairs, NackPair{PID: binary.BigEndian.Uint16(fb.FCI[i:]), BLP: binary.BigEndian.Uint16(fb.FCI[i+2:])})
+    }
+    return nil
+}
+
+func (pkt *Nack) Encode() []byte {
+    fb := Feedback{Comm: Comm{PT: RTCP_RTPFB}, FMT: RTPFB_NACK, SenderSSRC: pkt.SenderSSRC, MediaSSRC: pkt.MediaSSRC}
+    fb.FCI = make([]byte, 4*len(pkt.Pairs))
+    for i, pair := range pkt.Pairs {
+        binary.BigEndian.PutUint16(fb.FCI[i*4:], pair.PID)
+        binary.BigEndian.PutUint16(fb.FCI[i*4+2:], pair.BLP)
+    }
+    return fb.Encode()
+}
+
+// draft-holmer-rmcat-transport-wide-cc-extensions-01 3.1 Transport-wide RTCP Feedback Message
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      base sequence number     |      packet status count      |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                 reference time                | fb pkt. count |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |          packet chunk         |         packet chunk          |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// .                                                               .
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |         packet chunk          |  recv delta   |  recv delta   |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// .                                                               .
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |           recv delta          |  recv delta   | zero padding  |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+const (
+    TWCC_NOT_RECEIVED = 0
+    TWCC_SMALL_DELTA  = 1 //0~63.75ms
+    TWCC_LARGE_DELTA  = 2 //negative or larger delta
+)
+
+type TwccPacket struct {
+    Sequence uint16
+    Status   uint8 //TWCC_NOT_RECEIVED/TWCC_SMALL_DELTA/TWCC_LARGE_DELTA
+    Delta    int32 //250us,relative to reference time for the first received packet,otherwise to the previous one
+}
+
+type TransportCC struct {
+    SenderSSRC    uint32
+    MediaSSRC     uint32
+    BaseSequence  uint16
+    ReferenceTime int32 //64ms,24 bits signed
+    FbPktCount    uint8
+    Packets       []TwccPacket
+}
+
+// the status is derived from delta if it is received
+func NewTwccPacket(seq uint16, received bool, delta int32) TwccPacket {
+    pkt := TwccPacket{Sequence: seq, Delta: delta}
+    if !received {
+        pkt.Status = TWCC_NOT_RECEIVED
+        pkt.Delta = 0
+    } else if delta >= 0 && delta <= 0xFF {
+        pkt.Status = TWCC_SMALL_DELTA
+    } else {
+        pkt.Status = TWCC_LARGE_DELTA
+    }
+    return pkt
+}
+
+func (pkt *TransportCC) Decode(data []byte) error {
+    fb := Feedback{}
+    if err := fb.Decode(data); err != nil {
+        return err
+    }
+    if fb.PT != RTCP_RTPFB || fb.FMT != RTPFB_TWCC {
+        return errors.New("rtcp packet is not transport-wide cc feedback")
+    }
+    fci := fb.FCI
+    if len(fci) < 8 {
+        return errors.New("transport-wide cc feedback need more data")
+    }
+    pkt.SenderSSRC = fb.SenderSSRC
+    pkt.MediaSSRC = fb.MediaSSRC
+    pkt.BaseSequence = binary.BigEndian.Uint16(fci)
+    count := int(binary.BigEndian.Uint16(fci[2:]))
+    pkt.ReferenceTime = int32(binary.BigEndian.Uint32(fci[4:])) >> 8
+    pkt.FbPktCount = fci[7]
+    pkt.Packets = make([]TwccPacket, 0, count)
+    offset := 8
+    for len(pkt.Packets) < count {
+        if offset+2 > len(fci) {
+            return errors.New("transport-wide cc feedback need more chunks")
+        }
+        chunk := binary.BigEndian.Uint16(fci[offset:])
+        offset += 2
+        var status []uint8
+        if chunk&0x8000 == 0 {
+            //run length chunk
+            for i := 0; i < int(chunk&0x1FFF); i++ {
+                status = append(status, uint8(chunk>>13)&0x03)
+            }
+        } else if chunk&0x4000 == 0 {
+            //status vector chunk of 14 1-bit symbols
+            for i := 13; i >= 0; i-- {
+                status = append(status, uint8(chunk>>i)&0x01)
+            }
+        } else {
+            //status vector chunk of 7 2-bit symbols
+            for i := 6; i >= 0; i-- {
+                status = append(status, uint8(chunk>>(2*i))&0x03)
+            }
+        }
+        for _, s := range status {
+            if len(pkt.Packets) == count {
+                break
+            }
+            pkt.Packets = append(pkt.Packets, TwccPacket{Sequence: pkt.BaseSequence + uint16(len(pkt.Packets)), Status: s})
+        }
+    }
+    for i := range pkt.Packets {
+        switch pkt.Packets[i].Status {
+        case TWCC_SMALL_DELTA:
+            if offset+1 > len(fci) {
+                return errors.New("transport-wide cc feedback need more deltas")
+            }
+            pkt.Packets[i].Delta = int32(fci[offset])
+            offset++
+        case TWCC_LARGE_DELTA:
+            if offset+2 > len(fci) {
+                return errors.New("transport-wide cc feedback need more deltas")
+            }
+            pkt.Packets[i].Delta = int32(int16(binary.BigEndian.Uint16(fci[offset:])))
+            offset += 2
+        }
+    }
+    return nil
+}
+
+// a run of 7 or more identical status is written as run length chunk,
+// others are written as 2-bit status vector chunks
+func (pkt *TransportCC) Encode() []byte {
+    fci := make([]byte, 8, 8+len(pkt.Packets)*2)
+    binary.BigEndian.PutUint16(fci, pkt.BaseSequence)
+    binary.BigEndian.PutUint16(fci[2:], uint16(len(pkt.Packets)))
+    binary.BigEndian.PutUint32(fci[4:], uint32(pkt.ReferenceTime)<<8|uint32(pkt.FbPktCount))
+    for i := 0; i < len(pkt.Packets); {
+        run := 1
+        for i+run < len(pkt.Packets) && run < 0x1FFF && pkt.Packets[i+run].Status == pkt.Packets[i].Status {
+            run++
+        }
+        var chunk uint16
+        if run >= 7 {
+            chunk = uint16(pkt.Packets[i].Status&0x03)<<13 | uint16(run)
+            i += run
+        } else {
+            chunk = 0xC000
+            for j := 0; j < 7; j++ {
+                if i < len(pkt.Packets) {
+                    chunk |= uint16(pkt.Packets[i].Status&0x03) << (2 * (6 - j))
+                    i++
+                }
+            }
+        }
+        fci = append(fci, byte(chunk>>8), byte(chunk))
+    }
+    for _, p := range pkt.Packets {
+        switch p.Status {
+        case TWCC_SMALL_DELTA:
+            fci = append(fci, byte(p.Delta))
+        case TWCC_LARGE_DELTA:
+            fci = append(fci, byte(uint16(p.Delta)>>8), byte(p.Delta))
+        }
+    }
+    fb := Feedback{Comm: Comm{PT: RTCP_RTPFB}, FMT: RTPFB_TWCC, SenderSSRC: pkt.SenderSSRC, MediaSSRC: pkt.MediaSSRC, FCI: fci}
+    if pad := (4 - len(fci)%4) % 4; pad > 0 {
+        fb.Padding = true
+        fb.PaddingData = make([]byte, pad-1)
+    }
+    return fb.Encode()
+}
@@ -0,0 +1,138 @@
+package rtcp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestNack_Encode(t *testing.T) {
+	//the lost packets wrap around 65535
+	nack := NewNack(0x11111111, 0x22222222, []uint16{3, 0, 65535, 20, 65534, 0})
+	wantPairs := []NackPair{{PID: 65534, BLP: 0x0013}, {PID: 20, BLP: 0}}
+	if !reflect.DeepEqual(nack.Pairs, wantPairs) {
+		t.Fatalf("NewNack() pairs = %v, want %v", nack.Pairs, wantPairs)
+	}
+	want := []byte{
+		0x81, 0xCD, 0x00, 0x04,
+		0x11, 0x11, 0x11, 0x11,
+		0x22, 0x22, 0x22, 0x22,
+		0xFF, 0xFE, 0x00, 0x13,
+		0x00, 0x14, 0x00, 0x00,
+	}
+	if got := nack.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("Nack.Encode() = %x, want %x", got, want)
+	}
+}
+
+func TestNack_Decode(t *testing.T) {
+	data := []byte{
+		0x81, 0xCD, 0x00, 0x04,
+		0x11, 0x11, 0x11, 0x11,
+		0x22, 0x22, 0x22, 0x22,
+		0xFF, 0xFE, 0x00, 0x13,
+		0x00, 0x14, 0x00, 0x00,
+	}
+	nack := &Nack{}
+	if err := nack.Decode(data); err != nil {
+		t.Fatalf("Nack.Decode() error = %v", err)
+	}
+	if nack.SenderSSRC != 0x11111111 || nack.MediaSSRC != 0x22222222 {
+		t.Errorf("Nack.Decode() ssrc = %x %x", nack.SenderSSRC, nack.MediaSSRC)
+	}
+	want := []uint16{65534, 65535, 0, 3, 20}
+	if got := nack.Sequences(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Nack.Sequences() = %v, want %v", got, want)
+	}
+	pli := (&Pli{SenderSSRC: 1, MediaSSRC: 2}).Encode()
+	if err := nack.Decode(pli); err == nil {
+		t.Errorf("Nack.Decode(pli) error = nil, want error")
+	}
+	if err := nack.Decode(data[:16]); err == nil {
+		t.Errorf("Nack.Decode(truncated) error = nil, want error")
+	}
+}
+
+func TestTransportCC_RunLength(t *testing.T) {
+	twcc := &TransportCC{
+		SenderSSRC:    0x11111111,
+		MediaSSRC:     0x22222222,
+		BaseSequence:  100,
+		ReferenceTime: 0x000102,
+		FbPktCount:    5,
+	}
+	for seq := uint16(100); seq < 110; seq++ {
+		twcc.Packets = append(twcc.Packets, NewTwccPacket(seq, false, 0))
+	}
+	twcc.Packets = append(twcc.Packets, NewTwccPacket(110, true, 4), NewTwccPacket(111, true, -4), NewTwccPacket(112, true, 255))
+	//a run length chunk of 10 not received packets,then a 2-bit status vector chunk
+	want := []byte{
+		0x8F, 0xCD, 0x00, 0x06,
+		0x11, 0x11, 0x11, 0x11,
+		0x22, 0x22, 0x22, 0x22,
+		0x00, 0x64, 0x00, 0x0D,
+		0x00, 0x01, 0x02, 0x05,
+		0x00, 0x0A, 0xD9, 0x00,
+		0x04, 0xFF, 0xFC, 0xFF,
+	}
+	got := twcc.Encode()
+	if !bytes.Equal(got, want) {
+		t.Fatalf("TransportCC.Encode() = %x, want %x", got, want)
+	}
+	decoded := &TransportCC{}
+	if err := decoded.Decode(got); err != nil {
+		t.Fatalf("TransportCC.Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, twcc) {
+		t.Errorf("TransportCC.Decode() = %+v, want %+v", decoded, twcc)
+	}
+}
+
+func TestTransportCC_OneBitVector(t *testing.T) {
+	//a 1-bit status vector chunk of 14 symbols,the first packet is received
+	data := []byte{
+		0xAF, 0xCD, 0x00, 0x05,
+		0x11, 0x11, 0x11, 0x11,
+		0x22, 0x22, 0x22, 0x22,
+		0xFF, 0xFF, 0x00, 0x0E,
+		0xFF, 0xFF, 0xFE, 0x00,
+		0xA0, 0x00, 0x07, 0x01,
+	}
+	twcc := &TransportCC{}
+	if err := twcc.Decode(data); err != nil {
+		t.Fatalf("TransportCC.Decode() error = %v", err)
+	}
+	if twcc.ReferenceTime != -2 || len(twcc.Packets) != 14 {
+		t.Fatalf("TransportCC.Decode() reference time %d, %d packets", twcc.ReferenceTime, len(twcc.Packets))
+	}
+	if p := twcc.Packets[0]; p.Sequence != 65535 || p.Status != TWCC_SMALL_DELTA || p.Delta != 7 {
+		t.Errorf("packet 0 = %+v", p)
+	}
+	for i, p := range twcc.Packets[1:] {
+		if p.Sequence != uint16(i) || p.Status != TWCC_NOT_RECEIVED {
+			t.Errorf("packet %d = %+v, want not received", i+1, p)
+		}
+	}
+	if err := twcc.Decode(data[:20]); err == nil {
+		t.Errorf("TransportCC.Decode(truncated) error = nil, want error")
+	}
+}
+
+func TestTransportCC_RoundTrip(t *testing.T) {
+	twcc := &TransportCC{SenderSSRC: 1, MediaSSRC: 2, BaseSequence: 65530, ReferenceTime: 100, FbPktCount: 1}
+	deltas := []int32{10, -1, 0, 300, 255, 256, 1, 2}
+	for i, delta := range deltas {
+		twcc.Packets = append(twcc.Packets, NewTwccPacket(65530+uint16(i), i != 3, delta))
+	}
+	data := twcc.Encode()
+	if len(data)%4 != 0 {
+		t.Fatalf("TransportCC.Encode() length %d is not aligned", len(data))
+	}
+	decoded := &TransportCC{}
+	if err := decoded.Decode(data); err != nil {
+		t.Fatalf("TransportCC.Decode() error = %v", err)
+	}
+	if !reflect.DeepEqual(decoded, twcc) {
+		t.Errorf("TransportCC.Decode() = %+v, want %+v", decoded, twcc)
+	}
+}
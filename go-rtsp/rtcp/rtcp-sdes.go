@@ -1,6 +1,9 @@
 package rtcp
 
-import "encoding/binary"
+import (
+    "encoding/binary"
+    "errors"
+)
 
 //           0                   1                   2                   3
 //           0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
@@ -39,7 +42,7 @@ func (item *ChunkItem) Encode() []byte {
     data := make([]byte, 2+len(item.Txt))
     data[0] = item.Type
     data[1] = item.Length
-    copy(data, item.Txt)
+    copy(data[2:], item.Txt)
     return data
 }
 
@@ -47,7 +50,7 @@ func MakeCNameItem(name []byte) *ChunkItem {
     return &ChunkItem{
         Type:   SDES_CNAME,
         Length: uint8(len(name)),
-        Txt:    make([]byte, len(name)),
+        Txt:    append([]byte{}, name...),
     }
 }
 
@@ -73,17 +76,35 @@ func (pkt *SourceDescription) Decode(data []byte) error {
         return err
     }
     pkt.SC = data[0] & 0x1F
+    end := 4 + int(pkt.PayloadLen)
+    if end > len(data) {
+        return errors.New("sdes rtcp packet need more data")
+    }
     offset := 4
     for i := 0; i < int(pkt.SC); i++ {
+        if offset+4 > end {
+            return errors.New("sdes rtcp packet need more data")
+        }
         chk := SDESChunk{}
-        chk.SSRC = binary.BigEndian.Uint32(data[4:])
+        chk.SSRC = binary.BigEndian.Uint32(data[offset:])
         offset += 4
-        chk.Item = &ChunkItem{
-            Type:   data[offset],
-            Length: data[offset+1],
+        //the items end with a null item,the chunk is padded to 32 bits
+        for offset < end && data[offset] != 0 {
+            if offset+2 > end || offset+2+int(data[offset+1]) > end {
+                return errors.New("sdes item need more data")
+            }
+            if chk.Item == nil {
+                chk.Item = &ChunkItem{
+                    Type:   data[offset],
+                    Length: data[offset+1],
+                }
+                chk.Item.Txt = make([]byte, chk.Item.Length)
+                copy(chk.Item.Txt, data[offset+2:offset+2+int(chk.Item.Length)])
+            }
+            offset += 2 + int(data[offset+1])
         }
-        chk.Item.Txt = make([]byte, chk.Item.Length)
-        copy(chk.Item.Txt, data[offset+2:offset+2+int(chk.Item.Length)])
+        offset = (offset + 4) / 4 * 4
+        pkt.Chunks = append(pkt.Chunks, chk)
     }
     return nil
 }
@@ -100,7 +121,7 @@ func (pkt *SourceDescription) Encode() []byte {
         data[offset] = chk.Item.Type
         data[offset+1] = chk.Item.Length
         copy(data[offset+2:], chk.Item.Txt)
-        offset += 2 + len(chk.Item.Txt)
+        offset += chk.size() - 4
     }
     return data
 }
@@ -108,13 +129,12 @@ func (pkt *SourceDescription) Encode() []byte {
 func (pkt *SourceDescription) calcLength() uint16 {
     length := 0
     for _, chk := range pkt.Chunks {
-        length += 4
-        length += int(chk.Item.Length) + 2
-    }
-    length += 1
-    if length%4 == 0 {
-        return uint16(length) / 4
-    } else {
-        return uint16(length)/4 + 1
+        length += chk.size()
     }
+    return uint16(length / 4)
+}
+
+// the item and the null item,padded to 32 bits
+func (chk *SDESChunk) size() int {
+    return 4 + (2+int(chk.Item.Length)+1+3)/4*4
 }
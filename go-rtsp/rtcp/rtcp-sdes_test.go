@@ -0,0 +1,75 @@
+package rtcp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSourceDescription_Decode(t *testing.T) {
+	//the first chunk has CNAME and TOOL items,only the first item is kept
+	data := []byte{
+		0x82, 0xCA, 0x00, 0x07,
+		0x11, 0x11, 0x11, 0x11,
+		0x01, 0x04, 'h', 'o',
+		's', 't', 0x06, 0x02,
+		'g', 'm', 0x00, 0x00,
+		0x22, 0x22, 0x22, 0x22,
+		0x01, 0x02, 'a', 'b',
+		0x00, 0x00, 0x00, 0x00,
+	}
+	sdes := NewSourceDescription()
+	if err := sdes.Decode(data); err != nil {
+		t.Fatalf("SourceDescription.Decode() error = %v", err)
+	}
+	if sdes.SC != 2 || len(sdes.Chunks) != 2 {
+		t.Fatalf("SourceDescription.Decode() %d chunks", len(sdes.Chunks))
+	}
+	want := []struct {
+		ssrc uint32
+		txt  string
+	}{
+		{0x11111111, "host"},
+		{0x22222222, "ab"},
+	}
+	for i, chk := range sdes.Chunks {
+		if chk.SSRC != want[i].ssrc || chk.Item == nil || chk.Item.Type != SDES_CNAME || string(chk.Item.Txt) != want[i].txt {
+			t.Errorf("chunk %d = %x %+v, want %x %q", i, chk.SSRC, chk.Item, want[i].ssrc, want[i].txt)
+		}
+	}
+
+	truncated := append([]byte{}, data[:20]...)
+	truncated[3] = 0x04
+	if err := NewSourceDescription().Decode(truncated); err == nil {
+		t.Errorf("SourceDescription.Decode(truncated) error = nil, want error")
+	}
+}
+
+func TestSourceDescription_Encode(t *testing.T) {
+	//each chunk ends with a null item and is padded to 32 bits
+	want := []byte{
+		0x82, 0xCA, 0x00, 0x06,
+		0x11, 0x11, 0x11, 0x11,
+		0x01, 0x04, 'h', 'o',
+		's', 't', 0x00, 0x00,
+		0x22, 0x22, 0x22, 0x22,
+		0x01, 0x02, 'a', 'b',
+		0x00, 0x00, 0x00, 0x00,
+	}
+	sdes := NewSourceDescription()
+	sdes.Chunks = []SDESChunk{
+		{SSRC: 0x11111111, Item: MakeCNameItem([]byte("host"))},
+		{SSRC: 0x22222222, Item: MakeCNameItem([]byte("ab"))},
+	}
+	got := sdes.Encode()
+	if !bytes.Equal(got, want) {
+		t.Fatalf("SourceDescription.Encode() = %x, want %x", got, want)
+	}
+	decoded := NewSourceDescription()
+	if err := decoded.Decode(got); err != nil || len(decoded.Chunks) != 2 || string(decoded.Chunks[1].Item.Txt) != "ab" {
+		t.Errorf("SourceDescription.Decode() = %+v, %v", decoded.Chunks, err)
+	}
+	item := MakeCNameItem([]byte("ab"))
+	if got := item.Encode(); !bytes.Equal(got, []byte{0x01, 0x02, 'a', 'b'}) {
+		t.Errorf("ChunkItem.Encode() = %x", got)
+	}
+}
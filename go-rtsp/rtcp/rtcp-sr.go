@@ -2,6 +2,7 @@ package rtcp
 
 import (
     "encoding/binary"
+    "errors"
 )
 
 // 0                   1                   2                   3
@@ -62,6 +63,9 @@ func (pkt *SenderReport) Decode(data []byte) error {
         return err
     }
     pkt.RC = data[0] & 0x1f
+    if len(data) < 28+int(pkt.RC)*24 {
+        return errors.New("sr rtcp packet need more data")
+    }
     pkt.SSRC = binary.BigEndian.Uint32(data[4:])
     pkt.NTP = binary.BigEndian.Uint64(data[8:])
     pkt.RtpTimestamp = binary.BigEndian.Uint32(data[16:])
@@ -74,6 +78,7 @@ func (pkt *SenderReport) Decode(data []byte) error {
             return err
         }
         pkt.Blocks = append(pkt.Blocks, block)
+        offset += 24
     }
     return nil
 }
@@ -0,0 +1,66 @@
+package rtcp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var testSenderReport = []byte{
+	0x81, 0xC8, 0x00, 0x0C,
+	0x11, 0x11, 0x11, 0x11,
+	0x01, 0x02, 0x03, 0x04,
+	0x05, 0x06, 0x07, 0x08,
+	0x00, 0x00, 0x0B, 0xB8,
+	0x00, 0x00, 0x00, 0x0A,
+	0x00, 0x00, 0x03, 0xE8,
+	//the report block begins at 28
+	0x22, 0x22, 0x22, 0x22,
+	0x40, 0x00, 0x01, 0x02,
+	0x00, 0x01, 0x00, 0x05,
+	0x00, 0x00, 0x00, 0x10,
+	0x12, 0x34, 0x56, 0x78,
+	0x00, 0x01, 0x00, 0x00,
+}
+
+func TestSenderReport_Decode(t *testing.T) {
+	sr := NewSenderReport()
+	if err := sr.Decode(testSenderReport); err != nil {
+		t.Fatalf("SenderReport.Decode() error = %v", err)
+	}
+	if sr.SSRC != 0x11111111 || sr.NTP != 0x0102030405060708 || sr.RtpTimestamp != 3000 || sr.SendPacketCount != 10 || sr.SendOctetCount != 1000 {
+		t.Errorf("SenderReport.Decode() sender info = %+v", sr)
+	}
+	want := []ReportBlock{{
+		SSRC:             0x22222222,
+		Fraction:         0x40,
+		Lost:             0x000102,
+		ExtendHighestSeq: 0x00010005,
+		Jitter:           16,
+		Lsr:              0x12345678,
+		Dlsr:             0x00010000,
+	}}
+	if !reflect.DeepEqual(sr.Blocks, want) {
+		t.Fatalf("SenderReport.Decode() blocks = %+v, want %+v", sr.Blocks, want)
+	}
+	if got := sr.Encode(); !bytes.Equal(got, testSenderReport) {
+		t.Errorf("SenderReport.Encode() = %x, want %x", got, testSenderReport)
+	}
+	if err := NewSenderReport().Decode(testSenderReport[:40]); err == nil {
+		t.Errorf("SenderReport.Decode(truncated block) error = nil, want error")
+	}
+}
+
+func TestReceiverReport_Decode(t *testing.T) {
+	data := append([]byte{0x81, 0xC9, 0x00, 0x07, 0x33, 0x33, 0x33, 0x33}, testSenderReport[28:]...)
+	rr := NewReceiverReport()
+	if err := rr.Decode(data); err != nil {
+		t.Fatalf("ReceiverReport.Decode() error = %v", err)
+	}
+	if rr.SSRC != 0x33333333 || len(rr.Blocks) != 1 || rr.Blocks[0].SSRC != 0x22222222 || rr.Blocks[0].Lost != 0x000102 {
+		t.Errorf("ReceiverReport.Decode() = %+v", rr)
+	}
+	if got := rr.Encode(); !bytes.Equal(got, data) {
+		t.Errorf("ReceiverReport.Encode() = %x, want %x", got, data)
+	}
+}
@@ -6,4 +6,20 @@ const (
     RTCP_SDES = 202
     RTCP_BYE  = 203
     RTCP_APP  = 204
+    //rfc4585 feedback messages
+    RTCP_RTPFB = 205
+    RTCP_PSFB  = 206
+)
+
+//FMT of transport layer feedback
+const (
+    RTPFB_NACK = 1
+    RTPFB_TWCC = 15 //draft-holmer-rmcat-transport-wide-cc-extensions
+)
+
+//FMT of payload-specific feedback
+const (
+    PSFB_PLI = 1
+    PSFB_FIR = 4  //rfc5104
+    PSFB_AFB = 15 //application layer feedback,e.g. REMB
 )
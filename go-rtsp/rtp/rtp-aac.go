@@ -3,7 +3,6 @@ package rtp
 import (
     "encoding/binary"
     "errors"
-    "fmt"
 
     "github.com/yapingcat/gomedia/go-codec"
 )
@@ -62,7 +61,6 @@ func (packer *AACPacker) Pack(data []byte, timestamp uint32) error {
     if len(data)+4+RTP_FIX_HEAD_LEN > packer.mtu {
         return errors.New("unsupport fragment aac into multi rtp packet")
     }
-    fmt.Println("pack aac")
     pkg := RtpPacket{}
     pkg.Header.PayloadType = packer.pt
     pkg.Header.SequenceNumber = packer.sequence
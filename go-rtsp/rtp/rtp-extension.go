@@ -0,0 +1,423 @@
+package rtp
+
+import (
+    "encoding/binary"
+    "errors"
+    "sort"
+    "time"
+)
+
+// rfc8285 A General Mechanism for RTP Header Extensions
+//
+// one-byte header
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       0xBE    |    0xDE       |           length=3            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |  ID   | L=0   |     data      |  ID   |  L=1  |   data...
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// two-byte header
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       0x100         |appbits|           length=3            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      ID       |     L=0       |     ID        |     L=1       |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       data    |    0 (pad)    |       ID      |      L=4      |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+const (
+    RTP_EXTENSION_ONE_BYTE_PROFILE = 0xBEDE
+    RTP_EXTENSION_TWO_BYTE_PROFILE = 0x1000
+    RTP_EXTENSION_ONVIF_PROFILE    = 0xABAC
+    RTP_EXTENSION_MAX_ONE_BYTE_ID  = 14
+)
+
+// uri of a=extmap
+const (
+    EXTMAP_ABS_SEND_TIME = "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"
+    EXTMAP_TRANSPORT_CC  = "http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01"
+    EXTMAP_AUDIO_LEVEL   = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+    EXTMAP_PLAYOUT_DELAY = "http://www.webrtc.org/experiments/rtp-hdrext/playout-delay"
+    EXTMAP_ONVIF_REPLAY  = "http://www.onvif.org/ver20/streaming/replay"
+)
+
+var errNotRfc8285 = errors.New("rtp header extension is not rfc8285")
+
+type RtpExtension struct {
+    Id      uint8
+    Payload []byte
+}
+
+// ExtensionMap is the mapping between the extension id and uri negotiated by a=extmap
+type ExtensionMap struct {
+    uris map[uint8]string
+    ids  map[string]uint8
+}
+
+func NewExtensionMap() *ExtensionMap {
+    return &ExtensionMap{
+        uris: make(map[uint8]string),
+        ids:  make(map[string]uint8),
+    }
+}
+
+// id 1-14 for one-byte header,1-255 for two-byte header
+func (m *ExtensionMap) Register(id uint8, uri string) error {
+    if id == 0 {
+        return errors.New("rtp header extension id must be 1-255")
+    }
+    if old, found := m.uris[id]; found {
+        delete(m.ids, old)
+    }
+    if old, found := m.ids[uri]; found {
+        delete(m.uris, old)
+    }
+    m.uris[id] = uri
+    m.ids[uri] = id
+    return nil
+}
+
+// 0 if the uri is not registered
+func (m *ExtensionMap) Id(uri string) uint8 {
+    return m.ids[uri]
+}
+
+func (m *ExtensionMap) Uri(id uint8) string {
+    return m.uris[id]
+}
+
+// registered ids in ascending order
+func (m *ExtensionMap) Ids() []uint8 {
+    ids := make([]uint8, 0, len(m.uris))
+    for id := range m.uris {
+        ids = append(ids, id)
+    }
+    sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+    return ids
+}
+
+// the profile of rfc8285 header extension is 0xBEDE(one-byte) or 0x100X(two-byte)
+func isRfc8285Profile(profile uint16) bool {
+    return profile == RTP_EXTENSION_ONE_BYTE_PROFILE || profile&0xFFF0 == RTP_EXTENSION_TWO_BYTE_PROFILE
+}
+
+// data is the whole header extension,including profile and length
+func DecodeHeaderExtension(data []byte) ([]RtpExtension, error) {
+    if len(data) < 4 {
+        return nil, errors.New("rtp extension need 4 bytes at least")
+    }
+    profile := binary.BigEndian.Uint16(data)
+    length := int(binary.BigEndian.Uint16(data[2:])) * 4
+    if len(data)-4 < length {
+        return nil, errors.New("rtp extension need more bytes")
+    }
+    if !isRfc8285Profile(profile) {
+        return nil, errNotRfc8285
+    }
+    data = data[4 : 4+length]
+    var exts []RtpExtension
+    for len(data) > 0 {
+        var id uint8
+        var l int
+        if profile == RTP_EXTENSION_ONE_BYTE_PROFILE {
+            id = data[0] >> 4
+            if id == 0 {
+                data = data[1:]
+                continue
+            } else if id == 15 {
+                break
+            }
+            l = int(data[0]&0x0F) + 1
+            data = data[1:]
+        } else {
+            id = data[0]
+            if id == 0 {
+                data = data[1:]
+                continue
+            }
+            if len(data) < 2 {
+                return nil, errors.New("rtp extension need more bytes")
+            }
+            l = int(data[1])
+            data = data[2:]
+        }
+        if len(data) < l {
+            return nil, errors.New("rtp extension need more bytes")
+        }
+        exts = append(exts, RtpExtension{Id: id, Payload: data[:l]})
+        data = data[l:]
+    }
+    return exts, nil
+}
+
+// one-byte header is used if all the ids are 1-14 and payloads are 1-16 bytes,
+// otherwise two-byte header,nil if exts is empty
+func EncodeHeaderExtension(exts []RtpExtension) ([]byte, error) {
+    if len(exts) == 0 {
+        return nil, nil
+    }
+    oneByte := true
+    size := 4
+    for _, ext := range exts {
+        if ext.Id == 0 || len(ext.Payload) > 255 {
+            return nil, errors.New("invalid rtp header extension element")
+        }
+        if ext.Id > RTP_EXTENSION_MAX_ONE_BYTE_ID || len(ext.Payload) == 0 || len(ext.Payload) > 16 {
+            oneByte = false
+        }
+        size += 2 + len(ext.Payload)
+    }
+    data := make([]byte, 4, (size+3)/4*4)
+    if oneByte {
+        binary.BigEndian.PutUint16(data, RTP_EXTENSION_ONE_BYTE_PROFILE)
+    } else {
+        binary.BigEndian.PutUint16(data, RTP_EXTENSION_TWO_BYTE_PROFILE)
+    }
+    for _, ext := range exts {
+        if oneByte {
+            data = append(data, ext.Id<<4|uint8(len(ext.Payload)-1))
+        } else {
+            data = append(data, ext.Id, uint8(len(ext.Payload)))
+        }
+        data = append(data, ext.Payload...)
+    }
+    for len(data)%4 != 0 {
+        data = append(data, 0)
+    }
+    binary.BigEndian.PutUint16(data[2:], uint16(len(data)/4-1))
+    return data, nil
+}
+
+// the rfc8285 elements,including the ones following the onvif replay extension
+func (pkg *RtpPacket) HeaderExtensions() ([]RtpExtension, error) {
+    _, rest := pkg.splitOnvifReplay()
+    if len(rest) == 0 {
+        return nil, nil
+    }
+    return DecodeHeaderExtension(rest)
+}
+
+// nil if the extension is not found
+func (pkg *RtpPacket) GetExtension(id uint8) []byte {
+    if id == 0 {
+        return nil
+    }
+    exts, err := pkg.HeaderExtensions()
+    if err != nil {
+        return nil
+    }
+    for _, ext := range exts {
+        if ext.Id == id {
+            return ext.Payload
+        }
+    }
+    return nil
+}
+
+// add or replace the extension element,the onvif replay extension is kept
+func (pkg *RtpPacket) SetExtension(id uint8, payload []byte) error {
+    exts, err := pkg.HeaderExtensions()
+    if err != nil {
+        return err
+    }
+    replaced := false
+    for i := range exts {
+        if exts[i].Id == id {
+            exts[i].Payload = payload
+            replaced = true
+        }
+    }
+    if !replaced {
+        exts = append(exts, RtpExtension{Id: id, Payload: payload})
+    }
+    return pkg.setHeaderExtensions(exts)
+}
+
+func (pkg *RtpPacket) RemoveExtension(id uint8) error {
+    exts, err := pkg.HeaderExtensions()
+    if err != nil {
+        return err
+    }
+    remain := exts[:0]
+    for _, ext := range exts {
+        if ext.Id != id {
+            remain = append(remain, ext)
+        }
+    }
+    return pkg.setHeaderExtensions(remain)
+}
+
+func (pkg *RtpPacket) setHeaderExtensions(exts []RtpExtension) error {
+    data, err := EncodeHeaderExtension(exts)
+    if err != nil {
+        return err
+    }
+    if replay, _ := pkg.splitOnvifReplay(); replay != nil {
+        data = joinOnvifReplay(replay, data)
+    }
+    pkg.Extensions = data
+    return nil
+}
+
+// abs-send-time is 6.18 fixed point seconds,the middle 24 bits of NTP timestamp
+func AbsSendTime(t time.Time) uint32 {
+    sec := uint64(t.Unix()) + 0x83AA7E80
+    frac := uint64(t.Nanosecond()) << 32 / 1000000000
+    return uint32((sec<<32|frac)>>14) & 0x00FFFFFF
+}
+
+func (pkg *RtpPacket) SetAbsSendTime(id uint8, t time.Time) error {
+    ast := AbsSendTime(t)
+    return pkg.SetExtension(id, []byte{byte(ast >> 16), byte(ast >> 8), byte(ast)})
+}
+
+func (pkg *RtpPacket) GetAbsSendTime(id uint8) (uint32, bool) {
+    payload := pkg.GetExtension(id)
+    if len(payload) < 3 {
+        return 0, false
+    }
+    return uint32(payload[0])<<16 | uint32(payload[1])<<8 | uint32(payload[2]), true
+}
+
+// transport-wide sequence number of transport-cc
+func (pkg *RtpPacket) SetTransportSequence(id uint8, seq uint16) error {
+    return pkg.SetExtension(id, []byte{byte(seq >> 8), byte(seq)})
+}
+
+func (pkg *RtpPacket) GetTransportSequence(id uint8) (uint16, bool) {
+    payload := pkg.GetExtension(id)
+    if len(payload) < 2 {
+        return 0, false
+    }
+    return binary.BigEndian.Uint16(payload), true
+}
+
+// rfc6464,level is -dBov(0-127),voice is the V flag
+func (pkg *RtpPacket) SetAudioLevel(id uint8, voice bool, level uint8) error {
+    b := level & 0x7F
+    if voice {
+        b |= 0x80
+    }
+    return pkg.SetExtension(id, []byte{b})
+}
+
+func (pkg *RtpPacket) GetAudioLevel(id uint8) (voice bool, level uint8, ok bool) {
+    payload := pkg.GetExtension(id)
+    if len(payload) < 1 {
+        return false, 0, false
+    }
+    return payload[0]&0x80 != 0, payload[0] & 0x7F, true
+}
+
+// playout-delay,12 bits min delay and 12 bits max delay in 10ms
+func (pkg *RtpPacket) SetPlayoutDelay(id uint8, min time.Duration, max time.Duration) error {
+    minDelay := uint32(min/(10*time.Millisecond)) & 0xFFF
+    maxDelay := uint32(max/(10*time.Millisecond)) & 0xFFF
+    delay := minDelay<<12 | maxDelay
+    return pkg.SetExtension(id, []byte{byte(delay >> 16), byte(delay >> 8), byte(delay)})
+}
+
+func (pkg *RtpPacket) GetPlayoutDelay(id uint8) (min time.Duration, max time.Duration, ok bool) {
+    payload := pkg.GetExtension(id)
+    if len(payload) < 3 {
+        return 0, 0, false
+    }
+    delay := uint32(payload[0])<<16 | uint32(payload[1])<<8 | uint32(payload[2])
+    return time.Duration(delay>>12) * 10 * time.Millisecond, time.Duration(delay&0xFFF) * 10 * time.Millisecond, true
+}
+
+// ONVIF Streaming Specification 6.3 RTP header extension
+//
+//	0                   1                   2                   3
+//	0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+//
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      0xABAC                   |        length=3               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          NTP timestamp...                     |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                          NTP timestamp                        |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |C|E|D|T|mbz    |  CSeq         |        padding                |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+type OnvifReplay struct {
+    NtpTime       uint64
+    CleanPoint    bool
+    End           bool
+    Discontinuity bool
+    Terminate     bool
+    CSeq          uint8 //the low byte of CSeq of PLAY request
+}
+
+// 12 bytes,the payload of 0xABAC extension or rfc8285 element
+func (replay *OnvifReplay) Encode() []byte {
+    data := make([]byte, 12)
+    binary.BigEndian.PutUint64(data, replay.NtpTime)
+    if replay.CleanPoint {
+        data[8] |= 0x80
+    }
+    if replay.End {
+        data[8] |= 0x40
+    }
+    if replay.Discontinuity {
+        data[8] |= 0x20
+    }
+    if replay.Terminate {
+        data[8] |= 0x10
+    }
+    data[9] = replay.CSeq
+    return data
+}
+
+func (replay *OnvifReplay) Decode(data []byte) error {
+    if len(data) < 12 {
+        return errors.New("onvif replay extension need 12 bytes")
+    }
+    replay.NtpTime = binary.BigEndian.Uint64(data)
+    replay.CleanPoint = data[8]&0x80 != 0
+    replay.End = data[8]&0x40 != 0
+    replay.Discontinuity = data[8]&0x20 != 0
+    replay.Terminate = data[8]&0x10 != 0
+    replay.CSeq = data[9]
+    return nil
+}
+
+// the header extension begins with 0xABAC extension,
+// the rfc8285 elements(e.g. abs-send-time) are kept after it and counted in its length
+func (pkg *RtpPacket) SetOnvifReplay(replay *OnvifReplay) {
+    _, rest := pkg.splitOnvifReplay()
+    pkg.Extensions = joinOnvifReplay(replay.Encode(), rest)
+}
+
+// 0xABAC extension,or the rfc8285 element of id if it is not zero
+func (pkg *RtpPacket) GetOnvifReplay(id uint8) (*OnvifReplay, bool) {
+    payload, _ := pkg.splitOnvifReplay()
+    if payload == nil && id > 0 {
+        payload = pkg.GetExtension(id)
+    }
+    replay := &OnvifReplay{}
+    if replay.Decode(payload) != nil {
+        return nil, false
+    }
+    return replay, true
+}
+
+// the payload of 0xABAC extension and the header extension following it
+func (pkg *RtpPacket) splitOnvifReplay() (replay []byte, rest []byte) {
+    if len(pkg.Extensions) >= 16 && binary.BigEndian.Uint16(pkg.Extensions) == RTP_EXTENSION_ONVIF_PROFILE {
+        return pkg.Extensions[4:16], pkg.Extensions[16:]
+    }
+    return nil, pkg.Extensions
+}
+
+func joinOnvifReplay(replay []byte, rest []byte) []byte {
+    data := make([]byte, 4, 16+len(rest))
+    binary.BigEndian.PutUint16(data, RTP_EXTENSION_ONVIF_PROFILE)
+    binary.BigEndian.PutUint16(data[2:], uint16(3+len(rest)/4))
+    data = append(data, replay...)
+    return append(data, rest...)
+}
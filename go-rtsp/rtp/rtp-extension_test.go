@@ -0,0 +1,243 @@
+package rtp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHeaderExtension(t *testing.T) {
+	tests := []struct {
+		name string
+		exts []RtpExtension
+		data []byte
+	}{
+		{
+			name: "one-byte",
+			exts: []RtpExtension{{Id: 1, Payload: []byte{0xAA}}, {Id: 2, Payload: []byte{0x01, 0x02, 0x03}}},
+			data: []byte{0xBE, 0xDE, 0x00, 0x02, 0x10, 0xAA, 0x22, 0x01, 0x02, 0x03, 0x00, 0x00},
+		},
+		{
+			//the empty payload needs two-byte header
+			name: "two-byte",
+			exts: []RtpExtension{{Id: 1, Payload: []byte{}}, {Id: 20, Payload: []byte{0x01}}},
+			data: []byte{0x10, 0x00, 0x00, 0x02, 0x01, 0x00, 0x14, 0x01, 0x01, 0x00, 0x00, 0x00},
+		},
+		{
+			//id 15 is reserved in one-byte header
+			name: "id 15",
+			exts: []RtpExtension{{Id: 15, Payload: []byte{0x05}}},
+			data: []byte{0x10, 0x00, 0x00, 0x01, 0x0F, 0x01, 0x05, 0x00},
+		},
+		{
+			name: "17 bytes payload",
+			exts: []RtpExtension{{Id: 1, Payload: bytes.Repeat([]byte{0x11}, 17)}},
+			data: append(append([]byte{0x10, 0x00, 0x00, 0x05, 0x01, 0x11}, bytes.Repeat([]byte{0x11}, 17)...), 0x00),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeHeaderExtension(tt.exts)
+			if err != nil {
+				t.Fatalf("EncodeHeaderExtension() error = %v", err)
+			}
+			if !bytes.Equal(data, tt.data) {
+				t.Fatalf("EncodeHeaderExtension() = %x, want %x", data, tt.data)
+			}
+			exts, err := DecodeHeaderExtension(data)
+			if err != nil {
+				t.Fatalf("DecodeHeaderExtension() error = %v", err)
+			}
+			if !reflect.DeepEqual(exts, tt.exts) {
+				t.Errorf("DecodeHeaderExtension() = %+v, want %+v", exts, tt.exts)
+			}
+		})
+	}
+}
+
+func TestDecodeHeaderExtension(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []RtpExtension
+		wantErr bool
+	}{
+		{
+			name: "one-byte padding between elements",
+			data: []byte{0xBE, 0xDE, 0x00, 0x02, 0x10, 0xAA, 0x00, 0x00, 0x22, 0x01, 0x02, 0x03},
+			want: []RtpExtension{{Id: 1, Payload: []byte{0xAA}}, {Id: 2, Payload: []byte{0x01, 0x02, 0x03}}},
+		},
+		{
+			//the processing stops at id 15
+			name: "one-byte id 15",
+			data: []byte{0xBE, 0xDE, 0x00, 0x02, 0x10, 0xAA, 0xF0, 0x00, 0x22, 0x01, 0x02, 0x03},
+			want: []RtpExtension{{Id: 1, Payload: []byte{0xAA}}},
+		},
+		{
+			name: "two-byte appbits",
+			data: []byte{0x10, 0x03, 0x00, 0x01, 0x00, 0x05, 0x01, 0x07},
+			want: []RtpExtension{{Id: 5, Payload: []byte{0x07}}},
+		},
+		{name: "onvif profile", data: []byte{0xAB, 0xAC, 0x00, 0x00}, wantErr: true},
+		{name: "truncated extension", data: []byte{0xBE, 0xDE, 0x00, 0x02, 0x10, 0xAA}, wantErr: true},
+		{name: "truncated element", data: []byte{0xBE, 0xDE, 0x00, 0x01, 0x10, 0xAA, 0x23, 0x01}, wantErr: true},
+		{name: "two-byte truncated element", data: []byte{0x10, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x05}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeHeaderExtension(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeHeaderExtension() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeHeaderExtension() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if _, err := EncodeHeaderExtension([]RtpExtension{{Id: 0, Payload: []byte{1}}}); err == nil {
+		t.Errorf("EncodeHeaderExtension(id 0) error = nil, want error")
+	}
+	if _, err := EncodeHeaderExtension([]RtpExtension{{Id: 1, Payload: make([]byte, 256)}}); err == nil {
+		t.Errorf("EncodeHeaderExtension(256 bytes) error = nil, want error")
+	}
+}
+
+// the elements are kept through encoding and decoding of the packet
+func TestRtpPacket_Extensions(t *testing.T) {
+	pkg := &RtpPacket{Payload: []byte{0x01, 0x02}}
+	pkg.Header.PayloadType = 96
+	//abs-send-time of 1.5s is 0x060000 in 6.18 fixed point
+	if err := pkg.SetAbsSendTime(1, time.Unix(1, 500000000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.SetTransportSequence(2, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.SetAudioLevel(3, true, 30); err != nil {
+		t.Fatal(err)
+	}
+	if err := pkg.SetPlayoutDelay(4, 100*time.Millisecond, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := pkg.GetExtension(4); !bytes.Equal(got, []byte{0x00, 0xA0, 0xC8}) {
+		t.Errorf("playout-delay payload = %x, want 00a0c8", got)
+	}
+	if got := pkg.GetExtension(3); !bytes.Equal(got, []byte{0x9E}) {
+		t.Errorf("audio-level payload = %x, want 9e", got)
+	}
+
+	decoded := &RtpPacket{}
+	if err := decoded.Decode(pkg.Encode()); err != nil {
+		t.Fatalf("RtpPacket.Decode() error = %v", err)
+	}
+	if ast, ok := decoded.GetAbsSendTime(1); !ok || ast != 0x060000 {
+		t.Errorf("GetAbsSendTime() = %x %v, want 60000", ast, ok)
+	}
+	if seq, ok := decoded.GetTransportSequence(2); !ok || seq != 0x1234 {
+		t.Errorf("GetTransportSequence() = %x %v", seq, ok)
+	}
+	if voice, level, ok := decoded.GetAudioLevel(3); !ok || !voice || level != 30 {
+		t.Errorf("GetAudioLevel() = %v %d %v", voice, level, ok)
+	}
+	if min, max, ok := decoded.GetPlayoutDelay(4); !ok || min != 100*time.Millisecond || max != 2*time.Second {
+		t.Errorf("GetPlayoutDelay() = %v %v %v", min, max, ok)
+	}
+	if !bytes.Equal(decoded.Payload, []byte{0x01, 0x02}) {
+		t.Errorf("payload = %x", decoded.Payload)
+	}
+
+	if err := decoded.RemoveExtension(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decoded.GetTransportSequence(2); ok {
+		t.Errorf("the removed extension is found")
+	}
+	if _, ok := decoded.GetAbsSendTime(1); !ok {
+		t.Errorf("the other extension is removed")
+	}
+}
+
+func TestOnvifReplay(t *testing.T) {
+	replay := &OnvifReplay{NtpTime: 0x0102030405060708, CleanPoint: true, Discontinuity: true, CSeq: 7}
+	want := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0xA0, 0x07, 0x00, 0x00}
+	if got := replay.Encode(); !bytes.Equal(got, want) {
+		t.Fatalf("OnvifReplay.Encode() = %x, want %x", got, want)
+	}
+
+	//0xABAC header extension
+	pkg := &RtpPacket{Payload: []byte{0x01}}
+	pkg.SetOnvifReplay(replay)
+	decoded := &RtpPacket{}
+	if err := decoded.Decode(pkg.Encode()); err != nil {
+		t.Fatalf("RtpPacket.Decode() error = %v", err)
+	}
+	if !bytes.Equal(decoded.Extensions[:4], []byte{0xAB, 0xAC, 0x00, 0x03}) {
+		t.Errorf("extension header = %x", decoded.Extensions[:4])
+	}
+	if got, ok := decoded.GetOnvifReplay(0); !ok || *got != *replay {
+		t.Errorf("GetOnvifReplay() = %+v %v, want %+v", got, ok, replay)
+	}
+
+	//rfc8285 element
+	pkg = &RtpPacket{Payload: []byte{0x01}}
+	if err := pkg.SetExtension(5, replay.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	decoded = &RtpPacket{}
+	if err := decoded.Decode(pkg.Encode()); err != nil {
+		t.Fatalf("RtpPacket.Decode() error = %v", err)
+	}
+	if got, ok := decoded.GetOnvifReplay(5); !ok || *got != *replay {
+		t.Errorf("GetOnvifReplay(5) = %+v %v, want %+v", got, ok, replay)
+	}
+	if _, ok := decoded.GetOnvifReplay(0); ok {
+		t.Errorf("GetOnvifReplay(0) of rfc8285 extension = true")
+	}
+	if err := new(OnvifReplay).Decode(want[:11]); err == nil {
+		t.Errorf("OnvifReplay.Decode(11 bytes) error = nil, want error")
+	}
+}
+
+// the rfc8285 elements follow the 0xABAC extension
+func TestOnvifReplay_Merge(t *testing.T) {
+	replay := &OnvifReplay{NtpTime: 0x0102030405060708, CleanPoint: true, CSeq: 7}
+	pkg := &RtpPacket{Payload: []byte{0x01}}
+	if err := pkg.SetAbsSendTime(1, time.Unix(1, 500000000)); err != nil {
+		t.Fatal(err)
+	}
+	pkg.SetOnvifReplay(replay)
+	if err := pkg.SetTransportSequence(2, 0x1234); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0xAB, 0xAC, 0x00, 0x06,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x80, 0x07, 0x00, 0x00,
+		0xBE, 0xDE, 0x00, 0x02,
+		0x12, 0x06, 0x00, 0x00, 0x21, 0x12, 0x34, 0x00,
+	}
+	if !bytes.Equal(pkg.Extensions, want) {
+		t.Fatalf("Extensions = %x, want %x", pkg.Extensions, want)
+	}
+	decoded := &RtpPacket{}
+	if err := decoded.Decode(pkg.Encode()); err != nil {
+		t.Fatalf("RtpPacket.Decode() error = %v", err)
+	}
+	if got, ok := decoded.GetOnvifReplay(0); !ok || *got != *replay {
+		t.Errorf("GetOnvifReplay() = %+v %v, want %+v", got, ok, replay)
+	}
+	if ast, ok := decoded.GetAbsSendTime(1); !ok || ast != 0x060000 {
+		t.Errorf("GetAbsSendTime() = %x %v, want 60000", ast, ok)
+	}
+	if seq, ok := decoded.GetTransportSequence(2); !ok || seq != 0x1234 {
+		t.Errorf("GetTransportSequence() = %x %v", seq, ok)
+	}
+	//the replay extension is replaced,the elements are kept
+	decoded.SetOnvifReplay(&OnvifReplay{End: true})
+	if got, ok := decoded.GetOnvifReplay(0); !ok || !got.End || got.CleanPoint {
+		t.Errorf("GetOnvifReplay() = %+v %v after replacing", got, ok)
+	}
+	if _, ok := decoded.GetTransportSequence(2); !ok || len(decoded.Extensions) != len(want) {
+		t.Errorf("Extensions = %x after replacing", decoded.Extensions)
+	}
+}
@@ -4,6 +4,8 @@ import (
     "errors"
 )
 
+// G711 and G722(RFC3551),one frame in one rtp packet,
+// the clock rate of G722 is 8000 though the sample rate is 16000
 type G711Packer struct {
     CommPacker
     pt       uint8
@@ -32,13 +34,13 @@ func (packer *G711Packer) Pack(data []byte, timestamp uint32) error {
     pkg.Header.Marker = 1
     pkg.Payload = make([]byte, len(data))
     copy(pkg.Payload, data)
+    packer.sequence++
     if packer.onRtp != nil {
         packer.onRtp(&pkg)
     }
     if packer.onPacket != nil {
         return packer.onPacket(pkg.Encode())
     }
-    packer.sequence++
     return nil
 }
 
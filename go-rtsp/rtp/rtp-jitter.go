@@ -0,0 +1,226 @@
+package rtp
+
+import (
+    "time"
+)
+
+const (
+    DEFAULT_JITTER_DELAY   = 100 * time.Millisecond
+    DEFAULT_JITTER_PACKETS = 256
+    //rfc3550 A.1,a larger jump of sequence number means the source is restarted
+    jitterMaxDropout  = 3000
+    jitterMaxMisorder = 100
+)
+
+type ON_LOST_FUNC func(sequence uint16, count int)
+
+type JitterStats struct {
+    Received   uint64 //packets arrived,including duplicated and late packets
+    Duplicated uint64
+    Reordered  uint64 //packets arrived earlier than the previous sequence number
+    Late       uint64 //packets arrived after they were reported lost
+    Lost       uint64
+}
+
+type jitterPacket struct {
+    data    []byte
+    arrival time.Time
+}
+
+// JitterBuffer reorders rtp packets by sequence number in front of UnPacker,
+// a missing packet is waited for until the oldest buffered packet is held for maxDelay
+// or maxPackets are buffered,then it is reported lost.
+// the buffer is checked when a packet arrives,call Poll periodically to release the packets
+// of a stream that stops,and Flush at the end of stream
+type JitterBuffer struct {
+    unpacker   UnPacker
+    onRtp      RTP_HOOK_FUNC
+    onLost     ON_LOST_FUNC
+    maxDelay   time.Duration
+    maxPackets int
+    packets    map[uint64]*jitterPacket //extended sequence number
+    lost       map[uint64]struct{}
+    next       uint64 //extended sequence number of the next packet to unpack
+    ssrc       uint32
+    started    bool
+    stats      JitterStats
+    now        func() time.Time
+}
+
+func NewJitterBuffer(unpacker UnPacker) *JitterBuffer {
+    return &JitterBuffer{
+        unpacker:   unpacker,
+        maxDelay:   DEFAULT_JITTER_DELAY,
+        maxPackets: DEFAULT_JITTER_PACKETS,
+        packets:    make(map[uint64]*jitterPacket),
+        lost:       make(map[uint64]struct{}),
+        now:        time.Now,
+    }
+}
+
+func (jb *JitterBuffer) SetMaxDelay(delay time.Duration) {
+    jb.maxDelay = delay
+}
+
+func (jb *JitterBuffer) SetMaxPackets(count int) {
+    if count < 1 {
+        count = 1
+    }
+    jb.maxPackets = count
+}
+
+func (jb *JitterBuffer) OnFrame(onframe ON_FRAME_FUNC) {
+    jb.unpacker.OnFrame(onframe)
+}
+
+// the hook is called when the packet arrives,before reordering
+func (jb *JitterBuffer) HookRtp(cb RTP_HOOK_FUNC) {
+    jb.onRtp = cb
+}
+
+// called when the missing packets are given up
+func (jb *JitterBuffer) OnLost(onlost ON_LOST_FUNC) {
+    jb.onLost = onlost
+}
+
+func (jb *JitterBuffer) Stats() JitterStats {
+    return jb.stats
+}
+
+func (jb *JitterBuffer) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+    jb.stats.Received++
+    if jb.onRtp != nil {
+        jb.onRtp(pkg)
+    }
+
+    if jb.started && pkg.Header.SSRC != jb.ssrc {
+        //new source,the sequence number restarts
+        if err := jb.Flush(); err != nil {
+            return err
+        }
+        jb.started = false
+    }
+    if !jb.started {
+        jb.started = true
+        jb.ssrc = pkg.Header.SSRC
+        //leave room for the packets reordered before the first one
+        jb.next = 0x10000 | uint64(pkg.Header.SequenceNumber)
+    }
+
+    seq := unwrapSequence(jb.next, pkg.Header.SequenceNumber)
+    switch {
+    case seq < jb.next && jb.next-seq <= jitterMaxMisorder:
+        if _, found := jb.lost[seq]; found {
+            delete(jb.lost, seq)
+            jb.stats.Late++
+        } else {
+            jb.stats.Duplicated++
+        }
+        return nil
+    case seq < jb.next && jb.next-seq < jitterMaxDropout:
+        //too late to be reordered
+        jb.stats.Late++
+        return nil
+    case seq >= jb.next+jitterMaxDropout || seq < jb.next:
+        //the source is restarted
+        if err := jb.Flush(); err != nil {
+            return err
+        }
+        jb.next = seq
+    }
+    if _, found := jb.packets[seq]; found {
+        jb.stats.Duplicated++
+        return nil
+    }
+    for buffered := range jb.packets {
+        if buffered > seq {
+            jb.stats.Reordered++
+            break
+        }
+    }
+    now := jb.now()
+    jb.packets[seq] = &jitterPacket{data: append([]byte{}, pkt...), arrival: now}
+    return jb.release(false, now)
+}
+
+// unpack the buffered packets in order,the missing packets are reported lost
+func (jb *JitterBuffer) Flush() error {
+    return jb.release(true, time.Time{})
+}
+
+// unpack the packets whose missing packets have been waited for maxDelay at now,
+// it releases the packets without new input,e.g. the tail of a stream that stops
+func (jb *JitterBuffer) Poll(now time.Time) error {
+    return jb.release(false, now)
+}
+
+func (jb *JitterBuffer) release(all bool, now time.Time) error {
+    for len(jb.packets) > 0 {
+        pkt, found := jb.packets[jb.next]
+        if !found {
+            if !all && !jb.expired(now) {
+                return nil
+            }
+            jb.skip()
+            continue
+        }
+        delete(jb.packets, jb.next)
+        jb.next++
+        if err := jb.unpacker.UnPack(pkt.data); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (jb *JitterBuffer) expired(now time.Time) bool {
+    if len(jb.packets) >= jb.maxPackets {
+        return true
+    }
+    deadline := now.Add(-jb.maxDelay)
+    for _, pkt := range jb.packets {
+        if !pkt.arrival.After(deadline) {
+            return true
+        }
+    }
+    return false
+}
+
+// give up the packets before the first buffered packet
+func (jb *JitterBuffer) skip() {
+    first := jb.next
+    for seq := range jb.packets {
+        if first == jb.next || seq < first {
+            first = seq
+        }
+    }
+    count := first - jb.next
+    for seq := jb.next; seq < first; seq++ {
+        jb.lost[seq] = struct{}{}
+    }
+    for seq := range jb.lost {
+        if seq+jitterMaxMisorder < first {
+            delete(jb.lost, seq)
+        }
+    }
+    jb.stats.Lost += count
+    if jb.onLost != nil {
+        jb.onLost(uint16(jb.next), int(count))
+    }
+    jb.next = first
+}
+
+// extend 16 bits sequence number to the closest value of ref
+func unwrapSequence(ref uint64, seq uint16) uint64 {
+    ext := ref&^0xFFFF | uint64(seq)
+    if ext+0x8000 < ref {
+        ext += 0x10000
+    } else if ext > ref+0x8000 && ext >= 0x10000 {
+        ext -= 0x10000
+    }
+    return ext
+}
@@ -0,0 +1,163 @@
+package rtp
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// records the sequence numbers in the order of unpacking
+type seqRecorder struct {
+	CommUnPacker
+	seqs []uint16
+}
+
+func (r *seqRecorder) UnPack(pkt []byte) error {
+	var pkg RtpPacket
+	if err := pkg.Decode(pkt); err != nil {
+		return err
+	}
+	r.seqs = append(r.seqs, pkg.Header.SequenceNumber)
+	return nil
+}
+
+func makeJitterTestPacket(ssrc uint32, seq uint16) []byte {
+	pkg := RtpPacket{Payload: []byte{0x01, 0x02}}
+	pkg.Header.PayloadType = 96
+	pkg.Header.SSRC = ssrc
+	pkg.Header.SequenceNumber = seq
+	return pkg.Encode()
+}
+
+type jitterTestClock struct {
+	now time.Time
+}
+
+func (c *jitterTestClock) Now() time.Time {
+	return c.now
+}
+
+func newTestJitterBuffer() (*JitterBuffer, *seqRecorder, *jitterTestClock) {
+	recorder := &seqRecorder{}
+	clock := &jitterTestClock{now: time.Unix(1700000000, 0)}
+	jb := NewJitterBuffer(recorder)
+	jb.now = clock.Now
+	return jb, recorder, clock
+}
+
+func inputJitterTestPackets(t *testing.T, jb *JitterBuffer, ssrc uint32, seqs ...uint16) {
+	for _, seq := range seqs {
+		if err := jb.UnPack(makeJitterTestPacket(ssrc, seq)); err != nil {
+			t.Fatalf("JitterBuffer.UnPack(%d) error = %v", seq, err)
+		}
+	}
+}
+
+func TestJitterBuffer_Reorder(t *testing.T) {
+	jb, recorder, _ := newTestJitterBuffer()
+	inputJitterTestPackets(t, jb, 1, 65533, 65535, 65534, 1, 0, 0, 2, 65535)
+	want := []uint16{65533, 65534, 65535, 0, 1, 2}
+	if !reflect.DeepEqual(recorder.seqs, want) {
+		t.Fatalf("unpacked %v, want %v", recorder.seqs, want)
+	}
+	stats := jb.Stats()
+	if stats.Received != 8 || stats.Duplicated != 2 || stats.Reordered != 2 || stats.Lost != 0 {
+		t.Errorf("JitterBuffer.Stats() = %+v", stats)
+	}
+}
+
+func TestJitterBuffer_MaxPackets(t *testing.T) {
+	jb, recorder, _ := newTestJitterBuffer()
+	jb.SetMaxPackets(3)
+	var lost [][2]int
+	jb.OnLost(func(sequence uint16, count int) {
+		lost = append(lost, [2]int{int(sequence), count})
+	})
+	inputJitterTestPackets(t, jb, 1, 10, 13, 14)
+	if !reflect.DeepEqual(recorder.seqs, []uint16{10}) {
+		t.Fatalf("unpacked %v before the buffer is full", recorder.seqs)
+	}
+	inputJitterTestPackets(t, jb, 1, 15)
+	if !reflect.DeepEqual(recorder.seqs, []uint16{10, 13, 14, 15}) || !reflect.DeepEqual(lost, [][2]int{{11, 2}}) {
+		t.Fatalf("unpacked %v,lost %v", recorder.seqs, lost)
+	}
+	//the packet reported lost arrives
+	inputJitterTestPackets(t, jb, 1, 12, 16)
+	if stats := jb.Stats(); stats.Late != 1 || stats.Lost != 2 {
+		t.Errorf("JitterBuffer.Stats() = %+v", stats)
+	}
+	if !reflect.DeepEqual(recorder.seqs, []uint16{10, 13, 14, 15, 16}) {
+		t.Errorf("unpacked %v", recorder.seqs)
+	}
+}
+
+func TestJitterBuffer_Poll(t *testing.T) {
+	jb, recorder, clock := newTestJitterBuffer()
+	jb.SetMaxDelay(100 * time.Millisecond)
+	start := clock.now
+	inputJitterTestPackets(t, jb, 1, 100, 102, 103)
+	//no more input,the tail is released by time
+	if err := jb.Poll(start.Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorder.seqs, []uint16{100}) {
+		t.Fatalf("unpacked %v before the delay", recorder.seqs)
+	}
+	if err := jb.Poll(start.Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorder.seqs, []uint16{100, 102, 103}) || jb.Stats().Lost != 1 {
+		t.Fatalf("unpacked %v,stats %+v after the delay", recorder.seqs, jb.Stats())
+	}
+
+	//the delay is counted from the arrival of the oldest waiting packet
+	clock.now = start.Add(time.Second)
+	inputJitterTestPackets(t, jb, 1, 105)
+	clock.now = start.Add(time.Second + 80*time.Millisecond)
+	inputJitterTestPackets(t, jb, 1, 106)
+	jb.Poll(clock.now.Add(10 * time.Millisecond))
+	if len(recorder.seqs) != 3 {
+		t.Fatalf("unpacked %v before the delay", recorder.seqs)
+	}
+	jb.Poll(start.Add(time.Second + 100*time.Millisecond))
+	if !reflect.DeepEqual(recorder.seqs, []uint16{100, 102, 103, 105, 106}) {
+		t.Fatalf("unpacked %v after the delay", recorder.seqs)
+	}
+}
+
+func TestJitterBuffer_Late(t *testing.T) {
+	jb, recorder, _ := newTestJitterBuffer()
+	inputJitterTestPackets(t, jb, 1, 1000, 1001, 1002)
+	//a stray packet far behind is dropped instead of resetting the buffer
+	inputJitterTestPackets(t, jb, 1, 500, 1004, 1003)
+	want := []uint16{1000, 1001, 1002, 1003, 1004}
+	if !reflect.DeepEqual(recorder.seqs, want) {
+		t.Fatalf("unpacked %v, want %v", recorder.seqs, want)
+	}
+	if stats := jb.Stats(); stats.Late != 1 || stats.Lost != 0 {
+		t.Errorf("JitterBuffer.Stats() = %+v", stats)
+	}
+
+	//beyond the dropout the source is restarted
+	inputJitterTestPackets(t, jb, 1, 1005, 1007, 60000, 60001)
+	want = append(want, 1005, 1007, 60000, 60001)
+	if !reflect.DeepEqual(recorder.seqs, want) {
+		t.Fatalf("unpacked %v, want %v", recorder.seqs, want)
+	}
+}
+
+func TestJitterBuffer_Flush(t *testing.T) {
+	jb, recorder, _ := newTestJitterBuffer()
+	inputJitterTestPackets(t, jb, 1, 1, 3, 5)
+	//new ssrc flushes the packets of the previous source
+	inputJitterTestPackets(t, jb, 2, 100, 102)
+	if !reflect.DeepEqual(recorder.seqs, []uint16{1, 3, 5, 100}) {
+		t.Fatalf("unpacked %v after ssrc changes", recorder.seqs)
+	}
+	if err := jb.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(recorder.seqs, []uint16{1, 3, 5, 100, 102}) || jb.Stats().Lost != 3 {
+		t.Fatalf("unpacked %v,stats %+v after flush", recorder.seqs, jb.Stats())
+	}
+}
@@ -1 +1,525 @@
 package rtp
+
+import (
+    "encoding/binary"
+    "errors"
+)
+
+// RFC2435
+// the clock rate is 90000,static payload type 26,
+// the headers of JPEG(DQT,SOF,DHT,SOS) are not sent,they are rebuilt from the main jpeg header
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// | Type-specific |              Fragment Offset                  |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      Type     |       Q       |     Width     |     Height    |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Restart Marker header,64 <= Type <= 127
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |       Restart Interval        |F|L|       Restart Count       |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Quantization Table header,Q >= 128 and Fragment Offset is 0
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |      MBZ      |   Precision   |             Length            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                    Quantization Table Data                    |
+// |                              ...                              |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// Type 0 is YUV 4:2:2,Type 1 is YUV 4:2:0,Type 64/65 are the same with restart markers,
+// Q 1-99 selects the scaled tables of Annex K,the tables of Q 128-255 are sent in band
+
+const (
+    JPEG_HEAD_LEN    = 8
+    JPEG_RESTART_LEN = 4
+    JPEG_QTABLE_LEN  = 4
+)
+
+const (
+    JPEG_TYPE_YUV422 = 0
+    JPEG_TYPE_YUV420 = 1
+    JPEG_TYPE_DRI    = 64 //the types with restart markers
+)
+
+// the tables are always sent in band
+const JPEG_Q_INBAND = 255
+
+type JpegPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+}
+
+func NewJpegPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *JpegPacker {
+    return &JpegPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+// data is a baseline JFIF image(SOI...EOI) with the huffman tables of Annex K,
+// YUV 4:2:2 or 4:2:0,the width and height are less than 2048
+func (packer *JpegPacker) Pack(data []byte, timestamp uint32) error {
+    frame, err := parseJpeg(data)
+    if err != nil {
+        return err
+    }
+    var qtable []byte
+    var precision uint8 = 0
+    for i, table := range frame.qtables {
+        if len(table) == 128 {
+            precision |= 1 << i
+        }
+        qtable = append(qtable, table...)
+    }
+
+    offset := 0
+    scan := frame.scan
+    for offset == 0 || len(scan) > 0 {
+        pkg := RtpPacket{}
+        pkg.Header.PayloadType = packer.pt
+        pkg.Header.SequenceNumber = packer.sequence
+        pkg.Header.SSRC = packer.ssrc
+        pkg.Header.Timestamp = timestamp
+
+        payload := make([]byte, JPEG_HEAD_LEN, packer.mtu-RTP_FIX_HEAD_LEN)
+        binary.BigEndian.PutUint32(payload, uint32(offset))
+        payload[4] = frame.typ
+        payload[5] = JPEG_Q_INBAND
+        payload[6] = uint8((frame.width + 7) / 8)
+        payload[7] = uint8((frame.height + 7) / 8)
+        if frame.dri > 0 {
+            //the fragment is not aligned with the restart intervals
+            payload = append(payload, uint8(frame.dri>>8), uint8(frame.dri), 0xFF, 0xFF)
+        }
+        if offset == 0 {
+            payload = append(payload, 0, precision, uint8(len(qtable)>>8), uint8(len(qtable)))
+            payload = append(payload, qtable...)
+        }
+        if len(payload) >= packer.mtu-RTP_FIX_HEAD_LEN {
+            return errors.New("mtu is too small for jpeg header")
+        }
+        size := packer.mtu - RTP_FIX_HEAD_LEN - len(payload)
+        if size > len(scan) {
+            size = len(scan)
+        }
+        pkg.Payload = append(payload, scan[:size]...)
+        scan = scan[size:]
+        offset += size
+        if len(scan) == 0 {
+            pkg.Header.Marker = 1
+        }
+        packer.sequence++
+        if packer.onRtp != nil {
+            packer.onRtp(&pkg)
+        }
+        if packer.onPacket != nil {
+            if err := packer.onPacket(pkg.Encode()); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+type jpegFrame struct {
+    typ     uint8
+    width   int
+    height  int
+    dri     int
+    qtables [][]byte //luma and chroma tables in zigzag order,64 bytes or 128 bytes(16-bit precision)
+    scan    []byte   //the entropy-coded data after SOS,EOI is excluded
+}
+
+func parseJpeg(data []byte) (*jpegFrame, error) {
+    if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+        return nil, errors.New("jpeg must start with SOI")
+    }
+    frame := &jpegFrame{}
+    tables := make(map[uint8][]byte)
+    var tableIds [2]uint8
+    sof := false
+    data = data[2:]
+    for len(data) >= 4 {
+        if data[0] != 0xFF {
+            return nil, errors.New("illegal jpeg marker")
+        }
+        marker := data[1]
+        if marker == 0xFF {
+            //fill bytes
+            data = data[1:]
+            continue
+        }
+        length := int(binary.BigEndian.Uint16(data[2:]))
+        if length < 2 || len(data) < 2+length {
+            return nil, errors.New("jpeg segment is out of range")
+        }
+        segment := data[4 : 2+length]
+        data = data[2+length:]
+        switch marker {
+        case 0xDB: //DQT
+            for len(segment) > 0 {
+                size := 64
+                if segment[0]>>4 == 1 {
+                    size = 128
+                }
+                if len(segment) < 1+size {
+                    return nil, errors.New("illegal jpeg DQT")
+                }
+                tables[segment[0]&0x0F] = segment[1 : 1+size]
+                segment = segment[1+size:]
+            }
+        case 0xDD: //DRI
+            if len(segment) < 2 {
+                return nil, errors.New("illegal jpeg DRI")
+            }
+            frame.dri = int(binary.BigEndian.Uint16(segment))
+        case 0xC0: //SOF0
+            if len(segment) < 15 || segment[5] != 3 {
+                return nil, errors.New("only support jpeg with 3 components")
+            }
+            frame.height = int(binary.BigEndian.Uint16(segment[1:]))
+            frame.width = int(binary.BigEndian.Uint16(segment[3:]))
+            switch segment[7] {
+            case 0x21:
+                frame.typ = JPEG_TYPE_YUV422
+            case 0x22:
+                frame.typ = JPEG_TYPE_YUV420
+            default:
+                return nil, errors.New("unsupport jpeg sampling factor")
+            }
+            if segment[10] != 0x11 || segment[13] != 0x11 || segment[11] != segment[14] {
+                return nil, errors.New("unsupport jpeg chroma components")
+            }
+            tableIds[0], tableIds[1] = segment[8], segment[11]
+            sof = true
+        case 0xC1, 0xC2, 0xC3, 0xC5, 0xC6, 0xC7, 0xC9, 0xCA, 0xCB, 0xCD, 0xCE, 0xCF:
+            return nil, errors.New("only support baseline jpeg")
+        case 0xDA: //SOS
+            if !sof {
+                return nil, errors.New("jpeg has no SOF0")
+            }
+            if frame.width > 2040 || frame.height > 2040 || frame.width == 0 || frame.height == 0 {
+                return nil, errors.New("the width and height of jpeg must be less than 2048")
+            }
+            for _, id := range tableIds {
+                table, found := tables[id]
+                if !found {
+                    return nil, errors.New("jpeg has no quantization table")
+                }
+                frame.qtables = append(frame.qtables, table)
+            }
+            if frame.dri > 0 {
+                frame.typ += JPEG_TYPE_DRI
+            }
+            frame.scan = data
+            if len(data) >= 2 && data[len(data)-2] == 0xFF && data[len(data)-1] == 0xD9 {
+                frame.scan = data[:len(data)-2]
+            }
+            return frame, nil
+        }
+    }
+    return nil, errors.New("jpeg has no SOS")
+}
+
+// the JFIF image is rebuilt when the packet with marker is received
+type JpegUnPacker struct {
+    CommUnPacker
+    timestamp   uint32
+    started     bool
+    typ         uint8
+    q           uint8
+    width       int
+    height      int
+    dri         int
+    qtable      []byte
+    precision   uint8
+    scan        []byte
+    cachedTable map[uint8][]byte //the tables of Q 128-254 may be sent only once
+}
+
+func NewJpegUnPacker() *JpegUnPacker {
+    return &JpegUnPacker{
+        cachedTable: make(map[uint8][]byte),
+    }
+}
+
+func (unpacker *JpegUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    payload := pkg.Payload
+    if len(payload) < JPEG_HEAD_LEN {
+        return errors.New("jpeg rtp packet less than 8 bytes")
+    }
+    offset := int(binary.BigEndian.Uint32(payload) & 0x00FFFFFF)
+    typ := payload[4]
+    q := payload[5]
+    width := int(payload[6]) * 8
+    height := int(payload[7]) * 8
+    payload = payload[JPEG_HEAD_LEN:]
+    if typ&0x3F > JPEG_TYPE_YUV420 || typ >= 128 {
+        return errors.New("unsupport jpeg type")
+    }
+    dri := 0
+    if typ >= JPEG_TYPE_DRI {
+        if len(payload) < JPEG_RESTART_LEN {
+            return errors.New("jpeg restart marker header less than 4 bytes")
+        }
+        dri = int(binary.BigEndian.Uint16(payload))
+        payload = payload[JPEG_RESTART_LEN:]
+    }
+
+    if offset == 0 {
+        unpacker.started = true
+        unpacker.timestamp = pkg.Header.Timestamp
+        unpacker.typ, unpacker.q, unpacker.width, unpacker.height, unpacker.dri = typ, q, width, height, dri
+        unpacker.scan = unpacker.scan[:0]
+        unpacker.precision = 0
+        if q >= 128 {
+            if len(payload) < JPEG_QTABLE_LEN {
+                return errors.New("jpeg quantization table header less than 4 bytes")
+            }
+            unpacker.precision = payload[1]
+            length := int(binary.BigEndian.Uint16(payload[2:]))
+            payload = payload[JPEG_QTABLE_LEN:]
+            if len(payload) < length {
+                return errors.New("jpeg quantization table is out of range")
+            }
+            if length > 0 {
+                unpacker.qtable = append([]byte{}, payload[:length]...)
+                if q < 255 {
+                    unpacker.cachedTable[q] = unpacker.qtable
+                }
+            } else if table, found := unpacker.cachedTable[q]; found {
+                unpacker.qtable = table
+            } else {
+                unpacker.started = false
+                return errors.New("jpeg quantization table is not received")
+            }
+            payload = payload[length:]
+        } else {
+            unpacker.qtable = makeJpegTables(int(q))
+        }
+    } else if !unpacker.started || offset != len(unpacker.scan) || pkg.Header.Timestamp != unpacker.timestamp {
+        //the fragment is lost,wait for the next frame
+        unpacker.started = false
+        return nil
+    }
+    unpacker.scan = append(unpacker.scan, payload...)
+    if pkg.Header.Marker == 0 {
+        return nil
+    }
+    unpacker.started = false
+    if unpacker.onFrame != nil {
+        unpacker.onFrame(unpacker.makeJfif(), unpacker.timestamp, false)
+    }
+    return nil
+}
+
+// RFC2435 Appendix B
+func (unpacker *JpegUnPacker) makeJfif() []byte {
+    jfif := make([]byte, 0, 1024+len(unpacker.scan))
+    jfif = append(jfif, 0xFF, 0xD8)
+
+    //DQT,the first table is luma,the second is chroma
+    for id, table := range splitQuantizationTables(unpacker.qtable, unpacker.precision) {
+        jfif = append(jfif, 0xFF, 0xDB, 0, uint8(3+len(table)), uint8(len(table)/128<<4|id))
+        jfif = append(jfif, table...)
+    }
+
+    if unpacker.dri > 0 {
+        jfif = append(jfif, 0xFF, 0xDD, 0, 4, uint8(unpacker.dri>>8), uint8(unpacker.dri))
+    }
+
+    //SOF0
+    var sampling uint8 = 0x21
+    if unpacker.typ&0x3F == JPEG_TYPE_YUV420 {
+        sampling = 0x22
+    }
+    jfif = append(jfif, 0xFF, 0xC0, 0, 17, 8,
+        uint8(unpacker.height>>8), uint8(unpacker.height), uint8(unpacker.width>>8), uint8(unpacker.width), 3,
+        0, sampling, 0,
+        1, 0x11, 1,
+        2, 0x11, 1)
+
+    //DHT
+    jfif = appendHuffmanTable(jfif, 0x00, lumDcCodelens[:], lumDcSymbols[:])
+    jfif = appendHuffmanTable(jfif, 0x10, lumAcCodelens[:], lumAcSymbols[:])
+    jfif = appendHuffmanTable(jfif, 0x01, chmDcCodelens[:], chmDcSymbols[:])
+    jfif = appendHuffmanTable(jfif, 0x11, chmAcCodelens[:], chmAcSymbols[:])
+
+    //SOS
+    jfif = append(jfif, 0xFF, 0xDA, 0, 12, 3, 0, 0x00, 1, 0x11, 2, 0x11, 0, 63, 0)
+    jfif = append(jfif, unpacker.scan...)
+    if len(unpacker.scan) < 2 || unpacker.scan[len(unpacker.scan)-2] != 0xFF || unpacker.scan[len(unpacker.scan)-1] != 0xD9 {
+        jfif = append(jfif, 0xFF, 0xD9)
+    }
+    return jfif
+}
+
+// the chroma shares the luma table if only one table is sent
+func splitQuantizationTables(qtable []byte, precision uint8) [][]byte {
+    var tables [][]byte
+    for id := 0; id < 2; id++ {
+        size := 64
+        if precision&(1<<id) > 0 {
+            size = 128
+        }
+        if len(qtable) < size {
+            break
+        }
+        tables = append(tables, qtable[:size])
+        qtable = qtable[size:]
+    }
+    if len(tables) == 1 {
+        tables = append(tables, tables[0])
+    }
+    return tables
+}
+
+func appendHuffmanTable(jfif []byte, class uint8, codelens []byte, symbols []byte) []byte {
+    length := 3 + len(codelens) + len(symbols)
+    jfif = append(jfif, 0xFF, 0xC4, uint8(length>>8), uint8(length), class)
+    jfif = append(jfif, codelens...)
+    return append(jfif, symbols...)
+}
+
+// RFC2435 Appendix A,the tables of Annex K scaled by Q,in zigzag order
+func makeJpegTables(q int) []byte {
+    factor := q
+    if q < 1 {
+        factor = 1
+    } else if q > 99 {
+        factor = 99
+    }
+    if q < 50 {
+        q = 5000 / factor
+    } else {
+        q = 200 - factor*2
+    }
+    tables := make([]byte, 128)
+    for i := 0; i < 64; i++ {
+        lq := (jpegLumaQuantizer[jpegZigzag[i]]*q + 50) / 100
+        cq := (jpegChromaQuantizer[jpegZigzag[i]]*q + 50) / 100
+        tables[i] = uint8(clampQuantizer(lq))
+        tables[64+i] = uint8(clampQuantizer(cq))
+    }
+    return tables
+}
+
+func clampQuantizer(q int) int {
+    if q < 1 {
+        return 1
+    } else if q > 255 {
+        return 255
+    }
+    return q
+}
+
+// the natural order index of the zigzag coefficient
+var jpegZigzag = [64]int{
+    0, 1, 8, 16, 9, 2, 3, 10,
+    17, 24, 32, 25, 18, 11, 4, 5,
+    12, 19, 26, 33, 40, 48, 41, 34,
+    27, 20, 13, 6, 7, 14, 21, 28,
+    35, 42, 49, 56, 57, 50, 43, 36,
+    29, 22, 15, 23, 30, 37, 44, 51,
+    58, 59, 52, 45, 38, 31, 39, 46,
+    53, 60, 61, 54, 47, 55, 62, 63,
+}
+
+var jpegLumaQuantizer = [64]int{
+    16, 11, 10, 16, 24, 40, 51, 61,
+    12, 12, 14, 19, 26, 58, 60, 55,
+    14, 13, 16, 24, 40, 57, 69, 56,
+    14, 17, 22, 29, 51, 87, 80, 62,
+    18, 22, 37, 56, 68, 109, 103, 77,
+    24, 35, 55, 64, 81, 104, 113, 92,
+    49, 64, 78, 87, 103, 121, 120, 101,
+    72, 92, 95, 98, 112, 100, 103, 99,
+}
+
+var jpegChromaQuantizer = [64]int{
+    17, 18, 24, 47, 99, 99, 99, 99,
+    18, 21, 26, 66, 99, 99, 99, 99,
+    24, 26, 56, 99, 99, 99, 99, 99,
+    47, 66, 99, 99, 99, 99, 99, 99,
+    99, 99, 99, 99, 99, 99, 99, 99,
+    99, 99, 99, 99, 99, 99, 99, 99,
+    99, 99, 99, 99, 99, 99, 99, 99,
+    99, 99, 99, 99, 99, 99, 99, 99,
+}
+
+// the huffman tables of Annex K.3
+var lumDcCodelens = [16]byte{0, 1, 5, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0, 0, 0}
+
+var lumDcSymbols = [12]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+
+var lumAcCodelens = [16]byte{0, 2, 1, 3, 3, 2, 4, 3, 5, 5, 4, 4, 0, 0, 1, 0x7d}
+
+var lumAcSymbols = [162]byte{
+    0x01, 0x02, 0x03, 0x00, 0x04, 0x11, 0x05, 0x12,
+    0x21, 0x31, 0x41, 0x06, 0x13, 0x51, 0x61, 0x07,
+    0x22, 0x71, 0x14, 0x32, 0x81, 0x91, 0xa1, 0x08,
+    0x23, 0x42, 0xb1, 0xc1, 0x15, 0x52, 0xd1, 0xf0,
+    0x24, 0x33, 0x62, 0x72, 0x82, 0x09, 0x0a, 0x16,
+    0x17, 0x18, 0x19, 0x1a, 0x25, 0x26, 0x27, 0x28,
+    0x29, 0x2a, 0x34, 0x35, 0x36, 0x37, 0x38, 0x39,
+    0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49,
+    0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59,
+    0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x69,
+    0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78, 0x79,
+    0x7a, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89,
+    0x8a, 0x92, 0x93, 0x94, 0x95, 0x96, 0x97, 0x98,
+    0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7,
+    0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4, 0xb5, 0xb6,
+    0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3, 0xc4, 0xc5,
+    0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2, 0xd3, 0xd4,
+    0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda, 0xe1, 0xe2,
+    0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9, 0xea,
+    0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+    0xf9, 0xfa,
+}
+
+var chmDcCodelens = [16]byte{0, 3, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0, 0}
+
+var chmDcSymbols = [12]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+
+var chmAcCodelens = [16]byte{0, 2, 1, 2, 4, 4, 3, 4, 7, 5, 4, 4, 0, 1, 2, 0x77}
+
+var chmAcSymbols = [162]byte{
+    0x00, 0x01, 0x02, 0x03, 0x11, 0x04, 0x05, 0x21,
+    0x31, 0x06, 0x12, 0x41, 0x51, 0x07, 0x61, 0x71,
+    0x13, 0x22, 0x32, 0x81, 0x08, 0x14, 0x42, 0x91,
+    0xa1, 0xb1, 0xc1, 0x09, 0x23, 0x33, 0x52, 0xf0,
+    0x15, 0x62, 0x72, 0xd1, 0x0a, 0x16, 0x24, 0x34,
+    0xe1, 0x25, 0xf1, 0x17, 0x18, 0x19, 0x1a, 0x26,
+    0x27, 0x28, 0x29, 0x2a, 0x35, 0x36, 0x37, 0x38,
+    0x39, 0x3a, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48,
+    0x49, 0x4a, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58,
+    0x59, 0x5a, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68,
+    0x69, 0x6a, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78,
+    0x79, 0x7a, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87,
+    0x88, 0x89, 0x8a, 0x92, 0x93, 0x94, 0x95, 0x96,
+    0x97, 0x98, 0x99, 0x9a, 0xa2, 0xa3, 0xa4, 0xa5,
+    0xa6, 0xa7, 0xa8, 0xa9, 0xaa, 0xb2, 0xb3, 0xb4,
+    0xb5, 0xb6, 0xb7, 0xb8, 0xb9, 0xba, 0xc2, 0xc3,
+    0xc4, 0xc5, 0xc6, 0xc7, 0xc8, 0xc9, 0xca, 0xd2,
+    0xd3, 0xd4, 0xd5, 0xd6, 0xd7, 0xd8, 0xd9, 0xda,
+    0xe2, 0xe3, 0xe4, 0xe5, 0xe6, 0xe7, 0xe8, 0xe9,
+    0xea, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8,
+    0xf9, 0xfa,
+}
@@ -0,0 +1,178 @@
+package rtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// image/jpeg encodes baseline 4:2:0 with the tables of Annex K scaled like RFC2435 Appendix A
+func makeJpegTestImage(t *testing.T, quality int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 48, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 48; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 5), uint8(y * 7), uint8(x * y), 0xFF})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// insert DRI before SOS
+func insertJpegDri(data []byte, dri int) []byte {
+	sos := bytes.Index(data, []byte{0xFF, 0xDA})
+	out := append([]byte{}, data[:sos]...)
+	out = append(out, 0xFF, 0xDD, 0, 4, uint8(dri>>8), uint8(dri))
+	return append(out, data[sos:]...)
+}
+
+func unpackJpegTest(t *testing.T, pkts ...[]byte) [][]byte {
+	unpacker := NewJpegUnPacker()
+	var frames [][]byte
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		frames = append(frames, append([]byte{}, frame...))
+	})
+	for _, pkt := range pkts {
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("JpegUnPacker.UnPack() error = %v", err)
+		}
+	}
+	return frames
+}
+
+func makeJpegTestPacket(payload []byte, marker bool) []byte {
+	pkg := RtpPacket{Payload: payload}
+	pkg.Header.PayloadType = 26
+	if marker {
+		pkg.Header.Marker = 1
+	}
+	return pkg.Encode()
+}
+
+func decodeJpegTest(t *testing.T, data []byte) *image.YCbCr {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("jpeg.Decode() error = %v", err)
+	}
+	return img.(*image.YCbCr)
+}
+
+func TestMakeJpegTables(t *testing.T) {
+	//Q 50 is the tables of Annex K
+	tables := makeJpegTables(50)
+	if tables[0] != 16 || tables[1] != 11 || tables[2] != 12 || tables[63] != 99 || tables[64] != 17 || tables[127] != 99 {
+		t.Errorf("makeJpegTables(50) = %v", tables)
+	}
+	if tables := makeJpegTables(10); tables[0] != 80 || tables[64] != 85 {
+		t.Errorf("makeJpegTables(10) = %v", tables)
+	}
+	//the quantizer is limited in 1-255
+	if tables := makeJpegTables(99); tables[0] != 1 || tables[64] != 1 {
+		t.Errorf("makeJpegTables(99) = %v", tables)
+	}
+	if tables := makeJpegTables(0); tables[0] != 255 || tables[64] != 255 {
+		t.Errorf("makeJpegTables(0) = %v", tables)
+	}
+}
+
+func TestJpegUnPacker_Q(t *testing.T) {
+	for _, q := range []int{30, 50, 90} {
+		data := makeJpegTestImage(t, q)
+		frame, err := parseJpeg(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(frame.qtables[0], makeJpegTables(q)[:64]) || !bytes.Equal(frame.qtables[1], makeJpegTables(q)[64:]) {
+			t.Fatalf("the tables of quality %d are not the tables of Q %d", q, q)
+		}
+		//Q 1-99,no quantization table header
+		head := []byte{0, 0, 0, 0, JPEG_TYPE_YUV420, uint8(q), 48 / 8, 32 / 8}
+		half := len(frame.scan) / 2
+		pkt1 := makeJpegTestPacket(append(append([]byte{}, head...), frame.scan[:half]...), false)
+		binary.BigEndian.PutUint32(head, uint32(half))
+		pkt2 := makeJpegTestPacket(append(head, frame.scan[half:]...), true)
+		frames := unpackJpegTest(t, pkt1, pkt2)
+		if len(frames) != 1 {
+			t.Fatalf("Q %d,unpacked %d frames", q, len(frames))
+		}
+		want, got := decodeJpegTest(t, data), decodeJpegTest(t, frames[0])
+		if want.Rect != got.Rect || want.SubsampleRatio != got.SubsampleRatio || !bytes.Equal(want.Y, got.Y) || !bytes.Equal(want.Cb, got.Cb) || !bytes.Equal(want.Cr, got.Cr) {
+			t.Errorf("Q %d,the rebuilt jpeg is different from the original", q)
+		}
+	}
+}
+
+func TestJpegPacker_Restart(t *testing.T) {
+	data := insertJpegDri(makeJpegTestImage(t, 75), 6)
+	frame, err := parseJpeg(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packer := NewJpegPacker(26, 0x1234, 0, 300)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	if err := packer.Pack(data, 3000); err != nil {
+		t.Fatalf("JpegPacker.Pack() error = %v", err)
+	}
+	if len(pkts) < 2 {
+		t.Fatalf("packed %d packets", len(pkts))
+	}
+	for i, pkt := range pkts {
+		var pkg RtpPacket
+		if err := pkg.Decode(pkt); err != nil {
+			t.Fatal(err)
+		}
+		//Type 65 is 4:2:0 with the restart marker header after the main header
+		if pkg.Payload[4] != JPEG_TYPE_DRI+JPEG_TYPE_YUV420 {
+			t.Errorf("packet %d type = %d", i, pkg.Payload[4])
+		}
+		if !bytes.Equal(pkg.Payload[JPEG_HEAD_LEN:JPEG_HEAD_LEN+JPEG_RESTART_LEN], []byte{0, 6, 0xFF, 0xFF}) {
+			t.Errorf("packet %d restart marker header = %x", i, pkg.Payload[JPEG_HEAD_LEN:JPEG_HEAD_LEN+JPEG_RESTART_LEN])
+		}
+	}
+	frames := unpackJpegTest(t, pkts...)
+	if len(frames) != 1 {
+		t.Fatalf("unpacked %d frames", len(frames))
+	}
+	if !bytes.Contains(frames[0], []byte{0xFF, 0xDD, 0, 4, 0, 6}) {
+		t.Error("the rebuilt jpeg has no DRI")
+	}
+	if !bytes.HasSuffix(frames[0], append(append([]byte{}, frame.scan...), 0xFF, 0xD9)) {
+		t.Error("the scan is not restored")
+	}
+}
+
+func TestJpegUnPacker_Restart(t *testing.T) {
+	data := makeJpegTestImage(t, 50)
+	frame, err := parseJpeg(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Type 64 is 4:2:2 with restart markers,Q 50 is not sent in band
+	payload := []byte{0, 0, 0, 0, JPEG_TYPE_DRI + JPEG_TYPE_YUV422, 50, 48 / 8, 32 / 8, 0, 2, 0xFF, 0xFF}
+	frames := unpackJpegTest(t, makeJpegTestPacket(append(payload, frame.scan...), true))
+	if len(frames) != 1 {
+		t.Fatalf("unpacked %d frames", len(frames))
+	}
+	if !bytes.Contains(frames[0], []byte{0xFF, 0xDD, 0, 4, 0, 2}) {
+		t.Error("the rebuilt jpeg has no DRI")
+	}
+	//SOF0 of 4:2:2
+	if !bytes.Contains(frames[0], []byte{0xFF, 0xC0, 0, 17, 8, 0, 32, 0, 48, 3, 0, 0x21}) {
+		t.Error("the rebuilt jpeg is not 4:2:2")
+	}
+
+	unpacker := NewJpegUnPacker()
+	if err := unpacker.UnPack(makeJpegTestPacket(payload[:JPEG_HEAD_LEN+2], true)); err == nil {
+		t.Error("the restart marker header less than 4 bytes is accepted")
+	}
+}
@@ -0,0 +1,160 @@
+package rtp
+
+import (
+    "bytes"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// RFC3016/RFC6416 MP4A-LATM
+// the rtp payload is one or more octet-aligned AudioMuxElement,
+// an AudioMuxElement may be fragmented into multiple rtp packets,the marker bit is set in the last one
+// a=rtpmap:96 MP4A-LATM/44100/2
+// a=fmtp:96 profile-level-id=30;cpresent=0;config=400024203fc0;object=2
+// with cpresent=0 the StreamMuxConfig is in the config of fmtp,
+// otherwise every AudioMuxElement carries useSameStreamMux and the StreamMuxConfig if it is changed
+
+type LATMPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+}
+
+func NewLATMPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *LATMPacker {
+    return &LATMPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+// data is raw aac frame,it is sent as AudioMuxElement(0)
+func (packer *LATMPacker) Pack(data []byte, timestamp uint32) error {
+    element := codec.CreateAudioMuxElement(data)
+    maxPayload := packer.mtu - RTP_FIX_HEAD_LEN
+    for len(element) > 0 {
+        size := len(element)
+        if size > maxPayload {
+            size = maxPayload
+        }
+        pkg := RtpPacket{}
+        pkg.Header.PayloadType = packer.pt
+        pkg.Header.SequenceNumber = packer.sequence
+        pkg.Header.SSRC = packer.ssrc
+        pkg.Header.Timestamp = timestamp
+        if size == len(element) {
+            pkg.Header.Marker = 1
+        }
+        pkg.Payload = make([]byte, size)
+        copy(pkg.Payload, element[:size])
+        packer.sequence++
+        if packer.onRtp != nil {
+            packer.onRtp(&pkg)
+        }
+        if packer.onPacket != nil {
+            if err := packer.onPacket(pkg.Encode()); err != nil {
+                return err
+            }
+        }
+        element = element[size:]
+    }
+    return nil
+}
+
+// the raw aac frames are sent with adts header made from AudioSpecificConfig of StreamMuxConfig
+type LATMUnPacker struct {
+    CommUnPacker
+    muxConfigPresent bool
+    config           *codec.StreamMuxConfig
+    timestamp        uint32
+    lastSequence     uint16
+    started          bool
+    synced           bool //the next packet is the head of AudioMuxElement
+    lost             bool
+    frameBuffer      *bytes.Buffer
+}
+
+// cpresent and config are from the fmtp,the config is ignored if cpresent is true
+func NewLATMUnPacker(cpresent bool, config []byte) *LATMUnPacker {
+    unpacker := &LATMUnPacker{
+        muxConfigPresent: cpresent,
+        config:           &codec.StreamMuxConfig{},
+        synced:           true,
+        frameBuffer:      new(bytes.Buffer),
+    }
+    if !cpresent && len(config) > 0 {
+        unpacker.config.Decode(config)
+    }
+    return unpacker
+}
+
+func (unpacker *LATMUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    if unpacker.started && unpacker.lastSequence+1 != pkg.Header.SequenceNumber {
+        //there is no fragment header,the packets are dropped until the next AudioMuxElement
+        unpacker.frameBuffer.Reset()
+        unpacker.lost = true
+        unpacker.synced = false
+    }
+    unpacker.started = true
+    unpacker.lastSequence = pkg.Header.SequenceNumber
+    if !unpacker.synced {
+        unpacker.synced = pkg.Header.Marker == 1
+        return nil
+    }
+    if unpacker.frameBuffer.Len() == 0 {
+        unpacker.timestamp = pkg.Header.Timestamp
+    }
+    unpacker.frameBuffer.Write(pkg.Payload)
+    if pkg.Header.Marker == 0 {
+        return nil
+    }
+
+    data := unpacker.frameBuffer.Bytes()
+    timestamp := unpacker.timestamp
+    defer unpacker.frameBuffer.Reset()
+    for len(data) > 0 {
+        //the rest of the packet is dropped after the malformed AudioMuxElement
+        var sendErr error
+        n, err := codec.SplitAudioMuxElement(data, unpacker.muxConfigPresent, unpacker.config, func(aac []byte) {
+            if sendErr == nil {
+                sendErr = unpacker.sendFrame(aac, timestamp)
+            }
+            //the clock rate is same as the sampling rate(rfc6416 7.3)
+            timestamp += uint32(unpacker.config.FrameSamples())
+        })
+        if err == nil {
+            err = sendErr
+        }
+        if err != nil {
+            unpacker.lost = true
+            return err
+        }
+        data = data[n:]
+    }
+    return nil
+}
+
+// the AudioSpecificConfig of StreamMuxConfig can not be converted to adts header
+func (unpacker *LATMUnPacker) sendFrame(aac []byte, timestamp uint32) error {
+    adtsHdr, err := codec.ConvertASCToADTS(unpacker.config.Asc, len(aac)+7)
+    if err != nil {
+        return err
+    }
+    if unpacker.onFrame != nil {
+        frame := append(adtsHdr.Encode(), aac...)
+        unpacker.onFrame(frame, timestamp, unpacker.lost)
+    }
+    unpacker.lost = false
+    return nil
+}
@@ -0,0 +1,61 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yapingcat/gomedia/go-codec"
+)
+
+func TestLATMUnPacker(t *testing.T) {
+	//AAC LC,44100Hz,stereo
+	config := codec.NewStreamMuxConfig([]byte{0x12, 0x10}).Encode()
+	packer := NewLATMPacker(96, 0x1234, 0, 1400)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	aac := []byte{0x21, 0x00, 0x49, 0x90, 0x02, 0x19}
+	if err := packer.Pack(aac, 1024); err != nil {
+		t.Fatalf("LATMPacker.Pack() error = %v", err)
+	}
+	unpacker := NewLATMUnPacker(false, config)
+	var frames [][]byte
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		frames = append(frames, append([]byte{}, frame...))
+	})
+	for _, pkt := range pkts {
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("LATMUnPacker.UnPack() error = %v", err)
+		}
+	}
+	if len(frames) != 1 || len(frames[0]) != 7+len(aac) || !bytes.Equal(frames[0][7:], aac) {
+		t.Fatalf("unpacked %x", frames)
+	}
+	if frames[0][0] != 0xFF || frames[0][1]&0xF0 != 0xF0 {
+		t.Errorf("adts header %x", frames[0][:7])
+	}
+}
+
+func TestLATMUnPacker_BadConfig(t *testing.T) {
+	//the AudioSpecificConfig of 8 bits can not be converted to adts header
+	smc := codec.NewStreamMuxConfig([]byte{0x12})
+	smc.AudioMuxVersion = 1
+	packer := NewLATMPacker(96, 0x1234, 0, 1400)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	if err := packer.Pack([]byte{0x21, 0x00, 0x49}, 0); err != nil {
+		t.Fatal(err)
+	}
+	unpacker := NewLATMUnPacker(false, smc.Encode())
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		t.Error("the frame is sent without adts header")
+	})
+	if err := unpacker.UnPack(pkts[0]); err == nil {
+		t.Error("the bad StreamMuxConfig is not reported")
+	}
+}
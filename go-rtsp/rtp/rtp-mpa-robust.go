@@ -0,0 +1,473 @@
+package rtp
+
+import (
+    "errors"
+    "sort"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// RFC5219 mpa-robust,a loss-tolerant payload format for mp3(layer III),
+// the frames are rearranged into ADUs(Application Data Unit),
+// every ADU has its own header,side info and main data,so it can be decoded without the bit reservoir of the previous frames
+//
+// every ADU in the packet starts with a descriptor
+//  0 1 2 3 4 5 6 7           0                   1
+// +-+-+-+-+-+-+-+-+         +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |C|0|ADU size (6 bits)|   |C|1|     ADU size (14 bits)    |
+// +-+-+-+-+-+-+-+-+         +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// C: the continuation of the fragmented ADU,the ADU size is the size of the whole ADU
+//
+// the clock rate is 90000,the interleaved ADUs(section 7) are reordered by the unpacker,
+// the packer does not interleave
+
+const (
+    MPA_ROBUST_CLOCK_RATE = 90000
+    mp3MaxReservoir       = 4096
+)
+
+type MpaRobustPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+    encoder  mp3AduEncoder
+}
+
+func NewMpaRobustPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *MpaRobustPacker {
+    return &MpaRobustPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+// data is one or more mp3 frames,the timestamp is the time of the first frame,
+// the ADUs of the frames are aggregated into one packet if possible
+func (packer *MpaRobustPacker) Pack(data []byte, timestamp uint32) error {
+    var adus [][]byte
+    var timestamps []uint32
+    for len(data) > 0 {
+        head, err := codec.DecodeMp3Head(data)
+        if err != nil {
+            return err
+        }
+        if head.FrameSize <= 0 || head.FrameSize > len(data) {
+            return errors.New("incomplete mp3 frame")
+        }
+        adu, err := packer.encoder.encode(data[:head.FrameSize])
+        if err != nil {
+            return err
+        }
+        if adu != nil {
+            adus = append(adus, adu)
+            timestamps = append(timestamps, timestamp)
+        }
+        timestamp += mp3FrameDuration(head)
+        data = data[head.FrameSize:]
+    }
+
+    //the timestamp of packet is the time of its first ADU
+    maxPayload := packer.mtu - RTP_FIX_HEAD_LEN
+    payload := make([]byte, 0, maxPayload)
+    payloadTs := uint32(0)
+    for i, adu := range adus {
+        if len(payload)+2+len(adu) > maxPayload && len(payload) > 0 {
+            if err := packer.send(payload, payloadTs); err != nil {
+                return err
+            }
+            payload = payload[:0]
+        }
+        if 2+len(adu) <= maxPayload {
+            if len(payload) == 0 {
+                payloadTs = timestamps[i]
+            }
+            payload = appendAduDescriptor(payload, false, len(adu))
+            payload = append(payload, adu...)
+            continue
+        }
+        //the fragments of ADU are sent in the separate packets
+        for offset := 0; offset < len(adu); offset += maxPayload - 2 {
+            end := offset + maxPayload - 2
+            if end > len(adu) {
+                end = len(adu)
+            }
+            fragment := appendAduDescriptor(make([]byte, 0, 2+end-offset), offset > 0, len(adu))
+            fragment = append(fragment, adu[offset:end]...)
+            if err := packer.send(fragment, timestamps[i]); err != nil {
+                return err
+            }
+        }
+    }
+    if len(payload) > 0 {
+        return packer.send(payload, payloadTs)
+    }
+    return nil
+}
+
+func (packer *MpaRobustPacker) send(payload []byte, timestamp uint32) error {
+    pkg := RtpPacket{}
+    pkg.Header.PayloadType = packer.pt
+    pkg.Header.SequenceNumber = packer.sequence
+    pkg.Header.SSRC = packer.ssrc
+    pkg.Header.Timestamp = timestamp
+    pkg.Payload = make([]byte, len(payload))
+    copy(pkg.Payload, payload)
+    packer.sequence++
+    if packer.onRtp != nil {
+        packer.onRtp(&pkg)
+    }
+    if packer.onPacket != nil {
+        return packer.onPacket(pkg.Encode())
+    }
+    return nil
+}
+
+// the descriptor of fragment is always 2 bytes
+func appendAduDescriptor(payload []byte, continuation bool, size int) []byte {
+    var c byte = 0
+    if continuation {
+        c = 0x80
+    }
+    if size < 64 && !continuation {
+        return append(payload, c|byte(size))
+    }
+    return append(payload, c|0x40|byte(size>>8&0x3F), byte(size))
+}
+
+type interleavedAdu struct {
+    index     int
+    adu       []byte
+    timestamp uint32
+}
+
+// the ADUs are rearranged into mp3 frames
+type MpaRobustUnPacker struct {
+    CommUnPacker
+    decoder     mp3AduDecoder
+    fragment    []byte
+    aduSize     int
+    timestamp   uint32
+    cycle       int //the cycle count of the interleaved ADUs,-1 if no ADU is waiting
+    interleaved []interleavedAdu
+}
+
+func NewMpaRobustUnPacker() *MpaRobustUnPacker {
+    unpacker := &MpaRobustUnPacker{cycle: -1}
+    unpacker.decoder.onFrame = func(frame []byte, timestamp uint32) {
+        if unpacker.onFrame != nil {
+            unpacker.onFrame(frame, timestamp, false)
+        }
+    }
+    return unpacker
+}
+
+func (unpacker *MpaRobustUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    timestamp := pkg.Header.Timestamp
+    payload := pkg.Payload
+    for len(payload) > 0 {
+        continuation := payload[0]&0x80 > 0
+        size := int(payload[0] & 0x3F)
+        descriptorLen := 1
+        if payload[0]&0x40 > 0 {
+            if len(payload) < 2 {
+                return errors.New("adu descriptor need 2 bytes")
+            }
+            size = size<<8 | int(payload[1])
+            descriptorLen = 2
+        }
+        payload = payload[descriptorLen:]
+        if continuation && (len(unpacker.fragment) == 0 || unpacker.aduSize != size) {
+            //the head of ADU is lost,the fragment is the only one in packet
+            unpacker.fragment = unpacker.fragment[:0]
+            break
+        }
+        n := size - len(unpacker.fragment)
+        if !continuation {
+            n = size
+        }
+        if n > len(payload) {
+            n = len(payload)
+        }
+        data := payload[:n]
+        payload = payload[n:]
+
+        if continuation {
+            unpacker.fragment = append(unpacker.fragment, data...)
+        } else {
+            unpacker.fragment = append(unpacker.fragment[:0], data...)
+            unpacker.aduSize = size
+            unpacker.timestamp = timestamp
+        }
+        if len(unpacker.fragment) < unpacker.aduSize {
+            continue
+        }
+        duration, err := unpacker.input(unpacker.fragment, unpacker.timestamp)
+        unpacker.fragment = unpacker.fragment[:0]
+        if err != nil {
+            return err
+        }
+        //the next ADU in the same packet
+        timestamp += duration
+    }
+    return nil
+}
+
+// RFC5219 7,the first 11 bits(sync word) of the interleaved ADU are replaced by
+// 8 bits interleave index and 3 bits cycle count,
+// the ADUs of one cycle are decoded in the order of interleave index when the cycle count changes
+func (unpacker *MpaRobustUnPacker) input(adu []byte, timestamp uint32) (uint32, error) {
+    if len(adu) < 4 {
+        return 0, errors.New("adu is less than 4 bytes")
+    }
+    if adu[0] == 0xFF && adu[1]&0xE0 == 0xE0 {
+        if err := unpacker.deinterleave(); err != nil {
+            return 0, err
+        }
+        return unpacker.decoder.decode(adu, timestamp)
+    }
+    cycle := int(adu[1] >> 5)
+    if cycle != unpacker.cycle {
+        if err := unpacker.deinterleave(); err != nil {
+            return 0, err
+        }
+        unpacker.cycle = cycle
+    }
+    restored := make([]byte, len(adu))
+    copy(restored, adu)
+    restored[0] = 0xFF
+    restored[1] |= 0xE0
+    head, err := codec.DecodeMp3Head(restored)
+    if err != nil {
+        return 0, err
+    }
+    unpacker.interleaved = append(unpacker.interleaved, interleavedAdu{index: int(adu[0]), adu: restored, timestamp: timestamp})
+    return mp3FrameDuration(head), nil
+}
+
+func (unpacker *MpaRobustUnPacker) deinterleave() error {
+    adus := unpacker.interleaved
+    unpacker.interleaved = nil
+    unpacker.cycle = -1
+    sort.SliceStable(adus, func(i, j int) bool {
+        return adus[i].index < adus[j].index
+    })
+    for _, a := range adus {
+        if _, err := unpacker.decoder.decode(a.adu, a.timestamp); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// the header,crc and side info of layer III frame
+func mp3SideInfoLen(head *codec.MP3FrameHead) int {
+    n := 4
+    if head.Protecttion == 0 {
+        n += 2
+    }
+    mono := head.Mode == 0x03
+    if head.Version == codec.VERSION_MPEG_1 {
+        if mono {
+            return n + 17
+        }
+        return n + 32
+    }
+    if mono {
+        return n + 9
+    }
+    return n + 17
+}
+
+// main_data_begin and the size of main data in bytes(part2_3_length of all granules and channels)
+func mp3MainData(head *codec.MP3FrameHead, sideInfo []byte) (mainDataBegin int, mainDataSize int) {
+    bs := codec.NewBitStream(sideInfo)
+    channels := 2
+    if head.Mode == 0x03 {
+        channels = 1
+    }
+    bits := 0
+    if head.Version == codec.VERSION_MPEG_1 {
+        mainDataBegin = int(bs.GetBits(9))
+        if channels == 1 {
+            bs.SkipBits(5 + 4)
+        } else {
+            bs.SkipBits(3 + 8)
+        }
+        for gr := 0; gr < 2; gr++ {
+            for ch := 0; ch < channels; ch++ {
+                bits += int(bs.GetBits(12))
+                bs.SkipBits(47)
+            }
+        }
+    } else {
+        mainDataBegin = int(bs.GetBits(8))
+        bs.SkipBits(channels)
+        for ch := 0; ch < channels; ch++ {
+            bits += int(bs.GetBits(12))
+            bs.SkipBits(51)
+        }
+    }
+    return mainDataBegin, (bits + 7) / 8
+}
+
+func setMainDataBegin(head *codec.MP3FrameHead, sideInfo []byte, mainDataBegin int) {
+    if head.Version == codec.VERSION_MPEG_1 {
+        sideInfo[0] = byte(mainDataBegin >> 1)
+        sideInfo[1] = sideInfo[1]&0x7F | byte(mainDataBegin&0x01)<<7
+    } else {
+        sideInfo[0] = byte(mainDataBegin)
+    }
+}
+
+func mp3FrameDuration(head *codec.MP3FrameHead) uint32 {
+    if head.GetSampleRate() == 0 {
+        return 0
+    }
+    return uint32(head.SampleSize * MPA_ROBUST_CLOCK_RATE / head.GetSampleRate())
+}
+
+func decodeLayer3Head(data []byte) (*codec.MP3FrameHead, int, error) {
+    head, err := codec.DecodeMp3Head(data)
+    if err != nil {
+        return nil, 0, err
+    }
+    if head.Layer != codec.LAYER_3 {
+        return nil, 0, errors.New("mpa-robust only support layer III")
+    }
+    sideInfoLen := mp3SideInfoLen(head)
+    if len(data) < sideInfoLen {
+        return nil, 0, errors.New("mp3 frame is less than side info")
+    }
+    return head, sideInfoLen, nil
+}
+
+// the main data of frame may begin in the previous frames(bit reservoir)
+type mp3AduEncoder struct {
+    reservoir []byte
+}
+
+// nil if the main data is in the frames before the first frame
+func (enc *mp3AduEncoder) encode(frame []byte) ([]byte, error) {
+    head, sideInfoLen, err := decodeLayer3Head(frame)
+    if err != nil {
+        return nil, err
+    }
+    hdrLen := 4
+    if head.Protecttion == 0 {
+        hdrLen += 2
+    }
+    mainDataBegin, mainDataSize := mp3MainData(head, frame[hdrLen:sideInfoLen])
+    stream := append(enc.reservoir, frame[sideInfoLen:]...)
+    start := len(enc.reservoir) - mainDataBegin
+    if len(stream) > mp3MaxReservoir {
+        enc.reservoir = append([]byte{}, stream[len(stream)-mp3MaxReservoir:]...)
+    } else {
+        enc.reservoir = stream
+    }
+    if start < 0 {
+        return nil, nil
+    }
+    end := start + mainDataSize
+    if end > len(stream) {
+        return nil, errors.New("main data of mp3 frame is out of range")
+    }
+    adu := make([]byte, 0, sideInfoLen+mainDataSize)
+    adu = append(adu, frame[:sideInfoLen]...)
+    return append(adu, stream[start:end]...), nil
+}
+
+type aduFrame struct {
+    head      []byte //header,crc and side info
+    frameSize int
+    timestamp uint32
+}
+
+// the main data of ADU is placed at main_data_begin before the data area of its frame,
+// main_data_begin is rewritten if the ADU is moved because of the lost ADUs,
+// the frame is sent when no later ADU can put data into it
+type mp3AduDecoder struct {
+    frames   []*aduFrame //not sent
+    base     int         //the position of the data area of frames[0]
+    tail     int         //the end of the main data of last ADU
+    mainData []byte      //from base
+    onFrame  func(frame []byte, timestamp uint32)
+}
+
+// return the duration of ADU
+func (dec *mp3AduDecoder) decode(adu []byte, timestamp uint32) (uint32, error) {
+    head, sideInfoLen, err := decodeLayer3Head(adu)
+    if err != nil {
+        return 0, err
+    }
+    if head.FrameSize < sideInfoLen {
+        return 0, errors.New("mp3 frame size is less than side info")
+    }
+    hdrLen := 4
+    if head.Protecttion == 0 {
+        hdrLen += 2
+    }
+    mainDataBegin, _ := mp3MainData(head, adu[hdrLen:sideInfoLen])
+    data := adu[sideInfoLen:]
+    areaSize := head.FrameSize - sideInfoLen
+
+    areaStart := dec.base
+    for _, f := range dec.frames {
+        areaStart += f.frameSize - len(f.head)
+    }
+    areaEnd := areaStart + areaSize
+    dec.mainData = append(dec.mainData, make([]byte, areaSize)...)
+
+    start := areaStart - mainDataBegin
+    if start < dec.tail {
+        start = dec.tail
+    }
+    if start < dec.base {
+        start = dec.base
+    }
+    if start > areaStart {
+        start = areaStart
+    }
+    if start+len(data) > areaEnd {
+        data = data[:areaEnd-start]
+    }
+    copy(dec.mainData[start-dec.base:], data)
+    dec.tail = start + len(data)
+
+    frameHead := append([]byte{}, adu[:sideInfoLen]...)
+    setMainDataBegin(head, frameHead[hdrLen:], areaStart-start)
+    dec.frames = append(dec.frames, &aduFrame{head: frameHead, frameSize: head.FrameSize, timestamp: timestamp})
+
+    maxMainDataBegin := 511
+    if head.Version != codec.VERSION_MPEG_1 {
+        maxMainDataBegin = 255
+    }
+    //the main data of next ADU begins at areaEnd-maxMainDataBegin at least
+    for len(dec.frames) > 0 {
+        f := dec.frames[0]
+        size := f.frameSize - len(f.head)
+        if dec.tail < dec.base+size && areaEnd-maxMainDataBegin < dec.base+size {
+            break
+        }
+        frame := make([]byte, 0, f.frameSize)
+        frame = append(frame, f.head...)
+        frame = append(frame, dec.mainData[:size]...)
+        dec.mainData = dec.mainData[size:]
+        dec.base += size
+        dec.frames = dec.frames[1:]
+        if dec.onFrame != nil {
+            dec.onFrame(frame, f.timestamp)
+        }
+    }
+    return mp3FrameDuration(head), nil
+}
@@ -0,0 +1,124 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+// mpeg1 layer III,128kbps,44100Hz,mono,417 bytes,
+// 4 bytes header,17 bytes side info and 396 bytes data area
+func makeMp3TestFrame(mainDataBegin int, mainDataSize int, area []byte) []byte {
+	frame := make([]byte, 21, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+	sideInfo := frame[4:]
+	setBits := func(pos int, n int, v int) {
+		for i := 0; i < n; i++ {
+			if v>>(n-1-i)&0x01 > 0 {
+				sideInfo[(pos+i)/8] |= 0x80 >> ((pos + i) % 8)
+			}
+		}
+	}
+	setBits(0, 9, mainDataBegin)
+	//part2_3_length of two granules
+	setBits(18, 12, mainDataSize*4)
+	setBits(77, 12, mainDataSize*4)
+	return append(frame, area...)
+}
+
+func makeMp3TestArea(n int, seed byte) []byte {
+	area := make([]byte, n)
+	for i := range area {
+		area[i] = seed + byte(i*7)
+	}
+	return area
+}
+
+// the main data of frame 1 begins in frame 0
+func makeMp3TestFrames() [][]byte {
+	main0 := makeMp3TestArea(300, 0x10)
+	main1 := makeMp3TestArea(492, 0x20)
+	return [][]byte{
+		makeMp3TestFrame(0, 300, append(append([]byte{}, main0...), main1[:96]...)),
+		makeMp3TestFrame(96, 492, main1[96:]),
+		makeMp3TestFrame(0, 396, makeMp3TestArea(396, 0x30)),
+		makeMp3TestFrame(0, 396, makeMp3TestArea(396, 0x40)),
+	}
+}
+
+func unpackMpaRobustTest(t *testing.T, pkts [][]byte) ([][]byte, []uint32) {
+	unpacker := NewMpaRobustUnPacker()
+	var frames [][]byte
+	var timestamps []uint32
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		frames = append(frames, append([]byte{}, frame...))
+		timestamps = append(timestamps, timestamp)
+	})
+	for _, pkt := range pkts {
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("MpaRobustUnPacker.UnPack() error = %v", err)
+		}
+	}
+	return frames, timestamps
+}
+
+func TestMpaRobustPacker(t *testing.T) {
+	frames := makeMp3TestFrames()
+	for _, mtu := range []int{1400, 200} {
+		packer := NewMpaRobustPacker(96, 0x1234, 0, mtu)
+		var pkts [][]byte
+		packer.OnPacket(func(pkt []byte) error {
+			pkts = append(pkts, pkt)
+			return nil
+		})
+		if err := packer.Pack(bytes.Join(frames, nil), 90000); err != nil {
+			t.Fatalf("MpaRobustPacker.Pack() error = %v", err)
+		}
+		for _, pkt := range pkts {
+			if len(pkt) > mtu {
+				t.Errorf("mtu %d,packet %d bytes", mtu, len(pkt))
+			}
+		}
+		got, timestamps := unpackMpaRobustTest(t, pkts)
+		if len(got) != len(frames) {
+			t.Fatalf("mtu %d,unpacked %d frames, want %d", mtu, len(got), len(frames))
+		}
+		for i := range frames {
+			if !bytes.Equal(got[i], frames[i]) {
+				t.Errorf("mtu %d,frame %d is not restored", mtu, i)
+			}
+			if timestamps[i] != uint32(90000+i*2351) {
+				t.Errorf("mtu %d,frame %d timestamp %d", mtu, i, timestamps[i])
+			}
+		}
+	}
+}
+
+func TestMpaRobustUnPacker_Interleave(t *testing.T) {
+	var frames [][]byte
+	for i := 0; i < 5; i++ {
+		frames = append(frames, makeMp3TestFrame(0, 396, makeMp3TestArea(396, byte(i))))
+	}
+	makePacket := func(i int, index int, cycle int) []byte {
+		adu := append([]byte{}, frames[i]...)
+		if index >= 0 {
+			adu[0] = byte(index)
+			adu[1] = byte(cycle<<5) | adu[1]&0x1F
+		}
+		pkg := RtpPacket{Payload: append([]byte{0x40 | byte(len(adu)>>8), byte(len(adu))}, adu...)}
+		pkg.Header.PayloadType = 96
+		pkg.Header.SequenceNumber = uint16(i)
+		pkg.Header.Timestamp = uint32(i * 2351)
+		return pkg.Encode()
+	}
+	//the interleave cycle of two ADUs,the non-interleaved ADU ends the last cycle
+	pkts := [][]byte{makePacket(1, 1, 0), makePacket(0, 0, 0), makePacket(3, 1, 1), makePacket(2, 0, 1), makePacket(4, -1, 0)}
+	got, timestamps := unpackMpaRobustTest(t, pkts)
+	if len(got) != len(frames) {
+		t.Fatalf("unpacked %d frames, want %d", len(got), len(frames))
+	}
+	for i := range frames {
+		if !bytes.Equal(got[i], frames[i]) || timestamps[i] != uint32(i*2351) {
+			t.Errorf("frame %d is not deinterleaved,timestamp %d", i, timestamps[i])
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package rtp
+
+import (
+    "bytes"
+    "encoding/binary"
+    "errors"
+
+    "github.com/yapingcat/gomedia/go-codec"
+)
+
+// RFC2250 3.5 MPEG Audio-specific header
+// the clock rate is 90000,static payload type 14
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |             MBZ               |          Frag_offset          |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+//
+// a packet contains one or more whole frames,or a fragment of one frame,
+// Frag_offset is the byte offset of the fragment in the frame
+
+const MPA_HEAD_LEN = 4
+
+type MpaPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+}
+
+func NewMpaPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *MpaPacker {
+    return &MpaPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+// data is one or more mpeg audio frames with the same timestamp,
+// the whole frames are aggregated into one packet,the frame larger than mtu is fragmented
+func (packer *MpaPacker) Pack(data []byte, timestamp uint32) error {
+    maxPayload := packer.mtu - RTP_FIX_HEAD_LEN - MPA_HEAD_LEN
+    payload := make([]byte, 0, maxPayload)
+    for len(data) > 0 {
+        frameSize := len(data)
+        if head, err := codec.DecodeMp3Head(data); err == nil && head.FrameSize > 0 && head.FrameSize < len(data) {
+            frameSize = head.FrameSize
+        }
+        if len(payload)+frameSize > maxPayload && len(payload) > 0 {
+            if err := packer.send(payload, 0, timestamp); err != nil {
+                return err
+            }
+            payload = payload[:0]
+        }
+        if frameSize <= maxPayload {
+            payload = append(payload, data[:frameSize]...)
+        } else {
+            for offset := 0; offset < frameSize; offset += maxPayload {
+                end := offset + maxPayload
+                if end > frameSize {
+                    end = frameSize
+                }
+                if err := packer.send(data[offset:end], offset, timestamp); err != nil {
+                    return err
+                }
+            }
+        }
+        data = data[frameSize:]
+    }
+    if len(payload) > 0 {
+        return packer.send(payload, 0, timestamp)
+    }
+    return nil
+}
+
+func (packer *MpaPacker) send(data []byte, fragOffset int, timestamp uint32) error {
+    pkg := RtpPacket{}
+    pkg.Header.PayloadType = packer.pt
+    pkg.Header.SequenceNumber = packer.sequence
+    pkg.Header.SSRC = packer.ssrc
+    pkg.Header.Timestamp = timestamp
+    pkg.Payload = make([]byte, MPA_HEAD_LEN+len(data))
+    binary.BigEndian.PutUint16(pkg.Payload[2:], uint16(fragOffset))
+    copy(pkg.Payload[MPA_HEAD_LEN:], data)
+    packer.sequence++
+    if packer.onRtp != nil {
+        packer.onRtp(&pkg)
+    }
+    if packer.onPacket != nil {
+        return packer.onPacket(pkg.Encode())
+    }
+    return nil
+}
+
+// the frames are split by the frame size of mpeg audio header,
+// the fragments of a frame are reassembled by Frag_offset
+type MpaUnPacker struct {
+    CommUnPacker
+    timestamp   uint32
+    fragOffset  int //the expected Frag_offset of next fragment
+    frameBuffer *bytes.Buffer
+}
+
+func NewMpaUnPacker() *MpaUnPacker {
+    return &MpaUnPacker{
+        frameBuffer: new(bytes.Buffer),
+    }
+}
+
+func (unpacker *MpaUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    if len(pkg.Payload) < MPA_HEAD_LEN {
+        return errors.New("mpa rtp packet less than 4 bytes")
+    }
+    fragOffset := int(binary.BigEndian.Uint16(pkg.Payload[2:]))
+    payload := pkg.Payload[MPA_HEAD_LEN:]
+    if fragOffset == 0 {
+        //the tail of last frame is lost
+        if unpacker.frameBuffer.Len() > 0 {
+            unpacker.flush(true)
+        }
+        unpacker.timestamp = pkg.Header.Timestamp
+    } else if unpacker.frameBuffer.Len() == 0 || fragOffset != unpacker.fragOffset || pkg.Header.Timestamp != unpacker.timestamp {
+        //the middle or head of the frame is lost
+        unpacker.frameBuffer.Reset()
+        return nil
+    }
+    unpacker.fragOffset = fragOffset + len(payload)
+    unpacker.frameBuffer.Write(payload)
+    unpacker.splitFrames()
+    return nil
+}
+
+// the whole frames in buffer are sent,the incomplete frame is kept,
+// the data can not be decoded as mpeg audio is sent as lost
+func (unpacker *MpaUnPacker) splitFrames() {
+    data := unpacker.frameBuffer.Bytes()
+    lost := false
+    for len(data) > 0 {
+        head, err := codec.DecodeMp3Head(data)
+        if err != nil {
+            lost = true
+            break
+        }
+        if head.FrameSize <= 0 {
+            //free format,the size of frame is unknown
+            break
+        }
+        if head.FrameSize > len(data) {
+            rest := append([]byte{}, data...)
+            unpacker.frameBuffer.Reset()
+            unpacker.frameBuffer.Write(rest)
+            return
+        }
+        if unpacker.onFrame != nil {
+            unpacker.onFrame(data[:head.FrameSize], unpacker.timestamp, false)
+        }
+        data = data[head.FrameSize:]
+    }
+    if len(data) > 0 && unpacker.onFrame != nil {
+        unpacker.onFrame(data, unpacker.timestamp, lost)
+    }
+    unpacker.frameBuffer.Reset()
+}
+
+func (unpacker *MpaUnPacker) flush(lost bool) {
+    if unpacker.onFrame != nil {
+        unpacker.onFrame(unpacker.frameBuffer.Bytes(), unpacker.timestamp, lost)
+    }
+    unpacker.frameBuffer.Reset()
+}
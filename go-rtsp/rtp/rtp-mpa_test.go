@@ -0,0 +1,116 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+type mpaTestFrame struct {
+	data      []byte
+	timestamp uint32
+	lost      bool
+}
+
+// mpeg1 layer III,128kbps,44100Hz,mono,417 bytes
+func makeMpaTestFrame(fill byte) []byte {
+	frame := bytes.Repeat([]byte{fill}, 417)
+	copy(frame, []byte{0xFF, 0xFB, 0x90, 0xC0})
+	return frame
+}
+
+func packMpaTest(t *testing.T, mtu int, data []byte, timestamp uint32) [][]byte {
+	packer := NewMpaPacker(14, 0x1234, 0, mtu)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	if err := packer.Pack(data, timestamp); err != nil {
+		t.Fatalf("MpaPacker.Pack() error = %v", err)
+	}
+	return pkts
+}
+
+func unpackMpaTest(t *testing.T, pkts ...[]byte) []mpaTestFrame {
+	unpacker := NewMpaUnPacker()
+	var frames []mpaTestFrame
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		frames = append(frames, mpaTestFrame{append([]byte{}, frame...), timestamp, lost})
+	})
+	for _, pkt := range pkts {
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("MpaUnPacker.UnPack() error = %v", err)
+		}
+	}
+	return frames
+}
+
+func TestMpaPacker_Aggregate(t *testing.T) {
+	frame1, frame2 := makeMpaTestFrame(0x11), makeMpaTestFrame(0x22)
+	pkts := packMpaTest(t, 1400, append(append([]byte{}, frame1...), frame2...), 9000)
+	if len(pkts) != 1 {
+		t.Fatalf("packed %d packets, want 1", len(pkts))
+	}
+	var pkg RtpPacket
+	if err := pkg.Decode(pkts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pkg.Payload[:MPA_HEAD_LEN], []byte{0, 0, 0, 0}) || len(pkg.Payload) != MPA_HEAD_LEN+2*417 {
+		t.Fatalf("payload %d bytes,head %x", len(pkg.Payload), pkg.Payload[:MPA_HEAD_LEN])
+	}
+	frames := unpackMpaTest(t, pkts...)
+	if len(frames) != 2 || !bytes.Equal(frames[0].data, frame1) || !bytes.Equal(frames[1].data, frame2) {
+		t.Fatalf("unpacked %d frames", len(frames))
+	}
+	if frames[0].timestamp != 9000 || frames[0].lost || frames[1].lost {
+		t.Errorf("frames %+v", frames)
+	}
+}
+
+func TestMpaPacker_Fragment(t *testing.T) {
+	frame := makeMpaTestFrame(0x33)
+	//the payload of mtu 200 is 184 bytes
+	pkts := packMpaTest(t, 200, frame, 9000)
+	if len(pkts) != 3 {
+		t.Fatalf("packed %d packets, want 3", len(pkts))
+	}
+	for i, pkt := range pkts {
+		var pkg RtpPacket
+		if err := pkg.Decode(pkt); err != nil {
+			t.Fatal(err)
+		}
+		if offset := int(pkg.Payload[2])<<8 | int(pkg.Payload[3]); offset != i*184 {
+			t.Errorf("fragment %d Frag_offset = %d", i, offset)
+		}
+	}
+	frames := unpackMpaTest(t, pkts...)
+	if len(frames) != 1 || !bytes.Equal(frames[0].data, frame) || frames[0].lost {
+		t.Fatalf("unpacked %+v", frames)
+	}
+
+	//the middle fragment is lost,the incomplete frame is dropped
+	next := packMpaTest(t, 200, makeMpaTestFrame(0x44), 11351)
+	frames = unpackMpaTest(t, pkts[0], pkts[2], next[0], next[1], next[2])
+	if len(frames) != 1 || frames[0].lost || frames[0].timestamp != 11351 || !bytes.Equal(frames[0].data, makeMpaTestFrame(0x44)) {
+		t.Fatalf("unpacked %+v", frames)
+	}
+
+	//the tail fragment is lost,the next frame reports the incomplete one
+	frames = unpackMpaTest(t, pkts[0], pkts[1], next[0], next[1], next[2])
+	if len(frames) != 2 || !frames[0].lost || !bytes.Equal(frames[0].data, frame[:368]) || frames[1].lost {
+		t.Fatalf("unpacked %+v", frames)
+	}
+}
+
+func TestMpaUnPacker_Undecodable(t *testing.T) {
+	frame := makeMpaTestFrame(0x55)
+	pkg := RtpPacket{Payload: append(append(make([]byte, MPA_HEAD_LEN), frame...), 0x00, 0x01, 0x02, 0x03, 0x04)}
+	pkg.Header.PayloadType = 14
+	frames := unpackMpaTest(t, pkg.Encode())
+	if len(frames) != 2 || !bytes.Equal(frames[0].data, frame) || frames[0].lost {
+		t.Fatalf("unpacked %+v", frames)
+	}
+	if !frames[1].lost || !bytes.Equal(frames[1].data, []byte{0x00, 0x01, 0x02, 0x03, 0x04}) {
+		t.Errorf("the undecodable data %+v", frames[1])
+	}
+}
@@ -0,0 +1,86 @@
+package rtp
+
+import (
+    "errors"
+)
+
+// RFC7587
+// one opus packet in one rtp packet,the clock rate is always 48000
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |V=2|P|X|  CC   |M|     PT      |       sequence number         |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                           timestamp                           |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |           synchronization source (SSRC) identifier            |
+// +=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+=+
+// |                                                               |
+// |                     Opus packet                               |
+// |                                                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+type OpusPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+}
+
+func NewOpusPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *OpusPacker {
+    return &OpusPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+func (packer *OpusPacker) Pack(data []byte, timestamp uint32) error {
+    if len(data)+RTP_FIX_HEAD_LEN > packer.mtu {
+        return errors.New("opus packet size too large than mtu")
+    }
+    pkg := RtpPacket{}
+    pkg.Header.PayloadType = packer.pt
+    pkg.Header.SequenceNumber = packer.sequence
+    pkg.Header.SSRC = packer.ssrc
+    pkg.Header.Timestamp = timestamp
+    pkg.Payload = make([]byte, len(data))
+    copy(pkg.Payload, data)
+    packer.sequence++
+    if packer.onRtp != nil {
+        packer.onRtp(&pkg)
+    }
+    if packer.onPacket != nil {
+        return packer.onPacket(pkg.Encode())
+    }
+    return nil
+}
+
+type OpusUnPacker struct {
+    CommUnPacker
+}
+
+func NewOpusUnPacker() *OpusUnPacker {
+    return &OpusUnPacker{}
+}
+
+func (unpacker *OpusUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    //the empty packet is dtx,no frame
+    if len(pkg.Payload) == 0 {
+        return nil
+    }
+    if unpacker.onFrame != nil {
+        unpacker.onFrame(pkg.Payload, pkg.Header.Timestamp, false)
+    }
+    return nil
+}
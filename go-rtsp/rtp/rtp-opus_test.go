@@ -0,0 +1,56 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestOpusPacker(t *testing.T) {
+	packer := NewOpusPacker(111, 0x1234, 65535, 1400)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	frames := [][]byte{{0xFC, 0x01, 0x02}, {}, {0x78, 0x03}}
+	for i, frame := range frames {
+		if err := packer.Pack(frame, uint32(i*960)); err != nil {
+			t.Fatalf("OpusPacker.Pack() error = %v", err)
+		}
+	}
+	if err := packer.Pack(make([]byte, 1400), 0); err == nil {
+		t.Error("the opus packet larger than mtu is packed")
+	}
+	if len(pkts) != 3 {
+		t.Fatalf("packed %d packets, want 3", len(pkts))
+	}
+	for i, pkt := range pkts {
+		var pkg RtpPacket
+		if err := pkg.Decode(pkt); err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Header.PayloadType != 111 || pkg.Header.SequenceNumber != uint16(65535+i) || pkg.Header.Timestamp != uint32(i*960) {
+			t.Errorf("packet %d header = %+v", i, pkg.Header)
+		}
+	}
+
+	unpacker := NewOpusUnPacker()
+	var got [][]byte
+	var timestamps []uint32
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		got = append(got, append([]byte{}, frame...))
+		timestamps = append(timestamps, timestamp)
+	})
+	for _, pkt := range pkts {
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("OpusUnPacker.UnPack() error = %v", err)
+		}
+	}
+	//the empty packet of dtx is not a frame
+	if len(got) != 2 || !bytes.Equal(got[0], frames[0]) || !bytes.Equal(got[1], frames[2]) {
+		t.Fatalf("unpacked %x", got)
+	}
+	if timestamps[0] != 0 || timestamps[1] != 1920 {
+		t.Errorf("timestamps %v", timestamps)
+	}
+}
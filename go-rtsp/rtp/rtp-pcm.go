@@ -0,0 +1,92 @@
+package rtp
+
+import (
+    "errors"
+)
+
+// RFC3551 4.5.11 L16 and RFC3190 L24
+// the samples are in network byte order(big endian),the channels are interleaved,
+// the timestamp is increased by the number of samples per channel
+// static payload type 10 is L16/44100/2,11 is L16/44100/1
+
+type PcmPacker struct {
+    CommPacker
+    pt        uint8
+    ssrc      uint32
+    sequence  uint16
+    frameSize int //the bytes of one sample of all channels
+}
+
+// sampleSize is 2 for L16 and 3 for L24
+func NewPcmPacker(pt uint8, ssrc uint32, sequence uint16, mtu int, sampleSize int, channelCount int) *PcmPacker {
+    if channelCount <= 0 {
+        channelCount = 1
+    }
+    return &PcmPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        frameSize:  sampleSize * channelCount,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+// the samples larger than mtu are split into multiple packets at the boundary of sample
+func (packer *PcmPacker) Pack(data []byte, timestamp uint32) error {
+    if len(data)%packer.frameSize != 0 {
+        return errors.New("pcm data is not aligned with the sample size")
+    }
+    maxPayload := (packer.mtu - RTP_FIX_HEAD_LEN) / packer.frameSize * packer.frameSize
+    if maxPayload == 0 {
+        return errors.New("mtu is less than one pcm sample")
+    }
+    for len(data) > 0 {
+        size := len(data)
+        if size > maxPayload {
+            size = maxPayload
+        }
+        pkg := RtpPacket{}
+        pkg.Header.PayloadType = packer.pt
+        pkg.Header.SequenceNumber = packer.sequence
+        pkg.Header.SSRC = packer.ssrc
+        pkg.Header.Timestamp = timestamp
+        pkg.Payload = make([]byte, size)
+        copy(pkg.Payload, data[:size])
+        packer.sequence++
+        if packer.onRtp != nil {
+            packer.onRtp(&pkg)
+        }
+        if packer.onPacket != nil {
+            if err := packer.onPacket(pkg.Encode()); err != nil {
+                return err
+            }
+        }
+        timestamp += uint32(size / packer.frameSize)
+        data = data[size:]
+    }
+    return nil
+}
+
+type PcmUnPacker struct {
+    CommUnPacker
+}
+
+func NewPcmUnPacker() *PcmUnPacker {
+    return &PcmUnPacker{}
+}
+
+func (unpacker *PcmUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    if unpacker.onFrame != nil {
+        unpacker.onFrame(pkg.Payload, pkg.Header.Timestamp, false)
+    }
+    return nil
+}
@@ -0,0 +1,63 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPcmPacker(t *testing.T) {
+	//L24 stereo,6 bytes per sample,the payload of mtu 100 is 84 bytes
+	packer := NewPcmPacker(97, 0x1234, 100, 100, 3, 2)
+	var pkts [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		pkts = append(pkts, pkt)
+		return nil
+	})
+	data := make([]byte, 6*30)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := packer.Pack(data, 1000); err != nil {
+		t.Fatalf("PcmPacker.Pack() error = %v", err)
+	}
+	if err := packer.Pack(data[:5], 0); err == nil {
+		t.Error("the data not aligned with the sample size is packed")
+	}
+	if len(pkts) != 3 {
+		t.Fatalf("packed %d packets, want 3", len(pkts))
+	}
+
+	unpacker := NewPcmUnPacker()
+	var got []byte
+	var timestamps []uint32
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		got = append(got, frame...)
+		timestamps = append(timestamps, timestamp)
+	})
+	for i, pkt := range pkts {
+		var pkg RtpPacket
+		if err := pkg.Decode(pkt); err != nil {
+			t.Fatal(err)
+		}
+		if pkg.Header.SequenceNumber != uint16(100+i) || len(pkg.Payload)%6 != 0 {
+			t.Errorf("packet %d seq %d,payload %d bytes", i, pkg.Header.SequenceNumber, len(pkg.Payload))
+		}
+		if err := unpacker.UnPack(pkt); err != nil {
+			t.Fatalf("PcmUnPacker.UnPack() error = %v", err)
+		}
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("unpacked %x", got)
+	}
+	//the timestamp is increased by the samples per channel
+	if timestamps[0] != 1000 || timestamps[1] != 1014 || timestamps[2] != 1028 {
+		t.Errorf("timestamps %v", timestamps)
+	}
+}
+
+func TestPcmPacker_Mtu(t *testing.T) {
+	packer := NewPcmPacker(97, 0x1234, 100, RTP_FIX_HEAD_LEN+5, 3, 2)
+	if err := packer.Pack(make([]byte, 6), 0); err == nil {
+		t.Error("the mtu less than one sample is accepted")
+	}
+}
@@ -1 +1,243 @@
 package rtp
+
+import (
+    "bytes"
+
+    "github.com/yapingcat/gomedia/go-mpeg2"
+)
+
+// MPEG-PS over RTP
+//
+// RFC2250 2. Encapsulation of MPEG System and Transport Streams
+//   the payload is the program stream itself without any additional header,
+//   timestamp is 90kHz and the M bit is set whenever the timestamp is discontinuous,
+//   the packets should be started at pack/PES boundaries if possible
+//
+// GB/T 28181 Annex C
+//   payload type 96,encoding name PS,clock rate 90kHz,
+//   one frame(pack header + system header + psm + PES) is fragmented into rtp packets
+//   with the same timestamp,and the M bit is set on the last packet of the frame
+
+type PS_RTP_MODE int
+
+const (
+    PS_RTP_GB28181 PS_RTP_MODE = iota
+    PS_RTP_RFC2250
+)
+
+type PsPacker struct {
+    CommPacker
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+    mode     PS_RTP_MODE
+}
+
+func NewPsPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *PsPacker {
+    return &PsPacker{
+        pt:         pt,
+        ssrc:       ssrc,
+        sequence:   sequence,
+        mode:       PS_RTP_GB28181,
+        CommPacker: CommPacker{mtu: mtu},
+    }
+}
+
+func (pack *PsPacker) SetMode(mode PS_RTP_MODE) {
+    pack.mode = mode
+}
+
+// data: ps stream of one frame
+// timestamp: 90kHz
+func (pack *PsPacker) Pack(data []byte, timestamp uint32) error {
+    for len(data) > 0 {
+        pkg := RtpPacket{}
+        pkg.Header.PayloadType = pack.pt
+        pkg.Header.SequenceNumber = pack.sequence
+        pkg.Header.SSRC = pack.ssrc
+        pkg.Header.Timestamp = timestamp
+        size := pack.mtu - RTP_FIX_HEAD_LEN
+        if len(data) > size {
+            if pack.mode == PS_RTP_RFC2250 {
+                if pos := lastPsBoundary(data[:size]); pos > 0 {
+                    size = pos
+                }
+            }
+        } else {
+            size = len(data)
+            if pack.mode == PS_RTP_GB28181 {
+                pkg.Header.Marker = 1
+            }
+        }
+        pkg.Payload = make([]byte, size)
+        copy(pkg.Payload, data[:size])
+        data = data[size:]
+        pack.sequence++
+        if pack.onRtp != nil {
+            pack.onRtp(&pkg)
+        }
+        if pack.onPacket != nil {
+            if err := pack.onPacket(pkg.Encode()); err != nil {
+                return err
+            }
+        }
+    }
+    return nil
+}
+
+// the position of the last pack header or PES start code in data
+func lastPsBoundary(data []byte) int {
+    for pos := len(data) - 4; pos > 0; pos-- {
+        if data[pos] == 0x00 && data[pos+1] == 0x00 && data[pos+2] == 0x01 && data[pos+3] >= uint8(mpeg2.PES_STREAM_START) {
+            return pos
+        }
+    }
+    return -1
+}
+
+type PsUnPacker struct {
+    CommUnPacker
+    timestamp    int64
+    lastSequence uint16
+    lost         bool
+    mode         PS_RTP_MODE
+    frameBuffer  *bytes.Buffer
+}
+
+func NewPsUnPacker() *PsUnPacker {
+    return &PsUnPacker{
+        timestamp:   -1,
+        mode:        PS_RTP_GB28181,
+        frameBuffer: new(bytes.Buffer),
+    }
+}
+
+// PS_RTP_GB28181: the frame is completed by M bit or timestamp
+// PS_RTP_RFC2250: the frame is completed by timestamp only
+func (unpacker *PsUnPacker) SetMode(mode PS_RTP_MODE) {
+    unpacker.mode = mode
+}
+
+func (unpacker *PsUnPacker) UnPack(pkt []byte) error {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(pkt); err != nil {
+        return err
+    }
+
+    if unpacker.onRtp != nil {
+        unpacker.onRtp(pkg)
+    }
+
+    if unpacker.timestamp != -1 {
+        if unpacker.lastSequence+1 != pkg.Header.SequenceNumber {
+            unpacker.lost = true
+        }
+        if unpacker.timestamp != int64(pkg.Header.Timestamp) && unpacker.frameBuffer.Len() > 0 {
+            //the last packet of the previous frame is lost or M bit is not set
+            unpacker.flush()
+            if unpacker.lastSequence+1 == pkg.Header.SequenceNumber {
+                unpacker.lost = false
+            }
+        }
+    }
+    unpacker.timestamp = int64(pkg.Header.Timestamp)
+    unpacker.lastSequence = pkg.Header.SequenceNumber
+    unpacker.frameBuffer.Write(pkg.Payload)
+    if unpacker.mode == PS_RTP_GB28181 && pkg.Header.Marker == 1 {
+        unpacker.flush()
+        unpacker.lost = false
+    }
+    return nil
+}
+
+func (unpacker *PsUnPacker) flush() {
+    if unpacker.onFrame != nil {
+        unpacker.onFrame(unpacker.frameBuffer.Bytes(), uint32(unpacker.timestamp), unpacker.lost)
+    }
+    unpacker.frameBuffer.Reset()
+}
+
+// PsEsPacker muxes audio/video frames into program stream by mpeg2.PSMuxer,
+// then packs the program stream of each frame into rtp packets
+type PsEsPacker struct {
+    *PsPacker
+    muxer *mpeg2.PSMuxer
+    buf   []byte
+}
+
+func NewPsEsPacker(pt uint8, ssrc uint32, sequence uint16, mtu int) *PsEsPacker {
+    packer := &PsEsPacker{
+        PsPacker: NewPsPacker(pt, ssrc, sequence, mtu),
+        muxer:    mpeg2.NewPsMuxer(),
+    }
+    packer.muxer.OnPacket = func(pkg []byte) {
+        packer.buf = append(packer.buf, pkg...)
+    }
+    return packer
+}
+
+func (packer *PsEsPacker) AddStream(cid mpeg2.PS_STREAM_TYPE) uint8 {
+    return packer.muxer.AddStream(cid)
+}
+
+// pts/dts: millisecond
+func (packer *PsEsPacker) WriteFrame(sid uint8, frame []byte, pts uint64, dts uint64) error {
+    packer.buf = packer.buf[:0]
+    if err := packer.muxer.Write(sid, frame, pts, dts); err != nil {
+        return err
+    }
+    return packer.Pack(packer.buf, uint32(dts*90))
+}
+
+type ON_PS_ES_FRAME_FUNC func(frame []byte, cid mpeg2.PS_STREAM_TYPE, pts uint64, dts uint64)
+
+// PsEsUnPacker reassembles the program stream from rtp packets and demuxes it by mpeg2.PSDemuxer,
+// the incomplete frame is dropped
+type PsEsUnPacker struct {
+    *PsUnPacker
+    demuxer   *mpeg2.PSDemuxer
+    onEsFrame ON_PS_ES_FRAME_FUNC
+}
+
+func NewPsEsUnPacker() *PsEsUnPacker {
+    unpacker := &PsEsUnPacker{
+        PsUnPacker: NewPsUnPacker(),
+        demuxer:    mpeg2.NewPSDemuxer(),
+    }
+    unpacker.demuxer.OnFrame = func(frame []byte, cid mpeg2.PS_STREAM_TYPE, pts uint64, dts uint64) {
+        if unpacker.onEsFrame != nil {
+            unpacker.onEsFrame(frame, cid, pts, dts)
+        }
+    }
+    unpacker.PsUnPacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+        if lost {
+            unpacker.demuxer.Discontinuity()
+        } else {
+            unpacker.demuxer.Input(frame)
+        }
+    })
+    return unpacker
+}
+
+func (unpacker *PsEsUnPacker) OnEsFrame(onframe ON_PS_ES_FRAME_FUNC) {
+    unpacker.onEsFrame = onframe
+}
+
+// it is called instead of OnEsFrame if set,one access unit per callback,
+// the frame is damaged if part of it is lost
+func (unpacker *PsEsUnPacker) OnEsFrameWithInfo(onframe func(frame *mpeg2.PSFrame)) {
+    unpacker.demuxer.OnFrameWithInfo = onframe
+}
+
+// the es frames of all the streams are delivered,timestamp is the dts in 90kHz,
+// lost is true if the frame is damaged
+func (unpacker *PsEsUnPacker) OnFrame(onframe ON_FRAME_FUNC) {
+    unpacker.OnEsFrameWithInfo(func(frame *mpeg2.PSFrame) {
+        onframe(frame.Data, uint32(frame.Dts*90), frame.Damaged)
+    })
+}
+
+// deliver the frames buffered by demuxer
+func (unpacker *PsEsUnPacker) Flush() {
+    unpacker.demuxer.Flush()
+}
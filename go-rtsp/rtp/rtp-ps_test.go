@@ -0,0 +1,157 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/yapingcat/gomedia/go-mpeg2"
+)
+
+func makePsTestH264Frame(idr bool, size int) []byte {
+	var frame []byte
+	if idr {
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x67, 0x42, 0x00, 0x1E)
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x68, 0xCE, 0x3C, 0x80)
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x65, 0x88)
+	} else {
+		frame = append(frame, 0x00, 0x00, 0x00, 0x01, 0x41, 0x9A)
+	}
+	return append(frame, bytes.Repeat([]byte{0x11}, size)...)
+}
+
+func makePsTestAACFrame(size int) []byte {
+	length := size + 7
+	frame := []byte{0xFF, 0xF1, 0x50, 0x80, byte(length >> 3), byte(length&0x07)<<5 | 0x1F, 0xFC}
+	return append(frame, bytes.Repeat([]byte{0x22}, size)...)
+}
+
+type psTestFrame struct {
+	cid  mpeg2.PS_STREAM_TYPE
+	data []byte
+	pts  uint64
+}
+
+func packPsTestFrames(t *testing.T, mode PS_RTP_MODE, frames []psTestFrame) [][]byte {
+	packer := NewPsEsPacker(96, 0x1234, 100, 500)
+	packer.SetMode(mode)
+	var packets [][]byte
+	packer.OnPacket(func(pkt []byte) error {
+		packets = append(packets, append([]byte{}, pkt...))
+		return nil
+	})
+	video := packer.AddStream(mpeg2.PS_STREAM_H264)
+	audio := packer.AddStream(mpeg2.PS_STREAM_AAC)
+	for _, f := range frames {
+		sid := video
+		if f.cid == mpeg2.PS_STREAM_AAC {
+			sid = audio
+		}
+		if err := packer.WriteFrame(sid, f.data, f.pts, f.pts); err != nil {
+			t.Fatalf("PsEsPacker.WriteFrame() error = %v", err)
+		}
+	}
+	return packets
+}
+
+func TestPsEsUnPacker(t *testing.T) {
+	frames := []psTestFrame{
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(true, 3000), pts: 0},
+		{cid: mpeg2.PS_STREAM_AAC, data: makePsTestAACFrame(300), pts: 10},
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(false, 2000), pts: 40},
+		{cid: mpeg2.PS_STREAM_AAC, data: makePsTestAACFrame(200), pts: 50},
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(false, 100), pts: 80},
+	}
+	for _, mode := range []PS_RTP_MODE{PS_RTP_GB28181, PS_RTP_RFC2250} {
+		packets := packPsTestFrames(t, mode, frames)
+		if len(packets) <= len(frames) {
+			t.Fatalf("mode %d:the frames are not fragmented,%d packets", mode, len(packets))
+		}
+		var marker int
+		for _, pkt := range packets {
+			var pkg RtpPacket
+			if err := pkg.Decode(pkt); err != nil {
+				t.Fatal(err)
+			}
+			if len(pkt) > 500 || pkg.Header.PayloadType != 96 {
+				t.Fatalf("mode %d:packet size %d,payload type %d", mode, len(pkt), pkg.Header.PayloadType)
+			}
+			marker += int(pkg.Header.Marker)
+		}
+		if mode == PS_RTP_GB28181 && marker != len(frames) {
+			t.Errorf("%d packets have M bit, want %d", marker, len(frames))
+		}
+		if mode == PS_RTP_RFC2250 && marker != 0 {
+			t.Errorf("rfc2250 packets have M bit")
+		}
+
+		var got []*mpeg2.PSFrame
+		unpacker := NewPsEsUnPacker()
+		unpacker.SetMode(mode)
+		unpacker.OnEsFrameWithInfo(func(frame *mpeg2.PSFrame) {
+			f := *frame
+			f.Data = append([]byte{}, frame.Data...)
+			got = append(got, &f)
+		})
+		for _, pkt := range packets {
+			if err := unpacker.UnPack(pkt); err != nil {
+				t.Fatalf("PsEsUnPacker.UnPack() error = %v", err)
+			}
+		}
+		unpacker.PsUnPacker.flush()
+		unpacker.Flush()
+		if len(got) != len(frames) {
+			t.Fatalf("mode %d:got %d frames, want %d", mode, len(got), len(frames))
+		}
+		for _, want := range frames {
+			found := false
+			for _, f := range got {
+				if f.Cid == want.cid && f.Pts == want.pts {
+					found = true
+					if !bytes.Equal(f.Data, want.data) || f.Damaged {
+						t.Errorf("mode %d:frame %d of stream %d is damaged", mode, want.pts, want.cid)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("mode %d:frame %d of stream %d is not found", mode, want.pts, want.cid)
+			}
+		}
+	}
+}
+
+func TestPsEsUnPacker_Lost(t *testing.T) {
+	frames := []psTestFrame{
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(true, 1000), pts: 0},
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(false, 1000), pts: 40},
+		{cid: mpeg2.PS_STREAM_H264, data: makePsTestH264Frame(false, 1000), pts: 80},
+	}
+	packets := packPsTestFrames(t, PS_RTP_GB28181, frames)
+	var got [][]byte
+	var timestamps []uint32
+	unpacker := NewPsEsUnPacker()
+	unpacker.OnFrame(func(frame []byte, timestamp uint32, lost bool) {
+		if lost {
+			t.Errorf("frame %d is damaged", timestamp)
+		}
+		got = append(got, append([]byte{}, frame...))
+		timestamps = append(timestamps, timestamp)
+	})
+	//the first packet of the second frame is lost
+	lost := -1
+	for i, pkt := range packets {
+		var pkg RtpPacket
+		pkg.Decode(pkt)
+		if pkg.Header.Timestamp == 40*90 && lost < 0 {
+			lost = i
+			continue
+		}
+		unpacker.UnPack(pkt)
+	}
+	unpacker.Flush()
+	if len(got) != 2 || !bytes.Equal(got[0], frames[0].data) || !bytes.Equal(got[1], frames[2].data) {
+		t.Fatalf("got %d frames,timestamps %v, want the first and the last", len(got), timestamps)
+	}
+	if timestamps[0] != 0 || timestamps[1] != 80*90 {
+		t.Errorf("timestamps %v, want [0 7200]", timestamps)
+	}
+}
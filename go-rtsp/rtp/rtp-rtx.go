@@ -0,0 +1,99 @@
+package rtp
+
+import (
+    "encoding/binary"
+    "errors"
+)
+
+const DEFAULT_RETRANSMIT_PACKETS = 512
+
+// RetransmitBuffer keeps the recently sent rtp packets for NACK,
+// the packets are indexed by sequence number in a ring
+type RetransmitBuffer struct {
+    packets [][]byte
+    seqs    []uint16
+}
+
+func NewRetransmitBuffer(size int) *RetransmitBuffer {
+    if size <= 0 {
+        size = DEFAULT_RETRANSMIT_PACKETS
+    }
+    return &RetransmitBuffer{
+        packets: make([][]byte, size),
+        seqs:    make([]uint16, size),
+    }
+}
+
+// the packet is copied
+func (buf *RetransmitBuffer) Push(seq uint16, pkt []byte) {
+    idx := int(seq) % len(buf.packets)
+    buf.packets[idx] = append(buf.packets[idx][:0], pkt...)
+    buf.seqs[idx] = seq
+}
+
+// nil if the packet is too old
+func (buf *RetransmitBuffer) Get(seq uint16) []byte {
+    idx := int(seq) % len(buf.packets)
+    if buf.packets[idx] == nil || buf.seqs[idx] != seq {
+        return nil
+    }
+    return buf.packets[idx]
+}
+
+// rfc4588 4. RTP Payload Format
+//
+//  0                   1                   2                   3
+//  0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1 2 3 4 5 6 7 8 9 0 1
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |                         RTP Header                            |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+// |            OSN                |                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+                               |
+// |                  Original RTP Packet Payload                  |
+// |                                                               |
+// +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
+
+// RtxPacker wraps the original packets into the retransmission stream,
+// which has its own payload type,ssrc and sequence number
+type RtxPacker struct {
+    pt       uint8
+    ssrc     uint32
+    sequence uint16
+}
+
+func NewRtxPacker(pt uint8, ssrc uint32, sequence uint16) *RtxPacker {
+    return &RtxPacker{pt: pt, ssrc: ssrc, sequence: sequence}
+}
+
+func (packer *RtxPacker) Pack(original []byte) ([]byte, error) {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(original); err != nil {
+        return nil, err
+    }
+    osn := pkg.Header.SequenceNumber
+    pkg.Header.PayloadType = packer.pt
+    pkg.Header.SSRC = packer.ssrc
+    pkg.Header.SequenceNumber = packer.sequence
+    packer.sequence++
+    payload := make([]byte, 2+len(pkg.Payload))
+    binary.BigEndian.PutUint16(payload, osn)
+    copy(payload[2:], pkg.Payload)
+    pkg.Payload = payload
+    return pkg.Encode(), nil
+}
+
+// restore the original packet from the retransmission packet
+func UnpackRtx(rtx []byte, pt uint8, ssrc uint32) ([]byte, error) {
+    pkg := &RtpPacket{}
+    if err := pkg.Decode(rtx); err != nil {
+        return nil, err
+    }
+    if len(pkg.Payload) < 2 {
+        return nil, errors.New("rtx packet need original sequence number")
+    }
+    pkg.Header.SequenceNumber = binary.BigEndian.Uint16(pkg.Payload)
+    pkg.Header.PayloadType = pt
+    pkg.Header.SSRC = ssrc
+    pkg.Payload = pkg.Payload[2:]
+    return pkg.Encode(), nil
+}
@@ -0,0 +1,68 @@
+package rtp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRtxPacker(t *testing.T) {
+	original := RtpPacket{Payload: []byte{0x01, 0x02, 0x03}}
+	original.Header.PayloadType = 96
+	original.Header.SSRC = 0x11111111
+	original.Header.SequenceNumber = 65535
+	original.Header.Timestamp = 3000
+	original.Header.Marker = 1
+	data := original.Encode()
+
+	packer := NewRtxPacker(97, 0x22222222, 65535)
+	for i, wantSeq := range []uint16{65535, 0} {
+		rtx, err := packer.Pack(data)
+		if err != nil {
+			t.Fatalf("RtxPacker.Pack() error = %v", err)
+		}
+		pkg := RtpPacket{}
+		if err := pkg.Decode(rtx); err != nil {
+			t.Fatalf("RtpPacket.Decode() error = %v", err)
+		}
+		if pkg.Header.PayloadType != 97 || pkg.Header.SSRC != 0x22222222 || pkg.Header.SequenceNumber != wantSeq {
+			t.Errorf("rtx %d header = %+v", i, pkg.Header)
+		}
+		if pkg.Header.Timestamp != 3000 || pkg.Header.Marker != 1 {
+			t.Errorf("rtx %d timestamp/marker = %d/%d", i, pkg.Header.Timestamp, pkg.Header.Marker)
+		}
+		if !bytes.Equal(pkg.Payload, []byte{0xFF, 0xFF, 0x01, 0x02, 0x03}) {
+			t.Errorf("rtx %d payload = %x", i, pkg.Payload)
+		}
+		restored, err := UnpackRtx(rtx, 96, 0x11111111)
+		if err != nil {
+			t.Fatalf("UnpackRtx() error = %v", err)
+		}
+		if !bytes.Equal(restored, data) {
+			t.Errorf("UnpackRtx() = %x, want %x", restored, data)
+		}
+	}
+
+	short := RtpPacket{Payload: []byte{0x01}}
+	if _, err := UnpackRtx(short.Encode(), 96, 0x11111111); err == nil {
+		t.Errorf("UnpackRtx() without osn error = nil, want error")
+	}
+}
+
+func TestRetransmitBuffer(t *testing.T) {
+	buf := NewRetransmitBuffer(4)
+	for seq := uint16(65534); seq != 4; seq++ {
+		buf.Push(seq, []byte{byte(seq)})
+	}
+	for seq := uint16(0); seq < 4; seq++ {
+		if got := buf.Get(seq); !bytes.Equal(got, []byte{byte(seq)}) {
+			t.Errorf("Get(%d) = %x", seq, got)
+		}
+	}
+	//overwritten in the ring
+	if got := buf.Get(65534); got != nil {
+		t.Errorf("Get(65534) = %x, want nil", got)
+	}
+	if got := buf.Get(100); got != nil {
+		t.Errorf("Get(100) = %x, want nil", got)
+	}
+}
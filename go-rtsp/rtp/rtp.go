@@ -30,6 +30,9 @@ func (pack *CommPacker) SetMtu(mtu int) {
     pack.mtu = mtu
 }
 
+// the hook is called before the packet is encoded,
+// so the header extensions can be set in it(e.g. RtpPacket.SetAbsSendTime),
+// the extensions are not counted in mtu
 func (pack *CommPacker) HookRtp(cb RTP_HOOK_FUNC) {
     pack.onRtp = cb
 }
@@ -92,6 +95,8 @@ func (pkg *RtpPacket) Decode(data []byte) error {
 func (pkg *RtpPacket) Encode() []byte {
     if len(pkg.Extensions) > 0 {
         pkg.Header.ExtensionFlag = 1
+    } else {
+        pkg.Header.ExtensionFlag = 0
     }
     if len(pkg.Padding) > 0 {
         pkg.Header.PaddingFlag = 1
@@ -181,3 +181,34 @@ func (digest *digestAuth) check(authorization string) bool {
         return false
     }
 }
+
+// DigestAuth exports the digest logic for other digest based protocols,e.g. SIP REGISTER of GB28181
+type DigestAuth struct {
+    digest digestAuth
+}
+
+func NewDigestAuth(userName, passwd, realm string) *DigestAuth {
+    auth := &DigestAuth{}
+    auth.digest.setUserInfo(userName, passwd)
+    auth.digest.setRealm(realm)
+    return auth
+}
+
+// server side,WWW-Authenticate with a new nonce
+func (auth *DigestAuth) Challenge() string {
+    return auth.digest.wwwAuthenticate()
+}
+
+// server side,check Authorization against the last nonce
+func (auth *DigestAuth) Check(authorization string, method string) bool {
+    auth.digest.setMethod(method)
+    return auth.digest.check(authorization)
+}
+
+// client side,answer WWW-Authenticate from server
+func (auth *DigestAuth) Authorization(wwwAuthenticate string, method string, uri string) string {
+    auth.digest.decode(wwwAuthenticate)
+    auth.digest.setMethod(method)
+    auth.digest.setUri(uri)
+    return auth.digest.authenticateInfo()
+}
@@ -5,6 +5,7 @@ import (
     "encoding/binary"
     "errors"
     "fmt"
+    "math/rand"
     "net/url"
     "strconv"
     "strings"
@@ -12,6 +13,7 @@ import (
     "time"
 
     "github.com/yapingcat/gomedia/go-rtsp/sdp"
+    "github.com/yapingcat/gomedia/go-rtsp/srtp"
 )
 
 // The client can assume the following states:
@@ -70,12 +72,23 @@ type RtspClient struct {
     state            int
     sdpContext       *sdp.Sdp
     setupStep        int
+    capabilities     []sdp.Capability
+    trackMedias      map[string]int //the index of the media which the track is set up by
     handle           ClientHandle
     sessionId        string
     timeout          int
     scale            float32
     speed            float32
-    timeRange        RangeTime
+    timeRange        *RangeTime
+    onvifReplay      bool
+    backchannel      bool
+    noRateControl    bool
+    multicast        bool
+    version          int
+    seekStyle        string
+    mediaProps       *MediaProps
+    acceptRanges     []string
+    pipelined        string
 }
 
 type ClientOption func(cli *RtspClient)
@@ -86,19 +99,66 @@ func WithEnableRecord() ClientOption {
     }
 }
 
+// onvif replay,Require: onvif-replay is sent with DESCRIBE/SETUP/PLAY,
+// the absolute time range is set by SetRange(NewClockRange(begin, end)),
+// the replay extension of each frame is RtspSample.Replay
+func WithOnvifReplay() ClientOption {
+    return func(cli *RtspClient) {
+        cli.onvifReplay = true
+    }
+}
+
+// onvif audio backchannel,the sendonly audio media is set up as the track BACKCHANNEL_TRACK,
+// the audio is sent to the server by RtspTrack.WriteSample
+func WithOnvifBackchannel() ClientOption {
+    return func(cli *RtspClient) {
+        cli.backchannel = true
+    }
+}
+
+// the tracks without transport are set up by multicast,
+// the group to join is RtspTrack.GetTransport().MulticastGroup() in HandleSetup
+func WithMulticast() ClientOption {
+    return func(cli *RtspClient) {
+        cli.multicast = true
+    }
+}
+
+// RTSP/2.0(rfc7826) is tried first,the client falls back to RTSP/1.0 if the server does not support it,
+// the record mode is always RTSP/1.0
+func WithRtsp2() ClientOption {
+    return func(cli *RtspClient) {
+        cli.version = RTSP_2_0
+    }
+}
+
+// the codecs of DESCRIBE are negotiated by the capabilities(rfc3264),
+// the track is created by the most preferred codec of the media,
+// only one media is set up if there are several medias of the same type
+func WithCapabilities(caps ...sdp.Capability) ClientOption {
+    return func(cli *RtspClient) {
+        cli.capabilities = caps
+    }
+}
+
 func NewRtspClient(uri string, handle ClientHandle, opt ...ClientOption) (*RtspClient, error) {
     cli := &RtspClient{
         cseq:             1,
+        version:          RTSP_1_0,
         state:            STATE_Init,
         serverCapability: []string{OPTIONS, DESCRIBE, SETUP, PLAY, TEARDOWN, ANNOUNCE, RECORD, PAUSE, SET_PARAMETER, GET_PARAMETER, REDIRECT},
         setupStep:        0,
         handle:           handle,
-        sdpContext:       &sdp.Sdp{},
+        sdpContext:       sdp.NewSdp(),
         tracks:           make(map[string]*RtspTrack),
+        trackMedias:      make(map[string]int),
     }
     for _, o := range opt {
         o(cli)
     }
+    if cli.isRecord {
+        cli.version = RTSP_1_0
+    }
     u, err := url.Parse(uri)
     if err != nil {
         return nil, err
@@ -111,8 +171,7 @@ func NewRtspClient(uri string, handle ClientHandle, opt ...ClientOption) (*RtspC
     }
     u.User = nil
     cli.uri = u.String()
-    cli.sdpContext.Attrs = make(map[string]string)
-    cli.sdpContext.Attrs["control"] = "*"
+    cli.sdpContext.Attributes.Set("control", "*")
     return cli, nil
 }
 
@@ -120,7 +179,9 @@ func (client *RtspClient) AddTrack(track *RtspTrack) {
     track.uri = fmt.Sprintf("track%d", len(client.tracks))
     track.OpenTrack()
     client.tracks[track.TrackName] = track
-    client.sdpContext.ParserSdp(track.mediaDescripe())
+    media := &sdp.Media{}
+    media.Decode(track.mediaDescripe())
+    client.sdpContext.Medias = append(client.sdpContext.Medias, media)
 }
 
 func (client *RtspClient) GetTrack(trackName string) (track *RtspTrack, found bool) {
@@ -142,6 +203,9 @@ func (client *RtspClient) SetSessionDescribe(sdp *sdp.Sdp) {
 
 func (client *RtspClient) Start() error {
     req := makeOptions(client.uri, client.cseq)
+    if client.version == RTSP_2_0 {
+        req.Fileds[Supported] = FEATURE_PLAY_BASIC
+    }
     client.reponseHandler = client.handleOption
     return client.sendRtspRequest(&req)
 }
@@ -190,15 +254,33 @@ func (client *RtspClient) Input(data []byte) (err error) {
 }
 
 func (client *RtspClient) TearDown() (err error) {
-    return nil
+    req := makeTeardown(client.sdpContext.ControlUrl, client.cseq)
+    client.reponseHandler = client.handleTeardown
+    return client.sendRtspRequest(&req)
 }
 
 func (client *RtspClient) Pause() (err error) {
-    return nil
+    if client.state != STATE_Playing && client.state != STATE_Recording {
+        return errors.New("pause in state of not playing or recording")
+    }
+    req := makePause(client.sdpContext.ControlUrl, client.cseq)
+    client.reponseHandler = client.handlePause
+    return client.sendRtspRequest(&req)
 }
 
+// Deprecated: the error is dropped,use Resume
 func (client *RtspClient) Play() {
+    client.Resume()
+}
 
+// PLAY again with the current range/scale/speed,e.g. seek of onvif replay after PAUSE
+func (client *RtspClient) Resume() error {
+    if client.state == STATE_Init {
+        return errors.New("play before setup")
+    }
+    req := client.makePlay()
+    client.reponseHandler = client.handlePlay
+    return client.sendRtspRequest(&req)
 }
 
 func (client *RtspClient) SetSpeed(speed float32) {
@@ -210,7 +292,147 @@ func (client *RtspClient) SetScale(scale float32) {
 }
 
 func (client *RtspClient) SetRange(timeRange RangeTime) {
-    client.timeRange = timeRange
+    client.timeRange = &timeRange
+}
+
+// the server sends the stream as fast as possible if rate control is disabled(Rate-Control: no),
+// it is used for onvif replay
+func (client *RtspClient) SetRateControl(enable bool) {
+    client.noRateControl = !enable
+}
+
+// the Seek-Style of PLAY(rtsp 2.0),e.g. SEEK_STYLE_RAP
+func (client *RtspClient) SetSeekStyle(style string) {
+    client.seekStyle = style
+}
+
+// RTSP_2_0 or RTSP_1_0 after negotiation
+func (client *RtspClient) Version() int {
+    return client.version
+}
+
+// the Media-Properties of SETUP/PLAY response or PLAY_NOTIFY,nil if the server is not rtsp 2.0
+func (client *RtspClient) MediaProperties() *MediaProps {
+    return client.mediaProps
+}
+
+// the Accept-Ranges of SETUP response,e.g. npt,clock
+func (client *RtspClient) AcceptRanges() []string {
+    return client.acceptRanges
+}
+
+func (client *RtspClient) makePlay() RtspRequest {
+    req := makePlay(client.sdpContext.ControlUrl, client.cseq)
+    if client.timeRange != nil {
+        req.Fileds[Range] = client.timeRange.EncodeString()
+    }
+    if client.scale != 0 {
+        req.Fileds[Scale] = strconv.FormatFloat(float64(client.scale), 'f', -1, 32)
+    }
+    if client.speed != 0 {
+        req.Fileds[Speed] = strconv.FormatFloat(float64(client.speed), 'f', -1, 32)
+    }
+    if client.onvifReplay && client.noRateControl {
+        req.Fileds[RateControl] = "no"
+    }
+    if client.version == RTSP_2_0 && client.seekStyle != "" {
+        req.Fileds[SeekStyle] = client.seekStyle
+    }
+    return req
+}
+
+// the sendonly audio media is the backchannel if it is required
+func (client *RtspClient) trackKey(media *sdp.Media) string {
+    if client.backchannel && media.MediaType == "audio" {
+        if media.Direction() == sdp.SENDONLY {
+            return BACKCHANNEL_TRACK
+        }
+    }
+    return media.MediaType
+}
+
+// the track set up by the i-th media,
+// the other medias of the same track are not set up
+func (client *RtspClient) mediaTrack(i int) (*RtspTrack, bool) {
+    key := client.trackKey(client.sdpContext.Medias[i])
+    if owner, found := client.trackMedias[key]; found && owner != i {
+        return nil, false
+    }
+    track, found := client.tracks[key]
+    return track, found
+}
+
+// the group of c= and the port of m= are used if the server does not reply destination
+func (client *RtspClient) multicastFromSdp(media *sdp.Media, transport *RtspTransport) {
+    connection := media.ConnectionData
+    if connection.Address == "" {
+        connection = client.sdpContext.ConnectionData
+    }
+    if !connection.IsMulticast() {
+        return
+    }
+    transport.Destination = connection.Address
+    if transport.Ports[0] == 0 && media.Port != 0 {
+        transport.Ports[0] = media.Port
+        transport.Ports[1] = media.Port + 1
+    }
+    if transport.Ttl == 0 {
+        transport.Ttl = connection.Ttl
+    }
+}
+
+// the option tags of onvif are required in DESCRIBE/SETUP/PLAY
+func (client *RtspClient) addRequire(req *RtspRequest) {
+    switch req.Method {
+    case DESCRIBE, SETUP, PLAY:
+    default:
+        return
+    }
+    var tags []string
+    if client.onvifReplay {
+        tags = append(tags, REQUIRE_ONVIF_REPLAY)
+    }
+    if client.backchannel {
+        tags = append(tags, REQUIRE_ONVIF_BACKCHANNEL)
+    }
+    if len(tags) > 0 {
+        req.Fileds[Require] = strings.Join(tags, ", ")
+    }
+}
+
+// the Transport of SETUP,the key of the client is sent by KeyMgmt if the server announces its own key
+func (client *RtspClient) setTransport(req *RtspRequest, track *RtspTrack) {
+    track.transport.version = client.version
+    track.transport.Secure = track.IsSecure()
+    req.Fileds[Transport] = track.transport.EncodeString()
+    if track.remoteCrypto != nil && track.localCrypto != nil {
+        req.Fileds[KeyMgmt] = NewSdesKeyMgmt(req.Uri, track.localCrypto).EncodeString()
+    }
+}
+
+// the packets from the server are decrypted by the key of a=crypto,the client sends packets by its own key
+func (client *RtspClient) setupSrtp(track *RtspTrack, value string) error {
+    remote, err := srtp.ParseCryptoAttribute(value)
+    if err != nil {
+        return err
+    }
+    return track.SetRemoteCrypto(remote)
+}
+
+// the Media-Properties/Accept-Ranges of rtsp 2.0
+func (client *RtspClient) updateMediaProperties(fileds HeadFiled) {
+    if fileds.Has(MediaProperties) {
+        client.mediaProps = &MediaProps{}
+        client.mediaProps.Decode(fileds[MediaProperties])
+    }
+    if fileds.Has(AcceptRanges) {
+        client.acceptRanges = client.acceptRanges[:0]
+        for _, r := range strings.Split(fileds[AcceptRanges], ",") {
+            if r = strings.TrimSpace(r); r != "" {
+                client.acceptRanges = append(client.acceptRanges, r)
+            }
+        }
+    }
 }
 
 func (client *RtspClient) EnableRTCP() {
@@ -239,6 +461,8 @@ func (client *RtspClient) KeepAlive(method string) error {
 }
 
 func (client *RtspClient) sendRtspRequest(req *RtspRequest) error {
+    req.Version = client.version
+    client.addRequire(req)
     client.lastRequest = req
     atomic.AddInt32(&client.cseq, 1)
     if client.auth != nil {
@@ -248,6 +472,12 @@ func (client *RtspClient) sendRtspRequest(req *RtspRequest) error {
     }
     if client.sessionId != "" {
         req.Fileds[Session] = client.sessionId
+    } else if client.version == RTSP_2_0 && req.Method == SETUP {
+        //the requests of the same session before the session id is known
+        if client.pipelined == "" {
+            client.pipelined = strconv.FormatUint(uint64(rand.Uint32()), 10)
+        }
+        req.Fileds[PipelinedRequests] = client.pipelined
     }
     return client.sendToServer([]byte(req.Encode()))
 }
@@ -307,6 +537,15 @@ func (client *RtspClient) handleResponse(res []byte) (ret int, err error) {
     if err != nil {
         return
     }
+    //the server does not support RTSP/2.0,the request is sent again by RTSP/1.0
+    if client.version == RTSP_2_0 && (response.Version != RTSP_2_0 || response.StatusCode == Version_Not_Supported) {
+        client.version = RTSP_1_0
+        if response.StatusCode != OK {
+            delete(client.lastRequest.Fileds, Supported)
+            client.lastRequest.Version = RTSP_1_0
+            return ret, client.resendLastRequest()
+        }
+    }
     if response.StatusCode == 401 {
         return ret, client.handleUnAuth(response)
     }
@@ -314,7 +553,7 @@ func (client *RtspClient) handleResponse(res []byte) (ret int, err error) {
 }
 
 func (client *RtspClient) handleRequest(req []byte) (ret int, err error) {
-    request := RtspRequest{}
+    request := RtspRequest{Fileds: make(HeadFiled)}
     ret, err = request.parse(string(req))
     if err != nil {
         return
@@ -323,6 +562,8 @@ func (client *RtspClient) handleRequest(req []byte) (ret int, err error) {
     switch request.Method {
     case REDIRECT:
         return ret, client.handleRedirect(&request)
+    case PLAY_NOTIFY:
+        return ret, client.handlePlayNotify(&request)
     default:
         if client.handle != nil {
             return ret, client.handle.HandleRequest(client, request)
@@ -345,9 +586,13 @@ func (client *RtspClient) handleUnAuth(response RtspResponse) error {
     client.auth.setMethod(client.lastRequest.Method)
     client.auth.setUri(client.lastRequest.Uri)
     client.auth.decode(response.Fileds[WWWAuthenticate])
+    client.lastRequest.Fileds[Authorization] = client.auth.authenticateInfo()
+    return client.resendLastRequest()
+}
+
+func (client *RtspClient) resendLastRequest() error {
     client.lastRequest.Fileds.Add(CSeq, client.cseq)
     client.lastRequest.Fileds[Date] = time.Now().UTC().Format("02 Jan 06 15:04:05 GMT")
-    client.lastRequest.Fileds[Authorization] = client.auth.authenticateInfo()
     atomic.AddInt32(&client.cseq, 1)
     return client.sendToServer([]byte(client.lastRequest.Encode()))
 }
@@ -436,24 +681,48 @@ func (client *RtspClient) handleDescribe(res *RtspResponse) (err error) {
         return errors.New("unsupport empty aggregate control url in session level descriptions")
     }
     client.sdpContext.ControlUrl = getControlUrl(client.sdpContext.ControlUrl)
-    for _, media := range client.sdpContext.Medias {
-        fmtpHandle := sdp.CreateFmtpParamParser(media.EncodeName)
-        if fmtpHandle != nil {
-            fmtpHandle.Load(media.Attrs["fmtp"])
+    var negotiated []*sdp.NegotiatedMedia
+    if len(client.capabilities) > 0 {
+        answer, err := sdp.CreateAnswer(client.sdpContext, client.capabilities, sdp.WithLocalPreference())
+        if err != nil {
+            return err
+        }
+        if !hasAcceptedMedia(answer.Medias) {
+            return errors.New("no media is accepted")
+        }
+        negotiated = answer.Medias
+    }
+    for i, media := range client.sdpContext.Medias {
+        key := client.trackKey(media)
+        var opts []TrackOption
+        if key == BACKCHANNEL_TRACK {
+            opts = append(opts, WithBackchannel())
         }
         var track *RtspTrack = nil
-        if media.MediaType == "audio" {
-            track = NewAudioTrack(NewAudioCodec(media.EncodeName, uint8(media.PayloadType), uint32(media.ClockRate), media.ChannelCount), WithCodecParamHandler(fmtpHandle))
-        } else if media.MediaType == "video" {
-            track = NewVideoTrack(NewVideoCodec(media.EncodeName, uint8(media.PayloadType), uint32(media.ClockRate)), WithCodecParamHandler(fmtpHandle))
+        if negotiated != nil {
+            track = NewNegotiatedTrack(negotiated[i], opts...)
         } else {
-            track = NewMetaTrack(NewApplicatioCodec(media.EncodeName, uint8(media.PayloadType)))
+            track = newMediaTrack(media, resolvedRtpMap(media), opts...)
         }
         if track == nil {
+            //the track of unsupported codec is not setup
             continue
         }
+        if owner, found := client.trackMedias[key]; found {
+            //the media of more preferred codec replaces the previous one
+            if negotiated == nil || negotiated[i].Preference >= negotiated[owner].Preference {
+                continue
+            }
+        }
+        track.loadExtmap(client.sdpContext.Attributes.Extmaps(), media.Attributes.Extmaps())
+        if crypto, found := media.Attributes.Get("crypto"); found && strings.Contains(media.Proto, "SAVP") {
+            if err = client.setupSrtp(track, crypto); err != nil {
+                return err
+            }
+        }
         track.OpenTrack()
-        client.tracks[media.MediaType] = track
+        client.tracks[key] = track
+        client.trackMedias[key] = i
         media.ControlUrl = getControlUrl(media.ControlUrl)
     }
 
@@ -464,11 +733,14 @@ func (client *RtspClient) handleDescribe(res *RtspResponse) (err error) {
     }
     interleaved := 0
     for i := client.setupStep; i < len(client.sdpContext.Medias); i++ {
-        track, found := client.tracks[client.sdpContext.Medias[client.setupStep].MediaType]
+        track, found := client.mediaTrack(i)
         if !found || !track.isOpen {
             continue
         }
-        req := makeSetup(client.sdpContext.Medias[client.setupStep].ControlUrl, client.cseq)
+        req := makeSetup(client.sdpContext.Medias[i].ControlUrl, client.cseq)
+        if track.transport == nil && client.multicast && !client.isRecord {
+            track.transport = NewRtspTransport(WithEnableMulticast())
+        }
         if track.transport == nil {
             track.transport = NewRtspTransport(WithTcpInterleaved([2]int{interleaved, interleaved + 1}))
         }
@@ -476,7 +748,7 @@ func (client *RtspClient) handleDescribe(res *RtspResponse) (err error) {
             track.transport.Interleaved[0] = interleaved
             track.transport.Interleaved[1] = interleaved + 1
         }
-        req.Fileds[Transport] = track.transport.EncodeString()
+        client.setTransport(&req, track)
         client.setupStep = i + 1
         client.reponseHandler = client.handleSetup
         return client.sendRtspRequest(&req)
@@ -486,7 +758,7 @@ func (client *RtspClient) handleDescribe(res *RtspResponse) (err error) {
 
 func (client *RtspClient) handleSetup(res *RtspResponse) error {
 
-    lastTrack := client.tracks[client.sdpContext.Medias[client.setupStep-1].MediaType]
+    lastTrack, _ := client.mediaTrack(client.setupStep - 1)
     if res.StatusCode != 200 {
         if client.handle == nil {
             return nil
@@ -495,12 +767,12 @@ func (client *RtspClient) handleSetup(res *RtspResponse) error {
         err := client.handle.HandleSetup(client, *res, lastTrack, client.tracks, "", -1)
         if res.StatusCode == 461 {
             if lastTrack.transport.Proto != proto {
-                req := makeSetup(client.sdpContext.Medias[client.setupStep].ControlUrl, client.cseq)
+                req := makeSetup(client.sdpContext.Medias[client.setupStep-1].ControlUrl, client.cseq)
                 if lastTrack.transport.Proto == TCP && lastTrack.transport.Interleaved[0] == lastTrack.transport.Interleaved[1] {
-                    lastTrack.transport.Interleaved[0] = client.setupStep * 2
-                    lastTrack.transport.Interleaved[1] = client.setupStep*2 + 1
+                    lastTrack.transport.Interleaved[0] = (client.setupStep - 1) * 2
+                    lastTrack.transport.Interleaved[1] = (client.setupStep-1)*2 + 1
                 }
-                req.Fileds[Transport] = lastTrack.transport.EncodeString()
+                client.setTransport(&req, lastTrack)
                 return client.sendRtspRequest(&req)
             }
         }
@@ -529,7 +801,23 @@ func (client *RtspClient) handleSetup(res *RtspResponse) error {
             client.sessionId = sessionId
             client.timeout = timeout
         }
+        client.updateMediaProperties(res.Fileds)
+        if res.Fileds.Has(KeyMgmt) && lastTrack.localCrypto != nil && lastTrack.remoteCrypto == nil {
+            //the rtcp of the server in record mode is protected by its own key
+            keyMgmt := &KeyMgmtInfo{}
+            keyMgmt.Decode(res.Fileds[KeyMgmt])
+            crypto, err := keyMgmt.Crypto()
+            if err == nil {
+                err = lastTrack.SetRemoteCrypto(crypto)
+            }
+            if err != nil {
+                return err
+            }
+        }
         lastTrack.transport.DecodeString(res.Fileds[Transport])
+        if lastTrack.transport.IsMultiCast && lastTrack.transport.Destination == "" {
+            client.multicastFromSdp(client.sdpContext.Medias[client.setupStep-1], lastTrack.transport)
+        }
         if err := client.handle.HandleSetup(client, *res, lastTrack, client.tracks, client.sessionId, client.timeout); err != nil {
             return err
         }
@@ -552,11 +840,14 @@ func (client *RtspClient) handleSetup(res *RtspResponse) error {
     }
 
     for i := client.setupStep; i < len(client.sdpContext.Medias); i++ {
-        track, found := client.tracks[client.sdpContext.Medias[client.setupStep].MediaType]
+        track, found := client.mediaTrack(i)
         if !found || !track.isOpen {
             continue
         }
-        req := makeSetup(client.sdpContext.Medias[client.setupStep].ControlUrl, client.cseq)
+        req := makeSetup(client.sdpContext.Medias[i].ControlUrl, client.cseq)
+        if track.transport == nil && client.multicast && !client.isRecord {
+            track.transport = NewRtspTransport(WithEnableMulticast())
+        }
         if track.transport == nil {
             track.transport = NewRtspTransport(WithTcpInterleaved([2]int{lastTrack.transport.Interleaved[0] + 2, lastTrack.transport.Interleaved[0] + 3}))
         }
@@ -566,11 +857,11 @@ func (client *RtspClient) handleSetup(res *RtspResponse) error {
             track.transport.mode = PLAY
         }
         if track.transport.Proto == TCP && lastTrack.transport.Interleaved[0] == lastTrack.transport.Interleaved[1] {
-            track.transport.Interleaved[0] = client.setupStep * 2
-            track.transport.Interleaved[1] = client.setupStep*2 + 1
+            track.transport.Interleaved[0] = i * 2
+            track.transport.Interleaved[1] = i*2 + 1
         }
         client.setupStep = i + 1
-        req.Fileds[Transport] = track.transport.EncodeString()
+        client.setTransport(&req, track)
         return client.sendRtspRequest(&req)
     }
 
@@ -580,7 +871,7 @@ func (client *RtspClient) handleSetup(res *RtspResponse) error {
         req = &recordReq
         client.reponseHandler = client.handleRecord
     } else {
-        playReq := makePlay(client.sdpContext.ControlUrl, client.cseq)
+        playReq := client.makePlay()
         req = &playReq
         client.reponseHandler = client.handlePlay
     }
@@ -597,6 +888,7 @@ func (client *RtspClient) handlePlay(res *RtspResponse) (err error) {
         }
     }
     client.state = STATE_Playing
+    client.updateMediaProperties(res.Fileds)
     var tr *RangeTime = nil
     var info *RtpInfo = nil
     if res.Fileds.Has(Range) {
@@ -617,6 +909,10 @@ func (client *RtspClient) handlePlay(res *RtspResponse) (err error) {
 }
 
 func (client *RtspClient) handleTeardown(res *RtspResponse) error {
+    if res.StatusCode == 200 {
+        client.state = STATE_Init
+        client.sessionId = ""
+    }
     if client.handle != nil {
         return client.handle.HandleTeardown(client, *res)
     }
@@ -624,6 +920,9 @@ func (client *RtspClient) handleTeardown(res *RtspResponse) error {
 }
 
 func (client *RtspClient) handlePause(res *RtspResponse) error {
+    if res.StatusCode == 200 {
+        client.state = STATE_Ready
+    }
     if client.handle != nil {
         return client.handle.HandlePause(client, *res)
     }
@@ -653,7 +952,7 @@ func (client *RtspClient) handleAnnounce(res *RtspResponse) error {
     if client.setupStep >= len(client.sdpContext.Medias) {
         return errors.New("need track")
     }
-    track := client.tracks[client.sdpContext.Medias[client.setupStep].MediaType]
+    track := client.tracks[client.trackKey(client.sdpContext.Medias[client.setupStep])]
     req := makeSetup(client.sdpContext.Medias[client.setupStep].ControlUrl, client.cseq)
     if track.transport == nil {
         track.transport = NewRtspTransport(WithTcpInterleaved([2]int{client.setupStep * 2, client.setupStep*2 + 1}), WithMode(RECORD))
@@ -664,7 +963,7 @@ func (client *RtspClient) handleAnnounce(res *RtspResponse) error {
         track.transport.Interleaved[1] = client.setupStep*2 + 1
     }
     client.setupStep++
-    req.Fileds[Transport] = track.transport.EncodeString()
+    client.setTransport(&req, track)
     client.reponseHandler = client.handleSetup
     return client.sendRtspRequest(&req)
 }
@@ -726,6 +1025,24 @@ func (client *RtspClient) handleSetParameter(res *RtspResponse) error {
     return nil
 }
 
+// PLAY_NOTIFY of rtsp 2.0,e.g. Notify-Reason: end-of-stream,it is passed to HandleRequest and replied with 200
+func (client *RtspClient) handlePlayNotify(req *RtspRequest) error {
+    if req.Fileds[NotifyReason] == NOTIFY_MEDIA_PROPERTIES_UPDATE {
+        client.updateMediaProperties(req.Fileds)
+    }
+    if client.handle != nil {
+        if err := client.handle.HandleRequest(client, *req); err != nil {
+            return err
+        }
+    }
+    res := RtspResponse{Version: req.Version, StatusCode: OK, Fileds: make(HeadFiled)}
+    res.Fileds[CSeq] = req.Fileds[CSeq]
+    if req.Fileds.Has(Session) {
+        res.Fileds[Session] = req.Fileds[Session]
+    }
+    return client.sendToServer([]byte(res.Encode()))
+}
+
 func (client *RtspClient) handleRedirect(req *RtspRequest) error {
     if !req.Fileds.Has(Location) {
         return errors.New("redirect request has Location Filed")
@@ -1,7 +1,11 @@
 package rtsp
 
 import (
+    "errors"
+    "strconv"
     "strings"
+
+    "github.com/yapingcat/gomedia/go-mpeg2"
 )
 
 type RTSP_CODEC_ID int
@@ -14,6 +18,14 @@ const (
     RTSP_CODEC_G711U
     RTSP_CODEC_PS
     RTSP_CODEC_TS
+    RTSP_CODEC_OPUS
+    RTSP_CODEC_MPA //mpeg audio(RFC2250),mp1/mp2/mp3
+    RTSP_CODEC_MP3 //mpa-robust(RFC5219)
+    RTSP_CODEC_G722
+    RTSP_CODEC_L16
+    RTSP_CODEC_L24
+    RTSP_CODEC_MJPEG //RFC2435,static payload type 26
+    RTSP_CODEC_AAC_LATM //MP4A-LATM(RFC3016/RFC6416)
 )
 
 type RtspCodec struct {
@@ -23,58 +35,147 @@ type RtspCodec struct {
     ChannelCount uint8
 }
 
-func GetCodecIdByEncodeName(name string) RTSP_CODEC_ID {
+// the track of unsupported codec should be skipped
+func GetCodecIdByEncodeName(name string) (RTSP_CODEC_ID, error) {
     lowName := strings.ToLower(name)
     switch lowName {
     case "h264":
-        return RTSP_CODEC_H264
+        return RTSP_CODEC_H264, nil
     case "h265":
-        return RTSP_CODEC_H265
-    case "mpeg4-generic", "mpeg4-latm":
-        return RTSP_CODEC_AAC
-    case "pcmu":
-        return RTSP_CODEC_G711A
+        return RTSP_CODEC_H265, nil
+    case "mpeg4-generic":
+        return RTSP_CODEC_AAC, nil
+    case "mp4a-latm", "mpeg4-latm":
+        return RTSP_CODEC_AAC_LATM, nil
     case "pcma":
-        return RTSP_CODEC_G711U
+        return RTSP_CODEC_G711A, nil
+    case "pcmu":
+        return RTSP_CODEC_G711U, nil
     case "mp2t":
-        return RTSP_CODEC_TS
+        return RTSP_CODEC_TS, nil
+    case "mp2p", "ps":
+        return RTSP_CODEC_PS, nil
+    case "opus":
+        return RTSP_CODEC_OPUS, nil
+    case "mpa":
+        return RTSP_CODEC_MPA, nil
+    case "mpa-robust":
+        return RTSP_CODEC_MP3, nil
+    case "g722":
+        return RTSP_CODEC_G722, nil
+    case "l16":
+        return RTSP_CODEC_L16, nil
+    case "l24":
+        return RTSP_CODEC_L24, nil
+    case "jpeg":
+        return RTSP_CODEC_MJPEG, nil
     }
-    panic("unsupport codec")
+    return 0, errors.New("unsupport codec " + name)
 }
 
-func GetEncodeNameByCodecId(cid RTSP_CODEC_ID) string {
+func GetEncodeNameByCodecId(cid RTSP_CODEC_ID) (string, error) {
     switch cid {
     case RTSP_CODEC_H264:
-        return "H264"
+        return "H264", nil
     case RTSP_CODEC_H265:
-        return "H265"
+        return "H265", nil
     case RTSP_CODEC_AAC:
-        return "mpeg4-generic"
+        return "mpeg4-generic", nil
     case RTSP_CODEC_G711A:
-        return "pcma"
+        return "pcma", nil
     case RTSP_CODEC_G711U:
-        return "pcmu"
+        return "pcmu", nil
     case RTSP_CODEC_PS:
-        return "MP2P"
+        return "MP2P", nil
     case RTSP_CODEC_TS:
-        return "MP2T"
+        return "MP2T", nil
+    case RTSP_CODEC_OPUS:
+        return "opus", nil
+    case RTSP_CODEC_MPA:
+        return "MPA", nil
+    case RTSP_CODEC_MP3:
+        return "mpa-robust", nil
+    case RTSP_CODEC_G722:
+        return "G722", nil
+    case RTSP_CODEC_L16:
+        return "L16", nil
+    case RTSP_CODEC_L24:
+        return "L24", nil
+    case RTSP_CODEC_MJPEG:
+        return "JPEG", nil
+    case RTSP_CODEC_AAC_LATM:
+        return "MP4A-LATM", nil
+    default:
+        return "", errors.New("unsupport rtsp codec id " + strconv.Itoa(int(cid)))
+    }
+}
+
+// the codec of es stream demuxed from MP2P
+func psStreamTypeToCodecId(cid mpeg2.PS_STREAM_TYPE) (RTSP_CODEC_ID, bool) {
+    switch cid {
+    case mpeg2.PS_STREAM_H264:
+        return RTSP_CODEC_H264, true
+    case mpeg2.PS_STREAM_H265:
+        return RTSP_CODEC_H265, true
+    case mpeg2.PS_STREAM_AAC:
+        return RTSP_CODEC_AAC, true
+    case mpeg2.PS_STREAM_G711A:
+        return RTSP_CODEC_G711A, true
+    case mpeg2.PS_STREAM_G711U:
+        return RTSP_CODEC_G711U, true
     default:
-        panic("unsupport rtsp codec id")
+        return 0, false
     }
 }
 
+func codecIdToPsStreamType(cid RTSP_CODEC_ID) (mpeg2.PS_STREAM_TYPE, bool) {
+    switch cid {
+    case RTSP_CODEC_H264:
+        return mpeg2.PS_STREAM_H264, true
+    case RTSP_CODEC_H265:
+        return mpeg2.PS_STREAM_H265, true
+    case RTSP_CODEC_AAC:
+        return mpeg2.PS_STREAM_AAC, true
+    case RTSP_CODEC_G711A:
+        return mpeg2.PS_STREAM_G711A, true
+    case RTSP_CODEC_G711U:
+        return mpeg2.PS_STREAM_G711U, true
+    default:
+        return 0, false
+    }
+}
+
+// IsSupportedCodec checks the encoding name of rtpmap before creating the codec
+func IsSupportedCodec(name string) bool {
+    _, err := GetCodecIdByEncodeName(name)
+    return err == nil
+}
+
+// the constructors panic if the encoding name is unsupported,
+// check the name of remote sdp by IsSupportedCodec first
 func NewCodec(name string, pt uint8, sampleRate uint32, channel uint8) RtspCodec {
-    return RtspCodec{Cid: GetCodecIdByEncodeName(name), PayloadType: pt, SampleRate: sampleRate, ChannelCount: channel}
+    return RtspCodec{Cid: mustGetCodecId(name), PayloadType: pt, SampleRate: sampleRate, ChannelCount: channel}
 }
 
+// NewVideoCodec panics if the codec is unsupported,see NewCodec
 func NewVideoCodec(name string, pt uint8, sampleRate uint32) RtspCodec {
-    return RtspCodec{Cid: GetCodecIdByEncodeName(name), PayloadType: pt, SampleRate: sampleRate}
+    return RtspCodec{Cid: mustGetCodecId(name), PayloadType: pt, SampleRate: sampleRate}
 }
 
+// NewAudioCodec panics if the codec is unsupported,see NewCodec
 func NewAudioCodec(name string, pt uint8, sampleRate uint32, channelCount int) RtspCodec {
-    return RtspCodec{Cid: GetCodecIdByEncodeName(name), PayloadType: pt, SampleRate: sampleRate, ChannelCount: uint8(channelCount)}
+    return RtspCodec{Cid: mustGetCodecId(name), PayloadType: pt, SampleRate: sampleRate, ChannelCount: uint8(channelCount)}
 }
 
+// NewApplicatioCodec panics if the codec is unsupported,see NewCodec
 func NewApplicatioCodec(name string, pt uint8) RtspCodec {
-    return RtspCodec{Cid: GetCodecIdByEncodeName(name), PayloadType: pt}
+    return RtspCodec{Cid: mustGetCodecId(name), PayloadType: pt}
+}
+
+func mustGetCodecId(name string) RTSP_CODEC_ID {
+    cid, err := GetCodecIdByEncodeName(name)
+    if err != nil {
+        panic(err)
+    }
+    return cid
 }
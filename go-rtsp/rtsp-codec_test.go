@@ -0,0 +1,48 @@
+package rtsp
+
+import "testing"
+
+func TestRtspCodec(t *testing.T) {
+	for _, name := range []string{"H264", "opus", "mpa-robust", "L24", "JPEG", "MP4A-LATM"} {
+		if !IsSupportedCodec(name) {
+			t.Errorf("IsSupportedCodec(%s) = false", name)
+		}
+		cid, _ := GetCodecIdByEncodeName(name)
+		if got, err := GetEncodeNameByCodecId(cid); err != nil || !IsSupportedCodec(got) {
+			t.Errorf("GetEncodeNameByCodecId(%d) = %s,%v", cid, got, err)
+		}
+	}
+	if IsSupportedCodec("VP8") {
+		t.Error("IsSupportedCodec(VP8) = true")
+	}
+	if _, err := GetEncodeNameByCodecId(RTSP_CODEC_ID(100)); err == nil {
+		t.Error("the unknown codec id has a name")
+	}
+}
+
+func TestGetCodecIdByEncodeName_G711(t *testing.T) {
+	//pcma is G.711 A-law and pcmu is G.711 mu-law(rfc3551 4.5.14)
+	for name, want := range map[string]RTSP_CODEC_ID{"PCMA": RTSP_CODEC_G711A, "pcma": RTSP_CODEC_G711A, "PCMU": RTSP_CODEC_G711U, "pcmu": RTSP_CODEC_G711U} {
+		if cid, err := GetCodecIdByEncodeName(name); err != nil || cid != want {
+			t.Errorf("GetCodecIdByEncodeName(%s) = %d,%v, want %d", name, cid, err, want)
+		}
+	}
+}
+
+func TestNewCodec_Unsupported(t *testing.T) {
+	for name, newCodec := range map[string]func(){
+		"NewCodec":           func() { NewCodec("VP8", 96, 90000, 0) },
+		"NewVideoCodec":      func() { NewVideoCodec("VP8", 96, 90000) },
+		"NewAudioCodec":      func() { NewAudioCodec("iLBC", 97, 8000, 1) },
+		"NewApplicatioCodec": func() { NewApplicatioCodec("vnd.onvif.metadata", 107) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s does not panic", name)
+				}
+			}()
+			newCodec()
+		}()
+	}
+}
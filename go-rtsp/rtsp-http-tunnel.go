@@ -0,0 +1,296 @@
+package rtsp
+
+import (
+    "bytes"
+    "encoding/base64"
+    "errors"
+    "math/rand"
+    "net/textproto"
+    "net/url"
+    "strconv"
+    "strings"
+    "sync"
+)
+
+// rtsp over http(apple quicktime tunnel)
+// the client opens two http connections with the same x-sessioncookie,
+// GET channel:  server -> client,rtsp responses and interleaved rtp/rtcp as they are
+// POST channel: client -> server,rtsp requests and interleaved rtp/rtcp in base64
+
+const (
+    HTTP_TUNNEL_CONTENT_TYPE = "application/x-rtsp-tunnelled"
+    XSessionCookie           = "X-Sessioncookie"
+)
+
+type HttpTunnelClient struct {
+    path        string
+    host        string
+    cookie      string
+    userAgent   string
+    getOutput   OutPutCallBack
+    postOutput  OutPutCallBack
+    onRtsp      func([]byte) error
+    mtx         sync.Mutex //Write and Input are called in different goroutines
+    established bool
+    cache       []byte
+    pending     []byte
+}
+
+type HttpTunnelClientOption func(tunnel *HttpTunnelClient)
+
+func WithTunnelUserAgent(userAgent string) HttpTunnelClientOption {
+    return func(tunnel *HttpTunnelClient) {
+        tunnel.userAgent = userAgent
+    }
+}
+
+// the same cookie is used to pair the GET and POST connection,it is random if not set
+func WithTunnelCookie(cookie string) HttpTunnelClientOption {
+    return func(tunnel *HttpTunnelClient) {
+        tunnel.cookie = cookie
+    }
+}
+
+// usage:
+//
+//	tunnel.SetOutput(getConn.Write, postConn.Write)
+//	tunnel.OnRtsp(client.Input)
+//	client.SetOutput(tunnel.Write)
+//	tunnel.Start()
+//	client.Start()
+//	the data read from getConn is passed to tunnel.Input
+func NewHttpTunnelClient(uri string, opt ...HttpTunnelClientOption) (*HttpTunnelClient, error) {
+    u, err := url.Parse(uri)
+    if err != nil {
+        return nil, err
+    }
+    tunnel := &HttpTunnelClient{
+        path:      u.RequestURI(),
+        host:      u.Host,
+        userAgent: "gomedia",
+    }
+    for _, o := range opt {
+        o(tunnel)
+    }
+    if tunnel.cookie == "" {
+        tunnel.cookie = makeSessionCookie()
+    }
+    return tunnel, nil
+}
+
+func (tunnel *HttpTunnelClient) Cookie() string {
+    return tunnel.cookie
+}
+
+func (tunnel *HttpTunnelClient) SetOutput(getChannel OutPutCallBack, postChannel OutPutCallBack) {
+    tunnel.getOutput = getChannel
+    tunnel.postOutput = postChannel
+}
+
+// the rtsp messages and interleaved packets received from GET channel
+func (tunnel *HttpTunnelClient) OnRtsp(onRtsp func([]byte) error) {
+    tunnel.onRtsp = onRtsp
+}
+
+// send GET,the POST is sent after the response of GET
+func (tunnel *HttpTunnelClient) Start() error {
+    req := "GET " + tunnel.path + " HTTP/1.0\r\n"
+    req += "Host: " + tunnel.host + "\r\n"
+    req += "User-Agent: " + tunnel.userAgent + "\r\n"
+    req += "x-sessioncookie: " + tunnel.cookie + "\r\n"
+    req += "Accept: " + HTTP_TUNNEL_CONTENT_TYPE + "\r\n"
+    req += "Pragma: no-cache\r\n"
+    req += "Cache-Control: no-cache\r\n\r\n"
+    return tunnel.getOutput([]byte(req))
+}
+
+func (tunnel *HttpTunnelClient) sendPost() error {
+    req := "POST " + tunnel.path + " HTTP/1.0\r\n"
+    req += "Host: " + tunnel.host + "\r\n"
+    req += "User-Agent: " + tunnel.userAgent + "\r\n"
+    req += "x-sessioncookie: " + tunnel.cookie + "\r\n"
+    req += "Content-Type: " + HTTP_TUNNEL_CONTENT_TYPE + "\r\n"
+    req += "Pragma: no-cache\r\n"
+    req += "Cache-Control: no-cache\r\n"
+    req += "Content-Length: 32767\r\n"
+    req += "Expires: Sun, 9 Jan 1972 00:00:00 GMT\r\n\r\n"
+    return tunnel.postOutput([]byte(req))
+}
+
+// Write sends the rtsp data by POST channel,it is cached until the tunnel is established
+func (tunnel *HttpTunnelClient) Write(data []byte) error {
+    tunnel.mtx.Lock()
+    defer tunnel.mtx.Unlock()
+    if !tunnel.established {
+        tunnel.pending = append(tunnel.pending, data...)
+        return nil
+    }
+    return tunnel.postOutput([]byte(base64.StdEncoding.EncodeToString(data)))
+}
+
+// Input handles the data from GET channel
+func (tunnel *HttpTunnelClient) Input(data []byte) error {
+    tunnel.mtx.Lock()
+    established := tunnel.established
+    tunnel.mtx.Unlock()
+    if established {
+        if tunnel.onRtsp != nil {
+            return tunnel.onRtsp(data)
+        }
+        return nil
+    }
+    tunnel.cache = append(tunnel.cache, data...)
+    loc := bytes.Index(tunnel.cache, []byte("\r\n\r\n"))
+    if loc == -1 {
+        return nil
+    }
+    sets := strings.SplitN(string(tunnel.cache[:bytes.IndexByte(tunnel.cache, '\r')]), " ", 3)
+    if len(sets) < 2 || !strings.HasPrefix(sets[0], "HTTP/") {
+        return errors.New("illegal http tunnel response")
+    }
+    if code, _ := strconv.Atoi(sets[1]); code != OK {
+        return errors.New("http tunnel failed,status code " + sets[1])
+    }
+    rest := tunnel.cache[loc+4:]
+    tunnel.cache = nil
+    if err := tunnel.establish(); err != nil {
+        return err
+    }
+    if len(rest) > 0 {
+        return tunnel.Input(rest)
+    }
+    return nil
+}
+
+// send POST and the rtsp data cached before
+func (tunnel *HttpTunnelClient) establish() error {
+    tunnel.mtx.Lock()
+    defer tunnel.mtx.Unlock()
+    tunnel.established = true
+    if err := tunnel.sendPost(); err != nil {
+        return err
+    }
+    if len(tunnel.pending) == 0 {
+        return nil
+    }
+    pending := tunnel.pending
+    tunnel.pending = nil
+    return tunnel.postOutput([]byte(base64.StdEncoding.EncodeToString(pending)))
+}
+
+// the GET or POST request to open a channel of tunnel
+type HttpTunnelRequest struct {
+    Method string
+    Uri    string
+    Cookie string
+    Fileds HeadFiled
+}
+
+// IsHttpTunnel checks whether the data received from a new connection is a http request
+func IsHttpTunnel(data []byte) bool {
+    return bytes.HasPrefix(data, []byte("GET ")) || bytes.HasPrefix(data, []byte("POST "))
+}
+
+// ParseHttpTunnelRequest returns the length of http header,ret is 0 if the header is not complete,
+// the data following the header of POST is the base64 rtsp
+func ParseHttpTunnelRequest(data []byte) (req *HttpTunnelRequest, ret int, err error) {
+    loc := bytes.Index(data, []byte("\r\n\r\n"))
+    if loc == -1 {
+        return nil, 0, nil
+    }
+    strs := strings.Split(string(data[:loc]), "\r\n")
+    sets := strings.Fields(strs[0])
+    if len(sets) < 3 || !strings.HasPrefix(sets[2], "HTTP/") {
+        return nil, 0, errors.New("illegal http tunnel request")
+    }
+    req = &HttpTunnelRequest{Method: sets[0], Uri: sets[1], Fileds: make(HeadFiled)}
+    for i := 1; i < len(strs); i++ {
+        kv := strings.SplitN(strs[i], ":", 2)
+        if len(kv) < 2 {
+            continue
+        }
+        req.Fileds[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+    }
+    req.Cookie = req.Fileds[XSessionCookie]
+    if req.Cookie == "" {
+        return nil, 0, errors.New("http tunnel request without x-sessioncookie")
+    }
+    if req.Method != "GET" && req.Method != "POST" {
+        return nil, 0, errors.New("unsupport http tunnel method " + req.Method)
+    }
+    return req, loc + 4, nil
+}
+
+// HttpTunnelServer decodes the POST channel of one tunnel,
+// the output of RtspServer is written to GET channel directly
+type HttpTunnelServer struct {
+    cookie string
+    onRtsp func([]byte) error
+    cache  []byte
+}
+
+func NewHttpTunnelServer(cookie string) *HttpTunnelServer {
+    return &HttpTunnelServer{cookie: cookie}
+}
+
+func (tunnel *HttpTunnelServer) Cookie() string {
+    return tunnel.cookie
+}
+
+// the decoded rtsp data,e.g. RtspServer.Input
+func (tunnel *HttpTunnelServer) OnRtsp(onRtsp func([]byte) error) {
+    tunnel.onRtsp = onRtsp
+}
+
+// Response is the reply of GET,the POST has no reply
+func (tunnel *HttpTunnelServer) Response() []byte {
+    res := "HTTP/1.0 200 OK\r\n"
+    res += "Server: gomedia\r\n"
+    res += "Connection: close\r\n"
+    res += "Cache-Control: no-store\r\n"
+    res += "Pragma: no-cache\r\n"
+    res += "Content-Type: " + HTTP_TUNNEL_CONTENT_TYPE + "\r\n\r\n"
+    return []byte(res)
+}
+
+// Input handles the body of POST channel,
+// every message may be encoded alone,so the base64 with padding in the middle is decoded by segments
+func (tunnel *HttpTunnelServer) Input(data []byte) error {
+    for _, c := range data {
+        if c != '\r' && c != '\n' && c != ' ' && c != '\t' {
+            tunnel.cache = append(tunnel.cache, c)
+        }
+    }
+    n := len(tunnel.cache) / 4 * 4
+    if n == 0 {
+        return nil
+    }
+    out := make([]byte, 0, n/4*3)
+    start := 0
+    for i := 0; i < n; i += 4 {
+        if tunnel.cache[i+3] != '=' && i+4 < n {
+            continue
+        }
+        seg := make([]byte, base64.StdEncoding.DecodedLen(i+4-start))
+        m, err := base64.StdEncoding.Decode(seg, tunnel.cache[start:i+4])
+        if err != nil {
+            return err
+        }
+        out = append(out, seg[:m]...)
+        start = i + 4
+    }
+    tunnel.cache = tunnel.cache[:copy(tunnel.cache, tunnel.cache[n:])]
+    if tunnel.onRtsp != nil {
+        return tunnel.onRtsp(out)
+    }
+    return nil
+}
+
+func makeSessionCookie() string {
+    letters := []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+    b := make([]byte, 22)
+    for i := range b {
+        b[i] = letters[rand.Intn(len(letters))]
+    }
+    return string(b)
+}
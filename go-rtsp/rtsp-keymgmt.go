@@ -0,0 +1,53 @@
+package rtsp
+
+import (
+    "errors"
+    "strings"
+
+    "github.com/yapingcat/gomedia/go-rtsp/srtp"
+)
+
+// KeyMgmt(rfc4567) without MIKEY,the a=crypto(rfc4568) of the sender is carried as it is,
+// the client sends its own srtp key to the server in SETUP:
+// KeyMgmt: prot=sdes; uri="rtsp://example.com/live/track0"; data="1 AES_CM_128_HMAC_SHA1_80 inline:..."
+const KEYMGMT_PROT_SDES = "sdes"
+
+type KeyMgmtInfo struct {
+    Prot string
+    Uri  string
+    Data string
+}
+
+func NewSdesKeyMgmt(uri string, crypto *srtp.CryptoAttribute) *KeyMgmtInfo {
+    return &KeyMgmtInfo{Prot: KEYMGMT_PROT_SDES, Uri: uri, Data: crypto.Encode()}
+}
+
+func (info *KeyMgmtInfo) EncodeString() string {
+    return "prot=" + info.Prot + "; uri=\"" + info.Uri + "\"; data=\"" + info.Data + "\""
+}
+
+func (info *KeyMgmtInfo) Decode(str string) {
+    for _, item := range splitQuoted(str, ';') {
+        kv := strings.SplitN(item, "=", 2)
+        if len(kv) < 2 {
+            continue
+        }
+        value := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+        switch strings.TrimSpace(kv[0]) {
+        case "prot":
+            info.Prot = value
+        case "uri":
+            info.Uri = value
+        case "data":
+            info.Data = value
+        }
+    }
+}
+
+// the a=crypto of sdes,mikey is not supported
+func (info *KeyMgmtInfo) Crypto() (*srtp.CryptoAttribute, error) {
+    if info.Prot != KEYMGMT_PROT_SDES {
+        return nil, errors.New("unsupport key management protocol " + info.Prot)
+    }
+    return srtp.ParseCryptoAttribute(info.Data)
+}
@@ -0,0 +1,117 @@
+package rtsp
+
+import (
+    "strconv"
+    "strings"
+)
+
+// properties of Media-Properties(rtsp 2.0)
+const (
+    PROP_RANDOM_ACCESS    = "Random-Access"
+    PROP_BEGINNING_ONLY   = "Beginning-Only"
+    PROP_NO_SEEKING       = "No-Seeking"
+    PROP_IMMUTABLE        = "Immutable"
+    PROP_DYNAMIC          = "Dynamic"
+    PROP_TIME_PROGRESSING = "Time-Progressing"
+    PROP_UNLIMITED        = "Unlimited"
+    PROP_TIME_LIMITED     = "Time-Limited"
+    PROP_TIME_DURATION    = "Time-Duration"
+    PROP_SCALES           = "Scales"
+)
+
+// Media-Properties: Random-Access=2.5, Unlimited, Immutable
+// Media-Properties: No-Seeking, Time-Progressing, Time-Duration=0.0
+// Media-Properties: Random-Access=2.5, Unlimited, Immutable, Scales="-20, -10, -4, 0.5, 1, 4, 10, 20"
+type MediaProps struct {
+    RandomAccess   string  //Random-Access,Beginning-Only or No-Seeking
+    AccessInterval float64 //the max seconds between random access points,0 means absent
+    Content        string  //Immutable,Dynamic or Time-Progressing
+    Retention      string  //Unlimited,Time-Limited or Time-Duration
+    RetentionValue string  //utc time of Time-Limited or seconds of Time-Duration
+    Scales         []string
+    Others         []string //unknown properties as they are
+}
+
+// the properties of a live stream
+func NewLiveMediaProps() *MediaProps {
+    return &MediaProps{
+        RandomAccess:   PROP_NO_SEEKING,
+        Content:        PROP_TIME_PROGRESSING,
+        Retention:      PROP_TIME_DURATION,
+        RetentionValue: "0.0",
+    }
+}
+
+func (props *MediaProps) Decode(str string) {
+    for _, item := range splitQuoted(str, ',') {
+        kv := strings.SplitN(item, "=", 2)
+        name := strings.TrimSpace(kv[0])
+        value := ""
+        if len(kv) > 1 {
+            value = strings.TrimSpace(kv[1])
+        }
+        switch name {
+        case PROP_RANDOM_ACCESS:
+            props.RandomAccess = name
+            props.AccessInterval, _ = strconv.ParseFloat(value, 64)
+        case PROP_BEGINNING_ONLY, PROP_NO_SEEKING:
+            props.RandomAccess = name
+        case PROP_IMMUTABLE, PROP_DYNAMIC, PROP_TIME_PROGRESSING:
+            props.Content = name
+        case PROP_UNLIMITED, PROP_TIME_LIMITED, PROP_TIME_DURATION:
+            props.Retention = name
+            props.RetentionValue = value
+        case PROP_SCALES:
+            props.Scales = props.Scales[:0]
+            for _, scale := range strings.Split(strings.Trim(value, "\""), ",") {
+                if scale = strings.TrimSpace(scale); scale != "" {
+                    props.Scales = append(props.Scales, scale)
+                }
+            }
+        case "":
+        default:
+            props.Others = append(props.Others, item)
+        }
+    }
+}
+
+func (props *MediaProps) EncodeString() string {
+    items := make([]string, 0, 5)
+    if props.RandomAccess == PROP_RANDOM_ACCESS && props.AccessInterval > 0 {
+        items = append(items, PROP_RANDOM_ACCESS+"="+strconv.FormatFloat(props.AccessInterval, 'f', -1, 64))
+    } else if props.RandomAccess != "" {
+        items = append(items, props.RandomAccess)
+    }
+    if props.Content != "" {
+        items = append(items, props.Content)
+    }
+    if props.Retention != "" && props.RetentionValue != "" {
+        items = append(items, props.Retention+"="+props.RetentionValue)
+    } else if props.Retention != "" {
+        items = append(items, props.Retention)
+    }
+    if len(props.Scales) > 0 {
+        items = append(items, PROP_SCALES+"=\""+strings.Join(props.Scales, ", ")+"\"")
+    }
+    items = append(items, props.Others...)
+    return strings.Join(items, ", ")
+}
+
+// split by sep outside the quoted string
+func splitQuoted(str string, sep byte) []string {
+    var items []string
+    quoted := false
+    start := 0
+    for i := 0; i < len(str); i++ {
+        switch str[i] {
+        case '"':
+            quoted = !quoted
+        case sep:
+            if !quoted {
+                items = append(items, strings.TrimSpace(str[start:i]))
+                start = i + 1
+            }
+        }
+    }
+    return append(items, strings.TrimSpace(str[start:]))
+}
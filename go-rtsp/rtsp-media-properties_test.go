@@ -0,0 +1,85 @@
+package rtsp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMediaProps(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		props MediaProps
+		want  string
+	}{
+		{
+			name:  "on demand",
+			str:   "Random-Access=2.5, Unlimited, Immutable",
+			props: MediaProps{RandomAccess: PROP_RANDOM_ACCESS, AccessInterval: 2.5, Content: PROP_IMMUTABLE, Retention: PROP_UNLIMITED},
+			want:  "Random-Access=2.5, Immutable, Unlimited",
+		},
+		{
+			name:  "live",
+			str:   "No-Seeking, Time-Progressing, Time-Duration=0.0",
+			props: *NewLiveMediaProps(),
+		},
+		{
+			name: "scales",
+			str:  `Random-Access=2.5, Unlimited, Immutable, Scales="-20, -10, -4, 0.5, 1, 4, 10, 20"`,
+			props: MediaProps{RandomAccess: PROP_RANDOM_ACCESS, AccessInterval: 2.5, Content: PROP_IMMUTABLE, Retention: PROP_UNLIMITED,
+				Scales: []string{"-20", "-10", "-4", "0.5", "1", "4", "10", "20"}},
+			want: `Random-Access=2.5, Immutable, Unlimited, Scales="-20, -10, -4, 0.5, 1, 4, 10, 20"`,
+		},
+		{
+			name:  "time limited",
+			str:   "Beginning-Only, Dynamic, Time-Limited=20081128T165900.000Z",
+			props: MediaProps{RandomAccess: PROP_BEGINNING_ONLY, Content: PROP_DYNAMIC, Retention: PROP_TIME_LIMITED, RetentionValue: "20081128T165900.000Z"},
+		},
+		{
+			name:  "unknown property",
+			str:   "Random-Access, Immutable, Unlimited, X-Vendor=1",
+			props: MediaProps{RandomAccess: PROP_RANDOM_ACCESS, Content: PROP_IMMUTABLE, Retention: PROP_UNLIMITED, Others: []string{"X-Vendor=1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := MediaProps{}
+			props.Decode(tt.str)
+			if !reflect.DeepEqual(props, tt.props) {
+				t.Errorf("Decode(%s) = %+v, want %+v", tt.str, props, tt.props)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.str
+			}
+			if got := props.EncodeString(); got != want {
+				t.Errorf("EncodeString() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSeekStyle(t *testing.T) {
+	for _, style := range []string{SEEK_STYLE_RAP, SEEK_STYLE_CORAP, SEEK_STYLE_FIRST_PRIOR, SEEK_STYLE_NEXT} {
+		t.Run(style, func(t *testing.T) {
+			s := newOnvifTestSession(t, []ClientOption{WithRtsp2()}, nil, newOnvifTestVideoTrack(t))
+			s.client.SetSeekStyle(style)
+			if err := s.client.Start(); err != nil {
+				t.Fatal(err)
+			}
+			s.pump(t)
+			if s.client.Version() != RTSP_2_0 {
+				t.Fatalf("Version() = %d", s.client.Version())
+			}
+			//the server replies the seek style requested
+			last := s.responses[len(s.responses)-1]
+			if !strings.Contains(last, "\r\nSeek-Style: "+style+"\r\n") {
+				t.Errorf("PLAY response = %q", last)
+			}
+			if props := s.client.MediaProperties(); props == nil || props.RandomAccess != PROP_NO_SEEKING {
+				t.Errorf("MediaProperties() = %+v", props)
+			}
+		})
+	}
+}
@@ -25,6 +25,7 @@ const (
 // SETUP             C->S             S          required
 // SET_PARAMETER     C->S, S->C       P,S        optional
 // TEARDOWN          C->S             P,S        required
+// PLAY_NOTIFY       S->C             S          required(rtsp 2.0)
 const (
     OPTIONS       = "OPTIONS"
     DESCRIBE      = "DESCRIBE"
@@ -37,6 +38,7 @@ const (
     RECORD        = "RECORD"
     REDIRECT      = "REDIRECT"
     TEARDOWN      = "TEARDOWN"
+    PLAY_NOTIFY   = "PLAY_NOTIFY"
 )
 
 func hasPlayAbility(capset []string) bool {
@@ -111,7 +113,7 @@ const (
     Referer           = "Referer"
     Require           = "Require"
     RetryAfter        = "RetryAfter"
-    RTPInfo           = "RTPInfo"
+    RTPInfo           = "RTP-Info" //RFC2326 12.33,not "RTPInfo"
     Scale             = "Scale"
     Session           = "Session"
     Server            = "Server"
@@ -122,6 +124,43 @@ const (
     Via               = "Via"
     WWWAuthenticate   = "WWW-Authenticate"
     Location          = "Location"
+    RateControl       = "Rate-Control"
+    Immediate         = "Immediate"
+    MediaProperties   = "Media-Properties"
+    AcceptRanges      = "Accept-Ranges"
+    PipelinedRequests = "Pipelined-Requests"
+    SeekStyle         = "Seek-Style"
+    NotifyReason      = "Notify-Reason"
+    Supported         = "Supported"
+    KeyMgmt           = "KeyMgmt"
+)
+
+// Seek-Style of rtsp 2.0
+const (
+    SEEK_STYLE_RAP         = "RAP"
+    SEEK_STYLE_CORAP       = "CoRAP"
+    SEEK_STYLE_FIRST_PRIOR = "First-Prior"
+    SEEK_STYLE_NEXT        = "Next"
+)
+
+// Notify-Reason of PLAY_NOTIFY
+const (
+    NOTIFY_END_OF_STREAM           = "end-of-stream"
+    NOTIFY_MEDIA_PROPERTIES_UPDATE = "media-properties-update"
+    NOTIFY_SCALE_CHANGE            = "scale-change"
+)
+
+// feature tags of Supported
+const (
+    FEATURE_PLAY_BASIC = "play.basic"
+    FEATURE_PLAY_SCALE = "play.scale"
+    FEATURE_PLAY_SPEED = "play.speed"
+)
+
+// option tags of Require
+const (
+    REQUIRE_ONVIF_REPLAY      = "onvif-replay"
+    REQUIRE_ONVIF_BACKCHANNEL = "www.onvif.org/ver20/backchannel"
 )
 
 const (
@@ -131,11 +170,14 @@ const (
     BAD_REQUEST           = 400
     Unauthorized          = 401
     Not_Found             = 404
+    Unsupported_Media     = 415
     Session_Not_Found     = 454
     Unsupported_Transport = 461
+    Key_Management_Error  = 463
     Internal_Server_Error = 500
     Not_Implemented       = 501
     Version_Not_Supported = 505
+    Option_Not_Supported  = 551
 )
 
 var errNeedMore error = errors.New("need more")
@@ -233,6 +275,17 @@ func (req *RtspRequest) Encode() string {
     return request
 }
 
+// the option tags of Require/Proxy-Require
+func requireTags(value string) []string {
+    var tags []string
+    for _, tag := range strings.Split(value, ",") {
+        if tag = strings.TrimSpace(tag); tag != "" {
+            tags = append(tags, tag)
+        }
+    }
+    return tags
+}
+
 func makeOptions(uri string, cseq int32) RtspRequest {
     return makeCommonReq(OPTIONS, uri, cseq)
 }
@@ -300,16 +353,22 @@ func getReasonByStatusCode(code int) string {
         return "Unauthorized"
     case Not_Found:
         return "Not Found"
+    case Unsupported_Media:
+        return "Unsupported Media Type"
     case Session_Not_Found:
         return "Session Not Found"
     case Unsupported_Transport:
         return "Unsupported transport"
+    case Key_Management_Error:
+        return "Key Management Error"
     case Internal_Server_Error:
         return "Internal Server Error"
     case Not_Implemented:
         return "Not Implemented"
     case Version_Not_Supported:
         return "RTSP Version not supported"
+    case Option_Not_Supported:
+        return "Option not supported"
     }
     return "Unsupport StatusCode"
 }
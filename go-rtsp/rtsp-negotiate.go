@@ -0,0 +1,87 @@
+package rtsp
+
+import (
+    "strconv"
+    "strings"
+
+    "github.com/yapingcat/gomedia/go-rtsp/sdp"
+)
+
+// the track of the codec selected by sdp.CreateAnswer,
+// the first codec supported by RtspTrack is used,nil if the media is rejected or no codec is supported
+func NewNegotiatedTrack(media *sdp.NegotiatedMedia, opt ...TrackOption) *RtspTrack {
+    if !media.Accepted {
+        return nil
+    }
+    for _, rtpMap := range media.Codecs {
+        if track := newMediaTrack(media.Offer, rtpMap, opt...); track != nil {
+            return track
+        }
+    }
+    return nil
+}
+
+// the answer of all the inactive medias has no media to set up
+func hasAcceptedMedia(medias []*sdp.NegotiatedMedia) bool {
+    for _, media := range medias {
+        if media.Accepted {
+            return true
+        }
+    }
+    return false
+}
+
+// the track of the payload type described by media,nil if the codec is unsupported
+func newMediaTrack(media *sdp.Media, rtpMap sdp.RtpMap, opt ...TrackOption) *RtspTrack {
+    fmtpHandle := sdp.CreateFmtpParamParser(rtpMap.EncodeName)
+    if fmtp, found := media.Fmtp(rtpMap.PayloadType); found && fmtpHandle != nil {
+        fmtpHandle.Load(fmtp.Encode())
+    }
+    if !IsSupportedCodec(rtpMap.EncodeName) {
+        return nil
+    }
+    if pt, found := rtxPayloadType(media, rtpMap.PayloadType); found {
+        opt = append([]TrackOption{withRecvRtx(uint8(pt))}, opt...)
+    }
+    switch media.MediaType {
+    case "audio":
+        channelCount := 0
+        if rtpMap.EncodParam != "" {
+            channelCount, _ = strconv.Atoi(rtpMap.EncodParam)
+        }
+        codec := NewAudioCodec(rtpMap.EncodeName, uint8(rtpMap.PayloadType), uint32(rtpMap.ClockRate), channelCount)
+        return NewAudioTrack(codec, append([]TrackOption{WithCodecParamHandler(fmtpHandle)}, opt...)...)
+    case "video":
+        codec := NewVideoCodec(rtpMap.EncodeName, uint8(rtpMap.PayloadType), uint32(rtpMap.ClockRate))
+        return NewVideoTrack(codec, append([]TrackOption{WithCodecParamHandler(fmtpHandle)}, opt...)...)
+    default:
+        codec := NewApplicatioCodec(rtpMap.EncodeName, uint8(rtpMap.PayloadType))
+        return NewMetaTrack(codec, opt...)
+    }
+}
+
+// rfc4588 8.1,the rtx payload type whose apt is the payload type pt
+func rtxPayloadType(media *sdp.Media, pt int) (int, bool) {
+    for _, rtpMap := range media.RtpMaps() {
+        if !strings.EqualFold(rtpMap.EncodeName, "rtx") {
+            continue
+        }
+        fmtp, found := media.Fmtp(rtpMap.PayloadType)
+        if !found {
+            continue
+        }
+        if apt, err := strconv.Atoi(fmtp.Parameters()["apt"]); err == nil && apt == pt {
+            return rtpMap.PayloadType, true
+        }
+    }
+    return 0, false
+}
+
+// the codec of the first format of media
+func resolvedRtpMap(media *sdp.Media) sdp.RtpMap {
+    rtpMap := sdp.RtpMap{PayloadType: media.PayloadType, EncodeName: media.EncodeName, ClockRate: media.ClockRate}
+    if media.ChannelCount > 0 {
+        rtpMap.EncodParam = strconv.Itoa(media.ChannelCount)
+    }
+    return rtpMap
+}
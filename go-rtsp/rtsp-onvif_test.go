@@ -0,0 +1,332 @@
+package rtsp
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yapingcat/gomedia/go-rtsp/rtp"
+	"github.com/yapingcat/gomedia/go-rtsp/sdp"
+)
+
+type onvifTestServerHandle struct {
+	playRange *RangeTime
+	requests  []RtspRequest
+}
+
+func (h *onvifTestServerHandle) HandleOption(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+	h.requests = append(h.requests, req)
+}
+func (h *onvifTestServerHandle) HandleDescribe(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+	h.requests = append(h.requests, req)
+}
+func (h *onvifTestServerHandle) HandleSetup(svr *RtspServer, req RtspRequest, res *RtspResponse, transport *RtspTransport, tracks *RtspTrack) {
+	h.requests = append(h.requests, req)
+}
+func (h *onvifTestServerHandle) HandleAnnounce(svr *RtspServer, req RtspRequest, tracks map[string]*RtspTrack) {
+}
+func (h *onvifTestServerHandle) HandlePlay(svr *RtspServer, req RtspRequest, res *RtspResponse, timeRange *RangeTime, info []*RtpInfo) {
+	h.requests = append(h.requests, req)
+	h.playRange = timeRange
+}
+func (h *onvifTestServerHandle) HandlePause(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+}
+func (h *onvifTestServerHandle) HandleTeardown(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+}
+func (h *onvifTestServerHandle) HandleGetParameter(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+}
+func (h *onvifTestServerHandle) HandleSetParameter(svr *RtspServer, req RtspRequest, res *RtspResponse) {
+}
+func (h *onvifTestServerHandle) HandleRecord(svr *RtspServer, req RtspRequest, res *RtspResponse, timeRange *RangeTime, info []*RtpInfo) {
+}
+func (h *onvifTestServerHandle) HandleResponse(svr *RtspServer, res RtspResponse) {}
+
+type onvifTestClientHandle struct {
+	describeStatus int
+	playStatus     int
+	playRange      *RangeTime
+	playInfo       *RtpInfo
+	tracks         map[string]*RtspTrack
+}
+
+func (h *onvifTestClientHandle) HandleOption(cli *RtspClient, res RtspResponse, public []string) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleDescribe(cli *RtspClient, res RtspResponse, sdp *sdp.Sdp, tracks map[string]*RtspTrack) error {
+	h.describeStatus = res.StatusCode
+	h.tracks = tracks
+	return nil
+}
+func (h *onvifTestClientHandle) HandleSetup(cli *RtspClient, res RtspResponse, currentTrack *RtspTrack, tracks map[string]*RtspTrack, sessionId string, timeout int) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleAnnounce(cli *RtspClient, res RtspResponse) error { return nil }
+func (h *onvifTestClientHandle) HandlePlay(cli *RtspClient, res RtspResponse, timeRange *RangeTime, info *RtpInfo) error {
+	h.playStatus = res.StatusCode
+	h.playRange = timeRange
+	h.playInfo = info
+	return nil
+}
+func (h *onvifTestClientHandle) HandlePause(cli *RtspClient, res RtspResponse) error    { return nil }
+func (h *onvifTestClientHandle) HandleTeardown(cli *RtspClient, res RtspResponse) error { return nil }
+func (h *onvifTestClientHandle) HandleGetParameter(cli *RtspClient, res RtspResponse) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleSetParameter(cli *RtspClient, res RtspResponse) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleRedirect(cli *RtspClient, req RtspRequest, location string, timeRange *RangeTime) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleRecord(cli *RtspClient, res RtspResponse, timeRange *RangeTime, info *RtpInfo) error {
+	return nil
+}
+func (h *onvifTestClientHandle) HandleRequest(cli *RtspClient, req RtspRequest) error { return nil }
+
+// the client and server exchange the messages in memory,
+// the queued data is delivered by pump to avoid reentrance
+type onvifTestSession struct {
+	client       *RtspClient
+	server       *RtspServer
+	clientHandle *onvifTestClientHandle
+	serverHandle *onvifTestServerHandle
+	toServer     [][]byte
+	toClient     [][]byte
+	responses    []string
+}
+
+func newOnvifTestSession(t *testing.T, clientOpts []ClientOption, serverOpts []ServerOption, tracks ...*RtspTrack) *onvifTestSession {
+	s := &onvifTestSession{clientHandle: &onvifTestClientHandle{}, serverHandle: &onvifTestServerHandle{}}
+	client, err := NewRtspClient("rtsp://127.0.0.1/replay", s.clientHandle, clientOpts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.client = client
+	s.server = NewRtspServer(s.serverHandle, serverOpts...)
+	for _, track := range tracks {
+		s.server.AddTrack(track)
+	}
+	s.client.SetOutput(func(b []byte) error {
+		s.toServer = append(s.toServer, append([]byte{}, b...))
+		return nil
+	})
+	s.server.SetOutput(func(b []byte) error {
+		if b[0] != '$' {
+			s.responses = append(s.responses, string(b))
+		}
+		s.toClient = append(s.toClient, append([]byte{}, b...))
+		return nil
+	})
+	return s
+}
+
+func (s *onvifTestSession) pump(t *testing.T) {
+	for len(s.toServer) > 0 || len(s.toClient) > 0 {
+		for len(s.toServer) > 0 {
+			data := s.toServer[0]
+			s.toServer = s.toServer[1:]
+			if err := s.server.Input(data); err != nil {
+				t.Fatalf("RtspServer.Input() error = %v", err)
+			}
+		}
+		for len(s.toClient) > 0 {
+			data := s.toClient[0]
+			s.toClient = s.toClient[1:]
+			if err := s.client.Input(data); err != nil {
+				t.Fatalf("RtspClient.Input() error = %v", err)
+			}
+		}
+	}
+}
+
+func newOnvifTestVideoTrack(t *testing.T) *RtspTrack {
+	codec := NewVideoCodec("H264", 96, 90000)
+	return NewVideoTrack(codec)
+}
+
+func newOnvifTestAudioTrack(t *testing.T, opt ...TrackOption) *RtspTrack {
+	codec := NewAudioCodec("PCMA", 8, 8000, 1)
+	return NewAudioTrack(codec, opt...)
+}
+
+func TestOnvifReplay_Play(t *testing.T) {
+	video := newOnvifTestVideoTrack(t)
+	s := newOnvifTestSession(t, []ClientOption{WithOnvifReplay()}, []ServerOption{WithEnableOnvifReplay()}, video)
+	begin := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.client.SetRange(NewClockRange(begin, time.Time{}))
+	s.client.SetRateControl(false)
+	if err := s.client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.pump(t)
+	if s.clientHandle.playStatus != OK {
+		t.Fatalf("PLAY status = %d", s.clientHandle.playStatus)
+	}
+
+	//Require: onvif-replay is sent with DESCRIBE/SETUP/PLAY
+	var play RtspRequest
+	for _, req := range s.serverHandle.requests {
+		switch req.Method {
+		case DESCRIBE, SETUP, PLAY:
+			if req.Fileds[Require] != REQUIRE_ONVIF_REPLAY {
+				t.Errorf("%s Require = %q", req.Method, req.Fileds[Require])
+			}
+		}
+		if req.Method == PLAY {
+			play = req
+		}
+	}
+	if play.Fileds[Range] != "clock=20230102T030405Z-" || play.Fileds[RateControl] != "no" {
+		t.Errorf("PLAY Range = %q,Rate-Control = %q", play.Fileds[Range], play.Fileds[RateControl])
+	}
+	if r := s.serverHandle.playRange; r == nil || r.Type() != RANGE_UTC || !r.BeginTime().Equal(begin) || !r.EndTime().IsZero() {
+		t.Errorf("the range of server = %+v", r)
+	}
+	if r := s.clientHandle.playRange; r == nil || !r.BeginTime().Equal(begin) {
+		t.Errorf("the range of client = %+v", r)
+	}
+
+	//the replay extension of the frame carries the low byte of CSeq of PLAY
+	track, found := s.clientHandle.tracks["video"]
+	if !found {
+		t.Fatalf("the video track is not set up")
+	}
+	var samples []RtspSample
+	track.OnSample(func(sample RtspSample) {
+		samples = append(samples, sample)
+	})
+	ntp := uint64(0x0102030405060708)
+	idr := []byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88, 0x84, 0x00}
+	if err := video.WriteSample(RtspSample{Cid: RTSP_CODEC_H264, Sample: idr, Timestamp: 0, Replay: &rtp.OnvifReplay{NtpTime: ntp, CleanPoint: true}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := video.WriteSample(RtspSample{Cid: RTSP_CODEC_H264, Sample: idr, Timestamp: 3000}); err != nil {
+		t.Fatal(err)
+	}
+	s.pump(t)
+	if len(samples) == 0 {
+		t.Fatalf("no sample is received")
+	}
+	replay := samples[0].Replay
+	cseq := play.Fileds[CSeq]
+	if replay == nil || replay.NtpTime != ntp || !replay.CleanPoint || cseq == "" || strings.TrimSpace(cseq) != strconv.Itoa(int(replay.CSeq)) {
+		t.Errorf("sample replay = %+v,CSeq of PLAY %s", replay, cseq)
+	}
+}
+
+func TestOnvifReplay_Unsupported(t *testing.T) {
+	s := newOnvifTestSession(t, []ClientOption{WithOnvifReplay(), WithOnvifBackchannel()}, nil, newOnvifTestVideoTrack(t))
+	if err := s.client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.pump(t)
+	if s.clientHandle.describeStatus != Option_Not_Supported {
+		t.Fatalf("DESCRIBE status = %d, want 551", s.clientHandle.describeStatus)
+	}
+	last := s.responses[len(s.responses)-1]
+	if !strings.Contains(last, "Unsupported: "+REQUIRE_ONVIF_REPLAY+", "+REQUIRE_ONVIF_BACKCHANNEL+"\r\n") {
+		t.Errorf("551 response = %q", last)
+	}
+	if s.server.IsRequired(REQUIRE_ONVIF_REPLAY) {
+		t.Errorf("the unsupported tag is required")
+	}
+}
+
+func TestOnvifBackchannel(t *testing.T) {
+	//the backchannel is described only if it is required
+	for _, required := range []bool{false, true} {
+		var clientOpts []ClientOption
+		if required {
+			clientOpts = append(clientOpts, WithOnvifBackchannel())
+		}
+		back := newOnvifTestAudioTrack(t, WithBackchannel())
+		s := newOnvifTestSession(t, clientOpts, nil, newOnvifTestVideoTrack(t), back)
+		if err := s.client.Start(); err != nil {
+			t.Fatal(err)
+		}
+		s.pump(t)
+		if s.clientHandle.playStatus != OK {
+			t.Fatalf("required %v: PLAY status = %d", required, s.clientHandle.playStatus)
+		}
+		track, found := s.clientHandle.tracks[BACKCHANNEL_TRACK]
+		if found != required {
+			t.Fatalf("required %v: backchannel track found %v", required, found)
+		}
+		if !required {
+			continue
+		}
+		if !track.IsBackchannel() || !s.server.IsRequired(REQUIRE_ONVIF_BACKCHANNEL) {
+			t.Fatalf("the backchannel is not negotiated")
+		}
+		//the audio is sent from the client to the server
+		var samples []RtspSample
+		back.OnSample(func(sample RtspSample) {
+			samples = append(samples, sample)
+		})
+		for i := 0; i < 3; i++ {
+			if err := track.WriteSample(RtspSample{Cid: RTSP_CODEC_G711A, Sample: []byte{byte(i), byte(i)}, Timestamp: uint32(i * 160)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.pump(t)
+		if len(samples) < 2 || samples[0].Sample[0] != 0 || samples[1].Sample[0] != 1 {
+			t.Errorf("the server received %+v", samples)
+		}
+	}
+}
+
+func TestRtpInfo_PlayResponse(t *testing.T) {
+	s := newOnvifTestSession(t, nil, nil, newOnvifTestVideoTrack(t))
+	if err := s.client.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s.pump(t)
+	last := s.responses[len(s.responses)-1]
+	if !strings.Contains(last, "\r\nRTP-Info: url=") || !strings.Contains(last, ";seq=") {
+		t.Errorf("PLAY response = %q", last)
+	}
+	info := s.clientHandle.playInfo
+	if info == nil || !strings.HasSuffix(info.Url, "track0") {
+		t.Errorf("RTP-Info = %+v", info)
+	}
+}
+
+func TestRtpInfo_Header(t *testing.T) {
+	//the header of the servers in the wild is found by RTPInfo
+	data := "RTSP/1.0 200 OK\r\nCSeq: 4\r\nSession: 12345678\r\nRTP-Info: url=rtsp://127.0.0.1/live/track0;seq=100;rtptime=3000\r\n\r\n"
+	res := &RtspResponse{Fileds: make(HeadFiled)}
+	if _, err := res.parse(data); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Fileds.Has(RTPInfo) {
+		t.Fatalf("%s is not found in %v", RTPInfo, res.Fileds)
+	}
+	info := &RtpInfo{}
+	info.Decode(res.Fileds[RTPInfo])
+	if info.Seq != 100 || info.Rtptime != 3000 {
+		t.Errorf("RTP-Info = %+v", info)
+	}
+	if encoded := res.Encode(); !strings.Contains(encoded, "\r\nRTP-Info: url=") {
+		t.Errorf("Encode() = %q", encoded)
+	}
+}
+
+func TestRtpInfo(t *testing.T) {
+	info := NewRtpInfo("rtsp://127.0.0.1/live/track0", 65535)
+	if got := info.EncodeString(); got != "url=rtsp://127.0.0.1/live/track0;seq=65535" {
+		t.Errorf("EncodeString() = %q", got)
+	}
+	info.Rtptime = 3000
+	info.Ssrc = 0x0A13C760
+	if got := info.EncodeWithVersion(RTSP_2_0); got != "url=\"rtsp://127.0.0.1/live/track0\" ssrc=0A13C760:seq=65535;rtptime=3000" {
+		t.Errorf("EncodeWithVersion(2.0) = %q", got)
+	}
+	for _, version := range []int{RTSP_1_0, RTSP_2_0} {
+		decoded := &RtpInfo{}
+		decoded.Decode(info.EncodeWithVersion(version))
+		if decoded.Url != info.Url || decoded.Seq != 65535 || decoded.Rtptime != 3000 {
+			t.Errorf("Decode(%d) = %+v", version, decoded)
+		}
+	}
+}
@@ -21,6 +21,45 @@ type RangeTime struct {
     end       int64 // -1 meas has no end
 }
 
+// npt range in milliseconds,begin -1 means now,end -1 means open end
+func NewNptRange(begin int64, end int64) RangeTime {
+    return RangeTime{rangeType: RANGE_NPT, begin: begin, end: end}
+}
+
+// absolute time range,e.g. onvif replay,zero end means open end
+func NewClockRange(begin time.Time, end time.Time) RangeTime {
+    rt := RangeTime{rangeType: RANGE_UTC, begin: begin.UnixNano() / 1000000, end: -1}
+    if !end.IsZero() {
+        rt.end = end.UnixNano() / 1000000
+    }
+    return rt
+}
+
+func (rt RangeTime) Type() RangeType {
+    return rt.rangeType
+}
+
+// milliseconds,npt offset or unix time of clock range
+func (rt RangeTime) Begin() int64 {
+    return rt.begin
+}
+
+func (rt RangeTime) End() int64 {
+    return rt.end
+}
+
+func (rt RangeTime) BeginTime() time.Time {
+    return time.Unix(rt.begin/1000, rt.begin%1000*1000000).UTC()
+}
+
+// zero time if the range has no end
+func (rt RangeTime) EndTime() time.Time {
+    if rt.end == -1 {
+        return time.Time{}
+    }
+    return time.Unix(rt.end/1000, rt.end%1000*1000000).UTC()
+}
+
 func (rt RangeTime) EncodeString() string {
     switch rt.rangeType {
     case RANGE_NPT:
@@ -28,19 +67,17 @@ func (rt RangeTime) EncodeString() string {
         if rt.begin == -1 {
             npt += "now-"
         } else {
-            npt += fmt.Sprintf("%d.%d-", rt.begin/1000, rt.begin%1000)
+            npt += fmt.Sprintf("%d.%03d-", rt.begin/1000, rt.begin%1000)
             if rt.end != -1 {
-                npt += fmt.Sprintf("%d.%d", rt.end/1000, rt.end%1000)
+                npt += fmt.Sprintf("%d.%03d", rt.end/1000, rt.end%1000)
             }
         }
         return npt
     case RANGE_UTC:
         clock := "clock="
-        beg := time.Unix(rt.begin/1000, rt.begin%1000*1000000)
-        clock += beg.Format("20060102T150405.999Z-")
+        clock += rt.BeginTime().Format("20060102T150405.999Z-")
         if rt.end != -1 {
-            end := time.Unix(rt.end/1000, rt.end%1000*1000000)
-            clock += end.Format("20060102T150405.999Z")
+            clock += rt.EndTime().Format("20060102T150405.999Z")
         }
         return clock
     default:
@@ -73,21 +110,29 @@ func parseRange(str string) (*RangeTime, error) {
         tp := strings.Split(timestr[1], "-")
         if tp[0] == "now" {
             rt.begin = -1
+        } else {
+            rt.begin = parseNPT(tp[0])
         }
-        rt.begin = parseNPT(tp[0])
         rt.end = -1
-        if len(tp) > 1 {
+        if len(tp) > 1 && tp[1] != "" {
             rt.end = parseNPT(tp[1])
         }
         return rt, nil
     case "clock":
         rt.rangeType = RANGE_UTC
+        //the fractional seconds are accepted by time.Parse,e.g. 20090615T114900.440Z
         layout := "20060102T150405Z"
         tp := strings.Split(timestr[1], "-")
-        t, _ := time.Parse(layout, tp[0])
+        t, err := time.Parse(layout, tp[0])
+        if err != nil {
+            return rt, err
+        }
         rt.begin = t.UTC().UnixNano() / 1000000
-        if len(tp) > 1 {
-            t, _ = time.Parse(layout, tp[0])
+        rt.end = -1
+        if len(tp) > 1 && tp[1] != "" {
+            if t, err = time.Parse(layout, tp[1]); err != nil {
+                return rt, err
+            }
             rt.end = t.UTC().UnixNano() / 1000000
         }
         return rt, nil
@@ -0,0 +1,92 @@
+package rtsp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		str       string
+		rangeType RangeType
+		begin     int64
+		end       int64
+		wantErr   bool
+	}{
+		{name: "npt", str: "npt=10.5-20", rangeType: RANGE_NPT, begin: 10500, end: 20000},
+		{name: "npt open end", str: "npt=10-", rangeType: RANGE_NPT, begin: 10000, end: -1},
+		{name: "npt now", str: "npt=now-", rangeType: RANGE_NPT, begin: -1, end: -1},
+		{name: "npt hhmmss", str: "npt=0:01:02.500-1:00:00", rangeType: RANGE_NPT, begin: 62500, end: 3600000},
+		{name: "npt with time", str: "npt=0-7.741;time=19970123T153600Z", rangeType: RANGE_NPT, begin: 0, end: 7741},
+		{
+			name: "clock", str: "clock=20090615T114900.440Z-20090615T115000Z", rangeType: RANGE_UTC,
+			begin: time.Date(2009, 6, 15, 11, 49, 0, 440000000, time.UTC).UnixNano() / 1000000,
+			end:   time.Date(2009, 6, 15, 11, 50, 0, 0, time.UTC).UnixNano() / 1000000,
+		},
+		{
+			name: "clock open end", str: "clock=20090615T114900Z-", rangeType: RANGE_UTC,
+			begin: time.Date(2009, 6, 15, 11, 49, 0, 0, time.UTC).UnixNano() / 1000000, end: -1,
+		},
+		{name: "invalid clock begin", str: "clock=2009-", wantErr: true},
+		{name: "invalid clock end", str: "clock=20090615T114900Z-x", wantErr: true},
+		{name: "smpte", str: "smpte=10:07:00-10:07:33:05.01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, err := parseRange(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRange(%q) error = %v, wantErr %v", tt.str, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if rt.Type() != tt.rangeType || rt.Begin() != tt.begin || rt.End() != tt.end {
+				t.Errorf("parseRange(%q) = %v %d-%d, want %v %d-%d", tt.str, rt.Type(), rt.Begin(), rt.End(), tt.rangeType, tt.begin, tt.end)
+			}
+		})
+	}
+}
+
+func TestNewClockRange(t *testing.T) {
+	begin := time.Date(2009, 6, 15, 11, 49, 0, 440000000, time.FixedZone("CST", 8*3600))
+	end := begin.Add(time.Minute)
+	rt := NewClockRange(begin, end)
+	if rt.Type() != RANGE_UTC || !rt.BeginTime().Equal(begin) || !rt.EndTime().Equal(end) {
+		t.Fatalf("NewClockRange() = %v-%v", rt.BeginTime(), rt.EndTime())
+	}
+	//the clock is utc
+	str := rt.EncodeString()
+	if str != "clock=20090615T034900.44Z-20090615T035000.44Z" {
+		t.Fatalf("EncodeString() = %q", str)
+	}
+	parsed, err := parseRange(str)
+	if err != nil || *parsed != rt {
+		t.Errorf("parseRange(%q) = %+v %v, want %+v", str, parsed, err, rt)
+	}
+
+	open := NewClockRange(begin, time.Time{})
+	if open.End() != -1 || !open.EndTime().IsZero() || open.EncodeString() != "clock=20090615T034900.44Z-" {
+		t.Errorf("NewClockRange() without end = %q", open.EncodeString())
+	}
+}
+
+func TestNptRange(t *testing.T) {
+	tests := []struct {
+		rt  RangeTime
+		str string
+	}{
+		{NewNptRange(10500, 20000), "npt=10.500-20.000"},
+		{NewNptRange(0, -1), "npt=0.000-"},
+		{NewNptRange(-1, -1), "npt=now-"},
+	}
+	for _, tt := range tests {
+		if got := tt.rt.EncodeString(); got != tt.str {
+			t.Errorf("EncodeString() = %q, want %q", got, tt.str)
+		}
+		parsed, err := parseRange(tt.str)
+		if err != nil || *parsed != tt.rt {
+			t.Errorf("parseRange(%q) = %+v %v, want %+v", tt.str, parsed, err, tt.rt)
+		}
+	}
+}
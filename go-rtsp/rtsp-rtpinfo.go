@@ -1,29 +1,62 @@
 package rtsp
 
 import (
+    "fmt"
     "strconv"
     "strings"
 )
 
 type RtpInfo struct {
     Url     string
+    Ssrc    uint32 //rtsp 2.0 only
     Seq     uint16
-    Rtptime int64
+    Rtptime int64 //-1 means absent
 }
 
 func NewRtpInfo(url string, seq uint16) *RtpInfo {
-    return &RtpInfo{Url: url, Seq: seq}
+    return &RtpInfo{Url: url, Seq: seq, Rtptime: -1}
 }
 
 func (info *RtpInfo) EncodeString() string {
-    str := "url=" + info.Url + ";" + strconv.Itoa(int(info.Seq))
-    if info.Rtptime < 0 {
+    str := "url=" + info.Url + ";seq=" + strconv.Itoa(int(info.Seq))
+    if info.Rtptime >= 0 {
+        str += ";rtptime=" + strconv.Itoa(int(info.Rtptime))
+    }
+    return str
+}
+
+// rtsp 2.0: url="rtsp://example.com/foo/audio" ssrc=0A13C760:seq=45102;rtptime=12345678
+func (info *RtpInfo) EncodeWithVersion(version int) string {
+    if version != RTSP_2_0 {
+        return info.EncodeString()
+    }
+    str := "url=\"" + info.Url + "\" ssrc=" + fmt.Sprintf("%08X", info.Ssrc)
+    str += ":seq=" + strconv.Itoa(int(info.Seq))
+    if info.Rtptime >= 0 {
         str += ";rtptime=" + strconv.Itoa(int(info.Rtptime))
     }
     return str
 }
 
 func (info *RtpInfo) Decode(str string) {
+    str = strings.TrimSpace(str)
+    if strings.HasPrefix(str, "url=\"") {
+        if end := strings.Index(str[5:], "\""); end != -1 {
+            info.Url = str[5 : 5+end]
+            str = strings.TrimSpace(str[6+end:])
+        }
+        if strings.HasPrefix(str, "ssrc=") {
+            ssrc := str[5:]
+            if idx := strings.Index(ssrc, ":"); idx != -1 {
+                str = ssrc[idx+1:]
+                ssrc = ssrc[:idx]
+            } else {
+                str = ""
+            }
+            v, _ := strconv.ParseUint(ssrc, 16, 32)
+            info.Ssrc = uint32(v)
+        }
+    }
     items := strings.Split(str, ";")
     for _, item := range items {
         kv := strings.Split(item, "=")
@@ -6,10 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/yapingcat/gomedia/go-rtsp/sdp"
+	"github.com/yapingcat/gomedia/go-rtsp/srtp"
 )
 
 type RtspServer struct {
@@ -25,6 +27,17 @@ type RtspServer struct {
 	sdpContext  *sdp.Sdp
 	interleaved int
 	isRecord    bool
+	onvifReplay bool
+	require     []string //the option tags required by client
+	timeout     int      //second,the timeout parameter of Session
+	version     int      //the version of the last request
+	noRtsp2     bool
+	mediaProps  *MediaProps
+	ranges      string //Accept-Ranges
+	pipelined   string //Pipelined-Requests of SETUP which creates the session
+	playUri     string
+	caps        []sdp.Capability
+	cseq        int32 //the requests sent by server,e.g. PLAY_NOTIFY
 }
 
 type ServerOption func(*RtspServer)
@@ -48,14 +61,62 @@ func WithRealm(realm string) ServerOption {
 	}
 }
 
+// the server accepts Require: onvif-replay,
+// the replay extension is written by RtspTrack.WriteSample with RtspSample.Replay
+func WithEnableOnvifReplay() ServerOption {
+	return func(rs *RtspServer) {
+		rs.onvifReplay = true
+	}
+}
+
+// the session is closed by the server if there is no keepalive in timeout seconds,
+// it is announced by Session: xxxx;timeout=60
+func WithSessionTimeout(timeout int) ServerOption {
+	return func(rs *RtspServer) {
+		rs.timeout = timeout
+	}
+}
+
+// the RTSP/2.0 request is replied with 505,the client falls back to RTSP/1.0
+func WithDisableRtsp2() ServerOption {
+	return func(rs *RtspServer) {
+		rs.noRtsp2 = true
+	}
+}
+
+// the Media-Properties replied in SETUP/PLAY of rtsp 2.0,it is NewLiveMediaProps() by default
+func WithMediaProperties(props *MediaProps) ServerOption {
+	return func(rs *RtspServer) {
+		rs.mediaProps = props
+	}
+}
+
+// the Accept-Ranges replied in SETUP/PLAY of rtsp 2.0,it is npt by default
+func WithAcceptRanges(ranges ...string) ServerOption {
+	return func(rs *RtspServer) {
+		rs.ranges = strings.Join(ranges, ", ")
+	}
+}
+
+// the codecs of ANNOUNCE are negotiated by the capabilities(rfc3264),
+// ANNOUNCE is replied with 415 if no media is accepted,
+// only one media is recorded if there are several medias of the same type
+func WithAnnounceCapabilities(caps ...sdp.Capability) ServerOption {
+	return func(rs *RtspServer) {
+		rs.caps = caps
+	}
+}
+
 func NewRtspServer(handle ServerHandle, opt ...ServerOption) *RtspServer {
 	server := &RtspServer{
 		handle:     handle,
 		auth:       nil,
 		realm:      "gomedia server",
 		tracks:     make(map[string]*RtspTrack),
-		sdpContext: &sdp.Sdp{},
+		sdpContext: sdp.NewSdp(),
 		isRecord:   false,
+		ranges:     "npt",
+		cseq:       1,
 	}
 	for _, o := range opt {
 		o(server)
@@ -65,15 +126,107 @@ func NewRtspServer(handle ServerHandle, opt ...ServerOption) *RtspServer {
 		server.auth.setUserInfo(server.userName, server.passwd)
 		server.auth.setRealm(server.realm)
 	}
-	server.sdpContext.Attrs = make(map[string]string)
-	server.sdpContext.Attrs["control"] = "*"
+	server.sdpContext.Attributes.Set("control", "*")
 	return server
 }
 
+// the backchannel track(WithBackchannel) is described only if the client requires it
 func (server *RtspServer) AddTrack(track *RtspTrack) {
 	track.uri = fmt.Sprintf("track%d", len(server.tracks))
-	server.tracks[track.TrackName] = track
-	server.sdpContext.ParserSdp(track.mediaDescripe())
+	if track.backchannel {
+		server.tracks[BACKCHANNEL_TRACK] = track
+	} else {
+		server.tracks[track.TrackName] = track
+	}
+	media := &sdp.Media{}
+	media.Decode(track.mediaDescripe())
+	server.sdpContext.Medias = append(server.sdpContext.Medias, media)
+}
+
+func (server *RtspServer) GetTrack(trackName string) (track *RtspTrack, found bool) {
+	track, found = server.tracks[trackName]
+	return
+}
+
+// whether the client requires the option tag,e.g. REQUIRE_ONVIF_REPLAY
+func (server *RtspServer) IsRequired(tag string) bool {
+	for _, t := range server.require {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// the unsupported option tags of Require
+func (server *RtspServer) unsupportedOptions(request RtspRequest) []string {
+	var unsupported []string
+	for _, tag := range requireTags(request.Fileds[Require]) {
+		switch tag {
+		case REQUIRE_ONVIF_REPLAY:
+			if server.onvifReplay {
+				continue
+			}
+		case REQUIRE_ONVIF_BACKCHANNEL:
+			if _, found := server.tracks[BACKCHANNEL_TRACK]; found {
+				continue
+			}
+		}
+		unsupported = append(unsupported, tag)
+	}
+	return unsupported
+}
+
+// the sendonly backchannel media is removed if it is not required
+func (server *RtspServer) sessionDescribe() string {
+	if server.IsRequired(REQUIRE_ONVIF_BACKCHANNEL) {
+		return server.sdpContext.Encode()
+	}
+	describe := *server.sdpContext
+	describe.Medias = nil
+	for _, media := range server.sdpContext.Medias {
+		if media.Direction() == sdp.SENDONLY {
+			continue
+		}
+		describe.Medias = append(describe.Medias, media)
+	}
+	return describe.Encode()
+}
+
+func (server *RtspServer) SessionId() string {
+	return server.sessionId
+}
+
+// the version of the last request from the client
+func (server *RtspServer) Version() int {
+	return server.version
+}
+
+// PLAY_NOTIFY(rtsp 2.0) informs the client of the change of the stream,e.g. NOTIFY_END_OF_STREAM,
+// the reply of the client is passed to ServerHandle.HandleResponse
+func (server *RtspServer) PlayNotify(reason string, fileds HeadFiled) error {
+	if server.version != RTSP_2_0 {
+		return errors.New("play notify is supported only by rtsp 2.0")
+	}
+	if server.sessionId == "" {
+		return errors.New("play notify without session")
+	}
+	uri := server.playUri
+	if uri == "" {
+		uri = "*"
+	}
+	req := makeCommonReq(PLAY_NOTIFY, uri, server.cseq)
+	server.cseq++
+	req.Version = RTSP_2_0
+	for k, v := range fileds {
+		req.Fileds[k] = v
+	}
+	req.Fileds[NotifyReason] = reason
+	req.Fileds[Session] = server.sessionId
+	if server.output != nil {
+		return server.output([]byte(req.Encode()))
+	}
+	return nil
 }
 
 func (server *RtspServer) SetOutput(output OutPutCallBack) {
@@ -178,11 +331,14 @@ func (server *RtspServer) handleRtspMessage(msg []byte) (int, error) {
 	}
 }
 
-// TODO
-// server send request to client
+// the response of the request sent by server,e.g. PLAY_NOTIFY
 func (server *RtspServer) handleResponse(res []byte) (ret int, err error) {
-	response := RtspResponse{}
+	response := RtspResponse{Fileds: make(HeadFiled)}
 	ret, err = response.parse(string(res))
+	if err != nil {
+		return
+	}
+	server.handle.HandleResponse(server, response)
 	return
 }
 
@@ -193,6 +349,11 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 	if err != nil {
 		return
 	}
+	if (request.Version != RTSP_1_0 && request.Version != RTSP_2_0) || (request.Version == RTSP_2_0 && server.noRtsp2) {
+		res := RtspResponse{Version: RTSP_1_0, StatusCode: Version_Not_Supported, Fileds: make(HeadFiled)}
+		return ret, server.sendRespones(request, res)
+	}
+	server.version = request.Version
 	if server.userName != "" && server.passwd != "" {
 		server.auth.setMethod(request.Method)
 		if !request.Fileds.Has(Authorization) || !server.auth.check(request.Fileds[Authorization]) {
@@ -203,12 +364,21 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 	res := RtspResponse{}
 	res.Fileds = make(HeadFiled)
 	res.StatusCode = 200
-	res.Version = RTSP_1_0
-	if server.sessionId != "" {
-		if !request.Fileds.Has(Session) || request.Fileds[Session] != server.sessionId {
-			res.StatusCode = Session_Not_Found
+	if server.sessionId != "" && !server.matchSession(request) {
+		res.StatusCode = Session_Not_Found
+		return ret, server.sendRespones(request, res)
+	}
+	if request.Fileds.Has(Require) {
+		if unsupported := server.unsupportedOptions(request); len(unsupported) > 0 {
+			res.StatusCode = Option_Not_Supported
+			res.Fileds[Unsupported] = strings.Join(unsupported, ", ")
 			return ret, server.sendRespones(request, res)
 		}
+		for _, tag := range requireTags(request.Fileds[Require]) {
+			if !server.IsRequired(tag) {
+				server.require = append(server.require, tag)
+			}
+		}
 	}
 	switch request.Method {
 	case OPTIONS:
@@ -217,11 +387,14 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 		server.handle.HandleOption(server, request, &res)
 		if res.StatusCode == 200 {
 			res.Fileds[Public] = public
+			if request.Version == RTSP_2_0 && !res.Fileds.Has(Supported) {
+				res.Fileds[Supported] = FEATURE_PLAY_BASIC
+			}
 		}
 	case DESCRIBE:
 		server.handle.HandleDescribe(server, request, &res)
 		if res.StatusCode == OK {
-			res.Body = server.sdpContext.Encode()
+			res.Body = server.sessionDescribe()
 			res.Fileds[ContentType] = "application/sdp"
 		}
 	case SETUP:
@@ -232,17 +405,24 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 			}
 			foundTrack = true
 			track.uri = request.Uri
-			transport := NewRtspTransport()
-			transport.DecodeString(request.Fileds[Transport])
-			server.handle.HandleSetup(server, request, &res, transport, track)
+			if request.Fileds.Has(KeyMgmt) {
+				//the srtp key of the client
+				keyMgmt := &KeyMgmtInfo{}
+				keyMgmt.Decode(request.Fileds[KeyMgmt])
+				crypto, cryptoErr := keyMgmt.Crypto()
+				if cryptoErr == nil {
+					cryptoErr = track.SetRemoteCrypto(crypto)
+				}
+				if cryptoErr != nil {
+					res.StatusCode = Key_Management_Error
+					break
+				}
+			}
+			transport := server.selectTransport(request, &res, track)
 			if res.StatusCode == 200 {
 				if server.sessionId == "" {
-					number := []byte("0123456789")
-					b := make([]byte, 10)
-					for i := range b {
-						b[i] = number[rand.Intn(len(number))]
-					}
-					server.sessionId = string(b)
+					server.sessionId = makeSessionId(request.Version)
+					server.pipelined = request.Fileds[PipelinedRequests]
 				}
 				if transport.Proto == TCP {
 					transport.Interleaved[0] = server.interleaved
@@ -261,8 +441,19 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 						return server.output(interleavedPacket)
 					})
 				}
+				transport.Secure = track.IsSecure()
+				if track.remoteCrypto != nil && track.localCrypto != nil {
+					//the key of the server,it may be generated for the key of the client
+					res.Fileds[KeyMgmt] = NewSdesKeyMgmt(request.Uri, track.localCrypto).EncodeString()
+				}
 				res.Fileds[Transport] = transport.EncodeString()
 				res.Fileds[Session] = server.sessionId
+				if server.timeout > 0 {
+					res.Fileds[Session] += ";timeout=" + strconv.Itoa(server.timeout)
+				}
+				if request.Version == RTSP_2_0 {
+					server.addMediaProperties(&res)
+				}
 				track.SetTransport(transport)
 			}
 			break
@@ -274,24 +465,53 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 		if err = server.sdpContext.ParserSdp(request.Body); err != nil {
 			return
 		}
-		server.isRecord = true
-		for _, media := range server.sdpContext.Medias {
-			fmtpHandle := sdp.CreateFmtpParamParser(media.EncodeName)
-			if fmtpHandle != nil {
-				fmtpHandle.Load(media.Attrs["fmtp"])
+		var negotiated []*sdp.NegotiatedMedia
+		if len(server.caps) > 0 {
+			answer, answerErr := sdp.CreateAnswer(server.sdpContext, server.caps, sdp.WithLocalPreference())
+			if answerErr != nil || !hasAcceptedMedia(answer.Medias) {
+				res.StatusCode = Unsupported_Media
+				break
 			}
+			negotiated = answer.Medias
+		}
+		server.isRecord = true
+		owners := make(map[string]int)
+		for i, media := range server.sdpContext.Medias {
 			var track *RtspTrack = nil
-			if media.MediaType == "audio" {
-				track = NewAudioTrack(NewAudioCodec(media.EncodeName, uint8(media.PayloadType), uint32(media.ClockRate), media.ChannelCount), WithCodecParamHandler(fmtpHandle))
-			} else if media.MediaType == "video" {
-				track = NewVideoTrack(NewVideoCodec(media.EncodeName, uint8(media.PayloadType), uint32(media.ClockRate)), WithCodecParamHandler(fmtpHandle))
+			if negotiated != nil {
+				track = NewNegotiatedTrack(negotiated[i])
 			} else {
-				track = NewMetaTrack(NewApplicatioCodec(media.EncodeName, uint8(media.PayloadType)))
+				track = newMediaTrack(media, resolvedRtpMap(media))
+			}
+			if track == nil {
+				//the track of unsupported codec is not recorded
+				continue
+			}
+			if negotiated != nil {
+				//the media of more preferred codec replaces the previous one
+				if owner, found := owners[media.MediaType]; found && negotiated[i].Preference >= negotiated[owner].Preference {
+					continue
+				}
+				owners[media.MediaType] = i
 			}
 			track.uri = media.ControlUrl
+			track.loadExtmap(server.sdpContext.Attributes.Extmaps(), media.Attributes.Extmaps())
+			if value, found := media.Attributes.Get("crypto"); found && strings.Contains(media.Proto, "SAVP") {
+				//the pusher encrypts the packets by the key of a=crypto
+				crypto, cryptoErr := srtp.ParseCryptoAttribute(value)
+				if cryptoErr == nil {
+					cryptoErr = track.SetRemoteCrypto(crypto)
+				}
+				if cryptoErr != nil {
+					res.StatusCode = Key_Management_Error
+					break
+				}
+			}
 			server.tracks[media.MediaType] = track
 		}
-		server.handle.HandleAnnounce(server, request, server.tracks)
+		if res.StatusCode == 200 {
+			server.handle.HandleAnnounce(server, request, server.tracks)
+		}
 	case PLAY:
 		var tr *RangeTime = nil
 		var info []*RtpInfo
@@ -299,19 +519,34 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 			tr, _ = parseRange(request.Fileds[Range])
 		}
 		for _, t := range server.tracks {
-			i := &RtpInfo{}
-			i.Url = t.uri
-			i.Seq = t.initSequence
+			if t.backchannel {
+				continue
+			}
+			rtpInfo := NewRtpInfo(t.uri, t.initSequence)
+			rtpInfo.Ssrc = t.ssrc
+			info = append(info, rtpInfo)
+			if server.IsRequired(REQUIRE_ONVIF_REPLAY) {
+				cseq, _ := strconv.Atoi(request.Fileds[CSeq])
+				t.replayCSeq = uint8(cseq)
+			}
 		}
 		server.handle.HandlePlay(server, request, &res, tr, info)
 		if res.StatusCode == 200 {
+			server.playUri = request.Uri
 			if tr != nil {
 				res.Fileds[Range] = tr.EncodeString()
 			}
+			if request.Version == RTSP_2_0 {
+				server.addMediaProperties(&res)
+				//the seek style used by server is the one requested
+				if request.Fileds.Has(SeekStyle) && !res.Fileds.Has(SeekStyle) {
+					res.Fileds[SeekStyle] = request.Fileds[SeekStyle]
+				}
+			}
 			if len(info) > 0 {
 				infostr := ""
 				for _, i := range info {
-					infostr += i.EncodeString()
+					infostr += i.EncodeWithVersion(request.Version)
 					infostr += ","
 				}
 				res.Fileds[RTPInfo] = infostr[:len(infostr)-1]
@@ -324,9 +559,7 @@ func (server *RtspServer) handleRequest(req []byte) (ret int, err error) {
 			tr, _ = parseRange(request.Fileds[Range])
 		}
 		for _, t := range server.tracks {
-			i := &RtpInfo{}
-			i.Url = t.uri
-			i.Seq = t.initSequence
+			info = append(info, NewRtpInfo(t.uri, t.initSequence))
 		}
 		server.handle.HandleRecord(server, request, &res, tr, info)
 		if res.StatusCode == 200 {
@@ -361,11 +594,83 @@ func (server *RtspServer) handleUnAuth(request RtspRequest) error {
 	return server.sendRespones(request, response)
 }
 
+// the request belongs to the session,or it is pipelined with the SETUP which creates the session(rtsp 2.0)
+func (server *RtspServer) matchSession(request RtspRequest) bool {
+	if request.Fileds.Has(Session) {
+		//Session: xxxx;timeout=60
+		return strings.TrimSpace(strings.Split(request.Fileds[Session], ";")[0]) == server.sessionId
+	}
+	return server.pipelined != "" && request.Fileds[PipelinedRequests] == server.pipelined
+}
+
+func (server *RtspServer) addMediaProperties(res *RtspResponse) {
+	if !res.Fileds.Has(MediaProperties) {
+		props := server.mediaProps
+		if props == nil {
+			props = NewLiveMediaProps()
+		}
+		res.Fileds[MediaProperties] = props.EncodeString()
+	}
+	if !res.Fileds.Has(AcceptRanges) && server.ranges != "" {
+		res.Fileds[AcceptRanges] = server.ranges
+	}
+}
+
 func (server *RtspServer) sendRespones(req RtspRequest, res RtspResponse) error {
+	if res.Version == 0 {
+		res.Version = req.Version
+	}
 	res.Fileds[CSeq] = req.Fileds[CSeq]
+	if !res.Fileds.Has(Session) && server.sessionId != "" && (req.Fileds.Has(Session) || req.Fileds.Has(PipelinedRequests)) {
+		res.Fileds[Session] = server.sessionId
+	}
+	if req.Fileds.Has(PipelinedRequests) {
+		res.Fileds[PipelinedRequests] = req.Fileds[PipelinedRequests]
+	}
 	res.Fileds[Date] = time.Now().UTC().Format("02 Jan 06 15:04:05 GMT")
 	if server.output != nil {
 		return server.output([]byte(res.Encode()))
 	}
 	return nil
 }
+
+// rtsp 2.0 recommends the session id is random and not shorter than 8 characters
+// the transport-specs are tried in order until one is not rejected with 461 by the handle
+func (server *RtspServer) selectTransport(request RtspRequest, res *RtspResponse, track *RtspTrack) *RtspTransport {
+	transports := DecodeTransports(request.Fileds[Transport])
+	if len(transports) == 0 {
+		transports = append(transports, NewRtspTransport())
+	}
+	var transport *RtspTransport
+	for _, transport = range transports {
+		transport.version = request.Version
+		res.StatusCode = OK
+		server.handle.HandleSetup(server, request, res, transport, track)
+		if res.StatusCode == OK && transport.IsMultiCast && transport.Destination == "" {
+			//the group of the track is replied if the handle does not choose one
+			if group, port, ttl := track.MulticastGroup(); group != "" {
+				transport.SetMulticastGroup(group, port, port+1, ttl)
+			} else {
+				res.StatusCode = Unsupported_Transport
+			}
+		}
+		if res.StatusCode != Unsupported_Transport {
+			break
+		}
+	}
+	return transport
+}
+
+func makeSessionId(version int) string {
+	letters := []byte("0123456789")
+	length := 10
+	if version == RTSP_2_0 {
+		letters = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+		length = 16
+	}
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = letters[rand.Intn(len(letters))]
+	}
+	return string(b)
+}
@@ -3,12 +3,15 @@ package rtsp
 import (
     "fmt"
     "math/rand"
+    "strings"
     "time"
 
     "github.com/yapingcat/gomedia/go-codec"
+    "github.com/yapingcat/gomedia/go-mpeg2"
     "github.com/yapingcat/gomedia/go-rtsp/rtcp"
     "github.com/yapingcat/gomedia/go-rtsp/rtp"
     "github.com/yapingcat/gomedia/go-rtsp/sdp"
+    "github.com/yapingcat/gomedia/go-rtsp/srtp"
 )
 
 func init() {
@@ -20,10 +23,14 @@ type RtspSample struct {
     Sample    []byte
     Timestamp uint32 //in milliseconds
     Completed bool
+    Replay    *rtp.OnvifReplay //onvif replay extension,the absolute time and flags of the frame
 }
 
 type OnSampleCallBack func(sample RtspSample)
 
+// the name of onvif audio backchannel track in RtspClient/RtspServer
+const BACKCHANNEL_TRACK = "backchannel"
+
 type RtspTrack struct {
     TrackName    string //video/audio/application
     Codec        RtspCodec
@@ -41,6 +48,38 @@ type RtspTrack struct {
     recvCtx      *rtcp.RtcpContext
     sendCtx      *rtcp.RtcpContext
     autoSendRR   bool
+    onRtp        rtp.RTP_HOOK_FUNC
+    jitter       *rtp.JitterBuffer //reorder packets over udp
+    jitterDelay  time.Duration
+    jitterCount  int
+    noJitter     bool
+    nack         bool
+    rtxBuf       *rtp.RetransmitBuffer //sent packets for nack
+    rtx          *rtp.RtxPacker
+    recvRtx      bool  //the retransmission stream of payload type rtxPt is received
+    rtxPt        uint8
+    mediaSsrc    uint32 //the ssrc of the original stream,learned from the first packet
+    hasMediaSsrc bool
+    onKeyFrame   func()
+    cname        string
+    bandwidth    int //session bandwidth,bits per second
+    scheduler    *rtcp.RtcpScheduler
+    lastSent     uint64 //rtp packets sent before the last report
+    extmap       *rtp.ExtensionMap
+    onSendRtp    rtp.RTP_HOOK_FUNC
+    twccSeq      uint16 //transport-wide sequence number
+    backchannel  bool
+    recvReplay   *rtp.OnvifReplay //the replay extension of the frame being received
+    sendReplay   *rtp.OnvifReplay //the replay extension of the frame being sent
+    replayCSeq   uint8            //the low byte of CSeq of PLAY
+    multicast    *sdp.Connection  //the group announced by c= of the media
+    mcastPort    uint16
+    srtpProfile  srtp.ProtectionProfile
+    localCrypto  *srtp.CryptoAttribute //the key of the packets sent
+    remoteCrypto *srtp.CryptoAttribute //the key of the packets received
+    srtpSend     *srtp.Context
+    srtpRecv     *srtp.Context
+    psStreams    map[RTSP_CODEC_ID]uint8 //the stream id of each es codec muxed into MP2P
 }
 
 type PacketCallBack func(b []byte, isRtcp bool) error
@@ -58,6 +97,85 @@ func WithDisableRtcpRR() TrackOption {
     }
 }
 
+// the packets received over udp are reordered by jitter buffer,
+// a missing packet is waited for up to delay or count packets
+func WithJitterBuffer(delay time.Duration, count int) TrackOption {
+    return func(t *RtspTrack) {
+        t.jitterDelay = delay
+        t.jitterCount = count
+    }
+}
+
+func WithDisableJitterBuffer() TrackOption {
+    return func(t *RtspTrack) {
+        t.noJitter = true
+    }
+}
+
+// the receiver sends NACK for the lost packets over udp,
+// the sender keeps the recently sent packets and retransmits them on NACK
+func WithNack() TrackOption {
+    return func(t *RtspTrack) {
+        t.nack = true
+    }
+}
+
+// the packets are retransmitted in rfc4588 rtx stream of payload type pt instead of the original stream
+func WithRtx(pt uint8) TrackOption {
+    return func(t *RtspTrack) {
+        t.nack = true
+        t.rtx = rtp.NewRtxPacker(pt, rand.Uint32(), uint16(rand.Uint32()))
+        t.recvRtx = true
+        t.rtxPt = pt
+    }
+}
+
+// the packets of payload type pt are rfc4588 retransmissions of the original stream,
+// they are restored before unpacking
+func withRecvRtx(pt uint8) TrackOption {
+    return func(t *RtspTrack) {
+        t.recvRtx = true
+        t.rtxPt = pt
+    }
+}
+
+// session bandwidth in bits per second for rtcp transmission interval,
+// rtcp.DEFAULT_SESSION_BANDWIDTH by default
+func WithSessionBandwidth(bandwidth int) TrackOption {
+    return func(t *RtspTrack) {
+        t.bandwidth = bandwidth
+    }
+}
+
+func WithCName(cname string) TrackOption {
+    return func(t *RtspTrack) {
+        t.cname = cname
+    }
+}
+
+// rfc8285 header extension,the extensions are announced by a=extmap,
+// abs-send-time and transport-cc are filled in the sent packets automatically
+func WithExtmap(id uint8, uri string) TrackOption {
+    return func(t *RtspTrack) {
+        t.extmap.Register(id, uri)
+    }
+}
+
+// onvif audio backchannel,the media is sendonly from the client to the server(a=sendonly)
+func WithBackchannel() TrackOption {
+    return func(t *RtspTrack) {
+        t.backchannel = true
+    }
+}
+
+// the packets are protected by srtp,the random key is announced by a=crypto of sdp,
+// the media of sdp is RTP/SAVP
+func WithSrtp(profile srtp.ProtectionProfile) TrackOption {
+    return func(t *RtspTrack) {
+        t.srtpProfile = profile
+    }
+}
+
 func NewVideoTrack(codec RtspCodec, opt ...TrackOption) *RtspTrack {
     return newTrack("video", codec, opt...)
 
@@ -76,26 +194,121 @@ func newTrack(name string, codec RtspCodec, opt ...TrackOption) *RtspTrack {
         Codec:        codec,
         initSequence: uint16(rand.Uint32()),
         autoSendRR:   true,
+        jitterDelay:  rtp.DEFAULT_JITTER_DELAY,
+        jitterCount:  rtp.DEFAULT_JITTER_PACKETS,
+        extmap:       rtp.NewExtensionMap(),
     }
     for _, o := range opt {
         o(track)
     }
     track.ssrc = rand.Uint32()
+    if track.srtpProfile != 0 {
+        if crypto, err := srtp.NewCryptoAttribute(1, track.srtpProfile); err == nil {
+            track.SetLocalCrypto(crypto)
+        }
+    }
+    if track.cname == "" {
+        track.cname = fmt.Sprintf("%08x@gomedia", track.ssrc)
+    }
     track.unpack = track.createUnpacker()
     track.pack = track.createPacker()
     track.sendCtx = rtcp.NewRtcpContext(track.ssrc, track.initSequence, track.Codec.SampleRate)
-    track.unpack.HookRtp(func(pkg *rtp.RtpPacket) {
+    track.onRtp = func(pkg *rtp.RtpPacket) {
         if track.recvCtx == nil {
             track.recvCtx = rtcp.NewRtcpContext(track.ssrc, pkg.Header.SequenceNumber, track.Codec.SampleRate)
+            if track.nack {
+                track.recvCtx.OnLoss(func(lost []uint16) {
+                    if track.isUdp() {
+                        track.SendNack()
+                    }
+                })
+            }
         }
         track.recvCtx.ReceivedRtp(pkg)
+        if track.scheduler != nil {
+            track.scheduler.ReceivedRtp(pkg.Header.SSRC)
+        }
+    }
+    track.unpack.HookRtp(func(pkg *rtp.RtpPacket) {
+        track.onRtp(pkg)
+        track.onReplayRtp(pkg)
     })
+    if track.nack {
+        track.rtxBuf = rtp.NewRetransmitBuffer(rtp.DEFAULT_RETRANSMIT_PACKETS)
+    }
     track.pack.HookRtp(func(pkg *rtp.RtpPacket) {
+        track.setExtensions(pkg)
+        if track.sendReplay != nil {
+            track.setReplay(pkg)
+        }
+        if track.onSendRtp != nil {
+            track.onSendRtp(pkg)
+        }
         track.sendCtx.SendRtp(pkg)
+        if track.rtxBuf != nil {
+            track.rtxBuf.Push(pkg.Header.SequenceNumber, pkg.Encode())
+        }
     })
     return track
 }
 
+func (track *RtspTrack) setExtensions(pkg *rtp.RtpPacket) {
+    if id := track.extmap.Id(rtp.EXTMAP_ABS_SEND_TIME); id > 0 {
+        pkg.SetAbsSendTime(id, time.Now())
+    }
+    if id := track.extmap.Id(rtp.EXTMAP_TRANSPORT_CC); id > 0 {
+        pkg.SetTransportSequence(id, track.twccSeq)
+        track.twccSeq++
+    }
+}
+
+// the replay extension is carried in the first packet of the frame,
+// it is rfc8285 element if a=extmap of onvif replay is negotiated,otherwise 0xABAC extension
+func (track *RtspTrack) setReplay(pkg *rtp.RtpPacket) {
+    if track.sendReplay.CSeq == 0 {
+        track.sendReplay.CSeq = track.replayCSeq
+    }
+    if id := track.extmap.Id(rtp.EXTMAP_ONVIF_REPLAY); id > 0 {
+        pkg.SetExtension(id, track.sendReplay.Encode())
+    } else {
+        pkg.SetOnvifReplay(track.sendReplay)
+    }
+    track.sendReplay = nil
+}
+
+// the packets are in order after jitter buffer
+func (track *RtspTrack) onReplayRtp(pkg *rtp.RtpPacket) {
+    if replay, ok := pkg.GetOnvifReplay(track.extmap.Id(rtp.EXTMAP_ONVIF_REPLAY)); ok {
+        track.recvReplay = replay
+    }
+}
+
+func (track *RtspTrack) IsBackchannel() bool {
+    return track.backchannel
+}
+
+// the negotiated header extensions,
+// e.g. track.Extmap().Id(rtp.EXTMAP_AUDIO_LEVEL) for RtpPacket.GetAudioLevel
+func (track *RtspTrack) Extmap() *rtp.ExtensionMap {
+    return track.extmap
+}
+
+// session level a=extmap is overridden by media level
+func (track *RtspTrack) loadExtmap(session []sdp.Extmap, media []sdp.Extmap) {
+    for _, extmap := range session {
+        track.extmap.Register(uint8(extmap.Id), extmap.Uri)
+    }
+    for _, extmap := range media {
+        track.extmap.Register(uint8(extmap.Id), extmap.Uri)
+    }
+}
+
+// the hook is called with every packet to be sent,
+// the header extensions can be set by RtpPacket.SetExtension
+func (track *RtspTrack) HookSendRtp(cb rtp.RTP_HOOK_FUNC) {
+    track.onSendRtp = cb
+}
+
 func (track *RtspTrack) EnableTCP() {
     track.transport = NewRtspTransport()
 }
@@ -104,6 +317,75 @@ func (track *RtspTrack) SetCodecParamHandle(handle sdp.FmtpCodecParamParser) {
     track.paramHandler = handle
 }
 
+func (track *RtspTrack) CodecParamHandler() sdp.FmtpCodecParamParser {
+    return track.paramHandler
+}
+
+// the media is announced with c=IN IP4 group/ttl and the rtp port in m=,
+// it is called before RtspServer.AddTrack,
+// the server replies the group in SETUP if the client requests multicast
+func (track *RtspTrack) SetMulticastGroup(group string, port uint16, ttl int) {
+    track.multicast = &sdp.Connection{Nettype: "IN", Addrtype: "IP4", Address: group, Ttl: ttl}
+    if strings.Contains(group, ":") {
+        track.multicast.Addrtype = "IP6"
+    }
+    track.mcastPort = port
+}
+
+func (track *RtspTrack) MulticastGroup() (group string, port uint16, ttl int) {
+    if track.multicast == nil {
+        return "", 0, 0
+    }
+    return track.multicast.Address, track.mcastPort, track.multicast.Ttl
+}
+
+// the key of the packets sent by the track,it is announced by a=crypto of sdp or KeyMgmt of SETUP,
+// the packets received are decrypted by it too if the peer does not announce its own key
+func (track *RtspTrack) SetLocalCrypto(crypto *srtp.CryptoAttribute) error {
+    ctx, err := crypto.NewContext()
+    if err != nil {
+        return err
+    }
+    track.localCrypto = crypto
+    track.srtpSend = ctx
+    if track.remoteCrypto == nil {
+        track.srtpRecv, _ = crypto.NewContext()
+    }
+    return nil
+}
+
+func (track *RtspTrack) LocalCrypto() *srtp.CryptoAttribute {
+    return track.localCrypto
+}
+
+// the key of the packets received by the track,a=crypto or KeyMgmt of the peer,
+// a fresh local key of the same profile is generated if it is not set,it is announced to the peer by KeyMgmt
+func (track *RtspTrack) SetRemoteCrypto(crypto *srtp.CryptoAttribute) error {
+    ctx, err := crypto.NewContext()
+    if err != nil {
+        return err
+    }
+    track.remoteCrypto = crypto
+    track.srtpRecv = ctx
+    if track.localCrypto == nil {
+        local, err := srtp.NewCryptoAttribute(crypto.Tag, crypto.Profile)
+        if err != nil {
+            return err
+        }
+        return track.SetLocalCrypto(local)
+    }
+    return nil
+}
+
+func (track *RtspTrack) RemoteCrypto() *srtp.CryptoAttribute {
+    return track.remoteCrypto
+}
+
+// the packets are protected by srtp
+func (track *RtspTrack) IsSecure() bool {
+    return track.srtpSend != nil
+}
+
 func (track *RtspTrack) GetTransport() *RtspTransport {
     return track.transport
 }
@@ -118,6 +400,10 @@ func (track *RtspTrack) SetExtraData(extra interface{}) {
 
 func (track *RtspTrack) OnSample(onsample OnSampleCallBack) {
     track.onSample = onsample
+    if unpacker, ok := track.unpack.(*rtp.PsEsUnPacker); ok {
+        track.onPsSample(unpacker)
+        return
+    }
     hasSps := false
     hasPps := false
     hasVps := false
@@ -127,7 +413,9 @@ func (track *RtspTrack) OnSample(onsample OnSampleCallBack) {
             Sample:    frame,
             Timestamp: timestamp, //uint32(uint64() * 1000 / uint64(track.Codec.SampleRate)),
             Completed: !lost,
+            Replay:    track.recvReplay,
         }
+        track.recvReplay = nil
         if sample.Cid == RTSP_CODEC_H264 {
             nalu_type := codec.H264NaluType(frame)
             switch nalu_type {
@@ -185,15 +473,81 @@ func (track *RtspTrack) OnSample(onsample OnSampleCallBack) {
     })
 }
 
+// the program stream is demuxed,the samples are the es frames with their own codec id,
+// the frames of the codecs unsupported by rtsp are dropped
+func (track *RtspTrack) onPsSample(unpacker *rtp.PsEsUnPacker) {
+    unpacker.OnEsFrameWithInfo(func(frame *mpeg2.PSFrame) {
+        cid, found := psStreamTypeToCodecId(frame.Cid)
+        if !found {
+            return
+        }
+        sample := RtspSample{
+            Cid:       cid,
+            Sample:    frame.Data,
+            Timestamp: uint32(frame.Dts * 90),
+            Completed: !frame.Damaged,
+            Replay:    track.recvReplay,
+        }
+        track.recvReplay = nil
+        track.onSample(sample)
+    })
+}
+
 func (track *RtspTrack) OnPacket(f PacketCallBack) {
-    track.onPacket = f
+    track.onPacket = func(b []byte, isRtcp bool) (err error) {
+        if track.srtpSend == nil {
+            return f(b, isRtcp)
+        }
+        if isRtcp {
+            b, err = track.srtpSend.ProtectRtcp(b)
+        } else {
+            b, err = track.srtpSend.ProtectRtp(b)
+        }
+        if err != nil {
+            return err
+        }
+        return f(b, isRtcp)
+    }
     track.pack.OnPacket(func(pkt []byte) error {
         return track.onPacket(pkt, false)
     })
 }
 
+// the replay extension is written if sample.Replay is not nil,
+// CSeq of it is filled by the server if it is zero
 func (track *RtspTrack) WriteSample(sample RtspSample) error {
-    return track.pack.Pack(sample.Sample, sample.Timestamp)
+    if sample.Replay != nil {
+        replay := *sample.Replay
+        track.sendReplay = &replay
+    }
+    var err error
+    if packer, ok := track.pack.(*rtp.PsEsPacker); ok && sample.Cid != RTSP_CODEC_PS {
+        err = track.writePsSample(packer, sample)
+    } else {
+        err = track.pack.Pack(sample.Sample, sample.Timestamp)
+    }
+    track.sendReplay = nil
+    return err
+}
+
+// the es samples delivered by onPsSample are muxed into program stream again,
+// the sample of RTSP_CODEC_PS is the program stream itself and packed as it is,
+// timestamp is 90kHz
+func (track *RtspTrack) writePsSample(packer *rtp.PsEsPacker, sample RtspSample) error {
+    sid, found := track.psStreams[sample.Cid]
+    if !found {
+        streamType, ok := codecIdToPsStreamType(sample.Cid)
+        if !ok {
+            return fmt.Errorf("unsupport codec %d in ps stream", sample.Cid)
+        }
+        if track.psStreams == nil {
+            track.psStreams = make(map[RTSP_CODEC_ID]uint8)
+        }
+        sid = packer.AddStream(streamType)
+        track.psStreams[sample.Cid] = sid
+    }
+    ts := uint64(sample.Timestamp / 90)
+    return packer.WriteFrame(sid, sample.Sample, ts, ts)
 }
 
 func (track *RtspTrack) OpenTrack() {
@@ -208,20 +562,106 @@ func (track *RtspTrack) GetRtcpRecvContext() *rtcp.RtcpContext {
     return track.recvCtx
 }
 
+// compound packet of SR and SDES CNAME,
+// the reception report of the received stream is included in SR
 func (track *RtspTrack) SendReport() error {
     sr := track.sendCtx.GenerateSR()
-    return track.onPacket(sr.Encode(), true)
+    if track.recvCtx != nil {
+        sr.Blocks = append(sr.Blocks, track.recvCtx.GenerateRR().Blocks...)
+        sr.RC = uint8(len(sr.Blocks))
+    }
+    return track.sendCompound(time.Now(), sr)
 }
 
+// compound packet of RR and SDES CNAME
 func (track *RtspTrack) ReceiveReport() error {
-    fmt.Println("ReceiveReport")
-    rr := track.recvCtx.GenerateRR()
-    return track.onPacket(rr.Encode(), true)
+    return track.sendCompound(time.Now(), track.receiverReport())
+}
+
+func (track *RtspTrack) receiverReport() *rtcp.ReceiverReport {
+    if track.recvCtx == nil {
+        //empty RR is the first packet of compound packet
+        return &rtcp.ReceiverReport{Comm: rtcp.Comm{PT: rtcp.RTCP_RR}, SSRC: track.ssrc}
+    }
+    return track.recvCtx.GenerateRR()
 }
 
 func (track *RtspTrack) Bye() error {
-    bye := track.sendCtx.GenerateBye()
-    return track.onPacket(bye.Encode(), true)
+    return track.sendCompound(time.Now(), track.receiverReport(), track.sendCtx.GenerateBye())
+}
+
+// report is SR or RR,SDES CNAME is inserted after it
+func (track *RtspTrack) sendCompound(now time.Time, report rtcp.Packet, pkts ...rtcp.Packet) error {
+    if track.onPacket == nil {
+        return nil
+    }
+    sdes := track.sendCtx.GenerateSDES(rtcp.SDES_CNAME, track.cname)
+    data := rtcp.EncodeCompound(append([]rtcp.Packet{report, sdes}, pkts...)...)
+    if track.scheduler != nil {
+        track.scheduler.Sent(len(data), now)
+    }
+    return track.onPacket(data, true)
+}
+
+// the rfc3550 rtcp scheduler without timer,call it periodically(e.g. every 100ms) with the current time,
+// SR or RR is sent when the randomized transmission interval expires
+func (track *RtspTrack) PollReport(now time.Time) error {
+    if track.scheduler == nil {
+        track.scheduler = rtcp.NewRtcpScheduler(track.ssrc, track.bandwidth, now)
+        return nil
+    }
+    sent := track.sendCtx.SentPackets()
+    track.scheduler.SetSender(sent > track.lastSent)
+    if !track.scheduler.Poll(now) {
+        return nil
+    }
+    if sent > track.lastSent {
+        track.lastSent = sent
+        sr := track.sendCtx.GenerateSR()
+        if track.recvCtx != nil {
+            sr.Blocks = append(sr.Blocks, track.recvCtx.GenerateRR().Blocks...)
+            sr.RC = uint8(len(sr.Blocks))
+        }
+        return track.sendCompound(now, sr)
+    }
+    return track.sendCompound(now, track.receiverReport())
+}
+
+// the time of the next report,zero before the first PollReport
+func (track *RtspTrack) NextReportTime() time.Time {
+    if track.scheduler == nil {
+        return time.Time{}
+    }
+    return track.scheduler.NextTime()
+}
+
+// send NACK of the lost packets
+func (track *RtspTrack) SendNack() error {
+    if track.recvCtx == nil || track.onPacket == nil {
+        return nil
+    }
+    nack := track.recvCtx.GenerateNack()
+    if nack == nil {
+        return nil
+    }
+    return track.onPacket(nack.Encode(), true)
+}
+
+// send PLI to ask the sender for a key frame
+func (track *RtspTrack) RequestKeyFrame() error {
+    if track.recvCtx == nil || track.onPacket == nil {
+        return nil
+    }
+    return track.onPacket(track.recvCtx.GeneratePli().Encode(), true)
+}
+
+// called when PLI or FIR is received,the sender should encode a key frame
+func (track *RtspTrack) OnKeyFrameRequest(f func()) {
+    track.onKeyFrame = f
+}
+
+func (track *RtspTrack) isUdp() bool {
+    return track.transport != nil && track.transport.Proto == UDP
 }
 
 func (track *RtspTrack) SourceDescription(sdesType uint8, content string) error {
@@ -230,41 +670,188 @@ func (track *RtspTrack) SourceDescription(sdesType uint8, content string) error
 }
 
 func (track *RtspTrack) mediaDescripe() string {
-    md := fmt.Sprintf("m=%s 0 RTP/AVP %d\r\n", track.TrackName, track.Codec.PayloadType)
+    proto := "RTP/AVP"
+    if track.localCrypto != nil {
+        proto = "RTP/SAVP"
+    }
+    md := fmt.Sprintf("m=%s %d %s %d", track.TrackName, track.mcastPort, proto, track.Codec.PayloadType)
+    if track.rtx != nil {
+        md += fmt.Sprintf(" %d", track.rtxPt)
+    }
+    md += "\r\n"
+    if track.multicast != nil {
+        md += "c=" + track.multicast.Encode() + "\r\n"
+    }
     md += fmt.Sprintf("a=control:%s\r\n", track.uri)
-    if track.TrackName != "audio" {
-        md += fmt.Sprintf("a=rtpmap:%d %s/%d\r\n", track.Codec.PayloadType, GetEncodeNameByCodecId(track.Codec.Cid), track.Codec.SampleRate)
-    } else {
-        md += fmt.Sprintf("a=rtpmap:%d %s/%d/%d\r\n", track.Codec.PayloadType, GetEncodeNameByCodecId(track.Codec.Cid), track.Codec.SampleRate, track.Codec.ChannelCount)
+    //the static payload type can be described without rtpmap
+    if name, err := GetEncodeNameByCodecId(track.Codec.Cid); err == nil {
+        switch {
+        case track.Codec.Cid == RTSP_CODEC_OPUS:
+            //rfc7587,the clock rate is 48000 and the channel count is 2 even if the stream is mono
+            md += fmt.Sprintf("a=rtpmap:%d %s/48000/2\r\n", track.Codec.PayloadType, name)
+        case track.TrackName != "audio" || track.Codec.ChannelCount == 0:
+            md += fmt.Sprintf("a=rtpmap:%d %s/%d\r\n", track.Codec.PayloadType, name, track.Codec.SampleRate)
+        default:
+            md += fmt.Sprintf("a=rtpmap:%d %s/%d/%d\r\n", track.Codec.PayloadType, name, track.Codec.SampleRate, track.Codec.ChannelCount)
+        }
     }
     if track.paramHandler != nil {
         md += fmt.Sprintf("a=fmtp:%d %s\r\n", track.Codec.PayloadType, track.paramHandler.Save())
     }
+    if track.rtx != nil {
+        md += fmt.Sprintf("a=rtpmap:%d rtx/%d\r\n", track.rtxPt, track.Codec.SampleRate)
+        md += fmt.Sprintf("a=fmtp:%d apt=%d\r\n", track.rtxPt, track.Codec.PayloadType)
+    }
+    if track.backchannel {
+        md += "a=sendonly\r\n"
+    }
+    for _, id := range track.extmap.Ids() {
+        md += fmt.Sprintf("a=extmap:%d %s\r\n", id, track.extmap.Uri(id))
+    }
+    if track.localCrypto != nil {
+        md += "a=crypto:" + track.localCrypto.Encode() + "\r\n"
+    }
     return md
 }
 
 func (track *RtspTrack) Input(data []byte, isRtcp bool) error {
-    //TODO
+    return track.InputAt(data, isRtcp, time.Now())
+}
+
+// now is the arrival time of the packet,the clock of the caller as PollReport
+func (track *RtspTrack) InputAt(data []byte, isRtcp bool, now time.Time) (err error) {
+    if track.srtpRecv != nil {
+        if isRtcp {
+            data, err = track.srtpRecv.UnprotectRtcp(data)
+        } else {
+            data, err = track.srtpRecv.UnprotectRtp(data)
+        }
+        if err != nil {
+            return err
+        }
+    }
     if isRtcp {
-        return track.inputRtcp(data)
+        return track.inputRtcp(data, now)
+    }
+    if track.recvRtx {
+        if data, err = track.restoreRtx(data); data == nil {
+            return err
+        }
+    }
+    if track.isUdp() && !track.noJitter {
+        if track.jitter == nil {
+            //rtcp statistics are collected when the packet arrives
+            track.jitter = rtp.NewJitterBuffer(track.unpack)
+            track.jitter.SetMaxDelay(track.jitterDelay)
+            track.jitter.SetMaxPackets(track.jitterCount)
+            track.jitter.HookRtp(track.onRtp)
+            track.unpack.HookRtp(track.onReplayRtp)
+        }
+        return track.jitter.UnPack(data)
     }
     return track.unpack.UnPack(data)
 }
 
-func (track *RtspTrack) inputRtcp(data []byte) error {
-    pkt := rtcp.Comm{}
-    pkt.Decode(data)
-    switch pkt.PT {
-    case rtcp.RTCP_SR:
-        sr := rtcp.NewSenderReport()
-        sr.Decode(data)
-        if track.recvCtx != nil {
-            track.recvCtx.ReceivedSR(sr)
-            if track.autoSendRR {
-                track.ReceiveReport()
+// the rtx packet is restored to the original packet,nil if it is dropped
+func (track *RtspTrack) restoreRtx(data []byte) ([]byte, error) {
+    hdr := rtp.RtpHdr{}
+    if _, err := hdr.Decode(data); err != nil {
+        return nil, err
+    }
+    if hdr.PayloadType != track.rtxPt {
+        track.mediaSsrc = hdr.SSRC
+        track.hasMediaSsrc = true
+        return data, nil
+    }
+    if !track.hasMediaSsrc {
+        //the original stream is unknown yet
+        return nil, nil
+    }
+    return rtp.UnpackRtx(data, track.Codec.PayloadType, track.mediaSsrc)
+}
+
+// unpack the packets held by jitter buffer,e.g. at the end of stream
+func (track *RtspTrack) FlushJitterBuffer() error {
+    if track.jitter == nil {
+        return nil
+    }
+    return track.jitter.Flush()
+}
+
+// unpack the packets held by jitter buffer longer than the jitter delay at now,
+// call it periodically(e.g. with PollReport),otherwise the tail of a stream that stops is held
+func (track *RtspTrack) PollJitterBuffer(now time.Time) error {
+    if track.jitter == nil {
+        return nil
+    }
+    return track.jitter.Poll(now)
+}
+
+func (track *RtspTrack) GetJitterStats() rtp.JitterStats {
+    if track.jitter == nil {
+        return rtp.JitterStats{}
+    }
+    return track.jitter.Stats()
+}
+
+// the compound packet is split and each packet is handled in order
+func (track *RtspTrack) inputRtcp(data []byte, now time.Time) error {
+    pkts, err := rtcp.DecodeCompound(data)
+    for _, pkt := range pkts {
+        switch p := pkt.(type) {
+        case *rtcp.SenderReport:
+            if track.scheduler != nil {
+                track.scheduler.ReceivedRtcp(p.SSRC, len(data))
+            }
+            if track.recvCtx != nil {
+                track.recvCtx.ReceivedSR(p)
+                if track.autoSendRR {
+                    track.ReceiveReport()
+                }
+            }
+        case *rtcp.ReceiverReport:
+            if track.scheduler != nil {
+                track.scheduler.ReceivedRtcp(p.SSRC, len(data))
+            }
+        case *rtcp.Bye:
+            if track.scheduler != nil {
+                for _, ssrc := range p.SSRCS {
+                    track.scheduler.ReceivedBye(ssrc, now)
+                }
+            }
+        case *rtcp.Nack:
+            if err := track.retransmit(p.Sequences()); err != nil {
+                return err
+            }
+        case *rtcp.Pli, *rtcp.Fir:
+            if track.onKeyFrame != nil {
+                track.onKeyFrame()
             }
         }
     }
+    return err
+}
+
+func (track *RtspTrack) retransmit(seqs []uint16) error {
+    if track.rtxBuf == nil || track.onPacket == nil {
+        return nil
+    }
+    for _, seq := range seqs {
+        pkt := track.rtxBuf.Get(seq)
+        if pkt == nil {
+            continue
+        }
+        if track.rtx != nil {
+            rtx, err := track.rtx.Pack(pkt)
+            if err != nil {
+                return err
+            }
+            pkt = rtx
+        }
+        if err := track.onPacket(pkt, false); err != nil {
+            return err
+        }
+    }
     return nil
 }
 
@@ -281,8 +868,26 @@ func (track *RtspTrack) createUnpacker() rtp.UnPacker {
         } else {
             return rtp.NewAACUnPacker(13, 3, nil)
         }
-    case RTSP_CODEC_G711A, RTSP_CODEC_G711U:
+    case RTSP_CODEC_AAC_LATM:
+        if latmFmtp, ok := track.paramHandler.(*sdp.LATMFmtpParam); ok {
+            return rtp.NewLATMUnPacker(latmFmtp.CPresent(), latmFmtp.StreamMuxConfig())
+        } else {
+            return rtp.NewLATMUnPacker(true, nil)
+        }
+    case RTSP_CODEC_G711A, RTSP_CODEC_G711U, RTSP_CODEC_G722:
         return rtp.NewG711UnPacker()
+    case RTSP_CODEC_OPUS:
+        return rtp.NewOpusUnPacker()
+    case RTSP_CODEC_MPA:
+        return rtp.NewMpaUnPacker()
+    case RTSP_CODEC_MP3:
+        return rtp.NewMpaRobustUnPacker()
+    case RTSP_CODEC_L16, RTSP_CODEC_L24:
+        return rtp.NewPcmUnPacker()
+    case RTSP_CODEC_MJPEG:
+        return rtp.NewJpegUnPacker()
+    case RTSP_CODEC_PS:
+        return rtp.NewPsEsUnPacker()
     case RTSP_CODEC_TS:
         return rtp.NewTsUnPacker()
     }
@@ -293,14 +898,28 @@ func (track *RtspTrack) createPacker() rtp.Packer {
     switch track.Codec.Cid {
     case RTSP_CODEC_AAC:
         return rtp.NewAACPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
+    case RTSP_CODEC_AAC_LATM:
+        return rtp.NewLATMPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
     case RTSP_CODEC_H264:
         return rtp.NewH264Packer(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
     case RTSP_CODEC_H265:
         return rtp.NewH265Packer(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
-    case RTSP_CODEC_G711U, RTSP_CODEC_G711A:
+    case RTSP_CODEC_G711U, RTSP_CODEC_G711A, RTSP_CODEC_G722:
         return rtp.NewG711Packer(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
+    case RTSP_CODEC_OPUS:
+        return rtp.NewOpusPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
+    case RTSP_CODEC_MPA:
+        return rtp.NewMpaPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
+    case RTSP_CODEC_MP3:
+        return rtp.NewMpaRobustPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
+    case RTSP_CODEC_L16:
+        return rtp.NewPcmPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400, 2, int(track.Codec.ChannelCount))
+    case RTSP_CODEC_L24:
+        return rtp.NewPcmPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400, 3, int(track.Codec.ChannelCount))
+    case RTSP_CODEC_MJPEG:
+        return rtp.NewJpegPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
     case RTSP_CODEC_PS:
-        return nil
+        return rtp.NewPsEsPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
     case RTSP_CODEC_TS:
         return rtp.NewTsPacker(track.Codec.PayloadType, track.ssrc, track.initSequence, 1400)
     default:
@@ -0,0 +1,214 @@
+package rtsp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yapingcat/gomedia/go-mpeg2"
+	"github.com/yapingcat/gomedia/go-rtsp/rtcp"
+	"github.com/yapingcat/gomedia/go-rtsp/rtp"
+	"github.com/yapingcat/gomedia/go-rtsp/sdp"
+	"github.com/yapingcat/gomedia/go-rtsp/srtp"
+)
+
+func TestRtspTrack_PsSample(t *testing.T) {
+	idr := append([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 3000)...)
+	adts := append([]byte{0xFF, 0xF1, 0x50, 0x80, 0x14, 0x7F, 0xFC}, bytes.Repeat([]byte{0x22}, 156)...)
+	packer := rtp.NewPsEsPacker(96, 0x1234, 0, 1000)
+	video := packer.AddStream(mpeg2.PS_STREAM_H264)
+	audio := packer.AddStream(mpeg2.PS_STREAM_AAC)
+	track := NewVideoTrack(RtspCodec{Cid: RTSP_CODEC_PS, PayloadType: 96, SampleRate: 90000})
+	packer.OnPacket(func(pkt []byte) error {
+		return track.Input(pkt, false)
+	})
+	var samples []RtspSample
+	track.OnSample(func(sample RtspSample) {
+		sample.Sample = append([]byte{}, sample.Sample...)
+		samples = append(samples, sample)
+	})
+	packer.WriteFrame(video, idr, 0, 0)
+	packer.WriteFrame(audio, adts, 20, 20)
+	packer.WriteFrame(video, idr, 40, 40)
+	packer.WriteFrame(audio, adts, 60, 60)
+	//the frame is delivered when the next frame of the stream begins
+	packer.WriteFrame(video, idr, 80, 80)
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+	want := []RtspSample{
+		{Cid: RTSP_CODEC_H264, Sample: idr, Timestamp: 0},
+		{Cid: RTSP_CODEC_AAC, Sample: adts, Timestamp: 20 * 90},
+		{Cid: RTSP_CODEC_H264, Sample: idr, Timestamp: 40 * 90},
+	}
+	for i, sample := range samples {
+		if sample.Cid != want[i].Cid || sample.Timestamp != want[i].Timestamp || !sample.Completed || !bytes.Equal(sample.Sample, want[i].Sample) {
+			t.Errorf("sample %d = %v %d %v, want %v %d", i, sample.Cid, sample.Timestamp, sample.Completed, want[i].Cid, want[i].Timestamp)
+		}
+	}
+}
+
+func TestRtspTrack_PsRelay(t *testing.T) {
+	idr := append([]byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}, bytes.Repeat([]byte{0x11}, 3000)...)
+	adts := append([]byte{0xFF, 0xF1, 0x50, 0x80, 0x14, 0x7F, 0xFC}, bytes.Repeat([]byte{0x22}, 156)...)
+	packer := rtp.NewPsEsPacker(96, 0x1234, 0, 1000)
+	video := packer.AddStream(mpeg2.PS_STREAM_H264)
+	audio := packer.AddStream(mpeg2.PS_STREAM_AAC)
+	recv := NewVideoTrack(RtspCodec{Cid: RTSP_CODEC_PS, PayloadType: 96, SampleRate: 90000})
+	send := NewVideoTrack(RtspCodec{Cid: RTSP_CODEC_PS, PayloadType: 96, SampleRate: 90000})
+	packer.OnPacket(func(pkt []byte) error {
+		return recv.Input(pkt, false)
+	})
+	var relayErr error
+	recv.OnSample(func(sample RtspSample) {
+		if err := send.WriteSample(sample); err != nil {
+			relayErr = err
+		}
+	})
+	//the relayed program stream is decoded by another PsEsUnPacker
+	unpacker := rtp.NewPsEsUnPacker()
+	send.OnPacket(func(b []byte, isRtcp bool) error {
+		if isRtcp {
+			return nil
+		}
+		return unpacker.UnPack(b)
+	})
+	var samples []RtspSample
+	unpacker.OnEsFrameWithInfo(func(frame *mpeg2.PSFrame) {
+		cid, _ := psStreamTypeToCodecId(frame.Cid)
+		samples = append(samples, RtspSample{Cid: cid, Sample: append([]byte{}, frame.Data...), Timestamp: uint32(frame.Dts * 90), Completed: !frame.Damaged})
+	})
+	for i := uint64(0); i < 5; i++ {
+		packer.WriteFrame(video, idr, i*40, i*40)
+		packer.WriteFrame(audio, adts, i*40+20, i*40+20)
+	}
+	if relayErr != nil {
+		t.Fatal(relayErr)
+	}
+	unpacker.Flush()
+	//the frames of each stream keep their order and timestamps
+	var videos, audios int
+	for _, sample := range samples {
+		want, ts := idr, uint32(videos*40*90)
+		if sample.Cid == RTSP_CODEC_AAC {
+			want, ts = adts, uint32((audios*40+20)*90)
+			audios++
+		} else {
+			videos++
+		}
+		if !sample.Completed || sample.Timestamp != ts || !bytes.Equal(sample.Sample, want) {
+			t.Errorf("sample %v = %d %v, want %d", sample.Cid, sample.Timestamp, sample.Completed, ts)
+		}
+	}
+	if videos < 3 || audios < 3 {
+		t.Fatalf("got %d video and %d audio samples", videos, audios)
+	}
+}
+
+func TestRtspTrack_RtxInput(t *testing.T) {
+	codec := NewAudioCodec("PCMA", 8, 8000, 1)
+	sender := NewAudioTrack(codec, WithRtx(97))
+	media := &sdp.Media{}
+	if err := media.Decode(sender.mediaDescripe()); err != nil {
+		t.Fatalf("Media.Decode() error = %v", err)
+	}
+	rtpMap, _ := media.RtpMap(8)
+	track := newMediaTrack(media, rtpMap)
+	if track == nil || !track.recvRtx || track.rtxPt != 97 {
+		t.Fatalf("the rtx payload type of %q is not found", sender.mediaDescripe())
+	}
+	var samples [][]byte
+	track.OnSample(func(sample RtspSample) {
+		samples = append(samples, append([]byte{}, sample.Sample...))
+	})
+	makePacket := func(seq uint16) []byte {
+		pkg := rtp.RtpPacket{Payload: []byte{byte(seq), byte(seq)}}
+		pkg.Header.PayloadType = 8
+		pkg.Header.SSRC = 0x11111111
+		pkg.Header.SequenceNumber = seq
+		pkg.Header.Timestamp = uint32(seq) * 160
+		return pkg.Encode()
+	}
+	rtxPacker := rtp.NewRtxPacker(97, 0x22222222, 1000)
+	makeRtx := func(seq uint16) []byte {
+		rtx, err := rtxPacker.Pack(makePacket(seq))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rtx
+	}
+	//the retransmission before the original stream is dropped
+	inputs := [][]byte{makeRtx(1), makePacket(1), makeRtx(2), makePacket(3)}
+	for i, pkt := range inputs {
+		if err := track.Input(pkt, false); err != nil {
+			t.Fatalf("Input(%d) error = %v", i, err)
+		}
+	}
+	want := [][]byte{{1, 1}, {2, 2}, {3, 3}}
+	if len(samples) < 2 || len(samples) > len(want) {
+		t.Fatalf("got %d samples %x, want %x", len(samples), samples, want)
+	}
+	for i, sample := range samples {
+		if !bytes.Equal(sample, want[i]) {
+			t.Errorf("sample %d = %x, want %x", i, sample, want[i])
+		}
+	}
+}
+
+func TestRtspTrack_ByeClock(t *testing.T) {
+	codec := NewAudioCodec("PCMA", 8, 8000, 1)
+	track := NewAudioTrack(codec)
+	track.OnPacket(func(b []byte, isRtcp bool) error {
+		return nil
+	})
+	t0 := time.Unix(1700000000, 0)
+	track.PollReport(t0)
+	rr := &rtcp.ReceiverReport{Comm: rtcp.Comm{PT: rtcp.RTCP_RR}, SSRC: 0x22222222}
+	if err := track.InputAt(rr.Encode(), true, t0); err != nil {
+		t.Fatalf("InputAt(rr) error = %v", err)
+	}
+	//the first report is sent within 1.5*2.5s/(e-1.5)
+	sent := t0.Add(4 * time.Second)
+	track.PollReport(sent)
+	tn := track.NextReportTime()
+	if !tn.After(sent) {
+		t.Fatalf("NextReportTime() = %v, want after %v", tn, sent)
+	}
+	//reverse reconsideration at the arrival time of bye
+	now := sent.Add(time.Second)
+	bye := &rtcp.Bye{Comm: rtcp.Comm{PT: rtcp.RTCP_BYE}, SC: 1, SSRCS: []uint32{0x22222222}}
+	if err := track.InputAt(bye.Encode(), true, now); err != nil {
+		t.Fatalf("InputAt(bye) error = %v", err)
+	}
+	want := now.Add(time.Duration(float64(tn.Sub(now)) / 2))
+	if got := track.NextReportTime(); !got.Equal(want) {
+		t.Errorf("NextReportTime() = %v, want %v", got, want)
+	}
+}
+
+func TestRtspTrack_SetRemoteCrypto(t *testing.T) {
+	remote, err := srtp.NewCryptoAttribute(1, srtp.PROFILE_AES128_CM_HMAC_SHA1_80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	track := NewAudioTrack(NewAudioCodec("PCMA", 8, 8000, 1))
+	if err = track.SetRemoteCrypto(remote); err != nil {
+		t.Fatal(err)
+	}
+	//the packets sent are not protected by the key of the peer
+	local := track.LocalCrypto()
+	if local == nil || local.Profile != remote.Profile || local.Tag != remote.Tag || bytes.Equal(local.MasterKey, remote.MasterKey) {
+		t.Fatalf("LocalCrypto() = %+v", local)
+	}
+	if !strings.Contains(track.mediaDescripe(), "a=crypto:"+local.Encode()+"\r\n") {
+		t.Errorf("the local key is not announced:\n%s", track.mediaDescripe())
+	}
+	//the key set by the user is kept
+	track = NewAudioTrack(NewAudioCodec("PCMA", 8, 8000, 1))
+	track.SetLocalCrypto(local)
+	track.SetRemoteCrypto(remote)
+	if track.LocalCrypto() != local {
+		t.Error("the local key is replaced")
+	}
+}
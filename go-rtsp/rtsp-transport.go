@@ -2,6 +2,8 @@ package rtsp
 
 import (
     "fmt"
+    "net"
+    "strconv"
     "strings"
 )
 
@@ -24,6 +26,12 @@ type RtspTransport struct {
     Server_ports [2]uint16
     Interleaved  [2]int
     mode         string
+    Destination  string    //multicast group
+    Source       string    //the address of sender
+    Ports        [2]uint16 //rtp/rtcp port of multicast group
+    Ttl          int       //multicast time-to-live
+    version      int       //RTSP_2_0 uses dest_addr/src_addr instead of the ports
+    Secure       bool      //RTP/SAVP,the packets are protected by srtp
 }
 
 type TransportOption func(transport *RtspTransport)
@@ -34,6 +42,20 @@ func WithEnableUdp() TransportOption {
     }
 }
 
+// the client requests multicast,the group is chosen by the server
+func WithEnableMulticast() TransportOption {
+    return func(transport *RtspTransport) {
+        transport.Proto = UDP
+        transport.IsMultiCast = true
+    }
+}
+
+func WithMulticastGroup(destination string, rtpPort uint16, rtcpPort uint16, ttl int) TransportOption {
+    return func(transport *RtspTransport) {
+        transport.SetMulticastGroup(destination, rtpPort, rtcpPort, ttl)
+    }
+}
+
 func WithClientUdpPort(rtpPort uint16, rtcpPort uint16) TransportOption {
     return func(transport *RtspTransport) {
         transport.Client_ports[0] = rtpPort
@@ -74,8 +96,8 @@ func NewRtspTransport(opt ...TransportOption) *RtspTransport {
 }
 
 func (transport *RtspTransport) SetServerUdpPort(rtpPort uint16, rtcpPort uint16) {
-    transport.Client_ports[0] = rtpPort
-    transport.Client_ports[1] = rtcpPort
+    transport.Server_ports[0] = rtpPort
+    transport.Server_ports[1] = rtcpPort
 }
 
 func (transport *RtspTransport) SetClientUdpPort(rtpPort uint16, rtcpPort uint16) {
@@ -83,6 +105,25 @@ func (transport *RtspTransport) SetClientUdpPort(rtpPort uint16, rtcpPort uint16
     transport.Client_ports[1] = rtcpPort
 }
 
+func (transport *RtspTransport) SetMulticastGroup(destination string, rtpPort uint16, rtcpPort uint16, ttl int) {
+    transport.Proto = UDP
+    transport.IsMultiCast = true
+    transport.Destination = destination
+    transport.Ports[0] = rtpPort
+    transport.Ports[1] = rtcpPort
+    transport.Ttl = ttl
+}
+
+// the syntax of Transport changes in rtsp 2.0,it is set by the client/server after negotiation
+func (transport *RtspTransport) SetVersion(version int) {
+    transport.version = version
+}
+
+// the group to join and the rtp/rtcp ports,destination is empty if the server does not reply it
+func (transport *RtspTransport) MulticastGroup() (destination string, rtpPort uint16, rtcpPort uint16) {
+    return transport.Destination, transport.Ports[0], transport.Ports[1]
+}
+
 func (transport *RtspTransport) SetInterleaved(interleaved [2]int) {
     transport.Interleaved[0] = interleaved[0]
     transport.Interleaved[1] = interleaved[1]
@@ -90,43 +131,113 @@ func (transport *RtspTransport) SetInterleaved(interleaved [2]int) {
 
 // Transport: RTP/AVP;multicast;ttl=127;mode="PLAY",
 //            RTP/AVP;unicast;client_port=3456-3457;mode="PLAY"
+//            RTP/AVP;multicast;destination=224.2.0.1;port=3456-3457;ttl=16
+// rtsp 2.0:  RTP/AVP/UDP;unicast;dest_addr=":4588"/":4589";src_addr="192.0.2.5:6256"/"192.0.2.5:6257"
+//            RTP/AVP/UDP;multicast;dest_addr="224.2.0.1:3456"/"224.2.0.1:3457";ttl=16
 
 func (transport *RtspTransport) Decode(data []byte) error {
     return transport.DecodeString(string(data))
 }
 
+// only the first transport-spec is decoded if several are offered,
+// the server decodes all of them by DecodeTransports
 func (transport *RtspTransport) DecodeString(data string) error {
+    if specs := splitTransportSpecs(data); len(specs) > 0 {
+        data = specs[0]
+    }
     items := strings.Split(data, ";")
     for _, item := range items {
-        kv := strings.Split(item, "=")
+        kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
         switch kv[0] {
         case "RTP/AVP/TCP":
             transport.Proto = TCP
         case "RTP/AVP", "RTP/AVP/UDP":
             transport.Proto = UDP
+        case "RTP/SAVP/TCP":
+            transport.Proto = TCP
+            transport.Secure = true
+        case "RTP/SAVP", "RTP/SAVP/UDP":
+            transport.Proto = UDP
+            transport.Secure = true
         case "multicast":
             transport.IsMultiCast = true
         case "unicast":
             transport.IsMultiCast = false
         case "mode":
-            transport.mode = kv[1]
+            transport.mode = strings.Trim(kv[1], "\"")
         case "client_port":
             fmt.Sscanf(kv[1], "%d-%d", &transport.Client_ports[0], &transport.Client_ports[1])
         case "server_port":
             fmt.Sscanf(kv[1], "%d-%d", &transport.Server_ports[0], &transport.Server_ports[1])
         case "interleaved":
             fmt.Sscanf(kv[1], "%d-%d", &transport.Interleaved[0], &transport.Interleaved[1])
+        case "destination":
+            if len(kv) > 1 {
+                transport.Destination = kv[1]
+            }
+        case "source":
+            if len(kv) > 1 {
+                transport.Source = kv[1]
+            }
+        case "port":
+            n, _ := fmt.Sscanf(kv[1], "%d-%d", &transport.Ports[0], &transport.Ports[1])
+            if n == 1 {
+                transport.Ports[1] = transport.Ports[0] + 1
+            }
+        case "ttl":
+            fmt.Sscanf(kv[1], "%d", &transport.Ttl)
+        case "dest_addr":
+            if len(kv) < 2 {
+                continue
+            }
+            transport.version = RTSP_2_0
+            if transport.IsMultiCast {
+                transport.Destination = parseAddrList(kv[1], &transport.Ports)
+            } else {
+                transport.Destination = parseAddrList(kv[1], &transport.Client_ports)
+            }
+        case "src_addr":
+            if len(kv) < 2 {
+                continue
+            }
+            transport.version = RTSP_2_0
+            transport.Source = parseAddrList(kv[1], &transport.Server_ports)
         }
     }
     return nil
 }
 
+// the transport-specs of SETUP request in the order of preference of the client
+func DecodeTransports(data string) []*RtspTransport {
+    var transports []*RtspTransport
+    for _, spec := range splitTransportSpecs(data) {
+        transport := NewRtspTransport()
+        transport.DecodeString(spec)
+        transports = append(transports, transport)
+    }
+    return transports
+}
+
+// the transport-specs are separated by ',',which may appear in the quoted value
+func splitTransportSpecs(data string) []string {
+    var specs []string
+    for _, spec := range splitQuoted(data, ',') {
+        if spec != "" {
+            specs = append(specs, spec)
+        }
+    }
+    return specs
+}
+
 func (transport *RtspTransport) EncodeString() string {
-    str := ""
+    str := "RTP/AVP"
+    if transport.Secure {
+        str = "RTP/SAVP"
+    }
     if transport.Proto == TCP {
-        str += "RTP/AVP/TCP"
+        str += "/TCP"
     } else {
-        str += "RTP/AVP/UDP"
+        str += "/UDP"
     }
     if transport.IsMultiCast {
         str += ";multicast"
@@ -136,6 +247,35 @@ func (transport *RtspTransport) EncodeString() string {
 
     if transport.Proto == TCP {
         str += fmt.Sprintf(";interleaved=%d-%d", transport.Interleaved[0], transport.Interleaved[1])
+    } else if transport.version == RTSP_2_0 {
+        if transport.IsMultiCast {
+            if transport.Destination != "" && transport.Ports[0] != 0 {
+                str += ";dest_addr=" + encodeAddrList(transport.Destination, transport.Ports)
+            }
+            if transport.Ttl > 0 {
+                str += fmt.Sprintf(";ttl=%d", transport.Ttl)
+            }
+        } else {
+            if transport.Client_ports[0] != 0 {
+                str += ";dest_addr=" + encodeAddrList(transport.Destination, transport.Client_ports)
+            }
+            if transport.Server_ports[0] != 0 {
+                str += ";src_addr=" + encodeAddrList(transport.Source, transport.Server_ports)
+            }
+        }
+    } else if transport.IsMultiCast {
+        if transport.Destination != "" {
+            str += ";destination=" + transport.Destination
+        }
+        if transport.Source != "" {
+            str += ";source=" + transport.Source
+        }
+        if transport.Ports[0] != 0 {
+            str += fmt.Sprintf(";port=%d-%d", transport.Ports[0], transport.Ports[1])
+        }
+        if transport.Ttl > 0 {
+            str += fmt.Sprintf(";ttl=%d", transport.Ttl)
+        }
     } else {
         if transport.Client_ports[0] != 0 {
             str += fmt.Sprintf(";client_port=%d-%d", transport.Client_ports[0], transport.Client_ports[1])
@@ -145,10 +285,37 @@ func (transport *RtspTransport) EncodeString() string {
         }
     }
 
-    if strings.ToUpper(transport.mode) == MODE_PLAY {
-        str += ";mode=PLAY"
-    } else if strings.ToUpper(transport.mode) == MODE_RECORD {
-        str += ";mode=RECORD"
+    mode := strings.ToUpper(transport.mode)
+    if mode == MODE_PLAY || mode == MODE_RECORD {
+        if transport.version == RTSP_2_0 {
+            str += ";mode=\"" + mode + "\""
+        } else {
+            str += ";mode=" + mode
+        }
     }
     return str
 }
+
+// "host:rtp"/"host:rtcp",the host may be empty which means the address of rtsp connection
+func parseAddrList(value string, ports *[2]uint16) (host string) {
+    addrs := strings.Split(value, "/")
+    for i := 0; i < len(addrs) && i < 2; i++ {
+        h, p, err := net.SplitHostPort(strings.Trim(strings.TrimSpace(addrs[i]), "\""))
+        if err != nil {
+            continue
+        }
+        if i == 0 {
+            host = h
+        }
+        port, _ := strconv.Atoi(p)
+        ports[i] = uint16(port)
+    }
+    if ports[0] != 0 && ports[1] == 0 {
+        ports[1] = ports[0] + 1
+    }
+    return
+}
+
+func encodeAddrList(host string, ports [2]uint16) string {
+    return "\"" + net.JoinHostPort(host, strconv.Itoa(int(ports[0]))) + "\"/\"" + net.JoinHostPort(host, strconv.Itoa(int(ports[1]))) + "\""
+}
@@ -0,0 +1,118 @@
+package rtsp
+
+import "testing"
+
+func TestRtspTransport_Rtsp2Addr(t *testing.T) {
+	tests := []struct {
+		name        string
+		str         string
+		multicast   bool
+		destination string
+		source      string
+		destPorts   [2]uint16
+		srcPorts    [2]uint16
+		want        string
+	}{
+		{
+			name:      "port only",
+			str:       `RTP/AVP/UDP;unicast;dest_addr=":4588"/":4589";src_addr="192.0.2.5:6256"/"192.0.2.5:6257";mode="PLAY"`,
+			source:    "192.0.2.5",
+			destPorts: [2]uint16{4588, 4589},
+			srcPorts:  [2]uint16{6256, 6257},
+		},
+		{
+			name:        "ipv6",
+			str:         `RTP/AVP/UDP;unicast;dest_addr="[2001:db8::1]:4588"/"[2001:db8::1]:4589";src_addr="[2001:db8::5]:6256"/"[2001:db8::5]:6257";mode="PLAY"`,
+			destination: "2001:db8::1",
+			source:      "2001:db8::5",
+			destPorts:   [2]uint16{4588, 4589},
+			srcPorts:    [2]uint16{6256, 6257},
+		},
+		{
+			name:      "rtp address only",
+			str:       `RTP/AVP/UDP;unicast;dest_addr=":4588";mode="PLAY"`,
+			destPorts: [2]uint16{4588, 4589},
+			want:      `RTP/AVP/UDP;unicast;dest_addr=":4588"/":4589";mode="PLAY"`,
+		},
+		{
+			name:        "multicast",
+			str:         `RTP/AVP/UDP;multicast;dest_addr="224.2.0.1:3456"/"224.2.0.1:3457";ttl=16;mode="PLAY"`,
+			multicast:   true,
+			destination: "224.2.0.1",
+			destPorts:   [2]uint16{3456, 3457},
+		},
+		{
+			name:        "ipv6 multicast",
+			str:         `RTP/AVP/UDP;multicast;dest_addr="[ff0e::1]:3456"/"[ff0e::1]:3457";ttl=16;mode="PLAY"`,
+			multicast:   true,
+			destination: "ff0e::1",
+			destPorts:   [2]uint16{3456, 3457},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := NewRtspTransport()
+			if err := transport.DecodeString(tt.str); err != nil {
+				t.Fatal(err)
+			}
+			destPorts := transport.Client_ports
+			if tt.multicast {
+				destPorts = transport.Ports
+			}
+			if transport.Proto != UDP || transport.IsMultiCast != tt.multicast || transport.version != RTSP_2_0 {
+				t.Errorf("DecodeString(%s) = %+v", tt.str, transport)
+			}
+			if transport.Destination != tt.destination || transport.Source != tt.source || destPorts != tt.destPorts || transport.Server_ports != tt.srcPorts {
+				t.Errorf("DecodeString(%s) = %s %v %s %v, want %s %v %s %v", tt.str, transport.Destination, destPorts, transport.Source, transport.Server_ports,
+					tt.destination, tt.destPorts, tt.source, tt.srcPorts)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.str
+			}
+			if got := transport.EncodeString(); got != want {
+				t.Errorf("EncodeString() = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeTransports(t *testing.T) {
+	str := `RTP/AVP/UDP;multicast;dest_addr="224.2.0.1:3456"/"224.2.0.1:3457", RTP/AVP/UDP;unicast;dest_addr=":4588"/":4589",RTP/AVP/TCP;unicast;interleaved=0-1`
+	transports := DecodeTransports(str)
+	if len(transports) != 3 {
+		t.Fatalf("DecodeTransports() got %d transports, want 3", len(transports))
+	}
+	if !transports[0].IsMultiCast || transports[0].Destination != "224.2.0.1" || transports[0].Ports != [2]uint16{3456, 3457} {
+		t.Errorf("transport 0 = %+v", transports[0])
+	}
+	if transports[1].IsMultiCast || transports[1].Proto != UDP || transports[1].Client_ports != [2]uint16{4588, 4589} {
+		t.Errorf("transport 1 = %+v", transports[1])
+	}
+	if transports[2].Proto != TCP || transports[2].Interleaved != [2]int{0, 1} {
+		t.Errorf("transport 2 = %+v", transports[2])
+	}
+	//the client decodes the first one of the reply
+	transport := NewRtspTransport()
+	transport.DecodeString(str)
+	if !transport.IsMultiCast || transport.Client_ports[0] != 0 || transport.Interleaved[1] != 0 {
+		t.Errorf("DecodeString() = %+v", transport)
+	}
+}
+
+func TestRtspServer_SelectTransport(t *testing.T) {
+	//multicast is not supported by the track,the server picks the next transport-spec
+	track := newOnvifTestVideoTrack(t)
+	s := newOnvifTestSession(t, nil, nil, track)
+	res := RtspResponse{Fileds: make(HeadFiled)}
+	req := RtspRequest{Method: SETUP, Version: RTSP_1_0, Fileds: make(HeadFiled)}
+	req.Fileds[Transport] = "RTP/AVP;multicast, RTP/AVP/TCP;unicast;interleaved=0-1"
+	transport := s.server.selectTransport(req, &res, track)
+	if res.StatusCode != OK || transport.Proto != TCP || transport.IsMultiCast {
+		t.Errorf("selectTransport() = %d %+v", res.StatusCode, transport)
+	}
+	req.Fileds[Transport] = "RTP/AVP;multicast"
+	if s.server.selectTransport(req, &res, track); res.StatusCode != Unsupported_Transport {
+		t.Errorf("selectTransport() = %d, want %d", res.StatusCode, Unsupported_Transport)
+	}
+}
@@ -0,0 +1,300 @@
+package sdp
+
+import (
+    "encoding/hex"
+    "errors"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// rfc3264 offer/answer model
+// the answer has the same m= lines as the offer,the rejected media has port 0 and the first offered format,
+// the formats of the accepted media are the offered ones matched by the local capabilities,
+// the payload types of the offer are kept in the answer.
+// the port 0 of the offer is not treated as a disabled stream,because the rtsp servers describe all the medias with port 0
+
+// the codec supported by local
+type Capability struct {
+    MediaType  string //audio,video or application,"" matches any media
+    EncodeName string
+    ClockRate  int    //0 matches any clock rate
+    Channels   int    //0 matches any channel count
+    Fmtp       string //the constraints of fmtp,e.g. "profile-level-id=42e01f;packetization-mode=1"
+}
+
+// h264(rfc6184):packetization-mode must be same,profile_idc must be same,the level of answer is the lower one
+// h265(rfc7798):profile-id must be same
+// the other codecs:the parameters of the capability must be equal to the offered ones
+func (c *Capability) match(media *Media, rtpMap RtpMap) (Fmtp, bool) {
+    if c.MediaType != "" && c.MediaType != media.MediaType {
+        return Fmtp{}, false
+    }
+    if !strings.EqualFold(c.EncodeName, rtpMap.EncodeName) {
+        return Fmtp{}, false
+    }
+    if c.ClockRate != 0 && c.ClockRate != rtpMap.ClockRate {
+        return Fmtp{}, false
+    }
+    if c.Channels != 0 {
+        channels := 1
+        if rtpMap.EncodParam != "" {
+            channels, _ = strconv.Atoi(rtpMap.EncodParam)
+        }
+        if channels != c.Channels {
+            return Fmtp{}, false
+        }
+    }
+    offered, found := media.Fmtp(rtpMap.PayloadType)
+    if !found {
+        offered = Fmtp{Format: strconv.Itoa(rtpMap.PayloadType)}
+    }
+    offeredParams := offered.Parameters()
+    localParams := (&Fmtp{Params: c.Fmtp}).Parameters()
+    switch strings.ToLower(rtpMap.EncodeName) {
+    case "h264":
+        return matchH264(offered, offeredParams, localParams)
+    case "h265":
+        if profile, found := localParams["profile-id"]; found {
+            offeredProfile := offeredParams["profile-id"]
+            if offeredProfile == "" {
+                offeredProfile = "1"
+            }
+            if offeredProfile != profile {
+                return Fmtp{}, false
+            }
+        }
+    default:
+        for k, v := range localParams {
+            if !strings.EqualFold(offeredParams[k], v) {
+                return Fmtp{}, false
+            }
+        }
+    }
+    return offered, true
+}
+
+func matchH264(offered Fmtp, offeredParams map[string]string, localParams map[string]string) (Fmtp, bool) {
+    mode := offeredParams["packetization-mode"]
+    if mode == "" {
+        mode = "0"
+    }
+    if localMode, found := localParams["packetization-mode"]; found && localMode != mode {
+        return Fmtp{}, false
+    }
+    localProfile, found := localParams["profile-level-id"]
+    if !found {
+        return offered, true
+    }
+    offeredProfile := offeredParams["profile-level-id"]
+    if offeredProfile == "" {
+        //the default is baseline profile level 1.0
+        offeredProfile = "42000a"
+    }
+    op, err1 := hex.DecodeString(offeredProfile)
+    lp, err2 := hex.DecodeString(localProfile)
+    if err1 != nil || err2 != nil || len(op) != 3 || len(lp) != 3 || op[0] != lp[0] {
+        return Fmtp{}, false
+    }
+    if h264LevelOrder(lp) < h264LevelOrder(op) {
+        iop := op[1]
+        if isH264Level1bProfile(op[0]) && lp[2] == 11 {
+            //constraint_set3_flag decides whether level_idc 11 is level 1b or 1.1
+            iop = iop&^0x10 | lp[1]&0x10
+        }
+        offered.SetParameter("profile-level-id", hex.EncodeToString([]byte{op[0], iop, lp[2]}))
+    }
+    return offered, true
+}
+
+// level 1b is level_idc 11 with constraint_set3_flag in baseline,main and extended profiles,
+// or level_idc 9 in the other profiles,it is between level 1.0 and 1.1
+func h264LevelOrder(profileLevelId []byte) int {
+    level := profileLevelId[2]
+    if level == 9 || (level == 11 && profileLevelId[1]&0x10 > 0 && isH264Level1bProfile(profileLevelId[0])) {
+        return 21
+    }
+    return int(level) * 2
+}
+
+func isH264Level1bProfile(profileIdc byte) bool {
+    return profileIdc == 66 || profileIdc == 77 || profileIdc == 88
+}
+
+type NegotiatedMedia struct {
+    Offer      *Media
+    Answer     *Media
+    Accepted   bool     //false if the media is rejected or the direction is inactive
+    Codecs     []RtpMap //the matched codecs,rtx is not included
+    Direction  string   //the direction of the answerer
+    Preference int      //the index of the capability matched by the first codec,-1 if the media is not accepted
+}
+
+type Answer struct {
+    Sdp    *Sdp
+    Medias []*NegotiatedMedia //in the order of m= of the offer
+}
+
+type answerOptions struct {
+    directions      map[string]string
+    localPreference bool
+}
+
+type AnswerOption func(opts *answerOptions)
+
+// the direction supported by local for the media type,the default is sendrecv
+func WithLocalDirection(mediaType string, direction string) AnswerOption {
+    return func(opts *answerOptions) {
+        opts.directions[mediaType] = direction
+    }
+}
+
+// the codecs are in the order of the capabilities instead of the offer
+func WithLocalPreference() AnswerOption {
+    return func(opts *answerOptions) {
+        opts.localPreference = true
+    }
+}
+
+// the error is returned if no codec of any media is matched,
+// the inactive media is answered but not accepted
+func CreateAnswer(offer *Sdp, caps []Capability, opt ...AnswerOption) (*Answer, error) {
+    opts := &answerOptions{directions: make(map[string]string)}
+    for _, o := range opt {
+        o(opts)
+    }
+    answer := &Answer{Sdp: NewSdp()}
+    if len(offer.Timings) > 0 {
+        answer.Sdp.Timings = append([]Timing{}, offer.Timings...)
+    }
+    matched := false
+    for _, m := range offer.Medias {
+        nm := negotiateMedia(offer, m, caps, opts)
+        matched = matched || len(nm.Codecs) > 0
+        answer.Medias = append(answer.Medias, nm)
+        answer.Sdp.Medias = append(answer.Sdp.Medias, nm.Answer)
+    }
+    if !matched {
+        return nil, errors.New("no codec is matched")
+    }
+    return answer, nil
+}
+
+func negotiateMedia(offer *Sdp, m *Media, caps []Capability, opts *answerOptions) *NegotiatedMedia {
+    type selected struct {
+        rtpMap RtpMap
+        fmtp   Fmtp
+        index  int
+    }
+    var codecs []selected
+    var rtxs []RtpMap
+    for _, pt := range m.PayloadTypes() {
+        rtpMap, found := m.RtpMap(pt)
+        if !found {
+            continue
+        }
+        if strings.EqualFold(rtpMap.EncodeName, "rtx") {
+            rtxs = append(rtxs, rtpMap)
+            continue
+        }
+        for i := range caps {
+            if fmtp, ok := caps[i].match(m, rtpMap); ok {
+                codecs = append(codecs, selected{rtpMap: rtpMap, fmtp: fmtp, index: i})
+                break
+            }
+        }
+    }
+    if opts.localPreference {
+        sort.SliceStable(codecs, func(i, j int) bool { return codecs[i].index < codecs[j].index })
+    }
+
+    nm := &NegotiatedMedia{Offer: m, Preference: -1}
+    nm.Answer = &Media{MediaType: m.MediaType, Port: m.Port, NumberOfPorts: m.NumberOfPorts, Proto: m.Proto}
+    if len(codecs) == 0 {
+        nm.Answer.Port = 0
+        nm.Answer.NumberOfPorts = 0
+        if len(m.Formats) > 0 {
+            nm.Answer.Formats = append([]string(nil), m.Formats[0])
+        }
+        return nm
+    }
+    //the inactive media is answered with the matched codecs,but it is not set up
+    nm.Direction = answerDirection(offer, m, opts)
+    if nm.Direction != INACTIVE {
+        nm.Accepted = true
+        nm.Preference = codecs[0].index
+    }
+    if control := m.Control(); control != "" {
+        nm.Answer.Attributes.Add("control", control)
+    }
+    for _, c := range codecs {
+        nm.Codecs = append(nm.Codecs, c.rtpMap)
+        nm.Answer.addFormat(c.rtpMap, c.fmtp)
+    }
+    //the rtx of the accepted codecs is accepted if rtx is supported
+    for i := range caps {
+        if !strings.EqualFold(caps[i].EncodeName, "rtx") {
+            continue
+        }
+        for _, rtx := range rtxs {
+            fmtp, _ := m.Fmtp(rtx.PayloadType)
+            apt, err := strconv.Atoi(fmtp.Parameters()["apt"])
+            if err != nil {
+                continue
+            }
+            for _, c := range codecs {
+                if c.rtpMap.PayloadType == apt {
+                    nm.Answer.addFormat(rtx, fmtp)
+                    break
+                }
+            }
+        }
+        break
+    }
+    nm.Answer.Attributes.Add(nm.Direction, "")
+    nm.Answer.resolve()
+    return nm
+}
+
+func (m *Media) addFormat(rtpMap RtpMap, fmtp Fmtp) {
+    m.Formats = append(m.Formats, strconv.Itoa(rtpMap.PayloadType))
+    m.Attributes.Add("rtpmap", rtpMap.Encode())
+    if fmtp.Format != "" && fmtp.Params != "" {
+        m.Attributes.Add("fmtp", fmtp.Encode())
+    }
+}
+
+// the offered direction is reversed and intersected with the local direction
+func answerDirection(offer *Sdp, m *Media, opts *answerOptions) string {
+    offered := m.Direction()
+    if offered == "" {
+        offered = offer.Direction()
+    }
+    local := opts.directions[m.MediaType]
+    bits := directionBits(offered)
+    reversed := (bits&1)<<1 | (bits&2)>>1
+    switch reversed & directionBits(local) {
+    case 1:
+        return SENDONLY
+    case 2:
+        return RECVONLY
+    case 3:
+        return SENDRECV
+    default:
+        return INACTIVE
+    }
+}
+
+// bit 0 is send,bit 1 is receive
+func directionBits(direction string) int {
+    switch direction {
+    case SENDONLY:
+        return 1
+    case RECVONLY:
+        return 2
+    case INACTIVE:
+        return 0
+    default:
+        return 3
+    }
+}
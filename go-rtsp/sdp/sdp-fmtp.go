@@ -24,6 +24,10 @@ func CreateFmtpParamParser(name string) FmtpCodecParamParser {
         return NewH265FmtpParam()
     case "mpeg4-generic":
         return NewAACFmtpParam()
+    case "opus":
+        return NewOpusFmtpParam()
+    case "mp4a-latm", "mpeg4-latm":
+        return NewLATMFmtpParam()
     }
     return nil
 }
@@ -318,3 +322,197 @@ func (param *AACFmtpParam) Save() string {
 
     return paramstr
 }
+
+type OpusFmtpParam struct {
+    stereo            bool //the receiver prefers stereo
+    spropStereo       bool //the sender is likely to send stereo
+    useInbandFec      bool
+    useDtx            bool
+    maxAverageBitrate int
+}
+
+type OpusFmtpParamOption func(extra *OpusFmtpParam)
+
+func WithOpusStereo() OpusFmtpParamOption {
+    return func(extra *OpusFmtpParam) {
+        extra.stereo = true
+        extra.spropStereo = true
+    }
+}
+
+func WithOpusInbandFec() OpusFmtpParamOption {
+    return func(extra *OpusFmtpParam) {
+        extra.useInbandFec = true
+    }
+}
+
+func WithOpusMaxAverageBitrate(bitrate int) OpusFmtpParamOption {
+    return func(extra *OpusFmtpParam) {
+        extra.maxAverageBitrate = bitrate
+    }
+}
+
+// rfc7587
+// a=fmtp:111 minptime=10;useinbandfec=1;stereo=1;sprop-stereo=1
+func NewOpusFmtpParam(opt ...OpusFmtpParamOption) *OpusFmtpParam {
+    param := &OpusFmtpParam{}
+    for _, o := range opt {
+        o(param)
+    }
+    return param
+}
+
+// the channel count of the stream,the channel count of rtpmap is always 2
+func (param *OpusFmtpParam) ChannelCount() int {
+    if param.spropStereo {
+        return 2
+    }
+    return 1
+}
+
+func (param *OpusFmtpParam) UseInbandFec() bool {
+    return param.useInbandFec
+}
+
+func (param *OpusFmtpParam) Load(fmtp string) {
+    items := strings.SplitN(fmtp, " ", 2)
+    if len(items) < 2 {
+        return
+    }
+
+    codecParams := strings.Split(items[1], ";")
+    for _, p := range codecParams {
+        kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+        if len(kv) < 2 {
+            continue
+        }
+        switch kv[0] {
+        case "stereo":
+            param.stereo = kv[1] == "1"
+        case "sprop-stereo":
+            param.spropStereo = kv[1] == "1"
+        case "useinbandfec":
+            param.useInbandFec = kv[1] == "1"
+        case "usedtx":
+            param.useDtx = kv[1] == "1"
+        case "maxaveragebitrate":
+            param.maxAverageBitrate, _ = strconv.Atoi(kv[1])
+        }
+    }
+}
+
+func (param *OpusFmtpParam) Save() string {
+    paramstr := "minptime=10"
+    if param.useInbandFec {
+        paramstr += ";useinbandfec=1"
+    }
+    if param.useDtx {
+        paramstr += ";usedtx=1"
+    }
+    if param.stereo {
+        paramstr += ";stereo=1"
+    }
+    if param.spropStereo {
+        paramstr += ";sprop-stereo=1"
+    }
+    if param.maxAverageBitrate > 0 {
+        paramstr += ";maxaveragebitrate=" + strconv.Itoa(param.maxAverageBitrate)
+    }
+    return paramstr
+}
+
+// rfc6416
+// m=audio 49230 RTP/AVP 96
+// a=rtpmap:96 MP4A-LATM/44100/2
+// a=fmtp:96 profile-level-id=30;cpresent=0;config=400024203fc0;object=2
+// config is the hex of StreamMuxConfig
+type LATMFmtpParam struct {
+    profileLevelId int
+    cpresent       bool
+    config         []byte
+    object         int
+}
+
+type LATMFmtpParamOption func(extra *LATMFmtpParam)
+
+// the StreamMuxConfig is out-of-band(cpresent=0)
+func WithStreamMuxConfig(config []byte) LATMFmtpParamOption {
+    return func(extra *LATMFmtpParam) {
+        extra.cpresent = false
+        extra.config = make([]byte, len(config))
+        copy(extra.config, config)
+    }
+}
+
+// the StreamMuxConfig of one program with the asc
+func WithLATMAudioSpecificConfig(asc []byte) LATMFmtpParamOption {
+    return WithStreamMuxConfig(codec.NewStreamMuxConfig(asc).Encode())
+}
+
+// the default of cpresent is 1,the StreamMuxConfig is in-band
+func NewLATMFmtpParam(opt ...LATMFmtpParamOption) *LATMFmtpParam {
+    param := &LATMFmtpParam{
+        profileLevelId: 30,
+        cpresent:       true,
+    }
+    for _, o := range opt {
+        o(param)
+    }
+    return param
+}
+
+func (param *LATMFmtpParam) CPresent() bool {
+    return param.cpresent
+}
+
+func (param *LATMFmtpParam) StreamMuxConfig() []byte {
+    return param.config
+}
+
+func (param *LATMFmtpParam) AudioSpecificConfig() []byte {
+    smc := &codec.StreamMuxConfig{}
+    if len(param.config) == 0 || smc.Decode(param.config) != nil {
+        return nil
+    }
+    return smc.Asc
+}
+
+func (param *LATMFmtpParam) Load(fmtp string) {
+    items := strings.SplitN(fmtp, " ", 2)
+    if len(items) < 2 {
+        return
+    }
+
+    codecParams := strings.Split(items[1], ";")
+    for _, p := range codecParams {
+        kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+        if len(kv) < 2 {
+            continue
+        }
+        switch strings.ToLower(kv[0]) {
+        case "profile-level-id":
+            param.profileLevelId, _ = strconv.Atoi(kv[1])
+        case "cpresent":
+            param.cpresent = kv[1] != "0"
+        case "config":
+            param.config, _ = hex.DecodeString(kv[1])
+        case "object":
+            param.object, _ = strconv.Atoi(kv[1])
+        }
+    }
+}
+
+func (param *LATMFmtpParam) Save() string {
+    if param.cpresent {
+        return fmt.Sprintf("profile-level-id=%d;cpresent=1", param.profileLevelId)
+    }
+    paramstr := fmt.Sprintf("profile-level-id=%d;cpresent=0;config=%s", param.profileLevelId, hex.EncodeToString(param.config))
+    object := param.object
+    if asc := param.AudioSpecificConfig(); object == 0 && len(asc) > 0 {
+        object = int(asc[0] >> 3)
+    }
+    if object > 0 {
+        paramstr += ";object=" + strconv.Itoa(object)
+    }
+    return paramstr
+}
@@ -2,16 +2,21 @@ package sdp
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 //c=<nettype> <addrtype> <connection-address>
 //c=IN IP4 224.2.36.42/127
+//c=IN IP4 224.2.1.1/127/3
+//c=IN IP6 FF15::101/3
 type Connection struct {
     Nettype  string
     Addrtype string
     Address  string
+    Ttl      int //ipv4 multicast only
+    Number   int //number of multicast addresses,0 means 1
 }
 
 func (c *Connection) Decode(connectionData string) error {
@@ -21,10 +26,57 @@ func (c *Connection) Decode(connectionData string) error {
     }
     c.Nettype = items[0]
     c.Addrtype = items[1]
-    c.Address = items[2]
+    addr := strings.Split(items[2], "/")
+    c.Address = addr[0]
+    c.Ttl = 0
+    c.Number = 0
+    if c.Addrtype == "IP6" {
+        if len(addr) > 1 {
+            c.Number, _ = strconv.Atoi(addr[1])
+        }
+        return nil
+    }
+    if len(addr) > 1 {
+        c.Ttl, _ = strconv.Atoi(addr[1])
+    }
+    if len(addr) > 2 {
+        c.Number, _ = strconv.Atoi(addr[2])
+    }
     return nil
 }
 
+func (c *Connection) Encode() string {
+    nettype := c.Nettype
+    if nettype == "" {
+        nettype = "IN"
+    }
+    addrtype := c.Addrtype
+    if addrtype == "" {
+        addrtype = "IP4"
+    }
+    address := c.Address
+    if address == "" {
+        address = "0.0.0.0"
+    }
+    if addrtype == "IP4" && c.Ttl > 0 {
+        address += "/" + strconv.Itoa(c.Ttl)
+    }
+    if c.Number > 1 {
+        address += "/" + strconv.Itoa(c.Number)
+    }
+    return nettype + " " + addrtype + " " + address
+}
+
+// whether the address is in 224.0.0.0/4 or ff00::/8
+func (c *Connection) IsMulticast() bool {
+    if strings.Contains(c.Address, ":") {
+        return strings.HasPrefix(strings.ToLower(c.Address), "ff")
+    }
+    first, err := strconv.Atoi(strings.SplitN(c.Address, ".", 2)[0])
+    return err == nil && first >= 224 && first <= 239
+}
+
+//a=rtpmap:<payload type> <encoding name>/<clock rate> [/<encoding parameters>]
 type RtpMap struct {
     PayloadType int
     EncodeName  string
@@ -33,227 +85,948 @@ type RtpMap struct {
 }
 
 func (r *RtpMap) Decode(rtpmap string) error {
-    items := strings.SplitN(rtpmap, " ", 2)
-    r.PayloadType, _ = strconv.Atoi(items[0])
+    items := strings.SplitN(strings.TrimSpace(rtpmap), " ", 2)
+    pt, err := strconv.Atoi(items[0])
+    if err != nil || pt < 0 || pt > 127 {
+        return errors.New("invalid payload type of \"a=rtpmap\"")
+    }
+    r.PayloadType = pt
     if len(items) == 1 {
         return nil
     }
-    param := strings.Split(items[1], "/")
+    param := strings.Split(strings.TrimSpace(items[1]), "/")
+    if len(param) < 2 {
+        return errors.New("\"a=rtpmap\" has no clock rate")
+    }
     r.EncodeName = param[0]
-    r.ClockRate, _ = strconv.Atoi(param[1])
+    if r.ClockRate, err = strconv.Atoi(param[1]); err != nil {
+        return errors.New("invalid clock rate of \"a=rtpmap\"")
+    }
     if len(param) > 2 {
         r.EncodParam = param[2]
     }
     return nil
 }
 
+func (r *RtpMap) Encode() string {
+    rtpmap := strconv.Itoa(r.PayloadType) + " " + r.EncodeName + "/" + strconv.Itoa(r.ClockRate)
+    if r.EncodParam != "" {
+        rtpmap += "/" + r.EncodParam
+    }
+    return rtpmap
+}
+
+//a=fmtp:<format> <format specific parameters>
+type Fmtp struct {
+    Format string
+    Params string
+}
+
+func (f *Fmtp) Decode(fmtp string) error {
+    items := strings.SplitN(strings.TrimSpace(fmtp), " ", 2)
+    if items[0] == "" {
+        return errors.New("\"a=fmtp\" has no format")
+    }
+    f.Format = items[0]
+    f.Params = ""
+    if len(items) > 1 {
+        f.Params = strings.TrimSpace(items[1])
+    }
+    return nil
+}
+
+func (f *Fmtp) Encode() string {
+    return f.Format + " " + f.Params
+}
+
+// the parameters separated by ';',the name is in lower case
+func (f *Fmtp) Parameters() map[string]string {
+    params := make(map[string]string)
+    for _, p := range strings.Split(f.Params, ";") {
+        kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+        if kv[0] == "" {
+            continue
+        }
+        if len(kv) > 1 {
+            params[strings.ToLower(kv[0])] = kv[1]
+        } else {
+            params[strings.ToLower(kv[0])] = ""
+        }
+    }
+    return params
+}
+
+// the parameter is replaced if it exists,otherwise it is appended
+func (f *Fmtp) SetParameter(name string, value string) {
+    params := strings.Split(f.Params, ";")
+    for i, p := range params {
+        kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+        if strings.EqualFold(kv[0], name) {
+            params[i] = kv[0] + "=" + value
+            f.Params = strings.Join(params, ";")
+            return
+        }
+    }
+    if strings.TrimSpace(f.Params) == "" {
+        f.Params = name + "=" + value
+    } else {
+        f.Params = strings.TrimRight(f.Params, "; ") + ";" + name + "=" + value
+    }
+}
+
+//a=range:npt=0-7.741
+//a=range:npt=now-
+//a=range:clock=19961108T142300Z-19961108T143520Z
+type Range struct {
+    Unit  string //npt,smpte,clock
+    Start string
+    End   string
+}
+
+func (r *Range) Decode(value string) error {
+    unitRange := strings.SplitN(strings.TrimSpace(value), "=", 2)
+    if len(unitRange) < 2 {
+        return errors.New("\"a=range\" has no unit")
+    }
+    startEnd := strings.SplitN(unitRange[1], "-", 2)
+    if unitRange[0] == "clock" {
+        //the date is like 19961108T142300Z
+        startEnd = strings.SplitN(unitRange[1], "Z-", 2)
+        if len(startEnd) > 1 {
+            startEnd[0] += "Z"
+        }
+    }
+    if len(startEnd) < 2 {
+        return errors.New("invalid \"a=range\"")
+    }
+    r.Unit = unitRange[0]
+    r.Start = startEnd[0]
+    r.End = startEnd[1]
+    return nil
+}
+
+func (r *Range) Encode() string {
+    return r.Unit + "=" + r.Start + "-" + r.End
+}
+
+//a=extmap:<value>["/"<direction>] <URI> <extensionattributes>
+//a=extmap:1/sendonly http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time
+type Extmap struct {
+    Id         int
+    Direction  string
+    Uri        string
+    Attributes string
+}
+
+func (e *Extmap) Decode(extmap string) error {
+    items := strings.SplitN(strings.TrimSpace(extmap), " ", 3)
+    if len(items) < 2 {
+        return errors.New("parser \"a=extmap\" failed")
+    }
+    idDir := strings.SplitN(items[0], "/", 2)
+    id, err := strconv.Atoi(idDir[0])
+    if err != nil || id < 1 || id > 255 {
+        return errors.New("invalid id of \"a=extmap\"")
+    }
+    e.Id = id
+    if len(idDir) > 1 {
+        e.Direction = idDir[1]
+    }
+    e.Uri = items[1]
+    if len(items) > 2 {
+        e.Attributes = items[2]
+    }
+    return nil
+}
+
+func (e *Extmap) Encode() string {
+    extmap := strconv.Itoa(e.Id)
+    if e.Direction != "" {
+        extmap += "/" + e.Direction
+    }
+    extmap += " " + e.Uri
+    if e.Attributes != "" {
+        extmap += " " + e.Attributes
+    }
+    return extmap
+}
+
+//o=<username> <sess-id> <sess-version> <nettype> <addrtype> <unicast-address>
+//o=- 1109162014219182 1109162014219192 IN IP4 192.168.1.64
+type Origin struct {
+    Username       string
+    SessionId      string
+    SessionVersion string
+    Nettype        string
+    Addrtype       string
+    Address        string
+}
+
+func (o *Origin) Decode(origin string) error {
+    items := strings.Fields(origin)
+    if len(items) != 6 {
+        return errors.New("parser \"o=\" field failed")
+    }
+    o.Username = items[0]
+    o.SessionId = items[1]
+    o.SessionVersion = items[2]
+    o.Nettype = items[3]
+    o.Addrtype = items[4]
+    o.Address = items[5]
+    return nil
+}
+
+func (o *Origin) Encode() string {
+    fields := []string{o.Username, o.SessionId, o.SessionVersion, o.Nettype, o.Addrtype, o.Address}
+    defaults := []string{"-", "0", "0", "IN", "IP4", "0.0.0.0"}
+    for i := range fields {
+        if fields[i] == "" {
+            fields[i] = defaults[i]
+        }
+    }
+    return strings.Join(fields, " ")
+}
+
+//b=<bwtype>:<bandwidth>
+//b=AS:5000
+type Bandwidth struct {
+    Type      string //CT,AS,TIAS,RS,RR
+    Bandwidth int
+}
+
+func (b *Bandwidth) Decode(bandwidth string) error {
+    items := strings.SplitN(bandwidth, ":", 2)
+    if len(items) < 2 {
+        return errors.New("parser \"b=\" field failed")
+    }
+    bw, err := strconv.Atoi(strings.TrimSpace(items[1]))
+    if err != nil {
+        return errors.New("invalid bandwidth of \"b=\"")
+    }
+    b.Type = items[0]
+    b.Bandwidth = bw
+    return nil
+}
+
+func (b *Bandwidth) Encode() string {
+    return b.Type + ":" + strconv.Itoa(b.Bandwidth)
+}
+
+//t=<start-time> <stop-time>
+//r=<repeat interval> <active duration> <offsets from start-time>
+type Timing struct {
+    Start   uint64
+    Stop    uint64
+    Repeats []string
+}
+
+func (t *Timing) Decode(timing string) error {
+    items := strings.Fields(timing)
+    if len(items) != 2 {
+        return errors.New("parser \"t=\" field failed")
+    }
+    var err1, err2 error
+    t.Start, err1 = strconv.ParseUint(items[0], 10, 64)
+    t.Stop, err2 = strconv.ParseUint(items[1], 10, 64)
+    if err1 != nil || err2 != nil {
+        return errors.New("invalid time of \"t=\"")
+    }
+    return nil
+}
+
+func (t *Timing) Encode() string {
+    return strconv.FormatUint(t.Start, 10) + " " + strconv.FormatUint(t.Stop, 10)
+}
+
+//a=<attribute>
+//a=<attribute>:<value>
+type Attribute struct {
+    Key   string
+    Value string
+}
+
+func (a *Attribute) Decode(attribute string) {
+    kv := strings.SplitN(attribute, ":", 2)
+    a.Key = kv[0]
+    a.Value = ""
+    if len(kv) > 1 {
+        a.Value = kv[1]
+    }
+}
+
+func (a *Attribute) Encode() string {
+    if a.Value == "" {
+        return a.Key
+    }
+    return a.Key + ":" + a.Value
+}
+
+// the attributes in order,the same attribute may be repeated
+type Attributes []Attribute
+
+// the value of the first attribute
+func (attrs Attributes) Get(key string) (string, bool) {
+    for _, attr := range attrs {
+        if attr.Key == key {
+            return attr.Value, true
+        }
+    }
+    return "", false
+}
+
+func (attrs Attributes) GetAll(key string) []string {
+    var values []string
+    for _, attr := range attrs {
+        if attr.Key == key {
+            values = append(values, attr.Value)
+        }
+    }
+    return values
+}
+
+func (attrs Attributes) Has(key string) bool {
+    _, found := attrs.Get(key)
+    return found
+}
+
+func (attrs *Attributes) Add(key string, value string) {
+    *attrs = append(*attrs, Attribute{Key: key, Value: value})
+}
+
+// the first attribute is replaced,the others of the key are removed
+func (attrs *Attributes) Set(key string, value string) {
+    for i := range *attrs {
+        if (*attrs)[i].Key == key {
+            (*attrs)[i].Value = value
+            tail := (*attrs)[i+1:]
+            tail.del(key)
+            *attrs = append((*attrs)[:i+1], tail...)
+            return
+        }
+    }
+    attrs.Add(key, value)
+}
+
+func (attrs *Attributes) Del(key string) {
+    attrs.del(key)
+}
+
+func (attrs *Attributes) del(key string) {
+    kept := (*attrs)[:0]
+    for _, attr := range *attrs {
+        if attr.Key != key {
+            kept = append(kept, attr)
+        }
+    }
+    *attrs = kept
+}
+
+const (
+    SENDRECV = "sendrecv"
+    SENDONLY = "sendonly"
+    RECVONLY = "recvonly"
+    INACTIVE = "inactive"
+)
+
+// a=sendrecv,a=sendonly,a=recvonly or a=inactive,"" if there is none
+func (attrs Attributes) Direction() string {
+    for _, attr := range attrs {
+        switch attr.Key {
+        case SENDRECV, SENDONLY, RECVONLY, INACTIVE:
+            return attr.Key
+        }
+    }
+    return ""
+}
+
+func (attrs Attributes) Control() string {
+    control, _ := attrs.Get("control")
+    return control
+}
+
+func (attrs Attributes) Range() (Range, bool) {
+    var r Range
+    value, found := attrs.Get("range")
+    if !found || r.Decode(value) != nil {
+        return r, false
+    }
+    return r, true
+}
+
+// the invalid a=extmap is ignored,it is reported by the parser
+func (attrs Attributes) Extmaps() []Extmap {
+    var extmaps []Extmap
+    for _, value := range attrs.GetAll("extmap") {
+        extmap := Extmap{}
+        if extmap.Decode(value) == nil {
+            extmaps = append(extmaps, extmap)
+        }
+    }
+    return extmaps
+}
+
+// the map of the deprecated Attrs,nil if there is no attribute
+func (attrs Attributes) legacyMap() map[string]string {
+    if len(attrs) == 0 {
+        return nil
+    }
+    m := make(map[string]string)
+    for _, attr := range attrs {
+        m[attr.Key] = attr.Value
+    }
+    return m
+}
+
+// the deprecated Extmaps are encoded before Attrs,the keys of Attrs are sorted
+func legacyAttributes(attrMap map[string]string, extmaps []Extmap) Attributes {
+    var attrs Attributes
+    for _, extmap := range extmaps {
+        attrs.Add("extmap", extmap.Encode())
+    }
+    keys := make([]string, 0, len(attrMap))
+    for key := range attrMap {
+        if key == "extmap" && len(extmaps) > 0 {
+            continue
+        }
+        keys = append(keys, key)
+    }
+    sort.Strings(keys)
+    for _, key := range keys {
+        attrs.Add(key, attrMap[key])
+    }
+    return attrs
+}
+
+// rfc3551 static payload types
+var staticPayloadTypes = map[int]RtpMap{
+    0:  {PayloadType: 0, EncodeName: "PCMU", ClockRate: 8000, EncodParam: "1"},
+    8:  {PayloadType: 8, EncodeName: "PCMA", ClockRate: 8000, EncodParam: "1"},
+    9:  {PayloadType: 9, EncodeName: "G722", ClockRate: 8000, EncodParam: "1"}, //the clock rate of G722 is 8000 for historical reasons
+    10: {PayloadType: 10, EncodeName: "L16", ClockRate: 44100, EncodParam: "2"},
+    11: {PayloadType: 11, EncodeName: "L16", ClockRate: 44100, EncodParam: "1"},
+    14: {PayloadType: 14, EncodeName: "MPA", ClockRate: 90000},
+    26: {PayloadType: 26, EncodeName: "JPEG", ClockRate: 90000},
+    33: {PayloadType: 33, EncodeName: "MP2T", ClockRate: 90000},
+}
+
+//m=<media> <port>[/<number of ports>] <proto> <fmt> ...
+//i=* (media title)
+//c=* (connection information)
+//b=* (zero or more bandwidth information lines)
+//k=* (obsolete)
+//a=* (zero or more media attribute lines)
 type Media struct {
-    MediaType    string
-    Ports        []uint16
-    Proto        string
-    Fmts         []uint8
+    MediaType     string
+    Port          uint16
+    NumberOfPorts int //0 if it is not in m=
+    Proto         string
+    Formats       []string
+    Title         string
+    //media level c=,it overrides the session level one if Address is not empty
+    ConnectionData Connection
+    Bandwidths     []Bandwidth
+    EncryptionKey  string
+    Attributes     Attributes
+
+    //the codec of the first format and a=control,they are resolved by the parser
     PayloadType  int
     EncodeName   string
     ClockRate    int
     ChannelCount int
     ControlUrl   string
-    Attrs        map[string]string
+
+    //Deprecated: filled by the parser and encoded only if Port,Formats and Attributes are empty,
+    //use Port/NumberOfPorts,PayloadTypes,Attributes and Attributes.Extmaps
+    Ports   []uint16
+    Fmts    []uint8
+    Attrs   map[string]string //the last value of the repeated attribute
+    Extmaps []Extmap
 }
 
 func (m *Media) Encode() string {
-    mediaTxt := "m=" + m.MediaType + " " + strconv.Itoa(int(m.Ports[0]))
-    if len(m.Ports) > 1 {
-        mediaTxt += "/" + strconv.Itoa(len(m.Ports))
+    port, numberOfPorts := m.Port, m.NumberOfPorts
+    if port == 0 && len(m.Ports) > 0 {
+        port = m.Ports[0]
+        if len(m.Ports) > 1 {
+            numberOfPorts = len(m.Ports)
+        }
     }
-    mediaTxt += " "
-    mediaTxt += m.Proto
-    for _, pt := range m.Fmts {
-        mediaTxt += " " + strconv.Itoa(int(pt))
+    mediaTxt := "m=" + m.MediaType + " " + strconv.Itoa(int(port))
+    if numberOfPorts > 0 {
+        mediaTxt += "/" + strconv.Itoa(numberOfPorts)
     }
-    mediaTxt += "\r\n"
-
-    for attrKey, attrValue := range m.Attrs {
-        mediaTxt += "a=" + attrKey
-        if len(attrValue) > 0 {
-            mediaTxt += ":" + attrValue
+    mediaTxt += " " + m.Proto
+    if len(m.Formats) > 0 {
+        for _, f := range m.Formats {
+            mediaTxt += " " + f
         }
-        mediaTxt += "\r\n"
+    } else {
+        for _, pt := range m.Fmts {
+            mediaTxt += " " + strconv.Itoa(int(pt))
+        }
+    }
+    mediaTxt += "\r\n"
+    if m.Title != "" {
+        mediaTxt += "i=" + m.Title + "\r\n"
+    }
+    if m.ConnectionData.Address != "" {
+        mediaTxt += "c=" + m.ConnectionData.Encode() + "\r\n"
+    }
+    for _, b := range m.Bandwidths {
+        mediaTxt += "b=" + b.Encode() + "\r\n"
+    }
+    if m.EncryptionKey != "" {
+        mediaTxt += "k=" + m.EncryptionKey + "\r\n"
+    }
+    attrs := m.Attributes
+    if len(attrs) == 0 {
+        attrs = legacyAttributes(m.Attrs, m.Extmaps)
+    }
+    for _, attr := range attrs {
+        mediaTxt += "a=" + attr.Encode() + "\r\n"
     }
     return mediaTxt
 }
 
-// func (m *Media) Decode(mediaDes string) error {
-// 	lines := strings.FieldsFunc(mediaDes, func(r rune) bool {
-// 		if r == '\r' || r == '\n' {
-// 			return true
-// 		} else {
-// 			return false
-// 		}
-// 	})
-// 	m.ParseMLine(string(lines[0]))
-// 	for _, line := range lines[1:] {
-// 		nameValue := strings.SplitN(line, "=", 2)
-// 		if len(nameValue) < 2 {
-// 			return errors.New("parser sdp line failed")
-// 		}
-// 		name := nameValue[0]
-// 		value := nameValue[1]
-// 		if name != "a" {
-// 			continue
-// 		}
-
-// 	}
-
-// }
+// the media description starts with m=
+func (m *Media) Decode(mediaDes string) error {
+    *m = Media{}
+    lines := splitLines(mediaDes)
+    if len(lines) == 0 || !strings.HasPrefix(lines[0], "m=") {
+        return errors.New("media description must start with \"m=\"")
+    }
+    for i, line := range lines {
+        name, value, err := splitLine(line)
+        if err != nil {
+            return err
+        }
+        if i == 0 {
+            err = m.ParseMLine(value)
+        } else {
+            err = m.decodeLine(name, value)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    m.resolve()
+    return nil
+}
 
 func (m *Media) ParseMLine(mediaLine string) error {
-    strs := strings.SplitN(mediaLine, " ", 4)
+    strs := strings.Fields(mediaLine)
+    if len(strs) < 4 {
+        return errors.New("parser \"m=\" field failed")
+    }
     m.MediaType = strs[0]
     pn := strings.SplitN(strs[1], "/", 2)
-    p, _ := strconv.Atoi(pn[0])
-    m.Ports = append(m.Ports, uint16(p))
+    p, err := strconv.ParseUint(pn[0], 10, 16)
+    if err != nil {
+        return errors.New("invalid port of \"m=\"")
+    }
+    m.Port = uint16(p)
     if len(pn) > 1 {
-        numberOfPort, _ := strconv.Atoi(pn[1])
-        for i := 1; i < numberOfPort; i++ {
-            m.Ports = append(m.Ports, uint16(p)+1)
+        if m.NumberOfPorts, err = strconv.Atoi(pn[1]); err != nil || m.NumberOfPorts < 1 {
+            return errors.New("invalid number of ports of \"m=\"")
         }
     }
     m.Proto = strs[2]
-    fmts := strings.Split(strs[3], " ")
-    for _, fmt := range fmts {
-        f, _ := strconv.Atoi(fmt)
-        m.Fmts = append(m.Fmts, uint8(f))
+    m.Formats = strs[3:]
+    return nil
+}
+
+func (m *Media) decodeLine(name byte, value string) error {
+    switch name {
+    case 'i':
+        m.Title = value
+    case 'c':
+        return m.ConnectionData.Decode(value)
+    case 'b':
+        b := Bandwidth{}
+        if err := b.Decode(value); err != nil {
+            return err
+        }
+        m.Bandwidths = append(m.Bandwidths, b)
+    case 'k':
+        m.EncryptionKey = value
+    case 'a':
+        attr, err := decodeAttribute(value)
+        if err != nil {
+            return err
+        }
+        m.Attributes = append(m.Attributes, attr)
+    case 'v', 'o', 's', 'u', 'e', 'p', 't', 'r', 'z':
+        return errors.New("\"" + string(name) + "=\" is not allowed in media description")
     }
     return nil
 }
 
+// the codec of the first format is used by the track
+func (m *Media) resolve() {
+    m.ControlUrl = m.Control()
+    m.Ports = []uint16{m.Port}
+    for i := 1; i < m.NumberOfPorts; i++ {
+        m.Ports = append(m.Ports, m.Port+uint16(i))
+    }
+    m.Attrs = m.Attributes.legacyMap()
+    m.Extmaps = m.Attributes.Extmaps()
+    pts := m.PayloadTypes()
+    for _, pt := range pts {
+        if pt <= 0xFF {
+            m.Fmts = append(m.Fmts, uint8(pt))
+        }
+    }
+    if len(pts) == 0 {
+        return
+    }
+    m.PayloadType = pts[0]
+    if rtpMap, found := m.RtpMap(pts[0]); found {
+        m.EncodeName = rtpMap.EncodeName
+        m.ClockRate = rtpMap.ClockRate
+        if rtpMap.EncodParam != "" && m.MediaType == "audio" {
+            m.ChannelCount, _ = strconv.Atoi(rtpMap.EncodParam)
+        }
+    }
+}
+
+// the numeric formats of m=
+func (m *Media) PayloadTypes() []int {
+    var pts []int
+    for _, f := range m.Formats {
+        if pt, err := strconv.Atoi(f); err == nil {
+            pts = append(pts, pt)
+        }
+    }
+    return pts
+}
+
+// a=rtpmap of the payload type,or the static payload type of rfc3551
+func (m *Media) RtpMap(pt int) (RtpMap, bool) {
+    for _, value := range m.Attributes.GetAll("rtpmap") {
+        rtpMap := RtpMap{}
+        if rtpMap.Decode(value) == nil && rtpMap.PayloadType == pt {
+            return rtpMap, true
+        }
+    }
+    rtpMap, found := staticPayloadTypes[pt]
+    return rtpMap, found
+}
+
+// the rtpmap of every payload type of m=
+func (m *Media) RtpMaps() []RtpMap {
+    var rtpMaps []RtpMap
+    for _, pt := range m.PayloadTypes() {
+        if rtpMap, found := m.RtpMap(pt); found {
+            rtpMaps = append(rtpMaps, rtpMap)
+        }
+    }
+    return rtpMaps
+}
+
+func (m *Media) Fmtp(pt int) (Fmtp, bool) {
+    format := strconv.Itoa(pt)
+    for _, value := range m.Attributes.GetAll("fmtp") {
+        fmtp := Fmtp{}
+        if fmtp.Decode(value) == nil && fmtp.Format == format {
+            return fmtp, true
+        }
+    }
+    return Fmtp{}, false
+}
+
+func (m *Media) Control() string {
+    return m.Attributes.Control()
+}
+
+func (m *Media) Direction() string {
+    return m.Attributes.Direction()
+}
+
+func (m *Media) Range() (Range, bool) {
+    return m.Attributes.Range()
+}
+
+// a=framerate:25,0 if there is none
+func (m *Media) Framerate() float64 {
+    value, _ := m.Attributes.Get("framerate")
+    framerate, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+    return framerate
+}
+
+//v=  (protocol version)
+//o=  (originator and session identifier)
+//s=  (session name)
+//i=* (session information)
+//u=* (URI of description)
+//e=* (zero or more email address)
+//p=* (zero or more phone number)
+//c=* (connection information,not required if included in all media)
+//b=* (zero or more bandwidth information lines)
+//t=  (one or more time descriptions,every t= is followed by zero or more r=)
+//z=* (time zone adjustments)
+//k=* (obsolete)
+//a=* (zero or more session attribute lines)
+//m=  (zero or more media descriptions)
 type Sdp struct {
+    Origin         Origin
     SessionName    string
     SessionInfo    string
-    ControlUrl     string
+    Uri            string
+    Emails         []string
+    Phones         []string
     ConnectionData Connection
-    Attrs          map[string]string
+    Bandwidths     []Bandwidth
+    Timings        []Timing
+    TimeZones      string
+    EncryptionKey  string
+    Attributes     Attributes
     Medias         []*Media
+
+    //a=control of session,it is resolved by the parser
+    ControlUrl string
+
+    //Deprecated: filled by the parser and encoded only if Attributes is empty,
+    //use Attributes and Attributes.Extmaps
+    Attrs   map[string]string
+    Extmaps []Extmap //session level,apply to all the medias
+}
+
+// the description with default o=,s=,c= and t=
+func NewSdp() *Sdp {
+    return &Sdp{
+        Origin:         Origin{Username: "-", SessionId: "0", SessionVersion: "0", Nettype: "IN", Addrtype: "IP4", Address: "0.0.0.0"},
+        SessionName:    "gomedia rtsp",
+        ConnectionData: Connection{Nettype: "IN", Addrtype: "IP4", Address: "0.0.0.0"},
+        Timings:        []Timing{{}},
+    }
 }
 
 func (sdp *Sdp) Encode() string {
     sdptxt := "v=0\r\n"
-    sdptxt += "o=- 0 0 IN IP4 0.0.0.0\r\n"
-    sdptxt += "s=gomedia rtsp\r\n"
-    sdptxt += "c=IN IP4 \r\n"
-    sdptxt += "t=0 0\r\n"
-    for attrName, attrValue := range sdp.Attrs {
-        sdptxt += "a=" + attrName
-        if len(attrValue) > 0 {
-            sdptxt += ":" + attrValue
+    sdptxt += "o=" + sdp.Origin.Encode() + "\r\n"
+    if sdp.SessionName == "" {
+        sdptxt += "s=-\r\n"
+    } else {
+        sdptxt += "s=" + sdp.SessionName + "\r\n"
+    }
+    if sdp.SessionInfo != "" {
+        sdptxt += "i=" + sdp.SessionInfo + "\r\n"
+    }
+    if sdp.Uri != "" {
+        sdptxt += "u=" + sdp.Uri + "\r\n"
+    }
+    for _, email := range sdp.Emails {
+        sdptxt += "e=" + email + "\r\n"
+    }
+    for _, phone := range sdp.Phones {
+        sdptxt += "p=" + phone + "\r\n"
+    }
+    //c= must be in the session or all the medias
+    if sdp.ConnectionData.Address != "" || !sdp.allMediasHaveConnection() {
+        sdptxt += "c=" + sdp.ConnectionData.Encode() + "\r\n"
+    }
+    for _, b := range sdp.Bandwidths {
+        sdptxt += "b=" + b.Encode() + "\r\n"
+    }
+    if len(sdp.Timings) == 0 {
+        sdptxt += "t=0 0\r\n"
+    }
+    for _, t := range sdp.Timings {
+        sdptxt += "t=" + t.Encode() + "\r\n"
+        for _, r := range t.Repeats {
+            sdptxt += "r=" + r + "\r\n"
         }
-        sdptxt += "\r\n"
     }
-
+    if sdp.TimeZones != "" {
+        sdptxt += "z=" + sdp.TimeZones + "\r\n"
+    }
+    if sdp.EncryptionKey != "" {
+        sdptxt += "k=" + sdp.EncryptionKey + "\r\n"
+    }
+    attrs := sdp.Attributes
+    if len(attrs) == 0 {
+        attrs = legacyAttributes(sdp.Attrs, sdp.Extmaps)
+    }
+    for _, attr := range attrs {
+        sdptxt += "a=" + attr.Encode() + "\r\n"
+    }
     for _, m := range sdp.Medias {
         sdptxt += m.Encode()
     }
     return sdptxt
 }
 
-func (sdp *Sdp) ParserSdp(sdpContent string) error {
-    lines := strings.FieldsFunc(sdpContent, func(r rune) bool {
-        if r == '\r' || r == '\n' {
-            return true
-        } else {
+func (sdp *Sdp) allMediasHaveConnection() bool {
+    for _, m := range sdp.Medias {
+        if m.ConnectionData.Address == "" {
             return false
         }
-    })
+    }
+    return len(sdp.Medias) > 0
+}
+
+// the previous description is dropped,
+// the syntax errors of v=,o=,c=,b=,t=,r=,m=,a=rtpmap and a=fmtp are reported,
+// the malformed a=extmap is kept as it is and skipped by Attributes.Extmaps,
+// the lines of unknown type are ignored
+func (sdp *Sdp) ParserSdp(sdpContent string) error {
+    *sdp = Sdp{}
+    lines := splitLines(sdpContent)
+    if len(lines) == 0 || !strings.HasPrefix(lines[0], "v=") {
+        return errors.New("sdp must start with \"v=\"")
+    }
     for _, line := range lines {
-        nameValue := strings.SplitN(line, "=", 2)
-        if len(nameValue) < 2 {
-            return errors.New("parser sdp line failed")
-        }
-        name := nameValue[0]
-        value := nameValue[1]
-        switch name[0] {
-        case 's':
-            sdp.SessionName = string(value)
-        case 'i':
-            sdp.SessionInfo = string(value)
-        case 'c':
-            if err := sdp.ConnectionData.Decode(string(value)); err != nil {
-                return err
-            }
-        case 'a':
-            attribute := strings.SplitN(value, ":", 2)
-            var attrName string = string(attribute[0])
-            var attrValue string = ""
-            if len(attribute) > 1 {
-                attrValue = string(attribute[1])
-            }
-            if len(sdp.Medias) == 0 {
-                if sdp.Attrs == nil {
-                    sdp.Attrs = make(map[string]string)
-                }
-                sdp.Attrs[attrName] = attrValue
-            } else {
-                if sdp.Medias[len(sdp.Medias)-1].Attrs == nil {
-                    sdp.Medias[len(sdp.Medias)-1].Attrs = make(map[string]string)
-                }
-                sdp.Medias[len(sdp.Medias)-1].Attrs[attrName] = attrValue
-            }
-            switch attrName {
-            case "rtpmap":
-                rtpMap := &RtpMap{}
-                rtpMap.Decode(attrValue)
-                sdp.Medias[len(sdp.Medias)-1].EncodeName = rtpMap.EncodeName
-                sdp.Medias[len(sdp.Medias)-1].ClockRate = rtpMap.ClockRate
-                if len(sdp.Medias[len(sdp.Medias)-1].Fmts) > 0 &&
-                    sdp.Medias[len(sdp.Medias)-1].Fmts[0] == uint8(rtpMap.PayloadType) {
-                    sdp.Medias[len(sdp.Medias)-1].PayloadType = rtpMap.PayloadType
-                }
-                if rtpMap.EncodParam != "" && sdp.Medias[len(sdp.Medias)-1].MediaType == "audio" {
-                    sdp.Medias[len(sdp.Medias)-1].ChannelCount, _ = strconv.Atoi(rtpMap.EncodParam)
-                }
-            case "control":
-                if len(sdp.Medias) == 0 {
-                    sdp.ControlUrl = attrValue
-                } else {
-                    sdp.Medias[len(sdp.Medias)-1].ControlUrl = attrValue
-                }
-            }
-        case 'm':
+        name, value, err := splitLine(line)
+        if err != nil {
+            return err
+        }
+        if name == 'm' {
             m := &Media{}
-            if err := m.ParseMLine(string(value)); err != nil {
+            if err := m.ParseMLine(value); err != nil {
                 return err
             }
             sdp.Medias = append(sdp.Medias, m)
+            continue
+        }
+        if len(sdp.Medias) > 0 {
+            err = sdp.Medias[len(sdp.Medias)-1].decodeLine(name, value)
+        } else {
+            err = sdp.decodeLine(name, value)
+        }
+        if err != nil {
+            return err
+        }
+    }
+    sdp.ControlUrl = sdp.Control()
+    sdp.Attrs = sdp.Attributes.legacyMap()
+    sdp.Extmaps = sdp.Attributes.Extmaps()
+    for _, m := range sdp.Medias {
+        m.resolve()
+    }
+    return nil
+}
+
+func (sdp *Sdp) decodeLine(name byte, value string) error {
+    switch name {
+    case 'v':
+        if value != "0" {
+            return errors.New("unsupport sdp version " + value)
+        }
+    case 'o':
+        return sdp.Origin.Decode(value)
+    case 's':
+        sdp.SessionName = value
+    case 'i':
+        sdp.SessionInfo = value
+    case 'u':
+        sdp.Uri = value
+    case 'e':
+        sdp.Emails = append(sdp.Emails, value)
+    case 'p':
+        sdp.Phones = append(sdp.Phones, value)
+    case 'c':
+        return sdp.ConnectionData.Decode(value)
+    case 'b':
+        b := Bandwidth{}
+        if err := b.Decode(value); err != nil {
+            return err
+        }
+        sdp.Bandwidths = append(sdp.Bandwidths, b)
+    case 't':
+        t := Timing{}
+        if err := t.Decode(value); err != nil {
+            return err
+        }
+        sdp.Timings = append(sdp.Timings, t)
+    case 'r':
+        if len(sdp.Timings) == 0 {
+            return errors.New("\"r=\" must follow \"t=\"")
         }
+        sdp.Timings[len(sdp.Timings)-1].Repeats = append(sdp.Timings[len(sdp.Timings)-1].Repeats, value)
+    case 'z':
+        sdp.TimeZones = value
+    case 'k':
+        sdp.EncryptionKey = value
+    case 'a':
+        attr, err := decodeAttribute(value)
+        if err != nil {
+            return err
+        }
+        sdp.Attributes = append(sdp.Attributes, attr)
     }
+    return nil
+}
 
-    //https://datatracker.ietf.org/doc/html/rfc3551
-    for i := 0; i < len(sdp.Medias); i++ {
-        if _, found := sdp.Medias[i].Attrs["rtpmap"]; !found {
-            if len(sdp.Medias[i].Fmts) == 0 || sdp.Medias[i].Fmts[0] >= 96 {
-                continue
-            }
-            switch sdp.Medias[i].Fmts[0] {
-            case 0:
-                sdp.Medias[i].PayloadType = 0
-                sdp.Medias[i].EncodeName = "PCMU"
-                sdp.Medias[i].ClockRate = 8000
-                sdp.Medias[i].ChannelCount = 1
-            case 8:
-                sdp.Medias[i].PayloadType = 8
-                sdp.Medias[i].EncodeName = "PCMA"
-                sdp.Medias[i].ClockRate = 8000
-                sdp.Medias[i].ChannelCount = 1
-            case 26:
-                sdp.Medias[i].PayloadType = 26
-                sdp.Medias[i].EncodeName = "JPEG"
-                sdp.Medias[i].ClockRate = 90000
-            case 33:
-                sdp.Medias[i].PayloadType = 33
-                sdp.Medias[i].EncodeName = "MP2T"
-                sdp.Medias[i].ClockRate = 90000
+// the mandatory fields of rfc8866
+func (sdp *Sdp) Validate() error {
+    if sdp.Origin == (Origin{}) {
+        return errors.New("sdp has no \"o=\"")
+    }
+    if sdp.SessionName == "" {
+        return errors.New("sdp has no \"s=\"")
+    }
+    if len(sdp.Timings) == 0 {
+        return errors.New("sdp has no \"t=\"")
+    }
+    for _, m := range sdp.Medias {
+        if len(m.Formats) == 0 {
+            return errors.New("media " + m.MediaType + " has no format")
+        }
+        if sdp.ConnectionData.Address == "" && m.ConnectionData.Address == "" {
+            return errors.New("media " + m.MediaType + " has no \"c=\"")
+        }
+        if strings.Contains(m.Proto, "RTP/") {
+            for _, f := range m.Formats {
+                if pt, err := strconv.Atoi(f); err != nil || pt < 0 || pt > 127 {
+                    return errors.New("invalid payload type " + f + " of media " + m.MediaType)
+                }
             }
         }
     }
     return nil
 }
+
+func (sdp *Sdp) Control() string {
+    return sdp.Attributes.Control()
+}
+
+func (sdp *Sdp) Direction() string {
+    return sdp.Attributes.Direction()
+}
+
+func (sdp *Sdp) Range() (Range, bool) {
+    return sdp.Attributes.Range()
+}
+
+// the lines are separated by CRLF or LF,the empty lines are skipped
+func splitLines(content string) []string {
+    var lines []string
+    for _, line := range strings.Split(content, "\n") {
+        line = strings.TrimRight(line, "\r")
+        if line != "" {
+            lines = append(lines, line)
+        }
+    }
+    return lines
+}
+
+//<type>=<value>,type is one lower case letter
+func splitLine(line string) (byte, string, error) {
+    if len(line) < 2 || line[1] != '=' || line[0] < 'a' || line[0] > 'z' {
+        return 0, "", errors.New("invalid sdp line \"" + line + "\"")
+    }
+    return line[0], line[2:], nil
+}
+
+func decodeAttribute(value string) (Attribute, error) {
+    attr := Attribute{}
+    attr.Decode(value)
+    var err error
+    switch attr.Key {
+    case "rtpmap":
+        err = new(RtpMap).Decode(attr.Value)
+    case "fmtp":
+        err = new(Fmtp).Decode(attr.Value)
+    }
+    return attr, err
+}
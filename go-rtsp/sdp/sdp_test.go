@@ -1,7 +1,9 @@
 package sdp
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -31,3 +33,575 @@ func TestParserSdp(t *testing.T) {
 		fmt.Printf("%+v\n", sdp.Medias[1])
 	})
 }
+
+func TestExtmap(t *testing.T) {
+	tests := []struct {
+		name    string
+		extmap  string
+		want    Extmap
+		wantErr bool
+	}{
+		{name: "id and uri", extmap: "3 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time",
+			want: Extmap{Id: 3, Uri: "http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time"}},
+		{name: "id out of range", extmap: "256/sendonly urn:ietf:params:rtp-hdrext:ssrc-audio-level vad=on", wantErr: true},
+		{name: "two-byte id", extmap: "100/recvonly urn:ietf:params:rtp-hdrext:ssrc-audio-level vad=on",
+			want: Extmap{Id: 100, Direction: "recvonly", Uri: "urn:ietf:params:rtp-hdrext:ssrc-audio-level", Attributes: "vad=on"}},
+		{name: "missing uri", extmap: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Extmap
+			err := got.Decode(tt.extmap)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Extmap.Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want || got.Encode() != tt.extmap {
+				t.Errorf("Extmap.Decode() = %+v, Encode() = %s", got, got.Encode())
+			}
+		})
+	}
+	sdp := &Sdp{}
+	if err := sdp.ParserSdp("v=0\r\na=extmap:1 urn:ietf:params:rtp-hdrext:ssrc-audio-level\r\nm=video 0 RTP/AVP 96\r\n" +
+		"a=extmap:3 http://www.webrtc.org/experiments/rtp-hdrext/abs-send-time\r\n" +
+		"a=extmap:256 urn:ietf:params:rtp-hdrext:toffset\r\n" +
+		"a=extmap:x\r\n" +
+		"a=extmap:5 http://www.ietf.org/id/draft-holmer-rmcat-transport-wide-cc-extensions-01\r\n"); err != nil {
+		t.Fatalf("Sdp.ParserSdp() error = %v", err)
+	}
+	if len(sdp.Extmaps) != 1 || len(sdp.Medias[0].Extmaps) != 2 || sdp.Medias[0].Extmaps[1].Id != 5 {
+		t.Errorf("Sdp.ParserSdp() extmap = %+v %+v", sdp.Extmaps, sdp.Medias[0].Extmaps)
+	}
+	if len(sdp.Attributes.Extmaps()) != 1 || len(sdp.Medias[0].Attributes.Extmaps()) != 2 {
+		t.Errorf("Attributes.Extmaps() = %+v %+v", sdp.Attributes.Extmaps(), sdp.Medias[0].Attributes.Extmaps())
+	}
+	//the malformed a=extmap is kept in the attributes
+	if got := len(sdp.Medias[0].Attributes.GetAll("extmap")); got != 4 {
+		t.Errorf("Sdp.ParserSdp() kept %d a=extmap, want 4", got)
+	}
+}
+
+func TestConnection(t *testing.T) {
+	tests := []struct {
+		connection string
+		want       Connection
+		multicast  bool
+	}{
+		{connection: "IN IP4 192.168.1.2", want: Connection{Nettype: "IN", Addrtype: "IP4", Address: "192.168.1.2"}},
+		{connection: "IN IP4 224.2.36.42/127", want: Connection{Nettype: "IN", Addrtype: "IP4", Address: "224.2.36.42", Ttl: 127}, multicast: true},
+		{connection: "IN IP4 224.2.1.1/127/3", want: Connection{Nettype: "IN", Addrtype: "IP4", Address: "224.2.1.1", Ttl: 127, Number: 3}, multicast: true},
+		{connection: "IN IP6 FF15::101/3", want: Connection{Nettype: "IN", Addrtype: "IP6", Address: "FF15::101", Number: 3}, multicast: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.connection, func(t *testing.T) {
+			var got Connection
+			if err := got.Decode(tt.connection); err != nil {
+				t.Fatalf("Connection.Decode() error = %v", err)
+			}
+			if got != tt.want || got.Encode() != tt.connection || got.IsMulticast() != tt.multicast {
+				t.Errorf("Connection.Decode() = %+v, Encode() = %s", got, got.Encode())
+			}
+		})
+	}
+	sdp := &Sdp{}
+	if err := sdp.ParserSdp("v=0\r\nc=IN IP4 0.0.0.0\r\nm=video 20000 RTP/AVP 96\r\nc=IN IP4 239.0.0.1/16\r\n"); err != nil {
+		t.Fatalf("Sdp.ParserSdp() error = %v", err)
+	}
+	if sdp.ConnectionData.IsMulticast() || sdp.Medias[0].ConnectionData.Address != "239.0.0.1" || sdp.Medias[0].ConnectionData.Ttl != 16 {
+		t.Errorf("Sdp.ParserSdp() c= of session %+v,c= of media %+v", sdp.ConnectionData, sdp.Medias[0].ConnectionData)
+	}
+}
+
+func TestStaticPayloadType(t *testing.T) {
+	tests := []struct {
+		media        string
+		fmt          string
+		encodeName   string
+		clockRate    int
+		channelCount int
+	}{
+		{media: "audio", fmt: "0", encodeName: "PCMU", clockRate: 8000, channelCount: 1},
+		{media: "audio", fmt: "9", encodeName: "G722", clockRate: 8000, channelCount: 1},
+		{media: "audio", fmt: "10", encodeName: "L16", clockRate: 44100, channelCount: 2},
+		{media: "audio", fmt: "11", encodeName: "L16", clockRate: 44100, channelCount: 1},
+		{media: "audio", fmt: "14", encodeName: "MPA", clockRate: 90000},
+		{media: "video", fmt: "26", encodeName: "JPEG", clockRate: 90000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encodeName+"/"+tt.fmt, func(t *testing.T) {
+			sdp := &Sdp{}
+			if err := sdp.ParserSdp("v=0\r\nm=" + tt.media + " 0 RTP/AVP " + tt.fmt + "\r\n"); err != nil {
+				t.Fatalf("Sdp.ParserSdp() error = %v", err)
+			}
+			media := sdp.Medias[0]
+			if media.EncodeName != tt.encodeName || media.ClockRate != tt.clockRate || media.ChannelCount != tt.channelCount {
+				t.Errorf("Sdp.ParserSdp() = %+v", media)
+			}
+		})
+	}
+}
+
+func TestOpusFmtpParam(t *testing.T) {
+	param := NewOpusFmtpParam()
+	param.Load("111 minptime=10;useinbandfec=1;stereo=1;sprop-stereo=1")
+	if param.ChannelCount() != 2 || !param.UseInbandFec() {
+		t.Errorf("OpusFmtpParam.Load() = %+v", param)
+	}
+	if param.Save() != "minptime=10;useinbandfec=1;stereo=1;sprop-stereo=1" {
+		t.Errorf("OpusFmtpParam.Save() = %s", param.Save())
+	}
+	if NewOpusFmtpParam().ChannelCount() != 1 {
+		t.Error("the default opus stream is not mono")
+	}
+}
+
+func TestLATMFmtpParam(t *testing.T) {
+	param := NewLATMFmtpParam()
+	param.Load("96 profile-level-id=30; cpresent=0; config=400024203fc0; object=2")
+	if param.CPresent() || !bytes.Equal(param.AudioSpecificConfig(), []byte{0x12, 0x10}) {
+		t.Errorf("LATMFmtpParam.Load() = %+v", param)
+	}
+	if param.Save() != "profile-level-id=30;cpresent=0;config=400024203fc0;object=2" {
+		t.Errorf("LATMFmtpParam.Save() = %s", param.Save())
+	}
+	param = NewLATMFmtpParam(WithLATMAudioSpecificConfig([]byte{0x12, 0x10}))
+	if param.Save() != "profile-level-id=30;cpresent=0;config=400024203fc0;object=2" {
+		t.Errorf("LATMFmtpParam.Save() = %s", param.Save())
+	}
+	if !NewLATMFmtpParam().CPresent() {
+		t.Error("the default StreamMuxConfig is not in-band")
+	}
+}
+
+var cameraSdps = []struct {
+	name string
+	sdp  string
+}{
+	{name: "hikvision", sdp: "v=0\r\n" +
+		"o=- 1109162014219182 1109162014219192 IN IP4 192.168.1.64\r\n" +
+		"s=Media Presentation\r\n" +
+		"e=NONE\r\n" +
+		"b=AS:5100\r\n" +
+		"t=0 0\r\n" +
+		"a=control:rtsp://192.168.1.64:554/Streaming/Channels/101/?transportmode=unicast\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"b=AS:5000\r\n" +
+		"a=recvonly\r\n" +
+		"a=x-dimensions:1920,1080\r\n" +
+		"a=control:rtsp://192.168.1.64:554/Streaming/Channels/101/trackID=1?transportmode=unicast\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 profile-level-id=420029; packetization-mode=1; sprop-parameter-sets=Z00AKp2oHgCJ+WbgICAgQA==,aO48gA==\r\n" +
+		"m=audio 0 RTP/AVP 8\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"b=AS:50\r\n" +
+		"a=recvonly\r\n" +
+		"a=control:rtsp://192.168.1.64:554/Streaming/Channels/101/trackID=2?transportmode=unicast\r\n" +
+		"a=rtpmap:8 PCMA/8000\r\n" +
+		"a=Media_header:MEDIAINFO=494D4B48010200000400000111710110401F000000FA000000000000000000000000000000000000;\r\n" +
+		"a=appversion:1.0\r\n"},
+	{name: "dahua", sdp: "v=0\r\n" +
+		"o=- 2251938206 2251938206 IN IP4 0.0.0.0\r\n" +
+		"s=Media Server\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"t=0 0\r\n" +
+		"a=control:*\r\n" +
+		"a=packetization-supported:DH\r\n" +
+		"a=rtppayload-supported:DH\r\n" +
+		"a=range:npt=now-\r\n" +
+		"m=video 0 RTP/AVP 96\r\n" +
+		"a=control:trackID=0\r\n" +
+		"a=framerate:25.000000\r\n" +
+		"a=rtpmap:96 H265/90000\r\n" +
+		"a=fmtp:96 profile-id=1;sprop-sps=QgEBAWAAAAMAsAAAAwAAAwB7oAPAgBDlja5JMvTcBAQEAg==;sprop-pps=RAHA8vA8kAA=;sprop-vps=QAEMAf//AWAAAAMAsAAAAwAAAwB7rAk=\r\n" +
+		"a=recvonly\r\n" +
+		"m=audio 0 RTP/AVP 97\r\n" +
+		"a=control:trackID=1\r\n" +
+		"a=rtpmap:97 MPEG4-GENERIC/16000\r\n" +
+		"a=fmtp:97 streamtype=5;profile-level-id=1;mode=AAC-hbr;sizelength=13;indexlength=3;indexdeltalength=3;config=1408\r\n" +
+		"a=recvonly\r\n" +
+		"m=application 0 RTP/AVP 107\r\n" +
+		"a=control:trackID=4\r\n" +
+		"a=rtpmap:107 vnd.onvif.metadata/90000\r\n" +
+		"a=recvonly\r\n"},
+	{name: "axis", sdp: "v=0\r\n" +
+		"o=- 2188917546620498587 1 IN IP4 192.168.0.90\r\n" +
+		"s=Session streamed with GStreamer\r\n" +
+		"i=rtsp-server\r\n" +
+		"t=0 0\r\n" +
+		"a=tool:GStreamer\r\n" +
+		"a=type:broadcast\r\n" +
+		"a=range:npt=0-7.741\r\n" +
+		"a=control:rtsp://192.168.0.90/axis-media/media.amp?videocodec=h264\r\n" +
+		"m=video 0 RTP/AVP 96 97\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		"b=AS:50000\r\n" +
+		"a=rtpmap:96 H264/90000\r\n" +
+		"a=fmtp:96 packetization-mode=1;profile-level-id=4d0029;sprop-parameter-sets=Z00AKeKQDwBE/LgLcBAQGkHiRFQ=,aO48gA==\r\n" +
+		"a=rtpmap:97 rtx/90000\r\n" +
+		"a=fmtp:97 apt=96\r\n" +
+		"a=ts-refclk:local\r\n" +
+		"a=mediaclk:sender\r\n" +
+		"a=recvonly\r\n" +
+		"a=control:rtsp://192.168.0.90/axis-media/media.amp/stream=0?videocodec=h264\r\n" +
+		"a=framerate:30.000000\r\n" +
+		"a=transform:1.000000,0.000000,0.000000;0.000000,1.000000,0.000000;0.000000,0.000000,1.000000\r\n"},
+	{name: "rfc8866", sdp: "v=0\r\n" +
+		"o=jdoe 3724394400 3724394405 IN IP4 198.51.100.1\r\n" +
+		"s=Call to John Smith\r\n" +
+		"i=SDP Offer #1\r\n" +
+		"u=http://www.jdoe.example.com/home.html\r\n" +
+		"e=Jane Doe <jane@jdoe.example.com>\r\n" +
+		"p=+1 617 555-6011\r\n" +
+		"c=IN IP4 198.51.100.1\r\n" +
+		"t=3724394400 3724398000\r\n" +
+		"r=7d 1h 0 25h\r\n" +
+		"z=2882844526 -1h 2898848070 0\r\n" +
+		"m=audio 49170 RTP/AVP 0\r\n" +
+		"m=audio 49180/2 RTP/AVP 0 8\r\n" +
+		"m=video 51372 RTP/AVP 99\r\n" +
+		"c=IN IP6 2001:db8::2\r\n" +
+		"a=rtpmap:99 h263-1998/90000\r\n"},
+}
+
+func TestSdpRoundTrip(t *testing.T) {
+	for _, tt := range cameraSdps {
+		t.Run(tt.name, func(t *testing.T) {
+			sdp := &Sdp{}
+			if err := sdp.ParserSdp(tt.sdp); err != nil {
+				t.Fatalf("Sdp.ParserSdp() error = %v", err)
+			}
+			if err := sdp.Validate(); err != nil {
+				t.Fatalf("Sdp.Validate() error = %v", err)
+			}
+			if got := sdp.Encode(); got != tt.sdp {
+				t.Fatalf("Sdp.Encode() = %s", got)
+			}
+			//LF only
+			if err := sdp.ParserSdp(strings.ReplaceAll(tt.sdp, "\r\n", "\n")); err != nil || sdp.Encode() != tt.sdp {
+				t.Fatalf("Sdp.ParserSdp() with LF error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSdpAccessors(t *testing.T) {
+	sdp := &Sdp{}
+	sdp.ParserSdp(cameraSdps[0].sdp)
+	if sdp.Origin.SessionId != "1109162014219182" || sdp.Bandwidths[0] != (Bandwidth{Type: "AS", Bandwidth: 5100}) || sdp.Emails[0] != "NONE" {
+		t.Errorf("hikvision session %+v", sdp)
+	}
+	audio := sdp.Medias[1]
+	if audio.PayloadType != 8 || audio.EncodeName != "PCMA" || audio.ClockRate != 8000 || audio.Direction() != RECVONLY ||
+		audio.ControlUrl != "rtsp://192.168.1.64:554/Streaming/Channels/101/trackID=2?transportmode=unicast" {
+		t.Errorf("hikvision audio %+v", audio)
+	}
+	if fmtp, found := sdp.Medias[0].Fmtp(96); !found || fmtp.Parameters()["packetization-mode"] != "1" {
+		t.Errorf("hikvision fmtp %+v", fmtp)
+	}
+
+	sdp.ParserSdp(cameraSdps[1].sdp)
+	if r, found := sdp.Range(); !found || r != (Range{Unit: "npt", Start: "now"}) || sdp.ControlUrl != "*" {
+		t.Errorf("dahua range %+v", r)
+	}
+	if sdp.Medias[0].Framerate() != 25 || sdp.Medias[0].EncodeName != "H265" || sdp.Medias[2].EncodeName != "vnd.onvif.metadata" {
+		t.Errorf("dahua medias %+v %+v", sdp.Medias[0], sdp.Medias[2])
+	}
+
+	sdp.ParserSdp(cameraSdps[2].sdp)
+	video := sdp.Medias[0]
+	rtpMaps := video.RtpMaps()
+	if len(rtpMaps) != 2 || rtpMaps[1].EncodeName != "rtx" || video.PayloadType != 96 || video.EncodeName != "H264" {
+		t.Errorf("axis rtpmaps %+v", rtpMaps)
+	}
+	if fmtp, found := video.Fmtp(97); !found || fmtp.Parameters()["apt"] != "96" {
+		t.Errorf("axis fmtp of rtx %+v", fmtp)
+	}
+	if r, found := sdp.Range(); !found || r.Encode() != "npt=0-7.741" || r.End != "7.741" {
+		t.Errorf("axis range %+v", r)
+	}
+
+	sdp.ParserSdp(cameraSdps[3].sdp)
+	if sdp.Timings[0].Repeats[0] != "7d 1h 0 25h" || sdp.Medias[1].NumberOfPorts != 2 || sdp.Medias[1].Port != 49180 ||
+		len(sdp.Medias[1].RtpMaps()) != 2 || sdp.Medias[2].ConnectionData.Address != "2001:db8::2" {
+		t.Errorf("rfc8866 %+v", sdp)
+	}
+
+	var attrs Attributes
+	attrs.Add("rtpmap", "96 H264/90000")
+	attrs.Add("control", "track0")
+	attrs.Add("rtpmap", "97 rtx/90000")
+	attrs.Set("rtpmap", "98 H265/90000")
+	if len(attrs) != 2 || attrs[0].Value != "98 H265/90000" || attrs.Control() != "track0" {
+		t.Errorf("Attributes.Set() = %+v", attrs)
+	}
+	attrs.Del("control")
+	if attrs.Has("control") {
+		t.Errorf("Attributes.Del() = %+v", attrs)
+	}
+}
+
+func TestSdpErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sdp  string
+	}{
+		{name: "no version", sdp: "o=- 0 0 IN IP4 0.0.0.0\r\n"},
+		{name: "version", sdp: "v=1\r\n"},
+		{name: "line", sdp: "v=0\r\ns:Session\r\n"},
+		{name: "upper case type", sdp: "v=0\r\nS=Session\r\n"},
+		{name: "origin", sdp: "v=0\r\no=- 0 IN IP4 0.0.0.0\r\n"},
+		{name: "connection", sdp: "v=0\r\nc=IN IP4\r\n"},
+		{name: "bandwidth", sdp: "v=0\r\nb=AS\r\n"},
+		{name: "timing", sdp: "v=0\r\nt=0\r\n"},
+		{name: "repeat without timing", sdp: "v=0\r\nr=7d 1h 0 25h\r\n"},
+		{name: "media", sdp: "v=0\r\nm=video 0 RTP/AVP\r\n"},
+		{name: "port", sdp: "v=0\r\nm=video abc RTP/AVP 96\r\n"},
+		{name: "rtpmap", sdp: "v=0\r\nm=video 0 RTP/AVP 96\r\na=rtpmap:96 H264\r\n"},
+		{name: "fmtp", sdp: "v=0\r\nm=video 0 RTP/AVP 96\r\na=fmtp:\r\n"},
+		{name: "session line in media", sdp: "v=0\r\nm=video 0 RTP/AVP 96\r\nt=0 0\r\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdp := &Sdp{}
+			if err := sdp.ParserSdp(tt.sdp); err == nil {
+				t.Errorf("Sdp.ParserSdp() = %+v", sdp)
+			}
+		})
+	}
+
+	sdp := &Sdp{}
+	sdp.ParserSdp("v=0\r\nm=video 0 RTP/AVP 96\r\n")
+	if sdp.Validate() == nil {
+		t.Error("Sdp.Validate() of sdp without o=,s=,t=")
+	}
+	sdp.ParserSdp("v=0\r\no=- 0 0 IN IP4 0.0.0.0\r\ns=-\r\nt=0 0\r\nm=video 0 RTP/AVP 96\r\n")
+	if sdp.Validate() == nil {
+		t.Error("Sdp.Validate() of media without c=")
+	}
+	sdp.ParserSdp("v=0\r\no=- 0 0 IN IP4 0.0.0.0\r\ns=-\r\nc=IN IP4 0.0.0.0\r\nt=0 0\r\nm=video 0 RTP/AVP 256\r\n")
+	if sdp.Validate() == nil {
+		t.Error("Sdp.Validate() of invalid payload type")
+	}
+}
+
+func TestMediaDecode(t *testing.T) {
+	desc := "m=audio 0 RTP/AVP 97\r\nc=IN IP4 239.0.0.1/16\r\na=control:track1\r\na=rtpmap:97 opus/48000/2\r\na=fmtp:97 minptime=10;useinbandfec=1\r\na=sendonly\r\n"
+	m := &Media{}
+	if err := m.Decode(desc); err != nil {
+		t.Fatal(err)
+	}
+	if m.Encode() != desc || m.EncodeName != "opus" || m.ChannelCount != 2 || m.ControlUrl != "track1" || m.Direction() != SENDONLY {
+		t.Errorf("Media.Decode() = %+v", m)
+	}
+	if m.Decode("v=0\r\n") == nil {
+		t.Error("Media.Decode() without m=")
+	}
+}
+
+func TestCreateAnswer(t *testing.T) {
+	offer := &Sdp{}
+	err := offer.ParserSdp("v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\ns=-\r\nc=IN IP4 10.0.0.1\r\nt=0 0\r\n" +
+		"m=video 5000 RTP/AVP 96 97 98 99\r\na=rtpmap:96 H264/90000\r\na=fmtp:96 profile-level-id=640028;packetization-mode=0\r\n" +
+		"a=rtpmap:97 H264/90000\r\na=fmtp:97 profile-level-id=42e028;packetization-mode=1\r\n" +
+		"a=rtpmap:98 H265/90000\r\na=fmtp:98 profile-id=1\r\na=rtpmap:99 rtx/90000\r\na=fmtp:99 apt=97\r\na=control:trackID=0\r\na=sendonly\r\n" +
+		"m=audio 5002 RTP/AVP 0 8\r\na=sendrecv\r\n" +
+		"m=application 5004 RTP/AVP 107\r\na=rtpmap:107 vnd.onvif.metadata/90000\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	caps := []Capability{
+		{MediaType: "video", EncodeName: "H264", ClockRate: 90000, Fmtp: "profile-level-id=42e01f;packetization-mode=1"},
+		{MediaType: "video", EncodeName: "h265", Fmtp: "profile-id=1"},
+		{MediaType: "video", EncodeName: "rtx"},
+		{MediaType: "audio", EncodeName: "PCMA", ClockRate: 8000, Channels: 1},
+	}
+	answer, err := CreateAnswer(offer, caps, WithLocalDirection("audio", RECVONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Medias) != 3 || len(answer.Sdp.Medias) != 3 {
+		t.Fatalf("CreateAnswer() medias = %d", len(answer.Medias))
+	}
+
+	video := answer.Medias[0]
+	if !video.Accepted || len(video.Codecs) != 2 || video.Codecs[0].PayloadType != 97 || video.Codecs[1].PayloadType != 98 || video.Preference != 0 {
+		t.Errorf("video codecs = %+v", video.Codecs)
+	}
+	if video.Direction != RECVONLY || video.Answer.Port != 5000 || video.Answer.Control() != "trackID=0" {
+		t.Errorf("video answer = %+v", video.Answer)
+	}
+	if strings.Join(video.Answer.Formats, " ") != "97 98 99" {
+		t.Errorf("video fmts = %v", video.Answer.Formats)
+	}
+	if fmtp, _ := video.Answer.Fmtp(97); fmtp.Parameters()["profile-level-id"] != "42e01f" {
+		t.Errorf("h264 fmtp = %+v", fmtp)
+	}
+
+	audio := answer.Medias[1]
+	if !audio.Accepted || len(audio.Codecs) != 1 || audio.Codecs[0].EncodeName != "PCMA" || audio.Direction != RECVONLY {
+		t.Errorf("audio = %+v", audio)
+	}
+
+	application := answer.Medias[2]
+	if application.Accepted || application.Answer.Port != 0 || strings.Join(application.Answer.Formats, " ") != "107" || application.Preference != -1 {
+		t.Errorf("application = %+v", application.Answer)
+	}
+	//the rejected media does not share the formats of the offer
+	application.Answer.Formats[0] = "0"
+	if offer.Medias[2].Formats[0] != "107" {
+		t.Errorf("the offered formats are changed to %v", offer.Medias[2].Formats)
+	}
+
+	encoded := answer.Sdp.Encode()
+	parsed := &Sdp{}
+	if err := parsed.ParserSdp(encoded); err != nil || parsed.Encode() != encoded {
+		t.Errorf("answer sdp = %s,%v", encoded, err)
+	}
+
+	answer, err = CreateAnswer(offer, caps, WithLocalPreference())
+	if err != nil || answer.Medias[0].Codecs[0].PayloadType != 97 {
+		t.Errorf("CreateAnswer(WithLocalPreference()) = %+v,%v", answer, err)
+	}
+	caps[0], caps[1] = caps[1], caps[0]
+	answer, _ = CreateAnswer(offer, caps, WithLocalPreference())
+	if answer.Medias[0].Codecs[0].PayloadType != 98 || answer.Medias[0].Answer.PayloadType != 98 {
+		t.Errorf("CreateAnswer(WithLocalPreference()) = %+v", answer.Medias[0].Codecs)
+	}
+	answer, _ = CreateAnswer(offer, caps)
+	if answer.Medias[0].Codecs[0].PayloadType != 97 || answer.Medias[0].Preference != 1 {
+		t.Errorf("CreateAnswer() = %+v", answer.Medias[0].Codecs)
+	}
+
+	if _, err = CreateAnswer(offer, []Capability{{EncodeName: "H264", Fmtp: "profile-level-id=4d001f"}}); err == nil {
+		t.Error("CreateAnswer() of mismatched profile")
+	}
+	if _, err = CreateAnswer(offer, []Capability{{EncodeName: "H264", Fmtp: "packetization-mode=2"}}); err == nil {
+		t.Error("CreateAnswer() of mismatched packetization-mode")
+	}
+	if _, err = CreateAnswer(offer, []Capability{{EncodeName: "H265", Fmtp: "profile-id=2"}}); err == nil {
+		t.Error("CreateAnswer() of mismatched profile-id")
+	}
+}
+
+func TestCreateAnswer_Inactive(t *testing.T) {
+	offer := &Sdp{}
+	err := offer.ParserSdp("v=0\r\no=- 1 1 IN IP4 10.0.0.1\r\ns=-\r\nc=IN IP4 10.0.0.1\r\nt=0 0\r\n" +
+		"m=audio 5002 RTP/AVP 8\r\na=sendonly\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := CreateAnswer(offer, []Capability{{EncodeName: "PCMA"}}, WithLocalDirection("audio", SENDONLY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audio := answer.Medias[0]
+	if audio.Accepted || audio.Direction != INACTIVE || audio.Preference != -1 {
+		t.Errorf("inactive audio = %+v", audio)
+	}
+	if audio.Answer.Direction() != INACTIVE || audio.Answer.Port != 5002 {
+		t.Errorf("inactive answer = %s", audio.Answer.Encode())
+	}
+}
+
+func TestMatchH264Level(t *testing.T) {
+	tests := []struct {
+		offered string
+		local   string
+		want    string
+	}{
+		{offered: "42e01f", local: "42e00b", want: "42e00b"},
+		//level 1b of baseline is lower than 1.1
+		{offered: "42e01f", local: "42f00b", want: "42f00b"},
+		{offered: "42f00b", local: "42e00b", want: "42f00b"},
+		{offered: "42e00a", local: "42f00b", want: "42e00a"},
+		//constraint_set3_flag is cleared if the answer is level 1.1
+		{offered: "42f01f", local: "42e00b", want: "42e00b"},
+		//level 1b of high profile is level_idc 9
+		{offered: "640028", local: "640009", want: "640009"},
+		{offered: "64000a", local: "640009", want: "64000a"},
+	}
+	for _, tt := range tests {
+		offered := Fmtp{Format: "96", Params: "profile-level-id=" + tt.offered}
+		got, ok := matchH264(offered, offered.Parameters(), map[string]string{"profile-level-id": tt.local})
+		if !ok || got.Parameters()["profile-level-id"] != tt.want {
+			t.Errorf("matchH264(%s,%s) = %s, want %s", tt.offered, tt.local, got.Parameters()["profile-level-id"], tt.want)
+		}
+	}
+}
+
+func TestAnswerDirection(t *testing.T) {
+	tests := []struct {
+		offer string
+		local string
+		want  string
+	}{
+		{offer: SENDRECV, local: "", want: SENDRECV},
+		{offer: SENDONLY, local: "", want: RECVONLY},
+		{offer: RECVONLY, local: "", want: SENDONLY},
+		{offer: INACTIVE, local: "", want: INACTIVE},
+		{offer: SENDRECV, local: RECVONLY, want: RECVONLY},
+		{offer: RECVONLY, local: RECVONLY, want: INACTIVE},
+		{offer: SENDONLY, local: SENDONLY, want: INACTIVE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.offer+"-"+tt.local, func(t *testing.T) {
+			offer := &Sdp{}
+			offer.ParserSdp("v=0\r\no=- 1 1 IN IP4 0.0.0.0\r\ns=-\r\nt=0 0\r\na=" + tt.offer + "\r\nm=audio 0 RTP/AVP 0\r\n")
+			var opts []AnswerOption
+			if tt.local != "" {
+				opts = append(opts, WithLocalDirection("audio", tt.local))
+			}
+			answer, err := CreateAnswer(offer, []Capability{{EncodeName: "PCMU"}}, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if answer.Medias[0].Direction != tt.want || answer.Medias[0].Answer.Direction() != tt.want {
+				t.Errorf("direction = %s, want %s", answer.Medias[0].Direction, tt.want)
+			}
+		})
+	}
+}
+
+func TestFmtpSetParameter(t *testing.T) {
+	fmtp := Fmtp{Format: "96", Params: "Profile-Level-Id=42e028; packetization-mode=1"}
+	fmtp.SetParameter("profile-level-id", "42e01f")
+	fmtp.SetParameter("sprop-parameter-sets", "Z0IAH5WoFAFuQA==,aM48gA==")
+	if fmtp.Params != "Profile-Level-Id=42e01f; packetization-mode=1;sprop-parameter-sets=Z0IAH5WoFAFuQA==,aM48gA==" {
+		t.Errorf("Fmtp.SetParameter() = %s", fmtp.Params)
+	}
+	empty := Fmtp{Format: "0"}
+	empty.SetParameter("ptime", "20")
+	if empty.Params != "ptime=20" {
+		t.Errorf("Fmtp.SetParameter() = %s", empty.Params)
+	}
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	sdp := &Sdp{}
+	if err := sdp.ParserSdp("v=0\r\no=- 0 0 IN IP4 0.0.0.0\r\ns=-\r\nt=0 0\r\na=control:*\r\na=tool:a\r\na=tool:b\r\n" +
+		"m=audio 49180/2 RTP/AVP 0 8\r\na=recvonly\r\n"); err != nil {
+		t.Fatalf("Sdp.ParserSdp() error = %v", err)
+	}
+	if sdp.Attrs["control"] != "*" || sdp.Attrs["tool"] != "b" {
+		t.Errorf("Sdp.Attrs = %v", sdp.Attrs)
+	}
+	m := sdp.Medias[0]
+	if fmt.Sprint(m.Ports) != "[49180 49181]" || fmt.Sprint(m.Fmts) != "[0 8]" {
+		t.Errorf("Media.Ports = %v,Media.Fmts = %v", m.Ports, m.Fmts)
+	}
+	if _, found := m.Attrs["recvonly"]; !found {
+		t.Errorf("Media.Attrs = %v", m.Attrs)
+	}
+
+	//the media built with the deprecated fields
+	m = &Media{MediaType: "video", Ports: []uint16{5000}, Proto: "RTP/AVP", Fmts: []uint8{96},
+		Attrs:   map[string]string{"rtpmap": "96 H264/90000", "control": "trackID=1"},
+		Extmaps: []Extmap{{Id: 1, Uri: "urn:ietf:params:rtp-hdrext:sdes:mid"}}}
+	want := "m=video 5000 RTP/AVP 96\r\na=extmap:1 urn:ietf:params:rtp-hdrext:sdes:mid\r\na=control:trackID=1\r\na=rtpmap:96 H264/90000\r\n"
+	if got := m.Encode(); got != want {
+		t.Errorf("Media.Encode() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+    "encoding/binary"
+    "errors"
+    "net"
+    "sync"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+)
+
+const (
+    DEFAULT_MULTICAST_PORT = 20000
+    // the default ttl of multicast socket,the group is reachable only in the LAN
+    MULTICAST_TTL = 1
+)
+
+// multicastAllocator allocates a group for every stream from the first group
+type multicastAllocator struct {
+    mtx  sync.Mutex
+    base uint32
+    used map[uint32]bool
+}
+
+func newMulticastAllocator(addr string) *multicastAllocator {
+    ip := net.ParseIP(addr).To4()
+    if ip == nil || !ip.IsMulticast() {
+        return nil
+    }
+    return &multicastAllocator{base: binary.BigEndian.Uint32(ip), used: make(map[uint32]bool)}
+}
+
+func (ma *multicastAllocator) allocate() (net.IP, error) {
+    ma.mtx.Lock()
+    defer ma.mtx.Unlock()
+    for i := uint32(0); i < 65536; i++ {
+        addr := ma.base + i
+        if addr>>28 != 0xE {
+            break
+        }
+        if ma.used[addr] {
+            continue
+        }
+        ma.used[addr] = true
+        ip := make(net.IP, 4)
+        binary.BigEndian.PutUint32(ip, addr)
+        return ip, nil
+    }
+    return nil, errors.New("no free multicast group")
+}
+
+func (ma *multicastAllocator) release(ip net.IP) {
+    ma.mtx.Lock()
+    defer ma.mtx.Unlock()
+    delete(ma.used, binary.BigEndian.Uint32(ip.To4()))
+}
+
+// multicastGroup sends the samples of a stream to the group once for all the multicast readers,
+// the track i is sent to port+2*i
+type multicastGroup struct {
+    mtx    sync.Mutex
+    group  net.IP
+    conn   *net.UDPConn
+    tracks map[string]*multicastTrack
+}
+
+type multicastTrack struct {
+    track   *rtsp.RtspTrack
+    port    int
+    members int
+}
+
+func newMulticastGroup(group net.IP, port int, tracks []*rtsp.RtspTrack, opt ...rtsp.TrackOption) (*multicastGroup, error) {
+    conn, err := net.ListenUDP("udp4", nil)
+    if err != nil {
+        return nil, err
+    }
+    g := &multicastGroup{
+        group:  group,
+        conn:   conn,
+        tracks: make(map[string]*multicastTrack),
+    }
+    for i, src := range tracks {
+        mt := &multicastTrack{track: cloneTrack(src, opt...), port: port + 2*i}
+        mt.track.SetTransport(rtsp.NewRtspTransport(rtsp.WithMulticastGroup(group.String(), uint16(mt.port), uint16(mt.port+1), MULTICAST_TTL)))
+        mt.track.OnPacket(g.sender(mt.port))
+        g.tracks[src.TrackName] = mt
+    }
+    return g, nil
+}
+
+func (g *multicastGroup) sender(port int) rtsp.PacketCallBack {
+    rtpAddr := &net.UDPAddr{IP: g.group, Port: port}
+    rtcpAddr := &net.UDPAddr{IP: g.group, Port: port + 1}
+    return func(b []byte, isRtcp bool) (err error) {
+        if isRtcp {
+            _, err = g.conn.WriteToUDP(b, rtcpAddr)
+        } else {
+            _, err = g.conn.WriteToUDP(b, rtpAddr)
+        }
+        return
+    }
+}
+
+// the group and port of the track is announced in sdp,
+// the readers share the srtp key of the group
+func (g *multicastGroup) describe(track *rtsp.RtspTrack) {
+    if mt, found := g.tracks[track.TrackName]; found {
+        track.SetMulticastGroup(g.group.String(), uint16(mt.port), MULTICAST_TTL)
+        if crypto := mt.track.LocalCrypto(); crypto != nil {
+            track.SetLocalCrypto(crypto)
+        }
+    }
+}
+
+// the samples of the track are sent if there is at least one member
+func (g *multicastGroup) join(trackName string) {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    if mt, found := g.tracks[trackName]; found {
+        mt.members++
+    }
+}
+
+func (g *multicastGroup) leave(trackName string) {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    if mt, found := g.tracks[trackName]; found && mt.members > 0 {
+        mt.members--
+    }
+}
+
+func (g *multicastGroup) members(trackName string) int {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    if mt, found := g.tracks[trackName]; found {
+        return mt.members
+    }
+    return 0
+}
+
+func (g *multicastGroup) writeSample(trackName string, sample rtsp.RtspSample) error {
+    g.mtx.Lock()
+    defer g.mtx.Unlock()
+    mt, found := g.tracks[trackName]
+    if !found || mt.members == 0 {
+        return nil
+    }
+    return mt.track.WriteSample(sample)
+}
+
+func (g *multicastGroup) close() {
+    g.conn.Close()
+}
@@ -0,0 +1,450 @@
+package server
+
+import (
+    "errors"
+    "net"
+    "sort"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+)
+
+// Session is one rtsp connection,it is a publisher after RECORD or a reader after PLAY
+type Session struct {
+    srv        *Server
+    conn       net.Conn
+    rtsp       *rtsp.RtspServer
+    mtx        sync.Mutex
+    path       string
+    tracks     map[string]*rtsp.RtspTrack
+    stream     *Stream
+    publisher  bool
+    playing    bool
+    closing    bool //TEARDOWN
+    closed     bool
+    udp        []*udpPair
+    lastActive int64 //unix nano
+    closeOnce  sync.Once
+    tunnel     *rtsp.HttpTunnelServer //rtsp over http,conn is the GET channel
+    posts      []net.Conn
+    postMtx    sync.Mutex
+    mcast      []string //the tracks set up by multicast
+    joined     bool     //the member of multicast group after PLAY
+}
+
+var errTeardown = errors.New("session is torn down")
+
+func newSession(srv *Server, conn net.Conn) *Session {
+    sess := &Session{
+        srv:        srv,
+        conn:       conn,
+        tracks:     make(map[string]*rtsp.RtspTrack),
+        lastActive: time.Now().UnixNano(),
+    }
+    opts := []rtsp.ServerOption{rtsp.WithSessionTimeout(int(srv.cfg.SessionTimeout / time.Second))}
+    if srv.cfg.UserName != "" && srv.cfg.Password != "" {
+        opts = append(opts, rtsp.WithUserInfo(srv.cfg.UserName, srv.cfg.Password))
+    }
+    if srv.cfg.DisableRtsp2 {
+        opts = append(opts, rtsp.WithDisableRtsp2())
+    }
+    sess.rtsp = rtsp.NewRtspServer(&sessionHandle{sess: sess, user: srv.cfg.Handle}, opts...)
+    sess.rtsp.SetOutput(sess.write)
+    return sess
+}
+
+func (sess *Session) RemoteAddr() net.Addr {
+    return sess.conn.RemoteAddr()
+}
+
+func (sess *Session) Path() string {
+    return sess.path
+}
+
+func (sess *Session) IsPublisher() bool {
+    return sess.publisher
+}
+
+func (sess *Session) LastActive() time.Time {
+    return time.Unix(0, atomic.LoadInt64(&sess.lastActive))
+}
+
+func (sess *Session) touch() {
+    atomic.StoreInt64(&sess.lastActive, time.Now().UnixNano())
+}
+
+func (sess *Session) write(b []byte) error {
+    sess.conn.SetWriteDeadline(time.Now().Add(sess.srv.cfg.WriteTimeout))
+    _, err := sess.conn.Write(b)
+    return err
+}
+
+// the GET channel of http tunnel carries nothing from client,it is read only to detect closing
+func (sess *Session) serve(first []byte) {
+    defer sess.Close()
+    if len(first) > 0 && sess.input(first) != nil {
+        return
+    }
+    buf := make([]byte, 65536)
+    for {
+        n, err := sess.conn.Read(buf)
+        if err != nil {
+            return
+        }
+        if sess.tunnel != nil {
+            sess.touch()
+            continue
+        }
+        if err = sess.input(buf[:n]); err != nil {
+            return
+        }
+    }
+}
+
+func (sess *Session) input(data []byte) error {
+    sess.touch()
+    sess.mtx.Lock()
+    defer sess.mtx.Unlock()
+    if err := sess.rtsp.Input(data); err != nil {
+        return err
+    }
+    if sess.closing {
+        return errTeardown
+    }
+    return nil
+}
+
+// the POST channel of http tunnel,the client may close it and open a new one
+func (sess *Session) servePost(conn net.Conn, first []byte) {
+    defer conn.Close()
+    sess.mtx.Lock()
+    if sess.closed {
+        sess.mtx.Unlock()
+        return
+    }
+    sess.posts = append(sess.posts, conn)
+    sess.mtx.Unlock()
+    defer func() {
+        sess.mtx.Lock()
+        for i, c := range sess.posts {
+            if c == conn {
+                sess.posts = append(sess.posts[:i], sess.posts[i+1:]...)
+                break
+            }
+        }
+        sess.mtx.Unlock()
+    }()
+    data := first
+    buf := make([]byte, 65536)
+    for {
+        if len(data) > 0 {
+            sess.postMtx.Lock()
+            err := sess.tunnel.Input(data)
+            sess.postMtx.Unlock()
+            if err != nil {
+                sess.Close()
+                return
+            }
+        }
+        n, err := conn.Read(buf)
+        if err != nil {
+            return
+        }
+        data = buf[:n]
+    }
+}
+
+// the publisher removes the stream,the reader leaves the stream
+func (sess *Session) Close() {
+    sess.closeOnce.Do(func() {
+        sess.conn.Close()
+        sess.mtx.Lock()
+        sess.closed = true
+        stream := sess.stream
+        pairs := sess.udp
+        sess.udp = nil
+        posts := sess.posts
+        sess.posts = nil
+        sess.mtx.Unlock()
+        for _, pair := range pairs {
+            pair.close()
+        }
+        for _, post := range posts {
+            post.Close()
+        }
+        if stream == nil {
+            return
+        }
+        if sess.publisher {
+            sess.srv.unpublish(stream)
+        } else {
+            stream.removeReader(sess)
+            sess.leaveMulticast(stream)
+        }
+    })
+}
+
+// the rtsp 2.0 reader is notified before the stream is removed
+func (sess *Session) notifyEndOfStream() {
+    sess.mtx.Lock()
+    defer sess.mtx.Unlock()
+    if sess.closed || !sess.playing || sess.rtsp.Version() != rtsp.RTSP_2_0 {
+        return
+    }
+    sess.rtsp.PlayNotify(rtsp.NOTIFY_END_OF_STREAM, nil)
+}
+
+func (sess *Session) writeSample(trackName string, sample rtsp.RtspSample) error {
+    sess.mtx.Lock()
+    defer sess.mtx.Unlock()
+    if sess.closed || !sess.playing {
+        return nil
+    }
+    track, found := sess.tracks[trackName]
+    if !found || track.GetTransport() == nil || track.GetTransport().IsMultiCast {
+        return nil
+    }
+    return track.WriteSample(sample)
+}
+
+func (sess *Session) joinMulticast(stream *Stream) {
+    group := stream.multicastGroup()
+    if sess.joined || group == nil {
+        return
+    }
+    for _, name := range sess.mcast {
+        group.join(name)
+    }
+    sess.joined = true
+}
+
+func (sess *Session) leaveMulticast(stream *Stream) {
+    group := stream.multicastGroup()
+    if !sess.joined || group == nil {
+        return
+    }
+    for _, name := range sess.mcast {
+        group.leave(name)
+    }
+    sess.joined = false
+}
+
+// the rtp/rtcp over udp is received in its own goroutine
+func (sess *Session) setupUdp(track *rtsp.RtspTrack, transport *rtsp.RtspTransport) error {
+    ip := sess.conn.LocalAddr().(*net.TCPAddr).IP
+    peer := sess.conn.RemoteAddr().(*net.TCPAddr).IP
+    rtpConn, rtcpConn, err := sess.srv.ports.allocate(ip)
+    if err != nil {
+        return err
+    }
+    pair := &udpPair{
+        rtp:      rtpConn,
+        rtcp:     rtcpConn,
+        rtpPeer:  &net.UDPAddr{IP: peer, Port: int(transport.Client_ports[0])},
+        rtcpPeer: &net.UDPAddr{IP: peer, Port: int(transport.Client_ports[1])},
+    }
+    sess.udp = append(sess.udp, pair)
+    transport.SetServerUdpPort(pair.serverPorts())
+    track.OnPacket(func(b []byte, isRtcp bool) error {
+        return pair.write(b, isRtcp)
+    })
+    input := func(isRtcp bool) func(pkt []byte) {
+        return func(pkt []byte) {
+            sess.touch()
+            sess.mtx.Lock()
+            defer sess.mtx.Unlock()
+            if !sess.closed {
+                track.Input(pkt, isRtcp)
+            }
+        }
+    }
+    go pair.readLoop(pair.rtp, pair.rtpPeer, input(false))
+    go pair.readLoop(pair.rtcp, pair.rtcpPeer, input(true))
+    return nil
+}
+
+// the tracks of reader have the same codec and fmtp as the publisher
+func cloneTrack(src *rtsp.RtspTrack, opt ...rtsp.TrackOption) *rtsp.RtspTrack {
+    opt = append([]rtsp.TrackOption{rtsp.WithCodecParamHandler(src.CodecParamHandler())}, opt...)
+    switch src.TrackName {
+    case "video":
+        return rtsp.NewVideoTrack(src.Codec, opt...)
+    case "audio":
+        return rtsp.NewAudioTrack(src.Codec, opt...)
+    default:
+        return rtsp.NewMetaTrack(src.Codec, opt...)
+    }
+}
+
+// sessionHandle is the built-in ServerHandle of Session,
+// the user handle is called before it
+type sessionHandle struct {
+    sess *Session
+    user rtsp.ServerHandle
+}
+
+func (h *sessionHandle) HandleOption(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        h.user.HandleOption(svr, req, res)
+    }
+}
+
+func (h *sessionHandle) HandleDescribe(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        if h.user.HandleDescribe(svr, req, res); res.StatusCode != rtsp.OK {
+            return
+        }
+    }
+    sess := h.sess
+    stream, found := sess.srv.Stream(req.Uri)
+    if !found {
+        res.StatusCode = rtsp.Not_Found
+        return
+    }
+    if len(sess.tracks) == 0 {
+        group, _ := sess.srv.multicastGroup(stream)
+        for _, src := range stream.Tracks() {
+            track := cloneTrack(src, sess.srv.trackOptions()...)
+            if group != nil {
+                group.describe(track)
+            }
+            svr.AddTrack(track)
+            sess.tracks[track.TrackName] = track
+        }
+    }
+    sess.path = stream.Path()
+    res.Fileds[rtsp.ContentBase] = req.Uri + "/"
+}
+
+func (h *sessionHandle) HandleSetup(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse, transport *rtsp.RtspTransport, track *rtsp.RtspTrack) {
+    if h.user != nil {
+        if h.user.HandleSetup(svr, req, res, transport, track); res.StatusCode != rtsp.OK {
+            return
+        }
+    }
+    if transport.Proto != rtsp.UDP {
+        return
+    }
+    if transport.IsMultiCast {
+        //the group of the track is replied by RtspServer
+        if group, _, _ := track.MulticastGroup(); group == "" {
+            res.StatusCode = rtsp.Unsupported_Transport
+            return
+        }
+        h.sess.mcast = append(h.sess.mcast, track.TrackName)
+        return
+    }
+    if h.sess.srv.cfg.DisableUdp || transport.Client_ports[0] == 0 {
+        res.StatusCode = rtsp.Unsupported_Transport
+        return
+    }
+    if err := h.sess.setupUdp(track, transport); err != nil {
+        res.StatusCode = rtsp.Internal_Server_Error
+    }
+}
+
+func (h *sessionHandle) HandleAnnounce(svr *rtsp.RtspServer, req rtsp.RtspRequest, tracks map[string]*rtsp.RtspTrack) {
+    h.sess.path = streamPath(req.Uri)
+    h.sess.tracks = tracks
+    if h.user != nil {
+        h.user.HandleAnnounce(svr, req, tracks)
+    }
+}
+
+func (h *sessionHandle) HandlePlay(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse, timeRange *rtsp.RangeTime, info []*rtsp.RtpInfo) {
+    if h.user != nil {
+        if h.user.HandlePlay(svr, req, res, timeRange, info); res.StatusCode != rtsp.OK {
+            return
+        }
+    }
+    sess := h.sess
+    if sess.publisher {
+        res.StatusCode = rtsp.BAD_REQUEST
+        return
+    }
+    stream, found := sess.srv.Stream(sess.path)
+    if !found || !stream.addReader(sess) {
+        res.StatusCode = rtsp.Not_Found
+        return
+    }
+    sess.stream = stream
+    sess.playing = true
+    sess.joinMulticast(stream)
+}
+
+func (h *sessionHandle) HandleRecord(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse, timeRange *rtsp.RangeTime, info []*rtsp.RtpInfo) {
+    if h.user != nil {
+        if h.user.HandleRecord(svr, req, res, timeRange, info); res.StatusCode != rtsp.OK {
+            return
+        }
+    }
+    sess := h.sess
+    if sess.stream != nil {
+        return
+    }
+    if len(sess.tracks) == 0 {
+        res.StatusCode = rtsp.BAD_REQUEST
+        return
+    }
+    names := make([]string, 0, len(sess.tracks))
+    for name := range sess.tracks {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    tracks := make([]*rtsp.RtspTrack, 0, len(names))
+    for _, name := range names {
+        tracks = append(tracks, sess.tracks[name])
+    }
+    stream, err := sess.srv.publish(sess.path, sess, tracks)
+    if err != nil {
+        res.StatusCode = rtsp.BAD_REQUEST
+        return
+    }
+    sess.publisher = true
+    sess.stream = stream
+    for _, name := range names {
+        trackName := name
+        sess.tracks[name].OnSample(func(sample rtsp.RtspSample) {
+            stream.WriteSample(trackName, sample)
+        })
+    }
+}
+
+func (h *sessionHandle) HandlePause(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        if h.user.HandlePause(svr, req, res); res.StatusCode != rtsp.OK {
+            return
+        }
+    }
+    h.sess.playing = false
+    if h.sess.stream != nil && !h.sess.publisher {
+        h.sess.leaveMulticast(h.sess.stream)
+    }
+}
+
+func (h *sessionHandle) HandleTeardown(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        h.user.HandleTeardown(svr, req, res)
+    }
+    h.sess.closing = true
+}
+
+func (h *sessionHandle) HandleGetParameter(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        h.user.HandleGetParameter(svr, req, res)
+    }
+}
+
+func (h *sessionHandle) HandleSetParameter(svr *rtsp.RtspServer, req rtsp.RtspRequest, res *rtsp.RtspResponse) {
+    if h.user != nil {
+        h.user.HandleSetParameter(svr, req, res)
+    }
+}
+
+func (h *sessionHandle) HandleResponse(svr *rtsp.RtspServer, res rtsp.RtspResponse) {
+    if h.user != nil {
+        h.user.HandleResponse(svr, res)
+    }
+}
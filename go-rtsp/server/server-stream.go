@@ -0,0 +1,118 @@
+package server
+
+import (
+    "sync"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+)
+
+// Stream is a published path,the samples of publisher are sent to all the playing readers
+type Stream struct {
+    path      string
+    publisher *Session //nil for the stream added by Server.AddStream
+    tracks    []*rtsp.RtspTrack
+    mtx       sync.Mutex
+    readers   []*Session
+    closed    bool
+    multicast *multicastGroup //allocated by the first DESCRIBE if multicast is enabled
+}
+
+func newStream(path string, publisher *Session, tracks []*rtsp.RtspTrack) *Stream {
+    return &Stream{
+        path:      path,
+        publisher: publisher,
+        tracks:    tracks,
+    }
+}
+
+func (s *Stream) Path() string {
+    return s.path
+}
+
+// the tracks of publisher,only the codec and fmtp are used by readers
+func (s *Stream) Tracks() []*rtsp.RtspTrack {
+    return s.tracks
+}
+
+func (s *Stream) Readers() int {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    return len(s.readers)
+}
+
+func (s *Stream) addReader(reader *Session) bool {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    if s.closed {
+        return false
+    }
+    for _, r := range s.readers {
+        if r == reader {
+            return true
+        }
+    }
+    s.readers = append(s.readers, reader)
+    return true
+}
+
+func (s *Stream) removeReader(reader *Session) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    for i, r := range s.readers {
+        if r == reader {
+            s.readers = append(s.readers[:i], s.readers[i+1:]...)
+            break
+        }
+    }
+}
+
+// return the readers to be closed and the multicast group to be released
+func (s *Stream) close() ([]*Session, *multicastGroup) {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    s.closed = true
+    readers := s.readers
+    s.readers = nil
+    group := s.multicast
+    s.multicast = nil
+    return readers, group
+}
+
+func (s *Stream) multicastGroup() *multicastGroup {
+    s.mtx.Lock()
+    defer s.mtx.Unlock()
+    return s.multicast
+}
+
+// WriteSample sends the sample to the track of the same name of all the readers and the multicast group,
+// the reader failed to write is closed
+func (s *Stream) WriteSample(trackName string, sample rtsp.RtspSample) {
+    if sample.Cid == rtsp.RTSP_CODEC_AAC || sample.Cid == rtsp.RTSP_CODEC_AAC_LATM {
+        sample.Sample = stripADTS(sample.Sample)
+    }
+    //the lock of reader is not held with the lock of stream
+    s.mtx.Lock()
+    readers := make([]*Session, len(s.readers))
+    copy(readers, s.readers)
+    group := s.multicast
+    s.mtx.Unlock()
+    for _, reader := range readers {
+        if err := reader.writeSample(trackName, sample); err != nil {
+            reader.Close()
+        }
+    }
+    if group != nil {
+        group.writeSample(trackName, sample)
+    }
+}
+
+// rtp of aac(rfc3640) and latm(rfc6416) carries raw access unit
+func stripADTS(frame []byte) []byte {
+    if len(frame) < 7 || frame[0] != 0xFF || frame[1]&0xF0 != 0xF0 {
+        return frame
+    }
+    if frame[1]&0x01 == 0 && len(frame) >= 9 {
+        return frame[9:]
+    }
+    return frame[7:]
+}
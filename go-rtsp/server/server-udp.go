@@ -0,0 +1,100 @@
+package server
+
+import (
+    "errors"
+    "net"
+    "sync"
+)
+
+// portAllocator allocates the even rtp port and the next rtcp port in [min, max]
+type portAllocator struct {
+    mtx  sync.Mutex
+    min  int
+    max  int
+    next int
+}
+
+func newPortAllocator(min, max int) *portAllocator {
+    if min%2 != 0 {
+        min++
+    }
+    return &portAllocator{min: min, max: max, next: min}
+}
+
+func (pa *portAllocator) allocate(ip net.IP) (rtp *net.UDPConn, rtcp *net.UDPConn, err error) {
+    if pa.min <= 0 || pa.max <= pa.min {
+        //the ports are allocated by system
+        for i := 0; i < 100; i++ {
+            if rtp, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip}); err != nil {
+                return nil, nil, err
+            }
+            port := rtp.LocalAddr().(*net.UDPAddr).Port
+            if port%2 == 0 {
+                if rtcp, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port + 1}); err == nil {
+                    return rtp, rtcp, nil
+                }
+            }
+            rtp.Close()
+        }
+        return nil, nil, errors.New("no free udp port pair")
+    }
+    pa.mtx.Lock()
+    defer pa.mtx.Unlock()
+    for i := 0; i < (pa.max-pa.min)/2+1; i++ {
+        port := pa.next
+        pa.next += 2
+        if pa.next+1 > pa.max {
+            pa.next = pa.min
+        }
+        if rtp, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port}); err != nil {
+            continue
+        }
+        if rtcp, err = net.ListenUDP("udp", &net.UDPAddr{IP: ip, Port: port + 1}); err != nil {
+            rtp.Close()
+            continue
+        }
+        return rtp, rtcp, nil
+    }
+    return nil, nil, errors.New("no free udp port pair")
+}
+
+// udpPair is the rtp/rtcp sockets of one track over udp
+type udpPair struct {
+    rtp      *net.UDPConn
+    rtcp     *net.UDPConn
+    rtpPeer  *net.UDPAddr
+    rtcpPeer *net.UDPAddr
+}
+
+func (pair *udpPair) serverPorts() (uint16, uint16) {
+    return uint16(pair.rtp.LocalAddr().(*net.UDPAddr).Port), uint16(pair.rtcp.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (pair *udpPair) write(pkt []byte, isRtcp bool) (err error) {
+    if isRtcp {
+        _, err = pair.rtcp.WriteToUDP(pkt, pair.rtcpPeer)
+    } else {
+        _, err = pair.rtp.WriteToUDP(pkt, pair.rtpPeer)
+    }
+    return
+}
+
+// the packets from other address are dropped
+func (pair *udpPair) readLoop(conn *net.UDPConn, peer *net.UDPAddr, onPacket func(pkt []byte)) {
+    buf := make([]byte, 65536)
+    for {
+        n, from, err := conn.ReadFromUDP(buf)
+        if err != nil {
+            return
+        }
+        if !from.IP.Equal(peer.IP) {
+            continue
+        }
+        onPacket(buf[:n])
+    }
+}
+
+func (pair *udpPair) close() {
+    pair.rtp.Close()
+    pair.rtcp.Close()
+}
@@ -0,0 +1,424 @@
+package server
+
+import (
+    "bytes"
+    "errors"
+    "net"
+    "strings"
+    "sync"
+    "syscall"
+    "time"
+
+    "github.com/yapingcat/gomedia/go-rtsp"
+    "github.com/yapingcat/gomedia/go-rtsp/srtp"
+)
+
+const (
+    DEFAULT_SESSION_TIMEOUT = 60 * time.Second
+    DEFAULT_WRITE_TIMEOUT   = 10 * time.Second
+)
+
+type ServerConfig struct {
+    ListenAddr     string //tcp,e.g. 0.0.0.0:554
+    UserName       string //digest authentication if it is not empty
+    Password       string
+    SessionTimeout time.Duration //DEFAULT_SESSION_TIMEOUT if zero
+    WriteTimeout   time.Duration //DEFAULT_WRITE_TIMEOUT if zero,the slow reader is closed
+    RtpPortMin     int           //udp port pairs for rtp/rtcp,the ports are allocated by system if zero
+    RtpPortMax     int
+    DisableUdp     bool
+    //the group of the first stream,e.g. 239.0.0.1,every stream has its own group,
+    //multicast is disabled if it is empty
+    MulticastAddress string
+    MulticastPort    int //the rtp port of the first track,the next track uses port+2,DEFAULT_MULTICAST_PORT if zero
+    //rtsp over http(quicktime tunnel) is accepted on the same port
+    DisableHttpTunnel bool
+    //the RTSP/2.0 requests are replied with 505,the clients fall back to RTSP/1.0
+    DisableRtsp2 bool
+    //the media sent to the readers is protected by srtp with the key of a=crypto if it is not zero,
+    //the publishers choose by themselves
+    Srtp srtp.ProtectionProfile
+    // called before the built-in handling,
+    // the request is rejected if the status code of response is not 200,e.g. Not_Found
+    Handle rtsp.ServerHandle
+}
+
+// Server is a networked rtsp server,
+// the publishers push streams by ANNOUNCE/RECORD and the readers play them by DESCRIBE/PLAY with the same path,
+// rtsp over http is served on the same port
+type Server struct {
+    cfg        ServerConfig
+    listener   net.Listener
+    ports      *portAllocator
+    mcast      *multicastAllocator //nil if multicast is disabled
+    mtx        sync.Mutex
+    streams    map[string]*Stream
+    sessions   map[*Session]struct{}
+    tunnels    map[string]*Session   //x-sessioncookie of rtsp over http
+    handshakes map[net.Conn]struct{} //the connections whose first request is not received
+    closed     chan struct{}
+    wg         sync.WaitGroup
+    // called when a stream is published or removed
+    OnPublish   func(stream *Stream)
+    OnUnpublish func(stream *Stream)
+}
+
+func NewServer(cfg ServerConfig) *Server {
+    if cfg.SessionTimeout == 0 {
+        cfg.SessionTimeout = DEFAULT_SESSION_TIMEOUT
+    }
+    if cfg.WriteTimeout == 0 {
+        cfg.WriteTimeout = DEFAULT_WRITE_TIMEOUT
+    }
+    if cfg.MulticastPort == 0 {
+        cfg.MulticastPort = DEFAULT_MULTICAST_PORT
+    }
+    return &Server{
+        cfg:        cfg,
+        ports:      newPortAllocator(cfg.RtpPortMin, cfg.RtpPortMax),
+        mcast:      newMulticastAllocator(cfg.MulticastAddress),
+        streams:    make(map[string]*Stream),
+        sessions:   make(map[*Session]struct{}),
+        tunnels:    make(map[string]*Session),
+        handshakes: make(map[net.Conn]struct{}),
+        closed:     make(chan struct{}),
+    }
+}
+
+func (srv *Server) Start() error {
+    listener, err := net.Listen("tcp", srv.cfg.ListenAddr)
+    if err != nil {
+        return err
+    }
+    srv.listener = listener
+    srv.wg.Add(2)
+    go srv.serve()
+    go srv.checkTimeout()
+    return nil
+}
+
+func (srv *Server) Addr() net.Addr {
+    return srv.listener.Addr()
+}
+
+func (srv *Server) LocalPort() int {
+    return srv.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (srv *Server) isClosed() bool {
+    select {
+    case <-srv.closed:
+        return true
+    default:
+        return false
+    }
+}
+
+// close the listener and all the sessions
+func (srv *Server) Close() {
+    select {
+    case <-srv.closed:
+        return
+    default:
+        close(srv.closed)
+    }
+    srv.listener.Close()
+    srv.mtx.Lock()
+    sessions := make([]*Session, 0, len(srv.sessions))
+    for sess := range srv.sessions {
+        sessions = append(sessions, sess)
+    }
+    for conn := range srv.handshakes {
+        conn.Close()
+    }
+    srv.mtx.Unlock()
+    for _, sess := range sessions {
+        sess.Close()
+    }
+    srv.wg.Wait()
+}
+
+func (srv *Server) serve() {
+    defer srv.wg.Done()
+    for {
+        conn, err := srv.listener.Accept()
+        if err != nil {
+            select {
+            case <-srv.closed:
+                return
+            default:
+            }
+            if isRetryableAcceptError(err) {
+                time.Sleep(10 * time.Millisecond)
+                continue
+            }
+            return
+        }
+        srv.mtx.Lock()
+        if srv.isClosed() {
+            //accepted just before Close,which has taken the snapshot of handshakes
+            srv.mtx.Unlock()
+            conn.Close()
+            return
+        }
+        srv.handshakes[conn] = struct{}{}
+        srv.mtx.Unlock()
+        srv.wg.Add(1)
+        go srv.handleConn(conn)
+    }
+}
+
+// the listener is still usable after timeout,running out of file descriptors
+// or the connection aborted before it is accepted
+func isRetryableAcceptError(err error) bool {
+    if errors.Is(err, net.ErrClosed) {
+        return false
+    }
+    var ne net.Error
+    if errors.As(err, &ne) && ne.Timeout() {
+        return true
+    }
+    return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) || errors.Is(err, syscall.ECONNABORTED)
+}
+
+// the first request decides whether the connection is rtsp or a channel of rtsp over http
+func (srv *Server) handleConn(conn net.Conn) {
+    defer srv.wg.Done()
+    req, buf, err := srv.handshake(conn)
+    srv.mtx.Lock()
+    delete(srv.handshakes, conn)
+    srv.mtx.Unlock()
+    if err != nil {
+        conn.Close()
+        return
+    }
+    if req == nil {
+        srv.serveSession(newSession(srv, conn), buf)
+        return
+    }
+    if req.Method == "POST" {
+        srv.mtx.Lock()
+        sess, found := srv.tunnels[req.Cookie]
+        srv.mtx.Unlock()
+        if !found {
+            conn.Close()
+            return
+        }
+        sess.servePost(conn, buf)
+        return
+    }
+    sess := newSession(srv, conn)
+    sess.tunnel = rtsp.NewHttpTunnelServer(req.Cookie)
+    sess.tunnel.OnRtsp(sess.input)
+    srv.mtx.Lock()
+    if _, found := srv.tunnels[req.Cookie]; found {
+        srv.mtx.Unlock()
+        conn.Close()
+        return
+    }
+    srv.tunnels[req.Cookie] = sess
+    srv.mtx.Unlock()
+    if err = sess.write(sess.tunnel.Response()); err == nil {
+        srv.serveSession(sess, nil)
+    } else {
+        sess.Close()
+    }
+    srv.mtx.Lock()
+    delete(srv.tunnels, req.Cookie)
+    srv.mtx.Unlock()
+}
+
+// return the http tunnel request and the data following it,
+// or nil and the data read for rtsp
+func (srv *Server) handshake(conn net.Conn) (*rtsp.HttpTunnelRequest, []byte, error) {
+    conn.SetReadDeadline(time.Now().Add(srv.cfg.SessionTimeout))
+    defer conn.SetReadDeadline(time.Time{})
+    buf := make([]byte, 0, 4096)
+    tmp := make([]byte, 4096)
+    for {
+        n, err := conn.Read(tmp)
+        if err != nil {
+            return nil, nil, err
+        }
+        buf = append(buf, tmp[:n]...)
+        if len(buf) < 5 && (bytes.HasPrefix([]byte("GET "), buf) || bytes.HasPrefix([]byte("POST "), buf)) {
+            continue
+        }
+        if srv.cfg.DisableHttpTunnel || !rtsp.IsHttpTunnel(buf) {
+            return nil, buf, nil
+        }
+        req, ret, err := rtsp.ParseHttpTunnelRequest(buf)
+        if err != nil {
+            return nil, nil, err
+        }
+        if ret > 0 {
+            return req, buf[ret:], nil
+        }
+        if len(buf) > 8192 {
+            return nil, nil, errors.New("http tunnel request is too large")
+        }
+    }
+}
+
+// the session is registered under the lock with the closed flag checked,
+// Close either finds it or it is closed here
+func (srv *Server) serveSession(sess *Session, first []byte) {
+    srv.mtx.Lock()
+    if srv.isClosed() {
+        srv.mtx.Unlock()
+        sess.Close()
+        return
+    }
+    srv.sessions[sess] = struct{}{}
+    srv.mtx.Unlock()
+    sess.serve(first)
+    srv.mtx.Lock()
+    delete(srv.sessions, sess)
+    srv.mtx.Unlock()
+}
+
+// the session without keepalive(rtsp request,rtp or rtcp) is closed
+func (srv *Server) checkTimeout() {
+    defer srv.wg.Done()
+    interval := srv.cfg.SessionTimeout / 4
+    if interval < 100*time.Millisecond {
+        interval = 100 * time.Millisecond
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-srv.closed:
+            return
+        case now := <-ticker.C:
+            var expired []*Session
+            srv.mtx.Lock()
+            for sess := range srv.sessions {
+                if now.Sub(sess.LastActive()) > srv.cfg.SessionTimeout {
+                    expired = append(expired, sess)
+                }
+            }
+            srv.mtx.Unlock()
+            for _, sess := range expired {
+                sess.Close()
+            }
+        }
+    }
+}
+
+// the path of rtsp url without the leading and trailing '/',e.g. live/cam1
+func streamPath(uri string) string {
+    if idx := strings.Index(uri, "://"); idx >= 0 {
+        uri = uri[idx+3:]
+        if idx = strings.Index(uri, "/"); idx >= 0 {
+            uri = uri[idx:]
+        } else {
+            uri = ""
+        }
+    }
+    if idx := strings.IndexAny(uri, "?#"); idx >= 0 {
+        uri = uri[:idx]
+    }
+    return strings.Trim(uri, "/")
+}
+
+func (srv *Server) Stream(path string) (*Stream, bool) {
+    srv.mtx.Lock()
+    defer srv.mtx.Unlock()
+    stream, found := srv.streams[streamPath(path)]
+    return stream, found
+}
+
+func (srv *Server) Streams() []*Stream {
+    srv.mtx.Lock()
+    defer srv.mtx.Unlock()
+    streams := make([]*Stream, 0, len(srv.streams))
+    for _, stream := range srv.streams {
+        streams = append(streams, stream)
+    }
+    return streams
+}
+
+// AddStream publishes a local stream,the samples are written by Stream.WriteSample,
+// the tracks describe the codec of the samples
+func (srv *Server) AddStream(path string, tracks ...*rtsp.RtspTrack) (*Stream, error) {
+    return srv.publish(streamPath(path), nil, tracks)
+}
+
+func (srv *Server) RemoveStream(path string) {
+    if stream, found := srv.Stream(path); found {
+        srv.unpublish(stream)
+    }
+}
+
+func (srv *Server) publish(path string, publisher *Session, tracks []*rtsp.RtspTrack) (*Stream, error) {
+    srv.mtx.Lock()
+    if _, found := srv.streams[path]; found {
+        srv.mtx.Unlock()
+        return nil, errors.New("stream " + path + " is already published")
+    }
+    stream := newStream(path, publisher, tracks)
+    srv.streams[path] = stream
+    srv.mtx.Unlock()
+    if srv.OnPublish != nil {
+        srv.OnPublish(stream)
+    }
+    return stream, nil
+}
+
+// the options of the tracks sent to the readers
+func (srv *Server) trackOptions() []rtsp.TrackOption {
+    if srv.cfg.Srtp == 0 {
+        return nil
+    }
+    return []rtsp.TrackOption{rtsp.WithSrtp(srv.cfg.Srtp)}
+}
+
+// the multicast group of the stream is shared by all the readers
+func (srv *Server) multicastGroup(stream *Stream) (*multicastGroup, error) {
+    if srv.mcast == nil {
+        return nil, errors.New("multicast is disabled")
+    }
+    stream.mtx.Lock()
+    defer stream.mtx.Unlock()
+    if stream.closed {
+        return nil, errors.New("stream " + stream.path + " is closed")
+    }
+    if stream.multicast != nil {
+        return stream.multicast, nil
+    }
+    ip, err := srv.mcast.allocate()
+    if err != nil {
+        return nil, err
+    }
+    group, err := newMulticastGroup(ip, srv.cfg.MulticastPort, stream.tracks, srv.trackOptions()...)
+    if err != nil {
+        srv.mcast.release(ip)
+        return nil, err
+    }
+    stream.multicast = group
+    return group, nil
+}
+
+// the readers of the stream are closed
+func (srv *Server) unpublish(stream *Stream) {
+    srv.mtx.Lock()
+    if srv.streams[stream.path] != stream {
+        srv.mtx.Unlock()
+        return
+    }
+    delete(srv.streams, stream.path)
+    srv.mtx.Unlock()
+    readers, group := stream.close()
+    for _, reader := range readers {
+        reader.notifyEndOfStream()
+        reader.Close()
+    }
+    if group != nil {
+        group.close()
+        srv.mcast.release(group.group)
+    }
+    if srv.OnUnpublish != nil {
+        srv.OnUnpublish(stream)
+    }
+}
@@ -0,0 +1,43 @@
+package srtp
+
+// the sliding window of the received indexes,rfc3711 3.3.2
+const REPLAY_WINDOW_SIZE = 64
+
+type replayWindow struct {
+    started bool
+    max     uint64 //the highest index received
+    bitmap  uint64 //bit i is the index max-i
+}
+
+func (w *replayWindow) check(index uint64) bool {
+    if !w.started || index > w.max {
+        return true
+    }
+    diff := w.max - index
+    if diff >= REPLAY_WINDOW_SIZE {
+        return false
+    }
+    return w.bitmap&(1<<diff) == 0
+}
+
+// it is called after the packet is authenticated
+func (w *replayWindow) accept(index uint64) {
+    if !w.started {
+        w.started = true
+        w.max = index
+        w.bitmap = 1
+        return
+    }
+    if index > w.max {
+        shift := index - w.max
+        if shift >= REPLAY_WINDOW_SIZE {
+            w.bitmap = 0
+        } else {
+            w.bitmap <<= shift
+        }
+        w.bitmap |= 1
+        w.max = index
+        return
+    }
+    w.bitmap |= 1 << (w.max - index)
+}
@@ -0,0 +1,99 @@
+package srtp
+
+import (
+    "crypto/rand"
+    "encoding/base64"
+    "errors"
+    "strconv"
+    "strings"
+)
+
+// sdes key exchange(rfc4568),the key of the sender is announced in sdp
+// a=crypto:1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20
+type CryptoAttribute struct {
+    Tag           int
+    Profile       ProtectionProfile
+    MasterKey     []byte
+    MasterSalt    []byte
+    Lifetime      string   //e.g. 2^31,it is not checked
+    SessionParams []string //only KDR=0,the packets are always encrypted and authenticated
+}
+
+// the master key and salt are random
+func NewCryptoAttribute(tag int, profile ProtectionProfile) (*CryptoAttribute, error) {
+    if _, err := ProfileBySuite(profile.String()); err != nil {
+        return nil, err
+    }
+    keySalt := make([]byte, profile.KeyLen()+profile.SaltLen())
+    if _, err := rand.Read(keySalt); err != nil {
+        return nil, err
+    }
+    return &CryptoAttribute{
+        Tag:        tag,
+        Profile:    profile,
+        MasterKey:  keySalt[:profile.KeyLen()],
+        MasterSalt: keySalt[profile.KeyLen():],
+    }, nil
+}
+
+// the value of a=crypto,only the first key-param is used
+func ParseCryptoAttribute(value string) (*CryptoAttribute, error) {
+    fields := strings.Fields(value)
+    if len(fields) < 3 {
+        return nil, errors.New("illegal crypto attribute")
+    }
+    attr := &CryptoAttribute{SessionParams: fields[3:]}
+    for _, param := range attr.SessionParams {
+        //UNENCRYPTED_SRTP,UNENCRYPTED_SRTCP,UNAUTHENTICATED_SRTP,FEC_ORDER... are not supported
+        if param != "KDR=0" {
+            return nil, errors.New("unsupport crypto session parameter " + param)
+        }
+    }
+    var err error
+    if attr.Tag, err = strconv.Atoi(fields[0]); err != nil {
+        return nil, errors.New("illegal crypto tag " + fields[0])
+    }
+    if attr.Profile, err = ProfileBySuite(fields[1]); err != nil {
+        return nil, err
+    }
+    keyParam := strings.Split(fields[2], ";")[0]
+    if !strings.HasPrefix(keyParam, "inline:") {
+        return nil, errors.New("unsupport key method " + keyParam)
+    }
+    params := strings.Split(keyParam[len("inline:"):], "|")
+    keySalt, err := base64.StdEncoding.DecodeString(params[0])
+    if err != nil {
+        //the padding may be omitted
+        if keySalt, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(params[0], "=")); err != nil {
+            return nil, err
+        }
+    }
+    if len(keySalt) != attr.Profile.KeyLen()+attr.Profile.SaltLen() {
+        return nil, errors.New("wrong length of inline key")
+    }
+    attr.MasterKey = keySalt[:attr.Profile.KeyLen()]
+    attr.MasterSalt = keySalt[attr.Profile.KeyLen():]
+    for _, param := range params[1:] {
+        if strings.Contains(param, ":") {
+            return nil, errors.New("mki is not supported")
+        }
+        attr.Lifetime = param
+    }
+    return attr, nil
+}
+
+func (attr *CryptoAttribute) Encode() string {
+    keySalt := append(append([]byte{}, attr.MasterKey...), attr.MasterSalt...)
+    str := strconv.Itoa(attr.Tag) + " " + attr.Profile.String() + " inline:" + base64.StdEncoding.EncodeToString(keySalt)
+    if attr.Lifetime != "" {
+        str += "|" + attr.Lifetime
+    }
+    for _, param := range attr.SessionParams {
+        str += " " + param
+    }
+    return str
+}
+
+func (attr *CryptoAttribute) NewContext() (*Context, error) {
+    return NewContext(attr.Profile, attr.MasterKey, attr.MasterSalt)
+}
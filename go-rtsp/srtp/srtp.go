@@ -0,0 +1,437 @@
+package srtp
+
+import (
+    "crypto/aes"
+    "crypto/cipher"
+    "crypto/hmac"
+    "crypto/sha1"
+    "encoding/binary"
+    "errors"
+    "hash"
+)
+
+// SRTP/SRTCP(rfc3711) with AES-CM and HMAC-SHA1,AEAD AES-GCM(rfc7714),
+// a Context protects or unprotects the packets of one direction,it is not safe for concurrent use
+
+type ProtectionProfile int
+
+const (
+    PROFILE_AES128_CM_HMAC_SHA1_80 ProtectionProfile = iota + 1
+    PROFILE_AES128_CM_HMAC_SHA1_32
+    PROFILE_AEAD_AES_128_GCM
+    PROFILE_AEAD_AES_256_GCM
+)
+
+// the crypto-suite of sdes(rfc4568,rfc7714)
+func (profile ProtectionProfile) String() string {
+    switch profile {
+    case PROFILE_AES128_CM_HMAC_SHA1_80:
+        return "AES_CM_128_HMAC_SHA1_80"
+    case PROFILE_AES128_CM_HMAC_SHA1_32:
+        return "AES_CM_128_HMAC_SHA1_32"
+    case PROFILE_AEAD_AES_128_GCM:
+        return "AEAD_AES_128_GCM"
+    case PROFILE_AEAD_AES_256_GCM:
+        return "AEAD_AES_256_GCM"
+    }
+    return "unknown"
+}
+
+func ProfileBySuite(suite string) (ProtectionProfile, error) {
+    for _, profile := range []ProtectionProfile{PROFILE_AES128_CM_HMAC_SHA1_80, PROFILE_AES128_CM_HMAC_SHA1_32, PROFILE_AEAD_AES_128_GCM, PROFILE_AEAD_AES_256_GCM} {
+        if profile.String() == suite {
+            return profile, nil
+        }
+    }
+    return 0, errors.New("unsupport crypto suite " + suite)
+}
+
+// the length of master key
+func (profile ProtectionProfile) KeyLen() int {
+    if profile == PROFILE_AEAD_AES_256_GCM {
+        return 32
+    }
+    return 16
+}
+
+// the length of master salt
+func (profile ProtectionProfile) SaltLen() int {
+    if profile.isAead() {
+        return 12
+    }
+    return 14
+}
+
+func (profile ProtectionProfile) isAead() bool {
+    return profile == PROFILE_AEAD_AES_128_GCM || profile == PROFILE_AEAD_AES_256_GCM
+}
+
+func (profile ProtectionProfile) rtpTagLen() int {
+    switch profile {
+    case PROFILE_AES128_CM_HMAC_SHA1_32:
+        return 4
+    case PROFILE_AEAD_AES_128_GCM, PROFILE_AEAD_AES_256_GCM:
+        return 16
+    }
+    return 10
+}
+
+// the tag of srtcp is 80 bits for AES_CM_128_HMAC_SHA1_32 too
+func (profile ProtectionProfile) rtcpTagLen() int {
+    if profile.isAead() {
+        return 16
+    }
+    return 10
+}
+
+const (
+    labelRtpEncryption  = 0x00
+    labelRtpAuth        = 0x01
+    labelRtpSalt        = 0x02
+    labelRtcpEncryption = 0x03
+    labelRtcpAuth       = 0x04
+    labelRtcpSalt       = 0x05
+)
+
+const (
+    authKeyLen   = 20
+    maxRtcpIndex = 0x7FFFFFFF
+    rtcpEFlag    = 0x80000000
+)
+
+var (
+    errShortPacket = errors.New("srtp packet is too short")
+    errAuthFailed  = errors.New("srtp authentication failed")
+    errReplayed    = errors.New("srtp packet is replayed")
+)
+
+// the session keys of srtp or srtcp
+type sessionKeys struct {
+    block cipher.Block
+    salt  []byte
+    auth  hash.Hash   //nil if aead
+    aead  cipher.AEAD //nil if AES-CM
+}
+
+// the state of a SSRC,the rollover counter and the replay list
+type ssrcState struct {
+    started    bool
+    roc        uint32
+    lastSeq    uint16
+    rtpReplay  replayWindow
+    rtcpIndex  uint32 //the index of the next srtcp packet sent
+    rtcpReplay replayWindow
+}
+
+type Context struct {
+    profile ProtectionProfile
+    rtp     sessionKeys
+    rtcp    sessionKeys
+    streams map[uint32]*ssrcState
+}
+
+func NewContext(profile ProtectionProfile, masterKey []byte, masterSalt []byte) (*Context, error) {
+    if profile < PROFILE_AES128_CM_HMAC_SHA1_80 || profile > PROFILE_AEAD_AES_256_GCM {
+        return nil, errors.New("unsupport srtp protection profile")
+    }
+    if len(masterKey) != profile.KeyLen() || len(masterSalt) != profile.SaltLen() {
+        return nil, errors.New("wrong length of srtp master key or salt")
+    }
+    ctx := &Context{profile: profile, streams: make(map[uint32]*ssrcState)}
+    var err error
+    if ctx.rtp, err = newSessionKeys(profile, masterKey, masterSalt, labelRtpEncryption, labelRtpAuth, labelRtpSalt); err != nil {
+        return nil, err
+    }
+    if ctx.rtcp, err = newSessionKeys(profile, masterKey, masterSalt, labelRtcpEncryption, labelRtcpAuth, labelRtcpSalt); err != nil {
+        return nil, err
+    }
+    return ctx, nil
+}
+
+func newSessionKeys(profile ProtectionProfile, masterKey []byte, masterSalt []byte, encLabel, authLabel, saltLabel byte) (keys sessionKeys, err error) {
+    kdf, err := aes.NewCipher(masterKey)
+    if err != nil {
+        return
+    }
+    if keys.block, err = aes.NewCipher(deriveKey(kdf, masterSalt, encLabel, len(masterKey))); err != nil {
+        return
+    }
+    keys.salt = deriveKey(kdf, masterSalt, saltLabel, len(masterSalt))
+    if profile.isAead() {
+        keys.aead, err = cipher.NewGCM(keys.block)
+    } else {
+        keys.auth = hmac.New(sha1.New, deriveKey(kdf, masterSalt, authLabel, authKeyLen))
+    }
+    return
+}
+
+// the key derivation of rfc3711 4.3 with key_derivation_rate 0,
+// the keystream of AES-CM whose IV is (master_salt XOR label<<48)<<16
+func deriveKey(kdf cipher.Block, masterSalt []byte, label byte, length int) []byte {
+    iv := make([]byte, aes.BlockSize)
+    copy(iv, masterSalt)
+    iv[7] ^= label
+    out := make([]byte, length)
+    cipher.NewCTR(kdf, iv).XORKeyStream(out, out)
+    return out
+}
+
+func (ctx *Context) Profile() ProtectionProfile {
+    return ctx.profile
+}
+
+func (ctx *Context) stream(ssrc uint32) *ssrcState {
+    state, found := ctx.streams[ssrc]
+    if !found {
+        state = &ssrcState{}
+        ctx.streams[ssrc] = state
+    }
+    return state
+}
+
+// the rollover counter of the SSRC,the receiver joins the stream with it if the sender has sent 65536 packets
+func (ctx *Context) SetRolloverCounter(ssrc uint32, roc uint32) {
+    ctx.stream(ssrc).roc = roc
+}
+
+func (ctx *Context) RolloverCounter(ssrc uint32) uint32 {
+    return ctx.stream(ssrc).roc
+}
+
+// ProtectRtp encrypts the payload and appends the authentication tag,the packet is not modified
+func (ctx *Context) ProtectRtp(packet []byte) ([]byte, error) {
+    hdrLen, err := rtpHeaderLen(packet)
+    if err != nil {
+        return nil, err
+    }
+    ssrc := binary.BigEndian.Uint32(packet[8:])
+    seq := binary.BigEndian.Uint16(packet[2:])
+    state := ctx.stream(ssrc)
+    roc := state.estimate(seq)
+    state.update(seq, roc)
+
+    out := make([]byte, len(packet), len(packet)+ctx.profile.rtpTagLen())
+    copy(out, packet[:hdrLen])
+    if ctx.rtp.aead != nil {
+        return ctx.rtp.aead.Seal(out[:hdrLen], ctx.rtpNonce(ssrc, roc, seq), packet[hdrLen:], packet[:hdrLen]), nil
+    }
+    cipher.NewCTR(ctx.rtp.block, counterIV(ctx.rtp.salt, ssrc, uint64(roc)<<16|uint64(seq))).XORKeyStream(out[hdrLen:], packet[hdrLen:])
+    return append(out, ctx.rtpTag(out, roc)...), nil
+}
+
+// UnprotectRtp authenticates and decrypts the packet,the replayed packet is rejected
+func (ctx *Context) UnprotectRtp(packet []byte) ([]byte, error) {
+    hdrLen, err := rtpHeaderLen(packet)
+    if err != nil {
+        return nil, err
+    }
+    tagLen := ctx.profile.rtpTagLen()
+    if len(packet) < hdrLen+tagLen {
+        return nil, errShortPacket
+    }
+    ssrc := binary.BigEndian.Uint32(packet[8:])
+    seq := binary.BigEndian.Uint16(packet[2:])
+    state := ctx.stream(ssrc)
+    roc := state.estimate(seq)
+    index := uint64(roc)<<16 | uint64(seq)
+    if !state.rtpReplay.check(index) {
+        return nil, errReplayed
+    }
+
+    var out []byte
+    if ctx.rtp.aead != nil {
+        out = make([]byte, hdrLen, len(packet)-tagLen)
+        copy(out, packet[:hdrLen])
+        if out, err = ctx.rtp.aead.Open(out, ctx.rtpNonce(ssrc, roc, seq), packet[hdrLen:], packet[:hdrLen]); err != nil {
+            return nil, errAuthFailed
+        }
+    } else {
+        n := len(packet) - tagLen
+        if !hmac.Equal(ctx.rtpTag(packet[:n], roc), packet[n:]) {
+            return nil, errAuthFailed
+        }
+        out = make([]byte, n)
+        copy(out, packet[:hdrLen])
+        cipher.NewCTR(ctx.rtp.block, counterIV(ctx.rtp.salt, ssrc, index)).XORKeyStream(out[hdrLen:], packet[hdrLen:n])
+    }
+    state.rtpReplay.accept(index)
+    state.update(seq, roc)
+    return out, nil
+}
+
+// ProtectRtcp encrypts the compound packet except the first 8 bytes,
+// the E flag and srtcp index are appended before the authentication tag
+func (ctx *Context) ProtectRtcp(packet []byte) ([]byte, error) {
+    if len(packet) < 8 {
+        return nil, errShortPacket
+    }
+    ssrc := binary.BigEndian.Uint32(packet[4:])
+    state := ctx.stream(ssrc)
+    index := state.rtcpIndex
+    state.rtcpIndex = (state.rtcpIndex + 1) & maxRtcpIndex
+
+    trailer := make([]byte, 4)
+    binary.BigEndian.PutUint32(trailer, index|rtcpEFlag)
+    out := make([]byte, 8, len(packet)+4+ctx.profile.rtcpTagLen())
+    copy(out, packet[:8])
+    if ctx.rtcp.aead != nil {
+        out = ctx.rtcp.aead.Seal(out, ctx.rtcpNonce(ssrc, index), packet[8:], append(append([]byte{}, packet[:8]...), trailer...))
+        return append(out, trailer...), nil
+    }
+    out = out[:len(packet)]
+    cipher.NewCTR(ctx.rtcp.block, counterIV(ctx.rtcp.salt, ssrc, uint64(index))).XORKeyStream(out[8:], packet[8:])
+    out = append(out, trailer...)
+    return append(out, ctx.rtcpTag(out)...), nil
+}
+
+func (ctx *Context) UnprotectRtcp(packet []byte) ([]byte, error) {
+    tagLen := ctx.profile.rtcpTagLen()
+    if len(packet) < 8+4+tagLen {
+        return nil, errShortPacket
+    }
+    ssrc := binary.BigEndian.Uint32(packet[4:])
+    state := ctx.stream(ssrc)
+    var trailer []byte
+    if ctx.rtcp.aead != nil {
+        trailer = packet[len(packet)-4:]
+    } else {
+        trailer = packet[len(packet)-tagLen-4 : len(packet)-tagLen]
+    }
+    encrypted := binary.BigEndian.Uint32(trailer)&rtcpEFlag != 0
+    index := binary.BigEndian.Uint32(trailer) & maxRtcpIndex
+    if !state.rtcpReplay.check(uint64(index)) {
+        return nil, errReplayed
+    }
+
+    var out []byte
+    if ctx.rtcp.aead != nil {
+        body := packet[8 : len(packet)-4]
+        aad := append(append([]byte{}, packet[:8]...), trailer...)
+        out = make([]byte, 8, len(packet))
+        copy(out, packet[:8])
+        var err error
+        if encrypted {
+            out, err = ctx.rtcp.aead.Open(out, ctx.rtcpNonce(ssrc, index), body, aad)
+        } else {
+            //the payload is authenticated only
+            n := len(body) - tagLen
+            aad = append(append([]byte{}, packet[:8+n]...), trailer...)
+            _, err = ctx.rtcp.aead.Open(nil, ctx.rtcpNonce(ssrc, index), body[n:], aad)
+            out = append(out, body[:n]...)
+        }
+        if err != nil {
+            return nil, errAuthFailed
+        }
+    } else {
+        n := len(packet) - tagLen
+        if !hmac.Equal(ctx.rtcpTag(packet[:n]), packet[n:]) {
+            return nil, errAuthFailed
+        }
+        out = make([]byte, n-4)
+        copy(out, packet[:n-4])
+        if encrypted {
+            cipher.NewCTR(ctx.rtcp.block, counterIV(ctx.rtcp.salt, ssrc, uint64(index))).XORKeyStream(out[8:], packet[8:n-4])
+        }
+    }
+    state.rtcpReplay.accept(uint64(index))
+    return out, nil
+}
+
+// IV = (k_s * 2^16) XOR (SSRC * 2^64) XOR (i * 2^16)
+func counterIV(salt []byte, ssrc uint32, index uint64) []byte {
+    iv := make([]byte, aes.BlockSize)
+    copy(iv, salt)
+    iv[4] ^= byte(ssrc >> 24)
+    iv[5] ^= byte(ssrc >> 16)
+    iv[6] ^= byte(ssrc >> 8)
+    iv[7] ^= byte(ssrc)
+    for i := 0; i < 6; i++ {
+        iv[13-i] ^= byte(index >> (8 * i))
+    }
+    return iv
+}
+
+// the authentication of rtp covers the packet and ROC
+func (ctx *Context) rtpTag(packet []byte, roc uint32) []byte {
+    var rocBytes [4]byte
+    binary.BigEndian.PutUint32(rocBytes[:], roc)
+    ctx.rtp.auth.Reset()
+    ctx.rtp.auth.Write(packet)
+    ctx.rtp.auth.Write(rocBytes[:])
+    return ctx.rtp.auth.Sum(nil)[:ctx.profile.rtpTagLen()]
+}
+
+func (ctx *Context) rtcpTag(packet []byte) []byte {
+    ctx.rtcp.auth.Reset()
+    ctx.rtcp.auth.Write(packet)
+    return ctx.rtcp.auth.Sum(nil)[:ctx.profile.rtcpTagLen()]
+}
+
+// rfc7714 8.1: 00 00 || SSRC || ROC || SEQ,XOR the salt
+func (ctx *Context) rtpNonce(ssrc uint32, roc uint32, seq uint16) []byte {
+    nonce := make([]byte, 12)
+    binary.BigEndian.PutUint32(nonce[2:], ssrc)
+    binary.BigEndian.PutUint32(nonce[6:], roc)
+    binary.BigEndian.PutUint16(nonce[10:], seq)
+    for i := range nonce {
+        nonce[i] ^= ctx.rtp.salt[i]
+    }
+    return nonce
+}
+
+// rfc7714 9.1: 00 00 || SSRC || 00 00 || 0 + SRTCP index,XOR the salt
+func (ctx *Context) rtcpNonce(ssrc uint32, index uint32) []byte {
+    nonce := make([]byte, 12)
+    binary.BigEndian.PutUint32(nonce[2:], ssrc)
+    binary.BigEndian.PutUint32(nonce[8:], index)
+    for i := range nonce {
+        nonce[i] ^= ctx.rtcp.salt[i]
+    }
+    return nonce
+}
+
+// the fixed header,CSRC list and header extension are not encrypted
+func rtpHeaderLen(packet []byte) (int, error) {
+    if len(packet) < 12 {
+        return 0, errShortPacket
+    }
+    n := 12 + 4*int(packet[0]&0x0F)
+    if packet[0]&0x10 != 0 {
+        if len(packet) < n+4 {
+            return 0, errShortPacket
+        }
+        n += 4 + 4*int(binary.BigEndian.Uint16(packet[n+2:]))
+    }
+    if len(packet) < n {
+        return 0, errShortPacket
+    }
+    return n, nil
+}
+
+// the rollover counter guessed from the sequence number,rfc3711 appendix A
+func (state *ssrcState) estimate(seq uint16) uint32 {
+    if !state.started {
+        return state.roc
+    }
+    if state.lastSeq < 0x8000 {
+        if int(seq)-int(state.lastSeq) > 0x8000 && state.roc > 0 {
+            return state.roc - 1
+        }
+    } else if int(state.lastSeq)-0x8000 > int(seq) {
+        return state.roc + 1
+    }
+    return state.roc
+}
+
+func (state *ssrcState) update(seq uint16, roc uint32) {
+    if !state.started {
+        state.started = true
+        state.roc = roc
+        state.lastSeq = seq
+        return
+    }
+    if roc == state.roc+1 || (roc == state.roc && seq > state.lastSeq) {
+        state.roc = roc
+        state.lastSeq = seq
+    }
+}
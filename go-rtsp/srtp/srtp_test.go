@@ -0,0 +1,226 @@
+package srtp
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rfc3711 appendix B.3
+func TestDeriveKey(t *testing.T) {
+	kdf, err := aes.NewCipher(unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt := unhex(t, "0EC675AD498AFEEBB6960B3AABE6")
+	tests := []struct {
+		name   string
+		label  byte
+		length int
+		want   string
+	}{
+		{"cipher_key", labelRtpEncryption, 16, "C61E7A93744F39EE10734AFE3FF7A087"},
+		{"cipher_salt", labelRtpSalt, 14, "30CBBC08863D8C85D49DB34A9AE1"},
+		{"auth_key", labelRtpAuth, 32, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA48F0A0ACF3C34E2359E6CDBCE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deriveKey(kdf, salt, tt.label, tt.length); !bytes.Equal(got, unhex(t, tt.want)) {
+				t.Errorf("deriveKey() = %X, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// rfc3711 appendix B.2
+func TestCounterKeystream(t *testing.T) {
+	block, err := aes.NewCipher(unhex(t, "2B7E151628AED2A6ABF7158809CF4F3C"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := counterIV(unhex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFD"), 0, 0)
+	if !bytes.Equal(iv, unhex(t, "F0F1F2F3F4F5F6F7F8F9FAFBFCFD0000")) {
+		t.Fatalf("counterIV() = %X", iv)
+	}
+	keystream := make([]byte, 48)
+	cipher.NewCTR(block, iv).XORKeyStream(keystream, keystream)
+	want := unhex(t, "E03EAD0935C95E80E166B16DD92B4EB4D23513162B02D0F72A43A2FE4A5F97AB41E95B3BB0A2E8DD477901E4FCA894C0")
+	if !bytes.Equal(keystream, want) {
+		t.Errorf("keystream = %X", keystream)
+	}
+}
+
+// the master key and salt of rfc3711 appendix B.3,the reference packet of libsrtp
+func TestContext_ProtectRtp(t *testing.T) {
+	ctx, err := NewContext(PROFILE_AES128_CM_HMAC_SHA1_80, unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"), unhex(t, "0EC675AD498AFEEBB6960B3AABE6"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := unhex(t, "800f1234decafbadcafebabeabababababababababababababababab")
+	want := unhex(t, "800f1234decafbadcafebabe4e55dc4ce79978d88ca4d215949d2402b78d6acc99ea179b8dbb")
+	got, err := ctx.ProtectRtp(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ProtectRtp() = %x", got)
+	}
+	recv, _ := NewContext(PROFILE_AES128_CM_HMAC_SHA1_80, unhex(t, "E1F97A0D3E018BE0D64FA32C06DE4139"), unhex(t, "0EC675AD498AFEEBB6960B3AABE6"))
+	if got, err = recv.UnprotectRtp(want); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("UnprotectRtp() = %x, %v", got, err)
+	}
+}
+
+// rfc7714 16.1.1,the session key and salt are used directly
+func TestContext_ProtectRtpGcm(t *testing.T) {
+	block, err := aes.NewCipher(unhex(t, "000102030405060708090a0b0c0d0e0f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, _ := cipher.NewGCM(block)
+	keys := sessionKeys{block: block, aead: aead, salt: unhex(t, "517569642070726f2071756f")}
+	ctx := &Context{profile: PROFILE_AEAD_AES_128_GCM, rtp: keys, rtcp: keys, streams: make(map[uint32]*ssrcState)}
+	if nonce := ctx.rtpNonce(0x5501a0b2, 0, 0xf17b); !bytes.Equal(nonce, unhex(t, "51753c6580c2726f20718414")) {
+		t.Fatalf("rtpNonce() = %x", nonce)
+	}
+	plain := unhex(t, "8040f17b8041f8d35501a0b247616c6c696120657374206f6d6e69732064697669736120696e207061727465732074726573")
+	want := unhex(t, "8040f17b8041f8d35501a0b2f24de3a3fb34de6cacba861c9d7e4bcabe633bd50d294e6f42a5f47a51c7d19b36de3adf8833899d7f27beb16a9152cf765ee4390cce")
+	got, err := ctx.ProtectRtp(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("ProtectRtp() = %x", got)
+	}
+	ctx.streams = make(map[uint32]*ssrcState)
+	if got, err = ctx.UnprotectRtp(want); err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("UnprotectRtp() = %x, %v", got, err)
+	}
+}
+
+func makeRtp(seq uint16, payload []byte) []byte {
+	pkt := make([]byte, 12, 12+len(payload))
+	pkt[0] = 0x80
+	pkt[1] = 96
+	binary.BigEndian.PutUint16(pkt[2:], seq)
+	binary.BigEndian.PutUint32(pkt[4:], uint32(seq)*160)
+	binary.BigEndian.PutUint32(pkt[8:], 0x11223344)
+	return append(pkt, payload...)
+}
+
+func TestContext_RoundTrip(t *testing.T) {
+	profiles := []ProtectionProfile{PROFILE_AES128_CM_HMAC_SHA1_80, PROFILE_AES128_CM_HMAC_SHA1_32, PROFILE_AEAD_AES_128_GCM, PROFILE_AEAD_AES_256_GCM}
+	for _, profile := range profiles {
+		t.Run(profile.String(), func(t *testing.T) {
+			attr, err := NewCryptoAttribute(1, profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			send, _ := attr.NewContext()
+			recv, _ := attr.NewContext()
+			payload := []byte("gomedia srtp payload")
+
+			//the sequence number wraps and the rollover counter is increased
+			var last []byte
+			for _, seq := range []uint16{65534, 65535, 0, 1} {
+				protected, err := send.ProtectRtp(makeRtp(seq, payload))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.Contains(protected, payload) {
+					t.Fatal("the payload is not encrypted")
+				}
+				plain, err := recv.UnprotectRtp(protected)
+				if err != nil {
+					t.Fatalf("seq %d: %v", seq, err)
+				}
+				if !bytes.Equal(plain, makeRtp(seq, payload)) {
+					t.Fatalf("seq %d: wrong packet", seq)
+				}
+				last = protected
+			}
+			if send.RolloverCounter(0x11223344) != 1 || recv.RolloverCounter(0x11223344) != 1 {
+				t.Fatal("the rollover counter is not 1")
+			}
+			if _, err = recv.UnprotectRtp(last); err != errReplayed {
+				t.Fatalf("the replayed packet is accepted,%v", err)
+			}
+			tampered, _ := send.ProtectRtp(makeRtp(2, payload))
+			tampered[14] ^= 0x01
+			if _, err = recv.UnprotectRtp(tampered); err != errAuthFailed {
+				t.Fatalf("the tampered packet is accepted,%v", err)
+			}
+
+			rtcp := unhex(t, "80c8000611223344e6b37d4f3c8d8f7b0000a0000000002a00001a40")
+			protected, err := send.ProtectRtcp(rtcp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := recv.UnprotectRtcp(protected)
+			if err != nil || !bytes.Equal(plain, rtcp) {
+				t.Fatalf("UnprotectRtcp() = %x, %v", plain, err)
+			}
+			if _, err = recv.UnprotectRtcp(protected); err != errReplayed {
+				t.Fatalf("the replayed rtcp is accepted,%v", err)
+			}
+		})
+	}
+}
+
+func TestReplayWindow(t *testing.T) {
+	w := replayWindow{}
+	for _, index := range []uint64{100, 102, 101, 200} {
+		if !w.check(index) {
+			t.Fatalf("index %d is rejected", index)
+		}
+		w.accept(index)
+	}
+	for _, index := range []uint64{100, 101, 102, 200, 136} {
+		if w.check(index) {
+			t.Fatalf("index %d is accepted", index)
+		}
+	}
+	if !w.check(137) || !w.check(199) {
+		t.Fatal("the index in window is rejected")
+	}
+}
+
+func TestCryptoAttribute(t *testing.T) {
+	value := "1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20 KDR=0"
+	attr, err := ParseCryptoAttribute(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr.Tag != 1 || attr.Profile != PROFILE_AES128_CM_HMAC_SHA1_80 || attr.Lifetime != "2^20" || len(attr.SessionParams) != 1 {
+		t.Fatalf("wrong crypto attribute %+v", attr)
+	}
+	if string(attr.MasterKey) != "YS___semctl () {" || len(attr.MasterSalt) != 14 {
+		t.Fatalf("wrong master key %q", attr.MasterKey)
+	}
+	if attr.Encode() != value {
+		t.Fatalf("Encode() = %s", attr.Encode())
+	}
+	if _, err = ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz|2^20|1:32"); err == nil {
+		t.Fatal("mki is accepted")
+	}
+	if _, err = ParseCryptoAttribute("1 F8_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz"); err == nil {
+		t.Fatal("unsupported suite is accepted")
+	}
+	//the packets must be encrypted and authenticated
+	for _, param := range []string{"UNENCRYPTED_SRTP", "UNENCRYPTED_SRTCP", "UNAUTHENTICATED_SRTP", "KDR=24", "FEC_ORDER=FEC_SRTP", "WSH=128"} {
+		if _, err = ParseCryptoAttribute("1 AES_CM_128_HMAC_SHA1_80 inline:WVNfX19zZW1jdGwgKCkgewkyMjA7fQp9CnVubGVz KDR=0 " + param); err == nil {
+			t.Errorf("session parameter %s is accepted", param)
+		}
+	}
+}